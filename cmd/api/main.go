@@ -1,94 +1,600 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
 	"log"
+	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
-	swaggerFiles "github.com/swaggo/files"
-	ginSwagger "github.com/swaggo/gin-swagger"
-	_ "github.com/yashjain/konnect/docs"
 
+	"github.com/yashjain/konnect/internal/apispec"
+	"github.com/yashjain/konnect/internal/app"
+	"github.com/yashjain/konnect/internal/audit"
+	"github.com/yashjain/konnect/internal/auth"
 	"github.com/yashjain/konnect/internal/config"
 	"github.com/yashjain/konnect/internal/database"
 	"github.com/yashjain/konnect/internal/handlers"
+	"github.com/yashjain/konnect/internal/kong"
+	"github.com/yashjain/konnect/internal/logging"
+	"github.com/yashjain/konnect/internal/mail"
+	"github.com/yashjain/konnect/internal/metrics"
+	"github.com/yashjain/konnect/internal/middleware"
+	"github.com/yashjain/konnect/internal/openapi"
+	"github.com/yashjain/konnect/internal/outbox"
+	"github.com/yashjain/konnect/internal/render"
+	"github.com/yashjain/konnect/internal/repository"
+	"github.com/yashjain/konnect/internal/search"
+	"github.com/yashjain/konnect/internal/search/elasticsearch"
+	"github.com/yashjain/konnect/internal/sentry"
 )
 
-// @title Services API
-// @version 1.0
-// @description A REST API for managing services and their versions
-// @termsOfService http://swagger.io/terms/
-
-// @contact.name API Support
-// @contact.url http://www.swagger.io/support
-// @contact.email support@swagger.io
-
-// @license.name MIT
-// @license.url https://opensource.org/licenses/MIT
+func main() {
+	bootstrap := flag.Bool("bootstrap", false, "create the database schema and demo data on startup, like DEV_MODE=true")
+	flag.Parse()
 
-// @host localhost:8080
-// @BasePath /api/v1
-// @schemes http https
+	// Redact secrets and data values from everything that is logged, including
+	// what is logged while the configuration is loaded
+	log.SetOutput(logging.NewWriter(os.Stderr))
+	gin.DefaultWriter = logging.NewWriter(os.Stdout)
+	gin.DefaultErrorWriter = logging.NewWriter(os.Stderr)
 
-func main() {
 	// Load configuration
 	cfg := config.Load()
 
+	// Log structured records from here on, at a level that can be reloaded
+	parsed, err := logging.ParseLevel(cfg.LogLevel)
+	if err != nil {
+		slog.Warn("Invalid LOG_LEVEL, using info", "error", err)
+	}
+	level := new(slog.LevelVar)
+	level.Set(parsed)
+	logger, err := logging.New(os.Stderr, level, cfg.LogFormat)
+	if err != nil {
+		slog.Warn("Invalid LOG_FORMAT, using json", "error", err)
+		logger, _ = logging.New(os.Stderr, level, logging.FormatJSON)
+	}
+	slog.SetDefault(logger)
+
+	// Apply configuration changes on SIGHUP and POST /admin/config/reload
+	reloader := config.NewReloader()
+	reloader.OnReload(func(settings config.Reloadable) {
+		if parsed, err := logging.ParseLevel(settings.LogLevel); err == nil {
+			level.Set(parsed)
+		}
+	})
+	go reloadOnHangup(reloader)
+
 	// Initialize database
-	if err := database.Init(); err != nil {
-		log.Fatal("Failed to initialize database:", err)
+	store, err := database.Open()
+	if err != nil {
+		fatal("Failed to initialize database", err)
 	}
 	defer func() {
-		if err := database.Close(); err != nil {
-			log.Printf("Error closing database: %v", err)
+		if err := store.Close(); err != nil {
+			slog.Warn("Error closing database", "error", err)
 		}
 	}()
 
+	// In dev mode, create the schema and demo data so a fresh database works out of the box
+	if *bootstrap || cfg.DevMode {
+		if err := store.Migrate(context.Background()); err != nil {
+			fatal("Failed to bootstrap database schema", err)
+		}
+		slog.Info("Database schema bootstrapped")
+	}
+
+	// Publish connection pool statistics so pool exhaustion shows up before it causes an outage
+	for name, db := range store.Pools() {
+		metrics.RegisterDBStats(name, db)
+	}
+
+	// Watch the database so outages and recovery show up in the health endpoints
+	go store.Supervise(context.Background(), cfg.Database.HealthInterval)
+
+	// Deliver the change events recorded in the outbox
+	if cfg.Outbox.WebhookURL != "" {
+		publisher := outbox.NewWebhookPublisher(cfg.Outbox.WebhookURL, cfg.Outbox.WebhookSecret)
+		go outbox.NewRelay(store, publisher, cfg.Outbox.PollInterval, cfg.Outbox.BatchSize).Run(context.Background())
+	}
+
+	// Deliver the change events queued for webhook subscriptions
+	retry := outbox.RetryPolicy{MaxAttempts: cfg.Webhooks.MaxAttempts, Base: cfg.Webhooks.RetryBase, Max: cfg.Webhooks.RetryMax}
+	dispatcher := outbox.NewDispatcher(store, store, cfg.Webhooks.ServiceURL, cfg.Webhooks.PollInterval, cfg.Webhooks.Timeout, cfg.Webhooks.Retention, retry)
+	go dispatcher.Run(context.Background())
+
+	// Sync services to the Kong Gateway as they change
+	var kongSyncer handlers.KongSyncer
+	var kongReader handlers.KongReader
+	if cfg.Kong.AdminURL != "" {
+		client := kong.New(cfg.Kong.AdminURL, cfg.Kong.AdminToken, cfg.Kong.Timeout)
+		retry := outbox.RetryPolicy{MaxAttempts: cfg.Kong.MaxAttempts, Base: cfg.Kong.RetryBase, Max: cfg.Kong.RetryMax}
+		syncer := outbox.NewKongSyncer(store, store, store, client, cfg.Kong.UpstreamURL, cfg.Kong.PollInterval, retry)
+		go syncer.Run(context.Background())
+		kongSyncer, kongReader = syncer, client
+	}
+
+	// Email service owners and subscribers about deprecated versions
+	if cfg.SMTP.Host != "" {
+		sender := mail.NewSMTP(cfg.SMTP.Host, cfg.SMTP.Port, cfg.SMTP.Username, cfg.SMTP.Password, cfg.SMTP.From, cfg.SMTP.Timeout)
+		retry := outbox.RetryPolicy{MaxAttempts: cfg.SMTP.MaxAttempts, Base: cfg.SMTP.RetryBase, Max: cfg.SMTP.RetryMax}
+		go outbox.NewEmailNotifier(store, store, store, sender, cfg.Webhooks.ServiceURL, cfg.SMTP.PollInterval, retry).Run(context.Background())
+	}
+
+	// Export audit entries to the SIEM
+	sink, err := auditSink(cfg.Audit)
+	if err != nil {
+		fatal("Failed to configure audit export", err)
+	}
+	if sink != nil {
+		exporter := audit.NewExporter(sink, cfg.Audit.QueueSize, cfg.Audit.BatchSize, cfg.Audit.BlockTimeout)
+		go exporter.Run(context.Background())
+		audit.SetExporter(exporter)
+	}
+
+	// Serve search from the configured backend, timing each call to the database
+	repo, searchDeps, err := searchRepository(cfg.Search, repository.NewInstrumentedRepository(store))
+	if err != nil {
+		fatal("Failed to initialize search backend", err)
+	}
+
+	// Expand searches with their synonyms, kept in step with the database
+	synonyms := search.NewSynonymRepository(repo, cfg.Search.SynonymsRefresh)
+	if err := synonyms.Load(context.Background()); err != nil {
+		slog.Error("Error loading search synonyms", "error", err)
+	}
+	go synonyms.Run(context.Background())
+	repo = synonyms
+
+	// Answer identical searches from memory for a few seconds
+	if cfg.Search.CacheTTL > 0 {
+		repo = search.NewCachedRepository(repo, cfg.Search.CacheTTL, cfg.Search.CacheSize)
+	}
+
+	// Record a sample of searches for search analytics, including cached ones
+	if cfg.Search.AnalyticsSampleRate > 0 {
+		repo = search.NewAnalyticsRepository(repo, cfg.Search.AnalyticsSampleRate)
+	}
+
+	// Deprecate versions once their scheduled deprecation date passes, and remind
+	// of sunsets. It goes through repo so the deprecations drop cached searches.
+	go outbox.NewDeprecationScheduler(repo, cfg.DeprecationPollInterval, cfg.SunsetReminderLead).Run(context.Background())
+
+	// Report panics and server errors to Sentry
+	var reporter middleware.ErrorReporter
+	if cfg.Sentry.DSN != "" {
+		client, err := sentry.New(cfg.Sentry.DSN, cfg.Sentry.Environment, cfg.Sentry.Timeout)
+		if err != nil {
+			fatal("Failed to configure Sentry", err)
+		}
+		go client.Run(context.Background())
+		reporter = client
+	}
+
+	// Log request and response bodies when enabled on the admin listener
+	capture := middleware.NewBodyCapture(cfg.DebugCapture.MaxBytes, cfg.DebugCapture.MaxTTL)
+
 	// Setup router
-	router := setupRouter(cfg)
+	deps := append([]handlers.Dependency{{Name: "database", Critical: true, Check: repo.Ping}}, searchDeps...)
+	router := setupRouter(cfg, repo, deps, reporter, reloader, capture, dispatcher, kongSyncer, kongReader)
+
+	// Admin endpoints get their own listener, only when an admin token is configured
+	if cfg.Auth.AdminToken != "" && cfg.AdminAddr != "" {
+		go serveAdmin(cfg, repo, reporter, reloader, level, capture)
+	}
+
+	server := &http.Server{
+		Addr:    ":" + cfg.Port,
+		Handler: router,
+	}
 
 	// Start server
-	log.Printf("Server starting on port %s", cfg.Port)
-	if err := http.ListenAndServe(":"+cfg.Port, router); err != nil {
-		log.Printf("Server failed to start: %v", err)
+	if cfg.TLS.Enabled() {
+		tlsConfig, err := buildTLSConfig(cfg.TLS)
+		if err != nil {
+			fatal("Failed to configure TLS", err)
+		}
+		server.TLSConfig = tlsConfig
+
+		slog.Info("Server starting with TLS", "port", cfg.Port)
+		if err := server.ListenAndServeTLS(cfg.TLS.CertFile, cfg.TLS.KeyFile); err != nil {
+			slog.Error("Server failed to start", "error", err)
+		}
+		return
+	}
+
+	slog.Info("Server starting", "port", cfg.Port)
+	if err := server.ListenAndServe(); err != nil {
+		slog.Error("Server failed to start", "error", err)
+	}
+}
+
+// reloadOnHangup reloads the configuration each time the process receives SIGHUP
+func reloadOnHangup(reloader *config.Reloader) {
+	hangups := make(chan os.Signal, 1)
+	signal.Notify(hangups, syscall.SIGHUP)
+	for range hangups {
+		if _, err := reloader.Reload("SIGHUP"); err != nil {
+			slog.Error("Failed to reload configuration", "error", err)
+		}
+	}
+}
+
+// serveOpenAPI serves the OpenAPI document of the routes registered on r so
+// far at /openapi.json. Routes whose handlers spec does not document stop the
+// server from starting, so that the document covers every route.
+func serveOpenAPI(r *gin.Engine, spec openapi.Spec) {
+	doc, err := openapi.Generate(spec, r.Routes())
+	if err != nil {
+		fatal("Error generating the OpenAPI document", err)
+	}
+	r.GET("/openapi.json", handlers.OpenAPI(doc))
+}
+
+// fatal logs an error the server cannot start without and exits
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
+}
+
+// auditSink returns the sink audit entries are exported to, or nil when none is configured
+func auditSink(cfg config.AuditConfig) (audit.Sink, error) {
+	switch {
+	case cfg.WebhookURL != "" && cfg.SyslogAddr != "":
+		return nil, errors.New("set only one of AUDIT_WEBHOOK_URL and AUDIT_SYSLOG_ADDR")
+	case cfg.WebhookURL != "":
+		return audit.NewWebhookSink(cfg.WebhookURL, cfg.WebhookSecret, cfg.Timeout), nil
+	case cfg.SyslogAddr != "":
+		return audit.NewSyslogSink(cfg.SyslogAddr, cfg.Timeout)
+	default:
+		return nil, nil
+	}
+}
+
+// searchRepository returns repo searching through the configured backend, and
+// the dependency the backend adds, if any. An external backend's index is
+// created when missing; ReindexServices fills it.
+func searchRepository(cfg config.SearchConfig, repo repository.Repository) (repository.Repository, []handlers.Dependency, error) {
+	switch cfg.Backend {
+	case config.SearchBackendDatabase:
+		return repo, nil, nil
+	case config.SearchBackendElasticsearch:
+		backend := elasticsearch.New(cfg.ElasticsearchURL, cfg.ElasticsearchIndex, cfg.ElasticsearchUsername, cfg.ElasticsearchPassword, cfg.ElasticsearchTimeout, cfg.Weights)
+		if err := backend.EnsureIndex(context.Background()); err != nil {
+			return nil, nil, err
+		}
+		// Only searches need the backend, so it is not critical
+		return search.NewIndexedRepository(repo, backend), []handlers.Dependency{{Name: "search", Check: backend.Ping}}, nil
+	default:
+		return nil, nil, fmt.Errorf("invalid SEARCH_BACKEND %q: must be database or elasticsearch", cfg.Backend)
+	}
+}
+
+// buildTLSConfig returns the server TLS configuration, requiring client
+// certificates signed by the configured CA bundle when one is set
+func buildTLSConfig(cfg config.TLSConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if cfg.ClientCAFile == "" {
+		return tlsConfig, nil
+	}
+
+	pem, err := os.ReadFile(cfg.ClientCAFile)
+	if err != nil {
+		return nil, err
 	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in %s", cfg.ClientCAFile)
+	}
+	tlsConfig.ClientCAs = pool
+
+	switch cfg.ClientAuth {
+	case "require":
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	case "optional":
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	default:
+		return nil, fmt.Errorf("invalid TLS_CLIENT_AUTH %q: must be require or optional", cfg.ClientAuth)
+	}
+
+	return tlsConfig, nil
 }
 
-// setupRouter configures the Gin router with all routes
-func setupRouter(cfg *config.Config) *gin.Engine {
-	// Set Gin mode based on configuration
-	if cfg.LogLevel == "info" {
+// setupRouter configures the Gin router with all routes, checking deps for
+// readiness, reporting errors to reporter when it is not nil, applying the
+// settings reloaded by reloader, logging the bodies selected by capture,
+// sending test webhook deliveries with webhooks, syncing services to Kong with
+// kongSyncer and importing them from Kong with kongReader, when they are not nil
+func setupRouter(cfg *config.Config, repo repository.Repository, deps []handlers.Dependency, reporter middleware.ErrorReporter, reloader *config.Reloader, capture *middleware.BodyCapture, webhooks handlers.WebhookSender, kongSyncer handlers.KongSyncer, kongReader handlers.KongReader) *gin.Engine {
+	// Gin only prints its debug output at the debug log level
+	if level, _ := logging.ParseLevel(cfg.LogLevel); level > slog.LevelDebug {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
-	r := gin.Default()
+	accessLog := middleware.NewAccessLogger(cfg.AccessLog)
+	stats := middleware.NewRequestStats()
+	r := gin.New()
+	r.Use(middleware.RequestID(), accessLog.Handler(), stats.Handler())
+	// Outside Problems, so problem details are rendered in the negotiated media type too
+	r.Use(middleware.Negotiate(render.MsgPack{}, render.XML{}))
+	if !cfg.LegacyErrors {
+		// Inside the access log, which logs the rewritten body's size, and
+		// outside Recover, so panics are answered with problem details too
+		r.Use(middleware.Problems())
+	}
+	r.Use(middleware.Recover())
+	if reporter != nil {
+		r.Use(middleware.ReportErrors(reporter))
+	}
+
+	r.Use(capture.Handler())
+
+	// Record verified mTLS client identities on audit entries
+	r.Use(middleware.ClientCert())
+
+	// Health check endpoints
+	r.GET("/health", handlers.HealthCheck(repo))
+	r.GET("/ready", handlers.Readiness(repo))
+	r.GET("/health/ready", handlers.DeepReadiness(deps...))
+
+	// Public status page data
+	r.GET("/status", handlers.Status(time.Now(), stats, deps...))
+
+	// Failed credentials from any entry point share one brute-force tracker
+	lockout := auth.NewLockout(cfg.Auth.LockoutThreshold, cfg.Auth.LockoutBase, cfg.Auth.LockoutMax)
+
+	// Requests beyond the in-flight limits are shed; health checks are exempt
+	inFlight := middleware.NewLimiter("global", cfg.LoadShed.MaxInFlight)
+	apiInFlight := middleware.NewLimiter("api", cfg.LoadShed.MaxInFlightAPI)
+	authInFlight := middleware.NewLimiter("auth", cfg.LoadShed.MaxInFlightAuth)
 
-	// Swagger endpoint
-	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+	reloader.OnReload(func(settings config.Reloadable) {
+		accessLog.Update(settings.AccessLog)
+		inFlight.SetMax(settings.LoadShed.MaxInFlight)
+		apiInFlight.SetMax(settings.LoadShed.MaxInFlightAPI)
+		authInFlight.SetMax(settings.LoadShed.MaxInFlightAuth)
+	})
 
-	// Health check endpoint
-	r.GET("/health", handlers.HealthCheck)
+	// GitHub events, authenticated by the signature of a linked repository's secret rather than a token
+	r.POST("/integrations/github/events", middleware.Shed(cfg.LoadShed.RetryAfter, inFlight, apiInFlight),
+		handlers.GitHubEvents(repo, repo))
 
 	// API routes
-	setupAPIRoutes(r)
+	setupAPIRoutes(r, cfg, repo, webhooks, kongSyncer, kongReader, lockout, inFlight, apiInFlight)
 
+	// Login endpoints are only exposed when access tokens can be signed
+	if cfg.Auth.SigningKey != "" {
+		setupAuthRoutes(r, cfg, repo, lockout, inFlight, authInFlight)
+	}
+
+	serveOpenAPI(r, handlers.APISpec(cfg.LegacyErrors))
 	return r
 }
 
-// setupAPIRoutes configures all API routes
-func setupAPIRoutes(r *gin.Engine) {
-	api := r.Group("/api/v1")
+// setupAPIRoutes configures the routes of every API version, under the given
+// in-flight limits. v2 answers the same routes as v1, which is deprecated, with
+// problem details whether or not legacy errors are enabled, and pages lists by
+// cursor alone.
+func setupAPIRoutes(r *gin.Engine, cfg *config.Config, repo repository.Repository, webhooks handlers.WebhookSender, kongSyncer handlers.KongSyncer, kongReader handlers.KongReader, lockout *auth.Lockout, limits ...*middleware.Limiter) {
+	linter := apispec.NewLinter(cfg.SpecLintRules)
+
+	v1 := r.Group("/api/v1")
+	v1.Use(middleware.Deprecated(cfg.APIV1.DeprecatedAt, cfg.APIV1.Sunset, "/api/v1", "/api/v2"))
+	registerAPIRoutes(v1, cfg, repo, webhooks, kongSyncer, kongReader, linter, lockout, limits...)
+
+	v2 := r.Group("/api/v2")
+	v2.Use(middleware.Versioned(2))
+	if cfg.LegacyErrors {
+		// Legacy errors are kept for v1 clients only; the inner Recover answers
+		// panics with problem details too
+		v2.Use(middleware.Problems(), middleware.Recover())
+	}
+	registerAPIRoutes(v2, cfg, repo, webhooks, kongSyncer, kongReader, linter, lockout, limits...)
+}
+
+// registerAPIRoutes registers the API routes on api, under the given in-flight
+// limits. Kong sync and import routes are only registered when kongSyncer and
+// kongReader are not nil. OpenAPI documents are scored by linter.
+func registerAPIRoutes(api *gin.RouterGroup, cfg *config.Config, repo repository.Repository, webhooks handlers.WebhookSender, kongSyncer handlers.KongSyncer, kongReader handlers.KongReader, linter *apispec.Linter, lockout *auth.Lockout, limits ...*middleware.Limiter) {
+	api.Use(middleware.JSONAPI())
+	api.Use(middleware.Shed(cfg.LoadShed.RetryAfter, limits...))
+	api.Use(middleware.Auth(cfg.Auth, repo, lockout))
+
+	var releaseGate *app.ReleaseGate
+	if cfg.BlockBreakingReleases {
+		releaseGate = &app.ReleaseGate{Versions: repo, Specs: repo}
+	}
 	{
+		// Global search across entities
+		api.GET("/search", handlers.GlobalSearch(repo, repo))
+
 		// Service routes
-		api.GET("/services", handlers.GetServices)
-		api.GET("/services/search", handlers.SearchServices)
-		api.POST("/services", handlers.CreateService)
-		api.GET("/services/:id", handlers.GetService)
-		api.PUT("/services/:id", handlers.UpdateService)
-		api.DELETE("/services/:id", handlers.DeleteService)
+		api.GET("/services", handlers.GetServices(repo))
+		api.GET("/services/search", handlers.SearchServices(repo))
+		api.GET("/services/suggest", handlers.SuggestServices(repo))
+		api.GET("/services/export", handlers.ExportServices(repo))
+		api.POST("/services", handlers.CreateService(repo))
+		api.GET("/services/:id", handlers.GetService(repo, repo))
+		api.PUT("/services/:id", handlers.UpdateService(repo, repo))
+		api.DELETE("/services/:id", handlers.DeleteService(repo, repo))
+		api.GET("/teams/:team/services", handlers.GetTeamServices(repo))
+		api.GET("/services/:id/consumers", handlers.GetConsumers(repo, repo))
+		api.POST("/services/:id/consumers", handlers.CreateConsumer(repo, repo))
+		api.DELETE("/services/:id/consumers/:consumer_id", handlers.DeleteConsumer(repo, repo))
 
 		// Version routes
-		api.GET("/services/:id/versions", handlers.GetVersions)
-		api.POST("/services/:id/versions", handlers.CreateVersion)
+		api.GET("/services/:id/versions", handlers.GetVersions(repo, repo))
+		api.POST("/services/:id/versions", handlers.CreateVersion(repo, repo))
+		api.GET("/services/:id/versions/export", handlers.ExportVersions(repo, repo))
+		api.GET("/services/:id/versions/compare-spec", handlers.CompareVersionSpecs(repo, repo, repo))
+		api.GET("/services/:id/versions/feed.atom", handlers.GetServiceAtomFeed(repo, repo, repo))
+		api.GET("/services/:id/versions/feed.rss", handlers.GetServiceRSSFeed(repo, repo, repo))
+		api.GET("/versions/feed.atom", handlers.GetCatalogAtomFeed(repo, repo))
+		api.GET("/versions/feed.rss", handlers.GetCatalogRSSFeed(repo, repo))
+		api.GET("/calendar.ics", handlers.GetDeprecationCalendar(repo, repo, repo))
+		api.GET("/services/:id/versions/:version_id", handlers.GetVersion(repo, repo))
+		api.GET("/services/:id/versions/:version_id/deployments", handlers.GetDeployments(repo, repo))
+		api.POST("/services/:id/versions/:version_id/deployments", handlers.CreateDeployment(repo, repo))
+		api.PUT("/services/:id/versions/:version_id/deprecation", handlers.ScheduleDeprecation(repo, repo))
+		api.DELETE("/services/:id/versions/:version_id/deprecation", handlers.CancelDeprecation(repo, repo))
+		api.GET("/services/:id/versions/:version_id/spec", handlers.GetVersionSpec(repo, repo))
+		api.PUT("/services/:id/versions/:version_id/spec", handlers.PutVersionSpec(repo, repo, linter))
+		api.DELETE("/services/:id/versions/:version_id/spec", handlers.DeleteVersionSpec(repo, repo))
+		api.GET("/services/:id/versions/:version_id/spec/lint", handlers.LintVersionSpec(repo, repo, linter))
+
+		// Catalog routes
+		api.GET("/export", handlers.ExportCatalog(repo, repo))
+		api.GET("/export/backstage", handlers.ExportBackstage(repo, repo))
+		api.POST("/import", handlers.ImportCatalog(repo, repo, repo, releaseGate))
+
+		// Declarative config routes
+		api.GET("/config/dump", handlers.DumpConfig(repo, repo))
+		api.POST("/config/apply", handlers.ApplyConfig(repo, repo, repo, releaseGate))
+
+		// Category routes
+		api.GET("/categories", handlers.GetCategories(repo))
+		api.POST("/categories", handlers.CreateCategory(repo))
+		api.GET("/categories/:id", handlers.GetCategory(repo))
+		api.PUT("/categories/:id", handlers.UpdateCategory(repo))
+		api.DELETE("/categories/:id", handlers.DeleteCategory(repo))
+		api.GET("/services/:id/categories", handlers.GetServiceCategories(repo, repo))
+		api.PUT("/services/:id/categories/:category_id", handlers.AddServiceCategory(repo, repo))
+		api.DELETE("/services/:id/categories/:category_id", handlers.RemoveServiceCategory(repo, repo))
+
+		// GitHub repository routes
+		api.GET("/services/:id/github", handlers.GetGitHubRepository(repo, repo))
+		api.PUT("/services/:id/github", handlers.SetGitHubRepository(repo, repo))
+		api.DELETE("/services/:id/github", handlers.DeleteGitHubRepository(repo, repo))
+
+		// Access control routes
+		api.GET("/services/:id/acl", handlers.GetServiceACLs(repo))
+		api.POST("/services/:id/acl", handlers.CreateServiceACL(repo))
+		api.DELETE("/services/:id/acl/:acl_id", handlers.DeleteServiceACL(repo))
+
+		// Notification routes
+		api.GET("/notifications/preferences", handlers.GetNotificationPreferences(repo))
+		api.PUT("/notifications/preferences", handlers.SetNotificationPreferences(repo))
+		api.PUT("/services/:id/subscription", handlers.SubscribeToService(repo, repo))
+		api.DELETE("/services/:id/subscription", handlers.UnsubscribeFromService(repo))
+
+		// Webhook subscription routes
+		api.GET("/webhooks", handlers.GetWebhookSubscriptions(repo))
+		api.POST("/webhooks", handlers.CreateWebhookSubscription(repo))
+		api.GET("/webhooks/:id", handlers.GetWebhookSubscription(repo))
+		api.PUT("/webhooks/:id", handlers.UpdateWebhookSubscription(repo))
+		api.DELETE("/webhooks/:id", handlers.DeleteWebhookSubscription(repo))
+		api.GET("/webhooks/:id/deliveries", handlers.GetWebhookDeliveries(repo))
+		api.POST("/webhooks/:id/test", handlers.TestWebhookSubscription(repo, webhooks))
+
+		// Kong routes
+		if kongSyncer != nil {
+			api.GET("/services/:id/sync", handlers.GetServiceSync(repo, repo))
+			api.POST("/services/:id/sync", handlers.SyncService(kongSyncer, repo))
+		}
+		if kongReader != nil {
+			api.POST("/import/kong", handlers.ImportKong(kongReader, repo, repo, repo))
+		}
+	}
+}
+
+// setupAuthRoutes configures login and token refresh routes, under the given in-flight limits
+func setupAuthRoutes(r *gin.Engine, cfg *config.Config, repo repository.Repository, lockout *auth.Lockout, limits ...*middleware.Limiter) {
+	authGroup := r.Group("/auth")
+	authGroup.Use(middleware.Shed(cfg.LoadShed.RetryAfter, limits...))
+	{
+		authGroup.POST("/login", handlers.Login(cfg.Auth, repo, lockout))
+		authGroup.POST("/refresh", handlers.Refresh(cfg.Auth, repo, lockout))
+	}
+}
+
+// serveAdmin runs the admin listener, which is bound to localhost by default
+func serveAdmin(cfg *config.Config, repo repository.Repository, reporter middleware.ErrorReporter, reloader *config.Reloader, level *slog.LevelVar, capture *middleware.BodyCapture) {
+	server := &http.Server{
+		Addr:    cfg.AdminAddr,
+		Handler: setupAdminRouter(cfg, repo, reporter, reloader, level, capture),
+	}
+
+	slog.Info("Admin server starting", "addr", cfg.AdminAddr)
+	if err := server.ListenAndServe(); err != nil {
+		slog.Error("Admin server failed to start", "error", err)
+	}
+}
+
+// setupAdminRouter configures the admin router with tenant administration,
+// maintenance, metrics and, when enabled, profiling routes, all guarded by the
+// admin token. Errors are reported to reporter when it is not nil, the
+// configuration is reloaded through reloader, the log level set on level and
+// body capture rules added to capture.
+func setupAdminRouter(cfg *config.Config, repo repository.Repository, reporter middleware.ErrorReporter, reloader *config.Reloader, level *slog.LevelVar, capture *middleware.BodyCapture) *gin.Engine {
+	accessLog := middleware.NewAccessLogger(cfg.AccessLog)
+	reloader.OnReload(func(settings config.Reloadable) {
+		accessLog.Update(settings.AccessLog)
+	})
+
+	r := gin.New()
+	r.Use(middleware.RequestID(), accessLog.Handler(), middleware.Recover())
+	if reporter != nil {
+		r.Use(middleware.ReportErrors(reporter))
+	}
+	r.Use(middleware.AdminAuth(cfg.Auth.AdminToken))
+
+	admin := r.Group("/admin")
+	{
+		// Tenant administration
+		admin.GET("/organizations", handlers.GetOrganizations(repo))
+		admin.POST("/organizations", handlers.CreateOrganization(repo))
+		admin.POST("/organizations/:id/tokens", handlers.CreateAPIToken(repo, repo))
+		admin.POST("/organizations/:id/users", handlers.CreateUser(repo))
+		admin.POST("/organizations/:id/teams", handlers.CreateTeam(repo))
+		admin.PUT("/organizations/:id/teams/:team_id/members/:user_id", handlers.AddTeamMember(repo))
+
+		// Maintenance
+		admin.POST("/maintenance/reindex", handlers.ReindexSearch(repo))
+		admin.POST("/maintenance/archive-versions", handlers.ArchiveVersions(repo))
+
+		// Search analytics
+		admin.GET("/search/analytics", handlers.SearchAnalytics(repo, cfg.Search.AnalyticsSampleRate))
+
+		// Search synonyms
+		admin.GET("/search/synonyms", handlers.GetSynonyms(repo))
+		admin.PUT("/search/synonyms/:term", handlers.SetSynonyms(repo))
+		admin.DELETE("/search/synonyms/:term", handlers.DeleteSynonyms(repo))
+
+		// Backup and restore
+		admin.GET("/backup", handlers.ExportBackup(repo))
+		admin.POST("/restore", handlers.RestoreBackup(repo))
+
+		// Configuration
+		admin.POST("/config/reload", handlers.ReloadConfig(reloader))
+		admin.GET("/loglevel", handlers.GetLogLevel(level))
+		admin.PUT("/loglevel", handlers.SetLogLevel(level))
+
+		// Debugging
+		admin.GET("/debug/capture", handlers.GetDebugCapture(capture))
+		admin.POST("/debug/capture", handlers.EnableDebugCapture(capture))
+		admin.DELETE("/debug/capture", handlers.DisableDebugCapture(capture))
+	}
+
+	// Admin errors are not rewritten as problem details
+	serveOpenAPI(r, handlers.APISpec(true))
+
+	// Metrics
+	r.GET("/metrics", gin.WrapH(metrics.Handler()))
+
+	// Profiling
+	if cfg.Pprof {
+		r.GET("/debug/pprof/*profile", handlers.Pprof)
+		r.POST("/debug/pprof/*profile", handlers.Pprof)
 	}
+
+	return r
 }
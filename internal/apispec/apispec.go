@@ -0,0 +1,417 @@
+// Package apispec parses and validates the OpenAPI documents attached to
+// versions, and converts them between JSON and YAML. Documents are kept as YAML
+// nodes, which JSON is a subset of, so that converting them keeps the order of
+// their keys.
+package apispec
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Document formats
+const (
+	FormatJSON = "json"
+	FormatYAML = "yaml"
+)
+
+const (
+	// MaxProblems bounds the problems a ValidationError lists
+	MaxProblems = 20
+
+	// maxNodes bounds the nodes of a document once its aliases are expanded, so
+	// that a small YAML document cannot expand into a huge one
+	maxNodes = 1_000_000
+)
+
+// ErrInvalidSpec is returned for documents that are not valid OpenAPI 3.0 or 3.1
+var ErrInvalidSpec = errors.New("invalid OpenAPI document")
+
+// ValidationError lists what is wrong with a document, each problem prefixed
+// with the location it was found at, such as paths./pets.get.responses
+type ValidationError struct {
+	Problems []string
+}
+
+// Error implements error
+func (e *ValidationError) Error() string {
+	return ErrInvalidSpec.Error() + ": " + strings.Join(e.Problems, "; ")
+}
+
+// Is makes a ValidationError match ErrInvalidSpec
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrInvalidSpec
+}
+
+// Document is a parsed OpenAPI document
+type Document struct {
+	// OpenAPI is the version of the OpenAPI specification the document follows, such as 3.1.0
+	OpenAPI string
+
+	// Title and Version are those of the document's info, Version being the
+	// version of the API it describes
+	Title   string
+	Version string
+
+	root *yaml.Node
+}
+
+var (
+	openAPIVersion = regexp.MustCompile(`^3\.[01]\.\d+(-[0-9A-Za-z.-]+)?$`)
+	responseCode   = regexp.MustCompile(`^([1-5][0-9][0-9]|[1-5]XX|default)$`)
+)
+
+// methods are the operations of a path item, in the order they are listed
+var methods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+// Parse parses and validates an OpenAPI 3.0 or 3.1 document, as JSON or YAML,
+// returning a ValidationError when it is not valid
+func Parse(data []byte) (*Document, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, &ValidationError{Problems: []string{err.Error()}}
+	}
+	if root.Kind != yaml.DocumentNode || len(root.Content) == 0 {
+		return nil, &ValidationError{Problems: []string{"document is empty"}}
+	}
+	if nodes := countNodes(&root, 0); nodes > maxNodes {
+		return nil, &ValidationError{Problems: []string{fmt.Sprintf("document expands to more than %d nodes", maxNodes)}}
+	}
+
+	doc := &Document{root: root.Content[0]}
+	v := &validator{}
+	v.document(doc)
+	if len(v.problems) > 0 {
+		return nil, &ValidationError{Problems: v.problems}
+	}
+	return doc, nil
+}
+
+// FormatOf returns the format of a document, JSON when it is a JSON object and YAML otherwise
+func FormatOf(data []byte) string {
+	trimmed := bytes.TrimLeft(data, " \t\r\n")
+	if len(trimmed) > 0 && trimmed[0] == '{' && json.Valid(data) {
+		return FormatJSON
+	}
+	return FormatYAML
+}
+
+// Encode writes the document in format. Keys keep the order they were parsed in.
+func (d *Document) Encode(format string) ([]byte, error) {
+	if format == FormatJSON {
+		var buf bytes.Buffer
+		if err := writeJSON(&buf, d.root); err != nil {
+			return nil, err
+		}
+		var indented bytes.Buffer
+		if err := json.Indent(&indented, buf.Bytes(), "", "  "); err != nil {
+			return nil, err
+		}
+		indented.WriteByte('\n')
+		return indented.Bytes(), nil
+	}
+
+	restyle(d.root)
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(d.root); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// validator collects the problems of a document, up to MaxProblems
+type validator struct {
+	problems []string
+
+	// operationIDs are the operationIds seen so far, by the location of their operation
+	operationIDs map[string]string
+}
+
+// problem records a problem at a location
+func (v *validator) problem(at, format string, args ...interface{}) {
+	if len(v.problems) == MaxProblems {
+		return
+	}
+	msg := fmt.Sprintf(format, args...)
+	if at != "" {
+		msg = at + ": " + msg
+	}
+	v.problems = append(v.problems, msg)
+}
+
+// document checks the root of a document and fills its version and info
+func (v *validator) document(doc *Document) {
+	root := doc.root
+	if root.Kind != yaml.MappingNode {
+		v.problem("", "document must be an object")
+		return
+	}
+	if field(root, "swagger") != nil {
+		v.problem("swagger", "Swagger 2.0 documents are not supported; convert the document to OpenAPI 3")
+		return
+	}
+
+	doc.OpenAPI = v.str(root, "openapi", "", true)
+	if doc.OpenAPI != "" && !openAPIVersion.MatchString(doc.OpenAPI) {
+		v.problem("openapi", "must be an OpenAPI 3.0 or 3.1 version, such as 3.1.0, not %q", doc.OpenAPI)
+	}
+
+	if info := v.object(root, "info", "", true); info != nil {
+		doc.Title = v.str(info, "title", "info", true)
+		doc.Version = v.str(info, "version", "info", true)
+	}
+
+	// OpenAPI 3.1 makes paths optional, as long as there is something to describe
+	is30 := strings.HasPrefix(doc.OpenAPI, "3.0")
+	paths := v.object(root, "paths", "", is30)
+	if !is30 && field(root, "paths") == nil && field(root, "webhooks") == nil && field(root, "components") == nil {
+		v.problem("", "document must have paths, webhooks or components")
+	}
+	if paths != nil {
+		v.paths(paths, is30)
+	}
+	v.object(root, "components", "", false)
+	v.object(root, "webhooks", "", false)
+}
+
+// paths checks the path items of paths; OpenAPI 3.0 requires every operation to list its responses
+func (v *validator) paths(paths *yaml.Node, requireResponses bool) {
+	v.operationIDs = map[string]string{}
+	for i := 0; i+1 < len(paths.Content); i += 2 {
+		path, item := paths.Content[i].Value, resolve(paths.Content[i+1])
+		at := "paths." + path
+		if strings.HasPrefix(path, "x-") {
+			continue
+		}
+		if !strings.HasPrefix(path, "/") {
+			v.problem(at, "path must begin with /")
+			continue
+		}
+		if item.Kind != yaml.MappingNode {
+			v.problem(at, "must be an object")
+			continue
+		}
+		v.parameters(item, at)
+		for _, method := range methods {
+			if op := v.object(item, method, at, false); op != nil {
+				v.operation(op, at+"."+method, requireResponses)
+			}
+		}
+	}
+}
+
+// operation checks an operation found at a location
+func (v *validator) operation(op *yaml.Node, at string, requireResponses bool) {
+	if id := v.str(op, "operationId", at, false); id != "" {
+		if other, ok := v.operationIDs[id]; ok {
+			v.problem(at+".operationId", "%q is also the operationId of %s", id, other)
+		} else {
+			v.operationIDs[id] = at
+		}
+	}
+	v.parameters(op, at)
+	v.object(op, "requestBody", at, false)
+
+	responses := v.object(op, "responses", at, requireResponses)
+	if responses == nil {
+		return
+	}
+	if len(responses.Content) == 0 && requireResponses {
+		v.problem(at+".responses", "must list at least one response")
+	}
+	for i := 0; i+1 < len(responses.Content); i += 2 {
+		code := responses.Content[i].Value
+		if !responseCode.MatchString(code) && !strings.HasPrefix(code, "x-") {
+			v.problem(at+".responses."+code, "must be an HTTP status code, a range such as 4XX, or default")
+		}
+	}
+}
+
+// parameters checks the parameters of a path item or operation found at a location
+func (v *validator) parameters(parent *yaml.Node, at string) {
+	params := field(parent, "parameters")
+	if params == nil {
+		return
+	}
+	at += ".parameters"
+	if params.Kind != yaml.SequenceNode {
+		v.problem(at, "must be an array")
+		return
+	}
+	for i, param := range params.Content {
+		param = resolve(param)
+		paramAt := fmt.Sprintf("%s[%d]", at, i)
+		if param.Kind != yaml.MappingNode {
+			v.problem(paramAt, "must be an object")
+			continue
+		}
+		if field(param, "$ref") != nil {
+			continue
+		}
+		v.str(param, "name", paramAt, true)
+		switch in := v.str(param, "in", paramAt, true); in {
+		case "":
+		case "query", "header", "cookie":
+		case "path":
+			if required := field(param, "required"); required == nil || required.Value != "true" {
+				v.problem(paramAt, "path parameters must be required")
+			}
+		default:
+			v.problem(paramAt+".in", "must be query, header, path or cookie, not %q", in)
+		}
+	}
+}
+
+// object returns the object at key in parent, recording a problem when it is
+// not an object or is required and missing
+func (v *validator) object(parent *yaml.Node, key, at string, required bool) *yaml.Node {
+	n := field(parent, key)
+	if n == nil {
+		if required {
+			v.problem(at, "%s is required", key)
+		}
+		return nil
+	}
+	if n.Kind != yaml.MappingNode {
+		v.problem(join(at, key), "must be an object")
+		return nil
+	}
+	return n
+}
+
+// str returns the string at key in parent, recording a problem when it is not
+// a string or is required and missing or empty
+func (v *validator) str(parent *yaml.Node, key, at string, required bool) string {
+	n := field(parent, key)
+	if n == nil {
+		if required {
+			v.problem(at, "%s is required", key)
+		}
+		return ""
+	}
+	if n.Kind != yaml.ScalarNode || n.ShortTag() != "!!str" {
+		v.problem(join(at, key), "must be a string")
+		return ""
+	}
+	if required && strings.TrimSpace(n.Value) == "" {
+		v.problem(join(at, key), "must not be blank")
+	}
+	return n.Value
+}
+
+// join appends key to the location at
+func join(at, key string) string {
+	if at == "" {
+		return key
+	}
+	return at + "." + key
+}
+
+// field returns the value of key in a mapping node, nil when it has none
+func field(n *yaml.Node, key string) *yaml.Node {
+	n = resolve(n)
+	if n == nil || n.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			return resolve(n.Content[i+1])
+		}
+	}
+	return nil
+}
+
+// resolve follows aliases to the node they stand for
+func resolve(n *yaml.Node) *yaml.Node {
+	for n != nil && n.Kind == yaml.AliasNode {
+		n = n.Alias
+	}
+	return n
+}
+
+// countNodes counts the nodes under n, expanding aliases, and stops once past maxNodes
+func countNodes(n *yaml.Node, count int) int {
+	n = resolve(n)
+	count++
+	for _, child := range n.Content {
+		if count > maxNodes {
+			break
+		}
+		count = countNodes(child, count)
+	}
+	return count
+}
+
+// writeJSON writes n as JSON, expanding aliases
+func writeJSON(buf *bytes.Buffer, n *yaml.Node) error {
+	n = resolve(n)
+	switch n.Kind {
+	case yaml.DocumentNode:
+		return writeJSON(buf, n.Content[0])
+	case yaml.MappingNode:
+		buf.WriteByte('{')
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			key, _ := json.Marshal(resolve(n.Content[i]).Value)
+			buf.Write(key)
+			buf.WriteByte(':')
+			if err := writeJSON(buf, n.Content[i+1]); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	case yaml.SequenceNode:
+		buf.WriteByte('[')
+		for i, child := range n.Content {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeJSON(buf, child); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	default:
+		var value interface{} = n.Value
+		switch n.ShortTag() {
+		case "!!null", "!!bool", "!!int", "!!float":
+			if err := n.Decode(&value); err != nil {
+				return err
+			}
+		}
+		data, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Errorf("%s cannot be written as JSON: %w", n.Value, err)
+		}
+		buf.Write(data)
+	}
+	return nil
+}
+
+// restyle clears the styles of n and its children, such as the flow style of
+// documents parsed from JSON, so that they are written as block YAML; strings
+// spanning several lines are written as literal blocks
+func restyle(n *yaml.Node) {
+	switch {
+	case n.Kind == yaml.ScalarNode && n.ShortTag() == "!!str" && strings.Contains(strings.TrimRight(n.Value, "\n"), "\n"):
+		n.Style = yaml.LiteralStyle
+	default:
+		n.Style = 0
+	}
+	for _, child := range n.Content {
+		restyle(child)
+	}
+}
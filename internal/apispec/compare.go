@@ -0,0 +1,490 @@
+package apispec
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/yashjain/konnect/internal/models"
+)
+
+// Change types
+const (
+	ChangePathRemoved         = "path_removed"
+	ChangePathAdded           = "path_added"
+	ChangeOperationRemoved    = "operation_removed"
+	ChangeOperationAdded      = "operation_added"
+	ChangeParameterRemoved    = "parameter_removed"
+	ChangeParameterAdded      = "parameter_added"
+	ChangeParameterRequired   = "parameter_required"
+	ChangeRequestBodyRemoved  = "request_body_removed"
+	ChangeRequestBodyAdded    = "request_body_added"
+	ChangeRequestBodyRequired = "request_body_required"
+	ChangeResponseRemoved     = "response_removed"
+	ChangeResponseAdded       = "response_added"
+	ChangeMediaTypeRemoved    = "media_type_removed"
+	ChangeMediaTypeAdded      = "media_type_added"
+	ChangeTypeChanged         = "type_changed"
+	ChangePropertyRemoved     = "property_removed"
+	ChangePropertyAdded       = "property_added"
+	ChangePropertyRequired    = "property_required"
+	ChangePropertyOptional    = "property_optional"
+	ChangeEnumValueRemoved    = "enum_value_removed"
+	ChangeEnumValueAdded      = "enum_value_added"
+)
+
+const (
+	// maxRefHops bounds the $refs followed to reach a node, so that $refs to
+	// each other end
+	maxRefHops = 32
+
+	// maxSchemaDepth bounds how deep schemas are compared
+	maxSchemaDepth = 32
+
+	// inRequest and inResponse tell schema comparisons which way data flows
+	inRequest, inResponse = true, false
+)
+
+// pathTemplate matches the parameters of a path, so that /pets/{id} and
+// /pets/{petId} are the same path
+var pathTemplate = regexp.MustCompile(`\{[^}/]*\}`)
+
+// Compare lists the changes from one OpenAPI document to another, in the
+// order of the documents, marking those that can break clients written
+// against from: removed paths, operations, responses and media types, newly
+// required inputs, changed types, and schemas that accept less in requests or
+// promise less in responses. Local $refs are followed; external ones are not.
+func Compare(from, to *Document) []models.SpecChange {
+	c := &comparer{
+		from: side{root: from.root},
+		to:   side{root: to.root},
+		seen: map[[2]*yaml.Node]bool{},
+	}
+	c.paths(field(from.root, "paths"), field(to.root, "paths"))
+	return c.changes
+}
+
+// side is one of the documents being compared
+type side struct {
+	root *yaml.Node
+}
+
+// deref follows the local $ref of n, if it has one, to the node it refers to,
+// nil when the $ref does not resolve
+func (s side) deref(n *yaml.Node) *yaml.Node {
+	n = resolve(n)
+	for hops := 0; n != nil && n.Kind == yaml.MappingNode; hops++ {
+		ref := field(n, "$ref")
+		if ref == nil || ref.Kind != yaml.ScalarNode {
+			return n
+		}
+		if hops == maxRefHops || !strings.HasPrefix(ref.Value, "#/") {
+			return nil
+		}
+		n = s.root
+		for _, token := range strings.Split(ref.Value[2:], "/") {
+			token = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+			if n = field(n, token); n == nil {
+				return nil
+			}
+		}
+	}
+	return n
+}
+
+// comparer collects the changes between two documents
+type comparer struct {
+	from, to side
+	changes  []models.SpecChange
+
+	// seen are the pairs of schemas compared so far, so that recursive schemas end
+	seen map[[2]*yaml.Node]bool
+}
+
+// change records a change at a location
+func (c *comparer) change(typ, at string, breaking bool, format string, args ...interface{}) {
+	c.changes = append(c.changes, models.SpecChange{Type: typ, Location: at, Message: fmt.Sprintf(format, args...), Breaking: breaking})
+}
+
+// pathItem is a path of a document and its item
+type pathItem struct {
+	path string
+	item *yaml.Node
+}
+
+// pathItems returns the path items of paths, keyed by their path with the
+// names of path parameters left out
+func pathItems(paths *yaml.Node) (map[string]pathItem, []string) {
+	items := make(map[string]pathItem)
+	var keys []string
+	if paths == nil || paths.Kind != yaml.MappingNode {
+		return items, keys
+	}
+	for i := 0; i+1 < len(paths.Content); i += 2 {
+		path := paths.Content[i].Value
+		if !strings.HasPrefix(path, "/") {
+			continue
+		}
+		key := pathTemplate.ReplaceAllString(path, "{}")
+		if _, ok := items[key]; !ok {
+			keys = append(keys, key)
+		}
+		items[key] = pathItem{path: path, item: resolve(paths.Content[i+1])}
+	}
+	return items, keys
+}
+
+// paths compares the paths of the documents
+func (c *comparer) paths(fromPaths, toPaths *yaml.Node) {
+	fromItems, fromKeys := pathItems(fromPaths)
+	toItems, toKeys := pathItems(toPaths)
+	for _, key := range fromKeys {
+		from := fromItems[key]
+		to, ok := toItems[key]
+		if !ok {
+			c.change(ChangePathRemoved, from.path, true, "path %s was removed", from.path)
+			continue
+		}
+		for _, method := range methods {
+			fromOp, toOp := c.from.deref(field(from.item, method)), c.to.deref(field(to.item, method))
+			at := strings.ToUpper(method) + " " + to.path
+			switch {
+			case fromOp == nil && toOp == nil:
+			case toOp == nil:
+				c.change(ChangeOperationRemoved, strings.ToUpper(method)+" "+from.path, true, "operation %s %s was removed", strings.ToUpper(method), from.path)
+			case fromOp == nil:
+				c.change(ChangeOperationAdded, at, false, "operation %s was added", at)
+			default:
+				c.operation(from.item, fromOp, to.item, toOp, at)
+			}
+		}
+	}
+	for _, key := range toKeys {
+		if _, ok := fromItems[key]; !ok {
+			c.change(ChangePathAdded, toItems[key].path, false, "path %s was added", toItems[key].path)
+		}
+	}
+}
+
+// operation compares an operation of both documents, found at a location
+func (c *comparer) operation(fromItem, fromOp, toItem, toOp *yaml.Node, at string) {
+	c.parameters(c.from.parameters(fromItem, fromOp), c.to.parameters(toItem, toOp), at)
+	c.requestBody(c.from.deref(field(fromOp, "requestBody")), c.to.deref(field(toOp, "requestBody")), at)
+	c.responses(field(fromOp, "responses"), field(toOp, "responses"), at)
+}
+
+// parameter is a parameter of an operation
+type parameter struct {
+	in, name string
+	node     *yaml.Node
+}
+
+// key identifies a parameter within an operation
+func (p parameter) key() string {
+	return p.in + "." + p.name
+}
+
+// parameters returns the parameters of an operation, those of its path item
+// included unless the operation overrides them
+func (s side) parameters(item, op *yaml.Node) []parameter {
+	var params []parameter
+	index := make(map[string]int)
+	for _, parent := range []*yaml.Node{item, op} {
+		list := field(parent, "parameters")
+		if list == nil || list.Kind != yaml.SequenceNode {
+			continue
+		}
+		for _, n := range list.Content {
+			n = s.deref(n)
+			p := parameter{in: scalar(field(n, "in")), name: scalar(field(n, "name")), node: n}
+			if p.in == "" || p.name == "" {
+				continue
+			}
+			if i, ok := index[p.key()]; ok {
+				params[i] = p
+				continue
+			}
+			index[p.key()] = len(params)
+			params = append(params, p)
+		}
+	}
+	return params
+}
+
+// parameters compares the parameters of an operation. Path parameters are
+// matched by the path itself, so renaming them changes nothing.
+func (c *comparer) parameters(fromParams, toParams []parameter, at string) {
+	toIndex := make(map[string]parameter, len(toParams))
+	for _, p := range toParams {
+		toIndex[p.key()] = p
+	}
+	fromIndex := make(map[string]bool, len(fromParams))
+	for _, from := range fromParams {
+		fromIndex[from.key()] = true
+		paramAt := at + " parameter " + from.key()
+		to, ok := toIndex[from.key()]
+		if !ok {
+			if from.in != "path" {
+				c.change(ChangeParameterRemoved, paramAt, false, "%s parameter %s was removed", from.in, from.name)
+			}
+			continue
+		}
+		if !isTrue(field(from.node, "required")) && isTrue(field(to.node, "required")) {
+			c.change(ChangeParameterRequired, paramAt, true, "%s parameter %s became required", to.in, to.name)
+		}
+		c.schema(field(from.node, "schema"), field(to.node, "schema"), paramAt, "", inRequest, 0)
+	}
+	for _, to := range toParams {
+		if fromIndex[to.key()] || to.in == "path" {
+			continue
+		}
+		required := isTrue(field(to.node, "required"))
+		if required {
+			c.change(ChangeParameterAdded, at+" parameter "+to.key(), true, "required %s parameter %s was added", to.in, to.name)
+		} else {
+			c.change(ChangeParameterAdded, at+" parameter "+to.key(), false, "optional %s parameter %s was added", to.in, to.name)
+		}
+	}
+}
+
+// requestBody compares the request bodies of an operation
+func (c *comparer) requestBody(from, to *yaml.Node, at string) {
+	at += " request body"
+	switch {
+	case from == nil && to == nil:
+	case to == nil:
+		c.change(ChangeRequestBodyRemoved, at, false, "the request body was removed")
+	case from == nil:
+		if isTrue(field(to, "required")) {
+			c.change(ChangeRequestBodyAdded, at, true, "a required request body was added")
+		} else {
+			c.change(ChangeRequestBodyAdded, at, false, "an optional request body was added")
+		}
+	default:
+		if !isTrue(field(from, "required")) && isTrue(field(to, "required")) {
+			c.change(ChangeRequestBodyRequired, at, true, "the request body became required")
+		}
+		c.content(field(from, "content"), field(to, "content"), at, inRequest)
+	}
+}
+
+// responses compares the responses of an operation. Removing a success
+// response breaks clients; removing others only changes what they may see.
+func (c *comparer) responses(from, to *yaml.Node, at string) {
+	for _, code := range keys(from) {
+		if strings.HasPrefix(code, "x-") {
+			continue
+		}
+		responseAt := at + " response " + code
+		toResponse := c.to.deref(field(to, code))
+		if toResponse == nil {
+			c.change(ChangeResponseRemoved, responseAt, strings.HasPrefix(code, "2"), "response %s was removed", code)
+			continue
+		}
+		c.content(field(c.from.deref(field(from, code)), "content"), field(toResponse, "content"), responseAt, inResponse)
+	}
+	for _, code := range keys(to) {
+		if !strings.HasPrefix(code, "x-") && field(from, code) == nil {
+			c.change(ChangeResponseAdded, at+" response "+code, false, "response %s was added", code)
+		}
+	}
+}
+
+// content compares the media types of a request body or response
+func (c *comparer) content(from, to *yaml.Node, at string, request bool) {
+	for _, mediaType := range keys(from) {
+		mediaAt := at + " " + mediaType
+		toMedia := field(to, mediaType)
+		if toMedia == nil {
+			c.change(ChangeMediaTypeRemoved, mediaAt, true, "media type %s was removed", mediaType)
+			continue
+		}
+		c.schema(field(field(from, mediaType), "schema"), field(toMedia, "schema"), mediaAt, "body", request, 0)
+	}
+	for _, mediaType := range keys(to) {
+		if field(from, mediaType) == nil {
+			c.change(ChangeMediaTypeAdded, at+" "+mediaType, false, "media type %s was added", mediaType)
+		}
+	}
+}
+
+// schema compares a schema of both documents, found at a location and within
+// it at path, such as body.owner.name. Requests break clients when the schema
+// accepts less, and responses when it promises less.
+func (c *comparer) schema(from, to *yaml.Node, at, path string, request bool, depth int) {
+	from, to = c.from.deref(from), c.to.deref(to)
+	if from == nil || to == nil || depth == maxSchemaDepth || c.seen[[2]*yaml.Node{from, to}] {
+		return
+	}
+	c.seen[[2]*yaml.Node{from, to}] = true
+	defer delete(c.seen, [2]*yaml.Node{from, to})
+
+	schemaAt := strings.TrimSpace(at + " " + path)
+	what := "the schema"
+	if path != "" {
+		what = path
+	}
+
+	fromType, toType := schemaType(from), schemaType(to)
+	if fromType != "" && toType != "" && fromType != toType {
+		// integer requests still fit number, and integer responses still are numbers
+		widened := request && fromType == "integer" && toType == "number"
+		narrowed := !request && fromType == "number" && toType == "integer"
+		c.change(ChangeTypeChanged, schemaAt, !widened && !narrowed, "the type of %s changed from %s to %s", what, fromType, toType)
+		return
+	}
+
+	c.enum(field(from, "enum"), field(to, "enum"), schemaAt, what, request)
+
+	fromProps, fromRequired := c.from.properties(from, 0)
+	toProps, toRequired := c.to.properties(to, 0)
+	toIndex := make(map[string]*yaml.Node, len(toProps))
+	for _, p := range toProps {
+		toIndex[p.name] = p.node
+	}
+	fromIndex := make(map[string]bool, len(fromProps))
+	for _, p := range fromProps {
+		fromIndex[p.name] = true
+		propPath := join(path, p.name)
+		propAt := strings.TrimSpace(at + " " + propPath)
+		toProp, ok := toIndex[p.name]
+		if !ok {
+			c.change(ChangePropertyRemoved, propAt, !request, "property %s was removed", propPath)
+			continue
+		}
+		switch {
+		case request && !fromRequired[p.name] && toRequired[p.name]:
+			c.change(ChangePropertyRequired, propAt, true, "property %s became required", propPath)
+		case !request && fromRequired[p.name] && !toRequired[p.name]:
+			c.change(ChangePropertyOptional, propAt, true, "property %s is no longer required", propPath)
+		}
+		c.schema(p.node, toProp, at, propPath, request, depth+1)
+	}
+	for _, p := range toProps {
+		if fromIndex[p.name] {
+			continue
+		}
+		propPath := join(path, p.name)
+		if request && toRequired[p.name] {
+			c.change(ChangePropertyAdded, strings.TrimSpace(at+" "+propPath), true, "required property %s was added", propPath)
+		} else {
+			c.change(ChangePropertyAdded, strings.TrimSpace(at+" "+propPath), false, "property %s was added", propPath)
+		}
+	}
+
+	c.schema(field(from, "items"), field(to, "items"), at, path+"[]", request, depth+1)
+}
+
+// enum compares the enum values of a schema. Requests break clients when
+// values they send are removed, and responses when values they do not expect
+// are added.
+func (c *comparer) enum(from, to *yaml.Node, at, what string, request bool) {
+	if from == nil || to == nil || from.Kind != yaml.SequenceNode || to.Kind != yaml.SequenceNode {
+		return
+	}
+	fromValues, toValues := scalars(from), scalars(to)
+	for _, v := range from.Content {
+		if v := scalar(v); !toValues[v] {
+			c.change(ChangeEnumValueRemoved, at, request, "value %q was removed from the enum of %s", v, what)
+		}
+	}
+	for _, v := range to.Content {
+		if v := scalar(v); !fromValues[v] {
+			c.change(ChangeEnumValueAdded, at, !request, "value %q was added to the enum of %s", v, what)
+		}
+	}
+}
+
+// property is a property of an object schema
+type property struct {
+	name string
+	node *yaml.Node
+}
+
+// properties returns the properties of a schema and those it requires,
+// merging in those of the schemas it lists in allOf
+func (s side) properties(schema *yaml.Node, depth int) ([]property, map[string]bool) {
+	var props []property
+	required := make(map[string]bool)
+	if schema == nil || depth == maxSchemaDepth {
+		return props, required
+	}
+	if list := field(schema, "required"); list != nil && list.Kind == yaml.SequenceNode {
+		for _, name := range list.Content {
+			required[scalar(name)] = true
+		}
+	}
+	if allOf := field(schema, "allOf"); allOf != nil && allOf.Kind == yaml.SequenceNode {
+		for _, member := range allOf.Content {
+			memberProps, memberRequired := s.properties(s.deref(member), depth+1)
+			props = append(props, memberProps...)
+			for name := range memberRequired {
+				required[name] = true
+			}
+		}
+	}
+	if properties := field(schema, "properties"); properties != nil && properties.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(properties.Content); i += 2 {
+			props = append(props, property{name: properties.Content[i].Value, node: properties.Content[i+1]})
+		}
+	}
+	return props, required
+}
+
+// schemaType returns the type of a schema, its types joined with | when
+// OpenAPI 3.1 lists several, and empty when it has none
+func schemaType(schema *yaml.Node) string {
+	typ := field(schema, "type")
+	if typ == nil {
+		return ""
+	}
+	if typ.Kind == yaml.SequenceNode {
+		types := make([]string, 0, len(typ.Content))
+		for _, t := range typ.Content {
+			types = append(types, scalar(t))
+		}
+		sort.Strings(types)
+		return strings.Join(types, "|")
+	}
+	return scalar(typ)
+}
+
+// keys returns the keys of a mapping node in order
+func keys(n *yaml.Node) []string {
+	n = resolve(n)
+	if n == nil || n.Kind != yaml.MappingNode {
+		return nil
+	}
+	keys := make([]string, 0, len(n.Content)/2)
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		keys = append(keys, n.Content[i].Value)
+	}
+	return keys
+}
+
+// scalar returns the value of a scalar node, empty for other nodes
+func scalar(n *yaml.Node) string {
+	n = resolve(n)
+	if n == nil || n.Kind != yaml.ScalarNode {
+		return ""
+	}
+	return n.Value
+}
+
+// scalars returns the set of the scalar values of a sequence node
+func scalars(n *yaml.Node) map[string]bool {
+	values := make(map[string]bool, len(n.Content))
+	for _, v := range n.Content {
+		values[scalar(v)] = true
+	}
+	return values
+}
+
+// isTrue reports whether n is the boolean true
+func isTrue(n *yaml.Node) bool {
+	n = resolve(n)
+	return n != nil && n.Kind == yaml.ScalarNode && n.ShortTag() == "!!bool" && n.Value == "true"
+}
@@ -0,0 +1,326 @@
+package apispec
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/yashjain/konnect/internal/models"
+)
+
+// Lint severities, from the most to the least weighty
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+	SeverityInfo    = "info"
+)
+
+// MaxLintProblems bounds the problems a lint report lists; the score counts them all
+const MaxLintProblems = 200
+
+// severityWeights weigh the checks of each severity in a score
+var severityWeights = map[string]int{
+	SeverityError:   5,
+	SeverityWarning: 3,
+	SeverityInfo:    1,
+}
+
+var (
+	camelCase    = regexp.MustCompile(`^[a-z][a-zA-Z0-9]*$`)
+	pascalCase   = regexp.MustCompile(`^[A-Z][a-zA-Z0-9]*$`)
+	kebabSegment = regexp.MustCompile(`^[a-z0-9]+([-.][a-z0-9]+)*$`)
+	successCode  = regexp.MustCompile(`^2([0-9][0-9]|XX)$`)
+)
+
+// Rule is a lint rule, checking one aspect of a document's quality at every
+// place it applies
+type Rule struct {
+	ID          string
+	Severity    string
+	Description string
+
+	check func(l *lintRun, doc *Document)
+}
+
+// Rules are the lint rules, in the order they run
+var Rules = []Rule{
+	{ID: "info-description", Severity: SeverityInfo, Description: "info has a description", check: (*lintRun).infoDescription},
+	{ID: "info-contact", Severity: SeverityInfo, Description: "info names a contact", check: (*lintRun).infoContact},
+	{ID: "security-schemes", Severity: SeverityError, Description: "components define at least one security scheme", check: (*lintRun).securitySchemes},
+	{ID: "operation-security", Severity: SeverityError, Description: "operations are secured, by their own security or the document's, with defined schemes", check: (*lintRun).operationSecurity},
+	{ID: "operation-id", Severity: SeverityWarning, Description: "operations have an operationId", check: (*lintRun).operationID},
+	{ID: "operation-id-case", Severity: SeverityInfo, Description: "operationIds are camelCase", check: (*lintRun).operationIDCase},
+	{ID: "operation-description", Severity: SeverityWarning, Description: "operations have a summary or description", check: (*lintRun).operationDescription},
+	{ID: "operation-tags", Severity: SeverityInfo, Description: "operations have tags", check: (*lintRun).operationTags},
+	{ID: "operation-success-response", Severity: SeverityWarning, Description: "operations have a 2xx response", check: (*lintRun).operationSuccessResponse},
+	{ID: "path-case", Severity: SeverityWarning, Description: "path segments are lowercase kebab-case", check: (*lintRun).pathCase},
+	{ID: "parameter-description", Severity: SeverityInfo, Description: "parameters have a description", check: (*lintRun).parameterDescription},
+	{ID: "schema-name-case", Severity: SeverityInfo, Description: "component schema names are PascalCase", check: (*lintRun).schemaNameCase},
+}
+
+// Linter runs a set of lint rules
+type Linter struct {
+	rules []Rule
+}
+
+// NewLinter returns a linter running the rules listed by ID, each optionally
+// followed by :error, :warning or :info to change its severity, such as
+// operation-tags:warning, or every rule when the list is empty. Unknown rules
+// and severities are logged and ignored, the way invalid settings are.
+func NewLinter(ids []string) *Linter {
+	if len(ids) == 0 {
+		return &Linter{rules: Rules}
+	}
+	l := &Linter{}
+	for _, id := range ids {
+		id, severity, _ := strings.Cut(strings.TrimSpace(id), ":")
+		rule, ok := ruleByID(id)
+		if !ok {
+			slog.Warn("Unknown lint rule, ignoring it", "rule", id)
+			continue
+		}
+		if _, ok := severityWeights[severity]; ok {
+			rule.Severity = severity
+		} else if severity != "" {
+			slog.Warn("Unknown lint severity, keeping the default", "rule", id, "severity", severity, "default", rule.Severity)
+		}
+		l.rules = append(l.rules, rule)
+	}
+	return l
+}
+
+// ruleByID returns the rule with an ID
+func ruleByID(id string) (Rule, bool) {
+	for _, rule := range Rules {
+		if rule.ID == id {
+			return rule, true
+		}
+	}
+	return Rule{}, false
+}
+
+// Lint checks a document against the rules of the linter, or every rule when
+// l is nil. The score is the share of checks passed out of 100, each weighing
+// 5 for errors, 3 for warnings and 1 for infos; documents with nothing to
+// check score 100.
+func (l *Linter) Lint(doc *Document) models.SpecLintReport {
+	rules := Rules
+	if l != nil {
+		rules = l.rules
+	}
+	run := &lintRun{report: models.SpecLintReport{Rules: []models.SpecLintRule{}, Problems: []models.SpecLintProblem{}}}
+	for _, rule := range rules {
+		run.rule = rule
+		run.report.Rules = append(run.report.Rules, models.SpecLintRule{ID: rule.ID, Severity: rule.Severity, Description: rule.Description})
+		rule.check(run, doc)
+	}
+
+	run.report.Score = 100
+	if run.checked > 0 {
+		run.report.Score = int(math.Floor(100 * float64(run.passed) / float64(run.checked)))
+	}
+	return run.report
+}
+
+// lintRun collects the outcome of the checks of a lint
+type lintRun struct {
+	rule   Rule
+	report models.SpecLintReport
+
+	// passed and checked are the weights of the checks passed and made
+	passed, checked int
+}
+
+// check records the outcome of a check of the current rule at a location
+func (l *lintRun) check(ok bool, at, format string, args ...interface{}) {
+	weight := severityWeights[l.rule.Severity]
+	l.checked += weight
+	if ok {
+		l.passed += weight
+		return
+	}
+	l.report.ProblemsCount++
+	if len(l.report.Problems) < MaxLintProblems {
+		l.report.Problems = append(l.report.Problems, models.SpecLintProblem{Rule: l.rule.ID, Severity: l.rule.Severity, Location: at, Message: fmt.Sprintf(format, args...)})
+	}
+}
+
+// lintOperation is an operation of a document
+type lintOperation struct {
+	at string
+	op *yaml.Node
+}
+
+// operations returns the operations of a document, in order
+func (doc *Document) operations() []lintOperation {
+	var ops []lintOperation
+	paths := field(doc.root, "paths")
+	for _, path := range keys(paths) {
+		if !strings.HasPrefix(path, "/") {
+			continue
+		}
+		item := field(paths, path)
+		for _, method := range methods {
+			if op := field(item, method); op != nil && op.Kind == yaml.MappingNode {
+				ops = append(ops, lintOperation{at: "paths." + path + "." + method, op: op})
+			}
+		}
+	}
+	return ops
+}
+
+// hasText reports whether key of n is a string that is not blank
+func hasText(n *yaml.Node, key string) bool {
+	return strings.TrimSpace(scalar(field(n, key))) != ""
+}
+
+// infoDescription checks that info has a description
+func (l *lintRun) infoDescription(doc *Document) {
+	l.check(hasText(field(doc.root, "info"), "description"), "info", "info has no description")
+}
+
+// infoContact checks that info names a contact by name, email or URL
+func (l *lintRun) infoContact(doc *Document) {
+	contact := field(field(doc.root, "info"), "contact")
+	l.check(hasText(contact, "name") || hasText(contact, "email") || hasText(contact, "url"), "info", "info names no contact")
+}
+
+// securitySchemes checks that components define a security scheme
+func (l *lintRun) securitySchemes(doc *Document) {
+	l.check(len(keys(field(field(doc.root, "components"), "securitySchemes"))) > 0, "components", "no security schemes are defined")
+}
+
+// operationSecurity checks that every operation is secured by schemes the
+// document defines
+func (l *lintRun) operationSecurity(doc *Document) {
+	schemes := make(map[string]bool)
+	for _, name := range keys(field(field(doc.root, "components"), "securitySchemes")) {
+		schemes[name] = true
+	}
+	global := field(doc.root, "security")
+	for _, op := range doc.operations() {
+		security := field(op.op, "security")
+		at := op.at
+		if security == nil {
+			security, at = global, "security"
+		}
+		// An empty list makes an operation public on purpose
+		if security == nil || security.Kind != yaml.SequenceNode {
+			l.check(false, op.at, "operation is not secured")
+			continue
+		}
+		var undefined []string
+		for _, requirement := range security.Content {
+			for _, name := range keys(requirement) {
+				if !schemes[name] {
+					undefined = append(undefined, name)
+				}
+			}
+		}
+		l.check(len(undefined) == 0, at, "security scheme %s is not defined", strings.Join(undefined, ", "))
+	}
+}
+
+// operationID checks that every operation has an operationId
+func (l *lintRun) operationID(doc *Document) {
+	for _, op := range doc.operations() {
+		l.check(hasText(op.op, "operationId"), op.at, "operation has no operationId")
+	}
+}
+
+// operationIDCase checks that operationIds are camelCase
+func (l *lintRun) operationIDCase(doc *Document) {
+	for _, op := range doc.operations() {
+		if id := scalar(field(op.op, "operationId")); id != "" {
+			l.check(camelCase.MatchString(id), op.at+".operationId", "%q is not camelCase", id)
+		}
+	}
+}
+
+// operationDescription checks that every operation has a summary or description
+func (l *lintRun) operationDescription(doc *Document) {
+	for _, op := range doc.operations() {
+		l.check(hasText(op.op, "summary") || hasText(op.op, "description"), op.at, "operation has no summary or description")
+	}
+}
+
+// operationTags checks that every operation has tags
+func (l *lintRun) operationTags(doc *Document) {
+	for _, op := range doc.operations() {
+		tags := field(op.op, "tags")
+		l.check(tags != nil && tags.Kind == yaml.SequenceNode && len(tags.Content) > 0, op.at, "operation has no tags")
+	}
+}
+
+// operationSuccessResponse checks that every operation has a 2xx response
+func (l *lintRun) operationSuccessResponse(doc *Document) {
+	for _, op := range doc.operations() {
+		ok := false
+		for _, code := range keys(field(op.op, "responses")) {
+			ok = ok || successCode.MatchString(code)
+		}
+		l.check(ok, op.at+".responses", "operation has no 2xx response")
+	}
+}
+
+// pathCase checks that the segments of paths, path parameters aside, are
+// lowercase kebab-case
+func (l *lintRun) pathCase(doc *Document) {
+	for _, path := range keys(field(doc.root, "paths")) {
+		if !strings.HasPrefix(path, "/") {
+			continue
+		}
+		var bad []string
+		for _, segment := range strings.Split(strings.Trim(path, "/"), "/") {
+			if segment != "" && !kebabSegment.MatchString(pathTemplate.ReplaceAllString(segment, "x")) {
+				bad = append(bad, segment)
+			}
+		}
+		l.check(len(bad) == 0, "paths."+path, "segments %s are not lowercase kebab-case", strings.Join(bad, ", "))
+	}
+}
+
+// parameterDescription checks that parameters have a description
+func (l *lintRun) parameterDescription(doc *Document) {
+	var lists []parameterList
+	paths := field(doc.root, "paths")
+	for _, path := range keys(paths) {
+		lists = append(lists, parameterList{"paths." + path + ".parameters", field(field(paths, path), "parameters")})
+	}
+	for _, op := range doc.operations() {
+		lists = append(lists, parameterList{op.at + ".parameters", field(op.op, "parameters")})
+	}
+	for _, list := range lists {
+		if list.params == nil || list.params.Kind != yaml.SequenceNode {
+			continue
+		}
+		for i, param := range list.params.Content {
+			if field(param, "$ref") != nil {
+				continue
+			}
+			l.check(hasText(param, "description"), fmt.Sprintf("%s[%d]", list.at, i), "parameter %s has no description", scalar(field(param, "name")))
+		}
+	}
+	components := field(field(doc.root, "components"), "parameters")
+	for _, name := range keys(components) {
+		param := field(components, name)
+		l.check(hasText(param, "description"), "components.parameters."+name, "parameter %s has no description", scalar(field(param, "name")))
+	}
+}
+
+// parameterList is a list of parameters and where it is
+type parameterList struct {
+	at     string
+	params *yaml.Node
+}
+
+// schemaNameCase checks that component schema names are PascalCase
+func (l *lintRun) schemaNameCase(doc *Document) {
+	for _, name := range keys(field(field(doc.root, "components"), "schemas")) {
+		l.check(pascalCase.MatchString(name), "components.schemas."+name, "%q is not PascalCase", name)
+	}
+}
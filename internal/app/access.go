@@ -0,0 +1,76 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/google/uuid"
+
+	"github.com/yashjain/konnect/internal/auth"
+	"github.com/yashjain/konnect/internal/models"
+	"github.com/yashjain/konnect/internal/repository"
+)
+
+var (
+	// ErrNotFound is returned when a service does not exist or is not visible to the caller
+	ErrNotFound = errors.New("service not found")
+
+	// ErrForbidden is returned when a caller can see a service but lacks the required permission
+	ErrForbidden = errors.New("insufficient permission on service")
+)
+
+// Authorize checks that a principal holds permission on a service before any
+// data is read or written. Private services the caller cannot read are reported
+// as ErrNotFound so their existence is not leaked.
+//
+// Public services are readable by everyone in the organization; private
+// services only by organization-wide principals and ACL grantees. Writing to
+// any service, which includes changing its visibility, takes an
+// organization-wide principal or a write grant.
+func Authorize(ctx context.Context, access repository.AccessRepository, p auth.Principal, serviceID, permission string) error {
+	visibility, err := access.GetServiceVisibility(ctx, p.OrgID, serviceID)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	if p.IsOrgWide() {
+		return nil
+	}
+	public := visibility == models.VisibilityPublic
+	if public && permission == models.PermissionRead {
+		return nil
+	}
+
+	granted, err := access.GetServicePermission(ctx, p, serviceID)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case granted == "" && !public:
+		return ErrNotFound
+	case permission == models.PermissionWrite && granted != models.PermissionWrite:
+		return ErrForbidden
+	default:
+		return nil
+	}
+}
+
+// CreatorGrants returns the ACL grants a new service needs so that the user who
+// created it can keep writing to it. Organization-wide principals need none.
+func CreatorGrants(p auth.Principal, service *models.Service) []models.ServiceACL {
+	if p.IsOrgWide() {
+		return nil
+	}
+	return []models.ServiceACL{{
+		ID:          uuid.New().String(),
+		ServiceID:   service.ID,
+		SubjectType: models.SubjectUser,
+		SubjectID:   p.UserID,
+		Permission:  models.PermissionWrite,
+	}}
+}
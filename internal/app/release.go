@@ -0,0 +1,110 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/yashjain/konnect/internal/apispec"
+	"github.com/yashjain/konnect/internal/filter"
+	"github.com/yashjain/konnect/internal/models"
+	"github.com/yashjain/konnect/internal/repository"
+	"github.com/yashjain/konnect/pkg/types"
+	"github.com/yashjain/konnect/pkg/utils"
+)
+
+// ErrUndeclaredBreakingChanges is returned when releasing a version would ship
+// breaking changes to its OpenAPI document that its semver does not declare
+var ErrUndeclaredBreakingChanges = errors.New("undeclared breaking changes")
+
+// releasedVersions lists only released versions
+var releasedVersions = []types.FilterCondition{{Field: "status", Operator: filter.OpEqual, Values: []interface{}{models.VersionReleased}}}
+
+// ReleaseGate keeps versions from being released with OpenAPI documents that
+// break the document of the service's latest released version, unless their
+// semver is a major bump from that version's. A nil ReleaseGate lets every
+// version be released.
+type ReleaseGate struct {
+	Versions repository.VersionRepository
+	Specs    repository.SpecRepository
+}
+
+// CheckRelease returns an error wrapping ErrUndeclaredBreakingChanges when
+// version, an existing version of orgID, may not be released. Versions without
+// an OpenAPI document, and services without a released version that has one,
+// are not checked.
+func (g *ReleaseGate) CheckRelease(ctx context.Context, orgID string, version *models.Version) error {
+	if g == nil {
+		return nil
+	}
+	spec, err := g.Specs.GetVersionSpec(ctx, orgID, version.ServiceID, version.ID)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	base, err := g.latestReleasedSpec(ctx, orgID, version)
+	if err != nil || base == nil {
+		return err
+	}
+	if utils.IsMajorBump(base.version.Semver, version.Semver) {
+		return nil
+	}
+
+	from, err := apispec.Parse(base.spec.Content)
+	if err != nil {
+		return err
+	}
+	to, err := apispec.Parse(spec.Content)
+	if err != nil {
+		return err
+	}
+	var breaking []models.SpecChange
+	for _, change := range apispec.Compare(from, to) {
+		if change.Breaking {
+			breaking = append(breaking, change)
+		}
+	}
+	if len(breaking) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w: %d breaking changes from released version %s, starting with %s: %s; release them in a new major version",
+		ErrUndeclaredBreakingChanges, len(breaking), base.version.Semver, breaking[0].Location, breaking[0].Message)
+}
+
+// releasedSpec is a released version and its OpenAPI document
+type releasedSpec struct {
+	version models.Version
+	spec    *models.VersionSpec
+}
+
+// latestReleasedSpec returns the newest released version of version's service
+// with an OpenAPI document, other than version, or nil when there is none
+func (g *ReleaseGate) latestReleasedSpec(ctx context.Context, orgID string, version *models.Version) (*releasedSpec, error) {
+	params := types.PaginationParams{Page: 1, PageSize: 100, SkipCount: true, Filter: releasedVersions}
+	for {
+		versions, _, err := g.Versions.GetVersions(ctx, orgID, version.ServiceID, params)
+		if err != nil {
+			return nil, err
+		}
+		for _, released := range versions {
+			if released.ID == version.ID || !released.HasSpec {
+				continue
+			}
+			spec, err := g.Specs.GetVersionSpec(ctx, orgID, version.ServiceID, released.ID)
+			if err == sql.ErrNoRows {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			return &releasedSpec{version: released, spec: spec}, nil
+		}
+		if len(versions) < params.PageSize {
+			return nil, nil
+		}
+		params.Page++
+	}
+}
@@ -0,0 +1,105 @@
+// Package audit records security-relevant changes, such as access grants and
+// configuration changes. Each entry is logged, and exported to a SIEM when an
+// Exporter is set. Export is at-least-once: a batch is resent until the sink
+// accepts it, so sinks may receive an entry twice and should deduplicate by ID.
+package audit
+
+import (
+	"context"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yashjain/konnect/internal/logging"
+)
+
+// Entry is one audited action
+type Entry struct {
+	ID        string                 `json:"id"`
+	Time      time.Time              `json:"time"`
+	Action    string                 `json:"action"`
+	RequestID string                 `json:"request_id,omitempty"`
+	Fields    map[string]interface{} `json:"fields,omitempty"`
+
+	// ClientCertificate is the verified TLS client certificate of the request
+	// the action was taken in, nil without mutual TLS
+	ClientCertificate *ClientCertificate `json:"client_certificate,omitempty"`
+}
+
+// ClientCertificate describes the subject of a verified TLS client certificate
+type ClientCertificate struct {
+	CommonName   string   `json:"common_name"`
+	Organization []string `json:"organization,omitempty"`
+	DNSNames     []string `json:"dns_names,omitempty"`
+	URIs         []string `json:"uris,omitempty"`
+}
+
+// clientCertificateKey is the context key holding the verified client
+// certificate of the request being handled
+type clientCertificateKey struct{}
+
+// WithClientCertificate returns ctx carrying the verified client certificate of
+// a request, which entries recorded with it include
+func WithClientCertificate(ctx context.Context, cert ClientCertificate) context.Context {
+	return context.WithValue(ctx, clientCertificateKey{}, cert)
+}
+
+// ClientCertificateFrom returns the verified client certificate ctx carries and
+// whether it carries one
+func ClientCertificateFrom(ctx context.Context) (ClientCertificate, bool) {
+	cert, ok := ctx.Value(clientCertificateKey{}).(ClientCertificate)
+	return cert, ok
+}
+
+// exporter receives the entries recorded by Log, when set
+var exporter atomic.Pointer[Exporter]
+
+// SetExporter sends the entries recorded from now on to e; nil stops exporting
+func SetExporter(e *Exporter) {
+	exporter.Store(e)
+}
+
+// Log records action, such as "Access granted", with key-value pairs as in
+// slog. The entry is logged at warn, so it is kept at any level but error,
+// with "audit" set, and queued for export. Its string fields are redacted like
+// logs.
+func Log(ctx context.Context, action string, args ...any) {
+	attrs := []any{"audit", true}
+	if cert, ok := ClientCertificateFrom(ctx); ok {
+		attrs = append(attrs, "client_certificate", cert.CommonName)
+	}
+	slog.Log(ctx, slog.LevelWarn, action, append(attrs, args...)...)
+
+	if e := exporter.Load(); e != nil {
+		e.Record(ctx, newEntry(ctx, action, args))
+	}
+}
+
+// newEntry returns the entry for action, turning args into fields as slog does
+func newEntry(ctx context.Context, action string, args []any) Entry {
+	entry := Entry{
+		ID:        uuid.NewString(),
+		Time:      time.Now().UTC(),
+		Action:    action,
+		RequestID: logging.RequestID(ctx),
+	}
+	if cert, ok := ClientCertificateFrom(ctx); ok {
+		entry.ClientCertificate = &cert
+	}
+
+	record := slog.NewRecord(entry.Time, slog.LevelWarn, action, 0)
+	record.Add(args...)
+	if record.NumAttrs() > 0 {
+		entry.Fields = make(map[string]interface{}, record.NumAttrs())
+	}
+	record.Attrs(func(a slog.Attr) bool {
+		value := a.Value.Resolve().Any()
+		if s, ok := value.(string); ok {
+			value = logging.Redact(s)
+		}
+		entry.Fields[a.Key] = value
+		return true
+	})
+	return entry
+}
@@ -0,0 +1,117 @@
+package audit
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/yashjain/konnect/internal/metrics"
+)
+
+// Retry delays after a failed export, doubling from the first to the last
+const (
+	retryBase = time.Second
+	retryMax  = time.Minute
+)
+
+// defaultBatchSize is used when an exporter is created without a batch size
+const defaultBatchSize = 100
+
+// Sink delivers audit entries to a SIEM
+type Sink interface {
+	// Send delivers a batch of entries; an error has it sent again
+	Send(ctx context.Context, entries []Entry) error
+}
+
+// Exporter queues entries and sends them to a Sink in batches
+type Exporter struct {
+	sink         Sink
+	queue        chan Entry
+	batchSize    int
+	blockTimeout time.Duration
+}
+
+// NewExporter returns an exporter queueing up to queueSize entries for sink,
+// and sending up to batchSize at a time. While the queue is full, recording
+// an entry waits up to blockTimeout for room before the entry is dropped, so
+// a slow sink slows down audited requests rather than losing their entries,
+// but an unavailable one cannot stall them.
+func NewExporter(sink Sink, queueSize, batchSize int, blockTimeout time.Duration) *Exporter {
+	if batchSize < 1 {
+		batchSize = defaultBatchSize
+	}
+	return &Exporter{sink: sink, queue: make(chan Entry, queueSize), batchSize: batchSize, blockTimeout: blockTimeout}
+}
+
+// Record queues entry to be sent by Run
+func (e *Exporter) Record(ctx context.Context, entry Entry) {
+	select {
+	case e.queue <- entry:
+		return
+	default:
+	}
+
+	timer := time.NewTimer(e.blockTimeout)
+	defer timer.Stop()
+	select {
+	case e.queue <- entry:
+	case <-timer.C:
+		metrics.AuditEntriesDropped.Inc()
+		slog.ErrorContext(ctx, "Audit export queue full, dropping entry", "audit_id", entry.ID, "action", entry.Action)
+	case <-ctx.Done():
+		metrics.AuditEntriesDropped.Inc()
+		slog.ErrorContext(ctx, "Audit entry not queued for export", "audit_id", entry.ID, "action", entry.Action, "error", ctx.Err())
+	}
+}
+
+// Run sends queued entries until ctx is done. A batch is retried, with
+// exponential backoff, until the sink accepts it; entries queued meanwhile
+// wait, so they are delivered in the order they were recorded.
+func (e *Exporter) Run(ctx context.Context) {
+	for {
+		var batch []Entry
+		select {
+		case <-ctx.Done():
+			return
+		case entry := <-e.queue:
+			batch = append(batch, entry)
+		}
+	fill:
+		for len(batch) < e.batchSize {
+			select {
+			case entry := <-e.queue:
+				batch = append(batch, entry)
+			default:
+				break fill
+			}
+		}
+
+		if !e.send(ctx, batch) {
+			return
+		}
+	}
+}
+
+// send delivers batch, retrying until it succeeds; it returns false if ctx is done first
+func (e *Exporter) send(ctx context.Context, batch []Entry) bool {
+	delay := retryBase
+	for {
+		err := e.sink.Send(ctx, batch)
+		if err == nil {
+			metrics.AuditExports.WithLabelValues("delivered").Inc()
+			return true
+		}
+		if ctx.Err() != nil {
+			return false
+		}
+		metrics.AuditExports.WithLabelValues("failed").Inc()
+		slog.Warn("Audit export failed, retrying", "entries", len(batch), "retry_in", delay, "error", err)
+
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, retryMax)
+	}
+}
@@ -0,0 +1,93 @@
+package audit
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+)
+
+// syslogPriority is the log audit facility (13) at notice severity (5)
+const syslogPriority = 13*8 + 5
+
+// SyslogSink sends entries as RFC 5424 syslog messages whose message is the
+// entry as JSON. Over TCP and TLS, messages are framed by octet counting
+// (RFC 6587); over UDP, each is one datagram.
+type SyslogSink struct {
+	network  string
+	addr     string
+	timeout  time.Duration
+	hostname string
+}
+
+// NewSyslogSink returns a sink for address, given as tcp://host:port,
+// tls://host:port or udp://host:port. Each batch is sent over a new
+// connection, so a restarted collector is picked up by the next batch.
+func NewSyslogSink(address string, timeout time.Duration) (*SyslogSink, error) {
+	u, err := url.Parse(address)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid syslog address %q: must be tcp://, tls:// or udp://host:port", address)
+	}
+	switch u.Scheme {
+	case "tcp", "tls", "udp":
+	default:
+		return nil, fmt.Errorf("invalid syslog address %q: must be tcp://, tls:// or udp://host:port", address)
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "-"
+	}
+	return &SyslogSink{network: u.Scheme, addr: u.Host, timeout: timeout, hostname: hostname}, nil
+}
+
+// Send implements Sink
+func (s *SyslogSink) Send(ctx context.Context, entries []Entry) error {
+	conn, err := s.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = conn.Close() }()
+	if err := conn.SetDeadline(time.Now().Add(s.timeout)); err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		msg, err := s.format(entry)
+		if err != nil {
+			return err
+		}
+		if s.network != "udp" {
+			msg = append([]byte(strconv.Itoa(len(msg))+" "), msg...)
+		}
+		if _, err := conn.Write(msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dial connects to the collector
+func (s *SyslogSink) dial(ctx context.Context) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: s.timeout}
+	if s.network == "tls" {
+		td := &tls.Dialer{NetDialer: dialer}
+		return td.DialContext(ctx, "tcp", s.addr)
+	}
+	return dialer.DialContext(ctx, s.network, s.addr)
+}
+
+// format returns entry as an RFC 5424 message
+func (s *SyslogSink) format(entry Entry) ([]byte, error) {
+	body, err := json.Marshal(entry)
+	if err != nil {
+		return nil, err
+	}
+	header := fmt.Sprintf("<%d>1 %s %s konnect - audit - ", syslogPriority, entry.Time.Format(time.RFC3339Nano), s.hostname)
+	return append([]byte(header), body...), nil
+}
@@ -0,0 +1,63 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/yashjain/konnect/internal/webhook"
+)
+
+// WebhookSink POSTs each batch of entries as a JSON array to a URL, such as a
+// SIEM's HTTP collector. Any response other than 2xx is a failed delivery.
+type WebhookSink struct {
+	url    string
+	secret string
+	client *http.Client
+}
+
+// NewWebhookSink returns a sink posting to url, signing deliveries with secret
+// as described in the webhook package when it is set
+func NewWebhookSink(url, secret string, timeout time.Duration) *WebhookSink {
+	return &WebhookSink{url: url, secret: secret, client: &http.Client{Timeout: timeout}}
+}
+
+// Send implements Sink. The ID of the first entry is sent as the delivery ID.
+func (s *WebhookSink) Send(ctx context.Context, entries []Entry) error {
+	body, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.secret != "" {
+		webhook.SignRequest(req, s.secret, entries[0].ID, body)
+	} else {
+		req.Header.Set(webhook.HeaderID, entries[0].ID)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			slog.WarnContext(ctx, "Error closing audit webhook response", "error", err)
+		}
+	}()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("audit webhook responded %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package auth
+
+import (
+	"errors"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ErrInvalidAccessToken is returned when an access token fails verification
+var ErrInvalidAccessToken = errors.New("invalid access token")
+
+// AccessClaims are the claims carried by a signed access token
+type AccessClaims struct {
+	OrgID     string `json:"org"`
+	SessionID string `json:"sid"`
+	jwt.RegisteredClaims
+}
+
+// IssueAccessToken signs a short-lived access token for a user session
+func IssueAccessToken(key []byte, orgID, userID, sessionID string, ttl time.Duration) (string, error) {
+	now := time.Now()
+	claims := AccessClaims{
+		OrgID:     orgID,
+		SessionID: sessionID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   userID,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(key)
+}
+
+// ParseAccessToken verifies an access token and returns its claims
+func ParseAccessToken(key []byte, token string) (*AccessClaims, error) {
+	claims := &AccessClaims{}
+	_, err := jwt.ParseWithClaims(token, claims, func(*jwt.Token) (interface{}, error) {
+		return key, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil || claims.Subject == "" || claims.OrgID == "" {
+		return nil, ErrInvalidAccessToken
+	}
+	return claims, nil
+}
+
+// LooksLikeAccessToken distinguishes signed access tokens from opaque API tokens
+func LooksLikeAccessToken(token string) bool {
+	dots := 0
+	for _, r := range token {
+		if r == '.' {
+			dots++
+		}
+	}
+	return dots == 2
+}
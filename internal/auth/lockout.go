@@ -0,0 +1,150 @@
+package auth
+
+import (
+	"container/list"
+	"strings"
+	"sync"
+	"time"
+)
+
+// lockoutMaxEntries caps the number of tracked keys; past it the key whose last
+// failure is oldest is forgotten to make room
+const lockoutMaxEntries = 10000
+
+// Lockout tracks failed authentication attempts per key and locks a key out
+// for exponentially longer periods once it reaches the failure threshold.
+// Keys are built with IPKey and PrincipalKey.
+type Lockout struct {
+	threshold int
+	base      time.Duration
+	max       time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*list.Element // of *lockoutEntry
+	order   *list.List               // oldest last failure first
+}
+
+type lockoutEntry struct {
+	key         string
+	failures    int
+	lastFailure time.Time
+	lockedUntil time.Time
+}
+
+// NewLockout returns a Lockout that locks a key for base after threshold
+// consecutive failures, doubling for each further failure up to max.
+// A threshold of zero or less disables lockouts.
+func NewLockout(threshold int, base, max time.Duration) *Lockout {
+	return &Lockout{
+		threshold: threshold,
+		base:      base,
+		max:       max,
+		entries:   make(map[string]*list.Element),
+		order:     list.New(),
+	}
+}
+
+// IPKey returns the lockout key for a client IP
+func IPKey(ip string) string {
+	return "ip:" + ip
+}
+
+// PrincipalKey returns the lockout key for a login name
+func PrincipalKey(name string) string {
+	return "principal:" + strings.ToLower(strings.TrimSpace(name))
+}
+
+// Locked returns how long key remains locked out, or zero when it is not locked
+func (l *Lockout) Locked(key string, now time.Time) time.Duration {
+	if l == nil || l.threshold <= 0 {
+		return 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	e, ok := l.entries[key]
+	if !ok {
+		return 0
+	}
+	entry := e.Value.(*lockoutEntry)
+	if !now.Before(entry.lockedUntil) {
+		return 0
+	}
+	return entry.lockedUntil.Sub(now)
+}
+
+// Fail records a failed attempt for key and returns the lockout it started,
+// or zero when key is still below the threshold
+func (l *Lockout) Fail(key string, now time.Time) time.Duration {
+	if l == nil || l.threshold <= 0 {
+		return 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(now)
+
+	var entry *lockoutEntry
+	if e, ok := l.entries[key]; ok {
+		entry = e.Value.(*lockoutEntry)
+		if l.stale(entry, now) {
+			*entry = lockoutEntry{key: key}
+		}
+		l.order.MoveToBack(e)
+	} else {
+		if l.order.Len() >= lockoutMaxEntries {
+			l.remove(l.order.Front())
+		}
+		entry = &lockoutEntry{key: key}
+		l.entries[key] = l.order.PushBack(entry)
+	}
+	entry.failures++
+	entry.lastFailure = now
+
+	if entry.failures < l.threshold {
+		return 0
+	}
+
+	lock := l.max
+	if shift := entry.failures - l.threshold; shift < 32 {
+		if d := l.base << shift; d > 0 && d < l.max {
+			lock = d
+		}
+	}
+	entry.lockedUntil = now.Add(lock)
+	return lock
+}
+
+// Reset forgets the failures recorded for key after a successful attempt
+func (l *Lockout) Reset(key string) {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if e, ok := l.entries[key]; ok {
+		l.remove(e)
+	}
+}
+
+// stale reports whether an entry's lockout has ended and it has seen no
+// failures for max, so its history no longer counts
+func (l *Lockout) stale(entry *lockoutEntry, now time.Time) bool {
+	return !now.Before(entry.lockedUntil) && now.Sub(entry.lastFailure) >= l.max
+}
+
+// sweep drops stale entries. A lockout never outlasts max, so entries go stale
+// in the order of their last failure and only the front of the list is checked.
+func (l *Lockout) sweep(now time.Time) {
+	for e := l.order.Front(); e != nil && l.stale(e.Value.(*lockoutEntry), now); e = l.order.Front() {
+		l.remove(e)
+	}
+}
+
+// remove forgets an entry
+func (l *Lockout) remove(e *list.Element) {
+	delete(l.entries, l.order.Remove(e).(*lockoutEntry).key)
+}
@@ -0,0 +1,18 @@
+package auth
+
+// Principal identifies who is making a request
+type Principal struct {
+	OrgID string
+
+	// UserID is empty for organization-wide tokens
+	UserID string
+
+	// TeamIDs lists the teams UserID belongs to
+	TeamIDs []string
+}
+
+// IsOrgWide reports whether the principal acts for the whole organization
+// rather than a single user; such principals bypass per-service ACLs.
+func (p Principal) IsOrgWide() bool {
+	return p.UserID == ""
+}
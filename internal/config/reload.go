@@ -0,0 +1,247 @@
+package config
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/yashjain/konnect/internal/audit"
+	"github.com/yashjain/konnect/internal/logging"
+)
+
+// ErrNoConfigFile is returned by Reload when CONFIG_FILE is not set
+var ErrNoConfigFile = errors.New("CONFIG_FILE is not set")
+
+// fileValues holds the settings read from CONFIG_FILE, which take precedence
+// over the environment
+var fileValues atomic.Pointer[map[string]string]
+
+// reloadable lists the settings Reload applies without a restart, with the
+// check each value must pass, if any
+var reloadable = map[string]func(string) error{
+	"LOG_LEVEL":                 validLevel,
+	"ACCESS_LOG_EXCLUDE":        nil,
+	"ACCESS_LOG_SAMPLE_ROUTES":  nil,
+	"ACCESS_LOG_SAMPLE_RATE":    validFraction,
+	"ACCESS_LOG_SLOW_THRESHOLD": validDuration,
+	"MAX_IN_FLIGHT":             validLimit,
+	"MAX_IN_FLIGHT_API":         validLimit,
+	"MAX_IN_FLIGHT_AUTH":        validLimit,
+}
+
+// Reloadable holds the settings that can change without a restart
+type Reloadable struct {
+	LogLevel  string
+	AccessLog AccessLogConfig
+	LoadShed  LoadShedConfig
+}
+
+// Change is a setting changed by a reload
+type Change struct {
+	Key string `json:"key"`
+	Old string `json:"old"`
+	New string `json:"new"`
+}
+
+// Reloader re-reads CONFIG_FILE and applies the reloadable settings it changes
+type Reloader struct {
+	path string
+
+	mu       sync.Mutex
+	appliers []func(Reloadable)
+}
+
+// NewReloader returns a reloader of the file named by CONFIG_FILE
+func NewReloader() *Reloader {
+	return &Reloader{path: os.Getenv("CONFIG_FILE")}
+}
+
+// OnReload registers apply to be called with the settings after each reload that changes them
+func (r *Reloader) OnReload(apply func(Reloadable)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.appliers = append(r.appliers, apply)
+}
+
+// Reload re-reads CONFIG_FILE and applies the reloadable settings it changes,
+// returning them. Nothing is applied if any changed value is invalid. Changes
+// to other settings are kept for the next restart and logged as such. Applied
+// changes are logged as an audit record naming source, what triggered the
+// reload.
+func (r *Reloader) Reload(source string) ([]Change, error) {
+	if r.path == "" {
+		return nil, ErrNoConfigFile
+	}
+	values, err := readConfigFile(r.path)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	previous := map[string]string{}
+	if p := fileValues.Load(); p != nil {
+		previous = *p
+	}
+	keys := make(map[string]bool, len(previous)+len(values))
+	for key := range previous {
+		keys[key] = true
+	}
+	for key := range values {
+		keys[key] = true
+	}
+	sorted := make([]string, 0, len(keys))
+	for key := range keys {
+		sorted = append(sorted, key)
+	}
+	sort.Strings(sorted)
+
+	var changes []Change
+	var restart []string
+	for _, key := range sorted {
+		before, after := effectiveValue(previous, key), effectiveValue(values, key)
+		if before == after {
+			continue
+		}
+		check, ok := reloadable[key]
+		if !ok {
+			restart = append(restart, key)
+			continue
+		}
+		if check != nil && after != "" {
+			if err := check(after); err != nil {
+				return nil, fmt.Errorf("invalid %s %q: %w", key, after, err)
+			}
+		}
+		changes = append(changes, Change{Key: key, Old: before, New: after})
+	}
+
+	fileValues.Store(&values)
+	if len(restart) > 0 {
+		slog.Warn("Configuration changes need a restart to apply", "keys", restart)
+	}
+	if len(changes) == 0 {
+		return nil, nil
+	}
+
+	settings := loadReloadable()
+	for _, apply := range r.appliers {
+		apply(settings)
+	}
+	audit.Log(context.Background(), "Configuration changed", "source", source, "changes", changes)
+	return changes, nil
+}
+
+// loadConfigFile reads CONFIG_FILE, when set, for the settings loaded after it
+func loadConfigFile() {
+	path := os.Getenv("CONFIG_FILE")
+	if path == "" {
+		fileValues.Store(nil)
+		return
+	}
+	values, err := readConfigFile(path)
+	if err != nil {
+		slog.Error("Failed to load CONFIG_FILE", "error", err)
+		return
+	}
+	fileValues.Store(&values)
+}
+
+// loadReloadable loads the settings that can change without a restart
+func loadReloadable() Reloadable {
+	return Reloadable{
+		LogLevel:  getEnv("LOG_LEVEL", "debug"),
+		AccessLog: loadAccessLog(),
+		LoadShed:  loadLoadShed(),
+	}
+}
+
+// readConfigFile reads KEY=value lines, ignoring blank lines and # comments.
+// Values may be wrapped in single or double quotes.
+func readConfigFile(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading CONFIG_FILE: %w", err)
+	}
+	defer f.Close()
+
+	values := map[string]string{}
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" || strings.ContainsAny(key, " \t") {
+			return nil, fmt.Errorf("CONFIG_FILE line %d: expected KEY=value", n)
+		}
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		values[key] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading CONFIG_FILE: %w", err)
+	}
+	return values, nil
+}
+
+// effectiveValue returns the value of key given the file values, falling back to the environment
+func effectiveValue(values map[string]string, key string) string {
+	if value, ok := values[key]; ok {
+		return value
+	}
+	return os.Getenv(key)
+}
+
+// lookupEnv returns the value of key from CONFIG_FILE, or else the environment
+func lookupEnv(key string) (string, bool) {
+	if p := fileValues.Load(); p != nil {
+		if value, ok := (*p)[key]; ok {
+			return value, true
+		}
+	}
+	return os.LookupEnv(key)
+}
+
+func validLevel(value string) error {
+	_, err := logging.ParseLevel(value)
+	return err
+}
+
+func validFraction(value string) error {
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil || f < 0 || f > 1 {
+		return errors.New("must be a number from 0 to 1")
+	}
+	return nil
+}
+
+func validDuration(value string) error {
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return errors.New("must be a non-negative duration such as 500ms")
+	}
+	return nil
+}
+
+func validLimit(value string) error {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return errors.New("must be a non-negative integer")
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SecretSource resolves a secret from, in order of precedence, a mounted file
+// (<KEY>_FILE), HashiCorp Vault (<KEY>_VAULT) or the <KEY> environment variable.
+// Every Resolve re-reads the source so rotated secrets are picked up without a restart.
+type SecretSource struct {
+	Key       string
+	File      string
+	VaultRef  string
+	Value     string
+	Default   string
+	vault     *vaultClient
+	vaultOnce sync.Once
+}
+
+// NewSecretSource reads the configuration for a secret named key
+func NewSecretSource(key, defaultValue string) *SecretSource {
+	return &SecretSource{
+		Key:      key,
+		File:     os.Getenv(key + "_FILE"),
+		VaultRef: os.Getenv(key + "_VAULT"),
+		Value:    os.Getenv(key),
+		Default:  defaultValue,
+	}
+}
+
+// Resolve returns the current value of the secret
+func (s *SecretSource) Resolve() (string, error) {
+	switch {
+	case s.File != "":
+		data, err := os.ReadFile(s.File)
+		if err != nil {
+			return "", fmt.Errorf("reading %s_FILE: %w", s.Key, err)
+		}
+		return strings.TrimSpace(string(data)), nil
+	case s.VaultRef != "":
+		s.vaultOnce.Do(func() { s.vault = newVaultClient() })
+		return s.vault.read(s.VaultRef)
+	case s.Value != "":
+		return s.Value, nil
+	default:
+		return s.Default, nil
+	}
+}
+
+// vaultClient reads secrets from a Vault KV engine over its HTTP API
+type vaultClient struct {
+	addr     string
+	token    *SecretSource
+	cacheTTL time.Duration
+	http     *http.Client
+	mu       sync.Mutex
+	cache    map[string]vaultEntry
+}
+
+// vaultEntry is a cached Vault read
+type vaultEntry struct {
+	value   string
+	expires time.Time
+}
+
+// newVaultClient configures a client from VAULT_ADDR, VAULT_TOKEN[_FILE] and VAULT_CACHE_TTL
+func newVaultClient() *vaultClient {
+	return &vaultClient{
+		addr:     strings.TrimRight(getEnv("VAULT_ADDR", "http://127.0.0.1:8200"), "/"),
+		token:    NewSecretSource("VAULT_TOKEN", ""),
+		cacheTTL: getDuration("VAULT_CACHE_TTL", time.Minute),
+		http:     &http.Client{Timeout: 5 * time.Second},
+		cache:    make(map[string]vaultEntry),
+	}
+}
+
+// read resolves a reference of the form "<path>#<field>", e.g. "secret/data/konnect#mysql_dsn".
+// Both KV v2 (data.data) and KV v1 (data) response layouts are supported.
+func (v *vaultClient) read(ref string) (string, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if entry, ok := v.cache[ref]; ok && time.Now().Before(entry.expires) {
+		return entry.value, nil
+	}
+
+	path, field, ok := strings.Cut(ref, "#")
+	if !ok || path == "" || field == "" {
+		return "", fmt.Errorf("vault reference %q must be <path>#<field>", ref)
+	}
+
+	token, err := v.token.Resolve()
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequest(http.MethodGet, v.addr+"/v1/"+strings.TrimLeft(path, "/"), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", token)
+
+	resp, err := v.http.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("reading vault path %s: %w", path, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("reading vault path %s: unexpected status %d", path, resp.StatusCode)
+	}
+
+	var body struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("decoding vault response for %s: %w", path, err)
+	}
+
+	data := body.Data
+	if nested, ok := data["data"].(map[string]interface{}); ok {
+		data = nested
+	}
+	value, ok := data[field].(string)
+	if !ok {
+		return "", fmt.Errorf("vault path %s has no string field %q", path, field)
+	}
+
+	v.cache[ref] = vaultEntry{value: value, expires: time.Now().Add(v.cacheTTL)}
+	return value, nil
+}
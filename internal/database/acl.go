@@ -0,0 +1,207 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"strings"
+
+	"github.com/yashjain/konnect/internal/auth"
+	"github.com/yashjain/konnect/internal/models"
+)
+
+// CreateUser creates a user within an organization; an empty password hash disables login
+func (s *Store) CreateUser(ctx context.Context, user *models.User, passwordHash string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	user.CreatedAt = timestamp()
+	_, err := tenantExec(ctx, s.db, user.OrgID, "INSERT INTO users (id, org_id, email, name, password_hash, created_at) VALUES (?, {{tenant_id}}, ?, ?, ?, ?)",
+		user.ID, user.Email, user.Name, nullString(passwordHash), user.CreatedAt)
+	return err
+}
+
+// CreateTeam creates a team within an organization
+func (s *Store) CreateTeam(ctx context.Context, team *models.Team) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	team.CreatedAt = timestamp()
+	_, err := tenantExec(ctx, s.db, team.OrgID, "INSERT INTO teams (id, org_id, name, created_at) VALUES (?, {{tenant_id}}, ?, ?)",
+		team.ID, team.Name, team.CreatedAt)
+	return err
+}
+
+// AddTeamMember adds a user to a team; both must belong to the organization
+func (s *Store) AddTeamMember(ctx context.Context, orgID, teamID, userID string) (int64, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	result, err := tenantExec(ctx, s.db, orgID, s.db.dialect.insertIgnore+` team_members (team_id, user_id)
+		SELECT t.id, u.id FROM teams t JOIN users u ON u.org_id = t.org_id
+		WHERE t.id = ? AND u.id = ? AND {{tenant:t}}`+s.db.dialect.onConflictIgnore,
+		teamID, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	return rowsAffected, err
+}
+
+// GetTeamIDsForUser lists the teams a user belongs to.
+// tenant:exempt resolves the identity of an already-authenticated user before a tenant is known.
+func (s *Store) GetTeamIDsForUser(ctx context.Context, userID string) ([]string, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, "SELECT team_id FROM team_members WHERE user_id = ?", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.WarnContext(ctx, "Error closing rows", "error", err)
+		}
+	}()
+
+	var teamIDs []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		teamIDs = append(teamIDs, id)
+	}
+
+	return teamIDs, rows.Err()
+}
+
+// GetServiceVisibility returns the visibility of a service within an organization.
+// Soft-deleted services are reported as missing.
+func (s *Store) GetServiceVisibility(ctx context.Context, orgID, serviceID string) (string, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var visibility string
+	err := tenantQueryRow(ctx, s.db, orgID, "SELECT visibility FROM services WHERE id = ? AND {{tenant}} AND deleted_at IS NULL", serviceID).Scan(&visibility)
+	return visibility, err
+}
+
+// GetServicePermission returns the strongest permission granted to a principal on a service,
+// or an empty string when there is no grant
+func (s *Store) GetServicePermission(ctx context.Context, p auth.Principal, serviceID string) (string, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	clause, args := subjectClause(p)
+	query := "SELECT a.permission FROM service_acls a JOIN services s ON s.id = a.service_id WHERE a.service_id = ? AND {{tenant:s}} AND s.deleted_at IS NULL AND " + clause + " ORDER BY a.permission = 'write' DESC LIMIT 1"
+
+	var permission string
+	err := tenantQueryRow(ctx, s.db, p.OrgID, query, append([]interface{}{serviceID}, args...)...).Scan(&permission)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return permission, err
+}
+
+// CreateServiceACL grants a subject access to a service within an organization
+func (s *Store) CreateServiceACL(ctx context.Context, orgID string, acl *models.ServiceACL) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	acl.CreatedAt = timestamp()
+	_, err := tenantExec(ctx, s.db, orgID, `
+		INSERT INTO service_acls (id, service_id, subject_type, subject_id, permission, created_at)
+		SELECT ?, id, ?, ?, ?, ? FROM services WHERE id = ? AND {{tenant}} AND deleted_at IS NULL
+		`+s.db.dialect.upsertACL,
+		acl.ID, acl.SubjectType, acl.SubjectID, acl.Permission, acl.CreatedAt, acl.ServiceID)
+	return err
+}
+
+// GetServiceACLs lists the grants on a service within an organization
+func (s *Store) GetServiceACLs(ctx context.Context, orgID, serviceID string) ([]models.ServiceACL, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := tenantQuery(ctx, s.read, orgID, `
+		SELECT a.id, a.service_id, a.subject_type, a.subject_id, a.permission, a.created_at
+		FROM service_acls a JOIN services s ON s.id = a.service_id
+		WHERE a.service_id = ? AND {{tenant:s}} AND s.deleted_at IS NULL
+		ORDER BY a.created_at`, serviceID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.WarnContext(ctx, "Error closing rows", "error", err)
+		}
+	}()
+
+	var acls []models.ServiceACL
+	for rows.Next() {
+		var a models.ServiceACL
+		if err := rows.Scan(&a.ID, &a.ServiceID, &a.SubjectType, &a.SubjectID, &a.Permission, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		a.CreatedAt = a.CreatedAt.UTC()
+		acls = append(acls, a)
+	}
+
+	return acls, rows.Err()
+}
+
+// DeleteServiceACL revokes a grant on a service within an organization
+func (s *Store) DeleteServiceACL(ctx context.Context, orgID, serviceID, aclID string) (int64, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	result, err := tenantExec(ctx, s.db, orgID, `
+		DELETE FROM service_acls
+		WHERE id = ? AND service_id = ? AND service_id IN (SELECT id FROM services WHERE {{tenant}} AND deleted_at IS NULL)`, aclID, serviceID)
+	if err != nil {
+		return 0, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	return rowsAffected, err
+}
+
+// visibilityFilter restricts a services query to rows the principal may read.
+// Organization-wide principals see everything in their organization.
+// tenant:exempt the fragment is appended to queries that are already tenant-scoped.
+func visibilityFilter(p auth.Principal) (string, []interface{}) {
+	if p.IsOrgWide() {
+		return "", nil
+	}
+	clause, args := subjectClause(p)
+	return " AND (visibility = 'public' OR id IN (SELECT service_id FROM service_acls WHERE " + clause + "))", args
+}
+
+// subjectClause matches ACL rows granted to the principal's user or any of its teams
+func subjectClause(p auth.Principal) (string, []interface{}) {
+	clause := "((subject_type = 'user' AND subject_id = ?)"
+	args := []interface{}{p.UserID}
+	if len(p.TeamIDs) > 0 {
+		clause += " OR (subject_type = 'team' AND subject_id IN (?" + strings.Repeat(", ?", len(p.TeamIDs)-1) + "))"
+		for _, id := range p.TeamIDs {
+			args = append(args, id)
+		}
+	}
+	return clause + ")", args
+}
+
+// SubjectInOrg reports whether a user or team belongs to an organization
+func (s *Store) SubjectInOrg(ctx context.Context, orgID, subjectType, subjectID string) (bool, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	table := "users"
+	if subjectType == models.SubjectTeam {
+		table = "teams"
+	}
+
+	var exists bool
+	err := tenantQueryRow(ctx, s.db, orgID, "SELECT EXISTS(SELECT 1 FROM "+table+" WHERE id = ? AND {{tenant}})", subjectID).Scan(&exists)
+	return exists, err
+}
@@ -0,0 +1,429 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/yashjain/konnect/internal/models"
+)
+
+// ExportBackup passes every organization, service and version, including
+// soft-deleted ones, then the specs, deployments and environments of versions,
+// categories with their services, and users, teams and their members, ACL
+// grants and consumers, to emit in that order. API tokens and sessions are
+// credentials and are left out, so they have to be issued again after a
+// restore. The rows are read in one transaction so the backup is a consistent
+// snapshot. Since a backup may take longer than the query timeout, it is only
+// bounded by ctx.
+// tenant:exempt backups cover all organizations.
+func (s *Store) ExportBackup(ctx context.Context, emit func(models.BackupRecord) error) error {
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{Isolation: s.db.dialect.snapshotIsolation})
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+			slog.WarnContext(ctx, "Error ending backup transaction", "error", err)
+		}
+	}()
+
+	err = exportRows(ctx, tx, "SELECT id, name, slug, created_at FROM organizations ORDER BY created_at, id", func(row *sql.Rows) error {
+		var o models.Organization
+		if err := row.Scan(&o.ID, &o.Name, &o.Slug, &o.CreatedAt); err != nil {
+			return err
+		}
+		o.CreatedAt = o.CreatedAt.UTC()
+		return emit(models.BackupRecord{Type: models.BackupOrganization, Organization: &o})
+	})
+	if err != nil {
+		return err
+	}
+
+	err = exportRows(ctx, tx, "SELECT "+s.db.dialect.serviceColumns()+" FROM services ORDER BY org_id, created_at, id", func(row *sql.Rows) error {
+		service, err := scanService(row)
+		if err != nil {
+			return err
+		}
+		return emit(models.BackupRecord{Type: models.BackupService, Service: &service})
+	})
+	if err != nil {
+		return err
+	}
+
+	err = exportRows(ctx, tx, "SELECT "+s.db.dialect.versionColumns()+" FROM versions v ORDER BY v.service_id, v.created_at, v.id", func(row *sql.Rows) error {
+		version, err := scanVersion(row)
+		if err != nil {
+			return err
+		}
+		return emit(models.BackupRecord{Type: models.BackupVersion, Version: &version})
+	})
+	if err != nil {
+		return err
+	}
+
+	err = exportRows(ctx, tx, "SELECT version_id, service_id, format, content, digest, lint_score, updated_at FROM version_specs ORDER BY service_id, version_id", func(row *sql.Rows) error {
+		var spec models.BackupSpecRecord
+		var score sql.NullInt64
+		if err := row.Scan(&spec.VersionID, &spec.ServiceID, &spec.Format, &spec.Content, &spec.Digest, &score, &spec.UpdatedAt); err != nil {
+			return err
+		}
+		if score.Valid {
+			n := int(score.Int64)
+			spec.Score = &n
+		}
+		spec.UpdatedAt = spec.UpdatedAt.UTC()
+		return emit(models.BackupRecord{Type: models.BackupSpec, Spec: &spec})
+	})
+	if err != nil {
+		return err
+	}
+
+	err = exportRows(ctx, tx, "SELECT "+deploymentColumns+" FROM deployments d ORDER BY d.service_id, d.deployed_at, d.id", func(row *sql.Rows) error {
+		var d models.Deployment
+		if err := row.Scan(&d.ID, &d.OrgID, &d.ServiceID, &d.VersionID, &d.Environment, &d.DeployedAt); err != nil {
+			return err
+		}
+		d.DeployedAt = d.DeployedAt.UTC()
+		return emit(models.BackupRecord{Type: models.BackupDeployment, Deployment: &d})
+	})
+	if err != nil {
+		return err
+	}
+
+	err = exportRows(ctx, tx, "SELECT service_id, environment, version_id, deployed_at FROM current_deployments ORDER BY service_id, environment", func(row *sql.Rows) error {
+		var e models.BackupEnvironmentRecord
+		if err := row.Scan(&e.ServiceID, &e.Environment, &e.VersionID, &e.DeployedAt); err != nil {
+			return err
+		}
+		e.DeployedAt = e.DeployedAt.UTC()
+		return emit(models.BackupRecord{Type: models.BackupEnvironment, Environment: &e})
+	})
+	if err != nil {
+		return err
+	}
+
+	var categories []models.Category
+	err = exportRows(ctx, tx, "SELECT "+categoryColumns+" FROM categories ORDER BY org_id, created_at, id", func(row *sql.Rows) error {
+		category, err := scanCategory(row)
+		if err != nil {
+			return err
+		}
+		categories = append(categories, *category)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, c := range parentsFirst(categories) {
+		record := models.BackupCategoryRecord{ID: c.ID, OrgID: c.OrgID, ParentID: c.ParentID, Name: c.Name, Slug: c.Slug, CreatedAt: c.CreatedAt.UTC()}
+		if err := emit(models.BackupRecord{Type: models.BackupCategory, Category: &record}); err != nil {
+			return err
+		}
+	}
+
+	err = exportRows(ctx, tx, "SELECT service_id, category_id FROM service_categories ORDER BY service_id, category_id", func(row *sql.Rows) error {
+		var sc models.BackupServiceCategoryRecord
+		if err := row.Scan(&sc.ServiceID, &sc.CategoryID); err != nil {
+			return err
+		}
+		return emit(models.BackupRecord{Type: models.BackupServiceCategory, ServiceCategory: &sc})
+	})
+	if err != nil {
+		return err
+	}
+
+	err = exportRows(ctx, tx, "SELECT id, org_id, email, name, password_hash, created_at FROM users ORDER BY org_id, created_at, id", func(row *sql.Rows) error {
+		var u models.BackupUserRecord
+		var hash sql.NullString
+		if err := row.Scan(&u.ID, &u.OrgID, &u.Email, &u.Name, &hash, &u.CreatedAt); err != nil {
+			return err
+		}
+		if hash.Valid {
+			u.PasswordHash = &hash.String
+		}
+		u.CreatedAt = u.CreatedAt.UTC()
+		return emit(models.BackupRecord{Type: models.BackupUser, User: &u})
+	})
+	if err != nil {
+		return err
+	}
+
+	err = exportRows(ctx, tx, "SELECT id, org_id, name, created_at FROM teams ORDER BY org_id, created_at, id", func(row *sql.Rows) error {
+		var t models.Team
+		if err := row.Scan(&t.ID, &t.OrgID, &t.Name, &t.CreatedAt); err != nil {
+			return err
+		}
+		t.CreatedAt = t.CreatedAt.UTC()
+		return emit(models.BackupRecord{Type: models.BackupTeam, Team: &t})
+	})
+	if err != nil {
+		return err
+	}
+
+	err = exportRows(ctx, tx, "SELECT team_id, user_id FROM team_members ORDER BY team_id, user_id", func(row *sql.Rows) error {
+		var m models.BackupTeamMemberRecord
+		if err := row.Scan(&m.TeamID, &m.UserID); err != nil {
+			return err
+		}
+		return emit(models.BackupRecord{Type: models.BackupTeamMember, TeamMember: &m})
+	})
+	if err != nil {
+		return err
+	}
+
+	err = exportRows(ctx, tx, "SELECT id, service_id, subject_type, subject_id, permission, created_at FROM service_acls ORDER BY service_id, created_at, id", func(row *sql.Rows) error {
+		var a models.ServiceACL
+		if err := row.Scan(&a.ID, &a.ServiceID, &a.SubjectType, &a.SubjectID, &a.Permission, &a.CreatedAt); err != nil {
+			return err
+		}
+		a.CreatedAt = a.CreatedAt.UTC()
+		return emit(models.BackupRecord{Type: models.BackupServiceACL, ServiceACL: &a})
+	})
+	if err != nil {
+		return err
+	}
+
+	return exportRows(ctx, tx, "SELECT id, service_id, team_id, version_id, created_at FROM consumers ORDER BY service_id, created_at, id", func(row *sql.Rows) error {
+		var c models.BackupConsumerRecord
+		var versionID sql.NullString
+		if err := row.Scan(&c.ID, &c.ServiceID, &c.TeamID, &versionID, &c.CreatedAt); err != nil {
+			return err
+		}
+		if versionID.Valid {
+			c.VersionID = &versionID.String
+		}
+		c.CreatedAt = c.CreatedAt.UTC()
+		return emit(models.BackupRecord{Type: models.BackupConsumer, Consumer: &c})
+	})
+}
+
+// parentsFirst orders categories so that each comes after its parent, since a
+// category can be moved under one created after it. Categories whose parent is
+// missing come last.
+func parentsFirst(categories []models.Category) []models.Category {
+	ordered := make([]models.Category, 0, len(categories))
+	placed := make(map[string]bool, len(categories))
+	for len(categories) > 0 {
+		var rest []models.Category
+		for _, c := range categories {
+			if c.ParentID == nil || placed[*c.ParentID] {
+				ordered = append(ordered, c)
+				placed[c.ID] = true
+			} else {
+				rest = append(rest, c)
+			}
+		}
+		if len(rest) == len(categories) {
+			return append(ordered, rest...)
+		}
+		categories = rest
+	}
+	return ordered
+}
+
+// exportRows runs query and passes each row to fn
+func exportRows(ctx context.Context, tx *txn, query string, fn func(*sql.Rows) error) error {
+	rows, err := tx.QueryContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.WarnContext(ctx, "Error closing rows", "error", err)
+		}
+	}()
+
+	for rows.Next() {
+		if err := fn(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// RestoreBackup inserts the records returned by next until it returns io.EOF, in
+// one transaction, keeping their IDs and timestamps. Rows that already exist are
+// left untouched, so a backup can be restored more than once, and records whose
+// organization, service, version, user or team is missing are skipped. Like
+// ExportBackup it is only bounded by ctx, and it is not retried on lock
+// conflicts since the records cannot be read twice.
+// tenant:exempt backups cover all organizations.
+func (s *Store) RestoreBackup(ctx context.Context, next func() (models.BackupRecord, error)) (models.RestoreResult, error) {
+	var result models.RestoreResult
+	err := s.runTx(ctx, func(tx *txn) error {
+		for {
+			record, err := next()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+
+			inserted, err := s.restoreRecord(ctx, tx, record)
+			if err != nil {
+				return err
+			}
+			switch {
+			case !inserted:
+				result.Skipped++
+			case record.Type == models.BackupOrganization:
+				result.Organizations++
+			case record.Type == models.BackupService:
+				result.Services++
+			case record.Type == models.BackupVersion:
+				result.Versions++
+			case record.Type == models.BackupSpec:
+				result.Specs++
+			case record.Type == models.BackupDeployment:
+				result.Deployments++
+			case record.Type == models.BackupEnvironment:
+				result.Environments++
+			case record.Type == models.BackupCategory:
+				result.Categories++
+			case record.Type == models.BackupServiceCategory:
+				result.ServiceCategories++
+			case record.Type == models.BackupUser:
+				result.Users++
+			case record.Type == models.BackupTeam:
+				result.Teams++
+			case record.Type == models.BackupTeamMember:
+				result.TeamMembers++
+			case record.Type == models.BackupServiceACL:
+				result.ServiceACLs++
+			default:
+				result.Consumers++
+			}
+		}
+	})
+	return result, err
+}
+
+// restoreRecord inserts one backup record, with the tags of a service, reporting
+// whether a row was inserted.
+// tenant:exempt records carry their own organization.
+func (s *Store) restoreRecord(ctx context.Context, tx *txn, record models.BackupRecord) (bool, error) {
+	var res sql.Result
+	var metadata interface{}
+	var err error
+	switch {
+	case record.Type == models.BackupOrganization && record.Organization != nil:
+		o := record.Organization
+		res, err = tx.ExecContext(ctx, s.db.dialect.insertIgnore+" organizations (id, name, slug, created_at) VALUES (?, ?, ?, ?)"+s.db.dialect.onConflictIgnore,
+			o.ID, o.Name, o.Slug, o.CreatedAt)
+	case record.Type == models.BackupService && record.Service != nil:
+		sv := record.Service
+		if metadata, err = encodeMetadata(sv.Metadata); err != nil {
+			return false, err
+		}
+		res, err = tx.ExecContext(ctx, s.db.dialect.insertIgnore+` services (id, org_id, name, slug, description, visibility, owner_team, owner_email, metadata, created_at, updated_at, deleted_at)
+			SELECT ?, id, ?, ?, ?, ?, COALESCE(?, ''), COALESCE(?, ''), ?, ?, ?, ? FROM organizations WHERE id = ?`+s.db.dialect.onConflictIgnore,
+			sv.ID, sv.Name, sv.Slug, sv.Description, sv.Visibility, sv.OwnerTeam, sv.OwnerEmail, metadata, sv.CreatedAt, sv.UpdatedAt, nullTime(sv.DeletedAt), sv.OrgID)
+	case record.Type == models.BackupVersion && record.Version != nil:
+		v := record.Version
+		if metadata, err = encodeMetadata(v.Metadata); err != nil {
+			return false, err
+		}
+		res, err = tx.ExecContext(ctx, s.db.dialect.insertIgnore+` versions (id, service_id, semver, status, changelog, metadata, created_at, deleted_at, deprecated_at, sunset_at, released_at)
+			SELECT ?, id, ?, ?, ?, ?, ?, ?, ?, ?, ? FROM services WHERE id = ?`+s.db.dialect.onConflictIgnore,
+			v.ID, v.Semver, v.Status, v.Changelog, metadata, v.CreatedAt, nullTime(v.DeletedAt), nullTime(v.DeprecatedAt), nullTime(v.SunsetAt), nullTime(v.ReleasedAt), v.ServiceID)
+	case record.Type == models.BackupSpec && record.Spec != nil:
+		sp := record.Spec
+		res, err = tx.ExecContext(ctx, s.db.dialect.insertIgnore+` version_specs (version_id, service_id, format, content, digest, lint_score, updated_at)
+			SELECT id, service_id, ?, ?, ?, ?, ? FROM versions WHERE id = ? AND service_id = ?`+s.db.dialect.onConflictIgnore,
+			sp.Format, sp.Content, sp.Digest, sp.Score, sp.UpdatedAt, sp.VersionID, sp.ServiceID)
+	case record.Type == models.BackupDeployment && record.Deployment != nil:
+		d := record.Deployment
+		res, err = tx.ExecContext(ctx, s.db.dialect.insertIgnore+` deployments (id, org_id, service_id, version_id, environment, deployed_at)
+			SELECT ?, s.org_id, s.id, v.id, ?, ? FROM versions v JOIN services s ON s.id = v.service_id WHERE v.id = ? AND v.service_id = ?`+s.db.dialect.onConflictIgnore,
+			d.ID, d.Environment, d.DeployedAt, d.VersionID, d.ServiceID)
+	case record.Type == models.BackupEnvironment && record.Environment != nil:
+		e := record.Environment
+		res, err = tx.ExecContext(ctx, s.db.dialect.insertIgnore+` current_deployments (service_id, environment, version_id, deployed_at)
+			SELECT service_id, ?, id, ? FROM versions WHERE id = ? AND service_id = ?`+s.db.dialect.onConflictIgnore,
+			e.Environment, e.DeployedAt, e.VersionID, e.ServiceID)
+	case record.Type == models.BackupCategory && record.Category != nil:
+		c := record.Category
+		// A category whose parent was skipped is restored as a root
+		res, err = tx.ExecContext(ctx, s.db.dialect.insertIgnore+` categories (id, org_id, parent_id, name, slug, created_at)
+			SELECT ?, o.id, p.id, ?, ?, ? FROM organizations o LEFT JOIN categories p ON p.id = ? AND p.org_id = o.id
+			WHERE o.id = ?`+s.db.dialect.onConflictIgnore,
+			c.ID, c.Name, c.Slug, c.CreatedAt, c.ParentID, c.OrgID)
+	case record.Type == models.BackupServiceCategory && record.ServiceCategory != nil:
+		sc := record.ServiceCategory
+		res, err = tx.ExecContext(ctx, s.db.dialect.insertIgnore+` service_categories (service_id, category_id)
+			SELECT s.id, c.id FROM services s JOIN categories c ON c.org_id = s.org_id WHERE s.id = ? AND c.id = ?`+s.db.dialect.onConflictIgnore,
+			sc.ServiceID, sc.CategoryID)
+	case record.Type == models.BackupUser && record.User != nil:
+		u := record.User
+		res, err = tx.ExecContext(ctx, s.db.dialect.insertIgnore+` users (id, org_id, email, name, password_hash, created_at)
+			SELECT ?, id, ?, ?, ?, ? FROM organizations WHERE id = ?`+s.db.dialect.onConflictIgnore,
+			u.ID, u.Email, u.Name, u.PasswordHash, u.CreatedAt, u.OrgID)
+	case record.Type == models.BackupTeam && record.Team != nil:
+		t := record.Team
+		res, err = tx.ExecContext(ctx, s.db.dialect.insertIgnore+` teams (id, org_id, name, created_at)
+			SELECT ?, id, ?, ? FROM organizations WHERE id = ?`+s.db.dialect.onConflictIgnore,
+			t.ID, t.Name, t.CreatedAt, t.OrgID)
+	case record.Type == models.BackupTeamMember && record.TeamMember != nil:
+		m := record.TeamMember
+		res, err = tx.ExecContext(ctx, s.db.dialect.insertIgnore+` team_members (team_id, user_id)
+			SELECT t.id, u.id FROM teams t JOIN users u ON u.org_id = t.org_id WHERE t.id = ? AND u.id = ?`+s.db.dialect.onConflictIgnore,
+			m.TeamID, m.UserID)
+	case record.Type == models.BackupServiceACL && record.ServiceACL != nil:
+		a := record.ServiceACL
+		// The user or team granted access must belong to the service's organization
+		subjects := "users"
+		if a.SubjectType == models.SubjectTeam {
+			subjects = "teams"
+		}
+		res, err = tx.ExecContext(ctx, s.db.dialect.insertIgnore+` service_acls (id, service_id, subject_type, subject_id, permission, created_at)
+			SELECT ?, s.id, ?, x.id, ?, ? FROM services s JOIN `+subjects+` x ON x.org_id = s.org_id WHERE s.id = ? AND x.id = ?`+s.db.dialect.onConflictIgnore,
+			a.ID, a.SubjectType, a.Permission, a.CreatedAt, a.ServiceID, a.SubjectID)
+	case record.Type == models.BackupConsumer && record.Consumer != nil:
+		c := record.Consumer
+		// The team must belong to the service's organization, and the version, if
+		// any, to the service
+		query := s.db.dialect.insertIgnore + ` consumers (id, org_id, service_id, team_id, version_id, created_at)
+			SELECT ?, s.org_id, s.id, t.id, ?, ? FROM services s JOIN teams t ON t.org_id = s.org_id WHERE s.id = ? AND t.id = ?`
+		args := []interface{}{c.ID, c.VersionID, c.CreatedAt, c.ServiceID, c.TeamID}
+		if c.VersionID != nil {
+			query += " AND EXISTS (SELECT 1 FROM versions v WHERE v.id = ? AND v.service_id = s.id)"
+			args = append(args, *c.VersionID)
+		}
+		res, err = tx.ExecContext(ctx, query+s.db.dialect.onConflictIgnore, args...)
+	default:
+		return false, fmt.Errorf("invalid backup record of type %q", record.Type)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil || n == 0 {
+		return false, err
+	}
+
+	if record.Type == models.BackupService {
+		for _, tag := range record.Service.Tags {
+			_, err = tenantExec(ctx, tx, record.Service.OrgID, s.db.dialect.insertIgnore+" service_tags (service_id, tag) SELECT id, ? FROM services WHERE id = ? AND {{tenant}}"+s.db.dialect.onConflictIgnore,
+				tag, record.Service.ID)
+			if err != nil {
+				return false, err
+			}
+		}
+	}
+	return true, nil
+}
+
+// nullTime binds an optional time, converting it like any other time argument when set
+func nullTime(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return *t
+}
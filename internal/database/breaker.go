@@ -0,0 +1,91 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/yashjain/konnect/internal/config"
+	"github.com/yashjain/konnect/internal/metrics"
+	"github.com/yashjain/konnect/internal/repository"
+)
+
+// breaker stops sending statements to a database that keeps failing. After
+// threshold consecutive failures it rejects calls for cooldown, then lets one
+// call through: success closes it again, failure rejects for another cooldown.
+// A nil breaker admits everything.
+type breaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+}
+
+// newBreaker returns nil, disabling the breaker, when the threshold is not positive
+func newBreaker(cfg config.DatabaseConfig) *breaker {
+	if cfg.BreakerThreshold <= 0 {
+		return nil
+	}
+	return &breaker{threshold: cfg.BreakerThreshold, cooldown: cfg.BreakerCooldown}
+}
+
+// allow returns a *repository.UnavailableError while the breaker is open
+func (b *breaker) allow() error {
+	if b == nil {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failures < b.threshold {
+		return nil
+	}
+
+	now := time.Now()
+	if now.Before(b.openUntil) {
+		return &repository.UnavailableError{RetryAfter: b.openUntil.Sub(now)}
+	}
+
+	// Let this call probe the database and hold everyone else back until it reports
+	b.openUntil = now.Add(b.cooldown)
+	return nil
+}
+
+// record counts err towards tripping the breaker. Calls the client abandoned say
+// nothing about the database, and errors the server itself returned mean it is up.
+func (b *breaker) record(ctx context.Context, err error) {
+	if b == nil {
+		return
+	}
+
+	failed := isConnectionError(ctx, err) || errors.Is(err, context.DeadlineExceeded)
+	if !failed && ctx.Err() != nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !failed {
+		if b.failures >= b.threshold {
+			slog.Info("Database recovered, closing circuit breaker")
+			metrics.DBCircuitOpen.Set(0)
+		}
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.failures >= b.threshold {
+		if b.failures == b.threshold {
+			slog.Error("Database failing, rejecting queries", "failures", b.failures, "for", b.cooldown, "error", err)
+			metrics.DBCircuitOpen.Set(1)
+		}
+		b.openUntil = time.Now().Add(b.cooldown)
+	}
+}
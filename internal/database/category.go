@@ -0,0 +1,268 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+
+	"github.com/yashjain/konnect/internal/models"
+	"github.com/yashjain/konnect/internal/repository"
+)
+
+// categoryColumns are the categories columns read by scanCategory
+const categoryColumns = "id, org_id, parent_id, name, slug, created_at"
+
+// CreateCategory creates a category within an organization. It returns
+// repository.ErrInvalidParent when its parent is not a category of the
+// organization, and repository.ErrConflict when the slug is taken.
+func (s *Store) CreateCategory(ctx context.Context, category *models.Category) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	category.CreatedAt = timestamp()
+	err := s.withTx(ctx, func(tx *txn) error {
+		if category.ParentID != nil {
+			if err := checkParent(ctx, tx, category.OrgID, *category.ParentID); err != nil {
+				return err
+			}
+		}
+		_, err := tenantExec(ctx, tx, category.OrgID, "INSERT INTO categories (id, org_id, parent_id, name, slug, created_at) VALUES (?, {{tenant_id}}, ?, ?, ?, ?)",
+			category.ID, category.ParentID, category.Name, category.Slug, category.CreatedAt)
+		return err
+	})
+	return conflictError(err)
+}
+
+// GetCategories returns every category of an organization by name, each with
+// the ID of its parent; callers build the tree from those
+func (s *Store) GetCategories(ctx context.Context, orgID string) ([]models.Category, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := tenantQuery(ctx, s.read, orgID, "SELECT "+categoryColumns+" FROM categories WHERE {{tenant}} ORDER BY name, id")
+	if err != nil {
+		return nil, err
+	}
+	return scanCategories(ctx, rows)
+}
+
+// GetCategory returns a category within an organization, or sql.ErrNoRows
+func (s *Store) GetCategory(ctx context.Context, orgID, id string) (*models.Category, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	return scanCategory(tenantQueryRow(ctx, s.read, orgID, "SELECT "+categoryColumns+" FROM categories WHERE id = ? AND {{tenant}}", id))
+}
+
+// UpdateCategory renames and moves a category within an organization. It
+// returns repository.ErrInvalidParent when the new parent is not a category of
+// the organization, or is the category itself or one of its subcategories, and
+// repository.ErrConflict when the slug is taken.
+func (s *Store) UpdateCategory(ctx context.Context, orgID, id string, category *models.Category) (int64, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var rowsAffected int64
+	err := s.withTx(ctx, func(tx *txn) error {
+		if category.ParentID != nil {
+			// Moves are checked against the whole tree, locked so that two
+			// concurrent moves cannot make a cycle together
+			parents, err := lockCategories(ctx, tx, s.db.dialect, orgID)
+			if err != nil {
+				return err
+			}
+			if _, ok := parents[id]; !ok {
+				return nil
+			}
+			if _, ok := parents[*category.ParentID]; !ok {
+				return repository.ErrInvalidParent
+			}
+			for ancestor := category.ParentID; ancestor != nil; ancestor = parents[*ancestor] {
+				if *ancestor == id {
+					return repository.ErrInvalidParent
+				}
+			}
+		}
+		result, err := tenantExec(ctx, tx, orgID, "UPDATE categories SET parent_id = ?, name = ?, slug = ? WHERE id = ? AND {{tenant}}",
+			category.ParentID, category.Name, category.Slug, id)
+		if err != nil {
+			return err
+		}
+		rowsAffected, err = result.RowsAffected()
+		return err
+	})
+	return rowsAffected, conflictError(err)
+}
+
+// DeleteCategory deletes a category within an organization, unassigning its
+// services. It returns repository.ErrInUse while the category has subcategories.
+func (s *Store) DeleteCategory(ctx context.Context, orgID, id string) (int64, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var rowsAffected int64
+	err := s.withTx(ctx, func(tx *txn) error {
+		var children int
+		if err := tenantQueryRow(ctx, tx, orgID, "SELECT COUNT(*) FROM categories WHERE parent_id = ? AND {{tenant}}", id).Scan(&children); err != nil {
+			return err
+		}
+		if children > 0 {
+			return repository.ErrInUse
+		}
+		if _, err := tenantExec(ctx, tx, orgID, "DELETE FROM service_categories WHERE category_id IN (SELECT id FROM categories WHERE id = ? AND {{tenant}})", id); err != nil {
+			return err
+		}
+		result, err := tenantExec(ctx, tx, orgID, "DELETE FROM categories WHERE id = ? AND {{tenant}}", id)
+		if err != nil {
+			return err
+		}
+		rowsAffected, err = result.RowsAffected()
+		return err
+	})
+	return rowsAffected, err
+}
+
+// GetServiceCategories returns the categories a service is assigned to within
+// an organization, by name
+func (s *Store) GetServiceCategories(ctx context.Context, orgID, serviceID string) ([]models.Category, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := tenantQuery(ctx, s.read, orgID, `
+		SELECT `+categoryColumns+` FROM categories
+		WHERE {{tenant}} AND id IN (SELECT category_id FROM service_categories WHERE service_id = ?)
+		ORDER BY name, id`, serviceID)
+	if err != nil {
+		return nil, err
+	}
+	return scanCategories(ctx, rows)
+}
+
+// AddServiceCategory assigns a service to a category within an organization,
+// doing nothing when it already is. It returns sql.ErrNoRows when the service
+// or the category is not in the organization.
+func (s *Store) AddServiceCategory(ctx context.Context, orgID, serviceID, categoryID string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	return s.withTx(ctx, func(tx *txn) error {
+		var found int
+		err := tenantQueryRow(ctx, tx, orgID, `
+			SELECT COUNT(*) FROM services s, categories c
+			WHERE s.id = ? AND {{tenant:s}} AND s.deleted_at IS NULL AND c.id = ? AND {{tenant:c}}`,
+			serviceID, categoryID).Scan(&found)
+		if err != nil {
+			return err
+		}
+		if found == 0 {
+			return sql.ErrNoRows
+		}
+		_, err = tenantExec(ctx, tx, orgID, s.db.dialect.insertIgnore+" service_categories (service_id, category_id) SELECT id, ? FROM services WHERE id = ? AND {{tenant}}"+s.db.dialect.onConflictIgnore,
+			categoryID, serviceID)
+		return err
+	})
+}
+
+// RemoveServiceCategory unassigns a service from a category within an organization
+func (s *Store) RemoveServiceCategory(ctx context.Context, orgID, serviceID, categoryID string) (int64, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	result, err := tenantExec(ctx, s.db, orgID, `
+		DELETE FROM service_categories
+		WHERE service_id = ? AND category_id IN (SELECT id FROM categories WHERE id = ? AND {{tenant}})`,
+		serviceID, categoryID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// categoryFilter matches services of the current services row assigned to a
+// category, given by ID or slug, or to any of its subcategories, together with
+// its arguments
+func categoryFilter(category string) (string, []interface{}) {
+	if category == "" {
+		return "", nil
+	}
+	return ` AND id IN (SELECT sc.service_id FROM service_categories sc WHERE sc.category_id IN (
+		WITH RECURSIVE subtree (id) AS (
+			SELECT id FROM categories WHERE (id = ? OR slug = ?) AND {{tenant}}
+			UNION ALL
+			SELECT c.id FROM categories c JOIN subtree t ON c.parent_id = t.id
+		)
+		SELECT id FROM subtree))`, []interface{}{category, category}
+}
+
+// lockCategories locks the categories of an organization until tx ends,
+// returning the parent of each by ID
+func lockCategories(ctx context.Context, tx *txn, d *dialect, orgID string) (map[string]*string, error) {
+	rows, err := tenantQuery(ctx, tx, orgID, "SELECT id, parent_id FROM categories WHERE {{tenant}}"+d.forUpdate)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.WarnContext(ctx, "Error closing rows", "error", err)
+		}
+	}()
+
+	parents := map[string]*string{}
+	for rows.Next() {
+		var id string
+		var parentID sql.NullString
+		if err := rows.Scan(&id, &parentID); err != nil {
+			return nil, err
+		}
+		parents[id] = nil
+		if parentID.Valid {
+			parents[id] = &parentID.String
+		}
+	}
+	return parents, rows.Err()
+}
+
+// checkParent returns repository.ErrInvalidParent unless parentID is a category of the organization
+func checkParent(ctx context.Context, tx *txn, orgID, parentID string) error {
+	var found int
+	if err := tenantQueryRow(ctx, tx, orgID, "SELECT COUNT(*) FROM categories WHERE id = ? AND {{tenant}}", parentID).Scan(&found); err != nil {
+		return err
+	}
+	if found == 0 {
+		return repository.ErrInvalidParent
+	}
+	return nil
+}
+
+// scanCategories scans and closes rows of categoryColumns
+func scanCategories(ctx context.Context, rows *sql.Rows) ([]models.Category, error) {
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.WarnContext(ctx, "Error closing rows", "error", err)
+		}
+	}()
+
+	categories := []models.Category{}
+	for rows.Next() {
+		category, err := scanCategory(rows)
+		if err != nil {
+			return nil, err
+		}
+		categories = append(categories, *category)
+	}
+	return categories, rows.Err()
+}
+
+// scanCategory scans the categoryColumns of a row
+func scanCategory(row rowScanner) (*models.Category, error) {
+	var category models.Category
+	var parentID sql.NullString
+	if err := row.Scan(&category.ID, &category.OrgID, &parentID, &category.Name, &category.Slug, &category.CreatedAt); err != nil {
+		return nil, err
+	}
+	if parentID.Valid {
+		category.ParentID = &parentID.String
+	}
+	category.CreatedAt = category.CreatedAt.UTC()
+	return &category, nil
+}
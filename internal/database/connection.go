@@ -1,48 +1,236 @@
 package database
 
 import (
+	"context"
 	"database/sql"
-	"log"
-	"os"
+	"database/sql/driver"
+	"fmt"
+	"log/slog"
+	"time"
 
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+	"github.com/yashjain/konnect/internal/config"
+	"github.com/yashjain/konnect/internal/repository"
+	"github.com/yashjain/konnect/pkg/types"
 )
 
-var DB *sql.DB
+// Store implements repository.Repository on MySQL, Postgres or SQLite
+type Store struct {
+	db *conn
 
-// Init initializes the database connection
-func Init() error {
-	dsn := getDatabaseDSN()
+	// read serves list, search and get queries: a replica when one is
+	// configured, otherwise the primary
+	read querier
 
-	var err error
-	DB, err = sql.Open("mysql", dsn)
+	// timeout bounds each repository call, including reading its rows
+	timeout time.Duration
+
+	health health
+
+	// outbox records service and version changes as events for the relay to deliver
+	outbox bool
+
+	// kongSync queues services to be synced to Kong whenever they or their versions change
+	kongSync bool
+
+	// notifications queues emails about deprecated versions to their owners and subscribers
+	notifications bool
+
+	// weights weigh matches in each field when ranking searches
+	weights types.SearchWeights
+}
+
+var _ repository.Repository = (*Store)(nil)
+
+// Open connects to the database selected by DB_DRIVER
+func Open() (*Store, error) {
+	cfg := config.LoadDatabase()
+
+	if err := SetFieldEncryptionKeys(cfg.EncryptionKeys); err != nil {
+		return nil, err
+	}
+
+	if cfg.Driver == config.DriverSQLite {
+		return openSQLite(cfg)
+	}
+
+	primary, err := newConnector(cfg, cfg.DSN)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	var replica driver.Connector
+	replicaDSN, err := cfg.ReplicaDSN.Resolve()
+	if err != nil {
+		slog.Warn("Read replica disabled", "error", err)
+	}
+	if replicaDSN != "" {
+		// The replica speaks the primary's driver, which newConnector just accepted
+		replica, _ = newConnector(cfg, cfg.ReplicaDSN)
+	}
+
+	store, err := NewStore(cfg, primary, replica)
+	if err != nil {
+		return nil, err
+	}
+	if err := store.db.db.Ping(); err != nil {
+		if closeErr := store.Close(); closeErr != nil {
+			slog.Warn("Error closing database", "error", closeErr)
+		}
+		return nil, err
+	}
+
+	// An unreachable replica is not fatal; reads fall back to the primary until it recovers
+	if reads, ok := store.read.(*replicaConn); ok {
+		if err := reads.replica.db.Ping(); err != nil {
+			reads.markDown(err)
+		}
+	}
+
+	return store, nil
+}
+
+// NewStore returns a store on the database primary connects to, speaking the
+// SQL of cfg.Driver, mysql or postgres, with reads served by replica when it is
+// not nil. It does not connect; Open builds the connectors from the configured
+// DSNs and checks that the primary is up.
+func NewStore(cfg config.DatabaseConfig, primary, replica driver.Connector) (*Store, error) {
+	var d *dialect
+	switch cfg.Driver {
+	case config.DriverMySQL:
+		d = mysqlDialect
+	case config.DriverPostgres:
+		d = postgresDialect
+	default:
+		return nil, fmt.Errorf("unsupported DB_DRIVER %q: must be mysql or postgres", cfg.Driver)
+	}
+
+	p := newConn(cfg, primary, d)
+	p.breaker = newBreaker(cfg)
+	store := &Store{db: p, read: p, timeout: cfg.QueryTimeout, outbox: cfg.Outbox, kongSync: cfg.KongSync, notifications: cfg.Notifications, weights: cfg.SearchWeights}
+	if replica != nil {
+		store.read = &replicaConn{primary: p, replica: newConn(cfg, replica, d), downFor: cfg.ReplicaDownFor}
+	}
+	return store, nil
+}
+
+// newConnector returns the connector of the configured driver for dsn
+func newConnector(cfg config.DatabaseConfig, dsn *config.SecretSource) (driver.Connector, error) {
+	switch cfg.Driver {
+	case config.DriverMySQL:
+		return &rotatingConnector{dsn: dsn}, nil
+	case config.DriverPostgres:
+		return &postgresConnector{dsn: dsn}, nil
+	default:
+		return nil, fmt.Errorf("unsupported DB_DRIVER %q: must be mysql, postgres or sqlite", cfg.Driver)
 	}
+}
+
+// newConn opens a connection pool on connector without connecting
+func newConn(cfg config.DatabaseConfig, connector driver.Connector, d *dialect) *conn {
+	db := sql.OpenDB(connector)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	return &conn{db: db, dialect: d, retry: newRetryPolicy(cfg), stmts: newStmtCache(db, cfg.StatementCacheSize), slow: slowLog{cfg.SlowQueryThreshold}}
+}
+
+// DB returns the underlying primary connection pool
+func (s *Store) DB() *sql.DB {
+	return s.db.db
+}
+
+// Pools returns the connection pools by role: "primary", and "replica" when a
+// read replica is configured
+func (s *Store) Pools() map[string]*sql.DB {
+	pools := map[string]*sql.DB{"primary": s.db.db}
+	if reads, ok := s.read.(*replicaConn); ok {
+		pools["replica"] = reads.replica.db
+	}
+	return pools
+}
 
-	if err = DB.Ping(); err != nil {
-		if closeErr := DB.Close(); closeErr != nil {
-			log.Printf("Error closing database: %v", closeErr)
+// Close closes the database connections
+func (s *Store) Close() error {
+	if reads, ok := s.read.(*replicaConn); ok {
+		reads.replica.stmts.close()
+		if err := reads.replica.db.Close(); err != nil {
+			slog.Warn("Error closing read replica", "error", err)
 		}
-		return err
 	}
+	s.db.stmts.close()
+	return s.db.db.Close()
+}
+
+// withTimeout derives the context for one repository call from the caller's,
+// which is usually the request context, so a cancelled request cancels its queries
+func (s *Store) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if s.timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, s.timeout)
+}
 
-	return nil
+// timestamp returns the creation time stamped on new rows: UTC, and truncated to
+// the second precision of MySQL TIMESTAMP columns so that callers are handed the
+// same value that is later read back
+func timestamp() time.Time {
+	return time.Now().UTC().Truncate(time.Second)
 }
 
-// Close closes the database connection
-func Close() error {
-	if DB != nil {
-		return DB.Close()
+// rotatingConnector resolves the DSN for every new connection, so credentials
+// rotated in a mounted file or Vault are used once old connections expire
+type rotatingConnector struct {
+	dsn *config.SecretSource
+}
+
+// Connect opens a new MySQL connection using the current DSN
+func (c *rotatingConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	dsn, err := c.dsn.Resolve()
+	if err != nil {
+		return nil, err
 	}
-	return nil
+
+	mysqlCfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	// Timestamps are scanned into time.Time, which needs parseTime whatever the DSN says
+	mysqlCfg.ParseTime = true
+
+	connector, err := mysql.NewConnector(mysqlCfg)
+	if err != nil {
+		return nil, err
+	}
+	return connector.Connect(ctx)
+}
+
+// Driver returns the underlying MySQL driver
+func (c *rotatingConnector) Driver() driver.Driver {
+	return &mysql.MySQLDriver{}
 }
 
-// getDatabaseDSN returns the database connection string
-func getDatabaseDSN() string {
-	dsn := os.Getenv("MYSQL_DSN")
-	if dsn == "" {
-		dsn = "app:app@tcp(127.0.0.1:3306)/servicesdb?parseTime=true&charset=utf8mb4&collation=utf8mb4_0900_ai_ci"
+// postgresConnector is the Postgres counterpart of rotatingConnector
+type postgresConnector struct {
+	dsn *config.SecretSource
+}
+
+// Connect opens a new Postgres connection using the current DSN
+func (c *postgresConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	dsn, err := c.dsn.Resolve()
+	if err != nil {
+		return nil, err
+	}
+
+	pgCfg, err := pgx.ParseConfig(dsn)
+	if err != nil {
+		return nil, err
 	}
-	return dsn
+	return stdlib.GetConnector(*pgCfg).Connect(ctx)
+}
+
+// Driver returns the underlying pgx driver
+func (c *postgresConnector) Driver() driver.Driver {
+	return stdlib.GetDefaultDriver()
 }
@@ -0,0 +1,142 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+
+	"github.com/yashjain/konnect/internal/models"
+	"github.com/yashjain/konnect/internal/repository"
+)
+
+// consumerColumns are the consumers columns read by scanConsumer, followed by the team's name
+const consumerColumns = "c.id, c.org_id, c.service_id, c.team_id, c.version_id, c.created_at, t.name"
+
+// CreateConsumer registers a team as a consumer of a service within an
+// organization, or of one of its versions when VersionID is set. It returns
+// sql.ErrNoRows when the service, team or version is not in the organization
+// or has been soft-deleted, and repository.ErrConflict when the team already
+// consumes the same service or version.
+func (s *Store) CreateConsumer(ctx context.Context, consumer *models.Consumer) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	consumer.CreatedAt = timestamp()
+	return s.withTx(ctx, func(tx *txn) error {
+		// Lock the service, so that registering a consumer and deleting the
+		// service happen one after the other
+		var serviceID string
+		err := tenantQueryRow(ctx, tx, consumer.OrgID, "SELECT id FROM services WHERE id = ? AND {{tenant}} AND deleted_at IS NULL"+s.db.dialect.forUpdate, consumer.ServiceID).Scan(&serviceID)
+		if err != nil {
+			return err
+		}
+		err = tenantQueryRow(ctx, tx, consumer.OrgID, "SELECT name FROM teams WHERE id = ? AND {{tenant}}", consumer.TeamID).Scan(&consumer.TeamName)
+		if err != nil {
+			return err
+		}
+		if consumer.VersionID != nil {
+			var found int
+			err = tenantQueryRow(ctx, tx, consumer.OrgID, `
+				SELECT COUNT(*) FROM versions
+				WHERE id = ? AND service_id = ? AND deleted_at IS NULL
+					AND service_id IN (SELECT id FROM services WHERE {{tenant}})`,
+				*consumer.VersionID, consumer.ServiceID).Scan(&found)
+			if err != nil {
+				return err
+			}
+			if found == 0 {
+				return sql.ErrNoRows
+			}
+		}
+
+		versionFilter, versionArgs := consumedVersion(consumer.VersionID)
+		var existing int
+		err = tenantQueryRow(ctx, tx, consumer.OrgID, "SELECT COUNT(*) FROM consumers WHERE service_id = ? AND team_id = ? AND {{tenant}}"+versionFilter,
+			append([]interface{}{consumer.ServiceID, consumer.TeamID}, versionArgs...)...).Scan(&existing)
+		if err != nil {
+			return err
+		}
+		if existing > 0 {
+			return repository.ErrConflict
+		}
+
+		_, err = tenantExec(ctx, tx, consumer.OrgID, "INSERT INTO consumers (id, org_id, service_id, team_id, version_id, created_at) VALUES (?, {{tenant_id}}, ?, ?, ?, ?)",
+			consumer.ID, consumer.ServiceID, consumer.TeamID, consumer.VersionID, consumer.CreatedAt)
+		return err
+	})
+}
+
+// consumedVersion matches consumers of the current consumers row consuming
+// versionID, or every version when it is nil, together with its arguments
+func consumedVersion(versionID *string) (string, []interface{}) {
+	if versionID == nil {
+		return " AND version_id IS NULL", nil
+	}
+	return " AND version_id = ?", []interface{}{*versionID}
+}
+
+// GetConsumers returns the consumers of a service within an organization, by
+// team name, those of every version before those of a single one
+func (s *Store) GetConsumers(ctx context.Context, orgID, serviceID string) ([]models.Consumer, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := tenantQuery(ctx, s.read, orgID, `
+		SELECT `+consumerColumns+` FROM consumers c JOIN teams t ON t.id = c.team_id
+		WHERE c.service_id = ? AND {{tenant:c}}
+		ORDER BY t.name, c.version_id IS NOT NULL, c.created_at, c.id`, serviceID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.WarnContext(ctx, "Error closing rows", "error", err)
+		}
+	}()
+
+	consumers := []models.Consumer{}
+	for rows.Next() {
+		consumer, err := scanConsumer(rows)
+		if err != nil {
+			return nil, err
+		}
+		consumers = append(consumers, *consumer)
+	}
+	return consumers, rows.Err()
+}
+
+// GetConsumer returns a consumer of a service within an organization, or sql.ErrNoRows
+func (s *Store) GetConsumer(ctx context.Context, orgID, serviceID, id string) (*models.Consumer, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	return scanConsumer(tenantQueryRow(ctx, s.read, orgID, `
+		SELECT `+consumerColumns+` FROM consumers c JOIN teams t ON t.id = c.team_id
+		WHERE c.id = ? AND c.service_id = ? AND {{tenant:c}}`, id, serviceID))
+}
+
+// DeleteConsumer removes a consumer of a service within an organization
+func (s *Store) DeleteConsumer(ctx context.Context, orgID, serviceID, id string) (int64, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	result, err := tenantExec(ctx, s.db, orgID, "DELETE FROM consumers WHERE id = ? AND service_id = ? AND {{tenant}}", id, serviceID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// scanConsumer scans the consumerColumns of a row
+func scanConsumer(row rowScanner) (*models.Consumer, error) {
+	var consumer models.Consumer
+	var versionID sql.NullString
+	if err := row.Scan(&consumer.ID, &consumer.OrgID, &consumer.ServiceID, &consumer.TeamID, &versionID, &consumer.CreatedAt, &consumer.TeamName); err != nil {
+		return nil, err
+	}
+	if versionID.Valid {
+		consumer.VersionID = &versionID.String
+	}
+	consumer.CreatedAt = consumer.CreatedAt.UTC()
+	return &consumer, nil
+}
@@ -0,0 +1,124 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+
+	"github.com/yashjain/konnect/internal/models"
+)
+
+// deploymentColumns are the deployments columns GetDeployments reads, in order
+const deploymentColumns = "d.id, d.org_id, d.service_id, d.version_id, d.environment, d.deployed_at"
+
+// CreateDeployment records a version of a service within an organization being
+// deployed to an environment, which then runs that version in place of the one
+// deployed there before. It returns sql.ErrNoRows when the version is not in
+// the organization or either it or its service has been soft-deleted.
+func (s *Store) CreateDeployment(ctx context.Context, deployment *models.Deployment) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	deployment.DeployedAt = timestamp()
+	return s.withTx(ctx, func(tx *txn) error {
+		// Lock the service, so that concurrent deployments to one of its
+		// environments replace its current version one after the other
+		var serviceID string
+		err := tenantQueryRow(ctx, tx, deployment.OrgID, "SELECT id FROM services WHERE id = ? AND {{tenant}} AND deleted_at IS NULL"+s.db.dialect.forUpdate, deployment.ServiceID).Scan(&serviceID)
+		if err != nil {
+			return err
+		}
+		var found int
+		err = tenantQueryRow(ctx, tx, deployment.OrgID, `
+			SELECT COUNT(*) FROM versions
+			WHERE id = ? AND service_id = ? AND deleted_at IS NULL
+				AND service_id IN (SELECT id FROM services WHERE {{tenant}})`,
+			deployment.VersionID, deployment.ServiceID).Scan(&found)
+		if err != nil {
+			return err
+		}
+		if found == 0 {
+			return sql.ErrNoRows
+		}
+
+		_, err = tenantExec(ctx, tx, deployment.OrgID, "INSERT INTO deployments (id, org_id, service_id, version_id, environment, deployed_at) VALUES (?, {{tenant_id}}, ?, ?, ?, ?)",
+			deployment.ID, deployment.ServiceID, deployment.VersionID, deployment.Environment, deployment.DeployedAt)
+		if err != nil {
+			return err
+		}
+		_, err = tenantExec(ctx, tx, deployment.OrgID, "DELETE FROM current_deployments WHERE service_id IN (SELECT id FROM services WHERE id = ? AND {{tenant}}) AND environment = ?",
+			deployment.ServiceID, deployment.Environment)
+		if err != nil {
+			return err
+		}
+		_, err = tenantExec(ctx, tx, deployment.OrgID, "INSERT INTO current_deployments (service_id, environment, version_id, deployed_at) SELECT id, ?, ?, ? FROM services WHERE id = ? AND {{tenant}}",
+			deployment.Environment, deployment.VersionID, deployment.DeployedAt, deployment.ServiceID)
+		return err
+	})
+}
+
+// GetDeployments returns up to limit deployments of a version of a service
+// within an organization, newest first. It returns sql.ErrNoRows when the
+// version is not in the organization or either it or its service has been
+// soft-deleted.
+func (s *Store) GetDeployments(ctx context.Context, orgID, serviceID, versionID string, limit int) ([]models.Deployment, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var found int
+	err := tenantQueryRow(ctx, s.read, orgID, `
+		SELECT COUNT(*) FROM versions v JOIN services s ON s.id = v.service_id
+		WHERE v.id = ? AND v.service_id = ? AND {{tenant:s}} AND v.deleted_at IS NULL AND s.deleted_at IS NULL`,
+		versionID, serviceID).Scan(&found)
+	if err != nil {
+		return nil, err
+	}
+	if found == 0 {
+		return nil, sql.ErrNoRows
+	}
+
+	rows, err := tenantQuery(ctx, s.read, orgID, `
+		SELECT `+deploymentColumns+` FROM deployments d
+		WHERE d.version_id = ? AND d.service_id = ? AND {{tenant:d}}
+		ORDER BY d.deployed_at DESC, d.id
+		LIMIT ?`, versionID, serviceID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.WarnContext(ctx, "Error closing rows", "error", err)
+		}
+	}()
+
+	deployments := []models.Deployment{}
+	for rows.Next() {
+		var d models.Deployment
+		if err := rows.Scan(&d.ID, &d.OrgID, &d.ServiceID, &d.VersionID, &d.Environment, &d.DeployedAt); err != nil {
+			return nil, err
+		}
+		d.DeployedAt = d.DeployedAt.UTC()
+		deployments = append(deployments, d)
+	}
+	return deployments, rows.Err()
+}
+
+// deployedServiceFilter matches services of the current services row currently
+// deployed to an environment, together with its arguments.
+// tenant:exempt the fragment is appended to queries that are already tenant-scoped.
+func deployedServiceFilter(environment string) (string, []interface{}) {
+	if environment == "" {
+		return "", nil
+	}
+	return " AND id IN (SELECT service_id FROM current_deployments WHERE environment = ?)", []interface{}{environment}
+}
+
+// deployedVersionFilter matches versions of the current versions row, aliased
+// v, currently deployed to an environment, together with its arguments.
+// tenant:exempt the fragment is appended to queries that are already tenant-scoped.
+func deployedVersionFilter(environment string) (string, []interface{}) {
+	if environment == "" {
+		return "", nil
+	}
+	return " AND v.id IN (SELECT version_id FROM current_deployments WHERE environment = ?)", []interface{}{environment}
+}
@@ -0,0 +1,514 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yashjain/konnect/internal/search"
+	"github.com/yashjain/konnect/pkg/types"
+	"github.com/yashjain/konnect/pkg/utils"
+)
+
+// dialect captures the SQL that differs between supported drivers. Queries in
+// this package are written for MySQL with ? placeholders and use these fragments
+// where the syntax diverges.
+type dialect struct {
+	// numbered rewrites ? placeholders to $1, $2, ... before a query is run
+	numbered bool
+
+	// searchMatch is a services predicate matching a search string, bound to each
+	// of its placeholders
+	searchMatch string
+
+	// searchScore is the relevance of a service to a search string bound to each of
+	// its placeholders, weighing matches in each field by the weights; higher
+	// scores are better matches
+	searchScore func(w types.SearchWeights) string
+
+	// booleanMatch and booleanScore are searchMatch and searchScore for a boolean
+	// query, which booleanTerm renders in the dialect's syntax
+	booleanMatch string
+	booleanScore func(w types.SearchWeights) string
+	booleanTerm  func(q search.BooleanQuery) string
+
+	// fuzzyMatch is a services predicate matching names that resemble one bound
+	// search string despite typos, and fuzzyScore how closely they resemble it,
+	// higher being closer. fuzzyTerm rewrites the search string for both; nil
+	// binds it as is.
+	fuzzyMatch string
+	fuzzyScore string
+	fuzzyTerm  func(query string) string
+
+	// tagList aggregates the tags of the service in the current services row into
+	// one comma-separated string, NULL when it has none, in no particular order
+	tagList string
+
+	// environmentList aggregates the environments currently running the version
+	// in the current versions row, aliased v, into one comma-separated string,
+	// NULL when it runs in none
+	environmentList string
+
+	// like is the case-insensitive LIKE operator
+	like string
+
+	// fold wraps a column so that it compares like values folded with utils.Fold,
+	// ignoring case and accents; nil compares it as is, where the collation already does
+	fold func(column string) string
+
+	// metadataValue extracts the string value of a bound key from a JSON metadata
+	// column, NULL when it is missing; metadataKey rewrites the key to bind, nil
+	// binds it as is
+	metadataValue func(column string) string
+	metadataKey   func(key string) string
+
+	// searchTerm rewrites a user's search string for searchMatch and searchScore; nil binds it as is
+	searchTerm func(query string) string
+
+	// insertIgnore begins an INSERT that skips rows violating a unique key;
+	// onConflictIgnore must be appended to the statement
+	insertIgnore     string
+	onConflictIgnore string
+
+	// upsertACL is appended to an INSERT into service_acls to replace the
+	// permission of an existing grant to the same subject
+	upsertACL string
+
+	// reindex rebuilds the services search index
+	reindex string
+
+	// forUpdate is appended to a SELECT to lock the selected rows until the transaction ends
+	forUpdate string
+
+	// snapshotIsolation is the isolation level at which a transaction reads a
+	// consistent snapshot of every table
+	snapshotIsolation sql.IsolationLevel
+
+	// timeLayout formats time.Time arguments for drivers that store timestamps as
+	// text, so bound times compare and sort like CURRENT_TIMESTAMP; empty binds them as is
+	timeLayout string
+}
+
+// mysqlDialect matches searches through the FULLTEXT indexes on (name, description)
+// and slug, and scores them through the index of each field
+var mysqlDialect = &dialect{
+	searchMatch:  "(MATCH(name, description) AGAINST(? IN NATURAL LANGUAGE MODE) OR MATCH(slug) AGAINST(? IN NATURAL LANGUAGE MODE))",
+	searchScore:  mysqlScore("NATURAL LANGUAGE"),
+	booleanMatch: "(MATCH(name, description) AGAINST(? IN BOOLEAN MODE) OR MATCH(slug) AGAINST(? IN BOOLEAN MODE))",
+	booleanScore: mysqlScore("BOOLEAN"),
+	booleanTerm:  mysqlBooleanQuery,
+	fuzzyMatch:   "MATCH(name, slug) AGAINST(? IN NATURAL LANGUAGE MODE)",
+	fuzzyScore:   "MATCH(name, slug) AGAINST(? IN NATURAL LANGUAGE MODE)",
+	tagList:      "(SELECT GROUP_CONCAT(t.tag) FROM service_tags t WHERE t.service_id = services.id)",
+	like:         "LIKE",
+	insertIgnore: "INSERT IGNORE INTO",
+	upsertACL:    "ON DUPLICATE KEY UPDATE permission = VALUES(permission)",
+	reindex:      "OPTIMIZE TABLE services",
+	forUpdate:    " FOR UPDATE",
+
+	metadataValue: func(column string) string { return "JSON_UNQUOTE(JSON_EXTRACT(" + column + ", ?))" },
+	metadataKey:   jsonPath,
+
+	environmentList: "(SELECT GROUP_CONCAT(d.environment) FROM current_deployments d WHERE d.version_id = v.id)",
+
+	snapshotIsolation: sql.LevelRepeatableRead,
+}
+
+var postgresDialect = &dialect{
+	numbered:         true,
+	searchMatch:      "search_vector @@ plainto_tsquery('english', ?)",
+	searchScore:      postgresScore("plainto_tsquery"),
+	booleanMatch:     "search_vector @@ to_tsquery('english', ?)",
+	booleanScore:     postgresScore("to_tsquery"),
+	booleanTerm:      tsQuery,
+	searchTerm:       utils.Fold,
+	fuzzyMatch:       "? <% name",
+	fuzzyScore:       "word_similarity(?, name)",
+	tagList:          "(SELECT string_agg(t.tag, ',') FROM service_tags t WHERE t.service_id = services.id)",
+	like:             "ILIKE",
+	fold:             func(column string) string { return "lower(f_unaccent(" + column + "))" },
+	metadataValue:    func(column string) string { return "(" + column + " ->> ?)" },
+	insertIgnore:     "INSERT INTO",
+	onConflictIgnore: " ON CONFLICT DO NOTHING",
+	upsertACL:        "ON CONFLICT (service_id, subject_type, subject_id) DO UPDATE SET permission = EXCLUDED.permission",
+	reindex:          "REINDEX TABLE services",
+	forUpdate:        " FOR UPDATE",
+
+	environmentList: "(SELECT string_agg(d.environment, ',') FROM current_deployments d WHERE d.version_id = v.id)",
+
+	snapshotIsolation: sql.LevelRepeatableRead,
+}
+
+// sqliteDialect searches through the services_fts FTS5 table, and fuzzily through
+// the services_trigram one, whose bm25 ranks are negated into scores because lower
+// ranks are better matches. SQLite has no row locks; a write transaction locks
+// the whole database instead, and every transaction is serializable.
+var sqliteDialect = &dialect{
+	searchMatch:  "rowid IN (SELECT rowid FROM services_fts WHERE services_fts MATCH ?)",
+	searchScore:  sqliteScore,
+	searchTerm:   ftsQuery,
+	booleanMatch: "rowid IN (SELECT rowid FROM services_fts WHERE services_fts MATCH ?)",
+	booleanScore: sqliteScore,
+	booleanTerm:  ftsBooleanQuery,
+	fuzzyMatch:   "rowid IN (SELECT rowid FROM services_trigram WHERE services_trigram MATCH ?)",
+	fuzzyScore:   "-(SELECT rank FROM services_trigram WHERE services_trigram MATCH ? AND services_trigram.rowid = services.rowid)",
+	fuzzyTerm:    trigramQuery,
+	tagList:      "(SELECT group_concat(t.tag) FROM service_tags t WHERE t.service_id = services.id)",
+	like:         "LIKE",
+	fold:         func(column string) string { return "fold(" + column + ")" },
+	insertIgnore: "INSERT OR IGNORE INTO",
+	upsertACL:    "ON CONFLICT (service_id, subject_type, subject_id) DO UPDATE SET permission = excluded.permission",
+	reindex:      "INSERT INTO services_fts (services_fts) VALUES ('rebuild')",
+	timeLayout:   "2006-01-02 15:04:05.999999999",
+
+	metadataValue: func(column string) string { return "json_extract(" + column + ", ?)" },
+	metadataKey:   jsonPath,
+
+	environmentList: "(SELECT group_concat(d.environment) FROM current_deployments d WHERE d.version_id = v.id)",
+}
+
+// weight formats a search weight as an SQL number. Weights come from
+// configuration, never from requests.
+func weight(w float64) string {
+	return strconv.FormatFloat(w, 'g', -1, 64)
+}
+
+// mysqlScore sums the relevance of each field to a search in mode, through its own
+// FULLTEXT index, times its weight. Fields weighing zero are left out.
+func mysqlScore(mode string) func(w types.SearchWeights) string {
+	return func(w types.SearchWeights) string {
+		var terms []string
+		for _, f := range []struct {
+			column string
+			weight float64
+		}{{"name", w.Name}, {"slug", w.Slug}, {"description", w.Description}} {
+			if f.weight > 0 {
+				terms = append(terms, weight(f.weight)+" * MATCH("+f.column+") AGAINST(? IN "+mode+" MODE)")
+			}
+		}
+		return "(" + strings.Join(terms, " + ") + ")"
+	}
+}
+
+// postgresScore ranks search_vector against a query parsed by parser, weighing
+// names, slugs and descriptions, which it labels A, B and C. ts_rank takes weights
+// up to 1, so they are scaled by the largest.
+func postgresScore(parser string) func(w types.SearchWeights) string {
+	return func(w types.SearchWeights) string {
+		max := math.Max(w.Name, math.Max(w.Slug, w.Description))
+		return "ts_rank('{0, " + weight(w.Description/max) + ", " + weight(w.Slug/max) + ", " + weight(w.Name/max) + "}'::real[], search_vector, " + parser + "('english', ?))"
+	}
+}
+
+// sqliteScore negates the bm25 rank of services_fts, whose columns are name, slug
+// and description, weighing each
+func sqliteScore(w types.SearchWeights) string {
+	return "-(SELECT bm25(services_fts, " + weight(w.Name) + ", " + weight(w.Slug) + ", " + weight(w.Description) + ") FROM services_fts WHERE services_fts MATCH ? AND services_fts.rowid = services.rowid)"
+}
+
+// ftsQuery turns free text into an FTS5 query matching any of its words, quoting
+// each word so that FTS5 operators and punctuation in user input are taken literally
+func ftsQuery(query string) string {
+	words := strings.Fields(query)
+	for i, w := range words {
+		words[i] = `"` + strings.ReplaceAll(w, `"`, `""`) + `"`
+	}
+	return strings.Join(words, " OR ")
+}
+
+// trigramQuery turns free text into an FTS5 query matching names that share any
+// trigram with its words, so that names sharing the most rank best
+func trigramQuery(query string) string {
+	var trigrams []string
+	for _, word := range fuzzyWords(query) {
+		runes := []rune(word)
+		for i := 0; i+3 <= len(runes); i++ {
+			trigrams = append(trigrams, `"`+strings.ReplaceAll(string(runes[i:i+3]), `"`, `""`)+`"`)
+		}
+	}
+	return strings.Join(trigrams, " OR ")
+}
+
+// mysqlBooleanQuery renders a boolean query for MATCH ... IN BOOLEAN MODE
+func mysqlBooleanQuery(q search.BooleanQuery) string {
+	terms := make([]string, len(q.Terms))
+	for i, t := range q.Terms {
+		switch {
+		case t.Required:
+			terms[i] = "+"
+		case t.Excluded:
+			terms[i] = "-"
+		}
+		switch {
+		case t.Phrase():
+			terms[i] += `"` + strings.Join(t.Words, " ") + `"`
+		case t.Prefix:
+			terms[i] += t.Words[0] + "*"
+		default:
+			terms[i] += t.Words[0]
+		}
+	}
+	return strings.Join(terms, " ")
+}
+
+// tsQuery renders a boolean query for to_tsquery: every required term and none of
+// the excluded ones, plus any of the optional terms when none is required. Words
+// are folded like the unaccented search_vector.
+func tsQuery(q search.BooleanQuery) string {
+	term := func(t search.BooleanTerm) string {
+		switch {
+		case t.Phrase():
+			return "(" + utils.Fold(strings.Join(t.Words, " <-> ")) + ")"
+		case t.Prefix:
+			return utils.Fold(t.Words[0]) + ":*"
+		default:
+			return utils.Fold(t.Words[0])
+		}
+	}
+
+	var parts []string
+	for _, t := range q.Filter(true, false) {
+		parts = append(parts, term(t))
+	}
+	if len(parts) == 0 {
+		var any []string
+		for _, t := range q.Filter(false, false) {
+			any = append(any, term(t))
+		}
+		parts = append(parts, "("+strings.Join(any, " | ")+")")
+	}
+	for _, t := range q.Filter(false, true) {
+		parts = append(parts, "!"+term(t))
+	}
+	return strings.Join(parts, " & ")
+}
+
+// ftsBooleanQuery renders a boolean query for FTS5 with the same semantics as tsQuery
+func ftsBooleanQuery(q search.BooleanQuery) string {
+	term := func(t search.BooleanTerm) string {
+		if t.Prefix {
+			return `"` + t.Words[0] + `"*`
+		}
+		return `"` + strings.Join(t.Words, " ") + `"`
+	}
+	join := func(terms []search.BooleanTerm, op string) string {
+		rendered := make([]string, len(terms))
+		for i, t := range terms {
+			rendered[i] = term(t)
+		}
+		return "(" + strings.Join(rendered, " "+op+" ") + ")"
+	}
+
+	expr := join(q.Filter(false, false), "OR")
+	if required := q.Filter(true, false); len(required) > 0 {
+		expr = join(required, "AND")
+	}
+	if excluded := q.Filter(false, true); len(excluded) > 0 {
+		expr += " NOT " + join(excluded, "OR")
+	}
+	return expr
+}
+
+// folded wraps column with the dialect's fold, when it has one
+func (d *dialect) folded(column string) string {
+	if d.fold == nil {
+		return column
+	}
+	return d.fold(column)
+}
+
+// search returns query rewritten for the dialect's search predicates
+func (d *dialect) search(query string) string {
+	if d.searchTerm == nil {
+		return query
+	}
+	return d.searchTerm(query)
+}
+
+// rebind rewrites a query's ? placeholders into the driver's bind syntax
+func (d *dialect) rebind(query string) string {
+	if !d.numbered {
+		return query
+	}
+
+	var b strings.Builder
+	n, quoted := 0, false
+	for _, r := range query {
+		switch {
+		case r == '\'':
+			quoted = !quoted
+		case r == '?' && !quoted:
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// bindArgs converts a query's arguments into the values the driver should store
+func (d *dialect) bindArgs(args []interface{}) []interface{} {
+	if d.timeLayout == "" {
+		return args
+	}
+
+	// Copy before converting, since callers may reuse their argument slices
+	var bound []interface{}
+	for i, arg := range args {
+		if t, ok := arg.(time.Time); ok {
+			if bound == nil {
+				bound = append([]interface{}(nil), args...)
+			}
+			bound[i] = t.UTC().Format(d.timeLayout)
+		}
+	}
+	if bound == nil {
+		return args
+	}
+	return bound
+}
+
+// conn is a connection pool that runs queries written with ? placeholders on any supported driver
+type conn struct {
+	db      *sql.DB
+	dialect *dialect
+
+	// retry reruns single statements that fail with a transient error
+	retry retryPolicy
+
+	// breaker fails calls fast while the database is down; nil disables it
+	breaker *breaker
+
+	// stmts holds the statements read queries are prepared as; nil runs them unprepared
+	stmts *stmtCache
+
+	// slow logs statements that run for too long, retries included
+	slow slowLog
+}
+
+// ExecContext implements querier. Writes are only retried on lock conflicts:
+// after a dropped connection the statement may already have been applied.
+func (c *conn) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	if err := c.breaker.allow(); err != nil {
+		return nil, err
+	}
+	query, args = c.dialect.rebind(query), c.dialect.bindArgs(args)
+	defer c.slow.observe(ctx, query, args, time.Now())
+
+	var res sql.Result
+	err := c.retry.do(ctx, isLockConflict, func() error {
+		var err error
+		res, err = c.db.ExecContext(ctx, query, args...)
+		return err
+	})
+	c.breaker.record(ctx, err)
+	return res, err
+}
+
+// QueryContext implements querier
+func (c *conn) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	if err := c.breaker.allow(); err != nil {
+		return nil, err
+	}
+	query, args = c.dialect.rebind(query), c.dialect.bindArgs(args)
+	defer c.slow.observe(ctx, query, args, time.Now())
+
+	var rows *sql.Rows
+	err := c.retry.do(ctx, isTransient, func() error {
+		stmt, release, err := c.stmts.get(ctx, query)
+		if err != nil {
+			return err
+		}
+		defer release()
+		if stmt != nil {
+			rows, err = stmt.QueryContext(ctx, args...)
+		} else {
+			rows, err = c.db.QueryContext(ctx, query, args...)
+		}
+		return err
+	})
+	c.breaker.record(ctx, err)
+	return rows, err
+}
+
+// QueryRowContext implements querier. Only errors raised before Scan are
+// retried; sql.ErrNoRows is not an error here.
+func (c *conn) QueryRowContext(ctx context.Context, query string, args ...interface{}) rowScanner {
+	if err := c.breaker.allow(); err != nil {
+		return errRow{err: err}
+	}
+	query, args = c.dialect.rebind(query), c.dialect.bindArgs(args)
+	defer c.slow.observe(ctx, query, args, time.Now())
+
+	var row rowScanner
+	err := c.retry.do(ctx, isTransient, func() error {
+		stmt, release, err := c.stmts.get(ctx, query)
+		defer release()
+		switch {
+		case err != nil:
+			row = errRow{err: err}
+		case stmt != nil:
+			row = stmt.QueryRowContext(ctx, args...)
+		default:
+			row = c.db.QueryRowContext(ctx, query, args...)
+		}
+		return row.Err()
+	})
+	c.breaker.record(ctx, err)
+	return row
+}
+
+// BeginTx starts a transaction that rebinds placeholders like c
+func (c *conn) BeginTx(ctx context.Context, opts *sql.TxOptions) (*txn, error) {
+	if err := c.breaker.allow(); err != nil {
+		return nil, err
+	}
+	t, err := c.db.BeginTx(ctx, opts)
+	c.breaker.record(ctx, err)
+	if err != nil {
+		return nil, err
+	}
+	return &txn{tx: t, dialect: c.dialect, slow: c.slow}, nil
+}
+
+// txn is a transaction that runs queries written with ? placeholders
+type txn struct {
+	tx      *sql.Tx
+	dialect *dialect
+	slow    slowLog
+}
+
+// ExecContext implements querier
+func (t *txn) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	query, args = t.dialect.rebind(query), t.dialect.bindArgs(args)
+	defer t.slow.observe(ctx, query, args, time.Now())
+	return t.tx.ExecContext(ctx, query, args...)
+}
+
+// QueryContext implements querier
+func (t *txn) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	query, args = t.dialect.rebind(query), t.dialect.bindArgs(args)
+	defer t.slow.observe(ctx, query, args, time.Now())
+	return t.tx.QueryContext(ctx, query, args...)
+}
+
+// QueryRowContext implements querier
+func (t *txn) QueryRowContext(ctx context.Context, query string, args ...interface{}) rowScanner {
+	query, args = t.dialect.rebind(query), t.dialect.bindArgs(args)
+	defer t.slow.observe(ctx, query, args, time.Now())
+	return t.tx.QueryRowContext(ctx, query, args...)
+}
+
+// Commit commits the transaction
+func (t *txn) Commit() error {
+	return t.tx.Commit()
+}
+
+// Rollback aborts the transaction
+func (t *txn) Rollback() error {
+	return t.tx.Rollback()
+}
@@ -0,0 +1,161 @@
+package database
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"database/sql/driver"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// encryptedPrefix marks a column value written by EncryptField
+const encryptedPrefix = "enc:v1:"
+
+var (
+	// ErrUnknownEncryptionKey is returned when a value was encrypted with a key that is no longer configured
+	ErrUnknownEncryptionKey = errors.New("value encrypted with unknown key")
+
+	fieldKeysMu      sync.RWMutex
+	fieldKeys        map[string]cipher.AEAD
+	activeFieldKeyID string
+)
+
+// SetFieldEncryptionKeys configures the keys used for field-level encryption.
+// spec is a comma-separated list of "<id>:<base64 32-byte key>"; the first key
+// encrypts new values and all keys can decrypt, which allows rotation.
+// An empty spec disables encryption and values are stored as plaintext.
+func SetFieldEncryptionKeys(spec string) error {
+	keys := make(map[string]cipher.AEAD)
+	active := ""
+
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		id, encoded, ok := strings.Cut(entry, ":")
+		if !ok || id == "" {
+			return fmt.Errorf("encryption key entry must be <id>:<base64 key>")
+		}
+		raw, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return fmt.Errorf("encryption key %s: %w", id, err)
+		}
+		if len(raw) != 32 {
+			return fmt.Errorf("encryption key %s must be 32 bytes, got %d", id, len(raw))
+		}
+
+		block, err := aes.NewCipher(raw)
+		if err != nil {
+			return err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return err
+		}
+
+		keys[id] = aead
+		if active == "" {
+			active = id
+		}
+	}
+
+	fieldKeysMu.Lock()
+	defer fieldKeysMu.Unlock()
+	fieldKeys = keys
+	activeFieldKeyID = active
+	return nil
+}
+
+// EncryptField encrypts a value with the active key using AES-256-GCM.
+// It returns the value unchanged when encryption is disabled.
+func EncryptField(plaintext string) (string, error) {
+	fieldKeysMu.RLock()
+	defer fieldKeysMu.RUnlock()
+
+	if activeFieldKeyID == "" {
+		return plaintext, nil
+	}
+	aead := fieldKeys[activeFieldKeyID]
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(plaintext), []byte(activeFieldKeyID))
+
+	return encryptedPrefix + activeFieldKeyID + ":" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// DecryptField reverses EncryptField. Values without the encrypted prefix are
+// returned unchanged so columns can be migrated to encryption incrementally.
+func DecryptField(stored string) (string, error) {
+	if !strings.HasPrefix(stored, encryptedPrefix) {
+		return stored, nil
+	}
+
+	id, encoded, ok := strings.Cut(strings.TrimPrefix(stored, encryptedPrefix), ":")
+	if !ok {
+		return "", errors.New("malformed encrypted value")
+	}
+
+	fieldKeysMu.RLock()
+	aead, found := fieldKeys[id]
+	fieldKeysMu.RUnlock()
+	if !found {
+		return "", ErrUnknownEncryptionKey
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return "", errors.New("malformed encrypted value")
+	}
+
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, []byte(id))
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// EncryptedString is a column type that is transparently encrypted on write
+// and decrypted on read, e.g. `DB.Exec("... VALUES (?)", database.EncryptedString(v))`
+// and `rows.Scan(&es)`.
+type EncryptedString string
+
+// Value implements driver.Valuer
+func (s EncryptedString) Value() (driver.Value, error) {
+	return EncryptField(string(s))
+}
+
+// Scan implements sql.Scanner
+func (s *EncryptedString) Scan(src interface{}) error {
+	var stored string
+	switch v := src.(type) {
+	case nil:
+		*s = ""
+		return nil
+	case string:
+		stored = v
+	case []byte:
+		stored = string(v)
+	default:
+		return fmt.Errorf("cannot scan %T into EncryptedString", src)
+	}
+
+	plaintext, err := DecryptField(stored)
+	if err != nil {
+		return err
+	}
+	*s = EncryptedString(plaintext)
+	return nil
+}
@@ -0,0 +1,96 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/yashjain/konnect/internal/filter"
+	"github.com/yashjain/konnect/pkg/types"
+	"github.com/yashjain/konnect/pkg/utils"
+)
+
+// serviceFilterColumns map the fields of filter.ServiceFields to services columns
+var serviceFilterColumns = map[string]string{
+	"name":           "name",
+	"slug":           "slug",
+	"description":    "description",
+	"visibility":     "visibility",
+	"versions_count": versionsCount,
+	"created_at":     "created_at",
+	"updated_at":     "updated_at",
+	"latest_status":  latestVersionStatus,
+	"spec_score":     serviceSpecScore,
+}
+
+// versionFilterColumns map the fields of filter.VersionFields to versions columns, aliased v
+var versionFilterColumns = map[string]string{
+	"semver":        "v.semver",
+	"status":        "v.status",
+	"changelog":     "v.changelog",
+	"created_at":    "v.created_at",
+	"deprecated_at": "v.deprecated_at",
+	"released_at":   "v.released_at",
+	"sunset_at":     "v.sunset_at",
+	"spec_score":    versionSpecScore,
+}
+
+// foldedFilterFields are the free-text fields whose comparisons ignore case and
+// accents, so that name==cafe-api finds "Café-API"
+var foldedFilterFields = map[string]bool{
+	"name":        true,
+	"slug":        true,
+	"description": true,
+	"changelog":   true,
+}
+
+// filterClause turns filter conditions into SQL conditions on columns, each
+// prefixed with AND, and their arguments. Only fields in columns can be filtered
+// on, and values are always bound, never spliced into the SQL. Comparisons on
+// foldedFilterFields ignore case and accents.
+func (d *dialect) filterClause(conditions []types.FilterCondition, columns map[string]string) (string, []interface{}, error) {
+	var b strings.Builder
+	var args []interface{}
+	for _, c := range conditions {
+		column, ok := columns[c.Field]
+		if !ok || len(c.Values) == 0 {
+			return "", nil, fmt.Errorf("%w: unknown field %q", filter.ErrInvalidFilter, c.Field)
+		}
+		if foldedFilterFields[c.Field] {
+			column = d.folded(column)
+			c.Values = foldValues(c.Values)
+		}
+
+		switch c.Operator {
+		case filter.OpEqual:
+			b.WriteString(" AND " + column + " = ?")
+		case filter.OpNotEqual:
+			b.WriteString(" AND " + column + " <> ?")
+		case filter.OpGreater, filter.OpGreaterEqual, filter.OpLess, filter.OpLessEqual:
+			b.WriteString(" AND " + column + " " + c.Operator + " ?")
+		case filter.OpLike:
+			b.WriteString(" AND " + column + " " + d.like + " ? ESCAPE '" + likeEscape + "'")
+			args = append(args, "%"+escapeLike(fmt.Sprint(c.Values[0]))+"%")
+			continue
+		case filter.OpIn:
+			b.WriteString(" AND " + column + " IN (?" + strings.Repeat(", ?", len(c.Values)-1) + ")")
+			args = append(args, c.Values...)
+			continue
+		default:
+			return "", nil, fmt.Errorf("%w: unknown operator %q", filter.ErrInvalidFilter, c.Operator)
+		}
+		args = append(args, c.Values[0])
+	}
+	return b.String(), args, nil
+}
+
+// foldValues returns values with strings folded by utils.Fold
+func foldValues(values []interface{}) []interface{} {
+	folded := make([]interface{}, len(values))
+	for i, v := range values {
+		if str, ok := v.(string); ok {
+			v = utils.Fold(str)
+		}
+		folded[i] = v
+	}
+	return folded
+}
@@ -0,0 +1,110 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"strings"
+
+	"github.com/yashjain/konnect/internal/models"
+)
+
+// githubRepositoryColumns are the github_repositories columns read by scanGitHubRepository
+const githubRepositoryColumns = "service_id, repository, org_id, created_at"
+
+// SetGitHubRepository links a repository to a service within an organization,
+// replacing the repository it was linked to, with the secret signing its
+// events. It returns sql.ErrNoRows when the service is not in the
+// organization, and repository.ErrConflict when the repository is linked to
+// another service of the organization.
+func (s *Store) SetGitHubRepository(ctx context.Context, link *models.GitHubRepository) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	link.Repository = strings.ToLower(link.Repository)
+	link.CreatedAt = timestamp()
+	err := s.withTx(ctx, func(tx *txn) error {
+		if _, err := tenantExec(ctx, tx, link.OrgID, "DELETE FROM github_repositories WHERE service_id = ? AND {{tenant}}", link.ServiceID); err != nil {
+			return err
+		}
+		result, err := tenantExec(ctx, tx, link.OrgID, `
+			INSERT INTO github_repositories (repository, service_id, org_id, secret, created_at)
+			SELECT ?, id, org_id, ?, ? FROM services WHERE id = ? AND {{tenant}} AND deleted_at IS NULL`,
+			link.Repository, EncryptedString(link.Secret), link.CreatedAt, link.ServiceID)
+		if err != nil {
+			return err
+		}
+		if n, err := result.RowsAffected(); err != nil || n == 0 {
+			if err == nil {
+				err = sql.ErrNoRows
+			}
+			return err
+		}
+		return nil
+	})
+	return conflictError(err)
+}
+
+// GetGitHubRepository returns the repository linked to a service within an
+// organization, without its secret, or sql.ErrNoRows when there is none
+func (s *Store) GetGitHubRepository(ctx context.Context, orgID, serviceID string) (*models.GitHubRepository, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	return scanGitHubRepository(tenantQueryRow(ctx, s.db, orgID, "SELECT "+githubRepositoryColumns+" FROM github_repositories WHERE service_id = ? AND {{tenant}}", serviceID))
+}
+
+// DeleteGitHubRepository unlinks the repository of a service within an organization
+func (s *Store) DeleteGitHubRepository(ctx context.Context, orgID, serviceID string) (int64, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	result, err := tenantExec(ctx, s.db, orgID, "DELETE FROM github_repositories WHERE service_id = ? AND {{tenant}}", serviceID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// FindGitHubRepositories returns the links of a repository, given as owner/name
+// in any case, with their secrets. Organizations link repositories
+// independently, so one repository may have a link in each.
+// tenant:exempt GitHub events name a repository, not an organization; only the
+// link whose secret signed an event may act on it.
+func (s *Store) FindGitHubRepositories(ctx context.Context, repository string) ([]models.GitHubRepository, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, "SELECT "+githubRepositoryColumns+", secret FROM github_repositories WHERE repository = ?", strings.ToLower(repository))
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.WarnContext(ctx, "Error closing rows", "error", err)
+		}
+	}()
+
+	links := []models.GitHubRepository{}
+	for rows.Next() {
+		var secret EncryptedString
+		link, err := scanGitHubRepository(rows, &secret)
+		if err != nil {
+			return nil, err
+		}
+		link.Secret = string(secret)
+		links = append(links, *link)
+	}
+	return links, rows.Err()
+}
+
+// scanGitHubRepository scans the githubRepositoryColumns of a row, followed by extra
+func scanGitHubRepository(row rowScanner, extra ...interface{}) (*models.GitHubRepository, error) {
+	var link models.GitHubRepository
+	dest := append([]interface{}{&link.ServiceID, &link.Repository, &link.OrgID, &link.CreatedAt}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+	link.CreatedAt = link.CreatedAt.UTC()
+	return &link, nil
+}
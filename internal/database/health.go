@@ -0,0 +1,91 @@
+package database
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/yashjain/konnect/internal/metrics"
+)
+
+// maxIdleConns is database/sql's default idle pool size, restored after idle
+// connections are dropped
+const maxIdleConns = 2
+
+// health is the outcome of the most recent connectivity check
+type health struct {
+	mu  sync.Mutex
+	err error
+}
+
+// Health returns the error from the most recent check of the primary, or nil
+// while it is reachable
+func (s *Store) Health() error {
+	s.health.mu.Lock()
+	defer s.health.mu.Unlock()
+	return s.health.err
+}
+
+// Ping checks the primary is reachable now, without waiting for the next check
+func (s *Store) Ping(ctx context.Context) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	return s.db.db.PingContext(ctx)
+}
+
+// Supervise pings the primary every interval until ctx is done. While the
+// database is unreachable Health reports the failure and idle connections are
+// dropped, so connections to a server that went away are not handed out once
+// it is back. A zero interval disables supervision.
+func (s *Store) Supervise(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.check(ctx)
+		}
+	}
+}
+
+// check pings the primary once and records the result
+func (s *Store) check(ctx context.Context) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	err := s.db.db.PingContext(ctx)
+	if ctx.Err() == context.Canceled {
+		return
+	}
+
+	s.health.mu.Lock()
+	wasDown := s.health.err != nil
+	s.health.err = err
+	s.health.mu.Unlock()
+
+	if err != nil {
+		if !wasDown {
+			slog.Error("Database unreachable", "error", err)
+		}
+		metrics.DBUp.Set(0)
+		s.db.db.SetMaxIdleConns(0)
+		s.db.db.SetMaxIdleConns(maxIdleConns)
+		return
+	}
+
+	if wasDown {
+		slog.Info("Database reachable again")
+	}
+	metrics.DBUp.Set(1)
+
+	// A successful ping lets queries through without waiting out the breaker's cooldown
+	s.db.breaker.record(ctx, nil)
+}
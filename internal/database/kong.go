@@ -0,0 +1,141 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"time"
+
+	"github.com/yashjain/konnect/internal/models"
+)
+
+// kongSyncColumns are the kong_syncs columns read by scanKongSync
+const kongSyncColumns = "service_id, org_id, status, revision, attempts, last_error, next_attempt_at, synced_at, updated_at"
+
+// queueKongSync marks a service within an organization to be synced to Kong
+// now, when syncing is enabled, inside the transaction changing it
+func (s *Store) queueKongSync(ctx context.Context, q querier, orgID, serviceID string) error {
+	if !s.kongSync {
+		return nil
+	}
+	_, err := s.enqueueKongSync(ctx, q, orgID, serviceID)
+	return err
+}
+
+// enqueueKongSync creates or resets the sync of a service within an
+// organization, due now, returning the number of syncs queued: 0 when the
+// service is not in the organization
+func (s *Store) enqueueKongSync(ctx context.Context, q querier, orgID, serviceID string) (int64, error) {
+	now := timestamp()
+	_, err := tenantExec(ctx, q, orgID, s.db.dialect.insertIgnore+` kong_syncs (service_id, org_id, status, next_attempt_at, updated_at)
+		SELECT id, org_id, ?, ?, ? FROM services WHERE id = ? AND {{tenant}}`+s.db.dialect.onConflictIgnore,
+		models.KongSyncPending, now, now, serviceID)
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := tenantExec(ctx, q, orgID, `
+		UPDATE kong_syncs SET status = ?, revision = revision + 1, attempts = 0, last_error = NULL, next_attempt_at = ?, updated_at = ?
+		WHERE service_id = ? AND {{tenant}}`,
+		models.KongSyncPending, now, now, serviceID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// QueueKongSync marks a service within an organization to be synced to Kong
+// now, whether or not syncing is enabled. It returns sql.ErrNoRows when the
+// service is not in the organization.
+func (s *Store) QueueKongSync(ctx context.Context, orgID, serviceID string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	return s.withTx(ctx, func(tx *txn) error {
+		queued, err := s.enqueueKongSync(ctx, tx, orgID, serviceID)
+		if err == nil && queued == 0 {
+			err = sql.ErrNoRows
+		}
+		return err
+	})
+}
+
+// GetKongSync returns the sync of a service within an organization, or
+// sql.ErrNoRows when it was never queued
+func (s *Store) GetKongSync(ctx context.Context, orgID, serviceID string) (*models.KongSync, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	return scanKongSync(tenantQueryRow(ctx, s.db, orgID, "SELECT "+kongSyncColumns+" FROM kong_syncs WHERE service_id = ? AND {{tenant}}", serviceID))
+}
+
+// GetDueKongSyncs returns up to limit pending syncs that are due at now, oldest first.
+// tenant:exempt the syncer syncs the services of every organization.
+func (s *Store) GetDueKongSyncs(ctx context.Context, now time.Time, limit int) ([]models.KongSync, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, "SELECT "+kongSyncColumns+" FROM kong_syncs WHERE status = ? AND next_attempt_at <= ? ORDER BY next_attempt_at, service_id LIMIT ?",
+		models.KongSyncPending, now, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.WarnContext(ctx, "Error closing rows", "error", err)
+		}
+	}()
+
+	var syncs []models.KongSync
+	for rows.Next() {
+		sync, err := scanKongSync(rows)
+		if err != nil {
+			return nil, err
+		}
+		syncs = append(syncs, *sync)
+	}
+	return syncs, rows.Err()
+}
+
+// UpdateKongSync records the outcome of an attempt to sync a service, unless
+// the sync was queued again since the attempt read it, returning whether it was
+// recorded.
+// tenant:exempt syncs are addressed by their service's globally unique ID.
+func (s *Store) UpdateKongSync(ctx context.Context, sync *models.KongSync) (bool, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	sync.UpdatedAt = timestamp()
+	result, err := s.db.ExecContext(ctx, `
+		UPDATE kong_syncs SET status = ?, attempts = ?, last_error = ?, next_attempt_at = ?, synced_at = ?, updated_at = ?
+		WHERE service_id = ? AND revision = ?`,
+		sync.Status, sync.Attempts, nullString(sync.LastError), nullTime(sync.NextAttemptAt), nullTime(sync.SyncedAt), sync.UpdatedAt,
+		sync.ServiceID, sync.Revision)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// scanKongSync scans the kongSyncColumns of a row
+func scanKongSync(row rowScanner) (*models.KongSync, error) {
+	var sync models.KongSync
+	var lastError sql.NullString
+	var nextAttemptAt, syncedAt sql.NullTime
+	if err := row.Scan(&sync.ServiceID, &sync.OrgID, &sync.Status, &sync.Revision, &sync.Attempts, &lastError, &nextAttemptAt, &syncedAt, &sync.UpdatedAt); err != nil {
+		return nil, err
+	}
+
+	sync.LastError = lastError.String
+	if nextAttemptAt.Valid {
+		t := nextAttemptAt.Time.UTC()
+		sync.NextAttemptAt = &t
+	}
+	if syncedAt.Valid {
+		t := syncedAt.Time.UTC()
+		sync.SyncedAt = &t
+	}
+	sync.UpdatedAt = sync.UpdatedAt.UTC()
+	return &sync, nil
+}
@@ -0,0 +1,89 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/yashjain/konnect/internal/models"
+)
+
+// ReindexServices rebuilds the services table and its full-text index.
+// tenant:exempt admin maintenance runs across all organizations.
+func (s *Store) ReindexServices(ctx context.Context) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, s.db.dialect.reindex)
+	if err != nil {
+		return err
+	}
+	return rows.Close()
+}
+
+// ListAllServices returns up to limit services that are not deleted, ordered by
+// ID, starting after afterID; it pages through every service to rebuild an
+// external search index.
+// tenant:exempt admin maintenance runs across all organizations.
+func (s *Store) ListAllServices(ctx context.Context, afterID string, limit int) ([]models.Service, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.read.QueryContext(ctx, "SELECT "+s.db.dialect.serviceColumns()+" FROM services WHERE id > ? AND deleted_at IS NULL ORDER BY id LIMIT ?", afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanServices(rows)
+}
+
+// archivableVersions matches versions soft-deleted before a cutoff, together with
+// every version of a service soft-deleted before it or removed altogether. It
+// takes the cutoff twice.
+const archivableVersions = "deleted_at < ? OR service_id NOT IN (SELECT id FROM services WHERE deleted_at IS NULL OR deleted_at >= ?)"
+
+// ArchiveVersions moves the versions deleted before a cutoff, directly or with
+// their service, from versions into versions_archive and reports how many moved
+// and from which services.
+// tenant:exempt admin maintenance runs across all organizations.
+func (s *Store) ArchiveVersions(ctx context.Context, before time.Time) (models.ArchiveResult, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var archived models.ArchiveResult
+	err := s.withTx(ctx, func(tx *txn) error {
+		archived = models.ArchiveResult{Services: make(map[string]string)}
+		rows, err := tx.QueryContext(ctx, `
+			SELECT a.service_id, COALESCE(s.org_id, '')
+			FROM (SELECT DISTINCT service_id FROM versions WHERE `+archivableVersions+`) a
+			LEFT JOIN services s ON s.id = a.service_id`, before, before)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var serviceID, orgID string
+			if err := rows.Scan(&serviceID, &orgID); err != nil {
+				return err
+			}
+			archived.Services[serviceID] = orgID
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO versions_archive (id, service_id, semver, status, changelog, metadata, created_at, deleted_at, deprecated_at, sunset_at, released_at, archived_at)
+			SELECT id, service_id, semver, status, changelog, metadata, created_at, deleted_at, deprecated_at, sunset_at, released_at, ?
+			FROM versions WHERE `+archivableVersions, timestamp(), before, before)
+		if err != nil {
+			return err
+		}
+
+		result, err := tx.ExecContext(ctx, "DELETE FROM versions WHERE "+archivableVersions, before, before)
+		if err != nil {
+			return err
+		}
+		archived.Versions, err = result.RowsAffected()
+		return err
+	})
+	return archived, err
+}
@@ -0,0 +1,62 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"sort"
+	"strings"
+)
+
+// encodeMetadata binds metadata as a JSON object, or NULL when it is nil so that
+// updates can leave the current metadata unchanged with COALESCE
+func encodeMetadata(metadata map[string]string) (interface{}, error) {
+	if metadata == nil {
+		return nil, nil
+	}
+	encoded, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, err
+	}
+	return string(encoded), nil
+}
+
+// decodeMetadata reads a JSON metadata column, an empty map when it is NULL
+func decodeMetadata(raw sql.NullString) (map[string]string, error) {
+	metadata := map[string]string{}
+	if !raw.Valid || raw.String == "" {
+		return metadata, nil
+	}
+	if err := json.Unmarshal([]byte(raw.String), &metadata); err != nil {
+		return nil, err
+	}
+	return metadata, nil
+}
+
+// metadataFilter matches rows whose metadata column has every value of f,
+// together with its arguments. Keys are sorted so that the same filter always
+// makes the same query.
+func (d *dialect) metadataFilter(f map[string]string, column string) (string, []interface{}) {
+	keys := make([]string, 0, len(f))
+	for key := range f {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	args := make([]interface{}, 0, 2*len(keys))
+	for _, key := range keys {
+		b.WriteString(" AND " + d.metadataValue(column) + " = ?")
+		if d.metadataKey != nil {
+			args = append(args, d.metadataKey(key), f[key])
+		} else {
+			args = append(args, key, f[key])
+		}
+	}
+	return b.String(), args
+}
+
+// jsonPath is the JSON path of a top-level key, quoted since keys may contain
+// hyphens. Keys are validated to hold no quotes.
+func jsonPath(key string) string {
+	return `$."` + key + `"`
+}
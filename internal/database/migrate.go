@@ -0,0 +1,41 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"io/fs"
+
+	"github.com/pressly/goose/v3"
+
+	"github.com/yashjain/konnect/migrations"
+)
+
+// Migrate applies any pending embedded migrations for the store's driver,
+// creating the schema and demo data in an empty database. It is meant for dev
+// mode; production MySQL and Postgres schemas are migrated with the goose CLI.
+// SQLite databases are always migrated when opened.
+func (s *Store) Migrate(ctx context.Context) error {
+	switch s.db.dialect {
+	case mysqlDialect:
+		return migrate(ctx, s.db.db, goose.DialectMySQL, migrations.MySQL, ".")
+	case postgresDialect:
+		return migrate(ctx, s.db.db, goose.DialectPostgres, migrations.Postgres, "postgres")
+	default:
+		return migrate(ctx, s.db.db, goose.DialectSQLite3, migrations.SQLite, "sqlite")
+	}
+}
+
+// migrate applies the pending migrations found in dir of fsys
+func migrate(ctx context.Context, db *sql.DB, dialect goose.Dialect, fsys fs.FS, dir string) error {
+	sub, err := fs.Sub(fsys, dir)
+	if err != nil {
+		return err
+	}
+
+	provider, err := goose.NewProvider(dialect, db, sub)
+	if err != nil {
+		return err
+	}
+	_, err = provider.Up(ctx)
+	return err
+}
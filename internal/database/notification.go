@@ -0,0 +1,243 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/yashjain/konnect/internal/models"
+)
+
+// emailNotificationColumns are the email_notifications columns read by scanEmailNotification
+const emailNotificationColumns = "n.id, n.org_id, n.user_id, n.event_type, n.service_id, n.version_id, n.status, n.attempts, n.last_error, n.next_attempt_at, n.sent_at, n.created_at"
+
+// queueDeprecationEmails queues an email about a deprecated version to the
+// owners of its service, the users granted write on it directly or through a
+// team, the members of the teams consuming the service or the version, and to
+// its subscribers, unless they turned deprecation emails off.
+// Nothing is queued unless email notifications are enabled.
+func (s *Store) queueDeprecationEmails(ctx context.Context, tx *txn, orgID string, version models.Version) error {
+	return s.queueVersionEmails(ctx, tx, orgID, models.EventVersionDeprecated, version)
+}
+
+// queueSunsetReminders queues an email reminding the recipients of a
+// deprecated version's deprecation emails that its sunset date approaches
+func (s *Store) queueSunsetReminders(ctx context.Context, tx *txn, orgID string, version models.Version) error {
+	return s.queueVersionEmails(ctx, tx, orgID, models.NotificationSunsetReminder, version)
+}
+
+// queueVersionEmails queues an email of eventType about a version to the
+// recipients of queueDeprecationEmails
+func (s *Store) queueVersionEmails(ctx context.Context, tx *txn, orgID, eventType string, version models.Version) error {
+	if !s.notifications {
+		return nil
+	}
+
+	rows, err := tenantQuery(ctx, tx, orgID, `
+		SELECT u.id FROM users u
+		LEFT JOIN notification_preferences p ON p.user_id = u.id
+		WHERE {{tenant:u}} AND COALESCE(p.deprecations, ?) = ? AND (
+			u.id IN (SELECT subject_id FROM service_acls WHERE service_id = ? AND subject_type = ? AND permission = ?)
+			OR u.id IN (SELECT m.user_id FROM team_members m JOIN service_acls a ON a.subject_id = m.team_id
+				WHERE a.service_id = ? AND a.subject_type = ? AND a.permission = ?)
+			OR u.id IN (SELECT m.user_id FROM team_members m JOIN consumers c ON c.team_id = m.team_id
+				WHERE c.service_id = ? AND (c.version_id IS NULL OR c.version_id = ?))
+			OR u.id IN (SELECT user_id FROM service_subscribers WHERE service_id = ?))
+		ORDER BY u.id`,
+		true, true,
+		version.ServiceID, models.SubjectUser, models.PermissionWrite,
+		version.ServiceID, models.SubjectTeam, models.PermissionWrite,
+		version.ServiceID, version.ID,
+		version.ServiceID)
+	if err != nil {
+		return err
+	}
+	var userIDs []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			_ = rows.Close()
+			return err
+		}
+		userIDs = append(userIDs, id)
+	}
+	if err := rows.Close(); err != nil {
+		return err
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	now := timestamp()
+	for _, userID := range userIDs {
+		_, err := tenantExec(ctx, tx, orgID, `
+			INSERT INTO email_notifications (id, org_id, user_id, event_type, service_id, version_id, status, next_attempt_at, created_at)
+			VALUES (?, {{tenant_id}}, ?, ?, ?, ?, ?, ?, ?)`,
+			uuid.New().String(), userID, eventType, version.ServiceID, version.ID, models.NotificationPending, now, now)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetNotificationPreferences returns a user's notification preferences within
+// an organization, every notification being on for users who never set them
+func (s *Store) GetNotificationPreferences(ctx context.Context, orgID, userID string) (*models.NotificationPreferences, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	prefs := models.NotificationPreferences{UserID: userID, OrgID: orgID}
+	var deprecations bool
+	err := tenantQueryRow(ctx, s.db, orgID, "SELECT deprecations, updated_at FROM notification_preferences WHERE user_id = ? AND {{tenant}}", userID).
+		Scan(&deprecations, &prefs.UpdatedAt)
+	if err == sql.ErrNoRows {
+		deprecations = true
+		err = nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	prefs.Deprecations = &deprecations
+	prefs.UpdatedAt = prefs.UpdatedAt.UTC()
+	return &prefs, nil
+}
+
+// SetNotificationPreferences replaces a user's notification preferences. It
+// returns sql.ErrNoRows when the user is not in the organization.
+func (s *Store) SetNotificationPreferences(ctx context.Context, prefs *models.NotificationPreferences) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	prefs.UpdatedAt = timestamp()
+	return s.withTx(ctx, func(tx *txn) error {
+		if _, err := tenantExec(ctx, tx, prefs.OrgID, "DELETE FROM notification_preferences WHERE user_id = ? AND {{tenant}}", prefs.UserID); err != nil {
+			return err
+		}
+		result, err := tenantExec(ctx, tx, prefs.OrgID, `
+			INSERT INTO notification_preferences (user_id, org_id, deprecations, updated_at)
+			SELECT id, org_id, ?, ? FROM users WHERE id = ? AND {{tenant}}`,
+			prefs.Deprecations != nil && *prefs.Deprecations, prefs.UpdatedAt, prefs.UserID)
+		if err != nil {
+			return err
+		}
+		if n, err := result.RowsAffected(); err != nil || n == 0 {
+			if err == nil {
+				err = sql.ErrNoRows
+			}
+			return err
+		}
+		return nil
+	})
+}
+
+// SubscribeToService subscribes a user to the notifications of a service within
+// an organization; subscribing again is a no-op. It returns sql.ErrNoRows when
+// the service or user is not in the organization.
+func (s *Store) SubscribeToService(ctx context.Context, orgID, serviceID, userID string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	return s.withTx(ctx, func(tx *txn) error {
+		var id string
+		err := tenantQueryRow(ctx, tx, orgID, `
+			SELECT s.id FROM services s JOIN users u ON u.org_id = s.org_id
+			WHERE s.id = ? AND u.id = ? AND {{tenant:s}} AND s.deleted_at IS NULL`, serviceID, userID).Scan(&id)
+		if err != nil {
+			return err
+		}
+		_, err = tenantExec(ctx, tx, orgID, s.db.dialect.insertIgnore+` service_subscribers (service_id, user_id, org_id, created_at)
+			VALUES (?, ?, {{tenant_id}}, ?)`+s.db.dialect.onConflictIgnore,
+			serviceID, userID, timestamp())
+		return err
+	})
+}
+
+// UnsubscribeFromService removes a user's subscription to a service within an organization
+func (s *Store) UnsubscribeFromService(ctx context.Context, orgID, serviceID, userID string) (int64, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	result, err := tenantExec(ctx, s.db, orgID, "DELETE FROM service_subscribers WHERE service_id = ? AND user_id = ? AND {{tenant}}", serviceID, userID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// GetDueEmailNotifications returns up to limit pending notifications that are
+// due at now, oldest first, with their recipient's email.
+// tenant:exempt the notifier sends the emails of every organization.
+func (s *Store) GetDueEmailNotifications(ctx context.Context, now time.Time, limit int) ([]models.EmailNotification, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+emailNotificationColumns+`, u.email
+		FROM email_notifications n
+		JOIN users u ON u.id = n.user_id
+		WHERE n.status = ? AND n.next_attempt_at <= ?
+		ORDER BY n.next_attempt_at, n.id
+		LIMIT ?`,
+		models.NotificationPending, now, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.WarnContext(ctx, "Error closing rows", "error", err)
+		}
+	}()
+
+	var notifications []models.EmailNotification
+	for rows.Next() {
+		var email string
+		n, err := scanEmailNotification(rows, &email)
+		if err != nil {
+			return nil, err
+		}
+		n.Email = email
+		notifications = append(notifications, *n)
+	}
+	return notifications, rows.Err()
+}
+
+// UpdateEmailNotification records the outcome of an attempt to send a notification.
+// tenant:exempt notifications are addressed by their globally unique ID.
+func (s *Store) UpdateEmailNotification(ctx context.Context, n *models.EmailNotification) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE email_notifications SET status = ?, attempts = ?, last_error = ?, next_attempt_at = ?, sent_at = ?
+		WHERE id = ?`,
+		n.Status, n.Attempts, nullString(n.LastError), nullTime(n.NextAttemptAt), nullTime(n.SentAt), n.ID)
+	return err
+}
+
+// scanEmailNotification scans the emailNotificationColumns of a row, followed by any extra columns into extra
+func scanEmailNotification(row rowScanner, extra ...interface{}) (*models.EmailNotification, error) {
+	var n models.EmailNotification
+	var lastError sql.NullString
+	var nextAttemptAt, sentAt sql.NullTime
+	dest := append([]interface{}{&n.ID, &n.OrgID, &n.UserID, &n.EventType, &n.ServiceID, &n.VersionID, &n.Status, &n.Attempts,
+		&lastError, &nextAttemptAt, &sentAt, &n.CreatedAt}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+
+	n.LastError = lastError.String
+	if nextAttemptAt.Valid {
+		t := nextAttemptAt.Time.UTC()
+		n.NextAttemptAt = &t
+	}
+	if sentAt.Valid {
+		t := sentAt.Time.UTC()
+		n.SentAt = &t
+	}
+	n.CreatedAt = n.CreatedAt.UTC()
+	return &n, nil
+}
@@ -0,0 +1,88 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+
+	"github.com/yashjain/konnect/internal/auth"
+	"github.com/yashjain/konnect/internal/models"
+)
+
+// CreateOrganization creates a new organization
+func (s *Store) CreateOrganization(ctx context.Context, org *models.Organization) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	org.CreatedAt = timestamp()
+	_, err := s.db.ExecContext(ctx, "INSERT INTO organizations (id, name, slug, created_at) VALUES (?, ?, ?, ?)",
+		org.ID, org.Name, org.Slug, org.CreatedAt)
+	return err
+}
+
+// GetOrganizations retrieves all organizations ordered by name
+func (s *Store) GetOrganizations(ctx context.Context) ([]models.Organization, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, "SELECT id, name, slug, created_at FROM organizations ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.WarnContext(ctx, "Error closing rows", "error", err)
+		}
+	}()
+
+	var orgs []models.Organization
+	for rows.Next() {
+		var o models.Organization
+		if err := rows.Scan(&o.ID, &o.Name, &o.Slug, &o.CreatedAt); err != nil {
+			return nil, err
+		}
+		o.CreatedAt = o.CreatedAt.UTC()
+		orgs = append(orgs, o)
+	}
+
+	return orgs, rows.Err()
+}
+
+// CreateAPIToken stores a token for an organization under its hash
+func (s *Store) CreateAPIToken(ctx context.Context, token *models.APIToken, tokenHash string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	token.CreatedAt = timestamp()
+	_, err := s.db.ExecContext(ctx, "INSERT INTO api_tokens (id, org_id, user_id, name, token_hash, created_at) VALUES (?, ?, ?, ?, ?, ?)",
+		token.ID, token.OrgID, nullString(token.UserID), token.Name, tokenHash, token.CreatedAt)
+	return err
+}
+
+// GetPrincipalByTokenHash resolves the organization, user and teams behind a token
+func (s *Store) GetPrincipalByTokenHash(ctx context.Context, tokenHash string) (auth.Principal, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var p auth.Principal
+	var userID sql.NullString
+	err := s.db.QueryRowContext(ctx, "SELECT org_id, user_id FROM api_tokens WHERE token_hash = ?", tokenHash).Scan(&p.OrgID, &userID)
+	if err != nil {
+		return auth.Principal{}, err
+	}
+
+	if userID.Valid {
+		p.UserID = userID.String
+		p.TeamIDs, err = s.GetTeamIDsForUser(ctx, p.UserID)
+		if err != nil {
+			return auth.Principal{}, err
+		}
+	}
+
+	return p, nil
+}
+
+// nullString maps an empty string to SQL NULL
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
@@ -0,0 +1,139 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+
+	"github.com/google/uuid"
+
+	"github.com/yashjain/konnect/internal/models"
+)
+
+// recordEvent writes a change event inside the change's transaction, so the
+// event is stored exactly when the change commits: to the outbox when it is
+// enabled, and as a pending delivery to each webhook subscribed to its type.
+func (s *Store) recordEvent(ctx context.Context, tx *txn, orgID, eventType, subjectID string, payload interface{}) error {
+	subscriptions, err := subscribedWebhooks(ctx, tx, orgID, eventType)
+	if err != nil {
+		return err
+	}
+	if !s.outbox && len(subscriptions) == 0 {
+		return nil
+	}
+	return s.writeEvent(ctx, tx, orgID, eventType, subjectID, payload, subscriptions)
+}
+
+// recordServiceEvent writes a service event like recordEvent, whose payload is
+// the service as the transaction now sees it, including a deleted service
+func (s *Store) recordServiceEvent(ctx context.Context, tx *txn, orgID, eventType, id string) error {
+	subscriptions, err := subscribedWebhooks(ctx, tx, orgID, eventType)
+	if err != nil {
+		return err
+	}
+	if !s.outbox && len(subscriptions) == 0 {
+		return nil
+	}
+
+	service, err := scanService(tenantQueryRow(ctx, tx, orgID, "SELECT "+s.db.dialect.serviceColumns()+" FROM services WHERE id = ? AND {{tenant}}", id))
+	if err != nil {
+		return err
+	}
+	return s.writeEvent(ctx, tx, orgID, eventType, id, service, subscriptions)
+}
+
+// recordStatusEvent records version.released or version.deprecated when a
+// version takes that status, having had status previous before the change,
+// and queues the emails about a deprecation
+func (s *Store) recordStatusEvent(ctx context.Context, tx *txn, orgID, previous string, version models.Version) error {
+	if version.Status == previous {
+		return nil
+	}
+	switch version.Status {
+	case models.VersionReleased:
+		return s.recordEvent(ctx, tx, orgID, models.EventVersionReleased, version.ID, version)
+	case models.VersionDeprecated:
+		if err := s.recordEvent(ctx, tx, orgID, models.EventVersionDeprecated, version.ID, version); err != nil {
+			return err
+		}
+		return s.queueDeprecationEmails(ctx, tx, orgID, version)
+	}
+	return nil
+}
+
+// writeEvent writes an event to the outbox, when it is enabled, and queues its
+// delivery to subscriptions
+func (s *Store) writeEvent(ctx context.Context, tx *txn, orgID, eventType, subjectID string, payload interface{}, subscriptions []string) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	event := models.Event{
+		ID:        uuid.New().String(),
+		OrgID:     orgID,
+		Type:      eventType,
+		SubjectID: subjectID,
+		Payload:   body,
+		CreatedAt: timestamp(),
+	}
+
+	if s.outbox {
+		_, err = tenantExec(ctx, tx, orgID, "INSERT INTO outbox_events (id, org_id, event_type, subject_id, payload, created_at) VALUES (?, {{tenant_id}}, ?, ?, ?, ?)",
+			event.ID, event.Type, event.SubjectID, string(event.Payload), event.CreatedAt)
+		if err != nil {
+			return err
+		}
+	}
+	return queueWebhookDeliveries(ctx, tx, event, subscriptions)
+}
+
+// GetPendingEvents returns up to limit undelivered events, oldest first.
+// tenant:exempt the relay delivers the events of every organization.
+func (s *Store) GetPendingEvents(ctx context.Context, limit int) ([]models.Event, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, "SELECT id, org_id, event_type, subject_id, payload, attempts, created_at FROM outbox_events ORDER BY seq LIMIT ?", limit)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.WarnContext(ctx, "Error closing rows", "error", err)
+		}
+	}()
+
+	var events []models.Event
+	for rows.Next() {
+		var e models.Event
+		var payload string
+		if err := rows.Scan(&e.ID, &e.OrgID, &e.Type, &e.SubjectID, &payload, &e.Attempts, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		e.Payload = json.RawMessage(payload)
+		e.CreatedAt = e.CreatedAt.UTC()
+		events = append(events, e)
+	}
+
+	return events, rows.Err()
+}
+
+// DeleteEvent removes a delivered event from the outbox.
+// tenant:exempt events are addressed by their globally unique ID.
+func (s *Store) DeleteEvent(ctx context.Context, id string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, "DELETE FROM outbox_events WHERE id = ?", id)
+	return err
+}
+
+// RecordEventFailure counts a failed delivery of an event and keeps its error.
+// tenant:exempt events are addressed by their globally unique ID.
+func (s *Store) RecordEventFailure(ctx context.Context, id, reason string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, "UPDATE outbox_events SET attempts = attempts + 1, last_error = ? WHERE id = ?", reason, id)
+	return err
+}
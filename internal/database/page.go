@@ -0,0 +1,80 @@
+package database
+
+import (
+	"context"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/yashjain/konnect/pkg/types"
+)
+
+// pageLimit is the LIMIT of a page query. With lookAhead one row past the page
+// is fetched, so the caller can tell whether a next page exists without a count.
+func pageLimit(pageSize int, lookAhead bool) int {
+	if lookAhead {
+		return pageSize + 1
+	}
+	return pageSize
+}
+
+// pageWindow returns the LIMIT and OFFSET of a list page. Pages fetched without
+// a count or after a cursor look ahead; a cursor replaces the offset.
+func pageWindow(params types.PaginationParams) (limit, offset int) {
+	if params.Cursor != nil {
+		return pageLimit(params.PageSize, true), 0
+	}
+	return pageLimit(params.PageSize, params.SkipCount), (params.Page - 1) * params.PageSize
+}
+
+// keysetFilter restricts a list query to the rows after cursor, which are
+// ordered newest first by created_at and then id. prefix qualifies the columns.
+func keysetFilter(cursor *types.Cursor, prefix string) (string, []interface{}) {
+	if cursor == nil {
+		return "", nil
+	}
+	clause := " AND (" + prefix + "created_at < ? OR (" + prefix + "created_at = ? AND " + prefix + "id < ?))"
+	return clause, []interface{}{cursor.CreatedAt, cursor.CreatedAt, cursor.ID}
+}
+
+// pageAndCount runs a list's page query and, unless skipCount is set, its count
+// query concurrently, so a list takes as long as the slower of the two rather
+// than both. Either failing cancels the other.
+func pageAndCount[T any](ctx context.Context, skipCount bool, count func(ctx context.Context) (int, error), page func(ctx context.Context) ([]T, error)) ([]T, int, error) {
+	g, ctx := errgroup.WithContext(ctx)
+
+	var total int
+	if !skipCount {
+		g.Go(func() error {
+			var err error
+			total, err = count(ctx)
+			return err
+		})
+	}
+
+	var items []T
+	g.Go(func() error {
+		var err error
+		items, err = page(ctx)
+		return err
+	})
+
+	if err := g.Wait(); err != nil {
+		return nil, 0, err
+	}
+	return items, total, nil
+}
+
+// joinArgs concatenates query arguments into a new slice, so that queries run
+// concurrently never share a backing array
+func joinArgs(parts ...[]interface{}) []interface{} {
+	var n int
+	for _, part := range parts {
+		n += len(part)
+	}
+
+	args := make([]interface{}, 0, n)
+	for _, part := range parts {
+		args = append(args, part...)
+	}
+	return args
+}
@@ -0,0 +1,82 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// replicaConn sends reads to a replica and falls back to the primary when the
+// replica cannot be reached. Writes must never go through it.
+type replicaConn struct {
+	primary *conn
+	replica *conn
+
+	// downFor is how long reads stay on the primary after the replica fails
+	downFor time.Duration
+
+	mu        sync.Mutex
+	downUntil time.Time
+}
+
+// ExecContext implements querier; statements always run on the primary
+func (r *replicaConn) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return r.primary.ExecContext(ctx, query, args...)
+}
+
+// QueryContext implements querier
+func (r *replicaConn) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	if r.replicaUp() {
+		rows, err := r.replica.QueryContext(ctx, query, args...)
+		if !isConnectionError(ctx, err) {
+			return rows, err
+		}
+		r.markDown(err)
+	}
+	return r.primary.QueryContext(ctx, query, args...)
+}
+
+// QueryRowContext implements querier
+func (r *replicaConn) QueryRowContext(ctx context.Context, query string, args ...interface{}) rowScanner {
+	if r.replicaUp() {
+		row := r.replica.QueryRowContext(ctx, query, args...)
+		if !isConnectionError(ctx, row.Err()) {
+			return row
+		}
+		r.markDown(row.Err())
+	}
+	return r.primary.QueryRowContext(ctx, query, args...)
+}
+
+// replicaUp reports whether reads should be tried on the replica
+func (r *replicaConn) replicaUp() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return time.Now().After(r.downUntil)
+}
+
+// markDown sends reads to the primary for downFor
+func (r *replicaConn) markDown(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.downUntil = time.Now().Add(r.downFor)
+	slog.Warn("Read replica unavailable, reading from primary", "for", r.downFor, "error", err)
+}
+
+// isConnectionError reports whether err means the database could not be
+// reached, as opposed to the server rejecting the query or the caller giving up
+func isConnectionError(ctx context.Context, err error) bool {
+	if err == nil || err == sql.ErrNoRows || ctx.Err() != nil {
+		return false
+	}
+
+	var mysqlErr *mysql.MySQLError
+	var pgErr *pgconn.PgError
+	return !errors.As(err, &mysqlErr) && !errors.As(err, &pgErr)
+}
@@ -0,0 +1,167 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"syscall"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+	"modernc.org/sqlite"
+
+	"github.com/yashjain/konnect/internal/config"
+	"github.com/yashjain/konnect/internal/metrics"
+	"github.com/yashjain/konnect/internal/repository"
+)
+
+// MySQL error numbers for lock conflicts that roll back the statement or transaction
+const (
+	mysqlLockWaitTimeout = 1205
+	mysqlDeadlock        = 1213
+)
+
+// mysqlDuplicateEntry is ER_DUP_ENTRY, a unique key violation
+const mysqlDuplicateEntry = 1062
+
+// Postgres SQLSTATEs for lock conflicts
+const (
+	pgSerializationFailure = "40001"
+	pgDeadlockDetected     = "40P01"
+	pgLockNotAvailable     = "55P03"
+)
+
+// pgUniqueViolation is the Postgres SQLSTATE for a unique key violation
+const pgUniqueViolation = "23505"
+
+// sqliteBusy is SQLITE_BUSY, returned when busy_timeout elapses while another writer holds the lock
+const sqliteBusy = 5
+
+// sqliteConstraintUnique is SQLITE_CONSTRAINT_UNIQUE, an extended result code
+const sqliteConstraintUnique = 2067
+
+// retryPolicy retries transient database errors with jittered exponential backoff
+type retryPolicy struct {
+	// attempts is the maximum number of tries, including the first
+	attempts int
+	base     time.Duration
+	max      time.Duration
+}
+
+// newRetryPolicy reads the retry budget from cfg; fewer than one attempt means one
+func newRetryPolicy(cfg config.DatabaseConfig) retryPolicy {
+	attempts := cfg.RetryAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+	return retryPolicy{attempts: attempts, base: cfg.RetryBase, max: cfg.RetryMax}
+}
+
+// do runs fn until it succeeds, returns an error retryable rejects, the
+// attempt budget is spent, or ctx is done
+func (p retryPolicy) do(ctx context.Context, retryable func(error) bool, fn func() error) error {
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = fn()
+		if err == nil || attempt >= p.attempts || !retryable(err) {
+			return err
+		}
+
+		metrics.DBRetries.Inc()
+		timer := time.NewTimer(p.backoff(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// backoff returns a random delay of up to base * 2^(attempt-1), capped at max
+func (p retryPolicy) backoff(attempt int) time.Duration {
+	ceiling := p.max
+	if shift := attempt - 1; shift < 32 {
+		if d := p.base << shift; d > 0 && d < p.max {
+			ceiling = d
+		}
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(ceiling))) + 1
+}
+
+// isLockConflict reports whether err is a deadlock or lock timeout. The server
+// has rolled back the work, so the whole statement or transaction can be rerun.
+func isLockConflict(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number == mysqlDeadlock || mysqlErr.Number == mysqlLockWaitTimeout
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code == pgSerializationFailure || pgErr.Code == pgDeadlockDetected || pgErr.Code == pgLockNotAvailable
+	}
+
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.Code()&0xff == sqliteBusy
+	}
+
+	return false
+}
+
+// isUniqueViolation reports whether err is a unique key violation
+func isUniqueViolation(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number == mysqlDuplicateEntry
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code == pgUniqueViolation
+	}
+
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.Code() == sqliteConstraintUnique
+	}
+
+	return false
+}
+
+// conflictError marks unique key violations as repository.ErrConflict, keeping
+// the driver error for logs
+func conflictError(err error) error {
+	if isUniqueViolation(err) {
+		return fmt.Errorf("%w: %v", repository.ErrConflict, err)
+	}
+	return err
+}
+
+// isTransient reports whether a read failing with err can be rerun:
+// a lock conflict, or a connection dropped by a failover or network reset
+func isTransient(err error) bool {
+	if err == nil || err == sql.ErrNoRows {
+		return false
+	}
+	if isLockConflict(err) {
+		return true
+	}
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, mysql.ErrInvalidConn) ||
+		errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET) {
+		return true
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) && !netErr.Timeout()
+}
@@ -0,0 +1,271 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"strings"
+	"unicode"
+
+	"github.com/yashjain/konnect/internal/auth"
+	"github.com/yashjain/konnect/internal/search"
+	"github.com/yashjain/konnect/pkg/types"
+	"github.com/yashjain/konnect/pkg/utils"
+)
+
+// minFullTextWord is the shortest word full-text search is trusted to find. MyISAM
+// ignores words under ft_min_word_len (4) and InnoDB under innodb_ft_min_token_size
+// (3); the larger default is used so queries behave the same on either.
+const minFullTextWord = 4
+
+// fullTextStopwords are InnoDB's default stopwords, which full-text search ignores
+var fullTextStopwords = map[string]bool{
+	"a": true, "about": true, "an": true, "are": true, "as": true, "at": true, "be": true, "by": true,
+	"com": true, "de": true, "en": true, "for": true, "from": true, "how": true, "i": true, "in": true,
+	"is": true, "it": true, "la": true, "of": true, "on": true, "or": true, "that": true, "the": true,
+	"this": true, "to": true, "was": true, "what": true, "when": true, "where": true, "who": true,
+	"will": true, "with": true, "und": true, "www": true,
+}
+
+// likeEscape escapes LIKE wildcards in patterns. It is not a backslash, which
+// MySQL would also treat as a string escape.
+const likeEscape = "!"
+
+// searchPlan is how SearchServices matches and orders services for one query
+type searchPlan struct {
+	// match is a services predicate taking matchArgs, or empty to match every service
+	match     string
+	matchArgs []interface{}
+
+	// score is the relevance of a matched service, taking scoreArgs, or empty
+	// when matches are not ranked and are listed newest first instead
+	score     string
+	scoreArgs []interface{}
+}
+
+// planSearch uses the dialect's full-text search, unless every word of query is
+// one full-text search would ignore. Rather than match nothing, such queries,
+// like "api" or "db", fall back to an unscored substring match on name, slug
+// and description, ignoring case and accents. In boolean mode query is parsed
+// with search.ParseBoolean and never falls back. Fuzzy plans also match names
+// resembling query, adding their similarity to the score; fuzzy is ignored in
+// boolean mode. Browse plans match every service. Scores weigh matches in each
+// field by w.
+func (d *dialect) planSearch(query, mode string, fuzzy bool, w types.SearchWeights) (searchPlan, error) {
+	if mode == types.SearchModeBrowse {
+		return searchPlan{}, nil
+	}
+	if mode == types.SearchModeBoolean {
+		q, err := search.ParseBoolean(query)
+		if err != nil {
+			return searchPlan{}, err
+		}
+		term := d.booleanTerm(q)
+		score := d.booleanScore(w)
+		return searchPlan{
+			match:     d.booleanMatch,
+			matchArgs: bindEach(d.booleanMatch, term),
+			score:     score,
+			scoreArgs: bindEach(score, term),
+		}, nil
+	}
+
+	plan := d.planNatural(query, w)
+	if fuzzy && len(fuzzyWords(query)) > 0 {
+		plan = d.withFuzzy(plan, query)
+	}
+	return plan, nil
+}
+
+// planNatural plans a natural search for query, falling back to substring matches
+// for queries full-text search would ignore
+func (d *dialect) planNatural(query string, w types.SearchWeights) searchPlan {
+	if !fullTextIgnores(query) {
+		term := d.search(query)
+		score := d.searchScore(w)
+		return searchPlan{
+			match:     d.searchMatch,
+			matchArgs: bindEach(d.searchMatch, term),
+			score:     score,
+			scoreArgs: bindEach(score, term),
+		}
+	}
+
+	var clauses []string
+	var args []interface{}
+	for _, word := range strings.Fields(utils.Fold(query)) {
+		pattern := "%" + escapeLike(word) + "%"
+		for _, column := range []string{"name", "slug", "description"} {
+			clauses = append(clauses, d.folded(column)+" "+d.like+" ? ESCAPE '"+likeEscape+"'")
+			args = append(args, pattern)
+		}
+	}
+	return searchPlan{
+		match:     "(" + strings.Join(clauses, " OR ") + ")",
+		matchArgs: args,
+	}
+}
+
+// withFuzzy extends plan to also match names resembling query. Services matching
+// both ways score the sum of both scores, so exact matches still rank first.
+func (d *dialect) withFuzzy(plan searchPlan, query string) searchPlan {
+	term := query
+	if d.fuzzyTerm != nil {
+		term = d.fuzzyTerm(query)
+	}
+
+	fuzzy := searchPlan{
+		match:     "(" + plan.match + " OR " + d.fuzzyMatch + ")",
+		matchArgs: joinArgs(plan.matchArgs, []interface{}{term}),
+		score:     d.fuzzyScore,
+		scoreArgs: []interface{}{term},
+	}
+	if plan.score != "" {
+		fuzzy.score = "COALESCE(" + plan.score + ", 0) + COALESCE(" + d.fuzzyScore + ", 0)"
+		fuzzy.scoreArgs = joinArgs(plan.scoreArgs, fuzzy.scoreArgs)
+	}
+	return fuzzy
+}
+
+// searchFilter returns the conditions, after the tenant filter, on the services
+// table that SearchServices matches for params, together with their arguments and
+// the plan they match with
+func (s *Store) searchFilter(p auth.Principal, params types.SearchParams) (string, []interface{}, searchPlan, error) {
+	plan, err := s.db.dialect.planSearch(params.Query, params.Mode, params.Fuzzy, s.weights)
+	if err != nil {
+		return "", nil, searchPlan{}, err
+	}
+
+	visible, visibleArgs := visibilityFilter(p)
+	tagged, taggedArgs := tagFilter(params.Tags)
+	filter := notDeleted("", params.IncludeDeleted) + visible + tagged
+	if plan.match != "" {
+		filter = " AND " + plan.match + filter
+	}
+	args := joinArgs(plan.matchArgs, visibleArgs, taggedArgs)
+	if plan.score != "" && params.MinScore != nil {
+		filter += " AND " + plan.score + " >= ?"
+		args = joinArgs(args, plan.scoreArgs, []interface{}{*params.MinScore})
+	}
+	return filter, args, plan, nil
+}
+
+// SearchFacets counts the services SearchServices matches for params by each of
+// facets, which are types.FacetVisibility or types.FacetVersionStatus
+func (s *Store) SearchFacets(ctx context.Context, p auth.Principal, params types.SearchParams, facets []string) (map[string][]types.FacetBucket, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	filter, filterArgs, _, err := s.searchFilter(p, params)
+	if err != nil {
+		return nil, err
+	}
+
+	buckets := make(map[string][]types.FacetBucket, len(facets))
+	for _, facet := range facets {
+		var query string
+		switch facet {
+		case types.FacetVisibility:
+			query = "SELECT visibility, COUNT(*) AS n FROM services WHERE {{tenant}}" + filter + " GROUP BY visibility ORDER BY n DESC, visibility"
+		case types.FacetVersionStatus:
+			query = `
+				SELECT v.status, COUNT(DISTINCT v.service_id) AS n
+				FROM versions v
+				WHERE v.deleted_at IS NULL AND v.service_id IN (SELECT id FROM services WHERE {{tenant}}` + filter + `)
+				GROUP BY v.status
+				ORDER BY n DESC, v.status`
+		default:
+			return nil, fmt.Errorf("unknown search facet %q", facet)
+		}
+
+		rows, err := tenantQuery(ctx, s.read, p.OrgID, query, filterArgs...)
+		if err != nil {
+			return nil, err
+		}
+		if buckets[facet], err = scanFacetBuckets(rows); err != nil {
+			return nil, err
+		}
+	}
+	return buckets, nil
+}
+
+// scanFacetBuckets reads and closes rows of facet values and their counts
+func scanFacetBuckets(rows *sql.Rows) ([]types.FacetBucket, error) {
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Warn("Error closing rows", "error", err)
+		}
+	}()
+
+	buckets := []types.FacetBucket{}
+	for rows.Next() {
+		var bucket types.FacetBucket
+		if err := rows.Scan(&bucket.Value, &bucket.Count); err != nil {
+			return nil, err
+		}
+		buckets = append(buckets, bucket)
+	}
+
+	return buckets, rows.Err()
+}
+
+// fullTextIgnores reports whether every word of query is too short for, or a
+// stopword of, full-text search
+func fullTextIgnores(query string) bool {
+	words := strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(words) == 0 {
+		return false
+	}
+	for _, w := range words {
+		if len([]rune(w)) >= minFullTextWord && !fullTextStopwords[w] {
+			return false
+		}
+	}
+	return true
+}
+
+// fuzzyWords returns the lowercase words of query long enough to be matched by
+// their trigrams
+func fuzzyWords(query string) []string {
+	var words []string
+	for _, w := range strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if len([]rune(w)) >= 3 {
+			words = append(words, w)
+		}
+	}
+	return words
+}
+
+// bindEach binds arg to each placeholder of an SQL fragment
+func bindEach(fragment string, arg interface{}) []interface{} {
+	args := make([]interface{}, strings.Count(fragment, "?"))
+	for i := range args {
+		args[i] = arg
+	}
+	return args
+}
+
+// escapeLike escapes the LIKE wildcards in s with likeEscape
+func escapeLike(s string) string {
+	return strings.NewReplacer(likeEscape, likeEscape+likeEscape, "%", likeEscape+"%", "_", likeEscape+"_").Replace(s)
+}
+
+// prefixMatch is a predicate matching rows where any of columns starts with
+// prefix, ignoring case and accents, together with its arguments. Unlike the LIKE
+// fallback of planSearch it anchors the pattern, so indexes on the folded columns
+// can serve it.
+func (d *dialect) prefixMatch(prefix string, columns ...string) (string, []interface{}) {
+	pattern := escapeLike(utils.Fold(prefix)) + "%"
+	clauses := make([]string, len(columns))
+	args := make([]interface{}, len(columns))
+	for i, column := range columns {
+		clauses[i] = d.folded(column) + " LIKE ? ESCAPE '" + likeEscape + "'"
+		args[i] = pattern
+	}
+	return "(" + strings.Join(clauses, " OR ") + ")", args
+}
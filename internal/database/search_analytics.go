@@ -0,0 +1,86 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"time"
+
+	"github.com/yashjain/konnect/internal/models"
+)
+
+// RecordSearch stores one sampled search
+func (s *Store) RecordSearch(ctx context.Context, query models.SearchQuery) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	_, err := tenantExec(ctx, s.db, query.OrgID, "INSERT INTO search_queries (org_id, query, hits, searched_at) VALUES ({{tenant_id}}, ?, ?, ?)",
+		query.Query, query.Hits, query.SearchedAt)
+	return err
+}
+
+// GetSearchAnalytics counts an organization's sampled searches since a point in
+// time, with the limit most frequent queries overall and among those that found nothing
+func (s *Store) GetSearchAnalytics(ctx context.Context, orgID string, since time.Time, limit int) (*models.SearchAnalytics, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	analytics := &models.SearchAnalytics{Since: since}
+	err := tenantQueryRow(ctx, s.read, orgID, `
+		SELECT COUNT(*), COALESCE(SUM(CASE WHEN hits = 0 THEN 1 ELSE 0 END), 0)
+		FROM search_queries
+		WHERE {{tenant}} AND searched_at >= ?`, since).Scan(&analytics.Searches, &analytics.ZeroResultSearches)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := tenantQuery(ctx, s.read, orgID, `
+		SELECT query, COUNT(*) AS n, AVG(hits)
+		FROM search_queries
+		WHERE {{tenant}} AND searched_at >= ?
+		GROUP BY query
+		ORDER BY n DESC, query
+		LIMIT ?`, since, limit)
+	if err != nil {
+		return nil, err
+	}
+	if analytics.TopQueries, err = scanQueryStats(rows); err != nil {
+		return nil, err
+	}
+
+	rows, err = tenantQuery(ctx, s.read, orgID, `
+		SELECT query, COUNT(*) AS n, 0
+		FROM search_queries
+		WHERE {{tenant}} AND searched_at >= ? AND hits = 0
+		GROUP BY query
+		ORDER BY n DESC, query
+		LIMIT ?`, since, limit)
+	if err != nil {
+		return nil, err
+	}
+	if analytics.ZeroResultQueries, err = scanQueryStats(rows); err != nil {
+		return nil, err
+	}
+
+	return analytics, nil
+}
+
+// scanQueryStats reads and closes rows of queries, their counts and average hits
+func scanQueryStats(rows *sql.Rows) ([]models.QueryStat, error) {
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Warn("Error closing rows", "error", err)
+		}
+	}()
+
+	stats := []models.QueryStat{}
+	for rows.Next() {
+		var stat models.QueryStat
+		if err := rows.Scan(&stat.Query, &stat.Count, &stat.AvgHits); err != nil {
+			return nil, err
+		}
+		stats = append(stats, stat)
+	}
+
+	return stats, rows.Err()
+}
@@ -0,0 +1,73 @@
+package database
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/yashjain/konnect/internal/models"
+)
+
+// GetSynonyms returns every term with its synonyms, ordered by term. Synonyms
+// are read from the primary so that they are current right after a change.
+// tenant:exempt synonyms apply to every organization.
+func (s *Store) GetSynonyms(ctx context.Context) ([]models.SearchSynonyms, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, "SELECT term, synonym FROM search_synonyms ORDER BY term, synonym")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.WarnContext(ctx, "Error closing rows", "error", err)
+		}
+	}()
+
+	synonyms := []models.SearchSynonyms{}
+	for rows.Next() {
+		var term, synonym string
+		if err := rows.Scan(&term, &synonym); err != nil {
+			return nil, err
+		}
+		if n := len(synonyms); n == 0 || synonyms[n-1].Term != term {
+			synonyms = append(synonyms, models.SearchSynonyms{Term: term})
+		}
+		last := &synonyms[len(synonyms)-1]
+		last.Synonyms = append(last.Synonyms, synonym)
+	}
+
+	return synonyms, rows.Err()
+}
+
+// SetSynonyms replaces the synonyms of a term
+// tenant:exempt synonyms apply to every organization.
+func (s *Store) SetSynonyms(ctx context.Context, term string, synonyms []string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	return s.withTx(ctx, func(tx *txn) error {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM search_synonyms WHERE term = ?", term); err != nil {
+			return err
+		}
+		for _, synonym := range synonyms {
+			if _, err := tx.ExecContext(ctx, "INSERT INTO search_synonyms (term, synonym) VALUES (?, ?)", term, synonym); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// DeleteSynonyms removes the synonyms of a term, returning how many were removed
+// tenant:exempt synonyms apply to every organization.
+func (s *Store) DeleteSynonyms(ctx context.Context, term string) (int64, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	result, err := s.db.ExecContext(ctx, "DELETE FROM search_synonyms WHERE term = ?", term)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
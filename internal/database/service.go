@@ -1,128 +1,487 @@
 package database
 
 import (
-	"log"
+	"context"
+	"database/sql"
+	"log/slog"
+	"sort"
+	"strings"
 
+	"github.com/yashjain/konnect/internal/auth"
 	"github.com/yashjain/konnect/internal/models"
+	"github.com/yashjain/konnect/internal/repository"
 	"github.com/yashjain/konnect/pkg/types"
 )
 
-// GetServices retrieves paginated services from the database
-func GetServices(params types.PaginationParams) ([]models.Service, int, error) {
-	offset := (params.Page - 1) * params.PageSize
+// versionsCount counts the versions of a service on read rather than storing the
+// count, so it cannot drift from the versions table; it leaves out soft-deleted versions
+const versionsCount = "(SELECT COUNT(*) FROM versions v WHERE v.service_id = services.id AND v.deleted_at IS NULL)"
+
+// latestVersionStatus is the status of a service's newest version that is not
+// soft-deleted, NULL when it has none
+const latestVersionStatus = "(SELECT v.status FROM versions v WHERE v.service_id = services.id AND v.deleted_at IS NULL ORDER BY v.created_at DESC, v.id DESC LIMIT 1)"
+
+// serviceSpecScore is the lint score of the OpenAPI document of a service's
+// newest version that has a scored one and is not soft-deleted, NULL when there is none
+const serviceSpecScore = "(SELECT sp.lint_score FROM version_specs sp JOIN versions v ON v.id = sp.version_id AND v.service_id = sp.service_id " +
+	"WHERE sp.service_id = services.id AND sp.lint_score IS NOT NULL AND v.deleted_at IS NULL ORDER BY v.created_at DESC, v.id DESC LIMIT 1)"
+
+// serviceColumns are the columns scanService reads, in order. Every query that
+// returns services selects exactly these, so a new column is added in one place.
+func (d *dialect) serviceColumns() string {
+	return "id, org_id, name, slug, description, visibility, owner_team, owner_email, created_at, updated_at, deleted_at, metadata, " +
+		versionsCount + " AS versions_count, " + d.tagList + " AS tags, " + serviceSpecScore + " AS spec_score"
+}
 
-	// Get total count
-	var total int
-	err := DB.QueryRow("SELECT COUNT(*) FROM services").Scan(&total)
+// scanService reads a row selected with serviceColumns
+func scanService(row rowScanner) (models.Service, error) {
+	var s models.Service
+	var deletedAt sql.NullTime
+	var metadata, tags sql.NullString
+	var specScore sql.NullInt64
+	err := row.Scan(&s.ID, &s.OrgID, &s.Name, &s.Slug, &s.Description, &s.Visibility, &s.OwnerTeam, &s.OwnerEmail, &s.CreatedAt, &s.UpdatedAt, &deletedAt, &metadata, &s.VersionsCount, &tags, &specScore)
 	if err != nil {
-		return nil, 0, err
+		return s, err
+	}
+	s.SpecScore = intOrNil(specScore)
+	s.CreatedAt, s.UpdatedAt = s.CreatedAt.UTC(), s.UpdatedAt.UTC()
+	if deletedAt.Valid {
+		t := deletedAt.Time.UTC()
+		s.DeletedAt = &t
+	}
+	s.Tags = []string{}
+	if tags.Valid && tags.String != "" {
+		s.Tags = strings.Split(tags.String, ",")
+		sort.Strings(s.Tags)
 	}
+	s.Metadata, err = decodeMetadata(metadata)
+	return s, err
+}
 
-	// Get paginated services
-	query := "SELECT id, name, slug, description, created_at, updated_at, versions_count FROM services ORDER BY created_at DESC LIMIT ? OFFSET ?"
-	rows, err := DB.Query(query, params.PageSize, offset)
-	if err != nil {
-		return nil, 0, err
+// scanServices reads and closes rows selected with serviceColumns
+func scanServices(rows *sql.Rows) ([]models.Service, error) {
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Warn("Error closing rows", "error", err)
+		}
+	}()
+
+	var services []models.Service
+	for rows.Next() {
+		service, err := scanService(rows)
+		if err != nil {
+			return nil, err
+		}
+		services = append(services, service)
 	}
+
+	return services, rows.Err()
+}
+
+// scanScoredServices reads and closes rows selected with serviceColumns followed
+// by a nullable score
+func scanScoredServices(rows *sql.Rows) ([]models.Service, error) {
 	defer func() {
 		if err := rows.Close(); err != nil {
-			log.Printf("Error closing rows: %v", err)
+			slog.Warn("Error closing rows", "error", err)
 		}
 	}()
 
 	var services []models.Service
 	for rows.Next() {
-		var s models.Service
-		err := rows.Scan(&s.ID, &s.Name, &s.Slug, &s.Description, &s.CreatedAt, &s.UpdatedAt, &s.VersionsCount)
+		var score sql.NullFloat64
+		service, err := scanService(scoredRow{rows, &score})
+		if err != nil {
+			return nil, err
+		}
+		if score.Valid {
+			service.Score = &score.Float64
+		}
+		services = append(services, service)
+	}
+
+	return services, rows.Err()
+}
+
+// scoredRow is a rowScanner that reads a trailing score column after the
+// columns its caller asks for
+type scoredRow struct {
+	*sql.Rows
+	score *sql.NullFloat64
+}
+
+// Scan implements rowScanner
+func (r scoredRow) Scan(dest ...interface{}) error {
+	return r.Rows.Scan(append(dest, r.score)...)
+}
+
+// GetServices retrieves paginated services visible to a principal, newest first.
+// A params.Query matches services like SearchServices does, without changing the order.
+func (s *Store) GetServices(ctx context.Context, p auth.Principal, params types.PaginationParams) ([]models.Service, int, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	limit, offset := pageWindow(params)
+	filter, filterArgs := visibilityFilter(p)
+	keyset, keysetArgs := keysetFilter(params.Cursor, "")
+	conditions, conditionArgs, err := s.db.dialect.filterClause(params.Filter, serviceFilterColumns)
+	if err != nil {
+		return nil, 0, err
+	}
+	tagged, taggedArgs := tagFilter(params.Tags)
+	described, describedArgs := s.db.dialect.metadataFilter(params.Metadata, "metadata")
+	categorized, categorizedArgs := categoryFilter(params.Category)
+	owned, ownedArgs := ownerFilter(params.Owner)
+	deployed, deployedArgs := deployedServiceFilter(params.DeployedIn)
+	filter = notDeleted("", params.IncludeDeleted) + filter + conditions + tagged + described + categorized + owned + deployed
+	filterArgs = joinArgs(filterArgs, conditionArgs, taggedArgs, describedArgs, categorizedArgs, ownedArgs, deployedArgs)
+	if params.Query != "" {
+		plan, err := s.db.dialect.planSearch(params.Query, types.SearchModeNatural, false, s.weights)
 		if err != nil {
 			return nil, 0, err
 		}
-		services = append(services, s)
+		filter += " AND " + plan.match
+		filterArgs = joinArgs(filterArgs, plan.matchArgs)
 	}
 
-	return services, total, nil
+	countQuery := "SELECT COUNT(*) FROM services WHERE {{tenant}}" + filter
+	pageQuery := "SELECT " + s.db.dialect.serviceColumns() + " FROM services WHERE {{tenant}}" + filter + keyset + " ORDER BY created_at DESC, id DESC LIMIT ? OFFSET ?"
+	pageArgs := joinArgs(filterArgs, keysetArgs, []interface{}{limit, offset})
+
+	return pageAndCount(ctx, params.SkipCount,
+		func(ctx context.Context) (int, error) {
+			var total int
+			err := tenantQueryRow(ctx, s.read, p.OrgID, countQuery, filterArgs...).Scan(&total)
+			return total, err
+		},
+		func(ctx context.Context) ([]models.Service, error) {
+			rows, err := tenantQuery(ctx, s.read, p.OrgID, pageQuery, pageArgs...)
+			if err != nil {
+				return nil, err
+			}
+			return scanServices(rows)
+		})
 }
 
-// SearchServices performs full-text search on services
-func SearchServices(params types.SearchParams) ([]models.Service, int, error) {
-	offset := (params.Page - 1) * params.PageSize
+// searchOrders are the ORDER BY clauses of SearchServices for each sort other
+// than relevance, which depends on whether the search is scored
+var searchOrders = map[string]string{
+	types.SortName:          "name, id",
+	types.SortCreatedAt:     "created_at DESC, id DESC",
+	types.SortVersionsCount: "versions_count DESC, created_at DESC, id DESC",
+}
 
-	// Get total count for search results
-	countQuery := "SELECT COUNT(*) FROM services WHERE MATCH(name, description) AGAINST(? IN NATURAL LANGUAGE MODE)"
-	var total int
-	err := DB.QueryRow(countQuery, params.Query).Scan(&total)
+// SearchServices performs full-text search on services visible to a principal,
+// best matches first with their Score unless params.Sort orders them otherwise.
+// Substring matches of short queries are not scored; they are listed newest first
+// by relevance and params.MinScore does not apply to them.
+func (s *Store) SearchServices(ctx context.Context, p auth.Principal, params types.SearchParams) ([]models.Service, int, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	offset := (params.Page - 1) * params.PageSize
+	filter, filterArgs, plan, err := s.searchFilter(p, params)
 	if err != nil {
 		return nil, 0, err
 	}
 
-	// Get paginated search results
+	score, order := "NULL", "created_at DESC, id DESC"
+	if plan.score != "" {
+		score = plan.score
+		if params.Sort == types.SortRelevance || params.Sort == "" {
+			order = "score DESC, created_at DESC, id DESC"
+		}
+	}
+	if by, ok := searchOrders[params.Sort]; ok {
+		order = by
+	}
+
+	countQuery := "SELECT COUNT(*) FROM services WHERE {{tenant}}" + filter
 	searchQuery := `
-		SELECT id, name, slug, description, created_at, updated_at, versions_count 
-		FROM services 
-		WHERE MATCH(name, description) AGAINST(? IN NATURAL LANGUAGE MODE)
-		ORDER BY MATCH(name, description) AGAINST(? IN NATURAL LANGUAGE MODE) DESC, created_at DESC
+		SELECT ` + s.db.dialect.serviceColumns() + `, ` + score + ` AS score
+		FROM services
+		WHERE {{tenant}}` + filter + `
+		ORDER BY ` + order + `
 		LIMIT ? OFFSET ?`
+	searchArgs := joinArgs(plan.scoreArgs, filterArgs, []interface{}{pageLimit(params.PageSize, params.SkipCount), offset})
+
+	return pageAndCount(ctx, params.SkipCount,
+		func(ctx context.Context) (int, error) {
+			var total int
+			err := tenantQueryRow(ctx, s.read, p.OrgID, countQuery, filterArgs...).Scan(&total)
+			return total, err
+		},
+		func(ctx context.Context) ([]models.Service, error) {
+			rows, err := tenantQuery(ctx, s.read, p.OrgID, searchQuery, searchArgs...)
+			if err != nil {
+				return nil, err
+			}
+			return scanScoredServices(rows)
+		})
+}
+
+// SuggestServices returns up to limit services visible to a principal whose name
+// or slug starts with prefix, ignoring case, ordered by name. It runs no count so
+// that it stays fast enough to call on every keystroke.
+func (s *Store) SuggestServices(ctx context.Context, p auth.Principal, prefix string, limit int) ([]models.ServiceSuggestion, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
 
-	rows, err := DB.Query(searchQuery, params.Query, params.Query, params.PageSize, offset)
+	match, matchArgs := s.db.dialect.prefixMatch(prefix, "name", "slug")
+	filter, filterArgs := visibilityFilter(p)
+	query := "SELECT id, name, slug FROM services WHERE {{tenant}} AND deleted_at IS NULL AND " + match + filter + " ORDER BY name, id LIMIT ?"
+
+	rows, err := tenantQuery(ctx, s.read, p.OrgID, query, joinArgs(matchArgs, filterArgs, []interface{}{limit})...)
 	if err != nil {
-		return nil, 0, err
+		return nil, err
 	}
 	defer func() {
 		if err := rows.Close(); err != nil {
-			log.Printf("Error closing rows: %v", err)
+			slog.WarnContext(ctx, "Error closing rows", "error", err)
 		}
 	}()
 
-	var services []models.Service
+	var suggestions []models.ServiceSuggestion
 	for rows.Next() {
-		var s models.Service
-		err := rows.Scan(&s.ID, &s.Name, &s.Slug, &s.Description, &s.CreatedAt, &s.UpdatedAt, &s.VersionsCount)
-		if err != nil {
-			return nil, 0, err
+		var suggestion models.ServiceSuggestion
+		if err := rows.Scan(&suggestion.ID, &suggestion.Name, &suggestion.Slug); err != nil {
+			return nil, err
 		}
-		services = append(services, s)
+		suggestions = append(suggestions, suggestion)
 	}
 
-	return services, total, nil
+	return suggestions, rows.Err()
 }
 
-// CreateService creates a new service in the database
-func CreateService(service *models.Service) error {
-	_, err := DB.Exec("INSERT INTO services (id, name, slug, description) VALUES (?, ?, ?, ?)",
-		service.ID, service.Name, service.Slug, service.Description)
-	return err
+// GetServicesByIDs returns the services among ids that are visible to a principal,
+// in no particular order. Unknown, deleted and hidden services are left out.
+func (s *Store) GetServicesByIDs(ctx context.Context, p auth.Principal, ids []string) ([]models.Service, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	filter, filterArgs := visibilityFilter(p)
+	query := "SELECT " + s.db.dialect.serviceColumns() + " FROM services WHERE {{tenant}} AND deleted_at IS NULL AND id IN (?" + strings.Repeat(", ?", len(ids)-1) + ")" + filter
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+
+	rows, err := tenantQuery(ctx, s.read, p.OrgID, query, joinArgs(args, filterArgs)...)
+	if err != nil {
+		return nil, err
+	}
+	return scanServices(rows)
+}
+
+// CreateService creates a new service in the database together with any initial ACL grants
+func (s *Store) CreateService(ctx context.Context, service *models.Service, grants ...models.ServiceACL) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	service.CreatedAt = timestamp()
+	service.UpdatedAt = service.CreatedAt
+	if service.Metadata == nil {
+		service.Metadata = map[string]string{}
+	}
+	metadata, err := encodeMetadata(service.Metadata)
+	if err != nil {
+		return err
+	}
+
+	err = s.withTx(ctx, func(tx *txn) error {
+		_, err := tenantExec(ctx, tx, service.OrgID, "INSERT INTO services (id, org_id, name, slug, description, visibility, owner_team, owner_email, metadata, created_at, updated_at) VALUES (?, {{tenant_id}}, ?, ?, ?, ?, COALESCE(?, ''), COALESCE(?, ''), ?, ?, ?)",
+			service.ID, service.Name, service.Slug, service.Description, service.Visibility, service.OwnerTeam, service.OwnerEmail, metadata, service.CreatedAt, service.UpdatedAt)
+		if err != nil {
+			return err
+		}
+
+		for _, acl := range grants {
+			_, err = tenantExec(ctx, tx, service.OrgID, `
+				INSERT INTO service_acls (id, service_id, subject_type, subject_id, permission, created_at)
+				SELECT ?, id, ?, ?, ?, ? FROM services WHERE id = ? AND {{tenant}}`,
+				acl.ID, acl.SubjectType, acl.SubjectID, acl.Permission, service.CreatedAt, service.ID)
+			if err != nil {
+				return err
+			}
+		}
+		if err := setServiceTags(ctx, tx, service.OrgID, service.ID, service.Tags); err != nil {
+			return err
+		}
+		if err := s.queueKongSync(ctx, tx, service.OrgID, service.ID); err != nil {
+			return err
+		}
+		return s.recordServiceEvent(ctx, tx, service.OrgID, models.EventServiceCreated, service.ID)
+	})
+	return conflictError(err)
 }
 
-// GetServiceByID retrieves a service by its ID
-func GetServiceByID(id string) (*models.Service, error) {
-	var service models.Service
-	err := DB.QueryRow("SELECT id, name, slug, description, created_at, updated_at, versions_count FROM services WHERE id = ?", id).
-		Scan(&service.ID, &service.Name, &service.Slug, &service.Description, &service.CreatedAt, &service.UpdatedAt, &service.VersionsCount)
+// GetServiceByID retrieves a service by its ID within an organization
+func (s *Store) GetServiceByID(ctx context.Context, orgID, id string, opts ...types.ReadOptions) (*models.Service, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	query := "SELECT " + s.db.dialect.serviceColumns() + " FROM services WHERE id = ? AND {{tenant}}" + notDeleted("", includeDeleted(opts))
+	service, err := scanService(tenantQueryRow(ctx, s.read, orgID, query, id))
 	if err != nil {
 		return nil, err
 	}
 	return &service, nil
 }
 
-// UpdateService updates a service in the database
-func UpdateService(id string, service *models.Service) (int64, error) {
-	result, err := DB.Exec("UPDATE services SET name = ?, slug = ?, description = ? WHERE id = ?",
-		service.Name, service.Slug, service.Description, id)
+// GetServiceBySlug retrieves a service by its slug within an organization
+func (s *Store) GetServiceBySlug(ctx context.Context, orgID, slug string, opts ...types.ReadOptions) (*models.Service, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	query := "SELECT " + s.db.dialect.serviceColumns() + " FROM services WHERE slug = ? AND {{tenant}}" + notDeleted("", includeDeleted(opts))
+	service, err := scanService(tenantQueryRow(ctx, s.read, orgID, query, slug))
+	if err != nil {
+		return nil, err
+	}
+	return &service, nil
+}
+
+// UpdateService updates a service within an organization. Soft-deleted services
+// are not updated. An empty visibility leaves the current visibility unchanged,
+// and nil owners, tags or metadata leave the current ones unchanged.
+func (s *Store) UpdateService(ctx context.Context, orgID, id string, service *models.Service) (int64, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	metadata, err := encodeMetadata(service.Metadata)
 	if err != nil {
 		return 0, err
 	}
 
-	rowsAffected, err := result.RowsAffected()
+	var rowsAffected int64
+	err = s.withTx(ctx, func(tx *txn) error {
+		result, err := tenantExec(ctx, tx, orgID, "UPDATE services SET name = ?, slug = ?, description = ?, visibility = COALESCE(NULLIF(?, ''), visibility), owner_team = COALESCE(?, owner_team), owner_email = COALESCE(?, owner_email), metadata = COALESCE(?, metadata) WHERE id = ? AND {{tenant}} AND deleted_at IS NULL",
+			service.Name, service.Slug, service.Description, service.Visibility, service.OwnerTeam, service.OwnerEmail, metadata, id)
+		if err != nil {
+			return err
+		}
+		if rowsAffected, err = result.RowsAffected(); err != nil || rowsAffected == 0 {
+			return err
+		}
+		if service.Tags != nil {
+			if err := setServiceTags(ctx, tx, orgID, id, service.Tags); err != nil {
+				return err
+			}
+		}
+		if err := s.queueKongSync(ctx, tx, orgID, id); err != nil {
+			return err
+		}
+		return s.recordServiceEvent(ctx, tx, orgID, models.EventServiceUpdated, id)
+	})
+	return rowsAffected, conflictError(err)
+}
+
+// DeleteService soft-deletes a service within an organization by stamping its
+// deleted_at. The service keeps its name and slug, so that it can be restored.
+// It returns repository.ErrInUse while teams consume the service or one of its
+// versions.
+func (s *Store) DeleteService(ctx context.Context, orgID, id string) (int64, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var rowsAffected int64
+	err := s.withTx(ctx, func(tx *txn) error {
+		result, err := tenantExec(ctx, tx, orgID, "UPDATE services SET deleted_at = ? WHERE id = ? AND {{tenant}} AND deleted_at IS NULL", timestamp(), id)
+		if err != nil {
+			return err
+		}
+		if rowsAffected, err = result.RowsAffected(); err != nil || rowsAffected == 0 {
+			return err
+		}
+		// Counted once the update has locked the service, which CreateConsumer locks too
+		var consumers int
+		err = tenantQueryRow(ctx, tx, orgID, `
+			SELECT COUNT(*) FROM consumers c
+			WHERE c.service_id = ? AND {{tenant:c}} AND (c.version_id IS NULL
+				OR c.version_id IN (SELECT id FROM versions WHERE service_id = ? AND deleted_at IS NULL))`,
+			id, id).Scan(&consumers)
+		if err != nil {
+			return err
+		}
+		if consumers > 0 {
+			rowsAffected = 0
+			return repository.ErrInUse
+		}
+		if err := s.queueKongSync(ctx, tx, orgID, id); err != nil {
+			return err
+		}
+		return s.recordServiceEvent(ctx, tx, orgID, models.EventServiceDeleted, id)
+	})
 	return rowsAffected, err
 }
 
-// DeleteService deletes a service from the database
-func DeleteService(id string) (int64, error) {
-	result, err := DB.Exec("DELETE FROM services WHERE id = ?", id)
+// setServiceTags replaces the tags of a service within an organization
+func setServiceTags(ctx context.Context, tx *txn, orgID, id string, tags []string) error {
+	_, err := tenantExec(ctx, tx, orgID, "DELETE FROM service_tags WHERE service_id IN (SELECT id FROM services WHERE id = ? AND {{tenant}})", id)
 	if err != nil {
-		return 0, err
+		return err
+	}
+	for _, tag := range tags {
+		_, err = tenantExec(ctx, tx, orgID, "INSERT INTO service_tags (service_id, tag) SELECT id, ? FROM services WHERE id = ? AND {{tenant}}", tag, id)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tagFilter matches services of the current services row carrying the filter's
+// tags, together with its arguments. Both modes look tags up through the
+// (tag, service_id) index; matching all tags counts the matches per service.
+// tenant:exempt the fragment is appended to queries that are already tenant-scoped.
+func tagFilter(f types.TagFilter) (string, []interface{}) {
+	if len(f.Tags) == 0 {
+		return "", nil
 	}
 
-	rowsAffected, err := result.RowsAffected()
-	return rowsAffected, err
+	args := make([]interface{}, len(f.Tags))
+	for i, tag := range f.Tags {
+		args[i] = tag
+	}
+	filter := " AND id IN (SELECT service_id FROM service_tags WHERE tag IN (?" + strings.Repeat(", ?", len(f.Tags)-1) + ")"
+	if f.Any {
+		return filter + ")", args
+	}
+	return filter + " GROUP BY service_id HAVING COUNT(*) = ?)", append(args, len(f.Tags))
+}
+
+// ownerFilter matches services of the current services row owned by owner, an
+// email when it holds an @ and a team otherwise, together with its arguments
+func ownerFilter(owner string) (string, []interface{}) {
+	switch {
+	case owner == "":
+		return "", nil
+	case strings.Contains(owner, "@"):
+		return " AND owner_email = ?", []interface{}{owner}
+	default:
+		return " AND owner_team = ?", []interface{}{owner}
+	}
+}
+
+// notDeleted filters soft-deleted rows out of a query on the table aliased by
+// prefix, unless includeDeleted is set
+func notDeleted(prefix string, includeDeleted bool) string {
+	if includeDeleted {
+		return ""
+	}
+	return " AND " + prefix + "deleted_at IS NULL"
+}
+
+// includeDeleted reports whether any of opts asks for soft-deleted rows
+func includeDeleted(opts []types.ReadOptions) bool {
+	for _, o := range opts {
+		if o.IncludeDeleted {
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,100 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/yashjain/konnect/internal/models"
+)
+
+// GetUserCredentials returns the user, organization and password hash for a login email.
+// Users without a password cannot log in and are reported as sql.ErrNoRows.
+// tenant:exempt login establishes the tenant, so it cannot be scoped by one.
+func (s *Store) GetUserCredentials(ctx context.Context, email string) (userID, orgID, passwordHash string, err error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	err = s.db.QueryRowContext(ctx, "SELECT id, org_id, password_hash FROM users WHERE email = ? AND password_hash IS NOT NULL", email).
+		Scan(&userID, &orgID, &passwordHash)
+	return userID, orgID, passwordHash, err
+}
+
+// GetUserOrgID returns the organization a user belongs to.
+// tenant:exempt token refresh establishes the tenant, so it cannot be scoped by one.
+func (s *Store) GetUserOrgID(ctx context.Context, userID string) (string, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var orgID string
+	err := s.db.QueryRowContext(ctx, "SELECT org_id FROM users WHERE id = ?", userID).Scan(&orgID)
+	return orgID, err
+}
+
+// CreateSession stores a new refresh token session under its hash
+func (s *Store) CreateSession(ctx context.Context, session *models.Session, refreshHash string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, "INSERT INTO sessions (id, family_id, user_id, refresh_token_hash, expires_at) VALUES (?, ?, ?, ?, ?)",
+		session.ID, session.FamilyID, session.UserID, refreshHash, session.ExpiresAt)
+	return err
+}
+
+// GetSessionByRefreshHash retrieves the session a refresh token belongs to, revoked or not
+func (s *Store) GetSessionByRefreshHash(ctx context.Context, refreshHash string) (*models.Session, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var session models.Session
+	var revokedAt sql.NullTime
+	err := s.db.QueryRowContext(ctx, "SELECT id, family_id, user_id, expires_at, revoked_at FROM sessions WHERE refresh_token_hash = ?", refreshHash).
+		Scan(&session.ID, &session.FamilyID, &session.UserID, &session.ExpiresAt, &revokedAt)
+	if err != nil {
+		return nil, err
+	}
+	if revokedAt.Valid {
+		session.RevokedAt = &revokedAt.Time
+	}
+	return &session, nil
+}
+
+// RotateSession revokes a session and stores its successor atomically.
+// It returns false when the session was already revoked by a concurrent request.
+func (s *Store) RotateSession(ctx context.Context, oldID string, next *models.Session, refreshHash string) (bool, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	// withTx does not retry dropped connections: a rotation that committed before
+	// its connection dropped would be seen as refresh token reuse on a second try
+	var rotated bool
+	err := s.withTx(ctx, func(tx *txn) error {
+		result, err := tx.ExecContext(ctx, "UPDATE sessions SET revoked_at = CURRENT_TIMESTAMP WHERE id = ? AND revoked_at IS NULL", oldID)
+		if err != nil {
+			return err
+		}
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rotated = rowsAffected > 0; !rotated {
+			return nil
+		}
+
+		_, err = tx.ExecContext(ctx, "INSERT INTO sessions (id, family_id, user_id, refresh_token_hash, expires_at) VALUES (?, ?, ?, ?, ?)",
+			next.ID, next.FamilyID, next.UserID, refreshHash, next.ExpiresAt)
+		return err
+	})
+	if err != nil {
+		return false, err
+	}
+	return rotated, nil
+}
+
+// RevokeSessionFamily revokes every session descended from the same login
+func (s *Store) RevokeSessionFamily(ctx context.Context, familyID string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, "UPDATE sessions SET revoked_at = CURRENT_TIMESTAMP WHERE family_id = ? AND revoked_at IS NULL", familyID)
+	return err
+}
@@ -0,0 +1,43 @@
+package database
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/yashjain/konnect/internal/logging"
+)
+
+// slowLog logs statements that run longer than threshold; zero logs none
+type slowLog struct {
+	threshold time.Duration
+}
+
+// observe logs query, with a hash of its arguments, when it has been running for
+// longer than the threshold since start. Arguments are hashed rather than logged
+// since they carry user data, yet repeated slow calls with the same arguments
+// still share a hash.
+func (l slowLog) observe(ctx context.Context, query string, args []interface{}, start time.Time) {
+	elapsed := time.Since(start)
+	if l.threshold <= 0 || elapsed < l.threshold {
+		return
+	}
+	slog.WarnContext(ctx, "Slow query",
+		"sql", query,
+		"args_hash", argsHash(args),
+		"duration_ms", float64(elapsed.Microseconds())/1000,
+		"endpoint", logging.Endpoint(ctx),
+	)
+}
+
+// argsHash identifies a list of arguments by their types and values
+func argsHash(args []interface{}) string {
+	h := sha256.New()
+	for _, arg := range args {
+		fmt.Fprintf(h, "%T:%v\x00", arg, arg)
+	}
+	return hex.EncodeToString(h.Sum(nil)[:8])
+}
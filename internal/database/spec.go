@@ -0,0 +1,91 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/yashjain/konnect/internal/models"
+)
+
+// SetVersionSpec attaches an OpenAPI document to a version of a service within
+// an organization, replacing the one it had. It returns sql.ErrNoRows when the
+// version is not in the organization or either it or its service has been
+// soft-deleted.
+func (s *Store) SetVersionSpec(ctx context.Context, orgID string, spec *models.VersionSpec) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	spec.UpdatedAt = timestamp()
+	return s.withTx(ctx, func(tx *txn) error {
+		var found int
+		err := tenantQueryRow(ctx, tx, orgID, `
+			SELECT COUNT(*) FROM versions v JOIN services s ON s.id = v.service_id
+			WHERE v.id = ? AND v.service_id = ? AND {{tenant:s}} AND v.deleted_at IS NULL AND s.deleted_at IS NULL`,
+			spec.VersionID, spec.ServiceID).Scan(&found)
+		if err != nil {
+			return err
+		}
+		if found == 0 {
+			return sql.ErrNoRows
+		}
+
+		_, err = tenantExec(ctx, tx, orgID, "DELETE FROM version_specs WHERE version_id = ? AND service_id IN (SELECT id FROM services WHERE {{tenant}})", spec.VersionID)
+		if err != nil {
+			return err
+		}
+		_, err = tenantExec(ctx, tx, orgID, "INSERT INTO version_specs (version_id, service_id, format, content, digest, lint_score, updated_at) SELECT ?, id, ?, ?, ?, ?, ? FROM services WHERE id = ? AND {{tenant}}",
+			spec.VersionID, spec.Format, string(spec.Content), spec.Digest, spec.Score, spec.UpdatedAt, spec.ServiceID)
+		return err
+	})
+}
+
+// GetVersionSpec returns the OpenAPI document of a version of a service within
+// an organization, or sql.ErrNoRows when the version has none, is not in the
+// organization, or either it or its service has been soft-deleted
+func (s *Store) GetVersionSpec(ctx context.Context, orgID, serviceID, versionID string) (*models.VersionSpec, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var spec models.VersionSpec
+	var content string
+	var score sql.NullInt64
+	err := tenantQueryRow(ctx, s.read, orgID, `
+		SELECT sp.version_id, sp.service_id, sp.format, sp.content, sp.digest, sp.lint_score, sp.updated_at
+		FROM version_specs sp
+		JOIN versions v ON v.id = sp.version_id AND v.service_id = sp.service_id
+		JOIN services s ON s.id = sp.service_id
+		WHERE sp.version_id = ? AND sp.service_id = ? AND {{tenant:s}} AND v.deleted_at IS NULL AND s.deleted_at IS NULL`,
+		versionID, serviceID).Scan(&spec.VersionID, &spec.ServiceID, &spec.Format, &content, &spec.Digest, &score, &spec.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	spec.Score = intOrNil(score)
+	spec.Content = []byte(content)
+	spec.Size = len(spec.Content)
+	spec.UpdatedAt = spec.UpdatedAt.UTC()
+	return &spec, nil
+}
+
+// DeleteVersionSpec removes the OpenAPI document of a version of a service within an organization
+func (s *Store) DeleteVersionSpec(ctx context.Context, orgID, serviceID, versionID string) (int64, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	result, err := tenantExec(ctx, s.db, orgID, `
+		DELETE FROM version_specs
+		WHERE version_id = ? AND service_id = ? AND service_id IN (SELECT id FROM services WHERE {{tenant}} AND deleted_at IS NULL)`,
+		versionID, serviceID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// intOrNil returns a nullable integer column as an int, nil when it is NULL
+func intOrNil(n sql.NullInt64) *int {
+	if !n.Valid {
+		return nil
+	}
+	v := int(n.Int64)
+	return &v
+}
@@ -0,0 +1,58 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"log/slog"
+
+	"github.com/pressly/goose/v3"
+	"modernc.org/sqlite"
+
+	"github.com/yashjain/konnect/internal/config"
+	"github.com/yashjain/konnect/migrations"
+	"github.com/yashjain/konnect/pkg/utils"
+)
+
+// The fold SQL function is the dialect's fold on SQLite, which has no function
+// stripping accents. It is registered for every connection the driver opens.
+func init() {
+	sqlite.MustRegisterDeterministicScalarFunction("fold", 1, func(_ *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+		switch v := args[0].(type) {
+		case string:
+			return utils.Fold(v), nil
+		case []byte:
+			return utils.Fold(string(v)), nil
+		default:
+			return v, nil
+		}
+	})
+}
+
+// openSQLite opens the SQLite database at cfg.SQLitePath, creating it if needed,
+// and applies the embedded migrations so no external setup is required
+func openSQLite(cfg config.DatabaseConfig) (*Store, error) {
+	db, err := sql.Open("sqlite", "file:"+cfg.SQLitePath+"?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
+	if err != nil {
+		return nil, err
+	}
+
+	// SQLite allows a single writer; one connection avoids "database is locked"
+	// errors and keeps an in-memory database from being split across connections
+	db.SetMaxOpenConns(1)
+
+	if err := migrateSQLite(db); err != nil {
+		if closeErr := db.Close(); closeErr != nil {
+			slog.Warn("Error closing database", "error", closeErr)
+		}
+		return nil, err
+	}
+
+	primary := &conn{db: db, dialect: sqliteDialect, retry: newRetryPolicy(cfg), stmts: newStmtCache(db, cfg.StatementCacheSize), slow: slowLog{cfg.SlowQueryThreshold}}
+	return &Store{db: primary, read: primary, timeout: cfg.QueryTimeout, outbox: cfg.Outbox, kongSync: cfg.KongSync, notifications: cfg.Notifications, weights: cfg.SearchWeights}, nil
+}
+
+// migrateSQLite applies any pending embedded SQLite migrations
+func migrateSQLite(db *sql.DB) error {
+	return migrate(context.Background(), db, goose.DialectSQLite3, migrations.SQLite, "sqlite")
+}
@@ -0,0 +1,132 @@
+package database
+
+import (
+	"container/list"
+	"context"
+	"database/sql"
+	"log/slog"
+	"sync"
+)
+
+// stmtCache reuses prepared statements for read queries. Without it the driver
+// prepares, executes and closes a statement for every query with arguments,
+// so the list, get and version queries run on each request pay for a prepare
+// round trip every time. Statements are prepared on first use and up to size of
+// them kept, the least recently used closed to make room, so hot queries stay
+// prepared however many one-off filter and IN-list variants run.
+type stmtCache struct {
+	db   *sql.DB
+	size int
+
+	mu    sync.Mutex
+	stmts map[string]*list.Element // of *cachedStmt
+	lru   *list.List               // most recently used first
+}
+
+// cachedStmt is one prepared statement and the query it was prepared from
+type cachedStmt struct {
+	query string
+	stmt  *sql.Stmt
+
+	// users counts the callers between get and release; a statement evicted
+	// while in use is closed by its last user
+	users   int
+	evicted bool
+}
+
+// newStmtCache caches up to size statements on db; a size below one disables caching
+func newStmtCache(db *sql.DB, size int) *stmtCache {
+	if size < 1 {
+		return nil
+	}
+	return &stmtCache{db: db, size: size, stmts: make(map[string]*list.Element), lru: list.New()}
+}
+
+// get returns the prepared statement for query, preparing it on first use,
+// and the func to call once a query was started on it. The prepare round trip
+// runs without the lock held, so lookups of other queries are not held up by
+// it. It returns a nil statement when caching is disabled.
+func (c *stmtCache) get(ctx context.Context, query string) (*sql.Stmt, func(), error) {
+	if c == nil {
+		return nil, func() {}, nil
+	}
+
+	c.mu.Lock()
+	if e, ok := c.stmts[query]; ok {
+		defer c.mu.Unlock()
+		return c.use(e), c.releaser(e.Value.(*cachedStmt)), nil
+	}
+	c.mu.Unlock()
+
+	stmt, err := c.db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, func() {}, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Another caller may have prepared it meanwhile; theirs is kept
+	if e, ok := c.stmts[query]; ok {
+		closeStmt(stmt)
+		return c.use(e), c.releaser(e.Value.(*cachedStmt)), nil
+	}
+	e := c.lru.PushFront(&cachedStmt{query: query, stmt: stmt})
+	c.stmts[query] = e
+	if c.lru.Len() > c.size {
+		oldest := c.lru.Remove(c.lru.Back()).(*cachedStmt)
+		delete(c.stmts, oldest.query)
+		oldest.evicted = true
+		if oldest.users == 0 {
+			closeStmt(oldest.stmt)
+		}
+	}
+	return c.use(e), c.releaser(e.Value.(*cachedStmt)), nil
+}
+
+// use marks a cached statement as most recently used and in use; c.mu must be held
+func (c *stmtCache) use(e *list.Element) *sql.Stmt {
+	c.lru.MoveToFront(e)
+	cached := e.Value.(*cachedStmt)
+	cached.users++
+	return cached.stmt
+}
+
+// releaser returns the func ending a use of cached, which closes it when it was
+// evicted meanwhile. Rows already read from it keep it open until they are closed.
+func (c *stmtCache) releaser(cached *cachedStmt) func() {
+	return func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		cached.users--
+		if cached.evicted && cached.users == 0 {
+			closeStmt(cached.stmt)
+		}
+	}
+}
+
+// close closes every cached statement
+func (c *stmtCache) close() {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for e := c.lru.Front(); e != nil; e = e.Next() {
+		cached := e.Value.(*cachedStmt)
+		cached.evicted = true
+		if cached.users == 0 {
+			closeStmt(cached.stmt)
+		}
+	}
+	c.stmts = make(map[string]*list.Element)
+	c.lru.Init()
+}
+
+// closeStmt closes a statement, logging rather than returning its error
+func closeStmt(stmt *sql.Stmt) {
+	if err := stmt.Close(); err != nil {
+		slog.Warn("Error closing prepared statement", "error", err)
+	}
+}
@@ -0,0 +1,131 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"strings"
+)
+
+// Queries against tenant-owned tables must be written with a tenant placeholder,
+// which is expanded and bound to the caller's organization here:
+//
+//	{{tenant}}        -> org_id = ?
+//	{{tenant:alias}}  -> alias.org_id = ?
+//	{{tenant_id}}     -> ?   (for INSERT values)
+//
+// Running a query without a placeholder, or without an organization, fails rather
+// than silently returning every tenant's rows. TestTenantScopedQueries enforces
+// that every query touching a tenant table in this package uses a placeholder.
+var tenantPlaceholder = regexp.MustCompile(`\{\{tenant(_id|:[a-z_]+)?\}\}`)
+
+var (
+	// ErrMissingTenant is returned when a scoped query is run without an organization
+	ErrMissingTenant = errors.New("tenant-scoped query run without an organization")
+
+	// ErrMissingTenantFilter is returned when a scoped query has no tenant placeholder
+	ErrMissingTenantFilter = errors.New("tenant-scoped query has no tenant filter")
+)
+
+// querier is satisfied by both conn and txn
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) rowScanner
+}
+
+// rowScanner is the subset of *sql.Row used by callers, so errors can be deferred to Scan
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+	Err() error
+}
+
+// errRow is a rowScanner that always fails
+type errRow struct {
+	err error
+}
+
+// Scan implements rowScanner
+func (r errRow) Scan(...interface{}) error {
+	return r.err
+}
+
+// Err implements rowScanner
+func (r errRow) Err() error {
+	return r.err
+}
+
+// ScopeQuery expands tenant placeholders in query and binds orgID to each of them,
+// returning the rewritten query and its arguments in placeholder order
+func ScopeQuery(orgID, query string, args []interface{}) (string, []interface{}, error) {
+	if orgID == "" {
+		return "", nil, ErrMissingTenant
+	}
+
+	matches := tenantPlaceholder.FindAllStringSubmatchIndex(query, -1)
+	if len(matches) == 0 {
+		return "", nil, ErrMissingTenantFilter
+	}
+
+	var b strings.Builder
+	scoped := make([]interface{}, 0, len(args)+len(matches))
+	last, argIndex := 0, 0
+	for _, m := range matches {
+		// Carry over the arguments for the ? placeholders preceding this tenant placeholder
+		prefix := query[last:m[0]]
+		n := strings.Count(prefix, "?")
+		if argIndex+n > len(args) {
+			return "", nil, errors.New("tenant-scoped query has fewer arguments than placeholders")
+		}
+		scoped = append(scoped, args[argIndex:argIndex+n]...)
+		argIndex += n
+		b.WriteString(prefix)
+
+		suffix := ""
+		if m[2] >= 0 {
+			suffix = query[m[2]:m[3]]
+		}
+		switch {
+		case suffix == "":
+			b.WriteString("org_id = ?")
+		case suffix == "_id":
+			b.WriteString("?")
+		default:
+			b.WriteString(strings.TrimPrefix(suffix, ":") + ".org_id = ?")
+		}
+		scoped = append(scoped, orgID)
+		last = m[1]
+	}
+	b.WriteString(query[last:])
+	scoped = append(scoped, args[argIndex:]...)
+
+	return b.String(), scoped, nil
+}
+
+// tenantQuery runs a tenant-scoped query
+func tenantQuery(ctx context.Context, q querier, orgID, query string, args ...interface{}) (*sql.Rows, error) {
+	query, args, err := ScopeQuery(orgID, query, args)
+	if err != nil {
+		return nil, err
+	}
+	return q.QueryContext(ctx, query, args...)
+}
+
+// tenantQueryRow runs a tenant-scoped single-row query
+func tenantQueryRow(ctx context.Context, q querier, orgID, query string, args ...interface{}) rowScanner {
+	query, args, err := ScopeQuery(orgID, query, args)
+	if err != nil {
+		return errRow{err: err}
+	}
+	return q.QueryRowContext(ctx, query, args...)
+}
+
+// tenantExec runs a tenant-scoped statement
+func tenantExec(ctx context.Context, q querier, orgID, query string, args ...interface{}) (sql.Result, error) {
+	query, args, err := ScopeQuery(orgID, query, args)
+	if err != nil {
+		return nil, err
+	}
+	return q.ExecContext(ctx, query, args...)
+}
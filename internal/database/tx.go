@@ -0,0 +1,45 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+)
+
+// withTx runs fn in a transaction on the primary, committing when fn returns
+// nil and rolling back when it fails or panics. The whole transaction is rerun
+// on deadlocks and lock timeouts, so fn must not leave side effects outside tx
+// that a second attempt would duplicate. Dropped connections are not retried:
+// the commit may already have been applied.
+func (s *Store) withTx(ctx context.Context, fn func(tx *txn) error) error {
+	return s.db.retry.do(ctx, isLockConflict, func() error {
+		return s.runTx(ctx, fn)
+	})
+}
+
+// runTx runs one attempt of a withTx transaction
+func (s *Store) runTx(ctx context.Context, fn func(tx *txn) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	// A failed Commit ends the transaction too, so only roll back when fn did not get that far
+	done := false
+	defer func() {
+		if done {
+			return
+		}
+		if rollbackErr := tx.Rollback(); rollbackErr != nil && !errors.Is(rollbackErr, sql.ErrTxDone) {
+			slog.ErrorContext(ctx, "Error rolling back transaction", "error", rollbackErr)
+		}
+	}()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	done = true
+	return tx.Commit()
+}
@@ -1,85 +1,741 @@
 package database
 
 import (
-	"log"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
 
+	"github.com/yashjain/konnect/internal/auth"
 	"github.com/yashjain/konnect/internal/models"
+	"github.com/yashjain/konnect/internal/repository"
 	"github.com/yashjain/konnect/pkg/types"
 )
 
-// GetVersions retrieves paginated versions for a service
-func GetVersions(serviceID string, params types.PaginationParams) ([]models.Version, int, error) {
-	offset := (params.Page - 1) * params.PageSize
+// hasSpec is whether the version in the current versions row, aliased v, has an OpenAPI document
+const hasSpec = "EXISTS (SELECT 1 FROM version_specs sp WHERE sp.version_id = v.id)"
 
-	// Get total count for this service
-	var total int
-	err := DB.QueryRow("SELECT COUNT(*) FROM versions WHERE service_id = ?", serviceID).Scan(&total)
+// versionSpecScore is the lint score of the OpenAPI document of the version in
+// the current versions row, aliased v, NULL when it has none
+const versionSpecScore = "(SELECT sp.lint_score FROM version_specs sp WHERE sp.version_id = v.id)"
+
+// versionColumns are the columns scanVersion reads, in order, qualified by the
+// conventional alias v since version queries join services for tenant scoping.
+// Version queries always filter on v.service_id, the key versions are partitioned
+// by on MySQL and Postgres, so that they read a single partition.
+func (d *dialect) versionColumns() string {
+	return "v.id, v.service_id, v.semver, v.status, v.changelog, v.created_at, v.deleted_at, v.deprecated_at, v.sunset_at, v.released_at, v.metadata, " +
+		d.environmentList + " AS environments, " + hasSpec + " AS has_spec, " + versionSpecScore + " AS spec_score"
+}
+
+// scanVersion reads a row selected with versionColumns
+func scanVersion(row rowScanner) (models.Version, error) {
+	var v models.Version
+	var deletedAt, deprecatedAt, sunsetAt, releasedAt sql.NullTime
+	var metadata, environments sql.NullString
+	var specScore sql.NullInt64
+	err := row.Scan(&v.ID, &v.ServiceID, &v.Semver, &v.Status, &v.Changelog, &v.CreatedAt, &deletedAt, &deprecatedAt, &sunsetAt, &releasedAt, &metadata, &environments, &v.HasSpec, &specScore)
 	if err != nil {
-		return nil, 0, err
+		return v, err
+	}
+	v.SpecScore = intOrNil(specScore)
+	v.CreatedAt = v.CreatedAt.UTC()
+	v.DeletedAt, v.DeprecatedAt, v.SunsetAt = timeOrNil(deletedAt), timeOrNil(deprecatedAt), timeOrNil(sunsetAt)
+	v.ReleasedAt = timeOrNil(releasedAt)
+	v.Environments = []string{}
+	if environments.Valid && environments.String != "" {
+		v.Environments = strings.Split(environments.String, ",")
+		sort.Strings(v.Environments)
 	}
+	v.Metadata, err = decodeMetadata(metadata)
+	return v, err
+}
 
-	// Get paginated versions
-	query := "SELECT id, service_id, semver, status, changelog, created_at FROM versions WHERE service_id = ? ORDER BY created_at DESC LIMIT ? OFFSET ?"
-	rows, err := DB.Query(query, serviceID, params.PageSize, offset)
-	if err != nil {
-		return nil, 0, err
+// timeOrNil returns a nullable timestamp column in UTC, nil when it is NULL
+func timeOrNil(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
 	}
+	utc := t.Time.UTC()
+	return &utc
+}
+
+// scanVersions reads and closes rows selected with versionColumns
+func scanVersions(rows *sql.Rows) ([]models.Version, error) {
 	defer func() {
 		if err := rows.Close(); err != nil {
-			log.Printf("Error closing rows: %v", err)
+			slog.Warn("Error closing rows", "error", err)
 		}
 	}()
 
 	var versions []models.Version
 	for rows.Next() {
-		var v models.Version
-		err := rows.Scan(&v.ID, &v.ServiceID, &v.Semver, &v.Status, &v.Changelog, &v.CreatedAt)
+		version, err := scanVersion(rows)
 		if err != nil {
-			return nil, 0, err
+			return nil, err
 		}
-		versions = append(versions, v)
+		versions = append(versions, version)
 	}
 
-	return versions, total, nil
+	return versions, rows.Err()
 }
 
-// CreateVersion creates a new version for a service
-func CreateVersion(version *models.Version) error {
-	// Start a transaction to ensure atomicity
-	tx, err := DB.Begin()
+// GetVersions retrieves paginated versions for a service owned by an organization
+func (s *Store) GetVersions(ctx context.Context, orgID, serviceID string, params types.PaginationParams) ([]models.Version, int, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	limit, offset := pageWindow(params)
+	keyset, keysetArgs := keysetFilter(params.Cursor, "v.")
+	conditions, conditionArgs, err := s.db.dialect.filterClause(params.Filter, versionFilterColumns)
 	if err != nil {
-		return err
+		return nil, 0, err
 	}
+	described, describedArgs := s.db.dialect.metadataFilter(params.Metadata, "v.metadata")
+	deployed, deployedArgs := deployedVersionFilter(params.DeployedIn)
+	filter := notDeleted("v.", params.IncludeDeleted) + notDeleted("s.", params.IncludeDeleted) + conditions + described + deployed
+	countArgs := joinArgs([]interface{}{serviceID}, conditionArgs, describedArgs, deployedArgs)
 
-	// Track if transaction was committed
-	committed := false
-	defer func() {
-		if !committed {
-			if err := tx.Rollback(); err != nil {
-				log.Printf("Error rolling back transaction: %v", err)
+	countQuery := "SELECT COUNT(*) FROM versions v JOIN services s ON s.id = v.service_id WHERE v.service_id = ? AND {{tenant:s}}" + filter
+	pageQuery := `
+		SELECT ` + s.db.dialect.versionColumns() + `
+		FROM versions v
+		JOIN services s ON s.id = v.service_id
+		WHERE v.service_id = ? AND {{tenant:s}}` + filter + keyset + `
+		ORDER BY v.created_at DESC, v.id DESC
+		LIMIT ? OFFSET ?`
+	pageArgs := joinArgs(countArgs, keysetArgs, []interface{}{limit, offset})
+
+	return pageAndCount(ctx, params.SkipCount,
+		func(ctx context.Context) (int, error) {
+			var total int
+			err := tenantQueryRow(ctx, s.read, orgID, countQuery, countArgs...).Scan(&total)
+			return total, err
+		},
+		func(ctx context.Context) ([]models.Version, error) {
+			rows, err := tenantQuery(ctx, s.read, orgID, pageQuery, pageArgs...)
+			if err != nil {
+				return nil, err
 			}
-		}
-	}()
+			return scanVersions(rows)
+		})
+}
+
+// SearchVersions returns up to limit versions, newest first, whose semver or
+// changelog contains query, among the services visible to a principal. Versions
+// have no full-text index, so this is a substring match like the short-query
+// fallback of SearchServices.
+func (s *Store) SearchVersions(ctx context.Context, p auth.Principal, query string, limit int) ([]models.Version, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	like := s.db.dialect.like
+	match := "(v.semver " + like + " ? ESCAPE '" + likeEscape + "' OR v.changelog " + like + " ? ESCAPE '" + likeEscape + "')"
+	pattern := "%" + escapeLike(query) + "%"
+
+	// visibilityFilter's columns are unqualified, so it filters a subquery on services alone
+	filter, filterArgs := visibilityFilter(p)
+	if filter != "" {
+		filter = " AND s.id IN (SELECT id FROM services WHERE {{tenant}}" + filter + ")"
+	}
+
+	searchQuery := `
+		SELECT ` + s.db.dialect.versionColumns() + `
+		FROM versions v
+		JOIN services s ON s.id = v.service_id
+		WHERE {{tenant:s}} AND v.deleted_at IS NULL AND s.deleted_at IS NULL AND ` + match + filter + `
+		ORDER BY v.created_at DESC, v.id DESC
+		LIMIT ?`
+	args := joinArgs([]interface{}{pattern, pattern}, filterArgs, []interface{}{limit})
+
+	rows, err := tenantQuery(ctx, s.read, p.OrgID, searchQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	return scanVersions(rows)
+}
+
+// GetReleasedVersions returns up to limit released versions of the services
+// visible to a principal, or of one of them when serviceID is set, most
+// recently released first
+func (s *Store) GetReleasedVersions(ctx context.Context, p auth.Principal, serviceID string, limit int) ([]models.Version, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	filter, filterArgs := visibilityFilter(p)
+	if filter != "" {
+		filter = " AND s.id IN (SELECT id FROM services WHERE {{tenant}}" + filter + ")"
+	}
+	if serviceID != "" {
+		filter += " AND s.id = ?"
+		filterArgs = append(filterArgs, serviceID)
+	}
+
+	query := `
+		SELECT ` + s.db.dialect.versionColumns() + `
+		FROM versions v
+		JOIN services s ON s.id = v.service_id
+		WHERE {{tenant:s}} AND v.status = ? AND v.deleted_at IS NULL AND s.deleted_at IS NULL` + filter + `
+		ORDER BY v.released_at DESC, v.id DESC
+		LIMIT ?`
+	args := joinArgs([]interface{}{models.VersionReleased}, filterArgs, []interface{}{limit})
+
+	rows, err := tenantQuery(ctx, s.read, p.OrgID, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return scanVersions(rows)
+}
+
+// GetDeprecationCalendar returns up to limit versions of the services visible
+// to a principal that are deprecated or sunset no earlier than since, soonest
+// first. They are the versions a team consumes when teamID is set, those of a
+// service when serviceID is, and otherwise those of the services the
+// principal's user is subscribed to.
+func (s *Store) GetDeprecationCalendar(ctx context.Context, p auth.Principal, teamID, serviceID string, since time.Time, limit int) ([]models.Version, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var scope string
+	var scopeArgs []interface{}
+	switch {
+	case teamID != "":
+		scope = ` AND EXISTS (SELECT 1 FROM consumers c
+			WHERE {{tenant:c}} AND c.service_id = v.service_id AND c.team_id = ? AND (c.version_id IS NULL OR c.version_id = v.id))`
+		scopeArgs = []interface{}{teamID}
+	case serviceID != "":
+		scope = " AND v.service_id = ?"
+		scopeArgs = []interface{}{serviceID}
+	default:
+		scope = " AND v.service_id IN (SELECT service_id FROM service_subscribers WHERE {{tenant}} AND user_id = ?)"
+		scopeArgs = []interface{}{p.UserID}
+	}
+	filter, filterArgs := visibilityFilter(p)
+	if filter != "" {
+		filter = " AND s.id IN (SELECT id FROM services WHERE {{tenant}}" + filter + ")"
+	}
+
+	query := `
+		SELECT ` + s.db.dialect.versionColumns() + `
+		FROM versions v
+		JOIN services s ON s.id = v.service_id
+		WHERE {{tenant:s}} AND (v.deprecated_at >= ? OR v.sunset_at >= ?) AND v.deleted_at IS NULL AND s.deleted_at IS NULL` + scope + filter + `
+		ORDER BY COALESCE(v.deprecated_at, v.sunset_at), v.id
+		LIMIT ?`
+	args := joinArgs([]interface{}{since, since}, scopeArgs, filterArgs, []interface{}{limit})
+
+	rows, err := tenantQuery(ctx, s.read, p.OrgID, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return scanVersions(rows)
+}
+
+// CreateVersion creates a new version for a service owned by an organization.
+// It returns sql.ErrNoRows when the service does not exist in that organization
+// or has been soft-deleted.
+func (s *Store) CreateVersion(ctx context.Context, orgID string, version *models.Version) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
 
-	// Insert the version
-	_, err = tx.Exec("INSERT INTO versions (id, service_id, semver, status, changelog) VALUES (?, ?, ?, ?, ?)",
-		version.ID, version.ServiceID, version.Semver, version.Status, version.Changelog)
+	version.CreatedAt = timestamp()
+	version.DeprecatedAt, version.SunsetAt, version.ReleasedAt = nil, nil, nil
+	switch version.Status {
+	case models.VersionDeprecated:
+		version.DeprecatedAt = &version.CreatedAt
+	case models.VersionReleased:
+		version.ReleasedAt = &version.CreatedAt
+	}
+	version.Environments = []string{}
+	if version.Metadata == nil {
+		version.Metadata = map[string]string{}
+	}
+	metadata, err := encodeMetadata(version.Metadata)
 	if err != nil {
 		return err
 	}
 
-	// Update the versions_count in the services table
-	_, err = tx.Exec("UPDATE services SET versions_count = versions_count + 1 WHERE id = ?", version.ServiceID)
+	return s.withTx(ctx, func(tx *txn) error {
+		// Lock the parent service, which also verifies it belongs to the organization
+		var serviceID string
+		err := tenantQueryRow(ctx, tx, orgID, "SELECT id FROM services WHERE id = ? AND {{tenant}} AND deleted_at IS NULL"+s.db.dialect.forUpdate, version.ServiceID).Scan(&serviceID)
+		if err != nil {
+			return err
+		}
+
+		// Insert the version
+		_, err = tenantExec(ctx, tx, orgID, `
+			INSERT INTO versions (id, service_id, semver, status, changelog, metadata, created_at, deprecated_at, released_at)
+			SELECT ?, id, ?, ?, ?, ?, ?, ?, ? FROM services WHERE id = ? AND {{tenant}}`,
+			version.ID, version.Semver, version.Status, version.Changelog, metadata, version.CreatedAt, nullTime(version.DeprecatedAt), nullTime(version.ReleasedAt), version.ServiceID)
+		if err != nil {
+			return err
+		}
+		if err := s.queueKongSync(ctx, tx, orgID, version.ServiceID); err != nil {
+			return err
+		}
+		if err := s.recordEvent(ctx, tx, orgID, models.EventVersionCreated, version.ID, version); err != nil {
+			return err
+		}
+		return s.recordStatusEvent(ctx, tx, orgID, "", *version)
+	})
+}
+
+// GetVersionBySemver retrieves the newest version of a service owned by an
+// organization with the given semver. Semvers are not unique, so a service may
+// have several versions with the same one.
+func (s *Store) GetVersionBySemver(ctx context.Context, orgID, serviceID, semver string) (*models.Version, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT ` + s.db.dialect.versionColumns() + `
+		FROM versions v
+		JOIN services s ON s.id = v.service_id
+		WHERE v.service_id = ? AND v.semver = ? AND {{tenant:s}} AND v.deleted_at IS NULL AND s.deleted_at IS NULL
+		ORDER BY v.created_at DESC, v.id DESC
+		LIMIT 1`
+	version, err := scanVersion(tenantQueryRow(ctx, s.read, orgID, query, serviceID, semver))
 	if err != nil {
+		return nil, err
+	}
+	return &version, nil
+}
+
+// GetVersion returns a version of a service owned by an organization, or
+// sql.ErrNoRows. Soft-deleted versions and versions of soft-deleted services are
+// not returned.
+func (s *Store) GetVersion(ctx context.Context, orgID, serviceID, id string) (*models.Version, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT ` + s.db.dialect.versionColumns() + `
+		FROM versions v
+		JOIN services s ON s.id = v.service_id
+		WHERE v.service_id = ? AND v.id = ? AND {{tenant:s}} AND v.deleted_at IS NULL AND s.deleted_at IS NULL`
+	version, err := scanVersion(tenantQueryRow(ctx, s.read, orgID, query, serviceID, id))
+	if err != nil {
+		return nil, err
+	}
+	return &version, nil
+}
+
+// UpdateVersion sets the status, changelog and metadata of a version of a
+// service owned by an organization, then reads the version back into version.
+// Nil metadata leaves the current metadata unchanged. Deprecating a version
+// stamps its deprecated_at, replacing any scheduled date, and taking it out of
+// deprecated clears its deprecation and sunset dates. Soft-deleted versions and
+// versions of soft-deleted services are not updated.
+func (s *Store) UpdateVersion(ctx context.Context, orgID, serviceID, id string, version *models.Version) (int64, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	metadata, err := encodeMetadata(version.Metadata)
+	if err != nil {
+		return 0, err
+	}
+
+	var rowsAffected int64
+	err = s.withTx(ctx, func(tx *txn) error {
+		// The status before the update tells whether the version was just released or deprecated
+		var previous string
+		var deprecatedAt, sunsetAt, releasedAt sql.NullTime
+		err := tenantQueryRow(ctx, tx, orgID, `
+			SELECT status, deprecated_at, sunset_at, released_at FROM versions
+			WHERE id = ? AND service_id = ? AND deleted_at IS NULL
+				AND service_id IN (SELECT id FROM services WHERE {{tenant}} AND deleted_at IS NULL)`+s.db.dialect.forUpdate,
+			id, serviceID).Scan(&previous, &deprecatedAt, &sunsetAt, &releasedAt)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		deprecatedAt, sunsetAt = deprecationDates(previous, version.Status, deprecatedAt, sunsetAt)
+		// A version is released once; releasing it again keeps its first date
+		if version.Status == models.VersionReleased && !releasedAt.Valid {
+			releasedAt = sql.NullTime{Time: timestamp(), Valid: true}
+		}
+		result, err := tenantExec(ctx, tx, orgID, `
+			UPDATE versions SET status = ?, changelog = ?, metadata = COALESCE(?, metadata), deprecated_at = ?, sunset_at = ?, released_at = ?
+			WHERE id = ? AND service_id = ? AND deleted_at IS NULL
+				AND service_id IN (SELECT id FROM services WHERE {{tenant}} AND deleted_at IS NULL)`,
+			version.Status, version.Changelog, metadata, deprecatedAt, sunsetAt, releasedAt, id, serviceID)
+		if err != nil {
+			return err
+		}
+		if rowsAffected, err = result.RowsAffected(); err != nil || rowsAffected == 0 {
+			return err
+		}
+
+		updated, err := s.readVersion(ctx, tx, orgID, serviceID, id)
+		if err != nil {
+			return err
+		}
+		*version = updated
+		if err := s.queueKongSync(ctx, tx, orgID, serviceID); err != nil {
+			return err
+		}
+		if err := s.recordEvent(ctx, tx, orgID, models.EventVersionUpdated, id, updated); err != nil {
+			return err
+		}
+		return s.recordStatusEvent(ctx, tx, orgID, previous, updated)
+	})
+	return rowsAffected, err
+}
+
+// deprecationDates returns the deprecation and sunset dates of a version as
+// its status changes from previous to status: deprecating it dates it now, and
+// undeprecating it clears both dates
+func deprecationDates(previous, status string, deprecatedAt, sunsetAt sql.NullTime) (sql.NullTime, sql.NullTime) {
+	switch {
+	case status == previous:
+		return deprecatedAt, sunsetAt
+	case status == models.VersionDeprecated:
+		return sql.NullTime{Time: timestamp(), Valid: true}, sunsetAt
+	case previous == models.VersionDeprecated:
+		return sql.NullTime{}, sql.NullTime{}
+	}
+	return deprecatedAt, sunsetAt
+}
+
+// ScheduleDeprecation sets when a version of a service owned by an organization
+// is deprecated and sunset, then reads the version back. A version is
+// deprecated right away when its deprecatedAt is not in the future; otherwise
+// ApplyDueDeprecations deprecates it once the date passes. Versions already
+// deprecated keep their deprecation date and only change their sunset date. It
+// returns sql.ErrNoRows when the version is not in the organization, and
+// repository.ErrInvalidSchedule when sunsetAt is not after the deprecation.
+func (s *Store) ScheduleDeprecation(ctx context.Context, orgID, serviceID, id string, deprecatedAt time.Time, sunsetAt *time.Time) (*models.Version, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var version models.Version
+	err := s.withTx(ctx, func(tx *txn) error {
+		current, err := s.lockVersion(ctx, tx, orgID, serviceID, id)
+		if err != nil {
+			return err
+		}
+		if current.Status == models.VersionDeprecated && current.DeprecatedAt != nil {
+			deprecatedAt = *current.DeprecatedAt
+		}
+		if sunsetAt != nil && !sunsetAt.After(deprecatedAt) {
+			return repository.ErrInvalidSchedule
+		}
+
+		_, err = tenantExec(ctx, tx, orgID, `
+			UPDATE versions SET deprecated_at = ?, sunset_at = ?
+			WHERE id = ? AND service_id = ? AND service_id IN (SELECT id FROM services WHERE {{tenant}})`,
+			deprecatedAt, nullTime(sunsetAt), id, serviceID)
+		if err != nil {
+			return err
+		}
+		// A new sunset date is reminded of anew
+		if !sameTime(current.SunsetAt, sunsetAt) {
+			_, err = tenantExec(ctx, tx, orgID, `
+				UPDATE versions SET sunset_reminded_at = NULL
+				WHERE id = ? AND service_id = ? AND service_id IN (SELECT id FROM services WHERE {{tenant}})`,
+				id, serviceID)
+			if err != nil {
+				return err
+			}
+		}
+		if !deprecatedAt.After(timestamp()) {
+			if _, err := s.deprecateVersion(ctx, tx, orgID, current); err != nil {
+				return err
+			}
+		}
+		version, err = s.readVersion(ctx, tx, orgID, serviceID, id)
 		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &version, nil
+}
+
+// CancelDeprecation clears the deprecation and sunset dates of a version of a
+// service owned by an organization that is scheduled to be deprecated, and
+// returns the number of versions changed. Deprecated versions are not changed.
+func (s *Store) CancelDeprecation(ctx context.Context, orgID, serviceID, id string) (int64, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var rowsAffected int64
+	err := s.withTx(ctx, func(tx *txn) error {
+		result, err := tenantExec(ctx, tx, orgID, `
+			UPDATE versions SET deprecated_at = NULL, sunset_at = NULL
+			WHERE id = ? AND service_id = ? AND status <> ? AND deprecated_at IS NOT NULL AND deleted_at IS NULL
+				AND service_id IN (SELECT id FROM services WHERE {{tenant}} AND deleted_at IS NULL)`,
+			id, serviceID, models.VersionDeprecated)
+		if err != nil {
+			return err
+		}
+		if rowsAffected, err = result.RowsAffected(); err != nil || rowsAffected == 0 {
+			return err
+		}
+		updated, err := s.readVersion(ctx, tx, orgID, serviceID, id)
+		if err != nil {
+			return err
+		}
+		return s.recordEvent(ctx, tx, orgID, models.EventVersionUpdated, id, updated)
+	})
+	return rowsAffected, err
+}
+
+// ApplyDueDeprecations deprecates up to limit versions whose scheduled
+// deprecation is due at now, across all organizations, and returns how many it
+// deprecated. Each is deprecated in a transaction of its own, recording its
+// events and queueing its emails like UpdateVersion; versions deprecated
+// meanwhile, such as by another instance, are skipped. A version that fails is
+// logged and skipped too, so it does not hold up those due after it, and the
+// failures are returned joined once the others are done.
+func (s *Store) ApplyDueDeprecations(ctx context.Context, now time.Time, limit int) (int, error) {
+	due, err := s.dueDeprecations(ctx, now, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	deprecated := 0
+	var failures []error
+	for _, v := range due {
+		ok, err := s.applyDeprecation(ctx, v)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			if ctx.Err() != nil {
+				return deprecated, errors.Join(append(failures, err)...)
+			}
+			slog.WarnContext(ctx, "Scheduled deprecation failed", "org_id", v.orgID, "version_id", v.id, "error", err)
+			failures = append(failures, fmt.Errorf("deprecating version %s: %w", v.id, err))
+			continue
+		}
+		if ok {
+			deprecated++
+		}
 	}
+	return deprecated, errors.Join(failures...)
+}
+
+// dueVersion is a version due to be deprecated
+type dueVersion struct {
+	orgID, serviceID, id string
+}
 
-	// Commit the transaction
-	err = tx.Commit()
+// dueDeprecations returns up to limit versions due to be deprecated at now,
+// earliest first.
+// tenant:exempt the scheduler deprecates the versions of every organization.
+func (s *Store) dueDeprecations(ctx context.Context, now time.Time, limit int) ([]dueVersion, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT s.org_id, v.service_id, v.id
+		FROM versions v
+		JOIN services s ON s.id = v.service_id
+		WHERE v.deprecated_at <= ? AND v.status <> ? AND v.deleted_at IS NULL AND s.deleted_at IS NULL
+		ORDER BY v.deprecated_at, v.id
+		LIMIT ?`,
+		now, models.VersionDeprecated, limit)
 	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.WarnContext(ctx, "Error closing rows", "error", err)
+		}
+	}()
+
+	var due []dueVersion
+	for rows.Next() {
+		var v dueVersion
+		if err := rows.Scan(&v.orgID, &v.serviceID, &v.id); err != nil {
+			return nil, err
+		}
+		due = append(due, v)
+	}
+	return due, rows.Err()
+}
+
+// applyDeprecation deprecates a due version in a transaction of its own,
+// reporting false when it was deprecated meanwhile
+func (s *Store) applyDeprecation(ctx context.Context, v dueVersion) (bool, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var deprecated bool
+	err := s.withTx(ctx, func(tx *txn) error {
+		current, err := s.lockVersion(ctx, tx, v.orgID, v.serviceID, v.id)
+		if err != nil {
+			return err
+		}
+		deprecated, err = s.deprecateVersion(ctx, tx, v.orgID, current)
 		return err
+	})
+	return deprecated && err == nil, err
+}
+
+// QueueSunsetReminders reminds the recipients of the deprecation emails of up
+// to limit deprecated versions, across all organizations, that their sunset
+// date approaches: it falls after now and no later than before. Each version's
+// recipients are reminded once, in a transaction of its own that marks the
+// version reminded, and it returns how many versions were. Failures are
+// skipped and returned joined like in ApplyDueDeprecations.
+func (s *Store) QueueSunsetReminders(ctx context.Context, now, before time.Time, limit int) (int, error) {
+	due, err := s.dueSunsetReminders(ctx, now, before, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	reminded := 0
+	var failures []error
+	for _, v := range due {
+		ok, err := s.remindSunset(ctx, v)
+		if err != nil {
+			if ctx.Err() != nil {
+				return reminded, errors.Join(append(failures, err)...)
+			}
+			slog.WarnContext(ctx, "Sunset reminder failed", "org_id", v.orgID, "version_id", v.id, "error", err)
+			failures = append(failures, fmt.Errorf("reminding of the sunset of version %s: %w", v.id, err))
+			continue
+		}
+		if ok {
+			reminded++
+		}
+	}
+	return reminded, errors.Join(failures...)
+}
+
+// dueSunsetReminders returns up to limit deprecated versions not yet reminded
+// of whose sunset date falls after now and no later than before, soonest first.
+// tenant:exempt the scheduler reminds of the versions of every organization.
+func (s *Store) dueSunsetReminders(ctx context.Context, now, before time.Time, limit int) ([]dueVersion, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT s.org_id, v.service_id, v.id
+		FROM versions v
+		JOIN services s ON s.id = v.service_id
+		WHERE v.sunset_at > ? AND v.sunset_at <= ? AND v.sunset_reminded_at IS NULL AND v.status = ?
+			AND v.deleted_at IS NULL AND s.deleted_at IS NULL
+		ORDER BY v.sunset_at, v.id
+		LIMIT ?`,
+		now, before, models.VersionDeprecated, limit)
+	if err != nil {
+		return nil, err
 	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.WarnContext(ctx, "Error closing rows", "error", err)
+		}
+	}()
+
+	var due []dueVersion
+	for rows.Next() {
+		var v dueVersion
+		if err := rows.Scan(&v.orgID, &v.serviceID, &v.id); err != nil {
+			return nil, err
+		}
+		due = append(due, v)
+	}
+	return due, rows.Err()
+}
+
+// remindSunset marks a version reminded of and queues its reminders in a
+// transaction of its own, reporting false when it was reminded of meanwhile
+func (s *Store) remindSunset(ctx context.Context, v dueVersion) (bool, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var reminded bool
+	err := s.withTx(ctx, func(tx *txn) error {
+		result, err := tenantExec(ctx, tx, v.orgID, `
+			UPDATE versions SET sunset_reminded_at = ?
+			WHERE id = ? AND service_id = ? AND sunset_reminded_at IS NULL AND status = ? AND deleted_at IS NULL
+				AND service_id IN (SELECT id FROM services WHERE {{tenant}} AND deleted_at IS NULL)`,
+			timestamp(), v.id, v.serviceID, models.VersionDeprecated)
+		if err != nil {
+			return err
+		}
+		rowsAffected, err := result.RowsAffected()
+		if err != nil || rowsAffected == 0 {
+			reminded = false
+			return err
+		}
+
+		version, err := s.readVersion(ctx, tx, v.orgID, v.serviceID, v.id)
+		if err != nil {
+			return err
+		}
+		reminded = true
+		return s.queueSunsetReminders(ctx, tx, v.orgID, version)
+	})
+	return reminded && err == nil, err
+}
+
+// sameTime is whether two optional times are both nil or the same instant
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+// lockVersion locks a version of a service owned by an organization and reads
+// it, returning sql.ErrNoRows when it or its service is missing or soft-deleted
+func (s *Store) lockVersion(ctx context.Context, tx *txn, orgID, serviceID, id string) (models.Version, error) {
+	var version models.Version
+	var deprecatedAt, sunsetAt sql.NullTime
+	err := tenantQueryRow(ctx, tx, orgID, `
+		SELECT id, service_id, status, deprecated_at, sunset_at FROM versions
+		WHERE id = ? AND service_id = ? AND deleted_at IS NULL
+			AND service_id IN (SELECT id FROM services WHERE {{tenant}} AND deleted_at IS NULL)`+s.db.dialect.forUpdate,
+		id, serviceID).Scan(&version.ID, &version.ServiceID, &version.Status, &deprecatedAt, &sunsetAt)
+	version.DeprecatedAt = timeOrNil(deprecatedAt)
+	version.SunsetAt = timeOrNil(sunsetAt)
+	return version, err
+}
+
+// deprecateVersion flips a version locked by lockVersion to deprecated, keeping
+// any deprecation date it was scheduled for, and records the change like
+// UpdateVersion. It reports false when the version was deprecated already.
+func (s *Store) deprecateVersion(ctx context.Context, tx *txn, orgID string, current models.Version) (bool, error) {
+	if current.Status == models.VersionDeprecated {
+		return false, nil
+	}
+	serviceID, id := current.ServiceID, current.ID
+	_, err := tenantExec(ctx, tx, orgID, `
+		UPDATE versions SET status = ?, deprecated_at = COALESCE(deprecated_at, ?)
+		WHERE id = ? AND service_id = ? AND service_id IN (SELECT id FROM services WHERE {{tenant}})`,
+		models.VersionDeprecated, timestamp(), id, serviceID)
+	if err != nil {
+		return false, err
+	}
+
+	updated, err := s.readVersion(ctx, tx, orgID, serviceID, id)
+	if err != nil {
+		return false, err
+	}
+	if err := s.queueKongSync(ctx, tx, orgID, serviceID); err != nil {
+		return false, err
+	}
+	if err := s.recordEvent(ctx, tx, orgID, models.EventVersionUpdated, id, updated); err != nil {
+		return false, err
+	}
+	return true, s.recordStatusEvent(ctx, tx, orgID, current.Status, updated)
+}
 
-	committed = true
-	return nil
+// readVersion reads a version of a service owned by an organization within a
+// transaction, whether or not it is soft-deleted
+func (s *Store) readVersion(ctx context.Context, tx *txn, orgID, serviceID, id string) (models.Version, error) {
+	return scanVersion(tenantQueryRow(ctx, tx, orgID, `
+		SELECT `+s.db.dialect.versionColumns()+`
+		FROM versions v
+		JOIN services s ON s.id = v.service_id
+		WHERE v.id = ? AND v.service_id = ? AND {{tenant:s}}`, id, serviceID))
 }
@@ -0,0 +1,318 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/yashjain/konnect/internal/models"
+)
+
+// subscriptionColumns are the webhook_subscriptions columns read by scanSubscription
+const subscriptionColumns = "id, org_id, url, event_types, active, format, template, created_at, updated_at"
+
+// deliveryColumns are the webhook_deliveries columns read by scanDelivery
+const deliveryColumns = "d.id, d.subscription_id, d.event_id, d.event_type, d.payload, d.status, d.attempts, d.response_status, d.last_error, d.next_attempt_at, d.delivered_at, d.created_at"
+
+// subscribedWebhooks returns the IDs of an organization's subscriptions to eventType,
+// including paused ones, whose deliveries wait until they are resumed
+func subscribedWebhooks(ctx context.Context, q querier, orgID, eventType string) ([]string, error) {
+	rows, err := tenantQuery(ctx, q, orgID, "SELECT id, event_types FROM webhook_subscriptions WHERE {{tenant}}")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.WarnContext(ctx, "Error closing rows", "error", err)
+		}
+	}()
+
+	var ids []string
+	for rows.Next() {
+		var id, eventTypes string
+		if err := rows.Scan(&id, &eventTypes); err != nil {
+			return nil, err
+		}
+		for _, t := range strings.Split(eventTypes, ",") {
+			if t == eventType {
+				ids = append(ids, id)
+				break
+			}
+		}
+	}
+	return ids, rows.Err()
+}
+
+// queueWebhookDeliveries writes a pending delivery of event, due now, to each of subscriptions
+func queueWebhookDeliveries(ctx context.Context, q querier, event models.Event, subscriptions []string) error {
+	if len(subscriptions) == 0 {
+		return nil
+	}
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	for _, subscriptionID := range subscriptions {
+		_, err := tenantExec(ctx, q, event.OrgID, `
+			INSERT INTO webhook_deliveries (id, org_id, subscription_id, event_id, event_type, payload, status, next_attempt_at, created_at)
+			VALUES (?, {{tenant_id}}, ?, ?, ?, ?, ?, ?, ?)`,
+			uuid.New().String(), subscriptionID, event.ID, event.Type, string(body), models.DeliveryPending, event.CreatedAt, event.CreatedAt)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CreateWebhookSubscription creates a subscription within an organization
+func (s *Store) CreateWebhookSubscription(ctx context.Context, sub *models.WebhookSubscription) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	sub.CreatedAt = timestamp()
+	sub.UpdatedAt = sub.CreatedAt
+	_, err := tenantExec(ctx, s.db, sub.OrgID, `
+		INSERT INTO webhook_subscriptions (id, org_id, url, secret, event_types, active, format, template, created_at, updated_at)
+		VALUES (?, {{tenant_id}}, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		sub.ID, sub.URL, EncryptedString(sub.Secret), strings.Join(sub.EventTypes, ","), sub.Active == nil || *sub.Active,
+		sub.Format, nullString(sub.Template), sub.CreatedAt, sub.UpdatedAt)
+	return err
+}
+
+// GetWebhookSubscriptions lists an organization's subscriptions, oldest first, without their secrets
+func (s *Store) GetWebhookSubscriptions(ctx context.Context, orgID string) ([]models.WebhookSubscription, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := tenantQuery(ctx, s.read, orgID, "SELECT "+subscriptionColumns+" FROM webhook_subscriptions WHERE {{tenant}} ORDER BY created_at, id")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.WarnContext(ctx, "Error closing rows", "error", err)
+		}
+	}()
+
+	subs := []models.WebhookSubscription{}
+	for rows.Next() {
+		sub, err := scanSubscription(rows)
+		if err != nil {
+			return nil, err
+		}
+		subs = append(subs, *sub)
+	}
+	return subs, rows.Err()
+}
+
+// GetWebhookSubscription returns a subscription within an organization, with its secret
+func (s *Store) GetWebhookSubscription(ctx context.Context, orgID, id string) (*models.WebhookSubscription, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var secret EncryptedString
+	sub, err := scanSubscription(tenantQueryRow(ctx, s.db, orgID, "SELECT "+subscriptionColumns+", secret FROM webhook_subscriptions WHERE id = ? AND {{tenant}}", id), &secret)
+	if err != nil {
+		return nil, err
+	}
+	sub.Secret = string(secret)
+	return sub, nil
+}
+
+// UpdateWebhookSubscription replaces a subscription's URL, event types and
+// template, and its active flag, secret and format when they are set
+func (s *Store) UpdateWebhookSubscription(ctx context.Context, orgID, id string, sub *models.WebhookSubscription) (int64, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var active, secret interface{}
+	if sub.Active != nil {
+		active = *sub.Active
+	}
+	if sub.Secret != "" {
+		secret = EncryptedString(sub.Secret)
+	}
+
+	sub.UpdatedAt = timestamp()
+	result, err := tenantExec(ctx, s.db, orgID, `
+		UPDATE webhook_subscriptions SET url = ?, event_types = ?, active = COALESCE(?, active), secret = COALESCE(?, secret),
+			format = COALESCE(NULLIF(?, ''), format), template = ?, updated_at = ?
+		WHERE id = ? AND {{tenant}}`,
+		sub.URL, strings.Join(sub.EventTypes, ","), active, secret, sub.Format, nullString(sub.Template), sub.UpdatedAt, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// DeleteWebhookSubscription deletes a subscription within an organization; its
+// deliveries are deleted with it
+func (s *Store) DeleteWebhookSubscription(ctx context.Context, orgID, id string) (int64, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	result, err := tenantExec(ctx, s.db, orgID, "DELETE FROM webhook_subscriptions WHERE id = ? AND {{tenant}}", id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// GetWebhookDeliveries lists up to limit deliveries to a subscription within an organization, newest first
+func (s *Store) GetWebhookDeliveries(ctx context.Context, orgID, subscriptionID string, limit int) ([]models.WebhookDelivery, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := tenantQuery(ctx, s.read, orgID, "SELECT "+deliveryColumns+" FROM webhook_deliveries d WHERE d.subscription_id = ? AND {{tenant:d}} ORDER BY d.created_at DESC, d.id LIMIT ?", subscriptionID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.WarnContext(ctx, "Error closing rows", "error", err)
+		}
+	}()
+
+	deliveries := []models.WebhookDelivery{}
+	for rows.Next() {
+		d, err := scanDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, *d)
+	}
+	return deliveries, rows.Err()
+}
+
+// CreateWebhookDelivery records a delivery to a subscription within an organization
+func (s *Store) CreateWebhookDelivery(ctx context.Context, orgID string, d *models.WebhookDelivery) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	_, err := tenantExec(ctx, s.db, orgID, `
+		INSERT INTO webhook_deliveries (id, org_id, subscription_id, event_id, event_type, payload, status, attempts, response_status, last_error, next_attempt_at, delivered_at, created_at)
+		VALUES (?, {{tenant_id}}, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		d.ID, d.SubscriptionID, d.EventID, d.EventType, string(d.Payload), d.Status, d.Attempts,
+		nullInt(d.ResponseStatus), nullString(d.LastError), nullTime(d.NextAttemptAt), nullTime(d.DeliveredAt), d.CreatedAt)
+	return err
+}
+
+// GetDueWebhookDeliveries returns up to limit pending deliveries to active
+// subscriptions that are due at now, oldest first.
+// tenant:exempt the dispatcher delivers the webhooks of every organization.
+func (s *Store) GetDueWebhookDeliveries(ctx context.Context, now time.Time, limit int) ([]models.WebhookDelivery, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+deliveryColumns+`, s.url, s.secret, s.format, s.template
+		FROM webhook_deliveries d JOIN webhook_subscriptions s ON s.id = d.subscription_id
+		WHERE d.status = ? AND d.next_attempt_at <= ? AND s.active = ?
+		ORDER BY d.created_at, d.id LIMIT ?`,
+		models.DeliveryPending, now, true, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.WarnContext(ctx, "Error closing rows", "error", err)
+		}
+	}()
+
+	var deliveries []models.WebhookDelivery
+	for rows.Next() {
+		var url, format string
+		var secret EncryptedString
+		var template sql.NullString
+		d, err := scanDelivery(rows, &url, &secret, &format, &template)
+		if err != nil {
+			return nil, err
+		}
+		d.URL, d.Secret, d.Format, d.Template = url, string(secret), format, template.String
+		deliveries = append(deliveries, *d)
+	}
+	return deliveries, rows.Err()
+}
+
+// UpdateWebhookDelivery records the outcome of an attempt to deliver a webhook.
+// tenant:exempt deliveries are addressed by their globally unique ID.
+func (s *Store) UpdateWebhookDelivery(ctx context.Context, d *models.WebhookDelivery) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE webhook_deliveries SET status = ?, attempts = ?, response_status = ?, last_error = ?, next_attempt_at = ?, delivered_at = ?
+		WHERE id = ?`,
+		d.Status, d.Attempts, nullInt(d.ResponseStatus), nullString(d.LastError), nullTime(d.NextAttemptAt), nullTime(d.DeliveredAt), d.ID)
+	return err
+}
+
+// PruneWebhookDeliveries removes delivered and failed deliveries created before a cutoff.
+// tenant:exempt retention applies to every organization.
+func (s *Store) PruneWebhookDeliveries(ctx context.Context, before time.Time) (int64, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	result, err := s.db.ExecContext(ctx, "DELETE FROM webhook_deliveries WHERE status <> ? AND created_at < ?", models.DeliveryPending, before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// scanSubscription scans a subscription row, followed by any extra columns into extra
+func scanSubscription(row rowScanner, extra ...interface{}) (*models.WebhookSubscription, error) {
+	var sub models.WebhookSubscription
+	var eventTypes string
+	var active bool
+	var template sql.NullString
+	dest := append([]interface{}{&sub.ID, &sub.OrgID, &sub.URL, &eventTypes, &active, &sub.Format, &template, &sub.CreatedAt, &sub.UpdatedAt}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+	sub.EventTypes = strings.Split(eventTypes, ",")
+	sub.Template = template.String
+	sub.Active = &active
+	sub.CreatedAt = sub.CreatedAt.UTC()
+	sub.UpdatedAt = sub.UpdatedAt.UTC()
+	return &sub, nil
+}
+
+// scanDelivery scans the deliveryColumns of a row, followed by any extra columns into extra
+func scanDelivery(row rowScanner, extra ...interface{}) (*models.WebhookDelivery, error) {
+	var d models.WebhookDelivery
+	var payload string
+	var responseStatus sql.NullInt64
+	var lastError sql.NullString
+	var nextAttemptAt, deliveredAt sql.NullTime
+	dest := append([]interface{}{&d.ID, &d.SubscriptionID, &d.EventID, &d.EventType, &payload, &d.Status, &d.Attempts,
+		&responseStatus, &lastError, &nextAttemptAt, &deliveredAt, &d.CreatedAt}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+
+	d.Payload = json.RawMessage(payload)
+	d.ResponseStatus = int(responseStatus.Int64)
+	d.LastError = lastError.String
+	if nextAttemptAt.Valid {
+		t := nextAttemptAt.Time.UTC()
+		d.NextAttemptAt = &t
+	}
+	if deliveredAt.Valid {
+		t := deliveredAt.Time.UTC()
+		d.DeliveredAt = &t
+	}
+	d.CreatedAt = d.CreatedAt.UTC()
+	return &d, nil
+}
+
+// nullInt stores 0 as NULL
+func nullInt(n int) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(n), Valid: n != 0}
+}
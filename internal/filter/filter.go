@@ -0,0 +1,286 @@
+// Package filter parses the filter query language of list endpoints, such as
+// status==released;name=like=payments;created_at>=2024-01-01, into typed
+// conditions the database turns into parameterized SQL.
+package filter
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yashjain/konnect/internal/models"
+	"github.com/yashjain/konnect/pkg/types"
+)
+
+// Filter operators
+const (
+	OpEqual        = "=="
+	OpNotEqual     = "!="
+	OpGreater      = ">"
+	OpGreaterEqual = ">="
+	OpLess         = "<"
+	OpLessEqual    = "<="
+	OpLike         = "=like="
+	OpIn           = "=in="
+)
+
+// operators are tried in order, so that longer operators sharing a prefix come first
+var operators = []string{OpLike, OpIn, OpEqual, OpNotEqual, OpGreaterEqual, OpLessEqual, OpGreater, OpLess}
+
+const (
+	// MaxConditions bounds the conditions of one filter
+	MaxConditions = 10
+
+	// MaxValues bounds the values of one =in= condition
+	MaxValues = 50
+)
+
+// ErrInvalidFilter is returned for filters that do not follow the grammar or the fields' types
+var ErrInvalidFilter = errors.New("invalid filter")
+
+// Kind is the type of a filterable field, which decides its operators and how
+// its values are parsed
+type Kind int
+
+const (
+	// String fields support ==, !=, =like= and =in=
+	String Kind = iota
+
+	// Enum fields are strings limited to Field.Values, supporting ==, != and =in=
+	Enum
+
+	// Int fields support comparisons and =in=
+	Int
+
+	// Time fields take RFC 3339 times or dates and support comparisons
+	Time
+)
+
+// Field describes a filterable field
+type Field struct {
+	Kind Kind
+
+	// Values are the allowed values of an Enum
+	Values []string
+}
+
+// Schema lists the fields a list endpoint can be filtered by
+type Schema map[string]Field
+
+// ServiceFields are the fields GET /services can be filtered by
+var ServiceFields = Schema{
+	"name":           {Kind: String},
+	"slug":           {Kind: String},
+	"description":    {Kind: String},
+	"visibility":     {Kind: Enum, Values: []string{models.VisibilityPublic, models.VisibilityPrivate}},
+	"versions_count": {Kind: Int},
+	"created_at":     {Kind: Time},
+	"updated_at":     {Kind: Time},
+
+	// latest_status is the status of the newest version; services without
+	// versions meet no condition on it
+	"latest_status": {Kind: Enum, Values: []string{models.VersionDraft, models.VersionReleased, models.VersionDeprecated}},
+
+	// spec_score is the lint score of the OpenAPI document of the newest
+	// version that has a scored one; services without one meet no condition on it
+	"spec_score": {Kind: Int},
+}
+
+// VersionFields are the fields GET /services/{id}/versions can be filtered by
+var VersionFields = Schema{
+	"semver":        {Kind: String},
+	"status":        {Kind: Enum, Values: []string{models.VersionDraft, models.VersionReleased, models.VersionDeprecated}},
+	"changelog":     {Kind: String},
+	"created_at":    {Kind: Time},
+	"deprecated_at": {Kind: Time},
+	"released_at":   {Kind: Time},
+	"sunset_at":     {Kind: Time},
+	"spec_score":    {Kind: Int},
+}
+
+// allowed lists the operators of each kind
+var allowed = map[Kind][]string{
+	String: {OpEqual, OpNotEqual, OpLike, OpIn},
+	Enum:   {OpEqual, OpNotEqual, OpIn},
+	Int:    {OpEqual, OpNotEqual, OpGreater, OpGreaterEqual, OpLess, OpLessEqual, OpIn},
+	Time:   {OpEqual, OpNotEqual, OpGreater, OpGreaterEqual, OpLess, OpLessEqual},
+}
+
+// Parse parses a filter of conditions separated by semicolons, all of which must
+// hold. A condition is a field of schema, an operator and a value; values may be
+// double-quoted to contain semicolons, commas or parentheses, with \" and \\
+// escapes. =in= takes a parenthesized, comma-separated list of values. An empty
+// filter has no conditions.
+func Parse(raw string, schema Schema) ([]types.FilterCondition, error) {
+	parts, err := split(raw, ';')
+	if err != nil {
+		return nil, err
+	}
+
+	var conditions []types.FilterCondition
+	for _, part := range parts {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		condition, err := parseCondition(part, schema)
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, condition)
+		if len(conditions) > MaxConditions {
+			return nil, fmt.Errorf("%w: more than %d conditions", ErrInvalidFilter, MaxConditions)
+		}
+	}
+	return conditions, nil
+}
+
+// parseCondition parses one field, operator and value
+func parseCondition(raw string, schema Schema) (types.FilterCondition, error) {
+	raw = strings.TrimSpace(raw)
+	end := strings.IndexFunc(raw, func(r rune) bool { return !(r >= 'a' && r <= 'z' || r == '_') })
+	if end <= 0 {
+		return types.FilterCondition{}, fmt.Errorf("%w: condition %q does not start with a field", ErrInvalidFilter, raw)
+	}
+	name, rest := raw[:end], raw[end:]
+
+	field, ok := schema[name]
+	if !ok {
+		return types.FilterCondition{}, fmt.Errorf("%w: unknown field %q", ErrInvalidFilter, name)
+	}
+
+	var op string
+	for _, candidate := range operators {
+		if strings.HasPrefix(rest, candidate) {
+			op, rest = candidate, rest[len(candidate):]
+			break
+		}
+	}
+	if op == "" {
+		return types.FilterCondition{}, fmt.Errorf("%w: missing operator after %q", ErrInvalidFilter, name)
+	}
+	if !contains(allowed[field.Kind], op) {
+		return types.FilterCondition{}, fmt.Errorf("%w: %s is not supported on %q", ErrInvalidFilter, op, name)
+	}
+
+	rawValues := []string{rest}
+	if op == OpIn {
+		if !strings.HasPrefix(rest, "(") || !strings.HasSuffix(rest, ")") {
+			return types.FilterCondition{}, fmt.Errorf("%w: =in= on %q takes a parenthesized list", ErrInvalidFilter, name)
+		}
+		var err error
+		if rawValues, err = split(rest[1:len(rest)-1], ','); err != nil {
+			return types.FilterCondition{}, err
+		}
+		if len(rawValues) > MaxValues {
+			return types.FilterCondition{}, fmt.Errorf("%w: more than %d values for %q", ErrInvalidFilter, MaxValues, name)
+		}
+	}
+
+	condition := types.FilterCondition{Field: name, Operator: op}
+	for _, rawValue := range rawValues {
+		value, err := parseValue(name, field, strings.TrimSpace(rawValue))
+		if err != nil {
+			return types.FilterCondition{}, err
+		}
+		condition.Values = append(condition.Values, value)
+	}
+	return condition, nil
+}
+
+// parseValue unquotes a value and converts it to the field's type
+func parseValue(name string, field Field, raw string) (interface{}, error) {
+	value, err := unquote(raw)
+	if err != nil {
+		return nil, err
+	}
+	if value == "" {
+		return nil, fmt.Errorf("%w: missing value for %q", ErrInvalidFilter, name)
+	}
+
+	switch field.Kind {
+	case Enum:
+		if !contains(field.Values, value) {
+			return nil, fmt.Errorf("%w: %q must be one of %s", ErrInvalidFilter, name, strings.Join(field.Values, ", "))
+		}
+	case Int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q must be an integer", ErrInvalidFilter, name)
+		}
+		return n, nil
+	case Time:
+		if t, err := time.Parse(time.RFC3339, value); err == nil {
+			return t.UTC(), nil
+		}
+		t, err := time.Parse(time.DateOnly, value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q must be an RFC 3339 time or a YYYY-MM-DD date", ErrInvalidFilter, name)
+		}
+		return t, nil
+	}
+	return value, nil
+}
+
+// split splits s on sep outside double-quoted values
+func split(s string, sep byte) ([]string, error) {
+	var parts []string
+	start, quoted := 0, false
+	for i := 0; i < len(s); i++ {
+		switch {
+		case quoted && s[i] == '\\':
+			i++
+		case s[i] == '"':
+			quoted = !quoted
+		case !quoted && s[i] == sep:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	if quoted {
+		return nil, fmt.Errorf("%w: unterminated quote", ErrInvalidFilter)
+	}
+	return append(parts, s[start:]), nil
+}
+
+// unquote returns a value with its surrounding double quotes and escapes removed,
+// or as is when it is not quoted
+func unquote(raw string) (string, error) {
+	if !strings.HasPrefix(raw, `"`) {
+		if strings.ContainsAny(raw, `"()`) {
+			return "", fmt.Errorf("%w: value %q must be quoted", ErrInvalidFilter, raw)
+		}
+		return raw, nil
+	}
+
+	var b strings.Builder
+	for i := 1; i < len(raw); i++ {
+		switch raw[i] {
+		case '\\':
+			if i+1 == len(raw) {
+				return "", fmt.Errorf("%w: unterminated quote", ErrInvalidFilter)
+			}
+			i++
+			b.WriteByte(raw[i])
+		case '"':
+			if i != len(raw)-1 {
+				return "", fmt.Errorf("%w: unexpected text after quoted value %s", ErrInvalidFilter, raw)
+			}
+			return b.String(), nil
+		default:
+			b.WriteByte(raw[i])
+		}
+	}
+	return "", fmt.Errorf("%w: unterminated quote", ErrInvalidFilter)
+}
+
+// contains reports whether values holds value
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
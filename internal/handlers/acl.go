@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/yashjain/konnect/internal/app"
+	"github.com/yashjain/konnect/internal/audit"
+	"github.com/yashjain/konnect/internal/middleware"
+	"github.com/yashjain/konnect/internal/models"
+	"github.com/yashjain/konnect/internal/repository"
+)
+
+// respondAccessError maps an app.Authorize failure to an HTTP response
+func respondAccessError(c *gin.Context, err error) {
+	switch err {
+	case app.ErrNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Service not found"})
+	case app.ErrForbidden:
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	default:
+		respondInternalError(c, err)
+	}
+}
+
+// GetServiceACLs lists service ACL grants
+func GetServiceACLs(accessRepo repository.AccessRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		serviceID := c.Param("id")
+
+		if err := app.Authorize(c.Request.Context(), accessRepo, middleware.Principal(c), serviceID, models.PermissionWrite); err != nil {
+			respondAccessError(c, err)
+			return
+		}
+
+		acls, err := accessRepo.GetServiceACLs(c.Request.Context(), middleware.OrgID(c), serviceID)
+		if err != nil {
+			respondInternalError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": acls})
+	}
+}
+
+// CreateServiceACL grants access to a service
+func CreateServiceACL(accessRepo repository.AccessRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		serviceID := c.Param("id")
+		principal := middleware.Principal(c)
+
+		var acl models.ServiceACL
+		if err := c.ShouldBindJSON(&acl); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := app.Authorize(c.Request.Context(), accessRepo, principal, serviceID, models.PermissionWrite); err != nil {
+			respondAccessError(c, err)
+			return
+		}
+
+		ok, err := accessRepo.SubjectInOrg(c.Request.Context(), principal.OrgID, acl.SubjectType, acl.SubjectID)
+		if err != nil {
+			respondInternalError(c, err)
+			return
+		}
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "subject not found in organization"})
+			return
+		}
+
+		acl.ID = uuid.New().String()
+		acl.ServiceID = serviceID
+
+		if err := accessRepo.CreateServiceACL(c.Request.Context(), principal.OrgID, &acl); err != nil {
+			respondInternalError(c, err)
+			return
+		}
+		audit.Log(c.Request.Context(), "Service access granted", "org_id", principal.OrgID, "user_id", principal.UserID,
+			"service_id", serviceID, "acl_id", acl.ID, "subject_type", acl.SubjectType, "subject_id", acl.SubjectID, "permission", acl.Permission)
+
+		c.JSON(http.StatusCreated, acl)
+	}
+}
+
+// DeleteServiceACL revokes access to a service
+func DeleteServiceACL(accessRepo repository.AccessRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		serviceID := c.Param("id")
+
+		if err := app.Authorize(c.Request.Context(), accessRepo, middleware.Principal(c), serviceID, models.PermissionWrite); err != nil {
+			respondAccessError(c, err)
+			return
+		}
+
+		rowsAffected, err := accessRepo.DeleteServiceACL(c.Request.Context(), middleware.OrgID(c), serviceID, c.Param("acl_id"))
+		if err != nil {
+			respondInternalError(c, err)
+			return
+		}
+
+		if rowsAffected == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "ACL grant not found"})
+			return
+		}
+		principal := middleware.Principal(c)
+		audit.Log(c.Request.Context(), "Service access revoked", "org_id", principal.OrgID, "user_id", principal.UserID,
+			"service_id", serviceID, "acl_id", c.Param("acl_id"))
+
+		c.JSON(http.StatusOK, gin.H{"message": "ACL grant deleted"})
+	}
+}
@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"database/sql"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/yashjain/konnect/internal/auth"
+	"github.com/yashjain/konnect/internal/config"
+	"github.com/yashjain/konnect/internal/metrics"
+	"github.com/yashjain/konnect/internal/models"
+	"github.com/yashjain/konnect/internal/repository"
+)
+
+// dummyPasswordHash is compared against when an email is unknown so that
+// failed logins take the same time whether or not the user exists
+const dummyPasswordHash = "$2a$10$beJEROaPTFTyzTdTvyx/I.dYVCQ/BpQPDpi1.o2fnfg5G7xMIDL3e"
+
+// Login exchanges an email and password for tokens
+func Login(cfg config.AuthConfig, sessionRepo repository.SessionRepository, lockout *auth.Lockout) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.LoginRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		// Failures count against both the client IP and the account
+		ipKey := auth.IPKey(c.ClientIP())
+		principalKey := auth.PrincipalKey(req.Email)
+		if lockedOut(c, "login", lockout, ipKey, principalKey) {
+			return
+		}
+
+		userID, orgID, passwordHash, err := sessionRepo.GetUserCredentials(c.Request.Context(), req.Email)
+		if err == sql.ErrNoRows {
+			auth.CheckPassword(dummyPasswordHash, req.Password)
+			recordFailure("login", lockout, ipKey, principalKey)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid email or password", "code": "invalid_credentials"})
+			return
+		}
+		if err != nil {
+			respondInternalError(c, err)
+			return
+		}
+		if !auth.CheckPassword(passwordHash, req.Password) {
+			recordFailure("login", lockout, ipKey, principalKey)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid email or password", "code": "invalid_credentials"})
+			return
+		}
+
+		// Only the account is cleared: an attacker's own valid login must not
+		// reset the failures their IP accumulated against other accounts
+		lockout.Reset(principalKey)
+
+		// A login starts a new session family
+		session := newSession(cfg, userID, uuid.New().String())
+		refreshToken, err := auth.GenerateToken()
+		if err != nil {
+			respondInternalError(c, err)
+			return
+		}
+		if err := sessionRepo.CreateSession(c.Request.Context(), session, auth.HashToken(refreshToken)); err != nil {
+			respondInternalError(c, err)
+			return
+		}
+
+		respondTokens(c, cfg, orgID, session, refreshToken)
+	}
+}
+
+// Refresh refreshes an access token
+func Refresh(cfg config.AuthConfig, sessionRepo repository.SessionRepository, lockout *auth.Lockout) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.RefreshRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		ipKey := auth.IPKey(c.ClientIP())
+		if lockedOut(c, "refresh", lockout, ipKey) {
+			return
+		}
+
+		current, err := sessionRepo.GetSessionByRefreshHash(c.Request.Context(), auth.HashToken(req.RefreshToken))
+		if err == sql.ErrNoRows {
+			recordFailure("refresh", lockout, ipKey)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid refresh token", "code": "invalid_token"})
+			return
+		}
+		if err != nil {
+			respondInternalError(c, err)
+			return
+		}
+
+		if current.RevokedAt != nil {
+			revokeFamily(c, sessionRepo, current.FamilyID)
+			return
+		}
+		if time.Now().After(current.ExpiresAt) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "refresh token expired", "code": "token_expired"})
+			return
+		}
+
+		next := newSession(cfg, current.UserID, current.FamilyID)
+		refreshToken, err := auth.GenerateToken()
+		if err != nil {
+			respondInternalError(c, err)
+			return
+		}
+
+		rotated, err := sessionRepo.RotateSession(c.Request.Context(), current.ID, next, auth.HashToken(refreshToken))
+		if err != nil {
+			respondInternalError(c, err)
+			return
+		}
+		if !rotated {
+			// Another request rotated this token first, so it is being reused
+			revokeFamily(c, sessionRepo, current.FamilyID)
+			return
+		}
+
+		orgID, err := sessionRepo.GetUserOrgID(c.Request.Context(), current.UserID)
+		if err != nil {
+			respondInternalError(c, err)
+			return
+		}
+
+		respondTokens(c, cfg, orgID, next, refreshToken)
+	}
+}
+
+// lockedOut rejects the request with 429 if any of keys is locked out
+func lockedOut(c *gin.Context, endpoint string, lockout *auth.Lockout, keys ...string) bool {
+	var wait time.Duration
+	for _, key := range keys {
+		if d := lockout.Locked(key, time.Now()); d > wait {
+			wait = d
+		}
+	}
+	if wait == 0 {
+		return false
+	}
+
+	metrics.AuthFailures.WithLabelValues(endpoint, "locked_out").Inc()
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	c.JSON(http.StatusTooManyRequests, gin.H{"error": "too many failed attempts; try again later", "code": "locked_out"})
+	return true
+}
+
+// recordFailure counts a failed attempt against each of keys, which are
+// IP keys or principal keys, and records any lockout it starts
+func recordFailure(endpoint string, lockout *auth.Lockout, keys ...string) {
+	metrics.AuthFailures.WithLabelValues(endpoint, "invalid_credentials").Inc()
+	for _, key := range keys {
+		if lockout.Fail(key, time.Now()) == 0 {
+			continue
+		}
+		scope := "principal"
+		if strings.HasPrefix(key, auth.IPKey("")) {
+			scope = "ip"
+		}
+		metrics.AuthLockouts.WithLabelValues(scope).Inc()
+	}
+}
+
+// newSession builds a session expiring after the configured refresh token lifetime
+func newSession(cfg config.AuthConfig, userID, familyID string) *models.Session {
+	return &models.Session{
+		ID:        uuid.New().String(),
+		FamilyID:  familyID,
+		UserID:    userID,
+		ExpiresAt: time.Now().Add(cfg.RefreshTokenTTL),
+	}
+}
+
+// revokeFamily revokes a session family after refresh token reuse and rejects the request
+func revokeFamily(c *gin.Context, sessionRepo repository.SessionRepository, familyID string) {
+	if err := sessionRepo.RevokeSessionFamily(c.Request.Context(), familyID); err != nil {
+		respondInternalError(c, err)
+		return
+	}
+	c.JSON(http.StatusUnauthorized, gin.H{"error": "refresh token reuse detected; session revoked", "code": "token_reused"})
+}
+
+// respondTokens signs an access token for session and writes the token pair
+func respondTokens(c *gin.Context, cfg config.AuthConfig, orgID string, session *models.Session, refreshToken string) {
+	accessToken, err := auth.IssueAccessToken([]byte(cfg.SigningKey), orgID, session.UserID, session.ID, cfg.AccessTokenTTL)
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.TokenResponse{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(cfg.AccessTokenTTL.Seconds()),
+	})
+}
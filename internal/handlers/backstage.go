@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gopkg.in/yaml.v3"
+
+	"github.com/yashjain/konnect/internal/middleware"
+	"github.com/yashjain/konnect/internal/models"
+	"github.com/yashjain/konnect/internal/repository"
+	"github.com/yashjain/konnect/pkg/types"
+	"github.com/yashjain/konnect/pkg/utils"
+)
+
+// defaultBackstageOwner owns exported entities unless the export names an owner
+const defaultBackstageOwner = "unknown"
+
+// maxBackstageName is the longest name of a Backstage entity
+const maxBackstageName = 63
+
+// backstageOwnerPattern matches Backstage entity references, such as group:default/platform
+var backstageOwnerPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9:/_.-]*$`)
+
+// Annotations of exported Backstage entities, which tie them to their rows
+const (
+	backstageServiceID = "konnect/service-id"
+	backstageVersionID = "konnect/version-id"
+)
+
+// ExportBackstage exports the catalog as Backstage entities
+func ExportBackstage(serviceRepo repository.ServiceRepository, versionRepo repository.VersionRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		owner := c.DefaultQuery("owner", defaultBackstageOwner)
+		if !backstageOwnerPattern.MatchString(owner) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "owner must be a Backstage entity reference, such as group:default/platform"})
+			return
+		}
+
+		ctx := c.Request.Context()
+		orgID := middleware.OrgID(c)
+		principal := middleware.Principal(c)
+		var entities []models.BackstageEntity
+
+		servicePage := types.PaginationParams{Page: 1, PageSize: exportPageSize, SkipCount: true}
+		err := eachPage(func(cursor *types.Cursor) ([]models.Service, error) {
+			servicePage.Cursor = cursor
+			services, _, err := serviceRepo.GetServices(ctx, principal, servicePage)
+			return services, err
+		}, serviceCursor, func(services []models.Service) error {
+			for _, service := range services {
+				var released []models.Version
+				versionPage := types.PaginationParams{Page: 1, PageSize: exportPageSize, SkipCount: true}
+				err := eachPage(func(cursor *types.Cursor) ([]models.Version, error) {
+					versionPage.Cursor = cursor
+					versions, _, err := versionRepo.GetVersions(ctx, orgID, service.ID, versionPage)
+					return versions, err
+				}, versionCursor, func(versions []models.Version) error {
+					for _, version := range versions {
+						if version.Status == models.VersionReleased {
+							released = append(released, version)
+						}
+					}
+					return nil
+				})
+				if err != nil {
+					return err
+				}
+
+				// Pages are newest first; entities are listed oldest first, like catalog exports
+				slices.Reverse(released)
+				entities = append(entities, backstageEntities(service, released, owner)...)
+			}
+			return nil
+		})
+		if err != nil {
+			respondInternalError(c, err)
+			return
+		}
+
+		c.Header("Content-Disposition", `attachment; filename="catalog-info.yaml"`)
+		c.Header("Content-Type", "application/yaml; charset=utf-8")
+		c.Status(http.StatusOK)
+		enc := yaml.NewEncoder(c.Writer)
+		enc.SetIndent(2)
+		for _, entity := range entities {
+			if err := enc.Encode(entity); err != nil {
+				// The status is sent, so an error midway can only end the download early
+				slog.ErrorContext(ctx, "Export aborted", "method", c.Request.Method, "route", c.FullPath(), "error", err)
+				middleware.ReportError(c, err)
+				return
+			}
+		}
+		_ = enc.Close()
+	}
+}
+
+// backstageEntities returns a service as a Component, followed by its released
+// versions as the APIs it provides
+func backstageEntities(service models.Service, released []models.Version, owner string) []models.BackstageEntity {
+	name := backstageName(service.Slug, service.ID)
+	lifecycle := "experimental"
+	if len(released) > 0 {
+		lifecycle = "production"
+	}
+
+	if team, _ := service.Owner(); team != "" {
+		owner = "group:default/" + team
+	}
+
+	component := models.BackstageComponentSpec{Type: "service", Lifecycle: lifecycle, Owner: owner}
+	apis := make([]models.BackstageEntity, len(released))
+	for i, version := range released {
+		apiName := backstageName(service.Slug+"-"+version.Semver, version.ID)
+		component.ProvidesAPIs = append(component.ProvidesAPIs, apiName)
+
+		definition := version.Changelog
+		if definition == "" {
+			definition = service.Name + " " + version.Semver
+		}
+		apis[i] = models.BackstageEntity{
+			APIVersion: models.BackstageAPIVersion,
+			Kind:       models.BackstageAPI,
+			Metadata: models.BackstageMetadata{
+				Name:        apiName,
+				Title:       service.Name + " " + version.Semver,
+				Tags:        service.Tags,
+				Annotations: map[string]string{backstageServiceID: service.ID, backstageVersionID: version.ID},
+			},
+			Spec: models.BackstageAPISpec{Type: "other", Lifecycle: "production", Owner: owner, Definition: definition},
+		}
+	}
+
+	entity := models.BackstageEntity{
+		APIVersion: models.BackstageAPIVersion,
+		Kind:       models.BackstageComponent,
+		Metadata: models.BackstageMetadata{
+			Name:        name,
+			Title:       service.Name,
+			Description: service.Description,
+			Tags:        service.Tags,
+			Annotations: map[string]string{backstageServiceID: service.ID},
+		},
+		Spec: component,
+	}
+	return append([]models.BackstageEntity{entity}, apis...)
+}
+
+// backstageName turns s into the name of a Backstage entity: ASCII letters,
+// digits and single hyphens, at most 63 characters long, or fallback when
+// nothing of s is left
+func backstageName(s, fallback string) string {
+	name, err := utils.NormalizeSlug(s)
+	if err != nil {
+		name = fallback
+	}
+	if len(name) > maxBackstageName {
+		name = strings.TrimRight(name[:maxBackstageName], "-")
+	}
+	return name
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yashjain/konnect/internal/audit"
+	"github.com/yashjain/konnect/internal/models"
+	"github.com/yashjain/konnect/internal/repository"
+)
+
+// errInvalidBackup marks restore input that is not a valid backup
+var errInvalidBackup = errors.New("invalid backup")
+
+// ExportBackup exports a backup
+func ExportBackup(backupRepo repository.BackupRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		started := false
+		enc := json.NewEncoder(c.Writer)
+		err := backupRepo.ExportBackup(c.Request.Context(), func(record models.BackupRecord) error {
+			if !started {
+				started = true
+				c.Header("Content-Type", "application/x-ndjson")
+				c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="backup-%s.ndjson"`, time.Now().UTC().Format("20060102T150405Z")))
+				c.Status(http.StatusOK)
+			}
+			return enc.Encode(record)
+		})
+		if err == nil {
+			return
+		}
+
+		// Once records have been sent the status cannot change. End the stream with
+		// an error line instead, which also makes restoring the partial backup fail.
+		if started {
+			slog.ErrorContext(c.Request.Context(), "Backup aborted", "method", c.Request.Method, "route", c.FullPath(), "error", err)
+			_ = enc.Encode(gin.H{"error": "backup aborted"})
+			return
+		}
+		respondInternalError(c, err)
+	}
+}
+
+// RestoreBackup restores a backup
+func RestoreBackup(backupRepo repository.BackupRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		dec := json.NewDecoder(c.Request.Body)
+		line := 0
+		next := func() (models.BackupRecord, error) {
+			var record models.BackupRecord
+			if err := dec.Decode(&record); err != nil {
+				if err == io.EOF {
+					return record, err
+				}
+				return record, fmt.Errorf("%w: record %d: %v", errInvalidBackup, line+1, err)
+			}
+			line++
+			if !validBackupRecord(record) {
+				return record, fmt.Errorf("%w: record %d: unknown type %q or missing %[3]s", errInvalidBackup, line, record.Type)
+			}
+			return record, nil
+		}
+
+		result, err := backupRepo.RestoreBackup(c.Request.Context(), next)
+		if errors.Is(err, errInvalidBackup) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			respondInternalError(c, err)
+			return
+		}
+		audit.Log(c.Request.Context(), "Backup restored", "result", result)
+
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// validBackupRecord reports whether a record carries the object its type names
+func validBackupRecord(r models.BackupRecord) bool {
+	switch r.Type {
+	case models.BackupOrganization:
+		return r.Organization != nil
+	case models.BackupService:
+		return r.Service != nil
+	case models.BackupVersion:
+		return r.Version != nil
+	case models.BackupSpec:
+		return r.Spec != nil
+	case models.BackupDeployment:
+		return r.Deployment != nil
+	case models.BackupEnvironment:
+		return r.Environment != nil
+	case models.BackupCategory:
+		return r.Category != nil
+	case models.BackupServiceCategory:
+		return r.ServiceCategory != nil
+	case models.BackupUser:
+		return r.User != nil
+	case models.BackupTeam:
+		return r.Team != nil
+	case models.BackupTeamMember:
+		return r.TeamMember != nil
+	case models.BackupServiceACL:
+		// The subject type picks the table the subject is looked up in
+		return r.ServiceACL != nil &&
+			(r.ServiceACL.SubjectType == models.SubjectUser || r.ServiceACL.SubjectType == models.SubjectTeam) &&
+			(r.ServiceACL.Permission == models.PermissionRead || r.ServiceACL.Permission == models.PermissionWrite)
+	case models.BackupConsumer:
+		return r.Consumer != nil
+	}
+	return false
+}
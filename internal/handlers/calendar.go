@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/yashjain/konnect/internal/app"
+	"github.com/yashjain/konnect/internal/middleware"
+	"github.com/yashjain/konnect/internal/models"
+	"github.com/yashjain/konnect/internal/repository"
+)
+
+// calendarSize is how many versions a calendar lists at most
+const calendarSize = 500
+
+// calendarHistory is how long a deadline stays on a calendar once it has passed
+const calendarHistory = 30 * 24 * time.Hour
+
+// mediaTypeCalendar is the media type of iCalendar documents
+const mediaTypeCalendar = "text/calendar; charset=utf-8"
+
+// icsLineLength is the most octets an iCalendar line holds before it is folded
+const icsLineLength = 75
+
+// icsEscaper escapes iCalendar text values (RFC 5545, section 3.3.11)
+var icsEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+// GetDeprecationCalendar serves the deprecation and sunset dates of versions
+// as an iCalendar document: those a team consumes with team, those of a
+// service with service, and otherwise those of the services the caller is
+// subscribed to
+func GetDeprecationCalendar(serviceRepo repository.ServiceRepository, versionRepo repository.VersionRepository, accessRepo repository.AccessRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		principal := middleware.Principal(c)
+		teamID := c.Query("team")
+		serviceID := c.Query("service")
+
+		title := "Subscribed services"
+		switch {
+		case teamID != "" && serviceID != "":
+			c.JSON(http.StatusBadRequest, gin.H{"error": "team and service cannot be combined"})
+			return
+		case teamID != "":
+			title = "Team deadlines"
+		case serviceID != "":
+			if err := app.Authorize(ctx, accessRepo, principal, serviceID, models.PermissionRead); err != nil {
+				respondAccessError(c, err)
+				return
+			}
+			service, err := serviceRepo.GetServiceByID(ctx, principal.OrgID, serviceID)
+			if errors.Is(err, sql.ErrNoRows) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Service not found"})
+				return
+			}
+			if err != nil {
+				respondInternalError(c, err)
+				return
+			}
+			title = service.Name + " deadlines"
+		case principal.IsOrgWide():
+			c.JSON(http.StatusBadRequest, gin.H{"error": "team or service is required without a user's token"})
+			return
+		}
+
+		versions, err := versionRepo.GetDeprecationCalendar(ctx, principal, teamID, serviceID, time.Now().Add(-calendarHistory), calendarSize)
+		if err != nil {
+			respondInternalError(c, err)
+			return
+		}
+		ids := make([]string, 0, len(versions))
+		for _, v := range versions {
+			ids = append(ids, v.ServiceID)
+		}
+		services, err := serviceRepo.GetServicesByIDs(ctx, principal, ids)
+		if err != nil {
+			respondInternalError(c, err)
+			return
+		}
+		names := make(map[string]string, len(services))
+		for _, s := range services {
+			names[s.ID] = s.Name
+		}
+
+		c.Data(http.StatusOK, mediaTypeCalendar, []byte(calendar(c, title, versions, names)))
+	}
+}
+
+// calendar renders the deadlines of versions as an iCalendar document, one
+// all-day event per deprecation and sunset date, with absolute links like feeds
+func calendar(c *gin.Context, title string, versions []models.Version, names map[string]string) string {
+	base := requestBase(c)
+	api := apiPath(c)
+	stamp := time.Now().UTC().Format("20060102T150405Z")
+
+	var b strings.Builder
+	line := func(name, value string) { writeICSLine(&b, name+":"+value) }
+	line("BEGIN", "VCALENDAR")
+	line("VERSION", "2.0")
+	line("PRODID", "-//Konnect//Service Catalog//EN")
+	line("CALSCALE", "GREGORIAN")
+	line("METHOD", "PUBLISH")
+	line("X-WR-CALNAME", icsEscaper.Replace(title))
+	for _, v := range versions {
+		name, ok := names[v.ServiceID]
+		if !ok {
+			continue
+		}
+		link := base + versionLink(api, v.ServiceID, v.ID)
+		events := []struct {
+			kind string
+			at   *time.Time
+			what string
+		}{
+			{"deprecation", v.DeprecatedAt, "deprecated"},
+			{"sunset", v.SunsetAt, "sunset"},
+		}
+		for _, e := range events {
+			if e.at == nil {
+				continue
+			}
+			day := e.at.UTC()
+			line("BEGIN", "VEVENT")
+			line("UID", v.ID+"-"+e.kind+"@konnect")
+			line("DTSTAMP", stamp)
+			line("DTSTART;VALUE=DATE", day.Format("20060102"))
+			line("DTEND;VALUE=DATE", day.AddDate(0, 0, 1).Format("20060102"))
+			line("SUMMARY", icsEscaper.Replace(name+" "+v.Semver+" "+e.what))
+			line("DESCRIPTION", icsEscaper.Replace(calendarDescription(name, v, e.what)))
+			line("CATEGORIES", icsEscaper.Replace(name))
+			line("URL", link)
+			line("END", "VEVENT")
+		}
+	}
+	line("END", "VCALENDAR")
+	return b.String()
+}
+
+// calendarDescription describes a deadline of a version
+func calendarDescription(name string, v models.Version, what string) string {
+	if what == "sunset" {
+		return "Version " + v.Semver + " of " + name + " is no longer supported from this day."
+	}
+	description := "Version " + v.Semver + " of " + name + " is deprecated from this day."
+	if v.SunsetAt != nil {
+		description += " It will be sunset on " + v.SunsetAt.UTC().Format("January 2, 2006") + "."
+	}
+	return description
+}
+
+// writeICSLine writes a content line, folding it into lines of at most
+// icsLineLength octets without splitting UTF-8 characters
+func writeICSLine(b *strings.Builder, s string) {
+	limit := icsLineLength
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		b.WriteString(s[:cut])
+		b.WriteString("\r\n ")
+		s = s[cut:]
+		// Continuation lines start with a space, which counts toward their length
+		limit = icsLineLength - 1
+	}
+	b.WriteString(s)
+	b.WriteString("\r\n")
+}
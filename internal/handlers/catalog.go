@@ -0,0 +1,541 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gopkg.in/yaml.v3"
+
+	"github.com/yashjain/konnect/internal/app"
+	"github.com/yashjain/konnect/internal/audit"
+	"github.com/yashjain/konnect/internal/auth"
+	"github.com/yashjain/konnect/internal/middleware"
+	"github.com/yashjain/konnect/internal/models"
+	"github.com/yashjain/konnect/internal/repository"
+	"github.com/yashjain/konnect/internal/sanitize"
+	"github.com/yashjain/konnect/pkg/types"
+	"github.com/yashjain/konnect/pkg/utils"
+)
+
+// Catalog formats
+const (
+	catalogJSON = "json"
+	catalogYAML = "yaml"
+)
+
+// maxImportBytes bounds the body of an import
+const maxImportBytes = 16 << 20
+
+// maxImportServices bounds the services of an import
+const maxImportServices = 1000
+
+// maxSemverLength is the longest semver the versions table stores
+const maxSemverLength = 64
+
+// ExportCatalog exports the catalog
+func ExportCatalog(serviceRepo repository.ServiceRepository, versionRepo repository.VersionRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		format := c.DefaultQuery("format", catalogJSON)
+		if format != catalogJSON && format != catalogYAML {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "format must be json or yaml"})
+			return
+		}
+
+		catalog, err := exportCatalog(c.Request.Context(), serviceRepo, versionRepo, middleware.Principal(c))
+		if err != nil {
+			respondInternalError(c, err)
+			return
+		}
+
+		c.Header("Content-Disposition", `attachment; filename="catalog.`+format+`"`)
+		if format == catalogYAML {
+			c.YAML(http.StatusOK, catalog)
+			return
+		}
+		c.JSON(http.StatusOK, catalog)
+	}
+}
+
+// exportCatalog returns every service principal can see with its versions,
+// oldest first
+func exportCatalog(ctx context.Context, serviceRepo repository.ServiceRepository, versionRepo repository.VersionRepository, principal auth.Principal) (models.Catalog, error) {
+	services, versions, err := loadCatalog(ctx, serviceRepo, versionRepo, principal)
+	if err != nil {
+		return models.Catalog{}, err
+	}
+
+	catalog := models.Catalog{Services: []models.CatalogService{}}
+	for _, service := range services {
+		team, email := service.Owner()
+		item := models.CatalogService{
+			Name:        service.Name,
+			Slug:        service.Slug,
+			Description: service.Description,
+			Visibility:  service.Visibility,
+			OwnerTeam:   team,
+			OwnerEmail:  email,
+			Tags:        service.Tags,
+			Metadata:    service.Metadata,
+		}
+		for _, version := range versions[service.ID] {
+			item.Versions = append(item.Versions, models.CatalogVersion{
+				Semver:    version.Semver,
+				Status:    version.Status,
+				Changelog: version.Changelog,
+				Metadata:  version.Metadata,
+			})
+		}
+		catalog.Services = append(catalog.Services, item)
+	}
+	return catalog, nil
+}
+
+// loadCatalog returns every service principal can see and the versions of
+// each by service ID, all oldest first
+func loadCatalog(ctx context.Context, serviceRepo repository.ServiceRepository, versionRepo repository.VersionRepository, principal auth.Principal) ([]models.Service, map[string][]models.Version, error) {
+	var all []models.Service
+	versionsOf := make(map[string][]models.Version)
+
+	servicePage := types.PaginationParams{Page: 1, PageSize: exportPageSize, SkipCount: true}
+	err := eachPage(func(cursor *types.Cursor) ([]models.Service, error) {
+		servicePage.Cursor = cursor
+		services, _, err := serviceRepo.GetServices(ctx, principal, servicePage)
+		return services, err
+	}, serviceCursor, func(services []models.Service) error {
+		for _, service := range services {
+			versionPage := types.PaginationParams{Page: 1, PageSize: exportPageSize, SkipCount: true}
+			err := eachPage(func(cursor *types.Cursor) ([]models.Version, error) {
+				versionPage.Cursor = cursor
+				versions, _, err := versionRepo.GetVersions(ctx, principal.OrgID, service.ID, versionPage)
+				return versions, err
+			}, versionCursor, func(versions []models.Version) error {
+				versionsOf[service.ID] = append(versionsOf[service.ID], versions...)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+
+			// Pages are newest first; importing oldest first keeps the order
+			slices.Reverse(versionsOf[service.ID])
+			all = append(all, service)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	slices.Reverse(all)
+	return all, versionsOf, nil
+}
+
+// ImportCatalog imports a catalog, releasing versions only as releaseGate allows
+func ImportCatalog(serviceRepo repository.ServiceRepository, versionRepo repository.VersionRepository, accessRepo repository.AccessRepository, releaseGate *app.ReleaseGate) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		onConflict, dryRun, ok := importOptions(c)
+		if !ok {
+			return
+		}
+
+		catalog, ok := readCatalog(c)
+		if !ok {
+			return
+		}
+
+		imp := newCatalogImport(c, serviceRepo, versionRepo, accessRepo, releaseGate, onConflict, dryRun)
+		if err := imp.importServices(c.Request.Context(), catalog.Services); err != nil {
+			respondInternalError(c, err)
+			return
+		}
+
+		if !dryRun {
+			audit.Log(c.Request.Context(), "Catalog imported", "on_conflict", onConflict,
+				"created", imp.report.Created, "updated", imp.report.Updated, "skipped", imp.report.Skipped, "failed", imp.report.Failed)
+		}
+		c.JSON(http.StatusOK, imp.report)
+	}
+}
+
+// importOptions reads the on_conflict and dry_run parameters of an import,
+// responding with 400 and returning false when either is invalid
+func importOptions(c *gin.Context) (string, bool, bool) {
+	onConflict := c.DefaultQuery("on_conflict", models.ImportSkip)
+	if onConflict != models.ImportSkip && onConflict != models.ImportOverwrite {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "on_conflict must be skip or overwrite"})
+		return "", false, false
+	}
+	dryRun, ok := dryRunOption(c)
+	return onConflict, dryRun, ok
+}
+
+// dryRunOption reads the dry_run parameter, responding with 400 and returning
+// false when it is invalid
+func dryRunOption(c *gin.Context) (bool, bool) {
+	raw := c.Query("dry_run")
+	if raw == "" {
+		return false, true
+	}
+	dryRun, err := strconv.ParseBool(raw)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "dry_run must be true or false"})
+		return false, false
+	}
+	return dryRun, true
+}
+
+// readCatalog decodes the catalog in the body of c, as JSON or YAML depending on
+// its Content-Type, responding with an error and returning false when it cannot
+func readCatalog(c *gin.Context) (models.Catalog, bool) {
+	format, err := catalogFormat(c.GetHeader("Content-Type"))
+	if err != nil {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": err.Error()})
+		return models.Catalog{}, false
+	}
+
+	var catalog models.Catalog
+	if err := decodeCatalog(http.MaxBytesReader(c.Writer, c.Request.Body, maxImportBytes), format, &catalog); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("catalog must be at most %d bytes", maxImportBytes)})
+			return models.Catalog{}, false
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid catalog: " + err.Error()})
+		return models.Catalog{}, false
+	}
+	if len(catalog.Services) > maxImportServices {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("catalog must have at most %d services", maxImportServices)})
+		return models.Catalog{}, false
+	}
+	return catalog, true
+}
+
+// catalogFormat returns the catalog format of a request's Content-Type, JSON when there is none
+func catalogFormat(contentType string) (string, error) {
+	if contentType == "" {
+		return catalogJSON, nil
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "", fmt.Errorf("invalid Content-Type: %v", err)
+	}
+	switch mediaType {
+	case "application/json":
+		return catalogJSON, nil
+	case "application/yaml", "application/x-yaml", "text/yaml":
+		return catalogYAML, nil
+	}
+	return "", errors.New("Content-Type must be application/json or application/yaml")
+}
+
+// decodeCatalog reads a catalog in format from r
+func decodeCatalog(r io.Reader, format string, catalog *models.Catalog) error {
+	if format == catalogYAML {
+		return yaml.NewDecoder(r).Decode(catalog)
+	}
+	return json.NewDecoder(r).Decode(catalog)
+}
+
+// catalogImport imports the services of a catalog one at a time, recording the
+// outcome of each service and version in report
+type catalogImport struct {
+	services   repository.ServiceRepository
+	versions   repository.VersionRepository
+	access     repository.AccessRepository
+	gate       *app.ReleaseGate
+	principal  auth.Principal
+	onConflict string
+	dryRun     bool
+	report     models.ImportReport
+
+	// slugs are the slugs imported so far, so a catalog cannot set a service twice
+	slugs map[string]bool
+}
+
+// newCatalogImport returns an import by the caller of c
+func newCatalogImport(c *gin.Context, serviceRepo repository.ServiceRepository, versionRepo repository.VersionRepository, accessRepo repository.AccessRepository, releaseGate *app.ReleaseGate, onConflict string, dryRun bool) *catalogImport {
+	return &catalogImport{
+		services:   serviceRepo,
+		versions:   versionRepo,
+		access:     accessRepo,
+		gate:       releaseGate,
+		principal:  middleware.Principal(c),
+		onConflict: onConflict,
+		dryRun:     dryRun,
+		report:     models.ImportReport{DryRun: dryRun, OnConflict: onConflict, Items: []models.ImportItem{}},
+		slugs:      make(map[string]bool),
+	}
+}
+
+// importServices imports services in order. Only unexpected errors are returned.
+func (imp *catalogImport) importServices(ctx context.Context, services []models.CatalogService) error {
+	for i := range services {
+		if err := imp.importService(ctx, &services[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// add records the outcome of an item and counts it
+func (imp *catalogImport) add(item models.ImportItem) {
+	switch item.Result {
+	case models.ImportCreated:
+		imp.report.Created++
+	case models.ImportUpdated:
+		imp.report.Updated++
+	case models.ImportSkipped:
+		imp.report.Skipped++
+	case models.ImportFailed:
+		imp.report.Failed++
+	}
+	imp.report.Items = append(imp.report.Items, item)
+}
+
+// importService creates a service or resolves its conflict with the existing
+// service of the same slug, then imports its versions. A service that fails
+// leaves its versions out. Only unexpected errors are returned.
+func (imp *catalogImport) importService(ctx context.Context, in *models.CatalogService) error {
+	item := models.ImportItem{Kind: models.ImportItemService, Slug: in.Slug}
+	service, err := validService(imp.principal.OrgID, in, imp.slugs)
+	if err != nil {
+		item.Result, item.Error = models.ImportFailed, err.Error()
+		imp.add(item)
+		return nil
+	}
+	item.Slug = service.Slug
+
+	existing, err := imp.services.GetServiceBySlug(ctx, imp.principal.OrgID, service.Slug, types.ReadOptions{IncludeDeleted: true})
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+
+	if existing == nil {
+		item.Result = models.ImportCreated
+		if !imp.dryRun {
+			service.ID = uuid.New().String()
+			if service.Tags == nil {
+				service.Tags = []string{}
+			}
+			if service.Visibility == "" {
+				service.Visibility = models.VisibilityPublic
+			}
+			err := imp.services.CreateService(ctx, service, app.CreatorGrants(imp.principal, service)...)
+			if errors.Is(err, repository.ErrConflict) {
+				item.Result, item.Error = models.ImportFailed, "a service with this name already exists"
+				imp.add(item)
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			item.ID = service.ID
+		}
+		imp.add(item)
+		return imp.importVersions(ctx, service, in.Versions, false, true)
+	}
+
+	item.ID = existing.ID
+	if existing.DeletedAt != nil {
+		item.Result, item.Error = models.ImportFailed, "the slug belongs to a deleted service"
+		imp.add(item)
+		return nil
+	}
+
+	// Services the caller cannot see are reported like any other taken slug
+	writable := true
+	switch err := app.Authorize(ctx, imp.access, imp.principal, existing.ID, models.PermissionWrite); {
+	case errors.Is(err, app.ErrNotFound):
+		item.ID, item.Result, item.Error = "", models.ImportFailed, "the slug belongs to another service"
+		imp.add(item)
+		return nil
+	case errors.Is(err, app.ErrForbidden):
+		writable = false
+	case err != nil:
+		return err
+	}
+
+	switch {
+	case imp.onConflict == models.ImportSkip:
+		item.Result = models.ImportSkipped
+	case !writable:
+		item.Result, item.Error = models.ImportFailed, app.ErrForbidden.Error()
+		imp.add(item)
+		return nil
+	default:
+		item.Result = models.ImportUpdated
+		if !imp.dryRun {
+			// Owners left out of the import, as in Kong imports, keep their current value
+			if *service.OwnerTeam == "" {
+				service.OwnerTeam = nil
+			}
+			if *service.OwnerEmail == "" {
+				service.OwnerEmail = nil
+			}
+			_, err := imp.services.UpdateService(ctx, imp.principal.OrgID, existing.ID, service)
+			if errors.Is(err, repository.ErrConflict) {
+				item.Result, item.Error = models.ImportFailed, "a service with this name already exists"
+				imp.add(item)
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	imp.add(item)
+
+	service.ID = existing.ID
+	return imp.importVersions(ctx, service, in.Versions, true, writable)
+}
+
+// importVersions imports the versions of a service, matching them by semver
+// against the service's versions when it existed before the import. Versions
+// that would be created or updated fail unless the service is writable.
+func (imp *catalogImport) importVersions(ctx context.Context, service *models.Service, in []models.CatalogVersion, existed, writable bool) error {
+	semvers := make(map[string]bool)
+	for _, v := range in {
+		item := models.ImportItem{Kind: models.ImportItemVersion, Slug: service.Slug, Semver: v.Semver}
+		version, err := validVersion(v)
+		if err == nil && semvers[version.Semver] {
+			err = fmt.Errorf("semver %s is imported twice", version.Semver)
+		}
+		if err != nil {
+			item.Result, item.Error = models.ImportFailed, err.Error()
+			imp.add(item)
+			continue
+		}
+		semvers[version.Semver] = true
+		item.Semver = version.Semver
+
+		var existing *models.Version
+		if existed {
+			existing, err = imp.versions.GetVersionBySemver(ctx, imp.principal.OrgID, service.ID, version.Semver)
+			if err != nil && err != sql.ErrNoRows {
+				return err
+			}
+		}
+
+		switch {
+		case existing != nil && imp.onConflict == models.ImportSkip:
+			item.ID, item.Result = existing.ID, models.ImportSkipped
+		case !writable:
+			item.Result, item.Error = models.ImportFailed, app.ErrForbidden.Error()
+		case existing == nil:
+			item.Result = models.ImportCreated
+			if !imp.dryRun {
+				version.ID = uuid.New().String()
+				version.ServiceID = service.ID
+				if err := imp.versions.CreateVersion(ctx, imp.principal.OrgID, version); err != nil {
+					return err
+				}
+				item.ID = version.ID
+			}
+		default:
+			item.ID, item.Result = existing.ID, models.ImportUpdated
+			switch err := checkRelease(ctx, imp.gate, imp.principal.OrgID, existing, version); {
+			case errors.Is(err, app.ErrUndeclaredBreakingChanges):
+				item.Result, item.Error = models.ImportFailed, err.Error()
+			case err != nil:
+				return err
+			case !imp.dryRun:
+				if _, err := imp.versions.UpdateVersion(ctx, imp.principal.OrgID, service.ID, existing.ID, version); err != nil {
+					return err
+				}
+			}
+		}
+		imp.add(item)
+	}
+	return nil
+}
+
+// checkRelease asks gate whether existing may become version, when that
+// releases it
+func checkRelease(ctx context.Context, gate *app.ReleaseGate, orgID string, existing, version *models.Version) error {
+	if existing.Status == models.VersionReleased || version.Status != models.VersionReleased {
+		return nil
+	}
+	return gate.CheckRelease(ctx, orgID, existing)
+}
+
+// validService checks and normalizes a catalog service the way CreateService
+// does, returning it as a service of orgID. Slugs already in slugs are
+// rejected, and the slug of the service is added.
+func validService(orgID string, in *models.CatalogService, slugs map[string]bool) (*models.Service, error) {
+	service := &models.Service{
+		OrgID:       orgID,
+		Name:        strings.TrimSpace(in.Name),
+		Slug:        in.Slug,
+		Description: sanitize.Markdown(in.Description),
+		Visibility:  in.Visibility,
+	}
+	if service.Name == "" {
+		return nil, errors.New("name is required")
+	}
+	if service.Slug == "" {
+		return nil, errors.New("slug is required")
+	}
+	if err := normalizeSlug(service); err != nil {
+		return nil, err
+	}
+	if slugs[service.Slug] {
+		return nil, fmt.Errorf("slug %s is imported twice", service.Slug)
+	}
+	slugs[service.Slug] = true
+
+	if service.Visibility != "" && service.Visibility != models.VisibilityPublic && service.Visibility != models.VisibilityPrivate {
+		return nil, errors.New("visibility must be public or private")
+	}
+
+	service.OwnerTeam, service.OwnerEmail = &in.OwnerTeam, &in.OwnerEmail
+	if err := normalizeOwners(service); err != nil {
+		return nil, err
+	}
+
+	tags, err := utils.NormalizeTags(in.Tags)
+	if err != nil {
+		return nil, err
+	}
+	service.Tags = tags
+
+	if err := utils.ValidateMetadata(in.Metadata); err != nil {
+		return nil, err
+	}
+	service.Metadata = in.Metadata
+	return service, nil
+}
+
+// validVersion checks and normalizes a catalog version the way CreateVersion does
+func validVersion(in models.CatalogVersion) (*models.Version, error) {
+	version := &models.Version{
+		Semver:    strings.TrimSpace(in.Semver),
+		Status:    in.Status,
+		Changelog: sanitize.Markdown(in.Changelog),
+		Metadata:  in.Metadata,
+	}
+	if version.Semver == "" {
+		return nil, errors.New("semver is required")
+	}
+	if len(version.Semver) > maxSemverLength {
+		return nil, fmt.Errorf("semver must be at most %d characters", maxSemverLength)
+	}
+	switch version.Status {
+	case models.VersionDraft, models.VersionReleased, models.VersionDeprecated:
+	default:
+		return nil, errors.New("status must be draft, released or deprecated")
+	}
+	if err := utils.ValidateMetadata(version.Metadata); err != nil {
+		return nil, err
+	}
+	return version, nil
+}
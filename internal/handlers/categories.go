@@ -0,0 +1,277 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/yashjain/konnect/internal/app"
+	"github.com/yashjain/konnect/internal/middleware"
+	"github.com/yashjain/konnect/internal/models"
+	"github.com/yashjain/konnect/internal/repository"
+	"github.com/yashjain/konnect/pkg/utils"
+)
+
+// GetCategories gets the category tree, as its root categories with their subcategories
+func GetCategories(categoryRepo repository.CategoryRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		categories, err := categoryRepo.GetCategories(c.Request.Context(), middleware.OrgID(c))
+		if err != nil {
+			respondInternalError(c, err)
+			return
+		}
+
+		children := childCategories(categories)
+		roots := []models.Category{}
+		for _, category := range children[""] {
+			roots = append(roots, categoryTree(children, category))
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": roots})
+	}
+}
+
+// GetCategory gets a category with its subcategories
+func GetCategory(categoryRepo repository.CategoryRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		categories, err := categoryRepo.GetCategories(c.Request.Context(), middleware.OrgID(c))
+		if err != nil {
+			respondInternalError(c, err)
+			return
+		}
+
+		for _, category := range categories {
+			if category.ID == c.Param("id") {
+				c.JSON(http.StatusOK, categoryTree(childCategories(categories), category))
+				return
+			}
+		}
+		c.JSON(http.StatusNotFound, gin.H{"error": "Category not found"})
+	}
+}
+
+// CreateCategory creates a category
+func CreateCategory(categoryRepo repository.CategoryRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !requireOrgWide(c, "categories") {
+			return
+		}
+
+		var category models.Category
+		if err := c.ShouldBindJSON(&category); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := validateCategory(&category); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		category.ID = uuid.New().String()
+		category.OrgID = middleware.OrgID(c)
+		category.Children = nil
+
+		err := categoryRepo.CreateCategory(c.Request.Context(), &category)
+		if errors.Is(err, repository.ErrInvalidParent) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "parent_id must be an existing category"})
+			return
+		}
+		if errors.Is(err, repository.ErrConflict) {
+			c.JSON(http.StatusConflict, gin.H{"error": "A category with this slug already exists"})
+			return
+		}
+		if err != nil {
+			respondInternalError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, category)
+	}
+}
+
+// UpdateCategory renames or moves a category, replacing its name, slug and parent
+func UpdateCategory(categoryRepo repository.CategoryRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !requireOrgWide(c, "categories") {
+			return
+		}
+		id := c.Param("id")
+
+		var category models.Category
+		if err := c.ShouldBindJSON(&category); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := validateCategory(&category); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		rowsAffected, err := categoryRepo.UpdateCategory(c.Request.Context(), middleware.OrgID(c), id, &category)
+		if errors.Is(err, repository.ErrInvalidParent) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "parent_id must be an existing category outside the category's subtree"})
+			return
+		}
+		if errors.Is(err, repository.ErrConflict) {
+			c.JSON(http.StatusConflict, gin.H{"error": "A category with this slug already exists"})
+			return
+		}
+		if err != nil {
+			respondInternalError(c, err)
+			return
+		}
+		if rowsAffected == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Category not found"})
+			return
+		}
+
+		updated, err := categoryRepo.GetCategory(c.Request.Context(), middleware.OrgID(c), id)
+		if err != nil {
+			respondInternalError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, updated)
+	}
+}
+
+// DeleteCategory deletes a category without subcategories, unassigning its services
+func DeleteCategory(categoryRepo repository.CategoryRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !requireOrgWide(c, "categories") {
+			return
+		}
+
+		rowsAffected, err := categoryRepo.DeleteCategory(c.Request.Context(), middleware.OrgID(c), c.Param("id"))
+		if errors.Is(err, repository.ErrInUse) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Category has subcategories; move or delete them first"})
+			return
+		}
+		if err != nil {
+			respondInternalError(c, err)
+			return
+		}
+		if rowsAffected == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Category not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Category deleted"})
+	}
+}
+
+// GetServiceCategories gets the categories a service is assigned to
+func GetServiceCategories(categoryRepo repository.CategoryRepository, accessRepo repository.AccessRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		serviceID := c.Param("id")
+
+		if err := app.Authorize(c.Request.Context(), accessRepo, middleware.Principal(c), serviceID, models.PermissionRead); err != nil {
+			respondAccessError(c, err)
+			return
+		}
+
+		categories, err := categoryRepo.GetServiceCategories(c.Request.Context(), middleware.OrgID(c), serviceID)
+		if err != nil {
+			respondInternalError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": categories})
+	}
+}
+
+// AddServiceCategory assigns a service to a category
+func AddServiceCategory(categoryRepo repository.CategoryRepository, accessRepo repository.AccessRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		serviceID := c.Param("id")
+
+		if err := app.Authorize(c.Request.Context(), accessRepo, middleware.Principal(c), serviceID, models.PermissionWrite); err != nil {
+			respondAccessError(c, err)
+			return
+		}
+
+		err := categoryRepo.AddServiceCategory(c.Request.Context(), middleware.OrgID(c), serviceID, c.Param("category_id"))
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Service or category not found"})
+			return
+		}
+		if err != nil {
+			respondInternalError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Service added to category"})
+	}
+}
+
+// RemoveServiceCategory unassigns a service from a category
+func RemoveServiceCategory(categoryRepo repository.CategoryRepository, accessRepo repository.AccessRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		serviceID := c.Param("id")
+
+		if err := app.Authorize(c.Request.Context(), accessRepo, middleware.Principal(c), serviceID, models.PermissionWrite); err != nil {
+			respondAccessError(c, err)
+			return
+		}
+
+		rowsAffected, err := categoryRepo.RemoveServiceCategory(c.Request.Context(), middleware.OrgID(c), serviceID, c.Param("category_id"))
+		if err != nil {
+			respondInternalError(c, err)
+			return
+		}
+		if rowsAffected == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Service is not in the category"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Service removed from category"})
+	}
+}
+
+// validateCategory trims the name of category and normalizes its slug with
+// utils.NormalizeSlug, deriving it from the name when it is empty
+func validateCategory(category *models.Category) error {
+	category.Name = strings.TrimSpace(category.Name)
+	if category.Name == "" {
+		return errors.New("name must not be blank")
+	}
+	if category.ParentID != nil && *category.ParentID == "" {
+		category.ParentID = nil
+	}
+
+	slug := category.Slug
+	if slug == "" {
+		slug = category.Name
+	}
+	slug, err := utils.NormalizeSlug(slug)
+	if err != nil {
+		return err
+	}
+	category.Slug = slug
+	return nil
+}
+
+// childCategories indexes categories by the ID of their parent, roots under ""
+func childCategories(categories []models.Category) map[string][]models.Category {
+	children := make(map[string][]models.Category)
+	for _, category := range categories {
+		parentID := ""
+		if category.ParentID != nil {
+			parentID = *category.ParentID
+		}
+		children[parentID] = append(children[parentID], category)
+	}
+	return children
+}
+
+// categoryTree returns category with its subcategories from children, recursively
+func categoryTree(children map[string][]models.Category, category models.Category) models.Category {
+	for _, child := range children[category.ID] {
+		category.Children = append(category.Children, categoryTree(children, child))
+	}
+	return category
+}
@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yashjain/konnect/internal/config"
+)
+
+// ConfigReloader re-reads the configuration, applying what can change without a restart
+type ConfigReloader interface {
+	Reload(source string) ([]config.Change, error)
+}
+
+// ReloadConfig reloads the configuration
+func ReloadConfig(reloader ConfigReloader) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		changes, err := reloader.Reload("admin")
+		if errors.Is(err, config.ErrNoConfigFile) {
+			c.JSON(http.StatusConflict, gin.H{"error": "no CONFIG_FILE to reload"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if changes == nil {
+			changes = []config.Change{}
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Configuration reloaded", "changes": changes})
+	}
+}
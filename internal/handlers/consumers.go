@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/yashjain/konnect/internal/app"
+	"github.com/yashjain/konnect/internal/auth"
+	"github.com/yashjain/konnect/internal/middleware"
+	"github.com/yashjain/konnect/internal/models"
+	"github.com/yashjain/konnect/internal/repository"
+)
+
+// requireTeamMember answers 403 unless the request is made for the whole
+// organization or by a member of the team, so users only register and
+// unregister their own teams as consumers
+func requireTeamMember(c *gin.Context, principal auth.Principal, teamID string) bool {
+	if principal.IsOrgWide() || slices.Contains(principal.TeamIDs, teamID) {
+		return true
+	}
+	c.JSON(http.StatusForbidden, gin.H{"error": "consumers can only be managed by members of their team"})
+	return false
+}
+
+// GetConsumers gets the teams consuming a service
+func GetConsumers(consumerRepo repository.ConsumerRepository, accessRepo repository.AccessRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		serviceID := c.Param("id")
+
+		if err := app.Authorize(c.Request.Context(), accessRepo, middleware.Principal(c), serviceID, models.PermissionRead); err != nil {
+			respondAccessError(c, err)
+			return
+		}
+
+		consumers, err := consumerRepo.GetConsumers(c.Request.Context(), middleware.OrgID(c), serviceID)
+		if err != nil {
+			respondInternalError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": consumers})
+	}
+}
+
+// CreateConsumer registers a team as a consumer of a service or one of its versions
+func CreateConsumer(consumerRepo repository.ConsumerRepository, accessRepo repository.AccessRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		serviceID := c.Param("id")
+		principal := middleware.Principal(c)
+
+		if err := app.Authorize(c.Request.Context(), accessRepo, principal, serviceID, models.PermissionRead); err != nil {
+			respondAccessError(c, err)
+			return
+		}
+
+		var consumer models.Consumer
+		if err := c.ShouldBindJSON(&consumer); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if !requireTeamMember(c, principal, consumer.TeamID) {
+			return
+		}
+
+		consumer.ID = uuid.New().String()
+		consumer.OrgID = middleware.OrgID(c)
+		consumer.ServiceID = serviceID
+
+		err := consumerRepo.CreateConsumer(c.Request.Context(), &consumer)
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Team or version not found"})
+			return
+		}
+		if errors.Is(err, repository.ErrConflict) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Team already consumes this service or version"})
+			return
+		}
+		if err != nil {
+			respondInternalError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, consumer)
+	}
+}
+
+// DeleteConsumer unregisters a consumer of a service
+func DeleteConsumer(consumerRepo repository.ConsumerRepository, accessRepo repository.AccessRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		serviceID := c.Param("id")
+		principal := middleware.Principal(c)
+
+		if err := app.Authorize(c.Request.Context(), accessRepo, principal, serviceID, models.PermissionRead); err != nil {
+			respondAccessError(c, err)
+			return
+		}
+
+		consumer, err := consumerRepo.GetConsumer(c.Request.Context(), middleware.OrgID(c), serviceID, c.Param("consumer_id"))
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Consumer not found"})
+			return
+		}
+		if err != nil {
+			respondInternalError(c, err)
+			return
+		}
+		if !requireTeamMember(c, principal, consumer.TeamID) {
+			return
+		}
+
+		rowsAffected, err := consumerRepo.DeleteConsumer(c.Request.Context(), middleware.OrgID(c), serviceID, consumer.ID)
+		if err != nil {
+			respondInternalError(c, err)
+			return
+		}
+		if rowsAffected == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Consumer not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Consumer deleted"})
+	}
+}
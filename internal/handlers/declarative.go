@@ -0,0 +1,396 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/yashjain/konnect/internal/app"
+	"github.com/yashjain/konnect/internal/audit"
+	"github.com/yashjain/konnect/internal/auth"
+	"github.com/yashjain/konnect/internal/middleware"
+	"github.com/yashjain/konnect/internal/models"
+	"github.com/yashjain/konnect/internal/repository"
+	"github.com/yashjain/konnect/pkg/types"
+)
+
+// errVersionDelete fails the versions a config leaves out, since versions cannot be deleted
+var errVersionDelete = errors.New("versions cannot be deleted")
+
+// errServiceNotCreated fails the versions of a service that failed to be created
+var errServiceNotCreated = errors.New("the service was not created")
+
+// DumpConfig dumps the catalog as a declarative config
+func DumpConfig(serviceRepo repository.ServiceRepository, versionRepo repository.VersionRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		catalog, err := exportCatalog(c.Request.Context(), serviceRepo, versionRepo, middleware.Principal(c))
+		if err != nil {
+			respondInternalError(c, err)
+			return
+		}
+
+		c.YAML(http.StatusOK, catalog)
+	}
+}
+
+// ApplyConfig makes the catalog match a declarative config, releasing
+// versions only as releaseGate allows
+func ApplyConfig(serviceRepo repository.ServiceRepository, versionRepo repository.VersionRepository, accessRepo repository.AccessRepository, releaseGate *app.ReleaseGate) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		dryRun, ok := dryRunOption(c)
+		if !ok {
+			return
+		}
+		catalog, ok := readCatalog(c)
+		if !ok {
+			return
+		}
+
+		ctx := c.Request.Context()
+		plan := configPlan{
+			services:  serviceRepo,
+			versions:  versionRepo,
+			access:    accessRepo,
+			gate:      releaseGate,
+			principal: middleware.Principal(c),
+			report:    models.ConfigReport{DryRun: dryRun, Changes: []models.ConfigChange{}},
+		}
+		if err := plan.diff(ctx, catalog); err != nil {
+			respondInternalError(c, err)
+			return
+		}
+
+		// A config that cannot be applied in full is not applied at all
+		if plan.report.Failed > 0 {
+			c.JSON(http.StatusUnprocessableEntity, plan.report)
+			return
+		}
+
+		if !dryRun {
+			if err := plan.apply(ctx); err != nil {
+				respondInternalError(c, err)
+				return
+			}
+			audit.Log(ctx, "Config applied", "created", plan.report.Created, "updated", plan.report.Updated,
+				"deleted", plan.report.Deleted, "failed", plan.report.Failed)
+		}
+		c.JSON(http.StatusOK, plan.report)
+	}
+}
+
+// configPlan is the diff between a config and the services the caller can
+// see, as the changes of its report and the steps making them
+type configPlan struct {
+	services  repository.ServiceRepository
+	versions  repository.VersionRepository
+	access    repository.AccessRepository
+	gate      *app.ReleaseGate
+	principal auth.Principal
+	report    models.ConfigReport
+	steps     []configStep
+}
+
+// configStep makes the change at index change of the report, to service or,
+// when it is set, to version of service
+type configStep struct {
+	change  int
+	service *models.Service
+	version *models.Version
+}
+
+// add records a change and counts it, adding a step making it unless it failed
+func (p *configPlan) add(change models.ConfigChange, service *models.Service, version *models.Version) {
+	if change.Error != "" {
+		p.report.Failed++
+	} else {
+		p.count(change.Action, 1)
+		p.steps = append(p.steps, configStep{change: len(p.report.Changes), service: service, version: version})
+	}
+	p.report.Changes = append(p.report.Changes, change)
+}
+
+// count adds n to the count of changes with action
+func (p *configPlan) count(action string, n int) {
+	switch action {
+	case models.ConfigCreate:
+		p.report.Created += n
+	case models.ConfigUpdate:
+		p.report.Updated += n
+	case models.ConfigDelete:
+		p.report.Deleted += n
+	}
+}
+
+// diff plans the changes making the services the caller can see match
+// catalog: missing services and versions are created, differing ones updated,
+// and services left out deleted. Changes that are invalid or that the caller
+// may not make fail. Only unexpected errors are returned.
+func (p *configPlan) diff(ctx context.Context, catalog models.Catalog) error {
+	services, versions, err := loadCatalog(ctx, p.services, p.versions, p.principal)
+	if err != nil {
+		return err
+	}
+	current := make(map[string]*models.Service, len(services))
+	for i := range services {
+		current[services[i].Slug] = &services[i]
+	}
+
+	// Deletes go first, so the names they free can be taken by the other changes
+	slugs := make(map[string]bool)
+	desired := make([]*models.Service, len(catalog.Services))
+	var invalid []error
+	for i := range catalog.Services {
+		desired[i], err = validService(p.principal.OrgID, &catalog.Services[i], slugs)
+		invalid = append(invalid, err)
+	}
+	for _, service := range services {
+		if slugs[service.Slug] {
+			continue
+		}
+		change := models.ConfigChange{Kind: models.ImportItemService, Slug: service.Slug, Action: models.ConfigDelete, ID: service.ID}
+		switch err := p.authorize(ctx, service.ID); {
+		case errors.Is(err, app.ErrForbidden):
+			change.Error = err.Error()
+		case err != nil:
+			return err
+		}
+		p.add(change, &service, nil)
+	}
+
+	for i, in := range catalog.Services {
+		if invalid[i] != nil {
+			change := models.ConfigChange{Kind: models.ImportItemService, Slug: in.Slug, Action: models.ConfigCreate, Error: invalid[i].Error()}
+			if existing, ok := current[in.Slug]; ok {
+				change.Action, change.ID = models.ConfigUpdate, existing.ID
+			}
+			p.add(change, nil, nil)
+			continue
+		}
+
+		service := desired[i]
+		if service.Visibility == "" {
+			service.Visibility = models.VisibilityPublic
+		}
+		if service.Tags == nil {
+			service.Tags = []string{}
+		}
+		if service.Metadata == nil {
+			service.Metadata = map[string]string{}
+		}
+
+		existing, ok := current[service.Slug]
+		if !ok {
+			if err := p.diffNewService(ctx, service, in.Versions); err != nil {
+				return err
+			}
+			continue
+		}
+
+		service.ID = existing.ID
+		writable := p.authorize(ctx, existing.ID)
+		if writable != nil && !errors.Is(writable, app.ErrForbidden) {
+			return writable
+		}
+		change := models.ConfigChange{Kind: models.ImportItemService, Slug: service.Slug, Action: models.ConfigUpdate, ID: existing.ID, Diff: serviceDiff(existing, service)}
+		if len(change.Diff) == 0 {
+			p.report.Unchanged++
+		} else {
+			if writable != nil {
+				change.Error = writable.Error()
+			}
+			p.add(change, service, nil)
+		}
+		if err := p.diffVersions(ctx, service, in.Versions, versions[existing.ID], writable); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// diffNewService plans the creation of a service and its versions, unless its
+// slug belongs to a service the caller cannot see or to a deleted one
+func (p *configPlan) diffNewService(ctx context.Context, service *models.Service, versions []models.CatalogVersion) error {
+	change := models.ConfigChange{Kind: models.ImportItemService, Slug: service.Slug, Action: models.ConfigCreate}
+	taken, err := p.services.GetServiceBySlug(ctx, p.principal.OrgID, service.Slug, types.ReadOptions{IncludeDeleted: true})
+	switch {
+	case err != nil && err != sql.ErrNoRows:
+		return err
+	case taken != nil && taken.DeletedAt != nil:
+		change.Error = "the slug belongs to a deleted service"
+	case taken != nil:
+		change.Error = "the slug belongs to another service"
+	}
+	p.add(change, service, nil)
+	if change.Error != "" {
+		return nil
+	}
+	return p.diffVersions(ctx, service, versions, nil, nil)
+}
+
+// diffVersions plans the changes making the current versions of a service
+// match the desired ones. Versions left out fail, and so do changes unless
+// writable is nil, and releases the release gate blocks. Only unexpected
+// errors are returned.
+func (p *configPlan) diffVersions(ctx context.Context, service *models.Service, desired []models.CatalogVersion, current []models.Version, writable error) error {
+	bySemver := make(map[string]*models.Version, len(current))
+	for i := range current {
+		bySemver[current[i].Semver] = &current[i]
+	}
+
+	semvers := make(map[string]bool)
+	for _, v := range desired {
+		change := models.ConfigChange{Kind: models.ImportItemVersion, Slug: service.Slug, Semver: v.Semver, Action: models.ConfigCreate}
+		version, err := validVersion(v)
+		if err == nil && semvers[version.Semver] {
+			err = fmt.Errorf("semver %s is imported twice", version.Semver)
+		}
+		if err != nil {
+			change.Error = err.Error()
+			p.add(change, service, nil)
+			continue
+		}
+		semvers[version.Semver] = true
+		change.Semver = version.Semver
+		if version.Metadata == nil {
+			version.Metadata = map[string]string{}
+		}
+
+		if existing, ok := bySemver[version.Semver]; ok {
+			change.Action, change.ID, version.ID = models.ConfigUpdate, existing.ID, existing.ID
+			change.Diff = versionDiff(existing, version)
+			if len(change.Diff) == 0 {
+				p.report.Unchanged++
+				continue
+			}
+			switch err := checkRelease(ctx, p.gate, p.principal.OrgID, existing, version); {
+			case errors.Is(err, app.ErrUndeclaredBreakingChanges):
+				change.Error = err.Error()
+			case err != nil:
+				return err
+			}
+		}
+		if writable != nil {
+			change.Error = writable.Error()
+		}
+		p.add(change, service, version)
+	}
+
+	for _, version := range current {
+		if !semvers[version.Semver] {
+			p.add(models.ConfigChange{Kind: models.ImportItemVersion, Slug: service.Slug, Semver: version.Semver, Action: models.ConfigDelete,
+				ID: version.ID, Error: errVersionDelete.Error()}, service, nil)
+		}
+	}
+	return nil
+}
+
+// authorize returns nil when the caller may write to a service, and
+// app.ErrForbidden or an unexpected error otherwise
+func (p *configPlan) authorize(ctx context.Context, serviceID string) error {
+	err := app.Authorize(ctx, p.access, p.principal, serviceID, models.PermissionWrite)
+	if errors.Is(err, app.ErrNotFound) {
+		return app.ErrForbidden
+	}
+	return err
+}
+
+// apply makes the planned changes in order. A change that fails, such as
+// one taking a name that was taken meanwhile, is reported as failed along with
+// the versions of a service it did not create. Only unexpected errors are returned.
+func (p *configPlan) apply(ctx context.Context) error {
+	orgID := p.principal.OrgID
+	for _, step := range p.steps {
+		change := &p.report.Changes[step.change]
+		var err error
+		switch {
+		case step.version != nil && step.service.ID == "":
+			err = errServiceNotCreated
+		case step.version != nil && change.Action == models.ConfigCreate:
+			step.version.ID = uuid.New().String()
+			step.version.ServiceID = step.service.ID
+			if err = p.versions.CreateVersion(ctx, orgID, step.version); err == nil {
+				change.ID = step.version.ID
+			}
+		case step.version != nil:
+			_, err = p.versions.UpdateVersion(ctx, orgID, step.service.ID, step.version.ID, step.version)
+		case change.Action == models.ConfigCreate:
+			id := uuid.New().String()
+			step.service.ID = id
+			err = p.services.CreateService(ctx, step.service, app.CreatorGrants(p.principal, step.service)...)
+			if err == nil {
+				change.ID = id
+			} else {
+				step.service.ID = ""
+			}
+		case change.Action == models.ConfigUpdate:
+			_, err = p.services.UpdateService(ctx, orgID, step.service.ID, step.service)
+		case change.Action == models.ConfigDelete:
+			_, err = p.services.DeleteService(ctx, orgID, step.service.ID)
+		}
+
+		if errors.Is(err, repository.ErrConflict) {
+			err = errors.New("a service with this name already exists")
+		} else if errors.Is(err, repository.ErrInUse) {
+			err = errors.New("the service has consumers")
+		} else if err != nil && !errors.Is(err, errServiceNotCreated) {
+			return err
+		}
+		if err != nil {
+			change.Error = err.Error()
+			p.count(change.Action, -1)
+			p.report.Failed++
+		}
+	}
+	return nil
+}
+
+// serviceDiff lists the fields of current that desired changes
+func serviceDiff(current, desired *models.Service) []models.FieldDiff {
+	var diff []models.FieldDiff
+	if current.Name != desired.Name {
+		diff = append(diff, models.FieldDiff{Field: "name", From: current.Name, To: desired.Name})
+	}
+	if current.Description != desired.Description {
+		diff = append(diff, models.FieldDiff{Field: "description", From: current.Description, To: desired.Description})
+	}
+	if current.Visibility != desired.Visibility {
+		diff = append(diff, models.FieldDiff{Field: "visibility", From: current.Visibility, To: desired.Visibility})
+	}
+	currentTeam, currentEmail := current.Owner()
+	desiredTeam, desiredEmail := desired.Owner()
+	if currentTeam != desiredTeam {
+		diff = append(diff, models.FieldDiff{Field: "owner_team", From: currentTeam, To: desiredTeam})
+	}
+	if currentEmail != desiredEmail {
+		diff = append(diff, models.FieldDiff{Field: "owner_email", From: currentEmail, To: desiredEmail})
+	}
+	if !slices.Equal(current.Tags, desired.Tags) {
+		diff = append(diff, models.FieldDiff{Field: "tags", From: current.Tags, To: desired.Tags})
+	}
+	if !maps.Equal(current.Metadata, desired.Metadata) {
+		diff = append(diff, models.FieldDiff{Field: "metadata", From: current.Metadata, To: desired.Metadata})
+	}
+	return diff
+}
+
+// versionDiff lists the fields of current that desired changes
+func versionDiff(current, desired *models.Version) []models.FieldDiff {
+	var diff []models.FieldDiff
+	if current.Status != desired.Status {
+		diff = append(diff, models.FieldDiff{Field: "status", From: current.Status, To: desired.Status})
+	}
+	if current.Changelog != desired.Changelog {
+		diff = append(diff, models.FieldDiff{Field: "changelog", From: current.Changelog, To: desired.Changelog})
+	}
+	if !maps.Equal(current.Metadata, desired.Metadata) {
+		diff = append(diff, models.FieldDiff{Field: "metadata", From: current.Metadata, To: desired.Metadata})
+	}
+	return diff
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/yashjain/konnect/internal/app"
+	"github.com/yashjain/konnect/internal/middleware"
+	"github.com/yashjain/konnect/internal/models"
+	"github.com/yashjain/konnect/internal/repository"
+	"github.com/yashjain/konnect/pkg/utils"
+)
+
+const (
+	// defaultDeploymentsLimit and maxDeploymentsLimit bound the deployments listed per version
+	defaultDeploymentsLimit = 50
+	maxDeploymentsLimit     = 200
+)
+
+// CreateDeployment records a version being deployed to an environment
+func CreateDeployment(deploymentRepo repository.DeploymentRepository, accessRepo repository.AccessRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		serviceID := c.Param("id")
+
+		if err := app.Authorize(c.Request.Context(), accessRepo, middleware.Principal(c), serviceID, models.PermissionWrite); err != nil {
+			respondAccessError(c, err)
+			return
+		}
+
+		var deployment models.Deployment
+		if err := c.ShouldBindJSON(&deployment); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		environment, err := utils.NormalizeEnvironment(deployment.Environment)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		deployment.ID = uuid.New().String()
+		deployment.OrgID = middleware.OrgID(c)
+		deployment.ServiceID = serviceID
+		deployment.VersionID = c.Param("version_id")
+		deployment.Environment = environment
+
+		err = deploymentRepo.CreateDeployment(c.Request.Context(), &deployment)
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Version not found"})
+			return
+		}
+		if err != nil {
+			respondInternalError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, deployment)
+	}
+}
+
+// GetDeployments gets the deployments of a version, newest first
+func GetDeployments(deploymentRepo repository.DeploymentRepository, accessRepo repository.AccessRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		serviceID := c.Param("id")
+
+		limit := defaultDeploymentsLimit
+		if raw := c.Query("limit"); raw != "" {
+			n, err := strconv.Atoi(raw)
+			if err != nil || n < 1 || n > maxDeploymentsLimit {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 200"})
+				return
+			}
+			limit = n
+		}
+
+		if err := app.Authorize(c.Request.Context(), accessRepo, middleware.Principal(c), serviceID, models.PermissionRead); err != nil {
+			respondAccessError(c, err)
+			return
+		}
+
+		deployments, err := deploymentRepo.GetDeployments(c.Request.Context(), middleware.OrgID(c), serviceID, c.Param("version_id"), limit)
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Version not found"})
+			return
+		}
+		if err != nil {
+			respondInternalError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": deployments})
+	}
+}
+
+// deployedIn normalizes the deployed_in= filter, empty when it is not given
+func deployedIn(c *gin.Context) (string, error) {
+	if environment := c.Query("deployed_in"); environment != "" {
+		return utils.NormalizeEnvironment(environment)
+	}
+	return "", nil
+}
@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/yashjain/konnect/internal/app"
+	"github.com/yashjain/konnect/internal/middleware"
+	"github.com/yashjain/konnect/internal/models"
+	"github.com/yashjain/konnect/internal/repository"
+)
+
+// ScheduleDeprecation deprecates a version now or schedules its deprecation,
+// and sets when it is sunset
+func ScheduleDeprecation(versionRepo repository.VersionRepository, accessRepo repository.AccessRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		serviceID := c.Param("id")
+
+		if err := app.Authorize(c.Request.Context(), accessRepo, middleware.Principal(c), serviceID, models.PermissionWrite); err != nil {
+			respondAccessError(c, err)
+			return
+		}
+
+		var schedule models.DeprecationSchedule
+		if err := c.ShouldBindJSON(&schedule); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		deprecatedAt := time.Now().UTC().Truncate(time.Second)
+		if schedule.DeprecatedAt != nil {
+			deprecatedAt = schedule.DeprecatedAt.UTC()
+		}
+
+		version, err := versionRepo.ScheduleDeprecation(c.Request.Context(), middleware.OrgID(c), serviceID, c.Param("version_id"), deprecatedAt, schedule.SunsetAt)
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Version not found"})
+			return
+		}
+		if errors.Is(err, repository.ErrInvalidSchedule) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "sunset_at must be after deprecated_at"})
+			return
+		}
+		if err != nil {
+			respondInternalError(c, err)
+			return
+		}
+
+		respondVersion(c, http.StatusOK, version)
+	}
+}
+
+// CancelDeprecation cancels the scheduled deprecation of a version that is not deprecated yet
+func CancelDeprecation(versionRepo repository.VersionRepository, accessRepo repository.AccessRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		serviceID := c.Param("id")
+		versionID := c.Param("version_id")
+
+		if err := app.Authorize(c.Request.Context(), accessRepo, middleware.Principal(c), serviceID, models.PermissionWrite); err != nil {
+			respondAccessError(c, err)
+			return
+		}
+
+		rowsAffected, err := versionRepo.CancelDeprecation(c.Request.Context(), middleware.OrgID(c), serviceID, versionID)
+		if err != nil {
+			respondInternalError(c, err)
+			return
+		}
+		if rowsAffected > 0 {
+			c.JSON(http.StatusOK, gin.H{"message": "Deprecation cancelled"})
+			return
+		}
+
+		// Tell why nothing was cancelled
+		version, err := versionRepo.GetVersion(c.Request.Context(), middleware.OrgID(c), serviceID, versionID)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Version not found"})
+		case err != nil:
+			respondInternalError(c, err)
+		case version.Status == models.VersionDeprecated:
+			c.JSON(http.StatusConflict, gin.H{"error": "Version is already deprecated"})
+		default:
+			c.JSON(http.StatusNotFound, gin.H{"error": "No deprecation is scheduled"})
+		}
+	}
+}
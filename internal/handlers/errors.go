@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yashjain/konnect/internal/middleware"
+	"github.com/yashjain/konnect/internal/repository"
+)
+
+// statusClientClosedRequest is reported when the client disconnects before a response is written
+const statusClientClosedRequest = 499
+
+// respondInternalError logs and reports an unexpected error and returns a generic 500.
+// Raw errors are never sent to clients because SQL errors can echo row values.
+// Queries that ran past their timeout get a 504, calls rejected while the
+// database is down get a 503 with Retry-After, and requests the client
+// abandoned are not logged as failures.
+func respondInternalError(c *gin.Context, err error) {
+	var unavailable *repository.UnavailableError
+	switch {
+	case errors.As(err, &unavailable):
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(unavailable.RetryAfter.Seconds()))))
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "service temporarily unavailable", "code": "unavailable"})
+	case errors.Is(err, context.Canceled) && c.Request.Context().Err() != nil:
+		c.AbortWithStatus(statusClientClosedRequest)
+	case errors.Is(err, context.DeadlineExceeded):
+		slog.WarnContext(c.Request.Context(), "Request timed out", "method", c.Request.Method, "route", c.FullPath(), "error", err)
+		c.JSON(http.StatusGatewayTimeout, gin.H{"error": "request timed out", "code": "timeout"})
+	default:
+		slog.ErrorContext(c.Request.Context(), "Request failed", "method", c.Request.Method, "route", c.FullPath(), "error", err)
+		middleware.ReportError(c, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error", "code": "internal_error"})
+	}
+}
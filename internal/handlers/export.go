@@ -0,0 +1,221 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yashjain/konnect/internal/app"
+	"github.com/yashjain/konnect/internal/middleware"
+	"github.com/yashjain/konnect/internal/models"
+	"github.com/yashjain/konnect/internal/repository"
+	"github.com/yashjain/konnect/pkg/types"
+)
+
+// exportPageSize is how many rows an export reads from the repository at a time
+const exportPageSize = 500
+
+// exportFormatCSV is the only supported export format
+const exportFormatCSV = "csv"
+
+// csvColumn is an exportable column of T
+type csvColumn[T any] struct {
+	name  string
+	value func(T) string
+}
+
+// serviceCSVColumns are the columns of a service export, in their default order
+var serviceCSVColumns = []csvColumn[models.Service]{
+	{"id", func(s models.Service) string { return s.ID }},
+	{"name", func(s models.Service) string { return s.Name }},
+	{"slug", func(s models.Service) string { return s.Slug }},
+	{"description", func(s models.Service) string { return s.Description }},
+	{"visibility", func(s models.Service) string { return s.Visibility }},
+	{"tags", func(s models.Service) string { return strings.Join(s.Tags, ";") }},
+	{"versions_count", func(s models.Service) string { return strconv.Itoa(s.VersionsCount) }},
+	{"created_at", func(s models.Service) string { return s.CreatedAt.UTC().Format(time.RFC3339) }},
+	{"updated_at", func(s models.Service) string { return s.UpdatedAt.UTC().Format(time.RFC3339) }},
+}
+
+// versionCSVColumns are the columns of a version export, in their default order
+var versionCSVColumns = []csvColumn[models.Version]{
+	{"id", func(v models.Version) string { return v.ID }},
+	{"service_id", func(v models.Version) string { return v.ServiceID }},
+	{"semver", func(v models.Version) string { return v.Semver }},
+	{"status", func(v models.Version) string { return v.Status }},
+	{"changelog", func(v models.Version) string { return v.Changelog }},
+	{"created_at", func(v models.Version) string { return v.CreatedAt.UTC().Format(time.RFC3339) }},
+}
+
+// exportColumns returns the columns named by ?columns=, a comma-separated
+// list, in the order given; all columns when it is empty
+func exportColumns[T any](c *gin.Context, all []csvColumn[T]) ([]csvColumn[T], error) {
+	if format := c.DefaultQuery("format", exportFormatCSV); format != exportFormatCSV {
+		return nil, fmt.Errorf("format must be %s", exportFormatCSV)
+	}
+
+	raw := strings.TrimSpace(c.Query("columns"))
+	if raw == "" {
+		return all, nil
+	}
+
+	var names []string
+	for _, col := range all {
+		names = append(names, col.name)
+	}
+	var selected []csvColumn[T]
+	for _, name := range strings.Split(raw, ",") {
+		name = strings.TrimSpace(name)
+		found := false
+		for _, col := range all {
+			if col.name == name {
+				selected = append(selected, col)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown column %q: must be among %s", name, strings.Join(names, ", "))
+		}
+	}
+	return selected, nil
+}
+
+// csvCell guards a value against formula injection: spreadsheets evaluate
+// cells starting with =, +, - or @ (or a tab or carriage return before one),
+// so those are prefixed with a quote
+func csvCell(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// eachPage passes the rows returned by next to fn a page of exportPageSize at a
+// time, reading each page from the cursor of the last row of the one before until
+// a page comes back without a look-ahead row. fn is called at least once.
+func eachPage[T any](next func(cursor *types.Cursor) ([]T, error), cursorOf func(T) types.Cursor, fn func([]T) error) error {
+	var cursor *types.Cursor
+	for {
+		rows, err := next(cursor)
+		if err != nil {
+			return err
+		}
+		more := len(rows) > exportPageSize
+		if more {
+			rows = rows[:exportPageSize]
+		}
+		if err := fn(rows); err != nil || !more {
+			return err
+		}
+		last := cursorOf(rows[len(rows)-1])
+		cursor = &last
+	}
+}
+
+// streamCSV writes the rows returned by next, paged through with eachPage, as
+// a CSV attachment named filename. Nothing is written until the first page has
+// been read, so its errors get a proper response; a later error ends the
+// download early.
+func streamCSV[T any](c *gin.Context, filename string, columns []csvColumn[T], next func(cursor *types.Cursor) ([]T, error), cursorOf func(T) types.Cursor) {
+	w := csv.NewWriter(c.Writer)
+	record := make([]string, len(columns))
+	started := false
+
+	err := eachPage(next, cursorOf, func(rows []T) error {
+		if !started {
+			started = true
+			c.Header("Content-Type", "text/csv; charset=utf-8")
+			c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+			c.Status(http.StatusOK)
+			for i, col := range columns {
+				record[i] = col.name
+			}
+			if err := w.Write(record); err != nil {
+				return err
+			}
+		}
+
+		for _, row := range rows {
+			for i, col := range columns {
+				record[i] = csvCell(col.value(row))
+			}
+			if err := w.Write(record); err != nil {
+				return err
+			}
+		}
+		w.Flush()
+		c.Writer.Flush()
+		return w.Error()
+	})
+	if err == nil {
+		return
+	}
+
+	if started {
+		slog.ErrorContext(c.Request.Context(), "Export aborted", "method", c.Request.Method, "route", c.FullPath(), "error", err)
+		middleware.ReportError(c, err)
+		return
+	}
+	respondInternalError(c, err)
+}
+
+// ExportServices exports services as CSV
+func ExportServices(serviceRepo repository.ServiceRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		columns, err := exportColumns(c, serviceCSVColumns)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		params := types.PaginationParams{Page: 1, PageSize: exportPageSize, SkipCount: true}
+		if err := serviceFilters(c, &params); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		principal := middleware.Principal(c)
+		streamCSV(c, "services.csv", columns, func(cursor *types.Cursor) ([]models.Service, error) {
+			params.Cursor = cursor
+			services, _, err := serviceRepo.GetServices(c.Request.Context(), principal, params)
+			return services, err
+		}, serviceCursor)
+	}
+}
+
+// ExportVersions exports a service's versions as CSV
+func ExportVersions(versionRepo repository.VersionRepository, accessRepo repository.AccessRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		serviceID := c.Param("id")
+
+		columns, err := exportColumns(c, versionCSVColumns)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		params := types.PaginationParams{Page: 1, PageSize: exportPageSize, SkipCount: true}
+		if err := versionFilters(c, &params); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := app.Authorize(c.Request.Context(), accessRepo, middleware.Principal(c), serviceID, models.PermissionRead); err != nil {
+			respondAccessError(c, err)
+			return
+		}
+
+		orgID := middleware.OrgID(c)
+		streamCSV(c, "versions.csv", columns, func(cursor *types.Cursor) ([]models.Version, error) {
+			params.Cursor = cursor
+			versions, _, err := versionRepo.GetVersions(c.Request.Context(), orgID, serviceID, params)
+			return versions, err
+		}, versionCursor)
+	}
+}
@@ -0,0 +1,316 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/yashjain/konnect/internal/app"
+	"github.com/yashjain/konnect/internal/middleware"
+	"github.com/yashjain/konnect/internal/models"
+	"github.com/yashjain/konnect/internal/repository"
+)
+
+// feedSize is how many of the newest released versions a feed lists
+const feedSize = 50
+
+// Media types of feeds
+const (
+	mediaTypeAtom = "application/atom+xml; charset=utf-8"
+	mediaTypeRSS  = "application/rss+xml; charset=utf-8"
+)
+
+// atomFeed is an Atom (RFC 4287) feed
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomEntry struct {
+	ID       string      `xml:"id"`
+	Title    string      `xml:"title"`
+	Updated  string      `xml:"updated"`
+	Author   atomAuthor  `xml:"author"`
+	Link     atomLink    `xml:"link"`
+	Category atomTerm    `xml:"category"`
+	Content  atomContent `xml:"content"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomTerm struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomContent struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+// rssFeed is an RSS 2.0 feed
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link"`
+	GUID        rssGUID `xml:"guid"`
+	PubDate     string  `xml:"pubDate"`
+	Category    string  `xml:"category"`
+	Description string  `xml:"description"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	ID          string `xml:",chardata"`
+}
+
+// feed is a list of released versions, rendered as Atom or RSS
+type feed struct {
+	id, title, self, alternate string
+	versions                   []models.Version
+	services                   map[string]models.Service
+}
+
+// GetServiceAtomFeed serves the newest released versions of a service as an Atom feed
+func GetServiceAtomFeed(serviceRepo repository.ServiceRepository, versionRepo repository.VersionRepository, accessRepo repository.AccessRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if f, ok := serviceFeed(c, serviceRepo, versionRepo, accessRepo); ok {
+			writeAtom(c, f)
+		}
+	}
+}
+
+// GetServiceRSSFeed serves the newest released versions of a service as an RSS feed
+func GetServiceRSSFeed(serviceRepo repository.ServiceRepository, versionRepo repository.VersionRepository, accessRepo repository.AccessRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if f, ok := serviceFeed(c, serviceRepo, versionRepo, accessRepo); ok {
+			writeRSS(c, f)
+		}
+	}
+}
+
+// GetCatalogAtomFeed serves the newest released versions of every service the
+// caller can read as an Atom feed
+func GetCatalogAtomFeed(serviceRepo repository.ServiceRepository, versionRepo repository.VersionRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if f, ok := catalogFeed(c, serviceRepo, versionRepo); ok {
+			writeAtom(c, f)
+		}
+	}
+}
+
+// GetCatalogRSSFeed serves the newest released versions of every service the
+// caller can read as an RSS feed
+func GetCatalogRSSFeed(serviceRepo repository.ServiceRepository, versionRepo repository.VersionRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if f, ok := catalogFeed(c, serviceRepo, versionRepo); ok {
+			writeRSS(c, f)
+		}
+	}
+}
+
+// serviceFeed loads the feed of a service, answering with an error when it cannot
+func serviceFeed(c *gin.Context, serviceRepo repository.ServiceRepository, versionRepo repository.VersionRepository, accessRepo repository.AccessRepository) (feed, bool) {
+	serviceID := c.Param("id")
+	ctx := c.Request.Context()
+
+	if err := app.Authorize(ctx, accessRepo, middleware.Principal(c), serviceID, models.PermissionRead); err != nil {
+		respondAccessError(c, err)
+		return feed{}, false
+	}
+
+	service, err := serviceRepo.GetServiceByID(ctx, middleware.OrgID(c), serviceID)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Service not found"})
+		return feed{}, false
+	}
+	if err != nil {
+		respondInternalError(c, err)
+		return feed{}, false
+	}
+	versions, err := versionRepo.GetReleasedVersions(ctx, middleware.Principal(c), service.ID, feedSize)
+	if err != nil {
+		respondInternalError(c, err)
+		return feed{}, false
+	}
+
+	return feed{
+		id:        "urn:uuid:" + service.ID,
+		title:     service.Name + " releases",
+		self:      c.Request.URL.Path,
+		alternate: serviceLink(apiPath(c), service.ID),
+		versions:  versions,
+		services:  map[string]models.Service{service.ID: *service},
+	}, true
+}
+
+// catalogFeed loads the feed of the whole catalog, answering with an error when it cannot
+func catalogFeed(c *gin.Context, serviceRepo repository.ServiceRepository, versionRepo repository.VersionRepository) (feed, bool) {
+	ctx := c.Request.Context()
+	principal := middleware.Principal(c)
+
+	versions, err := versionRepo.GetReleasedVersions(ctx, principal, "", feedSize)
+	if err != nil {
+		respondInternalError(c, err)
+		return feed{}, false
+	}
+	ids := make([]string, 0, len(versions))
+	for _, v := range versions {
+		ids = append(ids, v.ServiceID)
+	}
+	services, err := serviceRepo.GetServicesByIDs(ctx, principal, ids)
+	if err != nil {
+		respondInternalError(c, err)
+		return feed{}, false
+	}
+	byID := make(map[string]models.Service, len(services))
+	for _, s := range services {
+		byID[s.ID] = s
+	}
+
+	return feed{
+		id:        "urn:uuid:" + principal.OrgID,
+		title:     "Catalog releases",
+		self:      c.Request.URL.Path,
+		alternate: apiPath(c) + "/services",
+		versions:  versions,
+		services:  byID,
+	}, true
+}
+
+// updated is when the newest version of a feed was released, or the zero time
+// for an empty feed
+func (f feed) updated() time.Time {
+	var updated time.Time
+	for _, v := range f.versions {
+		if released := releasedAt(v); released.After(updated) {
+			updated = released
+		}
+	}
+	return updated
+}
+
+// releasedAt is when v was released, or created for versions released before
+// release times were recorded
+func releasedAt(v models.Version) time.Time {
+	if v.ReleasedAt != nil {
+		return *v.ReleasedAt
+	}
+	return v.CreatedAt
+}
+
+// writeAtom answers with f as an Atom feed. Links are absolute, since feed
+// readers keep entries apart from the feed.
+func writeAtom(c *gin.Context, f feed) {
+	base := requestBase(c)
+	api := apiPath(c)
+
+	updated := f.updated()
+	if updated.IsZero() {
+		updated = time.Now()
+	}
+	doc := atomFeed{
+		ID:      f.id,
+		Title:   f.title,
+		Updated: updated.UTC().Format(time.RFC3339),
+		Links: []atomLink{
+			{Rel: "self", Type: "application/atom+xml", Href: base + f.self},
+			{Rel: "alternate", Type: "application/json", Href: base + f.alternate},
+		},
+	}
+	for i := range f.versions {
+		v := &f.versions[i]
+		name := f.services[v.ServiceID].Name
+		doc.Entries = append(doc.Entries, atomEntry{
+			ID:       "urn:uuid:" + v.ID,
+			Title:    name + " " + v.Semver,
+			Updated:  releasedAt(*v).UTC().Format(time.RFC3339),
+			Author:   atomAuthor{Name: name},
+			Link:     atomLink{Rel: "alternate", Type: "application/json", Href: base + versionLink(api, v.ServiceID, v.ID)},
+			Category: atomTerm{Term: name},
+			Content:  atomContent{Type: "html", Body: feedContent(v)},
+		})
+	}
+	writeXML(c, mediaTypeAtom, doc)
+}
+
+// writeRSS answers with f as an RSS feed, with absolute links like writeAtom
+func writeRSS(c *gin.Context, f feed) {
+	base := requestBase(c)
+	api := apiPath(c)
+
+	doc := rssFeed{Version: "2.0", Channel: rssChannel{Title: f.title, Link: base + f.alternate, Description: f.title}}
+	if updated := f.updated(); !updated.IsZero() {
+		doc.Channel.LastBuildDate = updated.UTC().Format(time.RFC1123Z)
+	}
+	for i := range f.versions {
+		v := &f.versions[i]
+		name := f.services[v.ServiceID].Name
+		doc.Channel.Items = append(doc.Channel.Items, rssItem{
+			Title:       name + " " + v.Semver,
+			Link:        base + versionLink(api, v.ServiceID, v.ID),
+			GUID:        rssGUID{ID: "urn:uuid:" + v.ID},
+			PubDate:     releasedAt(*v).UTC().Format(time.RFC1123Z),
+			Category:    name,
+			Description: feedContent(v),
+		})
+	}
+	writeXML(c, mediaTypeRSS, doc)
+}
+
+// writeXML answers with doc as an XML document of contentType
+func writeXML(c *gin.Context, contentType string, doc interface{}) {
+	body, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+	c.Data(http.StatusOK, contentType, append([]byte(xml.Header), body...))
+}
+
+// feedContent is the changelog of a version rendered as sanitized HTML, or
+// the changelog itself should it fail to render
+func feedContent(v *models.Version) string {
+	if err := renderVersion(v); err != nil {
+		return v.Changelog
+	}
+	return v.ChangelogHTML
+}
+
+// requestBase is the scheme and host a request was made to, honoring the
+// X-Forwarded-Proto of a TLS-terminating proxy
+func requestBase(c *gin.Context) string {
+	scheme := "http"
+	if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + c.Request.Host
+}
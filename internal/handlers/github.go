@@ -0,0 +1,253 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/yashjain/konnect/internal/app"
+	"github.com/yashjain/konnect/internal/auth"
+	"github.com/yashjain/konnect/internal/middleware"
+	"github.com/yashjain/konnect/internal/models"
+	"github.com/yashjain/konnect/internal/repository"
+	"github.com/yashjain/konnect/internal/webhook"
+)
+
+// maxGitHubEventBytes bounds the body of a GitHub event
+const maxGitHubEventBytes = 5 << 20
+
+// githubRepositoryName matches a repository's owner/name
+var githubRepositoryName = regexp.MustCompile(`^[A-Za-z0-9](?:[A-Za-z0-9-]{0,38})/[A-Za-z0-9._-]{1,100}$`)
+
+// githubReleaseEvent is the part of a GitHub release event that becomes a version
+type githubReleaseEvent struct {
+	Action  string `json:"action"`
+	Release struct {
+		TagName    string `json:"tag_name"`
+		Body       string `json:"body"`
+		Draft      bool   `json:"draft"`
+		Prerelease bool   `json:"prerelease"`
+	} `json:"release"`
+	Repository struct {
+		FullName string `json:"full_name"`
+	} `json:"repository"`
+}
+
+// GetGitHubRepository gets the GitHub repository linked to a service
+func GetGitHubRepository(linkRepo repository.GitHubRepositoryRepository, accessRepo repository.AccessRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		serviceID := c.Param("id")
+
+		if err := app.Authorize(c.Request.Context(), accessRepo, middleware.Principal(c), serviceID, models.PermissionRead); err != nil {
+			respondAccessError(c, err)
+			return
+		}
+
+		link, err := linkRepo.GetGitHubRepository(c.Request.Context(), middleware.OrgID(c), serviceID)
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Service has no GitHub repository"})
+			return
+		}
+		if err != nil {
+			respondInternalError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, link)
+	}
+}
+
+// SetGitHubRepository links a GitHub repository to a service, with the secret
+// its webhook must sign events with
+func SetGitHubRepository(linkRepo repository.GitHubRepositoryRepository, accessRepo repository.AccessRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		serviceID := c.Param("id")
+
+		var link models.GitHubRepository
+		if err := c.ShouldBindJSON(&link); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if !githubRepositoryName.MatchString(link.Repository) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "repository must be owner/name"})
+			return
+		}
+		if link.Secret != "" && len(link.Secret) < minWebhookSecretLength {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("secret must be at least %d characters", minWebhookSecretLength)})
+			return
+		}
+
+		if err := app.Authorize(c.Request.Context(), accessRepo, middleware.Principal(c), serviceID, models.PermissionWrite); err != nil {
+			respondAccessError(c, err)
+			return
+		}
+
+		if link.Secret == "" {
+			secret, err := auth.GenerateToken()
+			if err != nil {
+				respondInternalError(c, err)
+				return
+			}
+			link.Secret = secret
+		}
+
+		link.ServiceID = serviceID
+		link.OrgID = middleware.OrgID(c)
+		err := linkRepo.SetGitHubRepository(c.Request.Context(), &link)
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Service not found"})
+			return
+		}
+		if errors.Is(err, repository.ErrConflict) {
+			c.JSON(http.StatusConflict, gin.H{"error": "The repository is linked to another service"})
+			return
+		}
+		if err != nil {
+			respondInternalError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, link)
+	}
+}
+
+// DeleteGitHubRepository unlinks the GitHub repository of a service
+func DeleteGitHubRepository(linkRepo repository.GitHubRepositoryRepository, accessRepo repository.AccessRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		serviceID := c.Param("id")
+
+		if err := app.Authorize(c.Request.Context(), accessRepo, middleware.Principal(c), serviceID, models.PermissionWrite); err != nil {
+			respondAccessError(c, err)
+			return
+		}
+
+		rowsAffected, err := linkRepo.DeleteGitHubRepository(c.Request.Context(), middleware.OrgID(c), serviceID)
+		if err != nil {
+			respondInternalError(c, err)
+			return
+		}
+		if rowsAffected == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Service has no GitHub repository"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "GitHub repository unlinked"})
+	}
+}
+
+// GitHubEvents receives GitHub events, turning published releases of linked
+// repositories into released versions. An event is only accepted when signed
+// with the secret of a link of its repository, which proves the repository's
+// admins configured its webhook for that link, and only acts on that link.
+func GitHubEvents(linkRepo repository.GitHubRepositoryRepository, versionRepo repository.VersionRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxGitHubEventBytes))
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("event must be at most %d bytes", maxGitHubEventBytes)})
+				return
+			}
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid event: " + err.Error()})
+			return
+		}
+
+		var event githubReleaseEvent
+		if err := json.Unmarshal(body, &event); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid event: " + err.Error()})
+			return
+		}
+
+		// Events of unlinked repositories are not told apart from forged ones,
+		// so that callers learn nothing of the links of other organizations
+		ctx := c.Request.Context()
+		links, err := linkRepo.FindGitHubRepositories(ctx, event.Repository.FullName)
+		if err != nil {
+			respondInternalError(c, err)
+			return
+		}
+		var link *models.GitHubRepository
+		for i := range links {
+			if webhook.VerifyGitHub(links[i].Secret, c.GetHeader(webhook.HeaderGitHubSignature), body) == nil {
+				link = &links[i]
+				break
+			}
+		}
+		if link == nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid signature"})
+			return
+		}
+
+		switch c.GetHeader(webhook.HeaderGitHubEvent) {
+		case "ping":
+			c.JSON(http.StatusOK, gin.H{"message": "pong"})
+			return
+		case "release":
+		default:
+			c.JSON(http.StatusOK, gin.H{"message": "Event ignored"})
+			return
+		}
+
+		// Releases published as such send published and released; prereleases
+		// only send released once they become releases
+		if event.Action != "published" && event.Action != "released" || event.Release.Draft || event.Release.Prerelease {
+			c.JSON(http.StatusOK, gin.H{"message": "Event ignored"})
+			return
+		}
+
+		version, err := validVersion(models.CatalogVersion{
+			Semver:    releaseSemver(event.Release.TagName),
+			Status:    models.VersionReleased,
+			Changelog: event.Release.Body,
+		})
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid release: " + err.Error()})
+			return
+		}
+
+		// Deliveries are retried, so a release seen before is not an error
+		existing, err := versionRepo.GetVersionBySemver(ctx, link.OrgID, link.ServiceID, version.Semver)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			respondInternalError(c, err)
+			return
+		}
+		if existing != nil {
+			c.JSON(http.StatusOK, existing)
+			return
+		}
+
+		version.ID = uuid.New().String()
+		version.ServiceID = link.ServiceID
+		err = versionRepo.CreateVersion(ctx, link.OrgID, version)
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Service not found"})
+			return
+		}
+		if err != nil {
+			respondInternalError(c, err)
+			return
+		}
+
+		slog.InfoContext(ctx, "Version created from GitHub release", "org_id", link.OrgID, "service_id", link.ServiceID,
+			"repository", link.Repository, "semver", version.Semver)
+		c.JSON(http.StatusCreated, version)
+	}
+}
+
+// releaseSemver returns the semver of a release tag, without the v prefix of tags like v1.2.0
+func releaseSemver(tag string) string {
+	tag = strings.TrimSpace(tag)
+	if len(tag) > 1 && (tag[0] == 'v' || tag[0] == 'V') && tag[1] >= '0' && tag[1] <= '9' {
+		return tag[1:]
+	}
+	return tag
+}
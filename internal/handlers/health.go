@@ -1,18 +1,147 @@
 package handlers
 
 import (
+	"context"
+	"log/slog"
 	"net/http"
+	"sync"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/yashjain/konnect/internal/logging"
+	"github.com/yashjain/konnect/internal/repository"
 )
 
-// HealthCheck godoc
-// @Summary Health check endpoint
-// @Description Check if the API is running
-// @Tags health
-// @Produce json
-// @Success 200 {object} map[string]interface{}
-// @Router /health [get]
-func HealthCheck(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+// dependencyCheckTimeout bounds each dependency check of a deep readiness check
+const dependencyCheckTimeout = 2 * time.Second
+
+// Dependency is a service requests depend on, such as the database, checked by DeepReadiness
+type Dependency struct {
+	Name string
+
+	// Critical dependencies are needed to serve any request; the API still
+	// serves most requests while others are down
+	Critical bool
+
+	// Check returns an error while the dependency cannot be used
+	Check func(ctx context.Context) error
+}
+
+// DependencyStatus is the outcome of a dependency check, with the last failure
+// of the dependency seen by the checks, if any
+type DependencyStatus struct {
+	Status      string     `json:"status"`
+	Critical    bool       `json:"critical"`
+	LatencyMS   float64    `json:"latency_ms"`
+	LastError   string     `json:"last_error,omitempty"`
+	LastErrorAt *time.Time `json:"last_error_at,omitempty"`
+}
+
+// dependencyErrors remembers the last failure of each dependency across checks
+type dependencyErrors struct {
+	mu     sync.Mutex
+	errors map[string]dependencyError
+}
+
+type dependencyError struct {
+	message string
+	at      time.Time
+}
+
+// HealthCheck checks that the API is running
+func HealthCheck(healthRepo repository.HealthRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if healthRepo.Health() != nil {
+			c.JSON(http.StatusOK, gin.H{"status": "degraded", "database": "down"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"status": "ok", "database": "up"})
+	}
+}
+
+// Readiness checks that the API can serve requests
+func Readiness(healthRepo repository.HealthRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if healthRepo.Health() != nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"status": "unavailable", "database": "down"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"status": "ready", "database": "up"})
+	}
+}
+
+// DeepReadiness checks each dependency of the API
+func DeepReadiness(deps ...Dependency) gin.HandlerFunc {
+	last := &dependencyErrors{errors: make(map[string]dependencyError, len(deps))}
+	return func(c *gin.Context) {
+		statuses := checkDependencies(c.Request.Context(), deps, last)
+		status, code := overallStatus(statuses)
+		c.JSON(code, gin.H{"status": status, "dependencies": statuses})
+	}
+}
+
+// overallStatus returns "ready" while every dependency is up, "degraded" while
+// only dependencies that are not critical are down, and "unavailable", with a
+// 503, while a critical dependency is down
+func overallStatus(statuses map[string]DependencyStatus) (string, int) {
+	status, code := "ready", http.StatusOK
+	for _, s := range statuses {
+		if s.Status == "up" {
+			continue
+		}
+		if s.Critical {
+			return "unavailable", http.StatusServiceUnavailable
+		}
+		status = "degraded"
+	}
+	return status, code
+}
+
+// checkDependencies checks deps concurrently, each bounded by dependencyCheckTimeout,
+// and returns the status of each. Failures are logged and remembered in last,
+// redacted like logs.
+func checkDependencies(ctx context.Context, deps []Dependency, last *dependencyErrors) map[string]DependencyStatus {
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		statuses = make(map[string]DependencyStatus, len(deps))
+	)
+	for _, dep := range deps {
+		wg.Add(1)
+		go func(dep Dependency) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, dependencyCheckTimeout)
+			defer cancel()
+
+			start := time.Now()
+			err := dep.Check(ctx)
+			status := DependencyStatus{
+				Status:    "up",
+				Critical:  dep.Critical,
+				LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+			}
+			if err != nil {
+				slog.WarnContext(ctx, "Dependency check failed", "dependency", dep.Name, "error", err)
+				status.Status = "down"
+			}
+
+			last.mu.Lock()
+			if err != nil {
+				last.errors[dep.Name] = dependencyError{message: logging.Redact(err.Error()), at: start.UTC()}
+			}
+			if e, ok := last.errors[dep.Name]; ok {
+				at := e.at
+				status.LastError, status.LastErrorAt = e.message, &at
+			}
+			last.mu.Unlock()
+
+			mu.Lock()
+			statuses[dep.Name] = status
+			mu.Unlock()
+		}(dep)
+	}
+	wg.Wait()
+	return statuses
 }
@@ -0,0 +1,274 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/yashjain/konnect/internal/middleware"
+	"github.com/yashjain/konnect/internal/models"
+	"github.com/yashjain/konnect/pkg/types"
+)
+
+// JSON:API resource types
+const (
+	jsonAPIServices = "services"
+	jsonAPIVersions = "versions"
+)
+
+// jsonAPIDocument is a JSON:API top-level document
+type jsonAPIDocument struct {
+	Data    interface{}            `json:"data"`
+	Links   map[string]string      `json:"links,omitempty"`
+	Meta    map[string]interface{} `json:"meta,omitempty"`
+	JSONAPI jsonAPIVersion         `json:"jsonapi"`
+}
+
+// jsonAPIVersion is the version of the specification a document follows
+type jsonAPIVersion struct {
+	Version string `json:"version"`
+}
+
+// jsonAPIResource is a JSON:API resource object
+type jsonAPIResource struct {
+	Type          string                         `json:"type"`
+	ID            string                         `json:"id"`
+	Attributes    interface{}                    `json:"attributes"`
+	Relationships map[string]jsonAPIRelationship `json:"relationships,omitempty"`
+	Links         map[string]string              `json:"links,omitempty"`
+}
+
+// jsonAPIRelationship is a relationship of a JSON:API resource
+type jsonAPIRelationship struct {
+	Data  *jsonAPIIdentifier     `json:"data,omitempty"`
+	Links map[string]string      `json:"links,omitempty"`
+	Meta  map[string]interface{} `json:"meta,omitempty"`
+}
+
+// jsonAPIIdentifier is a JSON:API resource identifier object
+type jsonAPIIdentifier struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+// serviceAttributes are the attributes of a services resource
+type serviceAttributes struct {
+	Name            string            `json:"name"`
+	Slug            string            `json:"slug"`
+	Description     string            `json:"description"`
+	Visibility      string            `json:"visibility"`
+	OwnerTeam       string            `json:"owner_team"`
+	OwnerEmail      string            `json:"owner_email"`
+	Tags            []string          `json:"tags"`
+	Metadata        map[string]string `json:"metadata"`
+	VersionsCount   int               `json:"versions_count"`
+	SpecScore       *int              `json:"spec_score"`
+	CreatedAt       time.Time         `json:"created_at"`
+	UpdatedAt       time.Time         `json:"updated_at"`
+	DeletedAt       *time.Time        `json:"deleted_at,omitempty"`
+	Score           *float64          `json:"score,omitempty"`
+	DescriptionHTML string            `json:"description_html,omitempty"`
+}
+
+// versionAttributes are the attributes of a versions resource
+type versionAttributes struct {
+	Semver        string            `json:"semver"`
+	Status        string            `json:"status"`
+	Changelog     string            `json:"changelog"`
+	Metadata      map[string]string `json:"metadata"`
+	Environments  []string          `json:"environments"`
+	HasSpec       bool              `json:"has_spec"`
+	SpecScore     *int              `json:"spec_score"`
+	CreatedAt     time.Time         `json:"created_at"`
+	DeprecatedAt  *time.Time        `json:"deprecated_at"`
+	SunsetAt      *time.Time        `json:"sunset_at"`
+	ReleasedAt    *time.Time        `json:"released_at"`
+	DeletedAt     *time.Time        `json:"deleted_at,omitempty"`
+	ChangelogHTML string            `json:"changelog_html,omitempty"`
+}
+
+// serviceResource renders a service as a JSON:API resource, related to its versions
+func serviceResource(api string, s models.Service) jsonAPIResource {
+	team, email := s.Owner()
+	return jsonAPIResource{
+		Type: jsonAPIServices,
+		ID:   s.ID,
+		Attributes: serviceAttributes{
+			Name:            s.Name,
+			Slug:            s.Slug,
+			Description:     s.Description,
+			Visibility:      s.Visibility,
+			OwnerTeam:       team,
+			OwnerEmail:      email,
+			Tags:            s.Tags,
+			Metadata:        s.Metadata,
+			VersionsCount:   s.VersionsCount,
+			SpecScore:       s.SpecScore,
+			CreatedAt:       s.CreatedAt,
+			UpdatedAt:       s.UpdatedAt,
+			DeletedAt:       s.DeletedAt,
+			Score:           s.Score,
+			DescriptionHTML: s.DescriptionHTML,
+		},
+		Relationships: map[string]jsonAPIRelationship{
+			jsonAPIVersions: {
+				Links: map[string]string{"related": versionsLink(api, s.ID)},
+				Meta:  map[string]interface{}{"count": s.VersionsCount},
+			},
+		},
+		Links: map[string]string{"self": serviceLink(api, s.ID)},
+	}
+}
+
+// versionResource renders a version as a JSON:API resource, related to its service
+func versionResource(api string, v models.Version) jsonAPIResource {
+	return jsonAPIResource{
+		Type: jsonAPIVersions,
+		ID:   v.ID,
+		Attributes: versionAttributes{
+			Semver:        v.Semver,
+			Status:        v.Status,
+			Changelog:     v.Changelog,
+			Metadata:      v.Metadata,
+			Environments:  v.Environments,
+			HasSpec:       v.HasSpec,
+			SpecScore:     v.SpecScore,
+			CreatedAt:     v.CreatedAt,
+			DeprecatedAt:  v.DeprecatedAt,
+			SunsetAt:      v.SunsetAt,
+			ReleasedAt:    v.ReleasedAt,
+			DeletedAt:     v.DeletedAt,
+			ChangelogHTML: v.ChangelogHTML,
+		},
+		Relationships: map[string]jsonAPIRelationship{
+			"service": {
+				Data:  &jsonAPIIdentifier{Type: jsonAPIServices, ID: v.ServiceID},
+				Links: map[string]string{"related": serviceLink(api, v.ServiceID)},
+			},
+		},
+		Links: map[string]string{"self": versionLink(api, v.ServiceID, v.ID)},
+	}
+}
+
+// respondJSONAPI writes a JSON:API document with data, linked to the request
+func respondJSONAPI(c *gin.Context, status int, data interface{}, links map[string]string, meta map[string]interface{}) {
+	if links == nil {
+		links = map[string]string{}
+	}
+	links["self"] = c.Request.URL.RequestURI()
+
+	c.Header("Content-Type", middleware.MediaTypeJSONAPI)
+	if status == http.StatusCreated {
+		if resource, ok := data.(jsonAPIResource); ok && resource.Links["self"] != "" {
+			c.Header("Location", resource.Links["self"])
+		}
+	}
+	c.JSON(status, jsonAPIDocument{Data: data, Links: links, Meta: meta, JSONAPI: jsonAPIVersion{Version: "1.1"}})
+}
+
+// respondServices writes a page of services, as JSON:API when it was negotiated
+func respondServices(c *gin.Context, response types.PaginatedResponse, services []models.Service) {
+	api := apiPath(c)
+	setPageHeaders(c, response.Pagination)
+	if !middleware.WantsJSONAPI(c) {
+		for i := range services {
+			linkService(api, &services[i])
+		}
+		response.Links = responseLinks(c, response.Pagination)
+		c.JSON(http.StatusOK, response)
+		return
+	}
+	resources := make([]jsonAPIResource, len(services))
+	for i, s := range services {
+		resources[i] = serviceResource(api, s)
+	}
+	meta := map[string]interface{}{"pagination": response.Pagination}
+	if response.Facets != nil {
+		meta["facets"] = response.Facets
+	}
+	respondJSONAPI(c, http.StatusOK, resources, pageLinks(c, response.Pagination), meta)
+}
+
+// respondVersions writes a page of versions, as JSON:API when it was negotiated
+func respondVersions(c *gin.Context, response types.PaginatedResponse, versions []models.Version) {
+	api := apiPath(c)
+	setPageHeaders(c, response.Pagination)
+	if !middleware.WantsJSONAPI(c) {
+		for i := range versions {
+			linkVersion(api, &versions[i])
+		}
+		response.Links = responseLinks(c, response.Pagination)
+		c.JSON(http.StatusOK, response)
+		return
+	}
+	resources := make([]jsonAPIResource, len(versions))
+	for i, v := range versions {
+		resources[i] = versionResource(api, v)
+	}
+	respondJSONAPI(c, http.StatusOK, resources, pageLinks(c, response.Pagination), map[string]interface{}{"pagination": response.Pagination})
+}
+
+// respondService writes a service, as JSON:API when it was negotiated
+func respondService(c *gin.Context, status int, service *models.Service) {
+	api := apiPath(c)
+	if !middleware.WantsJSONAPI(c) {
+		linkService(api, service)
+		if status == http.StatusCreated {
+			c.Header("Location", service.Links["self"].Href)
+		}
+		c.JSON(status, service)
+		return
+	}
+	respondJSONAPI(c, status, serviceResource(api, *service), nil, nil)
+}
+
+// respondVersion writes a version, as JSON:API when it was negotiated
+func respondVersion(c *gin.Context, status int, version *models.Version) {
+	api := apiPath(c)
+	if !middleware.WantsJSONAPI(c) {
+		linkVersion(api, version)
+		if status == http.StatusCreated {
+			c.Header("Location", version.Links["self"].Href)
+		}
+		c.JSON(status, version)
+		return
+	}
+	respondJSONAPI(c, status, versionResource(api, *version), nil, nil)
+}
+
+// bindResource binds a request body to obj: a JSON:API document with a resource
+// of resourceType when the body is sent as application/vnd.api+json, whose
+// attributes are read like a plain JSON body, and a plain JSON body otherwise
+func bindResource(c *gin.Context, resourceType string, obj interface{}) error {
+	mediaType, _, err := mime.ParseMediaType(c.GetHeader("Content-Type"))
+	if err != nil || mediaType != middleware.MediaTypeJSONAPI {
+		return c.ShouldBindJSON(obj)
+	}
+
+	var doc struct {
+		Data *struct {
+			Type       string          `json:"type"`
+			Attributes json.RawMessage `json:"attributes"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(c.Request.Body).Decode(&doc); err != nil {
+		return err
+	}
+	if doc.Data == nil {
+		return errors.New("data is required")
+	}
+	if doc.Data.Type != resourceType {
+		return fmt.Errorf("data.type must be %s", resourceType)
+	}
+	if len(doc.Data.Attributes) > 0 {
+		if err := json.Unmarshal(doc.Data.Attributes, obj); err != nil {
+			return err
+		}
+	}
+	return binding.Validator.ValidateStruct(obj)
+}
@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/yashjain/konnect/internal/app"
+	"github.com/yashjain/konnect/internal/audit"
+	"github.com/yashjain/konnect/internal/kong"
+	"github.com/yashjain/konnect/internal/middleware"
+	"github.com/yashjain/konnect/internal/models"
+	"github.com/yashjain/konnect/internal/outbox"
+	"github.com/yashjain/konnect/internal/repository"
+	"github.com/yashjain/konnect/pkg/utils"
+)
+
+// KongSyncer syncs a service to the Kong Gateway straight away, returning the outcome
+type KongSyncer interface {
+	Sync(ctx context.Context, orgID, serviceID string) (*models.KongSync, error)
+}
+
+// KongReader lists the services and routes of the Kong Gateway
+type KongReader interface {
+	ListServices(ctx context.Context) ([]kong.Service, error)
+	ListRoutes(ctx context.Context) ([]kong.Route, error)
+}
+
+// GetServiceSync gets the status of a service's sync to Kong
+func GetServiceSync(syncRepo repository.KongSyncRepository, accessRepo repository.AccessRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		serviceID := c.Param("id")
+
+		if err := app.Authorize(c.Request.Context(), accessRepo, middleware.Principal(c), serviceID, models.PermissionRead); err != nil {
+			respondAccessError(c, err)
+			return
+		}
+
+		sync, err := syncRepo.GetKongSync(c.Request.Context(), middleware.OrgID(c), serviceID)
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Service has not been synced to Kong"})
+			return
+		}
+		if err != nil {
+			respondInternalError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, sync)
+	}
+}
+
+// SyncService syncs a service to Kong
+func SyncService(syncer KongSyncer, accessRepo repository.AccessRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		serviceID := c.Param("id")
+
+		if err := app.Authorize(c.Request.Context(), accessRepo, middleware.Principal(c), serviceID, models.PermissionWrite); err != nil {
+			respondAccessError(c, err)
+			return
+		}
+
+		sync, err := syncer.Sync(c.Request.Context(), middleware.OrgID(c), serviceID)
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Service not found"})
+			return
+		}
+		if err != nil {
+			respondInternalError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, sync)
+	}
+}
+
+// ImportKong imports the services of the Kong Gateway into the catalog
+func ImportKong(reader KongReader, serviceRepo repository.ServiceRepository, versionRepo repository.VersionRepository, accessRepo repository.AccessRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		onConflict, dryRun, ok := importOptions(c)
+		if !ok {
+			return
+		}
+
+		services, err := reader.ListServices(c.Request.Context())
+		if err != nil {
+			c.JSON(http.StatusBadGateway, gin.H{"error": "reading Kong: " + err.Error()})
+			return
+		}
+		routes, err := reader.ListRoutes(c.Request.Context())
+		if err != nil {
+			c.JSON(http.StatusBadGateway, gin.H{"error": "reading Kong: " + err.Error()})
+			return
+		}
+
+		imp := newCatalogImport(c, serviceRepo, versionRepo, accessRepo, nil, onConflict, dryRun)
+		if err := imp.importServices(c.Request.Context(), kongCatalog(services, routes)); err != nil {
+			respondInternalError(c, err)
+			return
+		}
+
+		if !dryRun {
+			audit.Log(c.Request.Context(), "Kong services imported", "on_conflict", onConflict,
+				"created", imp.report.Created, "updated", imp.report.Updated, "skipped", imp.report.Skipped, "failed", imp.report.Failed)
+		}
+		c.JSON(http.StatusOK, imp.report)
+	}
+}
+
+// kongCatalog turns Kong services into catalog services, leaving out those
+// synced from a catalog. Each is named after its Kong service, or its host when
+// it has no name, is private, keeps the Kong tags the catalog accepts, and
+// describes its upstream and the paths of its routes.
+func kongCatalog(services []kong.Service, routes []kong.Route) []models.CatalogService {
+	paths := make(map[string][]string)
+	for _, r := range routes {
+		if r.Service != nil {
+			paths[r.Service.ID] = append(paths[r.Service.ID], r.Paths...)
+		}
+	}
+
+	catalog := []models.CatalogService{}
+	for _, s := range services {
+		if slices.Contains(s.Tags, outbox.KongTag) {
+			continue
+		}
+		name := s.Name
+		if name == "" {
+			name = s.Host
+		}
+		catalog = append(catalog, models.CatalogService{
+			Name:        name,
+			Slug:        name,
+			Description: kongDescription(s, paths[s.ID]),
+			Visibility:  models.VisibilityPrivate,
+			Tags:        kongTags(s.Tags),
+		})
+	}
+	sort.Slice(catalog, func(i, j int) bool { return catalog[i].Slug < catalog[j].Slug })
+	return catalog
+}
+
+// kongDescription describes the upstream of a Kong service and the paths its routes match
+func kongDescription(s kong.Service, paths []string) string {
+	upstream := s.Protocol + "://" + s.Host
+	if s.Port != 0 {
+		upstream += ":" + strconv.Itoa(s.Port)
+	}
+	upstream += s.Path
+
+	description := fmt.Sprintf("Imported from Kong. Proxies to `%s`.", upstream)
+	if len(paths) > 0 {
+		sort.Strings(paths)
+		description += fmt.Sprintf(" Routes match `%s`.", strings.Join(slices.Compact(paths), "`, `"))
+	}
+	return description
+}
+
+// kongTags keeps the Kong tags the catalog accepts, up to utils.MaxTags
+func kongTags(tags []string) []string {
+	kept := []string{}
+	for _, tag := range tags {
+		if len(kept) == utils.MaxTags {
+			break
+		}
+		if normalized, err := utils.NormalizeTags([]string{tag}); err == nil && !slices.Contains(kept, normalized[0]) {
+			kept = append(kept, normalized[0])
+		}
+	}
+	return kept
+}
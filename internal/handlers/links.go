@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yashjain/konnect/internal/middleware"
+	"github.com/yashjain/konnect/internal/models"
+	"github.com/yashjain/konnect/pkg/types"
+)
+
+// latestVersion stands for a service's newest version in version paths
+const latestVersion = "latest"
+
+// apiPath is the path of the API version a request was made to, such as
+// /api/v1, which links stay within
+func apiPath(c *gin.Context) string {
+	return "/api/v" + strconv.Itoa(middleware.APIVersion(c))
+}
+
+// serviceLink is the path of a service in the API at api
+func serviceLink(api, id string) string {
+	return api + "/services/" + url.PathEscape(id)
+}
+
+// versionsLink is the path of a service's versions in the API at api
+func versionsLink(api, serviceID string) string {
+	return serviceLink(api, serviceID) + "/versions"
+}
+
+// versionLink is the path of a version of a service in the API at api
+func versionLink(api, serviceID, id string) string {
+	return versionsLink(api, serviceID) + "/" + url.PathEscape(id)
+}
+
+// linkService sets the links of a service to itself, its versions and, when it
+// has any, its newest version
+func linkService(api string, s *models.Service) {
+	s.Links = types.Links{
+		"self":     {Href: serviceLink(api, s.ID)},
+		"versions": {Href: versionsLink(api, s.ID)},
+	}
+	if s.VersionsCount > 0 {
+		s.Links["latest"] = types.Link{Href: versionLink(api, s.ID, latestVersion)}
+	}
+}
+
+// linkVersion sets the links of a version to itself, its service, the
+// service's versions and, when it has one, its OpenAPI document
+func linkVersion(api string, v *models.Version) {
+	v.Links = types.Links{
+		"self":     {Href: versionLink(api, v.ServiceID, v.ID)},
+		"service":  {Href: serviceLink(api, v.ServiceID)},
+		"versions": {Href: versionsLink(api, v.ServiceID)},
+	}
+	if v.HasSpec {
+		v.Links["spec"] = types.Link{Href: versionLink(api, v.ServiceID, v.ID) + "/spec"}
+	}
+}
+
+// responseLinks returns the links of a page of a list to itself and to the
+// pages pageLinks links it to
+func responseLinks(c *gin.Context, p types.Pagination) types.Links {
+	links := types.Links{"self": {Href: c.Request.URL.RequestURI()}}
+	for rel, href := range pageLinks(c, p) {
+		links[rel] = types.Link{Href: href}
+	}
+	return links
+}
+
+// pageRels are the relations of page links, in the order Link headers list them
+var pageRels = []string{"first", "prev", "next", "last"}
+
+// setPageHeaders describes a page of a list in headers for clients that do not
+// read the response body's pagination: an RFC 5988 Link header with the links
+// of pageLinks, and X-Total-Count when the total was counted
+func setPageHeaders(c *gin.Context, p types.Pagination) {
+	links := pageLinks(c, p)
+	header := make([]string, 0, len(pageRels))
+	for _, rel := range pageRels {
+		if href, ok := links[rel]; ok {
+			header = append(header, "<"+href+`>; rel="`+rel+`"`)
+		}
+	}
+	// Added rather than set, next to links such as the successor of a deprecated API
+	c.Writer.Header().Add("Link", strings.Join(header, ", "))
+	if p.Total != nil {
+		c.Header("X-Total-Count", strconv.Itoa(*p.Total))
+	}
+}
+
+// pageLinks returns the links to the first, previous and next pages of a list,
+// and to the last page when it was counted, as the request's URL with its paging
+// parameters replaced
+func pageLinks(c *gin.Context, p types.Pagination) map[string]string {
+	link := func(set map[string]string) string {
+		query := c.Request.URL.Query()
+		query.Del("page")
+		query.Del("cursor")
+		for k, v := range set {
+			query.Set(k, v)
+		}
+		u := url.URL{Path: c.Request.URL.Path, RawQuery: query.Encode()}
+		return u.String()
+	}
+
+	links := map[string]string{"first": link(nil)}
+	if p.HasPrev && p.Page > 1 {
+		links["prev"] = link(map[string]string{"page": strconv.Itoa(p.Page - 1)})
+	}
+	switch {
+	case p.NextCursor != "":
+		links["next"] = link(map[string]string{"cursor": p.NextCursor})
+	case p.HasNext:
+		links["next"] = link(map[string]string{"page": strconv.Itoa(p.Page + 1)})
+	}
+	if p.TotalPages != nil && p.Page > 0 && *p.TotalPages > 0 {
+		links["last"] = link(map[string]string{"page": strconv.Itoa(*p.TotalPages)})
+	}
+	return links
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yashjain/konnect/internal/audit"
+	"github.com/yashjain/konnect/internal/logging"
+	"github.com/yashjain/konnect/internal/middleware"
+	"github.com/yashjain/konnect/internal/models"
+)
+
+// GetLogLevel gets the log level
+func GetLogLevel(level *slog.LevelVar) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, models.LogLevel{Level: levelName(level.Level())})
+	}
+}
+
+// SetLogLevel sets the log level
+func SetLogLevel(level *slog.LevelVar) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var body models.LogLevel
+		if err := c.ShouldBindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		parsed, err := logging.ParseLevel(body.Level)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		// Recorded before the change, so it is logged whatever the new level
+		previous := level.Level()
+		audit.Log(c.Request.Context(), "Log level changed", "old", levelName(previous), "new", levelName(parsed))
+		level.Set(parsed)
+
+		c.JSON(http.StatusOK, models.LogLevel{Level: levelName(parsed)})
+	}
+}
+
+// levelName returns the LOG_LEVEL value naming level
+func levelName(level slog.Level) string {
+	return strings.ToLower(level.String())
+}
+
+// defaultCaptureTTL is how long body capture lasts when no ttl is given
+const defaultCaptureTTL = 15 * time.Minute
+
+// GetDebugCapture lists body capture rules
+func GetDebugCapture(capture *middleware.BodyCapture) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"data": capture.Rules()})
+	}
+}
+
+// EnableDebugCapture captures request and response bodies
+func EnableDebugCapture(capture *middleware.BodyCapture) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var body models.DebugCapture
+		if err := c.ShouldBindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		ttl := defaultCaptureTTL
+		if body.TTL != "" {
+			parsed, err := time.ParseDuration(body.TTL)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "ttl must be a duration such as 15m"})
+				return
+			}
+			ttl = parsed
+		}
+
+		rule, err := capture.Enable(strings.TrimSpace(body.Route), strings.TrimSpace(body.RequestID), ttl)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		audit.Log(c.Request.Context(), "Body capture enabled", "route", rule.Route, "capture_request_id", rule.RequestID, "expires", rule.Expires)
+
+		c.JSON(http.StatusCreated, gin.H{"data": rule})
+	}
+}
+
+// DisableDebugCapture stops capturing request and response bodies
+func DisableDebugCapture(capture *middleware.BodyCapture) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		capture.Disable()
+		audit.Log(c.Request.Context(), "Body capture disabled")
+		c.Status(http.StatusNoContent)
+	}
+}
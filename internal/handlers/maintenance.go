@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yashjain/konnect/internal/repository"
+)
+
+// ReindexSearch rebuilds the search index
+func ReindexSearch(maintenanceRepo repository.MaintenanceRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := maintenanceRepo.ReindexServices(c.Request.Context()); err != nil {
+			respondInternalError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Search index rebuilt"})
+	}
+}
+
+// ArchiveVersions archives deleted versions
+func ArchiveVersions(maintenanceRepo repository.MaintenanceRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		olderThan, err := time.ParseDuration(c.Query("older_than"))
+		if err != nil || olderThan < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "older_than must be a non-negative duration such as 720h"})
+			return
+		}
+
+		result, err := maintenanceRepo.ArchiveVersions(c.Request.Context(), time.Now().Add(-olderThan))
+		if err != nil {
+			respondInternalError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Versions archived", "archived": result.Versions})
+	}
+}
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/yashjain/konnect/internal/app"
+	"github.com/yashjain/konnect/internal/auth"
+	"github.com/yashjain/konnect/internal/middleware"
+	"github.com/yashjain/konnect/internal/models"
+	"github.com/yashjain/konnect/internal/repository"
+)
+
+// requireUser answers 403 unless the request is made by a user: notifications
+// are emailed to users, so organization-wide tokens have none
+func requireUser(c *gin.Context) (auth.Principal, bool) {
+	principal := middleware.Principal(c)
+	if principal.IsOrgWide() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "notifications can only be managed with a user's token"})
+		return principal, false
+	}
+	return principal, true
+}
+
+// GetNotificationPreferences gets the caller's notification preferences
+func GetNotificationPreferences(notificationRepo repository.NotificationRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := requireUser(c)
+		if !ok {
+			return
+		}
+
+		prefs, err := notificationRepo.GetNotificationPreferences(c.Request.Context(), principal.OrgID, principal.UserID)
+		if err != nil {
+			respondInternalError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, prefs)
+	}
+}
+
+// SetNotificationPreferences replaces the caller's notification preferences
+func SetNotificationPreferences(notificationRepo repository.NotificationRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := requireUser(c)
+		if !ok {
+			return
+		}
+
+		var prefs models.NotificationPreferences
+		if err := c.ShouldBindJSON(&prefs); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		prefs.UserID = principal.UserID
+		prefs.OrgID = principal.OrgID
+		err := notificationRepo.SetNotificationPreferences(c.Request.Context(), &prefs)
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		if err != nil {
+			respondInternalError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, prefs)
+	}
+}
+
+// SubscribeToService subscribes the caller to the notifications of a service
+func SubscribeToService(notificationRepo repository.NotificationRepository, accessRepo repository.AccessRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := requireUser(c)
+		if !ok {
+			return
+		}
+		serviceID := c.Param("id")
+
+		if err := app.Authorize(c.Request.Context(), accessRepo, principal, serviceID, models.PermissionRead); err != nil {
+			respondAccessError(c, err)
+			return
+		}
+
+		err := notificationRepo.SubscribeToService(c.Request.Context(), principal.OrgID, serviceID, principal.UserID)
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Service not found"})
+			return
+		}
+		if err != nil {
+			respondInternalError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Subscribed to service"})
+	}
+}
+
+// UnsubscribeFromService removes the caller's subscription to a service
+func UnsubscribeFromService(notificationRepo repository.NotificationRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := requireUser(c)
+		if !ok {
+			return
+		}
+
+		rowsAffected, err := notificationRepo.UnsubscribeFromService(c.Request.Context(), principal.OrgID, c.Param("id"), principal.UserID)
+		if err != nil {
+			respondInternalError(c, err)
+			return
+		}
+		if rowsAffected == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Not subscribed to service"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Unsubscribed from service"})
+	}
+}
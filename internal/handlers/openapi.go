@@ -0,0 +1,1155 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yashjain/konnect/internal/middleware"
+	"github.com/yashjain/konnect/internal/models"
+	"github.com/yashjain/konnect/internal/openapi"
+	"github.com/yashjain/konnect/internal/webhook"
+	"github.com/yashjain/konnect/pkg/types"
+)
+
+// APISpec documents the operations of every handler, for openapi.Generate to
+// document the routes they are registered on. Errors are documented as problem
+// details, or as {"error": "..."} bodies when legacyErrors is set, except in
+// API v2, which always answers with problem details. API v1 is deprecated.
+func APISpec(legacyErrors bool) openapi.Spec {
+	problems := openapi.ErrorFormat{Body: middleware.Problem{}, MediaType: middleware.MediaTypeProblem}
+	spec := openapi.Spec{
+		Info: openapi.Info{
+			Title:       "Services API",
+			Version:     "2.0",
+			Description: "A REST API for managing services and their versions",
+			License:     &openapi.License{Name: "MIT", Identifier: "MIT"},
+		},
+		SecuritySchemes: map[string]openapi.SecurityScheme{
+			"BearerAuth": {Type: "http", Scheme: "bearer", Description: "Organization API token or access token"},
+			"AdminAuth":  {Type: "http", Scheme: "bearer", Description: "Admin token"},
+		},
+		Errors:     problems,
+		PathErrors: map[string]openapi.ErrorFormat{"/api/v2": problems},
+		Operations: Operations,
+		Deprecated: []string{"/api/v1"},
+	}
+	if legacyErrors {
+		spec.Errors = openapi.ErrorFormat{Body: middleware.ErrorBody{}, MediaType: openapi.MediaTypeJSON}
+	}
+	return spec
+}
+
+// OpenAPI serves doc, the OpenAPI document of the router it is registered on
+func OpenAPI(doc *openapi.Document) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, doc)
+	}
+}
+
+// Operations document each handler's operation by the handler's name. Paths,
+// methods and path parameters come from the routes, and the schemas of bodies
+// from their types, binding rules included.
+var Operations = map[string]openapi.Operation{
+	// Access control
+	"GetServiceACLs": {
+		Summary:     "List service ACL grants",
+		Description: "List the users and teams granted access to a service",
+		Tags:        []string{"acl"},
+		Security:    "BearerAuth",
+		Parameters: []openapi.Parameter{
+			openapi.Path("id", "Service ID"),
+		},
+		Responses: map[int]interface{}{http.StatusOK: map[string]interface{}{}},
+		Errors:    []int{http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusInternalServerError},
+	},
+	"CreateServiceACL": {
+		Summary:     "Grant access to a service",
+		Description: "Grant a user or team read or write access to a service. Granting to a subject that already has a grant replaces its permission.",
+		Tags:        []string{"acl"},
+		Security:    "BearerAuth",
+		Parameters: []openapi.Parameter{
+			openapi.Path("id", "Service ID"),
+		},
+		Body:      models.ServiceACL{},
+		Responses: map[int]interface{}{http.StatusCreated: models.ServiceACL{}},
+		Errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusInternalServerError},
+	},
+	"DeleteServiceACL": {
+		Summary:     "Revoke access to a service",
+		Description: "Remove an ACL grant from a service",
+		Tags:        []string{"acl"},
+		Security:    "BearerAuth",
+		Parameters: []openapi.Parameter{
+			openapi.Path("id", "Service ID"),
+			openapi.Path("acl_id", "ACL grant ID"),
+		},
+		Responses: map[int]interface{}{http.StatusOK: map[string]interface{}{}},
+		Errors:    []int{http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusInternalServerError},
+	},
+
+	// Login
+	"Login": {
+		Summary:     "Log in",
+		Description: "Exchange an email and password for a short-lived access token and a refresh token",
+		Tags:        []string{"auth"},
+		Body:        models.LoginRequest{},
+		Responses:   map[int]interface{}{http.StatusOK: models.TokenResponse{}},
+		Errors:      []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusTooManyRequests, http.StatusInternalServerError},
+	},
+	"Refresh": {
+		Summary:     "Refresh an access token",
+		Description: "Exchange a refresh token for a new access token. The refresh token is rotated: the presented token is revoked and a new one is returned. Presenting a revoked refresh token revokes every token descended from the same login.",
+		Tags:        []string{"auth"},
+		Body:        models.RefreshRequest{},
+		Responses:   map[int]interface{}{http.StatusOK: models.TokenResponse{}},
+		Errors:      []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusTooManyRequests, http.StatusInternalServerError},
+	},
+
+	// Backup and restore
+	"ExportBackup": {
+		Summary:     "Export a backup",
+		Description: "Stream a consistent NDJSON dump of every organization, service and version, including soft-deleted ones, with the specs, deployments and environments of versions, the categories of services, users with their password hashes, teams, ACL grants and consumers. API tokens and sessions are left out and must be issued again after a restore (admin only)",
+		Tags:        []string{"admin"},
+		Security:    "AdminAuth",
+		Responses:   map[int]interface{}{http.StatusOK: models.BackupRecord{}},
+		Errors:      []int{http.StatusUnauthorized, http.StatusInternalServerError},
+	},
+	"RestoreBackup": {
+		Summary:     "Restore a backup",
+		Description: "Load an NDJSON backup produced by GET /admin/backup in one transaction. Existing rows are kept, so a backup can be restored more than once (admin only)",
+		Tags:        []string{"admin"},
+		Security:    "AdminAuth",
+		Responses:   map[int]interface{}{http.StatusOK: models.RestoreResult{}},
+		Errors:      []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusInternalServerError},
+	},
+
+	// Catalog
+	"ExportCatalog": {
+		Summary:     "Export the catalog",
+		Description: "Download every service visible to the caller with its versions, oldest first, as JSON or YAML that POST /import accepts",
+		Tags:        []string{"catalog"},
+		Security:    "BearerAuth",
+		Parameters: []openapi.Parameter{
+			openapi.Query("format", openapi.String().OneOf("json", "yaml"), "json (default) or yaml"),
+		},
+		Produces:  []string{openapi.MediaTypeJSON, "application/yaml"},
+		Responses: map[int]interface{}{http.StatusOK: models.Catalog{}},
+		Errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusInternalServerError},
+	},
+	"ExportBackstage": {
+		Summary:     "Export the catalog to Backstage",
+		Description: "Download every service visible to the caller as a Backstage Component, and each of its released versions as an API the Component provides, in one multi-document catalog-info.yaml for Backstage to ingest. Entities are tied to their rows by the konnect/service-id and konnect/version-id annotations.",
+		Tags:        []string{"catalog"},
+		Security:    "BearerAuth",
+		Parameters: []openapi.Parameter{
+			openapi.Query("owner", openapi.String(), "Backstage entity reference owning the entities of services without an owner_team, such as group:default/platform (default: unknown); the others are owned by group:default/<owner_team>"),
+		},
+		Produces:  []string{"application/yaml"},
+		Responses: map[int]interface{}{http.StatusOK: ""},
+		Errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusInternalServerError},
+	},
+	"ImportCatalog": {
+		Summary:     "Import a catalog",
+		Description: "Create or update services and their versions from a catalog in the format of GET /export, sent as JSON or YAML. Services are matched by slug and versions by semver; on_conflict decides whether existing ones are kept or overwritten. Each service and version succeeds or fails on its own, and the report lists the outcome of each.",
+		Tags:        []string{"catalog"},
+		Security:    "BearerAuth",
+		Parameters: []openapi.Parameter{
+			openapi.Query("dry_run", openapi.Boolean(), "Set to true to validate and report what would change without changing anything"),
+			openapi.Query("on_conflict", openapi.String().OneOf("skip", "overwrite"), "skip (default) to keep existing services and versions, or overwrite to update them"),
+		},
+		Body:      models.Catalog{},
+		Consumes:  []string{openapi.MediaTypeJSON, "application/yaml"},
+		Responses: map[int]interface{}{http.StatusOK: models.ImportReport{}},
+		Errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusRequestEntityTooLarge, http.StatusUnsupportedMediaType, http.StatusInternalServerError},
+	},
+
+	// Configuration
+	"ReloadConfig": {
+		Summary:     "Reload the configuration",
+		Description: "Re-read CONFIG_FILE and apply the settings that can change without a restart, such as LOG_LEVEL, the access log settings and the in-flight request limits (admin only)",
+		Tags:        []string{"admin"},
+		Security:    "AdminAuth",
+		Responses:   map[int]interface{}{http.StatusOK: map[string]interface{}{}},
+		Errors:      []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusConflict},
+	},
+
+	// Declarative config
+	"DumpConfig": {
+		Summary:     "Dump the declarative config",
+		Description: "Download every service visible to the caller with its versions as one YAML document of the desired state, which POST /config/apply accepts",
+		Tags:        []string{"catalog"},
+		Security:    "BearerAuth",
+		Produces:    []string{"application/yaml"},
+		Responses:   map[int]interface{}{http.StatusOK: models.Catalog{}},
+		Errors:      []int{http.StatusUnauthorized, http.StatusInternalServerError},
+	},
+	"ApplyConfig": {
+		Summary:     "Apply a declarative config",
+		Description: "Make the services visible to the caller match a config in the format of GET /config/dump, sent as YAML or JSON: missing services and versions are created, differing ones updated and services left out deleted. The report lists each change with the fields it changes. A config with any change that is invalid or not allowed, including leaving out a version, is answered with 422 and not applied at all.",
+		Tags:        []string{"catalog"},
+		Security:    "BearerAuth",
+		Parameters: []openapi.Parameter{
+			openapi.Query("dry_run", openapi.Boolean(), "Set to true to preview the changes without making them"),
+		},
+		Body:      models.Catalog{},
+		Consumes:  []string{"application/yaml", openapi.MediaTypeJSON},
+		Responses: map[int]interface{}{http.StatusOK: models.ConfigReport{}, http.StatusUnprocessableEntity: models.ConfigReport{}},
+		Errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusRequestEntityTooLarge, http.StatusUnsupportedMediaType, http.StatusInternalServerError},
+	},
+
+	// Exports
+	"ExportServices": {
+		Summary:     "Export services as CSV",
+		Description: "Download every service visible to the caller as CSV, newest first, narrowed like GET /services. Rows are streamed, so exports of any size use little memory; an error midway ends the download early.",
+		Tags:        []string{"services"},
+		Security:    "BearerAuth",
+		Parameters: []openapi.Parameter{
+			openapi.Query("format", openapi.String().OneOf("csv"), "Export format (default: csv)"),
+			openapi.Query("columns", openapi.String(), "Comma-separated columns, in order (default: id, name, slug, description, visibility, tags, versions_count, created_at, updated_at)"),
+			openapi.Query("q", openapi.String(), "Only services matching this search"),
+			openapi.Query("filter", openapi.String(), "Conditions separated by ';', as for GET /services"),
+			openapi.Query("tag", openapi.Array(openapi.String()), "Only services with these tags; repeat for several"),
+			openapi.Query("tag_mode", openapi.String().OneOf("all", "any"), "all (default) to require every tag, or any to require at least one"),
+			openapi.Query("metadata.{key}", openapi.String(), "Only services whose metadata has this value at key, e.g. metadata.team=payments; repeat with other keys to require several"),
+			openapi.Query("category", openapi.String(), "Only services in this category, by ID or slug, or in any of its subcategories"),
+			openapi.Query("owner", openapi.String(), "Only services owned by this owner_team or, given an email address, this owner_email"),
+			openapi.Query("deployed_in", openapi.String(), "Only services currently deployed to this environment, e.g. prod"),
+		},
+		Produces:  []string{"text/csv"},
+		Responses: map[int]interface{}{http.StatusOK: ""},
+		Errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusInternalServerError},
+	},
+	"ExportVersions": {
+		Summary:     "Export a service's versions as CSV",
+		Description: "Download every version of a service as CSV, newest first, narrowed by filter like GET /services/{id}/versions",
+		Tags:        []string{"versions"},
+		Security:    "BearerAuth",
+		Parameters: []openapi.Parameter{
+			openapi.Path("id", "Service ID"),
+			openapi.Query("format", openapi.String().OneOf("csv"), "Export format (default: csv)"),
+			openapi.Query("columns", openapi.String(), "Comma-separated columns, in order (default: id, service_id, semver, status, changelog, created_at)"),
+			openapi.Query("filter", openapi.String(), "Conditions separated by ';', as for GET /services/{id}/versions"),
+			openapi.Query("metadata.{key}", openapi.String(), "Only versions whose metadata has this value at key; repeat with other keys to require several"),
+			openapi.Query("deployed_in", openapi.String(), "Only versions currently deployed to this environment, e.g. prod"),
+		},
+		Produces:  []string{"text/csv"},
+		Responses: map[int]interface{}{http.StatusOK: ""},
+		Errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusInternalServerError},
+	},
+
+	// Categories
+	"GetCategories": {
+		Summary:     "Get the category tree",
+		Description: "Get the organization's categories as a tree: the root categories by name, each with its subcategories in children",
+		Tags:        []string{"categories"},
+		Security:    "BearerAuth",
+		Responses:   map[int]interface{}{http.StatusOK: map[string]interface{}{}},
+		Errors:      []int{http.StatusUnauthorized, http.StatusInternalServerError},
+	},
+	"CreateCategory": {
+		Summary:     "Create a category",
+		Description: "Create a category, under parent_id or as a root when it is left out. The slug is derived from the name when left out (organization-wide tokens only)",
+		Tags:        []string{"categories"},
+		Security:    "BearerAuth",
+		Body:        models.Category{},
+		Responses:   map[int]interface{}{http.StatusCreated: models.Category{}},
+		Errors:      []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusConflict, http.StatusInternalServerError},
+	},
+	"GetCategory": {
+		Summary:     "Get a category",
+		Description: "Get a category with its subcategories",
+		Tags:        []string{"categories"},
+		Security:    "BearerAuth",
+		Parameters: []openapi.Parameter{
+			openapi.Path("id", "Category ID"),
+		},
+		Responses: map[int]interface{}{http.StatusOK: models.Category{}},
+		Errors:    []int{http.StatusUnauthorized, http.StatusNotFound, http.StatusInternalServerError},
+	},
+	"UpdateCategory": {
+		Summary:     "Update a category",
+		Description: "Replace a category's name, slug and parent, moving it with its subcategories; a category cannot be moved under itself or one of its subcategories (organization-wide tokens only)",
+		Tags:        []string{"categories"},
+		Security:    "BearerAuth",
+		Parameters: []openapi.Parameter{
+			openapi.Path("id", "Category ID"),
+		},
+		Body:      models.Category{},
+		Responses: map[int]interface{}{http.StatusOK: models.Category{}},
+		Errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusConflict, http.StatusInternalServerError},
+	},
+	"DeleteCategory": {
+		Summary:     "Delete a category",
+		Description: "Delete a category, removing its services from it. Categories with subcategories cannot be deleted (organization-wide tokens only)",
+		Tags:        []string{"categories"},
+		Security:    "BearerAuth",
+		Parameters: []openapi.Parameter{
+			openapi.Path("id", "Category ID"),
+		},
+		Responses: map[int]interface{}{http.StatusOK: map[string]interface{}{}},
+		Errors:    []int{http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusConflict, http.StatusInternalServerError},
+	},
+	"GetServiceCategories": {
+		Summary:     "Get a service's categories",
+		Description: "List the categories a service is assigned to, by name",
+		Tags:        []string{"categories"},
+		Security:    "BearerAuth",
+		Parameters: []openapi.Parameter{
+			openapi.Path("id", "Service ID"),
+		},
+		Responses: map[int]interface{}{http.StatusOK: map[string]interface{}{}},
+		Errors:    []int{http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusInternalServerError},
+	},
+	"AddServiceCategory": {
+		Summary:     "Add a service to a category",
+		Description: "Assign a service to a category; assigning it again does nothing",
+		Tags:        []string{"categories"},
+		Security:    "BearerAuth",
+		Parameters: []openapi.Parameter{
+			openapi.Path("id", "Service ID"),
+			openapi.Path("category_id", "Category ID"),
+		},
+		Responses: map[int]interface{}{http.StatusOK: map[string]interface{}{}},
+		Errors:    []int{http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusInternalServerError},
+	},
+	"RemoveServiceCategory": {
+		Summary:     "Remove a service from a category",
+		Description: "Unassign a service from a category",
+		Tags:        []string{"categories"},
+		Security:    "BearerAuth",
+		Parameters: []openapi.Parameter{
+			openapi.Path("id", "Service ID"),
+			openapi.Path("category_id", "Category ID"),
+		},
+		Responses: map[int]interface{}{http.StatusOK: map[string]interface{}{}},
+		Errors:    []int{http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusInternalServerError},
+	},
+
+	// GitHub integration
+	"GetGitHubRepository": {
+		Summary:     "Get a service's GitHub repository",
+		Description: "Get the GitHub repository whose published releases become versions of a service",
+		Tags:        []string{"github"},
+		Security:    "BearerAuth",
+		Parameters: []openapi.Parameter{
+			openapi.Path("id", "Service ID"),
+		},
+		Responses: map[int]interface{}{http.StatusOK: models.GitHubRepository{}},
+		Errors:    []int{http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusInternalServerError},
+	},
+	"SetGitHubRepository": {
+		Summary:     "Link a GitHub repository to a service",
+		Description: "Link a GitHub repository, as owner/name, to a service, replacing its previous one, so that the repository's published releases become released versions of the service. A repository links to one service of an organization. The response carries the secret the repository's webhook must sign events with, generated when left out; it is not returned again.",
+		Tags:        []string{"github"},
+		Security:    "BearerAuth",
+		Parameters: []openapi.Parameter{
+			openapi.Path("id", "Service ID"),
+		},
+		Body:      models.GitHubRepository{},
+		Responses: map[int]interface{}{http.StatusOK: models.GitHubRepository{}},
+		Errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusConflict, http.StatusInternalServerError},
+	},
+	"DeleteGitHubRepository": {
+		Summary:     "Unlink a service's GitHub repository",
+		Description: "Stop turning the releases of a service's GitHub repository into versions",
+		Tags:        []string{"github"},
+		Security:    "BearerAuth",
+		Parameters: []openapi.Parameter{
+			openapi.Path("id", "Service ID"),
+		},
+		Responses: map[int]interface{}{http.StatusOK: map[string]interface{}{}},
+		Errors:    []int{http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusInternalServerError},
+	},
+	"GitHubEvents": {
+		Summary:     "Receive GitHub events",
+		Description: "Webhook receiver for repository webhooks. Events must be signed with the secret of a link of their repository, and only act on that link; events of unlinked repositories are rejected like forged ones. A published release becomes a released version of the linked service, with the semver of the release's tag without its v prefix and the release notes as changelog; a release whose version exists is answered with that version. Drafts, prereleases and other events are acknowledged and ignored.",
+		Tags:        []string{"github"},
+		Parameters: []openapi.Parameter{
+			openapi.Header(webhook.HeaderGitHubEvent, "GitHub event type, such as release or ping"),
+			openapi.Header(webhook.HeaderGitHubSignature, "sha256= and the hex HMAC-SHA256 of the body with the secret of the repository's link"),
+		},
+		Body:      githubReleaseEvent{},
+		Responses: map[int]interface{}{http.StatusOK: models.Version{}, http.StatusCreated: models.Version{}},
+		Errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound, http.StatusRequestEntityTooLarge, http.StatusInternalServerError},
+	},
+
+	// Health checks
+	"HealthCheck": {
+		Summary:     "Health check endpoint",
+		Description: "Check if the API is running. It stays 200 while the database is down and reports \"degraded\" instead, since restarting the process would not help.",
+		Tags:        []string{"health"},
+		Responses:   map[int]interface{}{http.StatusOK: map[string]interface{}{}},
+	},
+	"Readiness": {
+		Summary:     "Readiness check endpoint",
+		Description: "Check if the API can serve requests, i.e. its database is reachable",
+		Tags:        []string{"health"},
+		Responses:   map[int]interface{}{http.StatusOK: map[string]interface{}{}},
+		Errors:      []int{http.StatusServiceUnavailable},
+	},
+	"DeepReadiness": {
+		Summary:     "Deep readiness check endpoint",
+		Description: "Check each dependency of the API, such as the database, right now rather than relying on the last periodic check, and report the status, check latency and last error of each. A critical dependency being down fails the check with 503; other dependencies being down only degrade it.",
+		Tags:        []string{"health"},
+		Responses:   map[int]interface{}{http.StatusOK: map[string]interface{}{}},
+		Errors:      []int{http.StatusServiceUnavailable},
+	},
+
+	// Kong sync
+	"GetServiceSync": {
+		Summary:     "Get a service's Kong sync",
+		Description: "Get the status of the sync of a service to the Kong Gateway: pending while queued or awaiting a retry, synced, or failed once it ran out of attempts, with the error of the latest attempt",
+		Tags:        []string{"kong"},
+		Security:    "BearerAuth",
+		Parameters: []openapi.Parameter{
+			openapi.Path("id", "Service ID"),
+		},
+		Responses: map[int]interface{}{http.StatusOK: models.KongSync{}},
+		Errors:    []int{http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusInternalServerError},
+	},
+	"SyncService": {
+		Summary:     "Sync a service to Kong",
+		Description: "Push a service to the Kong Gateway straight away, as a Kong service and route, or remove it from Kong once deleted, and return the outcome. A failed attempt is retried in the background like any other sync.",
+		Tags:        []string{"kong"},
+		Security:    "BearerAuth",
+		Parameters: []openapi.Parameter{
+			openapi.Path("id", "Service ID"),
+		},
+		Responses: map[int]interface{}{http.StatusOK: models.KongSync{}},
+		Errors:    []int{http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusInternalServerError},
+	},
+	"ImportKong": {
+		Summary:     "Import from Kong",
+		Description: "Import the services of the Kong Gateway as private catalog services, described by their upstream and the paths of their routes and tagged with their Kong tags. Kong services synced from a catalog are left out. Services are matched by slug like in POST /import, and the report lists the outcome of each.",
+		Tags:        []string{"kong"},
+		Security:    "BearerAuth",
+		Parameters: []openapi.Parameter{
+			openapi.Query("dry_run", openapi.Boolean(), "Set to true to report what would change without changing anything"),
+			openapi.Query("on_conflict", openapi.String().OneOf("skip", "overwrite"), "skip (default) to keep existing services, or overwrite to update them"),
+		},
+		Responses: map[int]interface{}{http.StatusOK: models.ImportReport{}},
+		Errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusInternalServerError, http.StatusBadGateway},
+	},
+
+	// Log level and body capture
+	"GetLogLevel": {
+		Summary:     "Get the log level",
+		Description: "Get the lowest level of the records logged (admin only)",
+		Tags:        []string{"admin"},
+		Security:    "AdminAuth",
+		Responses:   map[int]interface{}{http.StatusOK: models.LogLevel{}},
+		Errors:      []int{http.StatusUnauthorized},
+	},
+	"SetLogLevel": {
+		Summary:     "Set the log level",
+		Description: "Switch the lowest level of the records logged to debug, info, warn or error, taking effect at once and lasting until the next restart or configuration reload changing LOG_LEVEL (admin only)",
+		Tags:        []string{"admin"},
+		Security:    "AdminAuth",
+		Body:        models.LogLevel{},
+		Responses:   map[int]interface{}{http.StatusOK: models.LogLevel{}},
+		Errors:      []int{http.StatusBadRequest, http.StatusUnauthorized},
+	},
+	"GetDebugCapture": {
+		Summary:     "List body capture rules",
+		Description: "List the rules capturing request and response bodies that have not expired (admin only)",
+		Tags:        []string{"admin"},
+		Security:    "AdminAuth",
+		Responses:   map[int]interface{}{http.StatusOK: map[string]interface{}{}},
+		Errors:      []int{http.StatusUnauthorized},
+	},
+	"EnableDebugCapture": {
+		Summary:     "Capture request and response bodies",
+		Description: "Log the redacted bodies of the requests to a route, or with a request ID, and of their responses, until the rule expires after ttl (default 15m, at most DEBUG_CAPTURE_MAX_TTL) (admin only)",
+		Tags:        []string{"admin"},
+		Security:    "AdminAuth",
+		Body:        models.DebugCapture{},
+		Responses:   map[int]interface{}{http.StatusCreated: map[string]interface{}{}},
+		Errors:      []int{http.StatusBadRequest, http.StatusUnauthorized},
+	},
+	"DisableDebugCapture": {
+		Summary:     "Stop capturing request and response bodies",
+		Description: "Remove every body capture rule (admin only)",
+		Tags:        []string{"admin"},
+		Security:    "AdminAuth",
+		Responses:   map[int]interface{}{http.StatusNoContent: nil},
+		Errors:      []int{http.StatusUnauthorized},
+	},
+
+	// Maintenance
+	"ReindexSearch": {
+		Summary:     "Rebuild the search index",
+		Description: "Rebuild the services table and its full-text index (admin only)",
+		Tags:        []string{"admin"},
+		Security:    "AdminAuth",
+		Responses:   map[int]interface{}{http.StatusOK: map[string]interface{}{}},
+		Errors:      []int{http.StatusUnauthorized, http.StatusInternalServerError},
+	},
+	"ArchiveVersions": {
+		Summary:     "Archive deleted versions",
+		Description: "Move versions deleted longer ago than older_than, directly or with their service, into the versions archive (admin only)",
+		Tags:        []string{"admin"},
+		Security:    "AdminAuth",
+		Parameters: []openapi.Parameter{
+			openapi.RequiredQuery("older_than", openapi.String(), "Minimum time since deletion, as a Go duration such as 720h"),
+		},
+		Responses: map[int]interface{}{http.StatusOK: map[string]interface{}{}},
+		Errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusInternalServerError},
+	},
+
+	// Notifications
+	"GetNotificationPreferences": {
+		Summary:     "Get your notification preferences",
+		Description: "Get the notifications emailed to the calling user; every notification is on until preferences are set (user tokens only)",
+		Tags:        []string{"notifications"},
+		Security:    "BearerAuth",
+		Responses:   map[int]interface{}{http.StatusOK: models.NotificationPreferences{}},
+		Errors:      []int{http.StatusUnauthorized, http.StatusForbidden, http.StatusInternalServerError},
+	},
+	"SetNotificationPreferences": {
+		Summary:     "Set your notification preferences",
+		Description: "Choose the notifications emailed to the calling user: deprecations emails them when a version of a service they own, consume or are subscribed to is deprecated, and as its sunset date nears (user tokens only)",
+		Tags:        []string{"notifications"},
+		Security:    "BearerAuth",
+		Body:        models.NotificationPreferences{},
+		Responses:   map[int]interface{}{http.StatusOK: models.NotificationPreferences{}},
+		Errors:      []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusInternalServerError},
+	},
+	"SubscribeToService": {
+		Summary:     "Subscribe to a service",
+		Description: "Email the calling user about the service's deprecated versions, as its owners are (user tokens only)",
+		Tags:        []string{"notifications"},
+		Security:    "BearerAuth",
+		Parameters: []openapi.Parameter{
+			openapi.Path("id", "Service ID"),
+		},
+		Responses: map[int]interface{}{http.StatusOK: map[string]interface{}{}},
+		Errors:    []int{http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusInternalServerError},
+	},
+	"UnsubscribeFromService": {
+		Summary:     "Unsubscribe from a service",
+		Description: "Stop emailing the calling user about the service, unless they own it (user tokens only)",
+		Tags:        []string{"notifications"},
+		Security:    "BearerAuth",
+		Parameters: []openapi.Parameter{
+			openapi.Path("id", "Service ID"),
+		},
+		Responses: map[int]interface{}{http.StatusOK: map[string]interface{}{}},
+		Errors:    []int{http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusInternalServerError},
+	},
+
+	// Release feeds
+	"GetServiceAtomFeed": {
+		Summary:     "Atom feed of a service's releases",
+		Description: "The 50 newest released versions of a service as an Atom feed, for feed readers and automation. Entries link to the versions and carry their rendered changelogs.",
+		Tags:        []string{"versions"},
+		Security:    "BearerAuth",
+		Parameters: []openapi.Parameter{
+			openapi.Path("id", "Service ID"),
+		},
+		Produces:  []string{"application/atom+xml"},
+		Responses: map[int]interface{}{http.StatusOK: ""},
+		Errors:    []int{http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusInternalServerError},
+	},
+	"GetServiceRSSFeed": {
+		Summary:     "RSS feed of a service's releases",
+		Description: "The 50 newest released versions of a service as an RSS 2.0 feed, like its Atom feed",
+		Tags:        []string{"versions"},
+		Security:    "BearerAuth",
+		Parameters: []openapi.Parameter{
+			openapi.Path("id", "Service ID"),
+		},
+		Produces:  []string{"application/rss+xml"},
+		Responses: map[int]interface{}{http.StatusOK: ""},
+		Errors:    []int{http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusInternalServerError},
+	},
+	"GetCatalogAtomFeed": {
+		Summary:     "Atom feed of catalog releases",
+		Description: "The 50 newest released versions of every service visible to the caller as an Atom feed, categorized by service",
+		Tags:        []string{"versions"},
+		Security:    "BearerAuth",
+		Produces:    []string{"application/atom+xml"},
+		Responses:   map[int]interface{}{http.StatusOK: ""},
+		Errors:      []int{http.StatusUnauthorized, http.StatusInternalServerError},
+	},
+	"GetCatalogRSSFeed": {
+		Summary:     "RSS feed of catalog releases",
+		Description: "The 50 newest released versions of every service visible to the caller as an RSS 2.0 feed, like the catalog's Atom feed",
+		Tags:        []string{"versions"},
+		Security:    "BearerAuth",
+		Produces:    []string{"application/rss+xml"},
+		Responses:   map[int]interface{}{http.StatusOK: ""},
+		Errors:      []int{http.StatusUnauthorized, http.StatusInternalServerError},
+	},
+	"GetDeprecationCalendar": {
+		Summary:     "Calendar of deprecation and sunset dates",
+		Description: "The deprecation and sunset dates of versions as an iCalendar document of all-day events, for calendar apps to subscribe to: the versions a team consumes with team, those of a service with service, and otherwise those of the services the calling user is subscribed to. Dates stay listed for 30 days after they pass.",
+		Tags:        []string{"versions"},
+		Security:    "BearerAuth",
+		Parameters: []openapi.Parameter{
+			openapi.Query("team", openapi.String(), "ID of a team whose consumed versions are listed"),
+			openapi.Query("service", openapi.String(), "ID of a service whose versions are listed"),
+		},
+		Produces:  []string{"text/calendar"},
+		Responses: map[int]interface{}{http.StatusOK: ""},
+		Errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusInternalServerError},
+	},
+
+	// Tenant administration
+	"CreateOrganization": {
+		Summary:     "Create an organization",
+		Description: "Create a new tenant organization (admin only)",
+		Tags:        []string{"admin"},
+		Security:    "AdminAuth",
+		Body:        models.Organization{},
+		Responses:   map[int]interface{}{http.StatusCreated: models.Organization{}},
+		Errors:      []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusInternalServerError},
+	},
+	"GetOrganizations": {
+		Summary:     "List organizations",
+		Description: "List all tenant organizations (admin only)",
+		Tags:        []string{"admin"},
+		Security:    "AdminAuth",
+		Responses:   map[int]interface{}{http.StatusOK: map[string]interface{}{}},
+		Errors:      []int{http.StatusUnauthorized, http.StatusInternalServerError},
+	},
+	"CreateAPIToken": {
+		Summary:     "Issue an API token",
+		Description: "Issue a bearer token scoped to an organization (admin only). Set user_id to bind the token to a user, otherwise it acts for the whole organization. The token is only returned once.",
+		Tags:        []string{"admin"},
+		Security:    "AdminAuth",
+		Parameters: []openapi.Parameter{
+			openapi.Path("id", "Organization ID"),
+		},
+		Body:      models.APIToken{},
+		Responses: map[int]interface{}{http.StatusCreated: models.APIToken{}},
+		Errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusInternalServerError},
+	},
+	"CreateUser": {
+		Summary:     "Create a user",
+		Description: "Create a user within an organization (admin only). Users created with a password can log in via /auth/login.",
+		Tags:        []string{"admin"},
+		Security:    "AdminAuth",
+		Parameters: []openapi.Parameter{
+			openapi.Path("id", "Organization ID"),
+		},
+		Body:      models.User{},
+		Responses: map[int]interface{}{http.StatusCreated: models.User{}},
+		Errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusInternalServerError},
+	},
+	"CreateTeam": {
+		Summary:     "Create a team",
+		Description: "Create a team within an organization (admin only)",
+		Tags:        []string{"admin"},
+		Security:    "AdminAuth",
+		Parameters: []openapi.Parameter{
+			openapi.Path("id", "Organization ID"),
+		},
+		Body:      models.Team{},
+		Responses: map[int]interface{}{http.StatusCreated: models.Team{}},
+		Errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusInternalServerError},
+	},
+	"AddTeamMember": {
+		Summary:     "Add a user to a team",
+		Description: "Add a user to a team; both must belong to the organization (admin only)",
+		Tags:        []string{"admin"},
+		Security:    "AdminAuth",
+		Parameters: []openapi.Parameter{
+			openapi.Path("id", "Organization ID"),
+			openapi.Path("team_id", "Team ID"),
+			openapi.Path("user_id", "User ID"),
+		},
+		Responses: map[int]interface{}{http.StatusOK: map[string]interface{}{}},
+		Errors:    []int{http.StatusUnauthorized, http.StatusNotFound, http.StatusInternalServerError},
+	},
+
+	// Global search
+	"GlobalSearch": {
+		Summary:     "Search everything",
+		Description: "Search services and versions in one call, returning the best hits of each type with a link to each, for omnibox-style UIs",
+		Tags:        []string{"search"},
+		Security:    "BearerAuth",
+		Parameters: []openapi.Parameter{
+			openapi.RequiredQuery("q", openapi.String(), "Search query, 2 to 200 characters"),
+			openapi.Query("limit", openapi.Integer().Min(1).Max(20), "Hits per type (default: 5, max: 20)"),
+		},
+		Responses: map[int]interface{}{http.StatusOK: models.GlobalSearchResponse{}},
+		Errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusInternalServerError},
+	},
+
+	// Search analytics
+	"SearchAnalytics": {
+		Summary:     "Report on searches",
+		Description: "Count an organization's sampled searches, with its most frequent queries and the most frequent ones that found nothing (admin only)",
+		Tags:        []string{"admin"},
+		Security:    "AdminAuth",
+		Parameters: []openapi.Parameter{
+			openapi.RequiredQuery("org_id", openapi.String(), "Organization ID"),
+			openapi.Query("since", openapi.String(), "How far back to look, as a Go duration (default: 168h)"),
+			openapi.Query("limit", openapi.Integer().Min(1).Max(100), "Queries listed per kind (default: 20, max: 100)"),
+		},
+		Responses: map[int]interface{}{http.StatusOK: models.SearchAnalytics{}},
+		Errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusInternalServerError},
+	},
+
+	// Search synonyms
+	"GetSynonyms": {
+		Summary:     "List search synonyms",
+		Description: "List every search term with the synonyms searches expand it with (admin only)",
+		Tags:        []string{"admin"},
+		Security:    "AdminAuth",
+		Responses:   map[int]interface{}{http.StatusOK: map[string]interface{}{}},
+		Errors:      []int{http.StatusUnauthorized, http.StatusInternalServerError},
+	},
+	"SetSynonyms": {
+		Summary:     "Set the synonyms of a search term",
+		Description: "Replace the synonyms natural searches containing term are expanded with, e.g. auth with authentication and oauth (admin only). Terms and synonyms are matched ignoring case and accents.",
+		Tags:        []string{"admin"},
+		Security:    "AdminAuth",
+		Parameters: []openapi.Parameter{
+			openapi.Path("term", "Search term, one word"),
+		},
+		Body:      models.SearchSynonyms{},
+		Responses: map[int]interface{}{http.StatusOK: models.SearchSynonyms{}},
+		Errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusInternalServerError},
+	},
+	"DeleteSynonyms": {
+		Summary:     "Delete the synonyms of a search term",
+		Description: "Stop expanding searches containing term (admin only)",
+		Tags:        []string{"admin"},
+		Security:    "AdminAuth",
+		Parameters: []openapi.Parameter{
+			openapi.Path("term", "Search term"),
+		},
+		Responses: map[int]interface{}{http.StatusOK: map[string]interface{}{}},
+		Errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound, http.StatusInternalServerError},
+	},
+
+	// Services
+	"GetServices": {
+		Summary:     "Get all services",
+		Description: "Get a paginated list of services, newest first, optionally narrowed by a search, filter, tags, metadata, category, owner and environment that all combine",
+		Tags:        []string{"services"},
+		Security:    "BearerAuth",
+		Parameters: []openapi.Parameter{
+			openapi.Query("page", openapi.Integer().Min(1), "Page number (default: 1); v1 only, as v2 pages by cursor alone"),
+			openapi.Query("page_size", openapi.Integer().Min(1).Max(100), "Number of items per page (default: 10, max: 100)"),
+			openapi.Query("count", openapi.Boolean(), "Set to false to skip the total count; total and total_pages are then omitted"),
+			openapi.Query("cursor", openapi.String(), "Continue after the page that returned this next_cursor; replaces page"),
+			openapi.Query("q", openapi.String(), "Only services matching this search, which keeps the newest-first order; use /services/search to rank by relevance"),
+			openapi.Query("filter", openapi.String(), "Conditions separated by ';' on name, slug, description, visibility, versions_count, created_at, updated_at, latest_status or spec_score, e.g. visibility==public;latest_status==released;created_at>=2024-01-01"),
+			openapi.Query("tag", openapi.Array(openapi.String()), "Only services with these tags; repeat for several"),
+			openapi.Query("tag_mode", openapi.String().OneOf("all", "any"), "all (default) to require every tag, or any to require at least one"),
+			openapi.Query("metadata.{key}", openapi.String(), "Only services whose metadata has this value at key, e.g. metadata.team=payments; repeat with other keys to require several"),
+			openapi.Query("category", openapi.String(), "Only services in this category, by ID or slug, or in any of its subcategories"),
+			openapi.Query("owner", openapi.String(), "Only services owned by this owner_team or, given an email address, this owner_email"),
+			openapi.Query("deployed_in", openapi.String(), "Only services currently deployed to this environment, e.g. prod"),
+			openapi.Query("render", openapi.String().OneOf("html"), "Set to 'html' to include rendered descriptions"),
+		},
+		Produces:  []string{openapi.MediaTypeJSON, middleware.MediaTypeJSONAPI},
+		Responses: map[int]interface{}{http.StatusOK: openapi.Refine(types.PaginatedResponse{}, "data", []models.Service{})},
+		Errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusInternalServerError},
+	},
+	"GetTeamServices": {
+		Summary:     "Get a team's services",
+		Description: "Get a paginated list of the services whose owner_team is team, newest first, narrowed like GET /services",
+		Tags:        []string{"services"},
+		Security:    "BearerAuth",
+		Parameters: []openapi.Parameter{
+			openapi.Path("team", "Owner team, normalized like owner_team"),
+			openapi.Query("page", openapi.Integer().Min(1), "Page number (default: 1); v1 only, as v2 pages by cursor alone"),
+			openapi.Query("page_size", openapi.Integer().Min(1).Max(100), "Number of items per page (default: 10, max: 100)"),
+			openapi.Query("count", openapi.Boolean(), "Set to false to skip the total count; total and total_pages are then omitted"),
+			openapi.Query("cursor", openapi.String(), "Continue after the page that returned this next_cursor; replaces page"),
+			openapi.Query("q", openapi.String(), "Only services matching this search, which keeps the newest-first order; use /services/search to rank by relevance"),
+			openapi.Query("filter", openapi.String(), "Conditions separated by ';' on name, slug, description, visibility, versions_count, created_at, updated_at, latest_status or spec_score, e.g. visibility==public;latest_status==released;created_at>=2024-01-01"),
+			openapi.Query("tag", openapi.Array(openapi.String()), "Only services with these tags; repeat for several"),
+			openapi.Query("tag_mode", openapi.String().OneOf("all", "any"), "all (default) to require every tag, or any to require at least one"),
+			openapi.Query("metadata.{key}", openapi.String(), "Only services whose metadata has this value at key, e.g. metadata.team=payments; repeat with other keys to require several"),
+			openapi.Query("category", openapi.String(), "Only services in this category, by ID or slug, or in any of its subcategories"),
+			openapi.Query("deployed_in", openapi.String(), "Only services currently deployed to this environment, e.g. prod"),
+			openapi.Query("render", openapi.String().OneOf("html"), "Set to 'html' to include rendered descriptions"),
+		},
+		Produces:  []string{openapi.MediaTypeJSON, middleware.MediaTypeJSONAPI},
+		Responses: map[int]interface{}{http.StatusOK: openapi.Refine(types.PaginatedResponse{}, "data", []models.Service{})},
+		Errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusInternalServerError},
+	},
+	"SearchServices": {
+		Summary:     "Search services",
+		Description: "Search services by name, slug, or description using full-text search, best matches first with their relevance score. Without q, browse every service in the chosen sort order instead, newest first by default.",
+		Tags:        []string{"services"},
+		Security:    "BearerAuth",
+		Parameters: []openapi.Parameter{
+			openapi.Query("q", openapi.String(), "Search query, 2 to 200 characters; leave out to browse"),
+			openapi.Query("mode", openapi.String().OneOf("natural", "boolean", "browse"), "natural (default), boolean for +required -excluded \"exact phrase\" and prefix* terms, or browse to list every service without q"),
+			openapi.Query("fuzzy", openapi.Boolean(), "Set to true to also find services whose names resemble q despite typos; natural mode only"),
+			openapi.Query("min_score", openapi.Number().Min(0), "Leave out full-text matches scoring below this"),
+			openapi.Query("sort", openapi.String().OneOf("relevance", "name", "created_at", "versions_count"), "relevance (default, best matches first), name (A to Z), created_at (newest first) or versions_count (most versions first)"),
+			openapi.Query("facets", openapi.String(), "Comma-separated facets to count the results by: visibility, version_status"),
+			openapi.Query("page", openapi.Integer().Min(1), "Page number (default: 1)"),
+			openapi.Query("page_size", openapi.Integer().Min(1).Max(100), "Number of items per page (default: 10, max: 100)"),
+			openapi.Query("count", openapi.Boolean(), "Set to false to skip the total count; total and total_pages are then omitted"),
+			openapi.Query("tag", openapi.Array(openapi.String()), "Only services with these tags; repeat for several"),
+			openapi.Query("tag_mode", openapi.String().OneOf("all", "any"), "all (default) to require every tag, or any to require at least one"),
+			openapi.Query("include_archived", openapi.Boolean(), "Set to true to also find deleted services, which carry deleted_at"),
+			openapi.Query("render", openapi.String().OneOf("html"), "Set to 'html' to include rendered descriptions"),
+		},
+		Produces:  []string{openapi.MediaTypeJSON, middleware.MediaTypeJSONAPI},
+		Responses: map[int]interface{}{http.StatusOK: openapi.Refine(types.PaginatedResponse{}, "data", []models.Service{})},
+		Errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusInternalServerError},
+	},
+	"SuggestServices": {
+		Summary:     "Suggest services",
+		Description: "Suggest services whose name or slug starts with q, ignoring case, for search-as-you-type. Results are ordered by name and not counted.",
+		Tags:        []string{"services"},
+		Security:    "BearerAuth",
+		Parameters: []openapi.Parameter{
+			openapi.RequiredQuery("q", openapi.String(), "Name or slug prefix, up to 200 characters"),
+			openapi.Query("limit", openapi.Integer().Min(1).Max(25), "Number of suggestions (default: 10, max: 25)"),
+		},
+		Responses: map[int]interface{}{http.StatusOK: map[string][]models.ServiceSuggestion{}},
+		Errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusInternalServerError},
+	},
+	"CreateService": {
+		Summary:     "Create a new service",
+		Description: "Create a new service with the provided information",
+		Tags:        []string{"services"},
+		Security:    "BearerAuth",
+		Body:        models.Service{},
+		Consumes:    []string{openapi.MediaTypeJSON, middleware.MediaTypeJSONAPI},
+		Produces:    []string{openapi.MediaTypeJSON, middleware.MediaTypeJSONAPI},
+		Responses:   map[int]interface{}{http.StatusCreated: models.Service{}},
+		Errors:      []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusConflict, http.StatusInternalServerError},
+	},
+	"GetService": {
+		Summary:     "Get a service by ID",
+		Description: "Get a specific service by its ID",
+		Tags:        []string{"services"},
+		Security:    "BearerAuth",
+		Parameters: []openapi.Parameter{
+			openapi.Path("id", "Service ID"),
+			openapi.Query("render", openapi.String().OneOf("html"), "Set to 'html' to include the rendered description"),
+		},
+		Produces:  []string{openapi.MediaTypeJSON, middleware.MediaTypeJSONAPI},
+		Responses: map[int]interface{}{http.StatusOK: models.Service{}},
+		Errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound, http.StatusInternalServerError},
+	},
+	"UpdateService": {
+		Summary:     "Update a service",
+		Description: "Update a service with the provided information",
+		Tags:        []string{"services"},
+		Security:    "BearerAuth",
+		Parameters: []openapi.Parameter{
+			openapi.Path("id", "Service ID"),
+		},
+		Body:      models.Service{},
+		Consumes:  []string{openapi.MediaTypeJSON, middleware.MediaTypeJSONAPI},
+		Produces:  []string{openapi.MediaTypeJSON, middleware.MediaTypeJSONAPI},
+		Responses: map[int]interface{}{http.StatusOK: models.Service{}},
+		Errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusConflict, http.StatusInternalServerError},
+	},
+	"DeleteService": {
+		Summary:     "Delete a service",
+		Description: "Soft-delete a service by its ID, hiding it and its versions from every endpoint. Services that teams consume cannot be deleted until their consumers unregister.",
+		Tags:        []string{"services"},
+		Security:    "BearerAuth",
+		Parameters: []openapi.Parameter{
+			openapi.Path("id", "Service ID"),
+		},
+		Responses: map[int]interface{}{http.StatusOK: map[string]interface{}{}},
+		Errors:    []int{http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusConflict, http.StatusInternalServerError},
+	},
+
+	// Consumers
+	"GetConsumers": {
+		Summary:     "List a service's consumers",
+		Description: "List the teams consuming a service, by team name. Consumers without a version_id consume every version.",
+		Tags:        []string{"services"},
+		Security:    "BearerAuth",
+		Parameters: []openapi.Parameter{
+			openapi.Path("id", "Service ID"),
+		},
+		Responses: map[int]interface{}{http.StatusOK: map[string]interface{}{}},
+		Errors:    []int{http.StatusUnauthorized, http.StatusNotFound, http.StatusInternalServerError},
+	},
+	"CreateConsumer": {
+		Summary:     "Register a consumer",
+		Description: "Register a team as a consumer of a service, or of one of its versions when version_id is set. Users can only register teams they belong to. Members of consumer teams are emailed when the versions they consume are deprecated, and the service cannot be deleted while it has consumers.",
+		Tags:        []string{"services"},
+		Security:    "BearerAuth",
+		Parameters: []openapi.Parameter{
+			openapi.Path("id", "Service ID"),
+		},
+		Body:      models.Consumer{},
+		Responses: map[int]interface{}{http.StatusCreated: models.Consumer{}},
+		Errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusConflict, http.StatusInternalServerError},
+	},
+	"DeleteConsumer": {
+		Summary:     "Unregister a consumer",
+		Description: "Remove a consumer from a service. Users can only unregister teams they belong to.",
+		Tags:        []string{"services"},
+		Security:    "BearerAuth",
+		Parameters: []openapi.Parameter{
+			openapi.Path("id", "Service ID"),
+			openapi.Path("consumer_id", "Consumer ID"),
+		},
+		Responses: map[int]interface{}{http.StatusOK: map[string]interface{}{}},
+		Errors:    []int{http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusInternalServerError},
+	},
+
+	// Status
+	"Status": {
+		Summary:     "Service status",
+		Description: "Report the process uptime, the requests handled and the share of them that failed with a server error, in total and over the last 5 minutes and hour, and whether each dependency is up. Meant for a public status page; dependencies are checked at most every 10 seconds and errors are not shown.",
+		Tags:        []string{"health"},
+		Responses:   map[int]interface{}{http.StatusOK: map[string]interface{}{}},
+	},
+
+	// Versions
+	"GetVersions": {
+		Summary:     "Get versions for a service",
+		Description: "Get a paginated list of versions for a specific service",
+		Tags:        []string{"versions"},
+		Security:    "BearerAuth",
+		Parameters: []openapi.Parameter{
+			openapi.Path("id", "Service ID"),
+			openapi.Query("page", openapi.Integer().Min(1), "Page number (default: 1); v1 only, as v2 pages by cursor alone"),
+			openapi.Query("page_size", openapi.Integer().Min(1).Max(100), "Number of items per page (default: 10, max: 100)"),
+			openapi.Query("count", openapi.Boolean(), "Set to false to skip the total count; total and total_pages are then omitted"),
+			openapi.Query("cursor", openapi.String(), "Continue after the page that returned this next_cursor; replaces page"),
+			openapi.Query("filter", openapi.String(), "Conditions separated by ';' on semver, status, changelog, created_at, released_at, deprecated_at, sunset_at or spec_score, e.g. status=in=(released,deprecated);created_at>=2024-01-01"),
+			openapi.Query("metadata.{key}", openapi.String(), "Only versions whose metadata has this value at key; repeat with other keys to require several"),
+			openapi.Query("deployed_in", openapi.String(), "Only versions currently deployed to this environment, e.g. prod"),
+			openapi.Query("render", openapi.String().OneOf("html"), "Set to 'html' to include rendered changelogs"),
+		},
+		Produces:  []string{openapi.MediaTypeJSON, middleware.MediaTypeJSONAPI},
+		Responses: map[int]interface{}{http.StatusOK: openapi.Refine(types.PaginatedResponse{}, "data", []models.Version{})},
+		Errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound, http.StatusInternalServerError},
+	},
+	"GetVersion": {
+		Summary:     "Get a version of a service",
+		Description: "Get a version of a service by its ID, or the service's newest version with the ID \"latest\"",
+		Tags:        []string{"versions"},
+		Security:    "BearerAuth",
+		Parameters: []openapi.Parameter{
+			openapi.Path("id", "Service ID"),
+			openapi.Path("version_id", "Version ID, or latest"),
+			openapi.Query("render", openapi.String().OneOf("html"), "Set to 'html' to include the rendered changelog"),
+		},
+		Produces:  []string{openapi.MediaTypeJSON, middleware.MediaTypeJSONAPI},
+		Responses: map[int]interface{}{http.StatusOK: models.Version{}},
+		Errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound, http.StatusInternalServerError},
+	},
+	"CreateVersion": {
+		Summary:     "Create a new version",
+		Description: "Create a new version for a specific service",
+		Tags:        []string{"versions"},
+		Security:    "BearerAuth",
+		Parameters: []openapi.Parameter{
+			openapi.Path("id", "Service ID"),
+		},
+		Body:      models.Version{},
+		Consumes:  []string{openapi.MediaTypeJSON, middleware.MediaTypeJSONAPI},
+		Produces:  []string{openapi.MediaTypeJSON, middleware.MediaTypeJSONAPI},
+		Responses: map[int]interface{}{http.StatusCreated: models.Version{}},
+		Errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusInternalServerError},
+	},
+
+	// Deployments
+	"GetDeployments": {
+		Summary:     "List a version's deployments",
+		Description: "List the most recent deployments of a version, newest first. The version's environments are those it was the last to be deployed to.",
+		Tags:        []string{"versions"},
+		Security:    "BearerAuth",
+		Parameters: []openapi.Parameter{
+			openapi.Path("id", "Service ID"),
+			openapi.Path("version_id", "Version ID"),
+			openapi.Query("limit", openapi.Integer().Min(1).Max(200), "Deployments listed (default: 50, max: 200)"),
+		},
+		Responses: map[int]interface{}{http.StatusOK: map[string]interface{}{}},
+		Errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound, http.StatusInternalServerError},
+	},
+	"CreateDeployment": {
+		Summary:     "Record a deployment",
+		Description: "Record a version being deployed to an environment, such as prod, which then runs it in place of the version deployed there before. Environments are normalized like slugs, so \"Prod EU\" becomes prod-eu.",
+		Tags:        []string{"versions"},
+		Security:    "BearerAuth",
+		Parameters: []openapi.Parameter{
+			openapi.Path("id", "Service ID"),
+			openapi.Path("version_id", "Version ID"),
+		},
+		Body:      models.Deployment{},
+		Responses: map[int]interface{}{http.StatusCreated: models.Deployment{}},
+		Errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusInternalServerError},
+	},
+
+	// Deprecation schedule
+	"ScheduleDeprecation": {
+		Summary:     "Schedule a version's deprecation",
+		Description: "Deprecate a version at deprecated_at, or now when it is omitted or not in the future, and set the sunset_at date after which it is no longer supported. Versions are deprecated by a background job once their date passes, which emails their owners, consumers and subscribers. Rescheduling replaces the dates; an already deprecated version keeps its deprecated_at.",
+		Tags:        []string{"versions"},
+		Security:    "BearerAuth",
+		Parameters: []openapi.Parameter{
+			openapi.Path("id", "Service ID"),
+			openapi.Path("version_id", "Version ID"),
+		},
+		Body:      models.DeprecationSchedule{},
+		Produces:  []string{openapi.MediaTypeJSON, middleware.MediaTypeJSONAPI},
+		Responses: map[int]interface{}{http.StatusOK: models.Version{}},
+		Errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusInternalServerError},
+	},
+	"CancelDeprecation": {
+		Summary:     "Cancel a version's scheduled deprecation",
+		Description: "Clear the deprecation and sunset dates of a version that is not deprecated yet. Deprecated versions are undeprecated by changing their status instead.",
+		Tags:        []string{"versions"},
+		Security:    "BearerAuth",
+		Parameters: []openapi.Parameter{
+			openapi.Path("id", "Service ID"),
+			openapi.Path("version_id", "Version ID"),
+		},
+		Responses: map[int]interface{}{http.StatusOK: map[string]interface{}{}},
+		Errors:    []int{http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusConflict, http.StatusInternalServerError},
+	},
+
+	// OpenAPI documents
+	"GetVersionSpec": {
+		Summary:     "Get a version's OpenAPI document",
+		Description: "Download the OpenAPI document of a version as JSON or YAML, chosen by format or else by the Accept header, and otherwise in the format it was uploaded in. Converted documents keep the order of their keys.",
+		Tags:        []string{"versions"},
+		Security:    "BearerAuth",
+		Parameters: []openapi.Parameter{
+			openapi.Path("id", "Service ID"),
+			openapi.Path("version_id", "Version ID"),
+			openapi.Query("format", openapi.String().OneOf("json", "yaml"), "Format of the document, overriding Accept"),
+		},
+		Produces:  []string{openapi.MediaTypeJSON, "application/yaml"},
+		Responses: map[int]interface{}{http.StatusOK: map[string]interface{}{}},
+		Errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound, http.StatusInternalServerError},
+	},
+	"PutVersionSpec": {
+		Summary:     "Attach an OpenAPI document to a version",
+		Description: "Upload the OpenAPI 3.0 or 3.1 document of a version, as JSON or YAML, replacing the one it had. Documents are validated first; an invalid one is answered with 400 listing its problems and not stored. Valid documents are linted, and their score stored on the version.",
+		Tags:        []string{"versions"},
+		Security:    "BearerAuth",
+		Parameters: []openapi.Parameter{
+			openapi.Path("id", "Service ID"),
+			openapi.Path("version_id", "Version ID"),
+		},
+		Body:      map[string]interface{}{},
+		Consumes:  []string{openapi.MediaTypeJSON, "application/yaml"},
+		Responses: map[int]interface{}{http.StatusOK: models.VersionSpec{}},
+		Errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusRequestEntityTooLarge, http.StatusUnsupportedMediaType, http.StatusInternalServerError},
+	},
+	"LintVersionSpec": {
+		Summary:     "Lint a version's OpenAPI document",
+		Description: "Check the OpenAPI document of a version against the lint rules in effect, covering naming, missing descriptions and security schemes, and list the problems found with the document's score from 0 to 100",
+		Tags:        []string{"versions"},
+		Security:    "BearerAuth",
+		Parameters: []openapi.Parameter{
+			openapi.Path("id", "Service ID"),
+			openapi.Path("version_id", "Version ID"),
+		},
+		Responses: map[int]interface{}{http.StatusOK: models.SpecLintReport{}},
+		Errors:    []int{http.StatusUnauthorized, http.StatusNotFound, http.StatusInternalServerError},
+	},
+	"DeleteVersionSpec": {
+		Summary:     "Remove a version's OpenAPI document",
+		Description: "Remove the OpenAPI document of a version",
+		Tags:        []string{"versions"},
+		Security:    "BearerAuth",
+		Parameters: []openapi.Parameter{
+			openapi.Path("id", "Service ID"),
+			openapi.Path("version_id", "Version ID"),
+		},
+		Responses: map[int]interface{}{http.StatusOK: map[string]interface{}{}},
+		Errors:    []int{http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusInternalServerError},
+	},
+	"CompareVersionSpecs": {
+		Summary:     "Compare the OpenAPI documents of two versions",
+		Description: "List the changes from the OpenAPI document of one version of a service to that of another, such as removed paths and operations, newly required parameters and properties, and changed schemas, marking those that break clients of the older version. Breaking changes are declared when the semver of to is a major bump from that of from. With BLOCK_BREAKING_RELEASES set, imports and config applies cannot release a version with undeclared breaking changes from the latest released version.",
+		Tags:        []string{"versions"},
+		Security:    "BearerAuth",
+		Parameters: []openapi.Parameter{
+			openapi.Path("id", "Service ID"),
+			openapi.RequiredQuery("from", openapi.String(), "ID or semver of the older version"),
+			openapi.RequiredQuery("to", openapi.String(), "ID or semver of the newer version"),
+		},
+		Responses: map[int]interface{}{http.StatusOK: models.SpecComparison{}},
+		Errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound, http.StatusInternalServerError},
+	},
+
+	// Webhook subscriptions
+	"GetWebhookSubscriptions": {
+		Summary:     "List webhook subscriptions",
+		Description: "List the organization's webhook subscriptions, without their secrets (organization-wide tokens only)",
+		Tags:        []string{"webhooks"},
+		Security:    "BearerAuth",
+		Responses:   map[int]interface{}{http.StatusOK: map[string]interface{}{}},
+		Errors:      []int{http.StatusUnauthorized, http.StatusForbidden, http.StatusInternalServerError},
+	},
+	"CreateWebhookSubscription": {
+		Summary:     "Subscribe a webhook to events",
+		Description: "POST the organization's events of the listed types to a URL. Deliveries are signed with the secret, which is generated when left out and only returned in this response. With format slack or teams, the URL is an incoming webhook sent a message about each event, rendered with the template or a default one (organization-wide tokens only)",
+		Tags:        []string{"webhooks"},
+		Security:    "BearerAuth",
+		Body:        models.WebhookSubscription{},
+		Responses:   map[int]interface{}{http.StatusCreated: models.WebhookSubscription{}},
+		Errors:      []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusInternalServerError},
+	},
+	"GetWebhookSubscription": {
+		Summary:     "Get a webhook subscription",
+		Description: "Get a webhook subscription, without its secret (organization-wide tokens only)",
+		Tags:        []string{"webhooks"},
+		Security:    "BearerAuth",
+		Parameters: []openapi.Parameter{
+			openapi.Path("id", "Subscription ID"),
+		},
+		Responses: map[int]interface{}{http.StatusOK: models.WebhookSubscription{}},
+		Errors:    []int{http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusInternalServerError},
+	},
+	"UpdateWebhookSubscription": {
+		Summary:     "Update a webhook subscription",
+		Description: "Replace a subscription's URL, event types and template. Setting active to false pauses deliveries, which are kept until it is set back to true; setting secret rotates it; format is kept when left out (organization-wide tokens only)",
+		Tags:        []string{"webhooks"},
+		Security:    "BearerAuth",
+		Parameters: []openapi.Parameter{
+			openapi.Path("id", "Subscription ID"),
+		},
+		Body:      models.WebhookSubscription{},
+		Responses: map[int]interface{}{http.StatusOK: models.WebhookSubscription{}},
+		Errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusInternalServerError},
+	},
+	"DeleteWebhookSubscription": {
+		Summary:     "Delete a webhook subscription",
+		Description: "Delete a webhook subscription with its delivery history; pending deliveries are dropped (organization-wide tokens only)",
+		Tags:        []string{"webhooks"},
+		Security:    "BearerAuth",
+		Parameters: []openapi.Parameter{
+			openapi.Path("id", "Subscription ID"),
+		},
+		Responses: map[int]interface{}{http.StatusOK: map[string]interface{}{}},
+		Errors:    []int{http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusInternalServerError},
+	},
+	"GetWebhookDeliveries": {
+		Summary:     "List a webhook subscription's deliveries",
+		Description: "List the most recent deliveries to a subscription, newest first, with their status, attempts and last response or error (organization-wide tokens only)",
+		Tags:        []string{"webhooks"},
+		Security:    "BearerAuth",
+		Parameters: []openapi.Parameter{
+			openapi.Path("id", "Subscription ID"),
+			openapi.Query("limit", openapi.Integer().Min(1).Max(200), "Deliveries listed (default: 50, max: 200)"),
+		},
+		Responses: map[int]interface{}{http.StatusOK: map[string]interface{}{}},
+		Errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusInternalServerError},
+	},
+	"TestWebhookSubscription": {
+		Summary:     "Send a test delivery",
+		Description: "Send a webhook.test event to a subscription straight away, whether or not it is active, and return the outcome; it is recorded in the delivery history but never retried (organization-wide tokens only)",
+		Tags:        []string{"webhooks"},
+		Security:    "BearerAuth",
+		Parameters: []openapi.Parameter{
+			openapi.Path("id", "Subscription ID"),
+		},
+		Responses: map[int]interface{}{http.StatusOK: models.WebhookDelivery{}},
+		Errors:    []int{http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusInternalServerError},
+	},
+}
@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/yashjain/konnect/internal/audit"
+	"github.com/yashjain/konnect/internal/auth"
+	"github.com/yashjain/konnect/internal/models"
+	"github.com/yashjain/konnect/internal/repository"
+)
+
+// CreateOrganization creates an organization
+func CreateOrganization(orgRepo repository.OrganizationRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var org models.Organization
+		if err := c.ShouldBindJSON(&org); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		org.ID = uuid.New().String()
+
+		if err := orgRepo.CreateOrganization(c.Request.Context(), &org); err != nil {
+			respondInternalError(c, err)
+			return
+		}
+		audit.Log(c.Request.Context(), "Organization created", "org_id", org.ID, "name", org.Name)
+
+		c.JSON(http.StatusCreated, org)
+	}
+}
+
+// GetOrganizations lists organizations
+func GetOrganizations(orgRepo repository.OrganizationRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orgs, err := orgRepo.GetOrganizations(c.Request.Context())
+		if err != nil {
+			respondInternalError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": orgs})
+	}
+}
+
+// CreateAPIToken issues an API token
+func CreateAPIToken(orgRepo repository.OrganizationRepository, accessRepo repository.AccessRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var token models.APIToken
+		if err := c.ShouldBindJSON(&token); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		secret, err := auth.GenerateToken()
+		if err != nil {
+			respondInternalError(c, err)
+			return
+		}
+
+		token.ID = uuid.New().String()
+		token.OrgID = c.Param("id")
+
+		if token.UserID != "" {
+			ok, err := accessRepo.SubjectInOrg(c.Request.Context(), token.OrgID, models.SubjectUser, token.UserID)
+			if err != nil {
+				respondInternalError(c, err)
+				return
+			}
+			if !ok {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "user not found in organization"})
+				return
+			}
+		}
+
+		if err := orgRepo.CreateAPIToken(c.Request.Context(), &token, auth.HashToken(secret)); err != nil {
+			respondInternalError(c, err)
+			return
+		}
+
+		audit.Log(c.Request.Context(), "API token issued", "org_id", token.OrgID, "token_id", token.ID, "token_user_id", token.UserID)
+
+		token.Token = secret
+		c.JSON(http.StatusCreated, token)
+	}
+}
+
+// CreateUser creates a user
+func CreateUser(orgRepo repository.OrganizationRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var user models.User
+		if err := c.ShouldBindJSON(&user); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		user.ID = uuid.New().String()
+		user.OrgID = c.Param("id")
+
+		var passwordHash string
+		if user.Password != "" {
+			hash, err := auth.HashPassword(user.Password)
+			if err != nil {
+				respondInternalError(c, err)
+				return
+			}
+			passwordHash = hash
+		}
+
+		if err := orgRepo.CreateUser(c.Request.Context(), &user, passwordHash); err != nil {
+			respondInternalError(c, err)
+			return
+		}
+
+		audit.Log(c.Request.Context(), "User created", "org_id", user.OrgID, "created_user_id", user.ID, "password", passwordHash != "")
+
+		user.Password = ""
+		c.JSON(http.StatusCreated, user)
+	}
+}
+
+// CreateTeam creates a team
+func CreateTeam(orgRepo repository.OrganizationRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var team models.Team
+		if err := c.ShouldBindJSON(&team); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		team.ID = uuid.New().String()
+		team.OrgID = c.Param("id")
+
+		if err := orgRepo.CreateTeam(c.Request.Context(), &team); err != nil {
+			respondInternalError(c, err)
+			return
+		}
+		audit.Log(c.Request.Context(), "Team created", "org_id", team.OrgID, "team_id", team.ID)
+
+		c.JSON(http.StatusCreated, team)
+	}
+}
+
+// AddTeamMember adds a user to a team
+func AddTeamMember(orgRepo repository.OrganizationRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		rowsAffected, err := orgRepo.AddTeamMember(c.Request.Context(), c.Param("id"), c.Param("team_id"), c.Param("user_id"))
+		if err != nil {
+			respondInternalError(c, err)
+			return
+		}
+
+		if rowsAffected == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Team or user not found, or already a member"})
+			return
+		}
+		audit.Log(c.Request.Context(), "Team member added", "org_id", c.Param("id"), "team_id", c.Param("team_id"), "member_user_id", c.Param("user_id"))
+
+		c.JSON(http.StatusOK, gin.H{"message": "Team member added"})
+	}
+}
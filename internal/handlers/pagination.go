@@ -0,0 +1,25 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yashjain/konnect/internal/middleware"
+	"github.com/yashjain/konnect/pkg/types"
+)
+
+// errPageNumbers rejects page numbers in API versions that page by cursor alone
+var errPageNumbers = errors.New("page is not supported; follow next_cursor to the next page")
+
+// cursorPaging pages lists requested from API v2 and later by cursor alone,
+// rejecting page numbers
+func cursorPaging(c *gin.Context, params *types.PaginationParams) error {
+	if middleware.APIVersion(c) < 2 {
+		return nil
+	}
+	if c.Query("page") != "" {
+		return errPageNumbers
+	}
+	params.CursorOnly = true
+	return nil
+}
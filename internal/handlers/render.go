@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yashjain/konnect/internal/models"
+	"github.com/yashjain/konnect/internal/sanitize"
+)
+
+// renderModeHTML is the only supported value of the render query parameter
+const renderModeHTML = "html"
+
+// errInvalidRender is returned when the render query parameter is not recognised
+var errInvalidRender = errors.New("render must be 'html' when provided")
+
+// wantsHTML reports whether the client asked for rendered markdown via ?render=html
+func wantsHTML(c *gin.Context) (bool, error) {
+	switch c.Query("render") {
+	case "":
+		return false, nil
+	case renderModeHTML:
+		return true, nil
+	default:
+		return false, errInvalidRender
+	}
+}
+
+// renderServices fills DescriptionHTML for each service
+func renderServices(services []models.Service) error {
+	for i := range services {
+		if err := renderService(&services[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// renderService fills DescriptionHTML from the markdown description
+func renderService(service *models.Service) error {
+	rendered, err := sanitize.RenderHTML(service.Description)
+	if err != nil {
+		return err
+	}
+	service.DescriptionHTML = rendered
+	return nil
+}
+
+// renderVersion fills ChangelogHTML
+func renderVersion(version *models.Version) error {
+	rendered, err := sanitize.RenderHTML(version.Changelog)
+	if err != nil {
+		return err
+	}
+	version.ChangelogHTML = rendered
+	return nil
+}
+
+// renderVersions fills ChangelogHTML for each version
+func renderVersions(versions []models.Version) error {
+	for i := range versions {
+		if err := renderVersion(&versions[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yashjain/konnect/internal/middleware"
+	"github.com/yashjain/konnect/internal/models"
+	"github.com/yashjain/konnect/internal/repository"
+	"github.com/yashjain/konnect/pkg/types"
+	"github.com/yashjain/konnect/pkg/utils"
+)
+
+const (
+	// defaultSearchLimit and maxSearchLimit bound the hits returned per type by a global search
+	defaultSearchLimit = 5
+	maxSearchLimit     = 20
+
+	// defaultSuggestLimit and maxSuggestLimit bound the suggestions returned for a typeahead prefix
+	defaultSuggestLimit = 10
+	maxSuggestLimit     = 25
+
+	// maxSummaryLength is the most characters of a description or changelog shown in a hit
+	maxSummaryLength = 140
+)
+
+// GlobalSearch searches everything
+func GlobalSearch(serviceRepo repository.ServiceRepository, versionRepo repository.VersionRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		query := strings.TrimSpace(c.Query("q"))
+		if query == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "search query 'q' is required"})
+			return
+		}
+		if err := utils.ValidateQuery(query, utils.MinQueryLength); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		limit := defaultSearchLimit
+		if raw := c.Query("limit"); raw != "" {
+			n, err := strconv.Atoi(raw)
+			if err != nil || n < 1 || n > maxSearchLimit {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 20"})
+				return
+			}
+			limit = n
+		}
+
+		ctx := c.Request.Context()
+		p := middleware.Principal(c)
+
+		services, _, err := serviceRepo.SearchServices(ctx, p, types.SearchParams{
+			Query:     query,
+			Mode:      types.SearchModeNatural,
+			Page:      1,
+			PageSize:  limit,
+			SkipCount: true,
+		})
+		if err != nil {
+			respondInternalError(c, err)
+			return
+		}
+		// Without a count the page holds a look-ahead row
+		if len(services) > limit {
+			services = services[:limit]
+		}
+
+		versions, err := versionRepo.SearchVersions(ctx, p, query, limit)
+		if err != nil {
+			respondInternalError(c, err)
+			return
+		}
+
+		api := apiPath(c)
+		serviceHits := make([]models.SearchHit, len(services))
+		for i, s := range services {
+			serviceHits[i] = models.SearchHit{
+				Type:    models.SearchHitService,
+				ID:      s.ID,
+				Title:   s.Name,
+				Summary: summarize(s.Description),
+				Link:    serviceLink(api, s.ID),
+			}
+		}
+
+		versionHits := make([]models.SearchHit, len(versions))
+		for i, v := range versions {
+			versionHits[i] = models.SearchHit{
+				Type:    models.SearchHitVersion,
+				ID:      v.ID,
+				Title:   v.Semver,
+				Summary: summarize(v.Changelog),
+				Link:    versionLink(api, v.ServiceID, v.ID),
+			}
+		}
+
+		c.JSON(http.StatusOK, models.GlobalSearchResponse{
+			Query: query,
+			Groups: []models.SearchGroup{
+				{Type: models.SearchHitService, Hits: serviceHits},
+				{Type: models.SearchHitVersion, Hits: versionHits},
+			},
+		})
+	}
+}
+
+// summarize returns the first line of markdown text, cut to maxSummaryLength characters
+func summarize(text string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(text), "\n")
+	if runes := []rune(line); len(runes) > maxSummaryLength {
+		return string(runes[:maxSummaryLength]) + "…"
+	}
+	return line
+}
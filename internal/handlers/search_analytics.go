@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yashjain/konnect/internal/repository"
+)
+
+const (
+	// defaultAnalyticsWindow is how far back search analytics look by default
+	defaultAnalyticsWindow = 7 * 24 * time.Hour
+
+	// defaultAnalyticsLimit and maxAnalyticsLimit bound the queries listed per kind
+	defaultAnalyticsLimit = 20
+	maxAnalyticsLimit     = 100
+)
+
+// SearchAnalytics reports on searches
+func SearchAnalytics(analyticsRepo repository.SearchAnalyticsRepository, sampleRate float64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orgID := c.Query("org_id")
+		if orgID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "org_id is required"})
+			return
+		}
+
+		window := defaultAnalyticsWindow
+		if raw := c.Query("since"); raw != "" {
+			d, err := time.ParseDuration(raw)
+			if err != nil || d <= 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "since must be a positive duration such as 168h"})
+				return
+			}
+			window = d
+		}
+
+		limit := defaultAnalyticsLimit
+		if raw := c.Query("limit"); raw != "" {
+			n, err := strconv.Atoi(raw)
+			if err != nil || n < 1 || n > maxAnalyticsLimit {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 100"})
+				return
+			}
+			limit = n
+		}
+
+		analytics, err := analyticsRepo.GetSearchAnalytics(c.Request.Context(), orgID, time.Now().Add(-window).UTC(), limit)
+		if err != nil {
+			respondInternalError(c, err)
+			return
+		}
+		analytics.SampleRate = sampleRate
+
+		c.JSON(http.StatusOK, analytics)
+	}
+}
@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yashjain/konnect/internal/models"
+	"github.com/yashjain/konnect/internal/repository"
+	"github.com/yashjain/konnect/pkg/utils"
+)
+
+const (
+	// maxSynonyms bounds the synonyms of one term
+	maxSynonyms = 20
+
+	// maxSynonymLength bounds the length of a term or synonym, in characters
+	maxSynonymLength = 100
+)
+
+var errInvalidTerm = fmt.Errorf("term must be one word of 1 to %d letters or digits", maxSynonymLength)
+
+// GetSynonyms lists search synonyms
+func GetSynonyms(synonymRepo repository.SynonymRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		synonyms, err := synonymRepo.GetSynonyms(c.Request.Context())
+		if err != nil {
+			respondInternalError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": synonyms})
+	}
+}
+
+// SetSynonyms sets the synonyms of a search term
+func SetSynonyms(synonymRepo repository.SynonymRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		term, err := normalizeTerm(c.Param("term"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var body models.SearchSynonyms
+		if err := c.ShouldBindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		synonyms, err := normalizeSynonyms(term, body.Synonyms)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := synonymRepo.SetSynonyms(c.Request.Context(), term, synonyms); err != nil {
+			respondInternalError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, models.SearchSynonyms{Term: term, Synonyms: synonyms})
+	}
+}
+
+// DeleteSynonyms deletes the synonyms of a search term
+func DeleteSynonyms(synonymRepo repository.SynonymRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		term, err := normalizeTerm(c.Param("term"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		deleted, err := synonymRepo.DeleteSynonyms(c.Request.Context(), term)
+		if err != nil {
+			respondInternalError(c, err)
+			return
+		}
+
+		if deleted == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Term has no synonyms"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Synonyms deleted"})
+	}
+}
+
+// normalizeTerm folds a term, which must be a single word since searches are
+// expanded word by word
+func normalizeTerm(term string) (string, error) {
+	term = utils.Fold(strings.TrimSpace(term))
+	if term == "" || len([]rune(term)) > maxSynonymLength {
+		return "", errInvalidTerm
+	}
+	for _, r := range term {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			return "", errInvalidTerm
+		}
+	}
+	return term, nil
+}
+
+// normalizeSynonyms folds synonyms and collapses their whitespace, dropping
+// duplicates and the term itself
+func normalizeSynonyms(term string, synonyms []string) ([]string, error) {
+	seen := map[string]bool{term: true}
+	normalized := []string{}
+	for _, synonym := range synonyms {
+		synonym = utils.Fold(strings.Join(strings.Fields(synonym), " "))
+		if synonym == "" || len([]rune(synonym)) > maxSynonymLength {
+			return nil, fmt.Errorf("synonyms must be 1 to %d characters", maxSynonymLength)
+		}
+		if !seen[synonym] {
+			seen[synonym] = true
+			normalized = append(normalized, synonym)
+		}
+	}
+	if len(normalized) == 0 || len(normalized) > maxSynonyms {
+		return nil, fmt.Errorf("a term needs 1 to %d synonyms", maxSynonyms)
+	}
+	return normalized, nil
+}
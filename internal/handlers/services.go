@@ -2,28 +2,46 @@ package handlers
 
 import (
 	"database/sql"
+	"errors"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
-	"github.com/yashjain/konnect/internal/database"
+	"github.com/yashjain/konnect/internal/app"
+	"github.com/yashjain/konnect/internal/filter"
+	"github.com/yashjain/konnect/internal/middleware"
 	"github.com/yashjain/konnect/internal/models"
+	"github.com/yashjain/konnect/internal/repository"
+	"github.com/yashjain/konnect/internal/sanitize"
+	"github.com/yashjain/konnect/internal/search"
 	"github.com/yashjain/konnect/pkg/types"
 	"github.com/yashjain/konnect/pkg/utils"
 )
 
-// GetServices godoc
-// @Summary Get all services
-// @Description Get a paginated list of all services
-// @Tags services
-// @Produce json
-// @Param page query int false "Page number (default: 1)" minimum(1)
-// @Param page_size query int false "Number of items per page (default: 10, max: 100)" minimum(1) maximum(100)
-// @Success 200 {object} types.PaginatedResponse{data=[]models.Service}
-// @Failure 400 {object} map[string]interface{}
-// @Failure 500 {object} map[string]interface{}
-// @Router /services [get]
-func GetServices(c *gin.Context) {
+// GetServices gets all services
+func GetServices(serviceRepo repository.ServiceRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		listServices(c, serviceRepo, "")
+	}
+}
+
+// GetTeamServices gets the services a team owns
+func GetTeamServices(serviceRepo repository.ServiceRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		team, err := utils.NormalizeOwnerTeam(c.Param("team"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		listServices(c, serviceRepo, team)
+	}
+}
+
+// listServices answers a page of services, narrowed to those team owns unless
+// it is empty
+func listServices(c *gin.Context, serviceRepo repository.ServiceRepository, team string) {
 	// Get pagination parameters
 	params := utils.GetPaginationParams(c)
 
@@ -37,187 +55,488 @@ func GetServices(c *gin.Context) {
 		return
 	}
 
-	// Get services from database
-	services, total, err := database.GetServices(params)
+	cursor, err := utils.GetCursor(c)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-
-	// Create paginated response
-	pagination := utils.CalculatePagination(params.Page, params.PageSize, total)
-	response := types.PaginatedResponse{
-		Data:       services,
-		Pagination: pagination,
-	}
-
-	c.JSON(http.StatusOK, response)
-}
-
-// SearchServices godoc
-// @Summary Search services
-// @Description Search services by name, slug, or description using full-text search
-// @Tags services
-// @Produce json
-// @Param q query string true "Search query"
-// @Param page query int false "Page number (default: 1)" minimum(1)
-// @Param page_size query int false "Number of items per page (default: 10, max: 100)" minimum(1) maximum(100)
-// @Success 200 {object} types.PaginatedResponse{data=[]models.Service}
-// @Failure 400 {object} map[string]interface{}
-// @Failure 500 {object} map[string]interface{}
-// @Router /services/search [get]
-func SearchServices(c *gin.Context) {
-	// Get search parameters
-	params := utils.GetSearchParams(c)
-
-	// Validate search query
-	if params.Query == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "search query 'q' is required"})
+	params.Cursor = cursor
+	if err := cursorPaging(c, &params); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Validate pagination parameters
-	if params.Page < 1 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "page must be greater than 0"})
+	if err := serviceFilters(c, &params); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	if params.PageSize < 1 || params.PageSize > 100 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "page_size must be between 1 and 100"})
+	if team != "" {
+		params.Owner = team
+	}
+
+	render, err := wantsHTML(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Search services in database
-	services, total, err := database.SearchServices(params)
+	// Get services from database
+	services, total, err := serviceRepo.GetServices(c.Request.Context(), middleware.Principal(c), params)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondInternalError(c, err)
 		return
 	}
 
+	services, pagination := utils.Paginate(services, params, total, serviceCursor)
+
+	if render {
+		if err := renderServices(services); err != nil {
+			respondInternalError(c, err)
+			return
+		}
+	}
+
 	// Create paginated response
-	pagination := utils.CalculatePagination(params.Page, params.PageSize, total)
 	response := types.PaginatedResponse{
 		Data:       services,
 		Pagination: pagination,
 	}
 
-	c.JSON(http.StatusOK, response)
+	respondServices(c, response, services)
 }
 
-// CreateService godoc
-// @Summary Create a new service
-// @Description Create a new service with the provided information
-// @Tags services
-// @Accept json
-// @Produce json
-// @Param service body models.Service true "Service object"
-// @Success 201 {object} models.Service
-// @Failure 400 {object} map[string]interface{}
-// @Failure 500 {object} map[string]interface{}
-// @Router /services [post]
-func CreateService(c *gin.Context) {
-	var service models.Service
-	if err := c.ShouldBindJSON(&service); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
+// serviceFilters sets the search, filter, tags and metadata of a services listing from the request
+func serviceFilters(c *gin.Context, params *types.PaginationParams) error {
+	var err error
+	params.Filter, err = filter.Parse(c.Query("filter"), filter.ServiceFields)
+	if err != nil {
+		return err
 	}
 
-	service.ID = uuid.New().String()
+	params.Tags, err = utils.GetTagFilter(c)
+	if err != nil {
+		return err
+	}
 
-	err := database.CreateService(&service)
+	params.Metadata, err = utils.GetMetadataFilter(c)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+		return err
 	}
 
-	c.JSON(http.StatusCreated, service)
-}
+	params.Category = strings.TrimSpace(c.Query("category"))
 
-// GetService godoc
-// @Summary Get a service by ID
-// @Description Get a specific service by its ID
-// @Tags services
-// @Produce json
-// @Param id path string true "Service ID"
-// @Success 200 {object} models.Service
-// @Failure 404 {object} map[string]interface{}
-// @Failure 500 {object} map[string]interface{}
-// @Router /services/{id} [get]
-func GetService(c *gin.Context) {
-	id := c.Param("id")
-
-	service, err := database.GetServiceByID(id)
-	if err == sql.ErrNoRows {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Service not found"})
-		return
+	if owner := c.Query("owner"); owner != "" {
+		if params.Owner, err = utils.NormalizeOwner(owner); err != nil {
+			return err
+		}
 	}
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+
+	if params.DeployedIn, err = deployedIn(c); err != nil {
+		return err
 	}
 
-	c.JSON(http.StatusOK, service)
+	params.Query = strings.TrimSpace(c.Query("q"))
+	if params.Query != "" {
+		return utils.ValidateQuery(params.Query, utils.MinQueryLength)
+	}
+	return nil
 }
 
-// UpdateService godoc
-// @Summary Update a service
-// @Description Update a service with the provided information
-// @Tags services
-// @Accept json
-// @Produce json
-// @Param id path string true "Service ID"
-// @Param service body models.Service true "Service object"
-// @Success 200 {object} models.Service
-// @Failure 400 {object} map[string]interface{}
-// @Failure 404 {object} map[string]interface{}
-// @Failure 500 {object} map[string]interface{}
-// @Router /services/{id} [put]
-func UpdateService(c *gin.Context) {
-	id := c.Param("id")
-
-	var service models.Service
-	if err := c.ShouldBindJSON(&service); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
+// serviceCursor is the keyset pagination cursor of a service
+func serviceCursor(service models.Service) types.Cursor {
+	return types.Cursor{CreatedAt: service.CreatedAt, ID: service.ID}
+}
+
+// SearchServices searches services
+func SearchServices(serviceRepo repository.ServiceRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Get search parameters
+		params := utils.GetSearchParams(c)
+
+		// Without a query, browse the whole catalog instead
+		params.Query = strings.TrimSpace(params.Query)
+		if params.Query == "" && params.Mode == types.SearchModeNatural {
+			params.Mode = types.SearchModeBrowse
+		}
+		if params.Query != "" {
+			if err := utils.ValidateQuery(params.Query, utils.MinQueryLength); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		// Validate the search mode, and boolean queries before they reach the database
+		switch params.Mode {
+		case types.SearchModeNatural:
+		case types.SearchModeBoolean:
+			if _, err := search.ParseBoolean(params.Query); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		case types.SearchModeBrowse:
+			if params.Query != "" {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "q must be empty in browse mode"})
+				return
+			}
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "mode must be natural, boolean or browse"})
+			return
+		}
+		if params.Fuzzy && params.Mode != types.SearchModeNatural {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "fuzzy is only supported in natural mode"})
+			return
+		}
+
+		switch params.Sort {
+		case types.SortRelevance, types.SortName, types.SortCreatedAt, types.SortVersionsCount:
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "sort must be relevance, name, created_at or versions_count"})
+			return
+		}
+
+		// Validate pagination parameters
+		if params.Page < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "page must be greater than 0"})
+			return
+		}
+		if params.PageSize < 1 || params.PageSize > 100 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "page_size must be between 1 and 100"})
+			return
+		}
+
+		minScore, err := utils.GetMinScore(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if minScore != nil && params.Mode == types.SearchModeBrowse {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "min_score is not supported in browse mode"})
+			return
+		}
+		params.MinScore = minScore
+
+		params.Tags, err = utils.GetTagFilter(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		facets, err := utils.GetFacets(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		render, err := wantsHTML(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		// Search services in database
+		services, total, err := serviceRepo.SearchServices(c.Request.Context(), middleware.Principal(c), params)
+		if err != nil {
+			respondInternalError(c, err)
+			return
+		}
+
+		var pagination types.Pagination
+		if params.SkipCount {
+			services, pagination = utils.PageWithoutCount(services, params.Page, params.PageSize)
+		} else {
+			pagination = utils.CalculatePagination(params.Page, params.PageSize, total)
+		}
+
+		if render {
+			if err := renderServices(services); err != nil {
+				respondInternalError(c, err)
+				return
+			}
+		}
+
+		// Create paginated response
+		response := types.PaginatedResponse{
+			Data:       services,
+			Pagination: pagination,
+		}
+
+		if len(facets) > 0 {
+			response.Facets, err = serviceRepo.SearchFacets(c.Request.Context(), middleware.Principal(c), params, facets)
+			if err != nil {
+				respondInternalError(c, err)
+				return
+			}
+		}
+
+		respondServices(c, response, services)
 	}
+}
 
-	rowsAffected, err := database.UpdateService(id, &service)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+// SuggestServices suggests services
+func SuggestServices(serviceRepo repository.ServiceRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		prefix := strings.TrimSpace(c.Query("q"))
+		if prefix == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "search query 'q' is required"})
+			return
+		}
+		// Prefixes are not matched against the full-text index, so one character will do
+		if err := utils.ValidateQuery(prefix, 1); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		limit := defaultSuggestLimit
+		if raw := c.Query("limit"); raw != "" {
+			n, err := strconv.Atoi(raw)
+			if err != nil || n < 1 || n > maxSuggestLimit {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 25"})
+				return
+			}
+			limit = n
+		}
+
+		suggestions, err := serviceRepo.SuggestServices(c.Request.Context(), middleware.Principal(c), prefix, limit)
+		if err != nil {
+			respondInternalError(c, err)
+			return
+		}
+		if suggestions == nil {
+			suggestions = []models.ServiceSuggestion{}
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": suggestions})
 	}
+}
 
-	if rowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Service not found"})
-		return
+// CreateService creates a new service
+func CreateService(serviceRepo repository.ServiceRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var service models.Service
+		if err := bindResource(c, jsonAPIServices, &service); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := normalizeSlug(&service); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		tags, err := utils.NormalizeTags(service.Tags)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if tags == nil {
+			tags = []string{}
+		}
+		service.Tags = tags
+
+		if err := utils.ValidateMetadata(service.Metadata); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := normalizeOwners(&service); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		team, email := service.Owner()
+		service.OwnerTeam, service.OwnerEmail = &team, &email
+
+		principal := middleware.Principal(c)
+		service.ID = uuid.New().String()
+		service.OrgID = principal.OrgID
+		service.Description = sanitize.Markdown(service.Description)
+		if service.Visibility == "" {
+			service.Visibility = models.VisibilityPublic
+		}
+
+		err = serviceRepo.CreateService(c.Request.Context(), &service, app.CreatorGrants(principal, &service)...)
+		if errors.Is(err, repository.ErrConflict) {
+			c.JSON(http.StatusConflict, gin.H{"error": "A service with this name or slug already exists"})
+			return
+		}
+		if err != nil {
+			respondInternalError(c, err)
+			return
+		}
+
+		respondService(c, http.StatusCreated, &service)
+	}
+}
+
+// GetService gets a service by ID
+func GetService(serviceRepo repository.ServiceRepository, accessRepo repository.AccessRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+
+		render, err := wantsHTML(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := app.Authorize(c.Request.Context(), accessRepo, middleware.Principal(c), id, models.PermissionRead); err != nil {
+			respondAccessError(c, err)
+			return
+		}
+
+		service, err := serviceRepo.GetServiceByID(c.Request.Context(), middleware.OrgID(c), id)
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Service not found"})
+			return
+		}
+		if err != nil {
+			respondInternalError(c, err)
+			return
+		}
+
+		if render {
+			if err := renderService(service); err != nil {
+				respondInternalError(c, err)
+				return
+			}
+		}
+
+		respondService(c, http.StatusOK, service)
 	}
+}
+
+// UpdateService updates a service
+func UpdateService(serviceRepo repository.ServiceRepository, accessRepo repository.AccessRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+
+		var service models.Service
+		if err := bindResource(c, jsonAPIServices, &service); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		service.Description = sanitize.Markdown(service.Description)
+		if err := normalizeSlug(&service); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 
-	service.ID = id
-	c.JSON(http.StatusOK, service)
+		// Tags left out of the body keep their current value
+		tags, err := utils.NormalizeTags(service.Tags)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		service.Tags = tags
+
+		// So does metadata
+		if err := utils.ValidateMetadata(service.Metadata); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		// So do owners, while empty ones clear them
+		if err := normalizeOwners(&service); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := app.Authorize(c.Request.Context(), accessRepo, middleware.Principal(c), id, models.PermissionWrite); err != nil {
+			respondAccessError(c, err)
+			return
+		}
+
+		rowsAffected, err := serviceRepo.UpdateService(c.Request.Context(), middleware.OrgID(c), id, &service)
+		if errors.Is(err, repository.ErrConflict) {
+			c.JSON(http.StatusConflict, gin.H{"error": "A service with this name or slug already exists"})
+			return
+		}
+		if err != nil {
+			respondInternalError(c, err)
+			return
+		}
+
+		if rowsAffected == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Service not found"})
+			return
+		}
+
+		// Fields left out of the body kept their value, and timestamps and counts
+		// are the database's, so the response is the service as stored
+		updated, err := serviceRepo.GetServiceByID(c.Request.Context(), middleware.OrgID(c), id)
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Service not found"})
+			return
+		}
+		if err != nil {
+			respondInternalError(c, err)
+			return
+		}
+		respondService(c, http.StatusOK, updated)
+	}
 }
 
-// DeleteService godoc
-// @Summary Delete a service
-// @Description Delete a service by its ID
-// @Tags services
-// @Produce json
-// @Param id path string true "Service ID"
-// @Success 200 {object} map[string]interface{}
-// @Failure 404 {object} map[string]interface{}
-// @Failure 500 {object} map[string]interface{}
-// @Router /services/{id} [delete]
-func DeleteService(c *gin.Context) {
-	id := c.Param("id")
-
-	rowsAffected, err := database.DeleteService(id)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+// DeleteService deletes a service no team consumes
+func DeleteService(serviceRepo repository.ServiceRepository, accessRepo repository.AccessRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+
+		if err := app.Authorize(c.Request.Context(), accessRepo, middleware.Principal(c), id, models.PermissionWrite); err != nil {
+			respondAccessError(c, err)
+			return
+		}
+
+		rowsAffected, err := serviceRepo.DeleteService(c.Request.Context(), middleware.OrgID(c), id)
+		if errors.Is(err, repository.ErrInUse) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Service has consumers; they must unregister first"})
+			return
+		}
+		if err != nil {
+			respondInternalError(c, err)
+			return
+		}
+
+		if rowsAffected == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Service not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Service deleted"})
 	}
+}
 
-	if rowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Service not found"})
-		return
+// normalizeSlug rewrites the slug of service with utils.NormalizeSlug, so slugs
+// differing only in case, accents or punctuation are stored alike. An empty slug
+// is left as is.
+func normalizeSlug(service *models.Service) error {
+	if service.Slug == "" {
+		return nil
+	}
+	slug, err := utils.NormalizeSlug(service.Slug)
+	if err != nil {
+		return err
 	}
+	service.Slug = slug
+	return nil
+}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Service deleted"})
+// normalizeOwners rewrites the owner team of service with
+// utils.NormalizeOwnerTeam and its owner email with utils.NormalizeOwnerEmail.
+// Owners that are not set are left unset.
+func normalizeOwners(service *models.Service) error {
+	if service.OwnerTeam != nil {
+		team, err := utils.NormalizeOwnerTeam(*service.OwnerTeam)
+		if err != nil {
+			return err
+		}
+		service.OwnerTeam = &team
+	}
+	if service.OwnerEmail != nil {
+		email, err := utils.NormalizeOwnerEmail(*service.OwnerEmail)
+		if err != nil {
+			return err
+		}
+		service.OwnerEmail = &email
+	}
+	return nil
 }
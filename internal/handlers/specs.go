@@ -0,0 +1,308 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/yashjain/konnect/internal/apispec"
+	"github.com/yashjain/konnect/internal/app"
+	"github.com/yashjain/konnect/internal/middleware"
+	"github.com/yashjain/konnect/internal/models"
+	"github.com/yashjain/konnect/internal/repository"
+	"github.com/yashjain/konnect/pkg/utils"
+)
+
+// maxSpecBytes bounds the size of an uploaded OpenAPI document
+const maxSpecBytes = 4 << 20
+
+// specMediaTypes are the media types of each OpenAPI document format, the one
+// responses are served with first
+var specMediaTypes = map[string][]string{
+	apispec.FormatJSON: {"application/json", "application/vnd.oai.openapi+json"},
+	apispec.FormatYAML: {"application/yaml", "application/x-yaml", "text/yaml", "application/vnd.oai.openapi"},
+}
+
+// PutVersionSpec attaches an OpenAPI document to a version, replacing the one
+// it had, and stores its score by the rules of linter
+func PutVersionSpec(specRepo repository.SpecRepository, accessRepo repository.AccessRepository, linter *apispec.Linter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		serviceID := c.Param("id")
+
+		if err := app.Authorize(c.Request.Context(), accessRepo, middleware.Principal(c), serviceID, models.PermissionWrite); err != nil {
+			respondAccessError(c, err)
+			return
+		}
+
+		if contentType := c.GetHeader("Content-Type"); contentType != "" {
+			mediaType, _, err := mime.ParseMediaType(contentType)
+			if err != nil || specFormatOf(mediaType) == "" {
+				c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Content-Type must be application/json or application/yaml"})
+				return
+			}
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxSpecBytes))
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("OpenAPI document must be at most %d bytes", maxSpecBytes)})
+				return
+			}
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid OpenAPI document: " + err.Error()})
+			return
+		}
+		doc, err := apispec.Parse(body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		digest := sha256.Sum256(body)
+		score := linter.Lint(doc).Score
+		spec := models.VersionSpec{
+			VersionID:  c.Param("version_id"),
+			ServiceID:  serviceID,
+			Format:     apispec.FormatOf(body),
+			OpenAPI:    doc.OpenAPI,
+			Title:      doc.Title,
+			APIVersion: doc.Version,
+			Digest:     hex.EncodeToString(digest[:]),
+			Size:       len(body),
+			Score:      &score,
+			Content:    body,
+		}
+		err = specRepo.SetVersionSpec(c.Request.Context(), middleware.OrgID(c), &spec)
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Version not found"})
+			return
+		}
+		if err != nil {
+			respondInternalError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, spec)
+	}
+}
+
+// GetVersionSpec serves the OpenAPI document of a version as JSON or YAML,
+// whichever format= or else the Accept header asks for, and otherwise as uploaded
+func GetVersionSpec(specRepo repository.SpecRepository, accessRepo repository.AccessRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		serviceID := c.Param("id")
+
+		format := c.Query("format")
+		if format != "" && format != apispec.FormatJSON && format != apispec.FormatYAML {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "format must be json or yaml"})
+			return
+		}
+
+		if err := app.Authorize(c.Request.Context(), accessRepo, middleware.Principal(c), serviceID, models.PermissionRead); err != nil {
+			respondAccessError(c, err)
+			return
+		}
+
+		spec, err := specRepo.GetVersionSpec(c.Request.Context(), middleware.OrgID(c), serviceID, c.Param("version_id"))
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Version has no OpenAPI document"})
+			return
+		}
+		if err != nil {
+			respondInternalError(c, err)
+			return
+		}
+
+		if format == "" {
+			format = acceptedSpecFormat(c.GetHeader("Accept"), spec.Format)
+		}
+		body := spec.Content
+		if format != spec.Format {
+			doc, err := apispec.Parse(spec.Content)
+			if err == nil {
+				body, err = doc.Encode(format)
+			}
+			if err != nil {
+				respondInternalError(c, err)
+				return
+			}
+		}
+
+		c.Data(http.StatusOK, specMediaTypes[format][0]+"; charset=utf-8", body)
+	}
+}
+
+// LintVersionSpec checks the OpenAPI document of a version against the rules
+// of linter, listing the problems found along with its score
+func LintVersionSpec(specRepo repository.SpecRepository, accessRepo repository.AccessRepository, linter *apispec.Linter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		serviceID := c.Param("id")
+
+		if err := app.Authorize(c.Request.Context(), accessRepo, middleware.Principal(c), serviceID, models.PermissionRead); err != nil {
+			respondAccessError(c, err)
+			return
+		}
+
+		spec, err := specRepo.GetVersionSpec(c.Request.Context(), middleware.OrgID(c), serviceID, c.Param("version_id"))
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Version has no OpenAPI document"})
+			return
+		}
+		if err != nil {
+			respondInternalError(c, err)
+			return
+		}
+		doc, err := apispec.Parse(spec.Content)
+		if err != nil {
+			respondInternalError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, linter.Lint(doc))
+	}
+}
+
+// DeleteVersionSpec removes the OpenAPI document of a version
+func DeleteVersionSpec(specRepo repository.SpecRepository, accessRepo repository.AccessRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		serviceID := c.Param("id")
+
+		if err := app.Authorize(c.Request.Context(), accessRepo, middleware.Principal(c), serviceID, models.PermissionWrite); err != nil {
+			respondAccessError(c, err)
+			return
+		}
+
+		rowsAffected, err := specRepo.DeleteVersionSpec(c.Request.Context(), middleware.OrgID(c), serviceID, c.Param("version_id"))
+		if err != nil {
+			respondInternalError(c, err)
+			return
+		}
+		if rowsAffected == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Version has no OpenAPI document"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "OpenAPI document deleted"})
+	}
+}
+
+// CompareVersionSpecs lists the changes between the OpenAPI documents of two
+// versions, given by ID or semver, marking the breaking ones
+func CompareVersionSpecs(versionRepo repository.VersionRepository, specRepo repository.SpecRepository, accessRepo repository.AccessRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		serviceID := c.Param("id")
+
+		fromRef, toRef := strings.TrimSpace(c.Query("from")), strings.TrimSpace(c.Query("to"))
+		if fromRef == "" || toRef == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from and to are required"})
+			return
+		}
+
+		if err := app.Authorize(c.Request.Context(), accessRepo, middleware.Principal(c), serviceID, models.PermissionRead); err != nil {
+			respondAccessError(c, err)
+			return
+		}
+
+		var docs [2]*apispec.Document
+		var versions [2]models.SpecComparisonVersion
+		for i, ref := range []string{fromRef, toRef} {
+			version, spec, err := versionSpec(c, versionRepo, specRepo, serviceID, ref)
+			if errors.Is(err, sql.ErrNoRows) {
+				c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Version %s not found", ref)})
+				return
+			}
+			if err != nil {
+				respondInternalError(c, err)
+				return
+			}
+			if spec == nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Version %s has no OpenAPI document", version.Semver)})
+				return
+			}
+			if docs[i], err = apispec.Parse(spec.Content); err != nil {
+				respondInternalError(c, err)
+				return
+			}
+			versions[i] = models.SpecComparisonVersion{ID: version.ID, Semver: version.Semver, Digest: spec.Digest}
+		}
+
+		comparison := models.SpecComparison{
+			From:     versions[0],
+			To:       versions[1],
+			Declared: utils.IsMajorBump(versions[0].Semver, versions[1].Semver),
+			Changes:  apispec.Compare(docs[0], docs[1]),
+		}
+		if comparison.Changes == nil {
+			comparison.Changes = []models.SpecChange{}
+		}
+		for _, change := range comparison.Changes {
+			if change.Breaking {
+				comparison.BreakingChanges++
+			}
+		}
+
+		c.JSON(http.StatusOK, comparison)
+	}
+}
+
+// versionSpec returns a version of a service, given by ID or else by semver,
+// and its OpenAPI document, nil when it has none
+func versionSpec(c *gin.Context, versionRepo repository.VersionRepository, specRepo repository.SpecRepository, serviceID, ref string) (*models.Version, *models.VersionSpec, error) {
+	ctx, orgID := c.Request.Context(), middleware.OrgID(c)
+	version, err := versionRepo.GetVersion(ctx, orgID, serviceID, ref)
+	if errors.Is(err, sql.ErrNoRows) {
+		version, err = versionRepo.GetVersionBySemver(ctx, orgID, serviceID, ref)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	spec, err := specRepo.GetVersionSpec(ctx, orgID, serviceID, version.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return version, nil, nil
+	}
+	return version, spec, err
+}
+
+// specFormatOf returns the OpenAPI document format of a media type, empty when it is neither
+func specFormatOf(mediaType string) string {
+	for format, mediaTypes := range specMediaTypes {
+		for _, t := range mediaTypes {
+			if mediaType == t {
+				return format
+			}
+		}
+	}
+	return ""
+}
+
+// acceptedSpecFormat returns the OpenAPI document format an Accept header
+// prefers, by highest q and then the order listed, or fallback when it lists
+// neither format
+func acceptedSpecFormat(accept, fallback string) string {
+	best, bestQ := fallback, 0.0
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		if format := specFormatOf(mediaType); format != "" && q > bestQ {
+			best, bestQ = format, q
+		}
+	}
+	return best
+}
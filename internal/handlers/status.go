@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yashjain/konnect/internal/middleware"
+)
+
+// statusCheckInterval is how long Status reuses its dependency checks, so a
+// busy status page does not load the dependencies
+const statusCheckInterval = 10 * time.Second
+
+// StatusWindow is the request counts over a recent period
+type StatusWindow struct {
+	Requests  uint64  `json:"requests"`
+	Errors    uint64  `json:"errors"`
+	ErrorRate float64 `json:"error_rate"`
+}
+
+// Status reports the status of the API for a status page
+func Status(started time.Time, stats *middleware.RequestStats, deps ...Dependency) gin.HandlerFunc {
+	var (
+		mu        sync.Mutex
+		checkedAt time.Time
+		statuses  map[string]DependencyStatus
+		last      = &dependencyErrors{errors: make(map[string]dependencyError, len(deps))}
+	)
+	return func(c *gin.Context) {
+		now := time.Now()
+		mu.Lock()
+		if now.Sub(checkedAt) >= statusCheckInterval {
+			// The checks are shared with later requests, so a client going away
+			// must not fail them; each is still bounded by dependencyCheckTimeout
+			statuses = checkDependencies(context.WithoutCancel(c.Request.Context()), deps, last)
+			checkedAt = now
+		}
+		dependencies := make(map[string]string, len(statuses))
+		for name, s := range statuses {
+			dependencies[name] = s.Status
+		}
+		status, _ := overallStatus(statuses)
+		mu.Unlock()
+
+		requests, errors := stats.Totals()
+		c.JSON(http.StatusOK, gin.H{
+			"status":         status,
+			"started_at":     started.UTC(),
+			"uptime_seconds": int64(now.Sub(started).Seconds()),
+			"requests":       StatusWindow{Requests: requests, Errors: errors, ErrorRate: errorRate(requests, errors)},
+			"last_5m":        statusWindow(stats, now, 5*time.Minute),
+			"last_1h":        statusWindow(stats, now, time.Hour),
+			"dependencies":   dependencies,
+		})
+	}
+}
+
+// statusWindow returns the request counts of stats over the last window
+func statusWindow(stats *middleware.RequestStats, now time.Time, window time.Duration) StatusWindow {
+	requests, errors := stats.Window(now, window)
+	return StatusWindow{Requests: requests, Errors: errors, ErrorRate: errorRate(requests, errors)}
+}
+
+// errorRate returns the share of requests that failed, or 0 without requests
+func errorRate(requests, errors uint64) float64 {
+	if requests == 0 {
+		return 0
+	}
+	return float64(errors) / float64(requests)
+}
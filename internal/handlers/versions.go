@@ -1,90 +1,200 @@
 package handlers
 
 import (
+	"database/sql"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
-	"github.com/yashjain/konnect/internal/database"
+	"github.com/yashjain/konnect/internal/app"
+	"github.com/yashjain/konnect/internal/filter"
+	"github.com/yashjain/konnect/internal/middleware"
 	"github.com/yashjain/konnect/internal/models"
+	"github.com/yashjain/konnect/internal/repository"
+	"github.com/yashjain/konnect/internal/sanitize"
 	"github.com/yashjain/konnect/pkg/types"
 	"github.com/yashjain/konnect/pkg/utils"
 )
 
-// GetVersions godoc
-// @Summary Get versions for a service
-// @Description Get a paginated list of versions for a specific service
-// @Tags versions
-// @Produce json
-// @Param id path string true "Service ID"
-// @Param page query int false "Page number (default: 1)" minimum(1)
-// @Param page_size query int false "Number of items per page (default: 10, max: 100)" minimum(1) maximum(100)
-// @Success 200 {object} types.PaginatedResponse{data=[]models.Version}
-// @Failure 400 {object} map[string]interface{}
-// @Failure 500 {object} map[string]interface{}
-// @Router /services/{id}/versions [get]
-func GetVersions(c *gin.Context) {
-	serviceID := c.Param("id")
-
-	// Get pagination parameters
-	params := utils.GetPaginationParams(c)
-
-	// Validate pagination parameters
-	if params.Page < 1 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "page must be greater than 0"})
-		return
-	}
-	if params.PageSize < 1 || params.PageSize > 100 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "page_size must be between 1 and 100"})
-		return
+// GetVersions gets versions for a service
+func GetVersions(versionRepo repository.VersionRepository, accessRepo repository.AccessRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		serviceID := c.Param("id")
+
+		// Get pagination parameters
+		params := utils.GetPaginationParams(c)
+
+		// Validate pagination parameters
+		if params.Page < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "page must be greater than 0"})
+			return
+		}
+		if params.PageSize < 1 || params.PageSize > 100 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "page_size must be between 1 and 100"})
+			return
+		}
+
+		cursor, err := utils.GetCursor(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		params.Cursor = cursor
+		if err := cursorPaging(c, &params); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := versionFilters(c, &params); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		render, err := wantsHTML(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := app.Authorize(c.Request.Context(), accessRepo, middleware.Principal(c), serviceID, models.PermissionRead); err != nil {
+			respondAccessError(c, err)
+			return
+		}
+
+		// Get versions from database
+		versions, total, err := versionRepo.GetVersions(c.Request.Context(), middleware.OrgID(c), serviceID, params)
+		if err != nil {
+			respondInternalError(c, err)
+			return
+		}
+
+		versions, pagination := utils.Paginate(versions, params, total, versionCursor)
+
+		if render {
+			if err := renderVersions(versions); err != nil {
+				respondInternalError(c, err)
+				return
+			}
+		}
+
+		// Create paginated response
+		response := types.PaginatedResponse{
+			Data:       versions,
+			Pagination: pagination,
+		}
+
+		respondVersions(c, response, versions)
 	}
+}
 
-	// Get versions from database
-	versions, total, err := database.GetVersions(serviceID, params)
+// versionFilters sets the filter, metadata and environment of a versions listing from the request
+func versionFilters(c *gin.Context, params *types.PaginationParams) error {
+	var err error
+	params.Filter, err = filter.Parse(c.Query("filter"), filter.VersionFields)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+		return err
 	}
 
-	// Create paginated response
-	pagination := utils.CalculatePagination(params.Page, params.PageSize, total)
-	response := types.PaginatedResponse{
-		Data:       versions,
-		Pagination: pagination,
+	params.Metadata, err = utils.GetMetadataFilter(c)
+	if err != nil {
+		return err
 	}
 
-	c.JSON(http.StatusOK, response)
+	params.DeployedIn, err = deployedIn(c)
+	return err
 }
 
-// CreateVersion godoc
-// @Summary Create a new version
-// @Description Create a new version for a specific service
-// @Tags versions
-// @Accept json
-// @Produce json
-// @Param id path string true "Service ID"
-// @Param version body models.Version true "Version object"
-// @Success 201 {object} models.Version
-// @Failure 400 {object} map[string]interface{}
-// @Failure 500 {object} map[string]interface{}
-// @Router /services/{id}/versions [post]
-func CreateVersion(c *gin.Context) {
-	serviceID := c.Param("id")
-
-	var version models.Version
-	if err := c.ShouldBindJSON(&version); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
+// versionCursor is the keyset pagination cursor of a version
+func versionCursor(version models.Version) types.Cursor {
+	return types.Cursor{CreatedAt: version.CreatedAt, ID: version.ID}
+}
 
-	version.ID = uuid.New().String()
-	version.ServiceID = serviceID
+// GetVersion gets a version of a service
+func GetVersion(versionRepo repository.VersionRepository, accessRepo repository.AccessRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		serviceID := c.Param("id")
+		id := c.Param("version_id")
 
-	err := database.CreateVersion(&version)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+		render, err := wantsHTML(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := app.Authorize(c.Request.Context(), accessRepo, middleware.Principal(c), serviceID, models.PermissionRead); err != nil {
+			respondAccessError(c, err)
+			return
+		}
+
+		var version *models.Version
+		if id == latestVersion {
+			// Versions are listed newest first
+			var versions []models.Version
+			versions, _, err = versionRepo.GetVersions(c.Request.Context(), middleware.OrgID(c), serviceID, types.PaginationParams{Page: 1, PageSize: 1, SkipCount: true})
+			if err == nil && len(versions) == 0 {
+				err = sql.ErrNoRows
+			}
+			if err == nil {
+				version = &versions[0]
+			}
+		} else {
+			version, err = versionRepo.GetVersion(c.Request.Context(), middleware.OrgID(c), serviceID, id)
+		}
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Version not found"})
+			return
+		}
+		if err != nil {
+			respondInternalError(c, err)
+			return
+		}
+
+		if render {
+			if err := renderVersion(version); err != nil {
+				respondInternalError(c, err)
+				return
+			}
+		}
+
+		respondVersion(c, http.StatusOK, version)
 	}
+}
 
-	c.JSON(http.StatusCreated, version)
+// CreateVersion creates a new version
+func CreateVersion(versionRepo repository.VersionRepository, accessRepo repository.AccessRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		serviceID := c.Param("id")
+
+		var version models.Version
+		if err := bindResource(c, jsonAPIVersions, &version); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := utils.ValidateMetadata(version.Metadata); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		version.ID = uuid.New().String()
+		version.ServiceID = serviceID
+		version.Changelog = sanitize.Markdown(version.Changelog)
+
+		if err := app.Authorize(c.Request.Context(), accessRepo, middleware.Principal(c), serviceID, models.PermissionWrite); err != nil {
+			respondAccessError(c, err)
+			return
+		}
+
+		err := versionRepo.CreateVersion(c.Request.Context(), middleware.OrgID(c), &version)
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Service not found"})
+			return
+		}
+		if err != nil {
+			respondInternalError(c, err)
+			return
+		}
+
+		respondVersion(c, http.StatusCreated, &version)
+	}
 }
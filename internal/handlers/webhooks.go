@@ -0,0 +1,324 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/yashjain/konnect/internal/audit"
+	"github.com/yashjain/konnect/internal/auth"
+	"github.com/yashjain/konnect/internal/middleware"
+	"github.com/yashjain/konnect/internal/models"
+	"github.com/yashjain/konnect/internal/repository"
+	"github.com/yashjain/konnect/internal/webhook"
+)
+
+const (
+	// defaultDeliveriesLimit and maxDeliveriesLimit bound the deliveries listed per subscription
+	defaultDeliveriesLimit = 50
+	maxDeliveriesLimit     = 200
+
+	// minWebhookSecretLength is the shortest secret accepted for a subscription
+	minWebhookSecretLength = 16
+)
+
+// WebhookSender attempts a single webhook delivery, recording its outcome in it
+type WebhookSender interface {
+	Attempt(ctx context.Context, url, secret string, delivery *models.WebhookDelivery)
+}
+
+// requireOrgWide answers 403 unless the request is made for the whole
+// organization, for resources such as webhook subscriptions that span every
+// service in it, including private ones. what names the resources in the error.
+func requireOrgWide(c *gin.Context, what string) bool {
+	if !middleware.Principal(c).IsOrgWide() {
+		c.JSON(http.StatusForbidden, gin.H{"error": what + " can only be managed with an organization-wide token"})
+		return false
+	}
+	return true
+}
+
+// validateSubscription checks a subscription's URL, event types, secret,
+// format and template, removing duplicate event types
+func validateSubscription(sub *models.WebhookSubscription) error {
+	u, err := url.Parse(sub.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("url must be an absolute http or https URL")
+	}
+	if len(sub.EventTypes) == 0 {
+		return fmt.Errorf("event_types must list at least one of %s", strings.Join(models.EventTypes, ", "))
+	}
+	var eventTypes []string
+	for _, t := range sub.EventTypes {
+		if !slices.Contains(models.EventTypes, t) {
+			return fmt.Errorf("unknown event type %q: must be one of %s", t, strings.Join(models.EventTypes, ", "))
+		}
+		if !slices.Contains(eventTypes, t) {
+			eventTypes = append(eventTypes, t)
+		}
+	}
+	sub.EventTypes = eventTypes
+	if sub.Secret != "" && len(sub.Secret) < minWebhookSecretLength {
+		return fmt.Errorf("secret must be at least %d characters", minWebhookSecretLength)
+	}
+	switch sub.Format {
+	case "", models.WebhookFormatJSON, models.WebhookFormatSlack, models.WebhookFormatTeams:
+	default:
+		return fmt.Errorf("format must be one of %s, %s or %s", models.WebhookFormatJSON, models.WebhookFormatSlack, models.WebhookFormatTeams)
+	}
+	if sub.Template != "" {
+		// Rendering a sample catches references to fields that do not exist
+		sample := webhook.Message{Event: models.Event{Type: models.EventVersionReleased}}
+		if _, err := webhook.RenderChat(models.WebhookFormatSlack, sub.Template, sample); err != nil {
+			return fmt.Errorf("invalid template: %w", err)
+		}
+	}
+	return nil
+}
+
+// GetWebhookSubscriptions lists webhook subscriptions
+func GetWebhookSubscriptions(webhookRepo repository.WebhookRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !requireOrgWide(c, "webhook subscriptions") {
+			return
+		}
+
+		subs, err := webhookRepo.GetWebhookSubscriptions(c.Request.Context(), middleware.OrgID(c))
+		if err != nil {
+			respondInternalError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": subs})
+	}
+}
+
+// CreateWebhookSubscription subscribes a webhook to events
+func CreateWebhookSubscription(webhookRepo repository.WebhookRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !requireOrgWide(c, "webhook subscriptions") {
+			return
+		}
+
+		var sub models.WebhookSubscription
+		if err := c.ShouldBindJSON(&sub); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := validateSubscription(&sub); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if sub.Secret == "" {
+			secret, err := auth.GenerateToken()
+			if err != nil {
+				respondInternalError(c, err)
+				return
+			}
+			sub.Secret = secret
+		}
+		if sub.Active == nil {
+			active := true
+			sub.Active = &active
+		}
+		if sub.Format == "" {
+			sub.Format = models.WebhookFormatJSON
+		}
+
+		sub.ID = uuid.New().String()
+		sub.OrgID = middleware.OrgID(c)
+
+		if err := webhookRepo.CreateWebhookSubscription(c.Request.Context(), &sub); err != nil {
+			respondInternalError(c, err)
+			return
+		}
+		audit.Log(c.Request.Context(), "Webhook subscribed", "org_id", sub.OrgID, "subscription_id", sub.ID,
+			"url", sub.URL, "event_types", sub.EventTypes)
+
+		c.JSON(http.StatusCreated, sub)
+	}
+}
+
+// GetWebhookSubscription gets a webhook subscription
+func GetWebhookSubscription(webhookRepo repository.WebhookRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !requireOrgWide(c, "webhook subscriptions") {
+			return
+		}
+
+		sub, err := webhookRepo.GetWebhookSubscription(c.Request.Context(), middleware.OrgID(c), c.Param("id"))
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Webhook subscription not found"})
+			return
+		}
+		if err != nil {
+			respondInternalError(c, err)
+			return
+		}
+
+		sub.Secret = ""
+		c.JSON(http.StatusOK, sub)
+	}
+}
+
+// UpdateWebhookSubscription updates a webhook subscription
+func UpdateWebhookSubscription(webhookRepo repository.WebhookRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !requireOrgWide(c, "webhook subscriptions") {
+			return
+		}
+
+		var sub models.WebhookSubscription
+		if err := c.ShouldBindJSON(&sub); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := validateSubscription(&sub); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		orgID, id := middleware.OrgID(c), c.Param("id")
+		rowsAffected, err := webhookRepo.UpdateWebhookSubscription(c.Request.Context(), orgID, id, &sub)
+		if err != nil {
+			respondInternalError(c, err)
+			return
+		}
+		if rowsAffected == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Webhook subscription not found"})
+			return
+		}
+		audit.Log(c.Request.Context(), "Webhook subscription updated", "org_id", orgID, "subscription_id", id,
+			"url", sub.URL, "event_types", sub.EventTypes, "secret_rotated", sub.Secret != "")
+
+		updated, err := webhookRepo.GetWebhookSubscription(c.Request.Context(), orgID, id)
+		if err != nil {
+			respondInternalError(c, err)
+			return
+		}
+		updated.Secret = ""
+		c.JSON(http.StatusOK, updated)
+	}
+}
+
+// DeleteWebhookSubscription deletes a webhook subscription
+func DeleteWebhookSubscription(webhookRepo repository.WebhookRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !requireOrgWide(c, "webhook subscriptions") {
+			return
+		}
+
+		orgID, id := middleware.OrgID(c), c.Param("id")
+		rowsAffected, err := webhookRepo.DeleteWebhookSubscription(c.Request.Context(), orgID, id)
+		if err != nil {
+			respondInternalError(c, err)
+			return
+		}
+		if rowsAffected == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Webhook subscription not found"})
+			return
+		}
+		audit.Log(c.Request.Context(), "Webhook unsubscribed", "org_id", orgID, "subscription_id", id)
+
+		c.JSON(http.StatusOK, gin.H{"message": "Webhook subscription deleted"})
+	}
+}
+
+// GetWebhookDeliveries lists a webhook subscription's deliveries
+func GetWebhookDeliveries(webhookRepo repository.WebhookRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !requireOrgWide(c, "webhook subscriptions") {
+			return
+		}
+
+		limit := defaultDeliveriesLimit
+		if raw := c.Query("limit"); raw != "" {
+			n, err := strconv.Atoi(raw)
+			if err != nil || n < 1 || n > maxDeliveriesLimit {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 200"})
+				return
+			}
+			limit = n
+		}
+
+		orgID, id := middleware.OrgID(c), c.Param("id")
+		if _, err := webhookRepo.GetWebhookSubscription(c.Request.Context(), orgID, id); err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Webhook subscription not found"})
+			return
+		} else if err != nil {
+			respondInternalError(c, err)
+			return
+		}
+
+		deliveries, err := webhookRepo.GetWebhookDeliveries(c.Request.Context(), orgID, id, limit)
+		if err != nil {
+			respondInternalError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": deliveries})
+	}
+}
+
+// TestWebhookSubscription sends a test delivery
+func TestWebhookSubscription(webhookRepo repository.WebhookRepository, sender WebhookSender) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !requireOrgWide(c, "webhook subscriptions") {
+			return
+		}
+
+		orgID := middleware.OrgID(c)
+		sub, err := webhookRepo.GetWebhookSubscription(c.Request.Context(), orgID, c.Param("id"))
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Webhook subscription not found"})
+			return
+		}
+		if err != nil {
+			respondInternalError(c, err)
+			return
+		}
+
+		now := time.Now().UTC().Truncate(time.Second)
+		event := models.Event{
+			ID:        uuid.New().String(),
+			OrgID:     orgID,
+			Type:      models.EventWebhookTest,
+			SubjectID: sub.ID,
+			Payload:   json.RawMessage(`{}`),
+			CreatedAt: now,
+		}
+		payload, err := json.Marshal(event)
+		if err != nil {
+			respondInternalError(c, err)
+			return
+		}
+		delivery := models.WebhookDelivery{
+			ID:             uuid.New().String(),
+			SubscriptionID: sub.ID,
+			EventID:        event.ID,
+			EventType:      event.Type,
+			Payload:        payload,
+			CreatedAt:      now,
+			Format:         sub.Format,
+			Template:       sub.Template,
+		}
+
+		sender.Attempt(c.Request.Context(), sub.URL, sub.Secret, &delivery)
+		if err := webhookRepo.CreateWebhookDelivery(c.Request.Context(), orgID, &delivery); err != nil {
+			respondInternalError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, delivery)
+	}
+}
@@ -0,0 +1,179 @@
+// Package kong is a client of the Kong Gateway Admin API, managing the Kong
+// services and routes the catalog's services are published as, and listing
+// those the catalog imports.
+package kong
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrNotFound is returned for requests answered with 404 Not Found
+var ErrNotFound = errors.New("not found")
+
+// pageSize is the number of entities asked for per page of a list
+const pageSize = 1000
+
+// Service is a Kong service, the upstream a route proxies to. Kong takes its
+// upstream as URL and lists it as Protocol, Host, Port and Path.
+type Service struct {
+	ID       string   `json:"id,omitempty"`
+	Name     string   `json:"name"`
+	URL      string   `json:"url,omitempty"`
+	Protocol string   `json:"protocol,omitempty"`
+	Host     string   `json:"host,omitempty"`
+	Port     int      `json:"port,omitempty"`
+	Path     string   `json:"path,omitempty"`
+	Tags     []string `json:"tags"`
+}
+
+// Route is a Kong route, matching requests for a service
+type Route struct {
+	ID      string     `json:"id,omitempty"`
+	Name    string     `json:"name"`
+	Paths   []string   `json:"paths"`
+	Service *Reference `json:"service"`
+	Tags    []string   `json:"tags"`
+}
+
+// Reference points to another Kong entity by ID
+type Reference struct {
+	ID string `json:"id"`
+}
+
+// Client calls the Admin API at one URL
+type Client struct {
+	url   string
+	token string
+	http  *http.Client
+}
+
+// New returns a client for the Admin API at baseURL, sending token as the
+// Kong-Admin-Token header when it is set. Each request is bounded by timeout.
+func New(baseURL, token string, timeout time.Duration) *Client {
+	return &Client{url: strings.TrimSuffix(baseURL, "/"), token: token, http: &http.Client{Timeout: timeout}}
+}
+
+// PutService creates or replaces the service with s.ID
+func (c *Client) PutService(ctx context.Context, s Service) error {
+	return c.do(ctx, http.MethodPut, "/services/"+url.PathEscape(s.ID), s, nil)
+}
+
+// PutRoute creates or replaces the route with r.ID
+func (c *Client) PutRoute(ctx context.Context, r Route) error {
+	return c.do(ctx, http.MethodPut, "/routes/"+url.PathEscape(r.ID), r, nil)
+}
+
+// DeleteService deletes a service, which must have no routes left. Deleting a
+// missing service succeeds.
+func (c *Client) DeleteService(ctx context.Context, id string) error {
+	return ignoreNotFound(c.do(ctx, http.MethodDelete, "/services/"+url.PathEscape(id), nil, nil))
+}
+
+// DeleteRoute deletes a route. Deleting a missing route succeeds.
+func (c *Client) DeleteRoute(ctx context.Context, id string) error {
+	return ignoreNotFound(c.do(ctx, http.MethodDelete, "/routes/"+url.PathEscape(id), nil, nil))
+}
+
+// ListServices lists every service
+func (c *Client) ListServices(ctx context.Context) ([]Service, error) {
+	return list[Service](ctx, c, "/services")
+}
+
+// ListRoutes lists every route
+func (c *Client) ListRoutes(ctx context.Context) ([]Route, error) {
+	return list[Route](ctx, c, "/routes")
+}
+
+// page is one page of a list, with the offset of the next page unless it is the last
+type page[T any] struct {
+	Data   []T    `json:"data"`
+	Offset string `json:"offset"`
+}
+
+// list follows the pages of the list at path until the last one
+func list[T any](ctx context.Context, c *Client, path string) ([]T, error) {
+	all := []T{}
+	query := url.Values{"size": {strconv.Itoa(pageSize)}}
+	for {
+		var p page[T]
+		if err := c.do(ctx, http.MethodGet, path+"?"+query.Encode(), nil, &p); err != nil {
+			return nil, err
+		}
+		all = append(all, p.Data...)
+		if p.Offset == "" {
+			return all, nil
+		}
+		query.Set("offset", p.Offset)
+	}
+}
+
+// ignoreNotFound returns nil for ErrNotFound, and err otherwise
+func ignoreNotFound(err error) error {
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+// do sends body, when not nil, as JSON to path and decodes the response into
+// out, when not nil. Responses other than 2xx are errors carrying Kong's message.
+func (c *Client) do(ctx context.Context, method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(encoded)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.url+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Kong-Admin-Token", c.token)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			slog.WarnContext(ctx, "Error closing Kong response", "error", err)
+		}
+	}()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("kong %s %s: %w", method, path, ErrNotFound)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		var kongErr struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(detail, &kongErr) == nil && kongErr.Message != "" {
+			detail = []byte(kongErr.Message)
+		}
+		return fmt.Errorf("kong %s %s: %s: %s", method, path, resp.Status, bytes.TrimSpace(detail))
+	}
+	if out != nil {
+		return json.NewDecoder(resp.Body).Decode(out)
+	}
+	return nil
+}
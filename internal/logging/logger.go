@@ -0,0 +1,39 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// Supported LOG_FORMAT values
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+// ParseLevel parses a LOG_LEVEL of debug, info, warn or error, ignoring case
+func ParseLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(strings.TrimSpace(s))); err != nil {
+		return slog.LevelInfo, fmt.Errorf("invalid log level %q: must be debug, info, warn or error", s)
+	}
+	return level, nil
+}
+
+// New returns a logger writing records at level and above to out, redacted, as
+// one JSON object per line for FormatJSON or as key=value pairs for FormatText.
+// Each record carries its time, level and message, and the request ID of the
+// context it is logged with.
+func New(out io.Writer, level slog.Leveler, format string) (*slog.Logger, error) {
+	opts := &slog.HandlerOptions{Level: level}
+	switch format {
+	case FormatJSON:
+		return slog.New(contextHandler{slog.NewJSONHandler(NewWriter(out), opts)}), nil
+	case FormatText:
+		return slog.New(contextHandler{slog.NewTextHandler(NewWriter(out), opts)}), nil
+	default:
+		return nil, fmt.Errorf("invalid log format %q: must be json or text", format)
+	}
+}
@@ -0,0 +1,134 @@
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	// AuthFailures counts rejected credentials by endpoint and reason
+	AuthFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "auth_failures_total",
+		Help: "Failed authentication attempts by endpoint and reason.",
+	}, []string{"endpoint", "reason"})
+
+	// AuthLockouts counts lockouts started, by whether the client IP or the principal was locked
+	AuthLockouts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "auth_lockouts_total",
+		Help: "Brute-force lockouts started, by scope.",
+	}, []string{"scope"})
+)
+
+// DBRetries counts database statements and transactions rerun after a transient error
+var DBRetries = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "db_retries_total",
+	Help: "Database statements and transactions retried after a deadlock, lock timeout or dropped connection.",
+})
+
+// DBCircuitOpen is 1 while the database circuit breaker is rejecting queries
+var DBCircuitOpen = promauto.NewGauge(prometheus.GaugeOpts{
+	Name: "db_circuit_open",
+	Help: "Whether the database circuit breaker is open (1) or closed (0).",
+})
+
+// DBUp is 1 while the primary database answers health checks
+var DBUp = promauto.NewGauge(prometheus.GaugeOpts{
+	Name: "db_up",
+	Help: "Whether the primary database answered its last health check (1) or not (0).",
+})
+
+// OutboxDeliveries counts outbox event deliveries by result: delivered or failed
+var OutboxDeliveries = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "outbox_deliveries_total",
+	Help: "Outbox event deliveries, by result.",
+}, []string{"result"})
+
+// WebhookDeliveries counts attempts to deliver events to webhook subscriptions, by result: delivered or failed
+var WebhookDeliveries = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "webhook_deliveries_total",
+	Help: "Attempts to deliver events to webhook subscriptions, by result.",
+}, []string{"result"})
+
+// KongSyncs counts attempts to sync services to Kong, by result: synced or failed
+var KongSyncs = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "kong_syncs_total",
+	Help: "Attempts to sync services to Kong, by result.",
+}, []string{"result"})
+
+// EmailNotifications counts attempts to send email notifications, by result: sent or failed
+var EmailNotifications = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "email_notifications_total",
+	Help: "Attempts to send email notifications, by result.",
+}, []string{"result"})
+
+// SearchIndexUpdates counts updates of the external search index after writes, by result: ok or error
+var SearchIndexUpdates = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "search_index_updates_total",
+	Help: "External search index updates after writes, by result.",
+}, []string{"result"})
+
+// SearchAnalyticsRecords counts sampled searches recorded for search analytics, by result: ok or error
+var SearchAnalyticsRecords = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "search_analytics_records_total",
+	Help: "Sampled searches recorded for search analytics, by result.",
+}, []string{"result"})
+
+// SearchCacheRequests counts searches looked up in the search cache, by result: hit or miss
+var SearchCacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "search_cache_requests_total",
+	Help: "Searches looked up in the search cache, by result.",
+}, []string{"result"})
+
+// Panics counts requests whose handlers panicked, by route
+var Panics = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "http_panics_total",
+	Help: "Requests whose handlers panicked, by route.",
+}, []string{"route"})
+
+// RequestsShed counts requests rejected because too many were being handled, by the limit reached
+var RequestsShed = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "http_requests_shed_total",
+	Help: "Requests rejected because too many were being handled, by the limit reached.",
+}, []string{"limit"})
+
+// RepositoryCallDuration times repository calls, by method
+var RepositoryCallDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "repository_call_duration_seconds",
+	Help:    "Time taken by repository calls, by method.",
+	Buckets: prometheus.DefBuckets,
+}, []string{"method"})
+
+// RepositoryErrors counts failed repository calls, by method
+var RepositoryErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "repository_errors_total",
+	Help: "Repository calls that failed, by method.",
+}, []string{"method"})
+
+// RegisterDBStats publishes the connection pool statistics of db, such as open
+// and in-use connections and the time spent waiting for one, as go_sql_* metrics
+// labelled with db_name=name
+func RegisterDBStats(name string, db *sql.DB) {
+	prometheus.MustRegister(collectors.NewDBStatsCollector(db, name))
+}
+
+// Handler serves the registered metrics in the Prometheus exposition format
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// AuditExports counts batches of audit entries sent to the SIEM sink, by result: delivered or failed
+var AuditExports = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "audit_exports_total",
+	Help: "Batches of audit entries sent to the SIEM sink, by result.",
+}, []string{"result"})
+
+// AuditEntriesDropped counts audit entries not exported because the export queue stayed full
+var AuditEntriesDropped = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "audit_entries_dropped_total",
+	Help: "Audit entries dropped because the export queue stayed full.",
+})
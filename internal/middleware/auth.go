@@ -0,0 +1,143 @@
+package middleware
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yashjain/konnect/internal/auth"
+	"github.com/yashjain/konnect/internal/config"
+	"github.com/yashjain/konnect/internal/metrics"
+	"github.com/yashjain/konnect/internal/repository"
+)
+
+// principalKey is the gin context key holding the authenticated auth.Principal
+const principalKey = "principal"
+
+// Auth resolves the bearer token to its principal and rejects unauthenticated requests.
+// Signed access tokens from /auth/login are accepted alongside opaque API tokens.
+// Invalid tokens count against the client IP in lockout.
+func Auth(cfg config.AuthConfig, orgRepo repository.OrganizationRepository, lockout *auth.Lockout) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := bearerToken(c)
+		if token == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "missing bearer token", "code": "missing_token"})
+			return
+		}
+
+		ipKey := auth.IPKey(c.ClientIP())
+		if wait := lockout.Locked(ipKey, time.Now()); wait > 0 {
+			metrics.AuthFailures.WithLabelValues("api", "locked_out").Inc()
+			abortLockedOut(c, wait)
+			return
+		}
+
+		if cfg.SigningKey != "" && auth.LooksLikeAccessToken(token) {
+			principal, err := accessTokenPrincipal(c.Request.Context(), cfg, orgRepo, token)
+			if err == auth.ErrInvalidAccessToken {
+				recordFailure(lockout, ipKey)
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error(), "code": "invalid_token"})
+				return
+			}
+			if err != nil {
+				abortInternalError(c, err)
+				return
+			}
+			SetPrincipal(c, principal)
+			c.Next()
+			return
+		}
+
+		principal, err := orgRepo.GetPrincipalByTokenHash(c.Request.Context(), auth.HashToken(token))
+		if err == sql.ErrNoRows {
+			recordFailure(lockout, ipKey)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid bearer token", "code": "invalid_token"})
+			return
+		}
+		if err != nil {
+			abortInternalError(c, err)
+			return
+		}
+
+		SetPrincipal(c, principal)
+		c.Next()
+	}
+}
+
+// recordFailure counts a rejected API token against the client IP
+func recordFailure(lockout *auth.Lockout, ipKey string) {
+	metrics.AuthFailures.WithLabelValues("api", "invalid_token").Inc()
+	if lockout.Fail(ipKey, time.Now()) > 0 {
+		metrics.AuthLockouts.WithLabelValues("ip").Inc()
+	}
+}
+
+// abortLockedOut rejects a request from a locked out client
+func abortLockedOut(c *gin.Context, wait time.Duration) {
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "too many failed attempts; try again later", "code": "locked_out"})
+}
+
+// accessTokenPrincipal verifies a signed access token and loads the user's teams
+func accessTokenPrincipal(ctx context.Context, cfg config.AuthConfig, orgRepo repository.OrganizationRepository, token string) (auth.Principal, error) {
+	claims, err := auth.ParseAccessToken([]byte(cfg.SigningKey), token)
+	if err != nil {
+		return auth.Principal{}, err
+	}
+
+	teamIDs, err := orgRepo.GetTeamIDsForUser(ctx, claims.Subject)
+	if err != nil {
+		return auth.Principal{}, err
+	}
+
+	return auth.Principal{OrgID: claims.OrgID, UserID: claims.Subject, TeamIDs: teamIDs}, nil
+}
+
+// AdminAuth only admits requests bearing the configured admin token
+func AdminAuth(adminToken string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := bearerToken(c)
+		if token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid admin token", "code": "invalid_token"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// SetPrincipal stores the principal a request is made by
+func SetPrincipal(c *gin.Context, principal auth.Principal) {
+	c.Set(principalKey, principal)
+}
+
+// Principal returns the principal a request is made by. Requests that did not
+// pass through Auth get a zero principal, which matches no organization.
+func Principal(c *gin.Context) auth.Principal {
+	if v, ok := c.Get(principalKey); ok {
+		if p, ok := v.(auth.Principal); ok {
+			return p
+		}
+	}
+	return auth.Principal{}
+}
+
+// OrgID returns the organization a request is scoped to
+func OrgID(c *gin.Context) string {
+	return Principal(c).OrgID
+}
+
+// bearerToken extracts the token from an "Authorization: Bearer <token>" header
+func bearerToken(c *gin.Context) string {
+	header := c.GetHeader("Authorization")
+	const prefix = "Bearer "
+	if len(header) < len(prefix) || !strings.EqualFold(header[:len(prefix)], prefix) {
+		return ""
+	}
+	return strings.TrimSpace(header[len(prefix):])
+}
@@ -0,0 +1,177 @@
+package middleware
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yashjain/konnect/internal/logging"
+)
+
+// bodyTooLarge replaces captured bodies longer than the capture limit
+const bodyTooLarge = "[TOO LARGE]"
+
+// CaptureRule enables body capture for the requests to Route, as "METHOD /route",
+// or with the request ID RequestID, until Expires
+type CaptureRule struct {
+	Route     string    `json:"route,omitempty"`
+	RequestID string    `json:"request_id,omitempty"`
+	Expires   time.Time `json:"expires"`
+}
+
+// BodyCapture logs the bodies of the requests matching its rules and of their
+// responses, for debugging. Rules expire on their own, so capture cannot be
+// left on by mistake.
+type BodyCapture struct {
+	maxBytes int
+	maxTTL   time.Duration
+
+	mu    sync.Mutex
+	rules []CaptureRule
+}
+
+// NewBodyCapture returns a capture without rules, logging bodies of up to
+// maxBytes and accepting rules lasting up to maxTTL
+func NewBodyCapture(maxBytes int, maxTTL time.Duration) *BodyCapture {
+	return &BodyCapture{maxBytes: maxBytes, maxTTL: maxTTL}
+}
+
+// Enable adds a rule capturing the requests to route or with requestID, one of
+// which must be set, for ttl
+func (b *BodyCapture) Enable(route, requestID string, ttl time.Duration) (CaptureRule, error) {
+	if (route == "") == (requestID == "") {
+		return CaptureRule{}, errors.New("one of route and request_id is required")
+	}
+	if ttl <= 0 || ttl > b.maxTTL {
+		return CaptureRule{}, fmt.Errorf("ttl must be positive and at most %s", b.maxTTL)
+	}
+
+	rule := CaptureRule{Route: route, RequestID: requestID, Expires: time.Now().Add(ttl).UTC()}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.rules = append(b.active(time.Now()), rule)
+	return rule, nil
+}
+
+// Rules returns the rules that have not expired
+func (b *BodyCapture) Rules() []CaptureRule {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.rules = b.active(time.Now())
+	return append([]CaptureRule{}, b.rules...)
+}
+
+// Disable removes every rule
+func (b *BodyCapture) Disable() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.rules = nil
+}
+
+// active returns the rules that have not expired at now; b.mu must be held
+func (b *BodyCapture) active(now time.Time) []CaptureRule {
+	var rules []CaptureRule
+	for _, rule := range b.rules {
+		if now.Before(rule.Expires) {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// matches reports whether an active rule covers the request to route with requestID
+func (b *BodyCapture) matches(route, requestID string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.rules) == 0 {
+		return false
+	}
+	b.rules = b.active(time.Now())
+	for _, rule := range b.rules {
+		if (rule.Route != "" && rule.Route == route) || (rule.RequestID != "" && rule.RequestID == requestID) {
+			return true
+		}
+	}
+	return false
+}
+
+// Handler returns the middleware logging the bodies of matching requests and
+// their responses as a "Request captured" record. Bodies are redacted like
+// request logs, and replaced with [TOO LARGE] beyond the capture limit. It
+// must come after RequestID for rules matching request IDs.
+func (b *BodyCapture) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := c.Request.Method + " " + c.FullPath()
+		if !b.matches(route, GetRequestID(c)) {
+			c.Next()
+			return
+		}
+
+		// Read up to one byte past the limit, then hand the handler the whole body
+		var request []byte
+		if c.Request.Body != nil {
+			request, _ = io.ReadAll(io.LimitReader(c.Request.Body, int64(b.maxBytes)+1))
+			c.Request.Body = struct {
+				io.Reader
+				io.Closer
+			}{io.MultiReader(bytes.NewReader(request), c.Request.Body), c.Request.Body}
+		}
+		writer := &captureWriter{ResponseWriter: c.Writer, max: b.maxBytes}
+		c.Writer = writer
+		c.Next()
+
+		slog.InfoContext(c.Request.Context(), "Request captured",
+			"method", c.Request.Method,
+			"route", c.FullPath(),
+			"status", c.Writer.Status(),
+			"request_body", b.loggedBody(request),
+			"response_body", b.loggedBody(writer.body.Bytes()),
+		)
+	}
+}
+
+// loggedBody redacts a captured body for logging
+func (b *BodyCapture) loggedBody(body []byte) string {
+	switch {
+	case len(body) == 0:
+		return ""
+	case len(body) > b.maxBytes:
+		return bodyTooLarge
+	default:
+		return logging.RedactJSON(body)
+	}
+}
+
+// captureWriter keeps a copy of up to one byte past max of the response body
+type captureWriter struct {
+	gin.ResponseWriter
+	max  int
+	body bytes.Buffer
+}
+
+// Write implements http.ResponseWriter
+func (w *captureWriter) Write(b []byte) (int, error) {
+	w.keep(b)
+	return w.ResponseWriter.Write(b)
+}
+
+// WriteString implements io.StringWriter
+func (w *captureWriter) WriteString(s string) (int, error) {
+	w.keep([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+// keep copies the part of b that fits in the buffer
+func (w *captureWriter) keep(b []byte) {
+	if room := w.max + 1 - w.body.Len(); room > 0 {
+		if len(b) > room {
+			b = b[:room]
+		}
+		w.body.Write(b)
+	}
+}
@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/yashjain/konnect/internal/audit"
+)
+
+// ClientIdentity describes the subject of a verified TLS client certificate
+type ClientIdentity = audit.ClientCertificate
+
+// ClientCert exposes the verified client certificate identity, if any, to later
+// handlers through GetClientIdentity, and records it on the request context so
+// that audit entries of the request name the client
+func ClientCert() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		state := c.Request.TLS
+		if state != nil && len(state.VerifiedChains) > 0 && len(state.VerifiedChains[0]) > 0 {
+			cert := state.VerifiedChains[0][0]
+			identity := ClientIdentity{
+				CommonName:   cert.Subject.CommonName,
+				Organization: cert.Subject.Organization,
+				DNSNames:     cert.DNSNames,
+			}
+			for _, u := range cert.URIs {
+				identity.URIs = append(identity.URIs, u.String())
+			}
+			c.Request = c.Request.WithContext(audit.WithClientCertificate(c.Request.Context(), identity))
+		}
+		c.Next()
+	}
+}
+
+// GetClientIdentity returns the verified client certificate identity and whether one was presented
+func GetClientIdentity(c *gin.Context) (ClientIdentity, bool) {
+	return audit.ClientCertificateFrom(c.Request.Context())
+}
@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yashjain/konnect/internal/repository"
+)
+
+// statusClientClosedRequest is reported when the client disconnects before a response is written
+const statusClientClosedRequest = 499
+
+// abortInternalError logs an unexpected error and aborts with a generic 500,
+// a 504 when a query ran past its timeout, or a 503 while the database is down
+func abortInternalError(c *gin.Context, err error) {
+	var unavailable *repository.UnavailableError
+	switch {
+	case errors.As(err, &unavailable):
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(unavailable.RetryAfter.Seconds()))))
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "service temporarily unavailable", "code": "unavailable"})
+	case errors.Is(err, context.Canceled) && c.Request.Context().Err() != nil:
+		c.AbortWithStatus(statusClientClosedRequest)
+	case errors.Is(err, context.DeadlineExceeded):
+		slog.WarnContext(c.Request.Context(), "Request timed out", "method", c.Request.Method, "route", c.FullPath(), "error", err)
+		c.AbortWithStatusJSON(http.StatusGatewayTimeout, gin.H{"error": "request timed out", "code": "timeout"})
+	default:
+		slog.ErrorContext(c.Request.Context(), "Request failed", "method", c.Request.Method, "route", c.FullPath(), "error", err)
+		ReportError(c, err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "internal server error", "code": "internal_error"})
+	}
+}
@@ -0,0 +1,91 @@
+package middleware
+
+import (
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MediaTypeJSONAPI is the media type of JSON:API documents
+const MediaTypeJSONAPI = "application/vnd.api+json"
+
+// jsonAPIKey marks requests that negotiated JSON:API responses
+const jsonAPIKey = "jsonapi"
+
+// errUnsupportedJSONAPI explains the 406 and 415 responses to JSON:API media types with parameters
+const errUnsupportedJSONAPI = "only the " + MediaTypeJSONAPI + " media type without parameters is supported"
+
+// JSONAPI negotiates JSON:API responses. Requests whose Accept header lists
+// application/vnd.api+json are marked for handlers to answer with JSON:API
+// documents (see WantsJSONAPI), and their error responses are rewritten as
+// JSON:API error objects. As the specification requires, requests accepting the
+// media type only with parameters other than profile get a 406, and requests
+// sending a body of the media type with such parameters a 415.
+func JSONAPI() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Vary", "Accept")
+
+		want, ok := acceptsJSONAPI(c.GetHeader("Accept"))
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusNotAcceptable, gin.H{"error": errUnsupportedJSONAPI})
+			return
+		}
+		if mediaType, params, err := mime.ParseMediaType(c.GetHeader("Content-Type")); err == nil && mediaType == MediaTypeJSONAPI {
+			delete(params, "profile")
+			if len(params) > 0 {
+				c.AbortWithStatusJSON(http.StatusUnsupportedMediaType, gin.H{"error": errUnsupportedJSONAPI})
+				return
+			}
+		}
+		if !want {
+			c.Next()
+			return
+		}
+
+		c.Set(jsonAPIKey, true)
+		w := &errorWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+		defer func() {
+			// Put the writer back first, so a panic is still answered by Recover
+			c.Writer = w.ResponseWriter
+			w.rewrite(func(status int, e ErrorBody) (interface{}, string) {
+				return gin.H{"errors": []gin.H{{
+					"status": strconv.Itoa(status),
+					"code":   errorCode(status, e),
+					"title":  http.StatusText(status),
+					"detail": e.Error,
+				}}}, MediaTypeJSONAPI
+			})
+		}()
+		c.Next()
+	}
+}
+
+// WantsJSONAPI reports whether a request negotiated JSON:API responses
+func WantsJSONAPI(c *gin.Context) bool {
+	return c.GetBool(jsonAPIKey)
+}
+
+// acceptsJSONAPI reports whether an Accept header lists the JSON:API media type,
+// and false for ok when it only lists it with unsupported parameters
+func acceptsJSONAPI(accept string) (want, ok bool) {
+	listed := false
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil || mediaType != MediaTypeJSONAPI {
+			continue
+		}
+		listed = true
+
+		// q weighs the media type rather than modifying it, and profiles may be ignored
+		delete(params, "q")
+		delete(params, "profile")
+		if len(params) == 0 {
+			return true, true
+		}
+	}
+	return false, !listed
+}
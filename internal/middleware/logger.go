@@ -0,0 +1,129 @@
+package middleware
+
+import (
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yashjain/konnect/internal/config"
+	"github.com/yashjain/konnect/internal/logging"
+)
+
+// AccessLog logs one record per request once it is handled, with its route,
+// status, latency and response size, the principal it was made by and the
+// request ID set by RequestID. Server errors are logged as errors and client
+// errors as warnings. Paths are logged without their query, which may carry
+// credentials.
+//
+// Requests to excluded paths are only logged when they fail with a server
+// error, and successful requests to sampled routes only at the sample rate,
+// unless they are slow. Sampled records carry the sample rate, so counts can
+// be scaled back up.
+// The endpoint is attached to the request context, so records logged while
+// handling it, such as slow queries, can name it.
+func AccessLog(cfg config.AccessLogConfig) gin.HandlerFunc {
+	return NewAccessLogger(cfg).Handler()
+}
+
+// AccessLogger logs requests like AccessLog, with exclusions and sampling that
+// can be updated while serving
+type AccessLogger struct {
+	rules atomic.Pointer[accessLogRules]
+}
+
+// accessLogRules is the access log configuration, indexed for lookups
+type accessLogRules struct {
+	exclude       map[string]bool
+	sampled       map[string]bool
+	sampleAll     bool
+	sampleRate    float64
+	slowThreshold time.Duration
+}
+
+// sampledRoute reports whether requests to route are sampled
+func (r *accessLogRules) sampledRoute(route string) bool {
+	return r.sampleAll || r.sampled[route]
+}
+
+// slow reports whether a request that took latency is always logged
+func (r *accessLogRules) slow(latency time.Duration) bool {
+	return r.slowThreshold > 0 && latency >= r.slowThreshold
+}
+
+// NewAccessLogger returns an access logger configured with cfg
+func NewAccessLogger(cfg config.AccessLogConfig) *AccessLogger {
+	l := &AccessLogger{}
+	l.Update(cfg)
+	return l
+}
+
+// Update replaces the logger's configuration, for the requests handled from now on
+func (l *AccessLogger) Update(cfg config.AccessLogConfig) {
+	rules := &accessLogRules{
+		exclude:       make(map[string]bool, len(cfg.Exclude)),
+		sampled:       make(map[string]bool, len(cfg.SampleRoutes)),
+		sampleRate:    cfg.SampleRate,
+		slowThreshold: cfg.SlowThreshold,
+	}
+	for _, path := range cfg.Exclude {
+		rules.exclude[path] = true
+	}
+	for _, route := range cfg.SampleRoutes {
+		if route == "*" {
+			rules.sampleAll = true
+		}
+		rules.sampled[route] = true
+	}
+	l.rules.Store(rules)
+}
+
+// Handler returns the middleware logging requests
+func (l *AccessLogger) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Request = c.Request.WithContext(logging.WithEndpoint(c.Request.Context(), c.Request.Method+" "+c.FullPath()))
+		c.Next()
+
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		switch {
+		case status >= http.StatusInternalServerError:
+			level = slog.LevelError
+		case status >= http.StatusBadRequest:
+			level = slog.LevelWarn
+		}
+
+		latency := time.Since(start)
+		rules := l.rules.Load()
+		if level < slog.LevelError && rules.exclude[c.Request.URL.Path] {
+			return
+		}
+		sampled := level < slog.LevelWarn && !rules.slow(latency) && rules.sampledRoute(c.Request.Method+" "+c.FullPath())
+		if sampled && rand.Float64() >= rules.sampleRate {
+			return
+		}
+
+		attrs := []slog.Attr{
+			slog.String("method", c.Request.Method),
+			slog.String("route", c.FullPath()),
+			slog.String("path", c.Request.URL.Path),
+			slog.Int("status", status),
+			slog.Float64("latency_ms", float64(latency.Microseconds())/1000),
+			slog.Int("bytes", c.Writer.Size()),
+			slog.String("client_ip", c.ClientIP()),
+		}
+		if sampled {
+			attrs = append(attrs, slog.Float64("sample_rate", rules.sampleRate))
+		}
+		if p := Principal(c); p.OrgID != "" {
+			attrs = append(attrs, slog.String("org_id", p.OrgID))
+			if p.UserID != "" {
+				attrs = append(attrs, slog.String("user_id", p.UserID))
+			}
+		}
+		slog.LogAttrs(c.Request.Context(), level, "request", attrs...)
+	}
+}
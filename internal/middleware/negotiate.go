@@ -0,0 +1,152 @@
+package middleware
+
+import (
+	"bytes"
+	"log/slog"
+	"mime"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yashjain/konnect/internal/render"
+)
+
+// Negotiate serves the JSON documents handlers respond with in the media type
+// the Accept header prefers among JSON and those of renderers, by highest q and
+// then the order listed; JSON is served when it is preferred, when no supported
+// media type is listed and to requests without Accept. Only JSON and problem
+// details bodies are rendered, so streamed downloads and other formats are
+// written as they are.
+func Negotiate(renderers ...render.Renderer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Vary", "Accept")
+
+		r := preferredRenderer(c.GetHeader("Accept"), renderers)
+		if r == nil {
+			c.Next()
+			return
+		}
+
+		w := &renderWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+		defer func() {
+			// Put the writer back first, so a panic is still answered by Recover
+			c.Writer = w.ResponseWriter
+			w.render(c, r)
+		}()
+		c.Next()
+	}
+}
+
+// preferredRenderer returns the renderer of the media type an Accept header
+// prefers, or nil when it prefers JSON or lists none of the renderers'
+func preferredRenderer(accept string, renderers []render.Renderer) render.Renderer {
+	var best render.Renderer
+	bestQ := 0.0
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		if q <= bestQ {
+			continue
+		}
+
+		switch {
+		case mediaType == "*/*" || mediaType == "application/*" || isJSONType(mediaType):
+			best, bestQ = nil, q
+		default:
+			for _, r := range renderers {
+				for _, t := range r.MediaTypes() {
+					if mediaType == t {
+						best, bestQ = r, q
+					}
+				}
+			}
+		}
+	}
+	return best
+}
+
+// isJSONType reports whether a media type is JSON or a JSON-based type such as application/problem+json
+func isJSONType(mediaType string) bool {
+	return mediaType == "application/json" || strings.HasPrefix(mediaType, "application/") && strings.HasSuffix(mediaType, "+json")
+}
+
+// renderWriter holds back JSON bodies, so that render can write them in another media type
+type renderWriter struct {
+	gin.ResponseWriter
+	body *bytes.Buffer
+
+	// passthrough is set once a body that is not JSON is written
+	passthrough bool
+}
+
+// Write implements http.ResponseWriter
+func (w *renderWriter) Write(data []byte) (int, error) {
+	if w.body == nil && !w.passthrough {
+		switch mediaTypeOf(w.Header().Get("Content-Type")) {
+		case "application/json", MediaTypeProblem:
+			w.body = &bytes.Buffer{}
+		default:
+			w.passthrough = true
+		}
+	}
+	if w.passthrough {
+		return w.ResponseWriter.Write(data)
+	}
+	return w.body.Write(data)
+}
+
+// WriteString implements io.StringWriter
+func (w *renderWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Written implements gin.ResponseWriter, counting a held back body as written
+func (w *renderWriter) Written() bool {
+	return w.body != nil || w.ResponseWriter.Written()
+}
+
+// Flush implements http.Flusher; held back bodies are only written once rendered
+func (w *renderWriter) Flush() {
+	if w.body == nil {
+		w.ResponseWriter.Flush()
+	}
+}
+
+// render writes a held back body in r's media type. Bodies that fail to render
+// are logged and written as the JSON they were.
+func (w *renderWriter) render(c *gin.Context, r render.Renderer) {
+	if w.body == nil {
+		return
+	}
+
+	jsonType := mediaTypeOf(w.Header().Get("Content-Type"))
+	contentType := r.ContentType(jsonType)
+	var out bytes.Buffer
+	doc, err := render.Decode(w.body.Bytes())
+	if err == nil {
+		err = r.Render(&out, contentType, doc)
+	}
+	if err != nil {
+		slog.WarnContext(c.Request.Context(), "Response not rendered", "method", c.Request.Method, "route", c.FullPath(), "content_type", contentType, "error", err)
+		_, _ = w.ResponseWriter.Write(w.body.Bytes())
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	_, _ = w.ResponseWriter.Write(out.Bytes())
+}
+
+// mediaTypeOf returns the media type of a Content-Type header, without its parameters
+func mediaTypeOf(contentType string) string {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	return mediaType
+}
@@ -0,0 +1,129 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MediaTypeProblem is the media type of RFC 7807 problem details
+const MediaTypeProblem = "application/problem+json"
+
+// Problem is an RFC 7807 problem details object, extended with a
+// machine-readable code and the ID of the request
+type Problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	Instance  string `json:"instance"`
+	Code      string `json:"code"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// Problems rewrites JSON error responses as RFC 7807 problem details. Handlers
+// answer errors with {"error": "...", "code": "..."} bodies, whose message
+// becomes the detail and whose optional code is kept for clients to branch on;
+// errors without a code get one derived from their status, such as not_found.
+// Problems have no type of their own, so their title is the status text, and
+// their instance is the request path.
+func Problems() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		w := &errorWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+		defer func() {
+			// Put the writer back first, so a panic is still answered by Recover
+			c.Writer = w.ResponseWriter
+			w.rewrite(func(status int, e ErrorBody) (interface{}, string) {
+				return Problem{
+					Type:      "about:blank",
+					Title:     http.StatusText(status),
+					Status:    status,
+					Detail:    e.Error,
+					Instance:  c.Request.URL.Path,
+					Code:      errorCode(status, e),
+					RequestID: GetRequestID(c),
+				}, MediaTypeProblem
+			})
+		}()
+		c.Next()
+	}
+}
+
+// ErrorBody is the {"error": "...", "code": "..."} body of an error response,
+// which Problems rewrites as problem details
+type ErrorBody struct {
+	Error string `json:"error"`
+	Code  string `json:"code"`
+}
+
+// errorCode is the code of an error, derived from its status when it has none
+func errorCode(status int, e ErrorBody) string {
+	if e.Code != "" {
+		return e.Code
+	}
+	if status == http.StatusInternalServerError {
+		return "internal_error"
+	}
+	text := http.StatusText(status)
+	if text == "" {
+		return "error"
+	}
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' {
+			return r
+		}
+		return '_'
+	}, strings.ToLower(text))
+}
+
+// errorWriter holds back the body of error responses, so that rewrite can
+// render it in another format once the handlers are done
+type errorWriter struct {
+	gin.ResponseWriter
+	body *bytes.Buffer
+}
+
+// Write implements http.ResponseWriter
+func (w *errorWriter) Write(data []byte) (int, error) {
+	if w.Status() < http.StatusBadRequest {
+		return w.ResponseWriter.Write(data)
+	}
+	if w.body == nil {
+		w.body = &bytes.Buffer{}
+	}
+	return w.body.Write(data)
+}
+
+// WriteString implements io.StringWriter
+func (w *errorWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Written implements gin.ResponseWriter, counting a held back body as written
+func (w *errorWriter) Written() bool {
+	return w.body != nil || w.ResponseWriter.Written()
+}
+
+// rewrite writes a held back JSON error body as the document render returns
+// for it, with the content type it returns, and any other body as it was
+func (w *errorWriter) rewrite(render func(status int, e ErrorBody) (interface{}, string)) {
+	if w.body == nil {
+		return
+	}
+
+	var e ErrorBody
+	if strings.HasPrefix(w.Header().Get("Content-Type"), "application/json") &&
+		json.Unmarshal(w.body.Bytes(), &e) == nil && e.Error != "" {
+		doc, contentType := render(w.Status(), e)
+		if body, err := json.Marshal(doc); err == nil {
+			w.Header().Set("Content-Type", contentType)
+			_, _ = w.ResponseWriter.Write(body)
+			return
+		}
+	}
+	_, _ = w.ResponseWriter.Write(w.body.Bytes())
+}
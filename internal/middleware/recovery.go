@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime"
+	"syscall"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yashjain/konnect/internal/metrics"
+)
+
+// stackFrame is one call of a logged stack trace
+type stackFrame struct {
+	Function string `json:"function"`
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+}
+
+// Recover answers requests whose handlers panic with a 500 carrying the usual
+// internal server error body, an internal_error code and the request ID. Each
+// panic is logged with its stack trace, innermost call first, and counted by
+// the http_panics_total metric.
+func Recover() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			r := recover()
+			if r == nil {
+				return
+			}
+			// http.ErrAbortHandler aborts a response on purpose
+			if r == http.ErrAbortHandler {
+				panic(r)
+			}
+
+			ctx := c.Request.Context()
+			if err, ok := r.(error); ok && (errors.Is(err, syscall.EPIPE) || errors.Is(err, syscall.ECONNRESET)) {
+				// The client is gone, so there is no one to answer
+				slog.WarnContext(ctx, "Connection closed while responding", "method", c.Request.Method, "route", c.FullPath(), "error", err)
+				c.Abort()
+				return
+			}
+
+			metrics.Panics.WithLabelValues(c.FullPath()).Inc()
+			// Skip runtime.Callers, this function and runtime.gopanic to start at the panic
+			stack := callers(3)
+			slog.ErrorContext(ctx, "Panic recovered", "method", c.Request.Method, "route", c.FullPath(), "panic", fmt.Sprint(r), "stack", stackFrames(stack))
+			if c.Writer.Written() {
+				c.Abort()
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "internal server error", "code": "internal_error"})
+		}()
+		c.Next()
+	}
+}
+
+// stackFrames resolves program counters returned by runtime.Callers into a stack trace
+func stackFrames(stack []uintptr) []stackFrame {
+	var out []stackFrame
+	frames := runtime.CallersFrames(stack)
+	for {
+		f, more := frames.Next()
+		if f.Function != "" {
+			out = append(out, stackFrame{Function: f.Function, File: f.File, Line: f.Line})
+		}
+		if !more {
+			return out
+		}
+	}
+}
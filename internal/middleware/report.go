@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"runtime"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yashjain/konnect/internal/sentry"
+)
+
+// errorReporterKey is the gin context key holding the ErrorReporter set by ReportErrors
+const errorReporterKey = "error_reporter"
+
+// maxStackDepth bounds the frames reported with an error
+const maxStackDepth = 64
+
+// ErrorReporter receives unexpected errors with the call stack and request they happened in
+type ErrorReporter interface {
+	Capture(err error, stack []uintptr, req sentry.Request)
+}
+
+// ReportErrors reports the panics of later handlers, and the errors they pass
+// to ReportError, to reporter. Panics are raised again for recovery middleware
+// to answer, so ReportErrors must come after it.
+func ReportErrors(reporter ErrorReporter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(errorReporterKey, reporter)
+		defer func() {
+			r := recover()
+			if r == nil {
+				return
+			}
+			// http.ErrAbortHandler aborts a response on purpose
+			if r != http.ErrAbortHandler {
+				err, ok := r.(error)
+				if !ok {
+					err = fmt.Errorf("%v", r)
+				}
+				// Skip runtime.Callers, this function and runtime.gopanic to start at the panic
+				reporter.Capture(fmt.Errorf("panic: %w", err), callers(3), reportedRequest(c))
+			}
+			panic(r)
+		}()
+		c.Next()
+	}
+}
+
+// ReportError reports an unexpected error raised while handling c, with the
+// caller's stack, when ReportErrors is in use
+func ReportError(c *gin.Context, err error) {
+	v, ok := c.Get(errorReporterKey)
+	if !ok {
+		return
+	}
+	if reporter, ok := v.(ErrorReporter); ok {
+		// Skip runtime.Callers and this function to start at the caller
+		reporter.Capture(err, callers(2), reportedRequest(c))
+	}
+}
+
+// callers returns the program counters of the calling goroutine's stack, skipping skip frames
+func callers(skip int) []uintptr {
+	stack := make([]uintptr, maxStackDepth)
+	return stack[:runtime.Callers(skip+1, stack)]
+}
+
+// reportedRequest describes c for an error report
+func reportedRequest(c *gin.Context) sentry.Request {
+	p := Principal(c)
+	return sentry.Request{
+		Method:    c.Request.Method,
+		Path:      c.Request.URL.Path,
+		Route:     c.FullPath(),
+		Headers:   c.Request.Header,
+		RequestID: GetRequestID(c),
+		OrgID:     p.OrgID,
+		UserID:    p.UserID,
+	}
+}
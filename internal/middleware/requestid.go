@@ -0,0 +1,87 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/yashjain/konnect/internal/logging"
+)
+
+// RequestIDHeader carries the ID correlating a request across services, logs and support tickets
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds the length of a request ID taken from a client
+const maxRequestIDLength = 128
+
+// RequestID takes the request's X-Request-ID, or generates one when it has none
+// or an unusable one, and attaches it to the request context so records logged
+// with it carry the ID. The ID is returned in the X-Request-ID response header
+// and added to JSON error responses as request_id.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID(id) {
+			id = uuid.New().String()
+		}
+
+		c.Request = c.Request.WithContext(logging.WithRequestID(c.Request.Context(), id))
+		c.Header(RequestIDHeader, id)
+		c.Writer = &requestIDWriter{ResponseWriter: c.Writer, id: id}
+		c.Next()
+	}
+}
+
+// GetRequestID returns the ID RequestID attached to the request, or "" without one
+func GetRequestID(c *gin.Context) string {
+	return logging.RequestID(c.Request.Context())
+}
+
+// validRequestID accepts IDs of letters, digits and . _ : - up to
+// maxRequestIDLength characters, so they are safe in headers, JSON and logs
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range id {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("._:-", r)) {
+			return false
+		}
+	}
+	return true
+}
+
+// requestIDWriter adds request_id to JSON objects written as error responses
+type requestIDWriter struct {
+	gin.ResponseWriter
+	id      string
+	written bool
+}
+
+// Write implements http.ResponseWriter. Error bodies are rendered in one write,
+// so only the first is rewritten.
+func (w *requestIDWriter) Write(b []byte) (int, error) {
+	first := !w.written
+	w.written = true
+	if !first || w.Status() < http.StatusBadRequest || !strings.HasPrefix(w.Header().Get("Content-Type"), "application/json") {
+		return w.ResponseWriter.Write(b)
+	}
+
+	trimmed := bytes.TrimSpace(b)
+	if len(trimmed) < 2 || trimmed[0] != '{' {
+		return w.ResponseWriter.Write(b)
+	}
+	field := `"request_id":` + strconv.Quote(w.id)
+	if len(bytes.TrimSpace(trimmed[1:len(trimmed)-1])) > 0 {
+		field += ","
+	}
+
+	body := append([]byte("{"+field), trimmed[1:]...)
+	if _, err := w.ResponseWriter.Write(body); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
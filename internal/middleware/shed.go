@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yashjain/konnect/internal/metrics"
+)
+
+// Limiter caps the requests handled at once by the routes sharing it
+type Limiter struct {
+	name string
+
+	mu       sync.Mutex
+	max      int
+	inFlight int
+}
+
+// NewLimiter returns a limiter, named name in metrics, admitting up to max
+// requests at once, or every request when max is not positive
+func NewLimiter(name string, max int) *Limiter {
+	return &Limiter{name: name, max: max}
+}
+
+// SetMax changes the requests admitted at once; requests already admitted
+// beyond a lowered limit are let finish
+func (l *Limiter) SetMax(max int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.max = max
+}
+
+// tryAcquire takes a slot if one is free
+func (l *Limiter) tryAcquire() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.max > 0 && l.inFlight >= l.max {
+		return false
+	}
+	l.inFlight++
+	return true
+}
+
+// release frees a slot taken by tryAcquire
+func (l *Limiter) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.inFlight--
+}
+
+// Shed admits a request only while each of limiters has a free slot, held
+// until the request is handled, and otherwise rejects it at once with a 503
+// and a Retry-After of retryAfter. Rejecting the overflow keeps the requests
+// already admitted fast, rather than having every request queue on a slow
+// database. Rejections are counted by the http_requests_shed_total metric.
+func Shed(retryAfter time.Duration, limiters ...*Limiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var held []*Limiter
+		defer func() {
+			for _, l := range held {
+				l.release()
+			}
+		}()
+
+		for _, l := range limiters {
+			if !l.tryAcquire() {
+				metrics.RequestsShed.WithLabelValues(l.name).Inc()
+				c.Header("Retry-After", strconv.Itoa(int(math.Max(1, math.Ceil(retryAfter.Seconds())))))
+				c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "service temporarily unavailable", "code": "overloaded"})
+				return
+			}
+			held = append(held, l)
+		}
+		c.Next()
+	}
+}
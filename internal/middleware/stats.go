@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// statsMinutes is how many minutes of per-minute request counts RequestStats keeps
+const statsMinutes = 60
+
+// RequestStats counts the requests handled and the server errors among them,
+// in total and per minute over the last hour
+type RequestStats struct {
+	mu       sync.Mutex
+	requests uint64
+	errors   uint64
+	minutes  [statsMinutes]minuteCount
+}
+
+// minuteCount holds the requests of one minute, numbered since the Unix epoch
+type minuteCount struct {
+	minute   int64
+	requests uint64
+	errors   uint64
+}
+
+// NewRequestStats returns stats without any requests
+func NewRequestStats() *RequestStats {
+	return &RequestStats{}
+}
+
+// Handler returns the middleware counting each request once it is handled
+func (s *RequestStats) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+		s.record(time.Now(), c.Writer.Status() >= http.StatusInternalServerError)
+	}
+}
+
+// record counts a request handled at now
+func (s *RequestStats) record(now time.Time, failed bool) {
+	minute := now.Unix() / 60
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m := &s.minutes[minute%statsMinutes]
+	if m.minute != minute {
+		*m = minuteCount{minute: minute}
+	}
+	m.requests++
+	s.requests++
+	if failed {
+		m.errors++
+		s.errors++
+	}
+}
+
+// Totals returns the requests handled and server errors since the stats were created
+func (s *RequestStats) Totals() (requests, errors uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests, s.errors
+}
+
+// Window returns the requests handled and server errors over the last window,
+// counted by whole minutes including the current one, and at most an hour
+func (s *RequestStats) Window(now time.Time, window time.Duration) (requests, errors uint64) {
+	current := now.Unix() / 60
+	oldest := current - int64(window/time.Minute) + 1
+	if oldest <= current-statsMinutes {
+		oldest = current - statsMinutes + 1
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, m := range s.minutes {
+		if m.minute >= oldest && m.minute <= current {
+			requests += m.requests
+			errors += m.errors
+		}
+	}
+	return requests, errors
+}
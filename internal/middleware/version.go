@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// apiVersionKey is the gin context key holding the version of the API a request was made to
+const apiVersionKey = "api_version"
+
+// Versioned marks requests as made to version of the API, for handlers to
+// answer in that version's shape (see APIVersion)
+func Versioned(version int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(apiVersionKey, version)
+		c.Next()
+	}
+}
+
+// APIVersion returns the version of the API a request was made to, 1 unless
+// Versioned marked it otherwise
+func APIVersion(c *gin.Context) int {
+	if v := c.GetInt(apiVersionKey); v > 0 {
+		return v
+	}
+	return 1
+}
+
+// Deprecated announces that the API under path from is deprecated in favor of
+// the one under path to: responses carry a Deprecation header (RFC 9745) with
+// the date it was deprecated, a Sunset header (RFC 8594) with the date after
+// which it may stop answering, when set, and a Link to the request's path in
+// the successor API.
+func Deprecated(deprecatedAt, sunset time.Time, from, to string) gin.HandlerFunc {
+	deprecation := "@" + strconv.FormatInt(deprecatedAt.Unix(), 10)
+	var sunsetDate string
+	if !sunset.IsZero() {
+		sunsetDate = sunset.UTC().Format(http.TimeFormat)
+	}
+
+	return func(c *gin.Context) {
+		h := c.Writer.Header()
+		h.Set("Deprecation", deprecation)
+		if sunsetDate != "" {
+			h.Set("Sunset", sunsetDate)
+		}
+		successor := to + strings.TrimPrefix(c.Request.URL.Path, from)
+		h.Add("Link", "<"+successor+`>; rel="successor-version"`)
+		c.Next()
+	}
+}
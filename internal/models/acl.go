@@ -0,0 +1,51 @@
+package models
+
+import "time"
+
+// Service visibility values
+const (
+	VisibilityPublic  = "public"
+	VisibilityPrivate = "private"
+)
+
+// ACL subject types
+const (
+	SubjectUser = "user"
+	SubjectTeam = "team"
+)
+
+// ACL permissions; write implies read
+const (
+	PermissionRead  = "read"
+	PermissionWrite = "write"
+)
+
+// User represents a member of an organization
+type User struct {
+	ID        string    `json:"id" db:"id"`
+	OrgID     string    `json:"org_id" db:"org_id"`
+	Email     string    `json:"email" db:"email" binding:"required,email"`
+	Name      string    `json:"name" db:"name" binding:"required"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+
+	// Password enables login when set at creation; it is hashed and never returned
+	Password string `json:"password,omitempty" db:"-"`
+}
+
+// Team represents a group of users within an organization
+type Team struct {
+	ID        string    `json:"id" db:"id"`
+	OrgID     string    `json:"org_id" db:"org_id"`
+	Name      string    `json:"name" db:"name" binding:"required"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// ServiceACL grants a user or team access to a private service
+type ServiceACL struct {
+	ID          string    `json:"id" db:"id"`
+	ServiceID   string    `json:"service_id" db:"service_id"`
+	SubjectType string    `json:"subject_type" db:"subject_type" binding:"required,oneof=user team"`
+	SubjectID   string    `json:"subject_id" db:"subject_id" binding:"required"`
+	Permission  string    `json:"permission" db:"permission" binding:"required,oneof=read write"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+}
@@ -0,0 +1,47 @@
+package models
+
+// BackstageAPIVersion is the apiVersion of the Backstage catalog entities exported
+const BackstageAPIVersion = "backstage.io/v1alpha1"
+
+// Kinds of exported Backstage entities
+const (
+	BackstageComponent = "Component"
+	BackstageAPI       = "API"
+)
+
+// BackstageEntity is an entity of a Backstage software catalog, as read from
+// catalog-info.yaml files. Services are exported as Components and their
+// released versions as the APIs those provide.
+type BackstageEntity struct {
+	APIVersion string            `json:"apiVersion" yaml:"apiVersion"`
+	Kind       string            `json:"kind" yaml:"kind"`
+	Metadata   BackstageMetadata `json:"metadata" yaml:"metadata"`
+
+	// Spec is a BackstageComponentSpec or a BackstageAPISpec, after Kind
+	Spec interface{} `json:"spec" yaml:"spec"`
+}
+
+// BackstageMetadata identifies and describes a BackstageEntity
+type BackstageMetadata struct {
+	Name        string            `json:"name" yaml:"name"`
+	Title       string            `json:"title,omitempty" yaml:"title,omitempty"`
+	Description string            `json:"description,omitempty" yaml:"description,omitempty"`
+	Tags        []string          `json:"tags,omitempty" yaml:"tags,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty" yaml:"annotations,omitempty"`
+}
+
+// BackstageComponentSpec is the spec of a Component entity
+type BackstageComponentSpec struct {
+	Type         string   `json:"type" yaml:"type"`
+	Lifecycle    string   `json:"lifecycle" yaml:"lifecycle"`
+	Owner        string   `json:"owner" yaml:"owner"`
+	ProvidesAPIs []string `json:"providesApis,omitempty" yaml:"providesApis,omitempty"`
+}
+
+// BackstageAPISpec is the spec of an API entity
+type BackstageAPISpec struct {
+	Type       string `json:"type" yaml:"type"`
+	Lifecycle  string `json:"lifecycle" yaml:"lifecycle"`
+	Owner      string `json:"owner" yaml:"owner"`
+	Definition string `json:"definition" yaml:"definition"`
+}
@@ -0,0 +1,124 @@
+package models
+
+import "time"
+
+// Backup record types, in the order they appear in a backup
+const (
+	BackupOrganization    = "organization"
+	BackupService         = "service"
+	BackupVersion         = "version"
+	BackupSpec            = "spec"
+	BackupDeployment      = "deployment"
+	BackupEnvironment     = "environment"
+	BackupCategory        = "category"
+	BackupServiceCategory = "service_category"
+	BackupUser            = "user"
+	BackupTeam            = "team"
+	BackupTeamMember      = "team_member"
+	BackupServiceACL      = "service_acl"
+	BackupConsumer        = "consumer"
+)
+
+// BackupRecord is one line of an NDJSON backup. Type says which of the
+// other fields is set.
+type BackupRecord struct {
+	Type            string                       `json:"type"`
+	Organization    *Organization                `json:"organization,omitempty"`
+	Service         *Service                     `json:"service,omitempty"`
+	Version         *Version                     `json:"version,omitempty"`
+	Spec            *BackupSpecRecord            `json:"spec,omitempty"`
+	Deployment      *Deployment                  `json:"deployment,omitempty"`
+	Environment     *BackupEnvironmentRecord     `json:"environment,omitempty"`
+	Category        *BackupCategoryRecord        `json:"category,omitempty"`
+	ServiceCategory *BackupServiceCategoryRecord `json:"service_category,omitempty"`
+	User            *BackupUserRecord            `json:"user,omitempty"`
+	Team            *Team                        `json:"team,omitempty"`
+	TeamMember      *BackupTeamMemberRecord      `json:"team_member,omitempty"`
+	ServiceACL      *ServiceACL                  `json:"service_acl,omitempty"`
+	Consumer        *BackupConsumerRecord        `json:"consumer,omitempty"`
+}
+
+// BackupSpecRecord is the OpenAPI document of a version, with its content,
+// which VersionSpec leaves out of JSON
+type BackupSpecRecord struct {
+	VersionID string    `json:"version_id"`
+	ServiceID string    `json:"service_id"`
+	Format    string    `json:"format"`
+	Content   string    `json:"content"`
+	Digest    string    `json:"digest"`
+	Score     *int      `json:"score"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// BackupEnvironmentRecord is the version an environment of a service runs,
+// the last one deployed there
+type BackupEnvironmentRecord struct {
+	ServiceID   string    `json:"service_id"`
+	Environment string    `json:"environment"`
+	VersionID   string    `json:"version_id"`
+	DeployedAt  time.Time `json:"deployed_at"`
+}
+
+// BackupCategoryRecord is a category with its organization, which Category
+// leaves out of JSON. Parents come before their subcategories.
+type BackupCategoryRecord struct {
+	ID        string    `json:"id"`
+	OrgID     string    `json:"org_id"`
+	ParentID  *string   `json:"parent_id"`
+	Name      string    `json:"name"`
+	Slug      string    `json:"slug"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// BackupServiceCategoryRecord assigns a service to a category
+type BackupServiceCategoryRecord struct {
+	ServiceID  string `json:"service_id"`
+	CategoryID string `json:"category_id"`
+}
+
+// BackupUserRecord is a user with their password hash, which User never
+// returns, so restored users can still log in. PasswordHash is nil for users
+// without a password.
+type BackupUserRecord struct {
+	ID           string    `json:"id"`
+	OrgID        string    `json:"org_id"`
+	Email        string    `json:"email"`
+	Name         string    `json:"name"`
+	PasswordHash *string   `json:"password_hash"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// BackupTeamMemberRecord adds a user to a team
+type BackupTeamMemberRecord struct {
+	TeamID string `json:"team_id"`
+	UserID string `json:"user_id"`
+}
+
+// BackupConsumerRecord is a team consuming a service, or one version of it
+type BackupConsumerRecord struct {
+	ID        string    `json:"id"`
+	ServiceID string    `json:"service_id"`
+	TeamID    string    `json:"team_id"`
+	VersionID *string   `json:"version_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// RestoreResult counts the records a restore inserted, and those it skipped
+// because the row already existed or its organization, service, version, user
+// or team did not
+type RestoreResult struct {
+	Organizations     int `json:"organizations"`
+	Services          int `json:"services"`
+	Versions          int `json:"versions"`
+	Specs             int `json:"specs"`
+	Deployments       int `json:"deployments"`
+	Environments      int `json:"environments"`
+	Categories        int `json:"categories"`
+	ServiceCategories int `json:"service_categories"`
+	Users             int `json:"users"`
+	Teams             int `json:"teams"`
+	TeamMembers       int `json:"team_members"`
+	ServiceACLs       int `json:"service_acls"`
+	Consumers         int `json:"consumers"`
+	Skipped           int `json:"skipped"`
+}
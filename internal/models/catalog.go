@@ -0,0 +1,72 @@
+package models
+
+// Catalog is an organization's services with their versions, in a form that
+// can be exported from one organization or instance and imported into another:
+// services are identified by slug and versions by semver, not by ID
+type Catalog struct {
+	Services []CatalogService `json:"services" yaml:"services"`
+}
+
+// CatalogService is a service of a Catalog
+type CatalogService struct {
+	Name        string            `json:"name" yaml:"name"`
+	Slug        string            `json:"slug" yaml:"slug"`
+	Description string            `json:"description,omitempty" yaml:"description,omitempty"`
+	Visibility  string            `json:"visibility,omitempty" yaml:"visibility,omitempty"`
+	OwnerTeam   string            `json:"owner_team,omitempty" yaml:"owner_team,omitempty"`
+	OwnerEmail  string            `json:"owner_email,omitempty" yaml:"owner_email,omitempty"`
+	Tags        []string          `json:"tags,omitempty" yaml:"tags,omitempty"`
+	Metadata    map[string]string `json:"metadata,omitempty" yaml:"metadata,omitempty"`
+	Versions    []CatalogVersion  `json:"versions,omitempty" yaml:"versions,omitempty"`
+}
+
+// CatalogVersion is a version of a CatalogService
+type CatalogVersion struct {
+	Semver    string            `json:"semver" yaml:"semver"`
+	Status    string            `json:"status" yaml:"status"`
+	Changelog string            `json:"changelog,omitempty" yaml:"changelog,omitempty"`
+	Metadata  map[string]string `json:"metadata,omitempty" yaml:"metadata,omitempty"`
+}
+
+// Ways an import resolves a service or version that already exists
+const (
+	ImportSkip      = "skip"
+	ImportOverwrite = "overwrite"
+)
+
+// Outcomes of importing a service or version
+const (
+	ImportCreated = "created"
+	ImportUpdated = "updated"
+	ImportSkipped = "skipped"
+	ImportFailed  = "failed"
+)
+
+// Kinds of imported items
+const (
+	ImportItemService = "service"
+	ImportItemVersion = "version"
+)
+
+// ImportItem is the outcome of importing one service or version
+type ImportItem struct {
+	Kind   string `json:"kind"`
+	Slug   string `json:"slug"`
+	Semver string `json:"semver,omitempty"`
+
+	// ID is the ID of the created, updated or skipped row; unset in a dry run for rows that would be created
+	ID     string `json:"id,omitempty"`
+	Result string `json:"result"`
+	Error  string `json:"error,omitempty"`
+}
+
+// ImportReport sums up an import, or what it would do in a dry run
+type ImportReport struct {
+	DryRun     bool         `json:"dry_run"`
+	OnConflict string       `json:"on_conflict"`
+	Created    int          `json:"created"`
+	Updated    int          `json:"updated"`
+	Skipped    int          `json:"skipped"`
+	Failed     int          `json:"failed"`
+	Items      []ImportItem `json:"items"`
+}
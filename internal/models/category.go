@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// Category groups services by domain. Categories form a tree within an
+// organization, such as Payments → Billing → Invoicing; a category without a
+// parent is a root. A service may be in any number of categories.
+type Category struct {
+	ID        string    `json:"id" db:"id"`
+	OrgID     string    `json:"-" db:"org_id"`
+	ParentID  *string   `json:"parent_id" db:"parent_id"`
+	Name      string    `json:"name" db:"name" binding:"required,max=100"`
+	Slug      string    `json:"slug" db:"slug" binding:"max=100"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+
+	// Children are the subcategories, set when categories are read as a tree
+	Children []Category `json:"children,omitempty" db:"-"`
+}
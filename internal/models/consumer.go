@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// Consumer registers a team as consuming a service, either every version of
+// it or, when VersionID is set, a single one. Consumers are emailed about the
+// deprecations of the versions they consume, and a service cannot be deleted
+// while it has consumers.
+type Consumer struct {
+	ID        string    `json:"id" db:"id"`
+	OrgID     string    `json:"-" db:"org_id"`
+	ServiceID string    `json:"service_id" db:"service_id"`
+	TeamID    string    `json:"team_id" db:"team_id" binding:"required"`
+	TeamName  string    `json:"team_name" db:"-"`
+	VersionID *string   `json:"version_id" db:"version_id"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
@@ -0,0 +1,43 @@
+package models
+
+// Actions of a config change
+const (
+	ConfigCreate = "create"
+	ConfigUpdate = "update"
+	ConfigDelete = "delete"
+)
+
+// ConfigChange is a change that applying a config makes to a service or
+// version, or would make in a dry run
+type ConfigChange struct {
+	Kind   string `json:"kind"`
+	Slug   string `json:"slug"`
+	Semver string `json:"semver,omitempty"`
+	Action string `json:"action"`
+
+	// ID is the ID of the changed row; unset for rows not created yet
+	ID string `json:"id,omitempty"`
+
+	// Diff lists the fields an update changes
+	Diff  []FieldDiff `json:"diff,omitempty"`
+	Error string      `json:"error,omitempty"`
+}
+
+// FieldDiff is the current and desired value of a field
+type FieldDiff struct {
+	Field string      `json:"field"`
+	From  interface{} `json:"from"`
+	To    interface{} `json:"to"`
+}
+
+// ConfigReport sums up the changes of applying a config, or those it would
+// make in a dry run. Services and versions left unchanged are only counted.
+type ConfigReport struct {
+	DryRun    bool           `json:"dry_run"`
+	Created   int            `json:"created"`
+	Updated   int            `json:"updated"`
+	Deleted   int            `json:"deleted"`
+	Unchanged int            `json:"unchanged"`
+	Failed    int            `json:"failed"`
+	Changes   []ConfigChange `json:"changes"`
+}
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// Deployment records a version of a service being deployed to an environment,
+// such as dev, staging or prod
+type Deployment struct {
+	ID          string    `json:"id" db:"id"`
+	OrgID       string    `json:"-" db:"org_id"`
+	ServiceID   string    `json:"service_id" db:"service_id"`
+	VersionID   string    `json:"version_id" db:"version_id"`
+	Environment string    `json:"environment" db:"environment" binding:"required"`
+	DeployedAt  time.Time `json:"deployed_at" db:"deployed_at"`
+}
@@ -0,0 +1,36 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Event types recorded when services and versions change
+const (
+	EventServiceCreated = "service.created"
+	EventServiceUpdated = "service.updated"
+	EventServiceDeleted = "service.deleted"
+	EventVersionCreated = "version.created"
+	EventVersionUpdated = "version.updated"
+
+	// EventVersionReleased and EventVersionDeprecated are recorded alongside
+	// version.created and version.updated when a version takes that status
+	EventVersionReleased   = "version.released"
+	EventVersionDeprecated = "version.deprecated"
+)
+
+// Event is a change to a service or version, recorded in the outbox in the same
+// transaction as the change and delivered afterwards
+type Event struct {
+	ID        string `json:"id" db:"id"`
+	OrgID     string `json:"org_id" db:"org_id"`
+	Type      string `json:"type" db:"event_type"`
+	SubjectID string `json:"subject_id" db:"subject_id"`
+
+	// Payload is the JSON of the service or version as it was after the change
+	Payload   json.RawMessage `json:"payload" db:"payload"`
+	CreatedAt time.Time       `json:"created_at" db:"created_at"`
+
+	// Attempts counts the failed deliveries so far
+	Attempts int `json:"-" db:"attempts"`
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// GitHubRepository links a GitHub repository, as owner/name, to the service
+// whose versions its published releases become
+type GitHubRepository struct {
+	ServiceID  string    `json:"service_id" db:"service_id"`
+	Repository string    `json:"repository" db:"repository" binding:"required"`
+	OrgID      string    `json:"-" db:"org_id"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+
+	// Secret signs the repository's webhook events. It is generated when left
+	// out, and only returned when the repository is linked.
+	Secret string `json:"secret,omitempty" db:"secret"`
+}
@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// Kong sync statuses
+const (
+	KongSyncPending = "pending"
+	KongSyncSynced  = "synced"
+	KongSyncFailed  = "failed"
+)
+
+// KongSync is the state of a service's sync to the Kong Gateway, queued by every
+// change to the service or its versions, with the outcome of its latest attempt
+type KongSync struct {
+	ServiceID     string     `json:"service_id" db:"service_id"`
+	OrgID         string     `json:"-" db:"org_id"`
+	Status        string     `json:"status" db:"status"`
+	Attempts      int        `json:"attempts" db:"attempts"`
+	LastError     string     `json:"last_error,omitempty" db:"last_error"`
+	NextAttemptAt *time.Time `json:"next_attempt_at,omitempty" db:"next_attempt_at"`
+	SyncedAt      *time.Time `json:"synced_at,omitempty" db:"synced_at"`
+	UpdatedAt     time.Time  `json:"updated_at" db:"updated_at"`
+
+	// Revision counts the times the sync was queued; an attempt only settles
+	// the revision it read, leaving a sync queued meanwhile pending
+	Revision int `json:"-" db:"revision"`
+}
@@ -0,0 +1,14 @@
+package models
+
+// LogLevel is the lowest level of the records logged: debug, info, warn or error
+type LogLevel struct {
+	Level string `json:"level" binding:"required"`
+}
+
+// DebugCapture enables capturing the bodies of the requests to Route, as
+// "METHOD /route", or with the request ID RequestID, for TTL, a duration such as 15m
+type DebugCapture struct {
+	Route     string `json:"route"`
+	RequestID string `json:"request_id"`
+	TTL       string `json:"ttl"`
+}
@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+// Email notification statuses
+const (
+	NotificationPending = "pending"
+	NotificationSent    = "sent"
+	NotificationFailed  = "failed"
+)
+
+// NotificationSunsetReminder is the event type of the emails reminding the
+// recipients of a deprecated version's deprecation emails that its sunset date
+// approaches
+const NotificationSunsetReminder = "version.sunset_reminder"
+
+// NotificationPreferences are the notifications a user is emailed. Users who
+// never set them get every notification.
+type NotificationPreferences struct {
+	UserID string `json:"user_id" db:"user_id"`
+	OrgID  string `json:"-" db:"org_id"`
+
+	// Deprecations emails the user when a version of a service they own,
+	// consume or are subscribed to is deprecated, and again as its sunset
+	// date approaches
+	Deprecations *bool     `json:"deprecations" db:"deprecations" binding:"required"`
+	UpdatedAt    time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// EmailNotification is an email to a user about a change to a version, queued
+// in the same transaction as the change and retried until it is sent or runs
+// out of attempts
+type EmailNotification struct {
+	ID            string     `json:"id" db:"id"`
+	OrgID         string     `json:"-" db:"org_id"`
+	UserID        string     `json:"user_id" db:"user_id"`
+	EventType     string     `json:"event_type" db:"event_type"`
+	ServiceID     string     `json:"service_id" db:"service_id"`
+	VersionID     string     `json:"version_id" db:"version_id"`
+	Status        string     `json:"status" db:"status"`
+	Attempts      int        `json:"attempts" db:"attempts"`
+	LastError     string     `json:"last_error,omitempty" db:"last_error"`
+	NextAttemptAt *time.Time `json:"next_attempt_at,omitempty" db:"next_attempt_at"`
+	SentAt        *time.Time `json:"sent_at,omitempty" db:"sent_at"`
+	CreatedAt     time.Time  `json:"created_at" db:"created_at"`
+
+	// Email is the user's address, loaded with due notifications
+	Email string `json:"-" db:"-"`
+}
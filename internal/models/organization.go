@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// Organization represents a tenant that owns services
+type Organization struct {
+	ID        string    `json:"id" db:"id"`
+	Name      string    `json:"name" db:"name" binding:"required"`
+	Slug      string    `json:"slug" db:"slug" binding:"required"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// APIToken represents a bearer token scoped to a single organization
+type APIToken struct {
+	ID        string    `json:"id" db:"id"`
+	OrgID     string    `json:"org_id" db:"org_id"`
+	Name      string    `json:"name" db:"name" binding:"required"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+
+	// UserID binds the token to a user; empty tokens act for the whole organization
+	UserID string `json:"user_id,omitempty" db:"user_id"`
+
+	// Token is the plaintext secret, returned once at creation and never stored
+	Token string `json:"token,omitempty" db:"-"`
+}
@@ -0,0 +1,77 @@
+package models
+
+import "time"
+
+// Search hit types
+const (
+	SearchHitService = "service"
+	SearchHitVersion = "version"
+)
+
+// SearchHit is one result of a global search, reduced to what an omnibox shows
+type SearchHit struct {
+	Type    string `json:"type"`
+	ID      string `json:"id"`
+	Title   string `json:"title"`
+	Summary string `json:"summary,omitempty"`
+
+	// Link is the API path of the hit's resource
+	Link string `json:"link"`
+}
+
+// SearchGroup holds the hits of one type, best matches first
+type SearchGroup struct {
+	Type string      `json:"type"`
+	Hits []SearchHit `json:"hits"`
+}
+
+// GlobalSearchResponse is the result of searching every entity type at once
+type GlobalSearchResponse struct {
+	Query  string        `json:"query"`
+	Groups []SearchGroup `json:"groups"`
+}
+
+// SearchQuery is one sampled search, recorded for search analytics
+type SearchQuery struct {
+	OrgID string `json:"org_id"`
+
+	// Query is the search as typed, lowercased with its whitespace collapsed
+	Query string `json:"query"`
+
+	// Hits is how many services the search found
+	Hits int `json:"hits"`
+
+	SearchedAt time.Time `json:"searched_at"`
+}
+
+// QueryStat counts the sampled searches for one query
+type QueryStat struct {
+	Query string `json:"query"`
+	Count int    `json:"count"`
+
+	// AvgHits is the average number of services the query found
+	AvgHits float64 `json:"avg_hits"`
+}
+
+// SearchAnalytics reports what an organization searched for since a point in
+// time. Counts are of sampled searches; divide them by SampleRate to estimate
+// the number of searches.
+type SearchAnalytics struct {
+	Since      time.Time `json:"since"`
+	SampleRate float64   `json:"sample_rate"`
+
+	Searches           int `json:"searches"`
+	ZeroResultSearches int `json:"zero_result_searches"`
+
+	// TopQueries are the most frequent queries, and ZeroResultQueries the most
+	// frequent of those that found nothing
+	TopQueries        []QueryStat `json:"top_queries"`
+	ZeroResultQueries []QueryStat `json:"zero_result_queries"`
+}
+
+// SearchSynonyms are the synonyms a search term is expanded with. Both are
+// stored folded to lowercase without accents.
+type SearchSynonyms struct {
+	Term     string   `json:"term"`
+	Synonyms []string `json:"synonyms" binding:"required"`
+}
@@ -1,12 +1,71 @@
 package models
 
-// Service represents a service entity in the system
+import (
+	"time"
+
+	"github.com/yashjain/konnect/pkg/types"
+)
+
+// Service represents a service entity in the system. Timestamps are in UTC and
+// serialized as RFC3339.
 type Service struct {
-	ID            string `json:"id" db:"id"`
-	Name          string `json:"name" db:"name"`
-	Slug          string `json:"slug" db:"slug"`
-	Description   string `json:"description" db:"description"`
-	CreatedAt     string `json:"created_at" db:"created_at"`
-	UpdatedAt     string `json:"updated_at" db:"updated_at"`
-	VersionsCount int    `json:"versions_count" db:"versions_count"`
+	ID            string    `json:"id" db:"id"`
+	OrgID         string    `json:"org_id" db:"org_id"`
+	Name          string    `json:"name" db:"name"`
+	Slug          string    `json:"slug" db:"slug"`
+	Description   string    `json:"description" db:"description"`
+	Visibility    string    `json:"visibility" db:"visibility" binding:"omitempty,oneof=public private"`
+	CreatedAt     time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at" db:"updated_at"`
+	VersionsCount int       `json:"versions_count" db:"versions_count"`
+
+	// OwnerTeam and OwnerEmail name who owns the service, such as the team to
+	// page during an incident; they are empty when unowned. Updates leave an
+	// owner that is nil unchanged and clear one that is empty.
+	OwnerTeam  *string `json:"owner_team" db:"owner_team"`
+	OwnerEmail *string `json:"owner_email" db:"owner_email"`
+
+	// SpecScore is the lint score of the OpenAPI document of the newest
+	// version that has a scored one, nil when none has
+	SpecScore *int `json:"spec_score" db:"-"`
+
+	// Tags label the service for filtering, lowercase and sorted
+	Tags []string `json:"tags" db:"-"`
+
+	// Metadata holds free-form string values by key, such as a repository URL
+	// or a cost center
+	Metadata map[string]string `json:"metadata" db:"metadata"`
+
+	// DeletedAt is set once the service is soft-deleted; such services are only
+	// returned when a read asks to include deleted rows
+	DeletedAt *time.Time `json:"deleted_at,omitempty" db:"deleted_at"`
+
+	// Score is the relevance of a full-text search result, higher being better.
+	// Scores are only comparable between results of the same database backend.
+	Score *float64 `json:"score,omitempty" db:"-"`
+
+	// DescriptionHTML is the sanitized HTML rendering of Description, set only when requested
+	DescriptionHTML string `json:"description_html,omitempty" db:"-"`
+
+	// Links point to the service, its versions and its newest version
+	Links types.Links `json:"_links,omitempty" db:"-"`
+}
+
+// Owner returns the owner team and owner email of a service, empty when it is
+// unowned or they are not set
+func (s *Service) Owner() (team, email string) {
+	if s.OwnerTeam != nil {
+		team = *s.OwnerTeam
+	}
+	if s.OwnerEmail != nil {
+		email = *s.OwnerEmail
+	}
+	return team, email
+}
+
+// ServiceSuggestion is a typeahead match for a service
+type ServiceSuggestion struct {
+	ID   string `json:"id" db:"id"`
+	Name string `json:"name" db:"name"`
+	Slug string `json:"slug" db:"slug"`
 }
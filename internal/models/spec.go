@@ -0,0 +1,91 @@
+package models
+
+import "time"
+
+// VersionSpec is the OpenAPI document attached to a version, kept as uploaded
+type VersionSpec struct {
+	VersionID string `json:"version_id" db:"version_id"`
+	ServiceID string `json:"service_id" db:"service_id"`
+
+	// Format is json or yaml, that of the document as uploaded
+	Format string `json:"format" db:"format"`
+
+	// OpenAPI, Title and APIVersion are the document's openapi, info.title and
+	// info.version, read from Content when the document is uploaded
+	OpenAPI    string `json:"openapi" db:"-"`
+	Title      string `json:"title" db:"-"`
+	APIVersion string `json:"api_version" db:"-"`
+
+	// Digest is the hex SHA-256 digest of Content, and Size its length in bytes
+	Digest string `json:"digest" db:"digest"`
+	Size   int    `json:"size" db:"-"`
+
+	// Score is the lint score of the document when it was uploaded, nil for
+	// documents uploaded before documents were linted
+	Score *int `json:"score" db:"lint_score"`
+
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+	Content   []byte    `json:"-" db:"content"`
+}
+
+// SpecChange is a difference between the OpenAPI documents of two versions
+type SpecChange struct {
+	// Type is the kind of change, such as path_removed or property_type_changed
+	Type string `json:"type"`
+
+	// Location is where the change is, such as GET /pets/{id} response 200 application/json body.owner
+	Location string `json:"location"`
+	Message  string `json:"message"`
+
+	// Breaking is set for changes that can break clients written against the older document
+	Breaking bool `json:"breaking"`
+}
+
+// SpecComparisonVersion is one of the versions of a SpecComparison
+type SpecComparisonVersion struct {
+	ID     string `json:"id"`
+	Semver string `json:"semver"`
+	Digest string `json:"digest"`
+}
+
+// SpecComparison lists the changes between the OpenAPI documents of two versions of a service
+type SpecComparison struct {
+	From SpecComparisonVersion `json:"from"`
+	To   SpecComparisonVersion `json:"to"`
+
+	// BreakingChanges counts the breaking changes. They are declared when the
+	// semver of To is a major bump from that of From.
+	BreakingChanges int  `json:"breaking_changes"`
+	Declared        bool `json:"declared"`
+
+	Changes []SpecChange `json:"changes"`
+}
+
+// SpecLintRule is a lint rule an OpenAPI document is checked against
+type SpecLintRule struct {
+	ID          string `json:"id"`
+	Severity    string `json:"severity"`
+	Description string `json:"description"`
+}
+
+// SpecLintProblem is a place an OpenAPI document breaks a lint rule
+type SpecLintProblem struct {
+	Rule     string `json:"rule"`
+	Severity string `json:"severity"`
+
+	// Location is where the problem is, such as paths./pets.get
+	Location string `json:"location"`
+	Message  string `json:"message"`
+}
+
+// SpecLintReport is the outcome of linting an OpenAPI document
+type SpecLintReport struct {
+	// Score rates the document from 0 to 100, by the weighted share of checks it passes
+	Score int `json:"score"`
+
+	Rules []SpecLintRule `json:"rules"`
+
+	// Problems lists the first problems found, and ProblemsCount counts them all
+	Problems      []SpecLintProblem `json:"problems"`
+	ProblemsCount int               `json:"problems_count"`
+}
@@ -1,11 +1,78 @@
 package models
 
+import (
+	"time"
+
+	"github.com/yashjain/konnect/pkg/types"
+)
+
+// Version statuses
+const (
+	VersionDraft      = "draft"
+	VersionReleased   = "released"
+	VersionDeprecated = "deprecated"
+)
+
 // Version represents a version of a service
 type Version struct {
-	ID        string `json:"id" db:"id"`
-	ServiceID string `json:"service_id" db:"service_id"`
-	Semver    string `json:"semver" db:"semver"`
-	Status    string `json:"status" db:"status"`
-	Changelog string `json:"changelog" db:"changelog"`
-	CreatedAt string `json:"created_at" db:"created_at"`
+	ID        string    `json:"id" db:"id"`
+	ServiceID string    `json:"service_id" db:"service_id"`
+	Semver    string    `json:"semver" db:"semver"`
+	Status    string    `json:"status" db:"status"`
+	Changelog string    `json:"changelog" db:"changelog"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+
+	// DeprecatedAt is when the version was deprecated or, while its status is
+	// not deprecated yet, when it is scheduled to be
+	DeprecatedAt *time.Time `json:"deprecated_at" db:"deprecated_at"`
+
+	// SunsetAt is when the deprecated version stops being served, nil when no
+	// date is planned
+	SunsetAt *time.Time `json:"sunset_at" db:"sunset_at"`
+
+	// ReleasedAt is when the version was first released, nil while it has
+	// never been
+	ReleasedAt *time.Time `json:"released_at" db:"released_at"`
+
+	// Metadata holds free-form string values by key, like the metadata of services
+	Metadata map[string]string `json:"metadata" db:"metadata"`
+
+	// Environments are those the version is currently deployed to, sorted; an
+	// environment runs the version deployed to it last
+	Environments []string `json:"environments" db:"-"`
+
+	// HasSpec is set when the version has an OpenAPI document
+	HasSpec bool `json:"has_spec" db:"-"`
+
+	// SpecScore is the lint score of the version's OpenAPI document, nil
+	// without one or when it was uploaded before documents were linted
+	SpecScore *int `json:"spec_score" db:"-"`
+
+	// DeletedAt is set once the version is soft-deleted
+	DeletedAt *time.Time `json:"deleted_at,omitempty" db:"deleted_at"`
+
+	// ChangelogHTML is the sanitized HTML rendering of Changelog, set only when requested
+	ChangelogHTML string `json:"changelog_html,omitempty" db:"-"`
+
+	// Links point to the version, its service and the service's versions
+	Links types.Links `json:"_links,omitempty" db:"-"`
+}
+
+// DeprecationSchedule schedules the deprecation of a version
+type DeprecationSchedule struct {
+	// DeprecatedAt is when the version is deprecated, now when nil; versions
+	// are deprecated right away unless it is in the future
+	DeprecatedAt *time.Time `json:"deprecated_at"`
+
+	// SunsetAt is when the version stops being served, after DeprecatedAt
+	SunsetAt *time.Time `json:"sunset_at"`
+}
+
+// ArchiveResult reports the versions an archival moved out of the versions table
+type ArchiveResult struct {
+	Versions int64
+
+	// Services maps each service that had versions archived to its
+	// organization, or to "" when the service no longer exists
+	Services map[string]string
 }
@@ -0,0 +1,78 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// EventWebhookTest is the type of the event sent by a test delivery
+const EventWebhookTest = "webhook.test"
+
+// EventTypes lists the event types webhook subscriptions can select
+var EventTypes = []string{EventServiceCreated, EventServiceUpdated, EventServiceDeleted, EventVersionCreated, EventVersionUpdated,
+	EventVersionReleased, EventVersionDeprecated}
+
+// Formats deliveries are sent in: the event as JSON, or a chat message for a
+// Slack or Microsoft Teams incoming webhook
+const (
+	WebhookFormatJSON  = "json"
+	WebhookFormatSlack = "slack"
+	WebhookFormatTeams = "teams"
+)
+
+// Webhook delivery statuses
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+// WebhookSubscription asks for an organization's events of some types to be
+// POSTed to a URL
+type WebhookSubscription struct {
+	ID         string    `json:"id" db:"id"`
+	OrgID      string    `json:"org_id" db:"org_id"`
+	URL        string    `json:"url" db:"url"`
+	EventTypes []string  `json:"event_types" db:"event_types"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at" db:"updated_at"`
+
+	// Active subscriptions receive events; deliveries to paused ones wait until
+	// they are resumed. Left out of an update, it keeps its current value.
+	Active *bool `json:"active,omitempty" db:"active"`
+
+	// Secret signs deliveries. It is generated when left out at creation,
+	// and only returned then; left out of an update, it is kept.
+	Secret string `json:"secret,omitempty" db:"secret"`
+
+	// Format is json, the default, slack or teams. Left out of an update, it
+	// keeps its current value.
+	Format string `json:"format" db:"format"`
+
+	// Template is a Go text/template rendering the chat messages of slack and
+	// teams subscriptions, instead of the default message of each event type
+	Template string `json:"template,omitempty" db:"template"`
+}
+
+// WebhookDelivery is one event to be delivered to a subscription, with the
+// outcome of its latest attempt
+type WebhookDelivery struct {
+	ID             string          `json:"id" db:"id"`
+	SubscriptionID string          `json:"subscription_id" db:"subscription_id"`
+	EventID        string          `json:"event_id" db:"event_id"`
+	EventType      string          `json:"event_type" db:"event_type"`
+	Status         string          `json:"status" db:"status"`
+	Attempts       int             `json:"attempts" db:"attempts"`
+	ResponseStatus int             `json:"response_status,omitempty" db:"response_status"`
+	LastError      string          `json:"last_error,omitempty" db:"last_error"`
+	CreatedAt      time.Time       `json:"created_at" db:"created_at"`
+	NextAttemptAt  *time.Time      `json:"next_attempt_at,omitempty" db:"next_attempt_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty" db:"delivered_at"`
+	Payload        json.RawMessage `json:"payload" db:"payload"`
+
+	// URL, Secret, Format and Template are the subscription's, loaded with due deliveries
+	URL      string `json:"-" db:"-"`
+	Secret   string `json:"-" db:"-"`
+	Format   string `json:"-" db:"-"`
+	Template string `json:"-" db:"-"`
+}
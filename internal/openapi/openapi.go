@@ -0,0 +1,335 @@
+// Package openapi generates the OpenAPI 3.1 document of a router from its
+// routes and the Go types its handlers bind and respond with, so that the
+// document cannot drift from the handlers it describes
+package openapi
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Version is the version of the OpenAPI Specification documents follow
+const Version = "3.1.0"
+
+// MediaTypeJSON is the media type operations consume and produce by default
+const MediaTypeJSON = "application/json"
+
+// Spec is what Generate documents a router's routes with
+type Spec struct {
+	Info Info
+
+	// SecuritySchemes are the schemes operations may require, by name
+	SecuritySchemes map[string]SecurityScheme
+
+	// Errors are the error responses of operations, except under the path
+	// prefixes of PathErrors, which have their own
+	Errors     ErrorFormat
+	PathErrors map[string]ErrorFormat
+
+	// Operations document routes by the name of their handler, such as
+	// GetServices for the closure handlers.GetServices returns
+	Operations map[string]Operation
+
+	// Deprecated are the path prefixes of deprecated routes, such as /api/v1.
+	// Their operations are marked deprecated, and their IDs are suffixed with
+	// the prefix's last segment, such as GetServicesV1, to tell them apart
+	// from those of the routes that succeed them.
+	Deprecated []string
+}
+
+// ErrorFormat is the format of error responses
+type ErrorFormat struct {
+	// Body is a value of the type of error bodies
+	Body interface{}
+
+	// MediaType is the media type they are served as
+	MediaType string
+}
+
+// Operation documents what a handler does. Its path, method and path
+// parameters are taken from the route the handler is registered on.
+type Operation struct {
+	Summary     string
+	Description string
+	Tags        []string
+
+	// Security is the name of the scheme the operation requires, if any
+	Security string
+
+	// Parameters are the query parameters, and descriptions of path parameters
+	Parameters []Parameter
+
+	// Body is a value of the type the request body binds to, nil when there is none
+	Body interface{}
+
+	// Consumes are the media types of the request body, JSON when empty
+	Consumes []string
+
+	// Produces are the media types of successful responses, JSON when empty
+	Produces []string
+
+	// Responses are values of the type of successful responses by status; a
+	// nil value is a response without content
+	Responses map[int]interface{}
+
+	// Errors are the statuses of the error responses the operation may answer with
+	Errors []int
+}
+
+// Document is an OpenAPI document
+type Document struct {
+	OpenAPI    string                                 `json:"openapi"`
+	Info       Info                                   `json:"info"`
+	Paths      map[string]map[string]*OperationObject `json:"paths"`
+	Components Components                             `json:"components"`
+}
+
+// Info is the metadata of the API a document describes
+type Info struct {
+	Title       string   `json:"title"`
+	Version     string   `json:"version"`
+	Description string   `json:"description,omitempty"`
+	License     *License `json:"license,omitempty"`
+}
+
+// License is the license of the API, by its SPDX identifier
+type License struct {
+	Name       string `json:"name"`
+	Identifier string `json:"identifier,omitempty"`
+}
+
+// SecurityScheme is a way operations authenticate requests
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+	Description  string `json:"description,omitempty"`
+}
+
+// Components hold the schemas of named types and the security schemes
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+// OperationObject is an operation of a document
+type OperationObject struct {
+	OperationID string                `json:"operationId"`
+	Summary     string                `json:"summary,omitempty"`
+	Description string                `json:"description,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Deprecated  bool                  `json:"deprecated,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]Response   `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+// Parameter is a path or query parameter
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Explode     *bool   `json:"explode,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// RequestBody is the body an operation consumes
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+// Response is a response an operation answers with
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// MediaType is the schema of a body in one media type
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Query documents a query parameter
+func Query(name string, schema *Schema, description string) Parameter {
+	p := Parameter{Name: name, In: "query", Description: description, Schema: schema}
+	if schema.Type == "array" {
+		// Arrays are sent as repeated parameters, such as tag=a&tag=b
+		explode := true
+		p.Explode = &explode
+	}
+	return p
+}
+
+// RequiredQuery documents a query parameter requests must have
+func RequiredQuery(name string, schema *Schema, description string) Parameter {
+	p := Query(name, schema, description)
+	p.Required = true
+	return p
+}
+
+// Path describes a path parameter
+func Path(name, description string) Parameter {
+	return Parameter{Name: name, In: "path", Description: description, Required: true, Schema: String()}
+}
+
+// Header describes a header requests must have
+func Header(name, description string) Parameter {
+	return Parameter{Name: name, In: "header", Description: description, Required: true, Schema: String()}
+}
+
+// Generate documents routes with spec. Every route must have its handler's
+// operation in spec, so that no route goes undocumented.
+func Generate(spec Spec, routes gin.RoutesInfo) (*Document, error) {
+	g := newGenerator()
+	doc := &Document{
+		OpenAPI: Version,
+		Info:    spec.Info,
+		Paths:   map[string]map[string]*OperationObject{},
+	}
+
+	var missing []string
+	ids := map[string]bool{}
+	for _, route := range routes {
+		name := HandlerName(route.Handler)
+		op, ok := spec.Operations[name]
+		if !ok {
+			missing = append(missing, route.Method+" "+route.Path)
+			continue
+		}
+
+		id, deprecated := name, false
+		for _, prefix := range spec.Deprecated {
+			if strings.HasPrefix(route.Path, prefix+"/") {
+				segment := prefix[strings.LastIndex(prefix, "/")+1:]
+				id, deprecated = name+strings.ToUpper(segment[:1])+segment[1:], true
+			}
+		}
+		if ids[id] {
+			return nil, fmt.Errorf("openapi: %s %s: operation ID %s is taken", route.Method, route.Path, id)
+		}
+		ids[id] = true
+
+		path, params, err := pathParameters(route.Path, op.Parameters)
+		if err != nil {
+			return nil, fmt.Errorf("openapi: %s %s: %w", route.Method, route.Path, err)
+		}
+		obj := &OperationObject{
+			OperationID: id,
+			Summary:     op.Summary,
+			Description: op.Description,
+			Tags:        op.Tags,
+			Deprecated:  deprecated,
+			Parameters:  params,
+			Responses:   map[string]Response{},
+		}
+		if op.Security != "" {
+			if _, ok := spec.SecuritySchemes[op.Security]; !ok {
+				return nil, fmt.Errorf("openapi: %s %s: unknown security scheme %q", route.Method, route.Path, op.Security)
+			}
+			obj.Security = []map[string][]string{{op.Security: {}}}
+		}
+		if op.Body != nil {
+			obj.RequestBody = &RequestBody{Required: true, Content: g.content(op.Body, mediaTypes(op.Consumes))}
+		}
+		for status, body := range op.Responses {
+			resp := Response{Description: http.StatusText(status)}
+			if body != nil {
+				resp.Content = g.content(body, mediaTypes(op.Produces))
+			}
+			obj.Responses[strconv.Itoa(status)] = resp
+		}
+		errors := spec.Errors
+		for prefix, format := range spec.PathErrors {
+			if strings.HasPrefix(route.Path, prefix+"/") {
+				errors = format
+			}
+		}
+		for _, status := range op.Errors {
+			obj.Responses[strconv.Itoa(status)] = Response{
+				Description: http.StatusText(status),
+				Content:     map[string]MediaType{errors.MediaType: {Schema: g.schemaOf(errors.Body)}},
+			}
+		}
+
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = map[string]*OperationObject{}
+		}
+		doc.Paths[path][strings.ToLower(route.Method)] = obj
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return nil, fmt.Errorf("openapi: routes without an operation: %s", strings.Join(missing, ", "))
+	}
+
+	doc.Components = Components{Schemas: g.schemas, SecuritySchemes: spec.SecuritySchemes}
+	return doc, nil
+}
+
+// HandlerName is the name operations of a handler are documented by: the
+// function a closure was returned by, or the function itself, without its
+// package, such as GetServices for
+// github.com/yashjain/konnect/internal/handlers.GetServices.func1
+func HandlerName(handler string) string {
+	if i := strings.LastIndex(handler, "/"); i >= 0 {
+		handler = handler[i+1:]
+	}
+	parts := strings.Split(handler, ".")
+	if len(parts) < 2 {
+		return handler
+	}
+	return parts[1]
+}
+
+// pathParameters turns a gin path into an OpenAPI one, such as
+// /services/:id/versions into /services/{id}/versions, and returns its
+// parameters, described by those of declared and followed by the rest of declared
+func pathParameters(ginPath string, declared []Parameter) (string, []Parameter, error) {
+	described := map[string]Parameter{}
+	var params []Parameter
+	for _, p := range declared {
+		if p.In == "path" {
+			described[p.Name] = p
+		}
+	}
+
+	segments := strings.Split(ginPath, "/")
+	for i, segment := range segments {
+		if segment == "" || (segment[0] != ':' && segment[0] != '*') {
+			continue
+		}
+		name := segment[1:]
+		p, ok := described[name]
+		if !ok {
+			p = Parameter{Name: name, In: "path", Required: true, Schema: String()}
+		}
+		delete(described, name)
+		params = append(params, p)
+		segments[i] = "{" + name + "}"
+	}
+	for name := range described {
+		return "", nil, fmt.Errorf("path parameter %q is not in the path", name)
+	}
+
+	for _, p := range declared {
+		if p.In != "path" {
+			params = append(params, p)
+		}
+	}
+	return strings.Join(segments, "/"), params, nil
+}
+
+// mediaTypes are the media types of a body, JSON unless others are given
+func mediaTypes(types []string) []string {
+	if len(types) == 0 {
+		return []string{MediaTypeJSON}
+	}
+	return types
+}
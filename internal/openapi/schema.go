@@ -0,0 +1,291 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schema is a JSON Schema, as OpenAPI 3.1 uses them
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 interface{}        `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	AllOf                []*Schema          `json:"allOf,omitempty"`
+	AnyOf                []*Schema          `json:"anyOf,omitempty"`
+}
+
+// String is the schema of a string
+func String() *Schema { return &Schema{Type: "string"} }
+
+// Integer is the schema of an integer
+func Integer() *Schema { return &Schema{Type: "integer"} }
+
+// Number is the schema of a number
+func Number() *Schema { return &Schema{Type: "number"} }
+
+// Boolean is the schema of a boolean
+func Boolean() *Schema { return &Schema{Type: "boolean"} }
+
+// Array is the schema of an array of items
+func Array(items *Schema) *Schema { return &Schema{Type: "array", Items: items} }
+
+// OneOf restricts s to values, and returns it
+func (s *Schema) OneOf(values ...interface{}) *Schema {
+	s.Enum = values
+	return s
+}
+
+// Min sets the minimum of s, and returns it
+func (s *Schema) Min(v float64) *Schema {
+	s.Minimum = &v
+	return s
+}
+
+// Max sets the maximum of s, and returns it
+func (s *Schema) Max(v float64) *Schema {
+	s.Maximum = &v
+	return s
+}
+
+// Refine documents a value of base's type whose field, by its JSON name, has
+// value's type instead, such as the data of a types.PaginatedResponse
+func Refine(base interface{}, field string, value interface{}) interface{} {
+	return refined{base: base, field: field, value: value}
+}
+
+// refined is a value documented by Refine
+type refined struct {
+	base  interface{}
+	field string
+	value interface{}
+}
+
+var (
+	timeType      = reflect.TypeOf(time.Time{})
+	marshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+// generator turns Go types into schemas, collecting those of named structs
+// as components that the schemas refer to
+type generator struct {
+	schemas map[string]*Schema
+	names   map[reflect.Type]string
+}
+
+func newGenerator() *generator {
+	return &generator{schemas: map[string]*Schema{}, names: map[reflect.Type]string{}}
+}
+
+// content is the content of a body of v's type in each of mediaTypes. JSON:API
+// and other +json documents wrap the types in a structure of their own, so
+// they are documented without a schema.
+func (g *generator) content(v interface{}, mediaTypes []string) map[string]MediaType {
+	content := map[string]MediaType{}
+	for _, mediaType := range mediaTypes {
+		schema := &Schema{}
+		if !strings.HasSuffix(mediaType, "+json") {
+			schema = g.schemaOf(v)
+		}
+		content[mediaType] = MediaType{Schema: schema}
+	}
+	return content
+}
+
+// schemaOf is the schema of v's type
+func (g *generator) schemaOf(v interface{}) *Schema {
+	if r, ok := v.(refined); ok {
+		return &Schema{AllOf: []*Schema{
+			g.schemaOf(r.base),
+			{Type: "object", Properties: map[string]*Schema{r.field: g.schemaOf(r.value)}},
+		}}
+	}
+	return g.schema(reflect.TypeOf(v))
+}
+
+// schema is the schema of t
+func (g *generator) schema(t reflect.Type) *Schema {
+	if t == nil {
+		return &Schema{}
+	}
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t.Kind() != reflect.Ptr && t.Implements(marshalerType):
+		// Types that marshal themselves, such as json.RawMessage, may be anything
+		return &Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		s := g.schema(t.Elem())
+		if typ, ok := s.Type.(string); ok {
+			s.Type = []string{typ, "null"}
+			return s
+		}
+		return &Schema{AnyOf: []*Schema{s, {Type: "null"}}}
+	case reflect.Bool:
+		return Boolean()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return Integer()
+	case reflect.Float32, reflect.Float64:
+		return Number()
+	case reflect.String:
+		return String()
+	case reflect.Slice, reflect.Array:
+		return Array(g.schema(t.Elem()))
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.object(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + g.component(t)}
+	default:
+		return &Schema{}
+	}
+}
+
+// component registers the schema of the named struct t, returning its name
+func (g *generator) component(t reflect.Type) string {
+	if name, ok := g.names[t]; ok {
+		return name
+	}
+
+	name := t.Name()
+	if _, taken := g.schemas[name]; taken {
+		// Types of the same name from different packages, such as models.Link and types.Link
+		pkg := t.PkgPath()
+		pkg = pkg[strings.LastIndex(pkg, "/")+1:]
+		name = strings.ToUpper(pkg[:1]) + pkg[1:] + name
+	}
+	g.names[t] = name
+	g.schemas[name] = &Schema{} // Reserved first, so recursive types refer to it
+	*g.schemas[name] = *g.object(t)
+	return name
+}
+
+// object is the schema of the struct t: a property for each field marshaled to
+// JSON, with embedded structs' fields inlined, constrained by its binding rules
+func (g *generator) object(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	g.fields(s, t)
+	return s
+}
+
+func (g *generator) fields(s *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			g.fields(s, f.Type)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		prop := g.schema(f.Type)
+		if required := bind(prop, f.Tag.Get("binding")); required {
+			s.Required = append(s.Required, name)
+		}
+		s.Properties[name] = prop
+	}
+}
+
+// bind constrains s by the validator rules of a binding tag, such as
+// required,oneof=read write, and reports whether the field is required.
+// Rules of referenced schemas are kept on those.
+func bind(s *Schema, rules string) (required bool) {
+	if rules == "" || s.Ref != "" {
+		return strings.Contains(rules, "required")
+	}
+
+	for _, rule := range strings.Split(rules, ",") {
+		key, value, _ := strings.Cut(rule, "=")
+		switch key {
+		case "required":
+			required = true
+		case "oneof":
+			for _, v := range strings.Fields(value) {
+				s.Enum = append(s.Enum, enumValue(s, v))
+			}
+		case "min", "gte":
+			limit(s, value, true)
+		case "max", "lte":
+			limit(s, value, false)
+		case "len":
+			limit(s, value, true)
+			limit(s, value, false)
+		case "email":
+			s.Format = "email"
+		case "url":
+			s.Format = "uri"
+		case "uuid":
+			s.Format = "uuid"
+		}
+	}
+	return required
+}
+
+// limit sets the minimum or maximum of s to value: its length for strings,
+// its number of items for arrays and its value for numbers
+func limit(s *Schema, value string, min bool) {
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return
+	}
+	count := int(n)
+
+	switch s.Type {
+	case "string":
+		if min {
+			s.MinLength = &count
+		} else {
+			s.MaxLength = &count
+		}
+	case "array":
+		if min {
+			s.MinItems = &count
+		} else {
+			s.MaxItems = &count
+		}
+	case "integer", "number":
+		if min {
+			s.Min(n)
+		} else {
+			s.Max(n)
+		}
+	}
+}
+
+// enumValue is a oneof value, as a number for numeric schemas
+func enumValue(s *Schema, v string) interface{} {
+	if s.Type == "integer" || s.Type == "number" {
+		if n, err := strconv.ParseFloat(v, 64); err == nil {
+			return n
+		}
+	}
+	return v
+}
@@ -0,0 +1,93 @@
+package outbox
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/yashjain/konnect/internal/repository"
+)
+
+// DeprecationScheduler deprecates versions once the date they were scheduled
+// to be deprecated on passes. Deprecating a version records its events and
+// queues its emails, like deprecating it through the API. It also reminds the
+// recipients of those emails once as the version's sunset date approaches.
+type DeprecationScheduler struct {
+	repo         repository.VersionRepository
+	interval     time.Duration
+	reminderLead time.Duration
+}
+
+// NewDeprecationScheduler returns a scheduler checking for due deprecations
+// every interval, and reminding of sunset dates reminderLead ahead of them
+func NewDeprecationScheduler(repo repository.VersionRepository, interval, reminderLead time.Duration) *DeprecationScheduler {
+	return &DeprecationScheduler{repo: repo, interval: interval, reminderLead: reminderLead}
+}
+
+// Run deprecates due versions and reminds of sunsets every interval until ctx
+// is done. A zero interval disables the scheduler.
+func (d *DeprecationScheduler) Run(ctx context.Context) {
+	if d.interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := d.Flush(ctx); err != nil && ctx.Err() == nil {
+				slog.Warn("Scheduled deprecation failed", "error", err)
+			}
+			if _, err := d.Remind(ctx); err != nil && ctx.Err() == nil {
+				slog.Warn("Sunset reminder failed", "error", err)
+			}
+		}
+	}
+}
+
+// Flush deprecates every version that is due and returns how many it deprecated
+func (d *DeprecationScheduler) Flush(ctx context.Context) (int, error) {
+	total := 0
+	for {
+		n, err := d.repo.ApplyDueDeprecations(ctx, time.Now().UTC(), defaultBatchSize)
+		total += n
+		if err != nil {
+			return total, err
+		}
+		if n > 0 {
+			slog.Info("Deprecated scheduled versions", "count", n)
+		}
+		if n < defaultBatchSize {
+			return total, nil
+		}
+	}
+}
+
+// Remind queues the reminders of every deprecated version whose sunset date is
+// less than the reminder lead away, and returns how many versions it reminded
+// of. A zero lead disables reminders.
+func (d *DeprecationScheduler) Remind(ctx context.Context) (int, error) {
+	if d.reminderLead <= 0 {
+		return 0, nil
+	}
+
+	total := 0
+	for {
+		now := time.Now().UTC()
+		n, err := d.repo.QueueSunsetReminders(ctx, now, now.Add(d.reminderLead), defaultBatchSize)
+		total += n
+		if err != nil {
+			return total, err
+		}
+		if n > 0 {
+			slog.Info("Reminded of version sunsets", "count", n)
+		}
+		if n < defaultBatchSize {
+			return total, nil
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/yashjain/konnect/internal/metrics"
+	"github.com/yashjain/konnect/internal/models"
+	"github.com/yashjain/konnect/internal/repository"
+	"github.com/yashjain/konnect/internal/webhook"
+	"github.com/yashjain/konnect/pkg/types"
+)
+
+// pruneInterval is how often settled deliveries past their retention are removed
+const pruneInterval = time.Hour
+
+// maxErrorLength bounds the error kept for a failed attempt
+const maxErrorLength = 512
+
+// RetryPolicy spaces out the attempts to deliver a webhook
+type RetryPolicy struct {
+	// MaxAttempts is the number of attempts before a delivery is marked failed
+	MaxAttempts int
+
+	// Base is the delay after the first failed attempt, doubled after each further one up to Max
+	Base time.Duration
+	Max  time.Duration
+}
+
+// delay returns how long to wait after the given number of failed attempts
+func (p RetryPolicy) delay(attempts int) time.Duration {
+	d := p.Base
+	for i := 1; i < attempts && d < p.Max; i++ {
+		d *= 2
+	}
+	return min(d, p.Max)
+}
+
+// Dispatcher delivers the events queued for webhook subscriptions. Each event
+// is POSTed as JSON to the subscription's URL, signed with its secret, until
+// it is answered with 2xx or runs out of attempts. Subscriptions in the slack
+// or teams format are sent a chat message about the event instead.
+type Dispatcher struct {
+	repo       repository.WebhookDeliveryRepository
+	services   repository.ServiceRepository
+	serviceURL string
+	client     *http.Client
+	interval   time.Duration
+	retention  time.Duration
+	retry      RetryPolicy
+}
+
+// NewDispatcher returns a dispatcher checking for due deliveries every interval,
+// bounding each attempt by timeout and keeping settled deliveries for retention.
+// Chat messages link to serviceURL with {id} and {slug} replaced by the
+// service's, and name services without a link when it is empty.
+func NewDispatcher(repo repository.WebhookDeliveryRepository, services repository.ServiceRepository, serviceURL string, interval, timeout, retention time.Duration, retry RetryPolicy) *Dispatcher {
+	return &Dispatcher{repo: repo, services: services, serviceURL: serviceURL, client: &http.Client{Timeout: timeout},
+		interval: interval, retention: retention, retry: retry}
+}
+
+// Run delivers due webhooks every interval until ctx is done. A zero interval disables the dispatcher.
+func (d *Dispatcher) Run(ctx context.Context) {
+	if d.interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+	lastPrune := time.Time{}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if _, err := d.Flush(ctx); err != nil && ctx.Err() == nil {
+				slog.Warn("Webhook dispatch failed", "error", err)
+			}
+			if d.retention > 0 && now.Sub(lastPrune) >= pruneInterval {
+				lastPrune = now
+				if _, err := d.repo.PruneWebhookDeliveries(ctx, now.Add(-d.retention)); err != nil && ctx.Err() == nil {
+					slog.Warn("Error pruning webhook deliveries", "error", err)
+				}
+			}
+		}
+	}
+}
+
+// Flush attempts every delivery that is due and returns how many succeeded.
+// A failure only delays the delivery that failed.
+func (d *Dispatcher) Flush(ctx context.Context) (int, error) {
+	due, err := d.repo.GetDueWebhookDeliveries(ctx, time.Now().UTC(), defaultBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	delivered := 0
+	for i := range due {
+		delivery := &due[i]
+		d.Attempt(ctx, delivery.URL, delivery.Secret, delivery)
+		if delivery.Status != models.DeliveryDelivered {
+			d.reschedule(delivery)
+		}
+		if err := d.repo.UpdateWebhookDelivery(ctx, delivery); err != nil {
+			return delivered, fmt.Errorf("recording webhook delivery %s: %w", delivery.ID, err)
+		}
+		if delivery.Status == models.DeliveryDelivered {
+			delivered++
+		}
+	}
+	return delivered, nil
+}
+
+// Attempt sends delivery to url once, signed with secret, and records the
+// outcome in it: delivered on a 2xx response, failed otherwise. The delivery
+// ID is sent as the delivery ID, so receivers can discard redeliveries.
+func (d *Dispatcher) Attempt(ctx context.Context, url, secret string, delivery *models.WebhookDelivery) {
+	delivery.Attempts++
+	delivery.NextAttemptAt = nil
+	status, err := d.post(ctx, url, secret, delivery)
+	delivery.ResponseStatus = status
+
+	if err != nil {
+		metrics.WebhookDeliveries.WithLabelValues("failed").Inc()
+		delivery.Status = models.DeliveryFailed
+		delivery.LastError = err.Error()
+		if len(delivery.LastError) > maxErrorLength {
+			delivery.LastError = delivery.LastError[:maxErrorLength]
+		}
+		return
+	}
+
+	metrics.WebhookDeliveries.WithLabelValues("delivered").Inc()
+	now := time.Now().UTC().Truncate(time.Second)
+	delivery.Status = models.DeliveryDelivered
+	delivery.LastError = ""
+	delivery.DeliveredAt = &now
+}
+
+// reschedule keeps a failed delivery pending until its next attempt, unless it
+// has run out of attempts
+func (d *Dispatcher) reschedule(delivery *models.WebhookDelivery) {
+	if delivery.Attempts >= d.retry.MaxAttempts {
+		slog.Warn("Webhook delivery failed", "delivery_id", delivery.ID, "subscription_id", delivery.SubscriptionID,
+			"attempts", delivery.Attempts, "error", delivery.LastError)
+		return
+	}
+	next := time.Now().UTC().Add(d.retry.delay(delivery.Attempts)).Truncate(time.Second)
+	delivery.Status = models.DeliveryPending
+	delivery.NextAttemptAt = &next
+}
+
+// post sends the delivery's payload, or its chat message, and returns the
+// response status, if any
+func (d *Dispatcher) post(ctx context.Context, url, secret string, delivery *models.WebhookDelivery) (int, error) {
+	body, err := d.body(ctx, delivery)
+	if err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(webhook.HeaderEvent, delivery.EventType)
+	webhook.SignRequest(req, secret, delivery.ID, body)
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			slog.WarnContext(ctx, "Error closing webhook response", "error", err)
+		}
+	}()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("webhook responded %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// body returns what is posted for a delivery: its payload, or the chat message
+// about its event for slack and teams subscriptions
+func (d *Dispatcher) body(ctx context.Context, delivery *models.WebhookDelivery) ([]byte, error) {
+	if delivery.Format == "" || delivery.Format == models.WebhookFormatJSON {
+		return delivery.Payload, nil
+	}
+
+	var msg webhook.Message
+	if err := json.Unmarshal(delivery.Payload, &msg.Event); err != nil {
+		return nil, fmt.Errorf("decoding event: %w", err)
+	}
+	switch {
+	case strings.HasPrefix(msg.Event.Type, "service."):
+		if err := json.Unmarshal(msg.Event.Payload, &msg.Service); err != nil {
+			return nil, fmt.Errorf("decoding service: %w", err)
+		}
+	case strings.HasPrefix(msg.Event.Type, "version."):
+		if err := json.Unmarshal(msg.Event.Payload, &msg.Version); err != nil {
+			return nil, fmt.Errorf("decoding version: %w", err)
+		}
+		service, err := d.services.GetServiceByID(ctx, msg.Event.OrgID, msg.Version.ServiceID, types.ReadOptions{IncludeDeleted: true})
+		if err != nil {
+			return nil, fmt.Errorf("reading service %s: %w", msg.Version.ServiceID, err)
+		}
+		msg.Service = *service
+	}
+	if d.serviceURL != "" && msg.Service.ID != "" {
+		msg.URL = strings.NewReplacer("{id}", msg.Service.ID, "{slug}", msg.Service.Slug).Replace(d.serviceURL)
+	}
+	return webhook.RenderChat(delivery.Format, delivery.Template, msg)
+}
@@ -0,0 +1,193 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/yashjain/konnect/internal/mail"
+	"github.com/yashjain/konnect/internal/metrics"
+	"github.com/yashjain/konnect/internal/models"
+	"github.com/yashjain/konnect/internal/repository"
+	"github.com/yashjain/konnect/pkg/types"
+)
+
+// errVersionGone settles the notifications of versions deleted before they were sent
+var errVersionGone = errors.New("the version no longer exists")
+
+// EmailNotifier sends the email notifications queued when versions are
+// deprecated or near their sunset date, retrying each one until it is sent or runs out of attempts
+type EmailNotifier struct {
+	repo       repository.NotificationRepository
+	services   repository.ServiceRepository
+	versions   repository.VersionRepository
+	sender     mail.Sender
+	serviceURL string
+	interval   time.Duration
+	retry      RetryPolicy
+}
+
+// NewEmailNotifier returns a notifier sending emails through sender, linking
+// to serviceURL with {id} and {slug} replaced by the service's when it is
+// set, and checking for due notifications every interval
+func NewEmailNotifier(repo repository.NotificationRepository, services repository.ServiceRepository, versions repository.VersionRepository, sender mail.Sender, serviceURL string, interval time.Duration, retry RetryPolicy) *EmailNotifier {
+	return &EmailNotifier{repo: repo, services: services, versions: versions, sender: sender, serviceURL: serviceURL, interval: interval, retry: retry}
+}
+
+// Run sends due notifications every interval until ctx is done. A zero interval disables the notifier.
+func (n *EmailNotifier) Run(ctx context.Context) {
+	if n.interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(n.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := n.Flush(ctx); err != nil && ctx.Err() == nil {
+				slog.Warn("Email notification failed", "error", err)
+			}
+		}
+	}
+}
+
+// Flush attempts every notification that is due and returns how many were
+// sent. A failure only delays the notification that failed.
+func (n *EmailNotifier) Flush(ctx context.Context) (int, error) {
+	due, err := n.repo.GetDueEmailNotifications(ctx, time.Now().UTC(), defaultBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for i := range due {
+		notification := &due[i]
+		n.attempt(ctx, notification)
+		if err := n.repo.UpdateEmailNotification(ctx, notification); err != nil {
+			return sent, fmt.Errorf("recording email notification %s: %w", notification.ID, err)
+		}
+		if notification.Status == models.NotificationSent {
+			sent++
+		}
+	}
+	return sent, nil
+}
+
+// attempt sends a notification once and records the outcome in it: sent, or
+// failed and rescheduled while attempts remain
+func (n *EmailNotifier) attempt(ctx context.Context, notification *models.EmailNotification) {
+	notification.Attempts++
+	notification.NextAttemptAt = nil
+
+	if err := n.send(ctx, notification); err != nil {
+		metrics.EmailNotifications.WithLabelValues("failed").Inc()
+		notification.Status = models.NotificationFailed
+		notification.LastError = err.Error()
+		if len(notification.LastError) > maxErrorLength {
+			notification.LastError = notification.LastError[:maxErrorLength]
+		}
+		if !errors.Is(err, errVersionGone) {
+			n.reschedule(notification)
+		}
+		return
+	}
+
+	metrics.EmailNotifications.WithLabelValues("sent").Inc()
+	now := time.Now().UTC().Truncate(time.Second)
+	notification.Status = models.NotificationSent
+	notification.LastError = ""
+	notification.SentAt = &now
+}
+
+// reschedule keeps a failed notification pending until its next attempt,
+// unless it has run out of attempts
+func (n *EmailNotifier) reschedule(notification *models.EmailNotification) {
+	if notification.Attempts >= n.retry.MaxAttempts {
+		slog.Warn("Email notification failed", "notification_id", notification.ID, "user_id", notification.UserID,
+			"attempts", notification.Attempts, "error", notification.LastError)
+		return
+	}
+	next := time.Now().UTC().Add(n.retry.delay(notification.Attempts)).Truncate(time.Second)
+	notification.Status = models.NotificationPending
+	notification.NextAttemptAt = &next
+}
+
+// send emails the notification about a version as it is now
+func (n *EmailNotifier) send(ctx context.Context, notification *models.EmailNotification) error {
+	service, err := n.services.GetServiceByID(ctx, notification.OrgID, notification.ServiceID, types.ReadOptions{IncludeDeleted: true})
+	if errors.Is(err, sql.ErrNoRows) {
+		return errVersionGone
+	}
+	if err != nil {
+		return err
+	}
+	version, err := n.versions.GetVersion(ctx, notification.OrgID, notification.ServiceID, notification.VersionID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errVersionGone
+	}
+	if err != nil {
+		return err
+	}
+
+	subject, body := deprecationEmail(service, version, n.link(service))
+	if notification.EventType == models.NotificationSunsetReminder {
+		subject, body = sunsetReminderEmail(service, version, n.link(service))
+	}
+	return n.sender.Send(ctx, notification.Email, subject, body)
+}
+
+// link returns the URL of a service's page, or "" when none is configured
+func (n *EmailNotifier) link(service *models.Service) string {
+	if n.serviceURL == "" {
+		return ""
+	}
+	return strings.NewReplacer("{id}", service.ID, "{slug}", service.Slug).Replace(n.serviceURL)
+}
+
+// deprecationEmail returns the subject and body of the email about a deprecated version
+func deprecationEmail(service *models.Service, version *models.Version, url string) (string, string) {
+	subject := fmt.Sprintf("%s %s is deprecated", service.Name, version.Semver)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Version %s of %s has been deprecated. Plan to move to a newer version.\n", version.Semver, service.Name)
+	if version.SunsetAt != nil {
+		fmt.Fprintf(&b, "It will be sunset on %s and no longer supported after that.\n", version.SunsetAt.UTC().Format("January 2, 2006"))
+	}
+	if version.Changelog != "" {
+		fmt.Fprintf(&b, "\nChangelog:\n%s\n", version.Changelog)
+	}
+	if url != "" {
+		fmt.Fprintf(&b, "\n%s\n", url)
+	}
+	fmt.Fprintf(&b, "\nYou are receiving this email because you own, consume or are subscribed to %s. "+
+		"Deprecation emails can be turned off in your notification preferences.\n", service.Name)
+	return subject, b.String()
+}
+
+// sunsetReminderEmail returns the subject and body of the email reminding that
+// a deprecated version is about to be sunset
+func sunsetReminderEmail(service *models.Service, version *models.Version, url string) (string, string) {
+	if version.SunsetAt == nil {
+		return deprecationEmail(service, version, url)
+	}
+	sunset := version.SunsetAt.UTC().Format("January 2, 2006")
+	subject := fmt.Sprintf("%s %s will be sunset on %s", service.Name, version.Semver, sunset)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Version %s of %s is deprecated and will be sunset on %s, after which it is no longer supported. "+
+		"Move to a newer version before then.\n", version.Semver, service.Name, sunset)
+	if url != "" {
+		fmt.Fprintf(&b, "\n%s\n", url)
+	}
+	fmt.Fprintf(&b, "\nYou are receiving this email because you own, consume or are subscribed to %s. "+
+		"Deprecation emails can be turned off in your notification preferences.\n", service.Name)
+	return subject, b.String()
+}
@@ -0,0 +1,188 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/yashjain/konnect/internal/filter"
+	"github.com/yashjain/konnect/internal/kong"
+	"github.com/yashjain/konnect/internal/metrics"
+	"github.com/yashjain/konnect/internal/models"
+	"github.com/yashjain/konnect/internal/repository"
+	"github.com/yashjain/konnect/pkg/types"
+)
+
+// KongTag tags every Kong service and route synced from the catalog
+const KongTag = "konnect"
+
+// kongVersionTag prefixes the tag carrying the semver of a service's newest released version
+const kongVersionTag = "konnect-version:"
+
+// releasedVersions filters versions down to released ones
+var releasedVersions = []types.FilterCondition{{Field: "status", Operator: filter.OpEqual, Values: []interface{}{models.VersionReleased}}}
+
+// KongSyncer publishes services to the Kong Gateway through its Admin API.
+// Each service becomes a Kong service proxying to its upstream URL and a route
+// matching /<slug>, both with the service's ID and tagged with its tags, KongTag
+// and the semver of its newest released version; deleted services are removed.
+// Syncs are queued with every change to a service or its versions and retried
+// until they succeed or run out of attempts.
+type KongSyncer struct {
+	syncs    repository.KongSyncRepository
+	services repository.ServiceRepository
+	versions repository.VersionRepository
+	client   *kong.Client
+	upstream string
+	interval time.Duration
+	retry    RetryPolicy
+}
+
+// NewKongSyncer returns a syncer pushing services through client, proxying to
+// upstream with {slug} replaced by each service's slug, and checking for due
+// syncs every interval
+func NewKongSyncer(syncs repository.KongSyncRepository, services repository.ServiceRepository, versions repository.VersionRepository, client *kong.Client, upstream string, interval time.Duration, retry RetryPolicy) *KongSyncer {
+	return &KongSyncer{syncs: syncs, services: services, versions: versions, client: client, upstream: upstream, interval: interval, retry: retry}
+}
+
+// Run syncs due services every interval until ctx is done. A zero interval disables the syncer.
+func (k *KongSyncer) Run(ctx context.Context) {
+	if k.interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(k.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := k.Flush(ctx); err != nil && ctx.Err() == nil {
+				slog.Warn("Kong sync failed", "error", err)
+			}
+		}
+	}
+}
+
+// Flush attempts every sync that is due and returns how many succeeded. A
+// failure only delays the sync that failed.
+func (k *KongSyncer) Flush(ctx context.Context) (int, error) {
+	due, err := k.syncs.GetDueKongSyncs(ctx, time.Now().UTC(), defaultBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	synced := 0
+	for i := range due {
+		sync := &due[i]
+		k.attempt(ctx, sync)
+		if _, err := k.syncs.UpdateKongSync(ctx, sync); err != nil {
+			return synced, fmt.Errorf("recording Kong sync of %s: %w", sync.ServiceID, err)
+		}
+		if sync.Status == models.KongSyncSynced {
+			synced++
+		}
+	}
+	return synced, nil
+}
+
+// Sync queues a service within an organization and syncs it straight away,
+// returning the outcome. It returns sql.ErrNoRows when the service is not in
+// the organization.
+func (k *KongSyncer) Sync(ctx context.Context, orgID, serviceID string) (*models.KongSync, error) {
+	if err := k.syncs.QueueKongSync(ctx, orgID, serviceID); err != nil {
+		return nil, err
+	}
+	sync, err := k.syncs.GetKongSync(ctx, orgID, serviceID)
+	if err != nil {
+		return nil, err
+	}
+
+	k.attempt(ctx, sync)
+	if _, err := k.syncs.UpdateKongSync(ctx, sync); err != nil {
+		return nil, err
+	}
+	return sync, nil
+}
+
+// attempt pushes the service of sync to Kong once and records the outcome in
+// it: synced, or failed and rescheduled while attempts remain
+func (k *KongSyncer) attempt(ctx context.Context, sync *models.KongSync) {
+	sync.Attempts++
+	sync.NextAttemptAt = nil
+
+	if err := k.push(ctx, sync.OrgID, sync.ServiceID); err != nil {
+		metrics.KongSyncs.WithLabelValues("failed").Inc()
+		sync.Status = models.KongSyncFailed
+		sync.LastError = err.Error()
+		if len(sync.LastError) > maxErrorLength {
+			sync.LastError = sync.LastError[:maxErrorLength]
+		}
+		k.reschedule(sync)
+		return
+	}
+
+	metrics.KongSyncs.WithLabelValues("synced").Inc()
+	now := time.Now().UTC().Truncate(time.Second)
+	sync.Status = models.KongSyncSynced
+	sync.LastError = ""
+	sync.SyncedAt = &now
+}
+
+// reschedule keeps a failed sync pending until its next attempt, unless it has
+// run out of attempts
+func (k *KongSyncer) reschedule(sync *models.KongSync) {
+	if sync.Attempts >= k.retry.MaxAttempts {
+		slog.Warn("Kong sync failed", "service_id", sync.ServiceID, "attempts", sync.Attempts, "error", sync.LastError)
+		return
+	}
+	next := time.Now().UTC().Add(k.retry.delay(sync.Attempts)).Truncate(time.Second)
+	sync.Status = models.KongSyncPending
+	sync.NextAttemptAt = &next
+}
+
+// push makes Kong match a service as it is now: its Kong service and route are
+// created or replaced, or deleted with the service
+func (k *KongSyncer) push(ctx context.Context, orgID, serviceID string) error {
+	service, err := k.services.GetServiceByID(ctx, orgID, serviceID, types.ReadOptions{IncludeDeleted: true})
+	if err != nil {
+		return err
+	}
+	if service.DeletedAt != nil {
+		// Kong refuses to delete a service that routes still point to
+		if err := k.client.DeleteRoute(ctx, service.ID); err != nil {
+			return err
+		}
+		return k.client.DeleteService(ctx, service.ID)
+	}
+
+	versions, _, err := k.versions.GetVersions(ctx, orgID, serviceID, types.PaginationParams{Page: 1, PageSize: 1, SkipCount: true, Filter: releasedVersions})
+	if err != nil {
+		return err
+	}
+	tags := append([]string{KongTag}, service.Tags...)
+	if len(versions) > 0 {
+		tags = append(tags, kongVersionTag+versions[0].Semver)
+	}
+
+	err = k.client.PutService(ctx, kong.Service{
+		ID:   service.ID,
+		Name: service.Slug,
+		URL:  strings.ReplaceAll(k.upstream, "{slug}", service.Slug),
+		Tags: tags,
+	})
+	if err != nil {
+		return err
+	}
+	return k.client.PutRoute(ctx, kong.Route{
+		ID:      service.ID,
+		Name:    service.Slug,
+		Paths:   []string{"/" + service.Slug},
+		Service: &kong.Reference{ID: service.ID},
+		Tags:    tags,
+	})
+}
@@ -0,0 +1,97 @@
+// Package outbox delivers the change events the store records in its outbox
+// table, and to webhook subscriptions, and syncs the services they change to
+// Kong. Events are written in the same transaction as the change they describe
+// and only settled once delivered, so a crash between the two loses nothing; it
+// may deliver an event twice, and receivers should deduplicate by delivery ID.
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/yashjain/konnect/internal/metrics"
+	"github.com/yashjain/konnect/internal/models"
+	"github.com/yashjain/konnect/internal/repository"
+)
+
+// defaultBatchSize is used when a relay is created without a batch size
+const defaultBatchSize = 100
+
+// Publisher delivers events to a webhook or message broker
+type Publisher interface {
+	// Publish delivers one event; an error leaves it in the outbox to be retried
+	Publish(ctx context.Context, event models.Event) error
+}
+
+// Relay moves events from the outbox to a Publisher
+type Relay struct {
+	repo      repository.OutboxRepository
+	publisher Publisher
+	interval  time.Duration
+	batchSize int
+}
+
+// NewRelay returns a relay that checks the outbox every interval and reads up to
+// batchSize events at a time
+func NewRelay(repo repository.OutboxRepository, publisher Publisher, interval time.Duration, batchSize int) *Relay {
+	if batchSize < 1 {
+		batchSize = defaultBatchSize
+	}
+	return &Relay{repo: repo, publisher: publisher, interval: interval, batchSize: batchSize}
+}
+
+// Run delivers pending events every interval until ctx is done. A zero interval disables the relay.
+func (r *Relay) Run(ctx context.Context) {
+	if r.interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := r.Flush(ctx); err != nil && ctx.Err() == nil {
+				slog.Warn("Outbox delivery failed", "error", err)
+			}
+		}
+	}
+}
+
+// Flush delivers pending events in the order they were recorded until the outbox
+// is empty or a delivery fails, and returns how many were delivered. Stopping at
+// the first failure keeps later events about the same service from overtaking it.
+func (r *Relay) Flush(ctx context.Context) (int, error) {
+	delivered := 0
+	for {
+		events, err := r.repo.GetPendingEvents(ctx, r.batchSize)
+		if err != nil {
+			return delivered, err
+		}
+
+		for _, event := range events {
+			if err := r.publisher.Publish(ctx, event); err != nil {
+				metrics.OutboxDeliveries.WithLabelValues("failed").Inc()
+				if recordErr := r.repo.RecordEventFailure(ctx, event.ID, err.Error()); recordErr != nil {
+					slog.Error("Error recording outbox failure", "event_id", event.ID, "error", recordErr)
+				}
+				return delivered, fmt.Errorf("event %s: %w", event.ID, err)
+			}
+			metrics.OutboxDeliveries.WithLabelValues("delivered").Inc()
+
+			if err := r.repo.DeleteEvent(ctx, event.ID); err != nil {
+				return delivered, err
+			}
+			delivered++
+		}
+
+		if len(events) < r.batchSize {
+			return delivered, nil
+		}
+	}
+}
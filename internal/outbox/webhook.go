@@ -0,0 +1,68 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/yashjain/konnect/internal/models"
+	"github.com/yashjain/konnect/internal/webhook"
+)
+
+// webhookTimeout bounds a single delivery, so a hung receiver cannot stall the relay
+const webhookTimeout = 10 * time.Second
+
+// WebhookPublisher POSTs each event as JSON to a URL. Any response other than
+// 2xx is a failed delivery.
+type WebhookPublisher struct {
+	url    string
+	secret string
+	client *http.Client
+}
+
+// NewWebhookPublisher returns a publisher posting to url, signing deliveries
+// with secret as described in the webhook package when it is set
+func NewWebhookPublisher(url, secret string) *WebhookPublisher {
+	return &WebhookPublisher{url: url, secret: secret, client: &http.Client{Timeout: webhookTimeout}}
+}
+
+// Publish implements Publisher. The event ID is sent as the delivery ID, so
+// receivers can discard redeliveries.
+func (p *WebhookPublisher) Publish(ctx context.Context, event models.Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if p.secret != "" {
+		webhook.SignRequest(req, p.secret, event.ID, body)
+	} else {
+		req.Header.Set(webhook.HeaderID, event.ID)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			slog.WarnContext(ctx, "Error closing webhook response", "error", err)
+		}
+	}()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook responded %s", resp.Status)
+	}
+	return nil
+}
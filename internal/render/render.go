@@ -0,0 +1,86 @@
+// Package render serializes the JSON documents handlers respond with in other
+// media types, so that clients can negotiate them through the Accept header
+package render
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+)
+
+// Renderer writes JSON documents in another media type
+type Renderer interface {
+	// MediaTypes are the media types clients ask for the renderer by in Accept
+	MediaTypes() []string
+
+	// ContentType is the Content-Type of a response of jsonType, application/json
+	// or application/problem+json, once rendered
+	ContentType(jsonType string) string
+
+	// Render writes doc, a document read by Decode, as a response of contentType
+	Render(w io.Writer, contentType string, doc interface{}) error
+}
+
+// Object is a decoded JSON object, which keeps its members in order
+type Object []Member
+
+// Member is a member of an Object
+type Member struct {
+	Key   string
+	Value interface{}
+}
+
+// Decode reads a JSON document into Objects, []interface{}, strings,
+// json.Numbers, bools and nils
+func Decode(data []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	doc, err := decodeValue(dec)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, errors.New("render: trailing data after JSON document")
+	}
+	return doc, nil
+}
+
+// decodeValue reads the next value of dec
+func decodeValue(dec *json.Decoder) (interface{}, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch tok {
+	case json.Delim('{'):
+		obj := Object{}
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := decodeValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			obj = append(obj, Member{Key: key.(string), Value: value})
+		}
+		_, err := dec.Token()
+		return obj, err
+	case json.Delim('['):
+		arr := []interface{}{}
+		for dec.More() {
+			value, err := decodeValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, value)
+		}
+		_, err := dec.Token()
+		return arr, err
+	default:
+		return tok, nil
+	}
+}
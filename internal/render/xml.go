@@ -0,0 +1,117 @@
+package render
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+)
+
+// Media types of XML documents
+const (
+	MediaTypeXML        = "application/xml"
+	MediaTypeProblemXML = "application/problem+xml"
+)
+
+// problemNamespace is the namespace of RFC 7807 problem details in XML
+const problemNamespace = "urn:ietf:rfc:7807"
+
+// XML renders documents as XML, for consumers that cannot read JSON. The
+// document is a <response> element, or a <problem> element in the RFC 7807
+// namespace for problem details. Object members become elements named after
+// their keys, or <entry key="..."> elements when the key is not a valid name;
+// array items become <item> elements; nulls are left out.
+type XML struct{}
+
+// MediaTypes implements Renderer
+func (XML) MediaTypes() []string {
+	return []string{MediaTypeXML, "text/xml"}
+}
+
+// ContentType implements Renderer
+func (XML) ContentType(jsonType string) string {
+	if jsonType == "application/problem+json" {
+		return MediaTypeProblemXML + "; charset=utf-8"
+	}
+	return MediaTypeXML + "; charset=utf-8"
+}
+
+// Render implements Renderer
+func (XML) Render(w io.Writer, contentType string, doc interface{}) error {
+	root := xml.StartElement{Name: xml.Name{Local: "response"}}
+	if strings.HasPrefix(contentType, MediaTypeProblemXML) {
+		root = xml.StartElement{Name: xml.Name{Space: problemNamespace, Local: "problem"}}
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	if err := encodeXML(enc, root, doc); err != nil {
+		return err
+	}
+	return enc.Flush()
+}
+
+// encodeXML writes v as the element start
+func encodeXML(enc *xml.Encoder, start xml.StartElement, v interface{}) error {
+	if v == nil {
+		return nil
+	}
+	if err := enc.EncodeToken(start); err != nil {
+		return err
+	}
+
+	switch v := v.(type) {
+	case Object:
+		for _, member := range v {
+			child := xml.StartElement{Name: xml.Name{Local: member.Key}}
+			if !validXMLName(member.Key) {
+				child = xml.StartElement{
+					Name: xml.Name{Local: "entry"},
+					Attr: []xml.Attr{{Name: xml.Name{Local: "key"}, Value: member.Key}},
+				}
+			}
+			if err := encodeXML(enc, child, member.Value); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			if err := encodeXML(enc, xml.StartElement{Name: xml.Name{Local: "item"}}, item); err != nil {
+				return err
+			}
+		}
+	case string:
+		if err := enc.EncodeToken(xml.CharData(v)); err != nil {
+			return err
+		}
+	case json.Number, bool:
+		if err := enc.EncodeToken(xml.CharData(fmt.Sprint(v))); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("render: cannot encode %T as XML", v)
+	}
+
+	return enc.EncodeToken(start.End())
+}
+
+// validXMLName reports whether a key can be used as an element name as is:
+// a letter or _ followed by letters, digits, _, - and ., not starting with xml
+func validXMLName(name string) bool {
+	if name == "" || strings.HasPrefix(strings.ToLower(name), "xml") {
+		return false
+	}
+	for i, r := range name {
+		switch {
+		case unicode.IsLetter(r) || r == '_':
+		case i > 0 && (unicode.IsDigit(r) || r == '-' || r == '.'):
+		default:
+			return false
+		}
+	}
+	return true
+}
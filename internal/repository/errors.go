@@ -0,0 +1,34 @@
+package repository
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrConflict is returned by writes that would break a uniqueness constraint,
+// such as a second service with the same name or slug in an organization
+var ErrConflict = errors.New("conflicts with an existing row")
+
+// ErrInvalidParent is returned by writes to a tree naming a parent that does
+// not exist, or that would make a row its own ancestor
+var ErrInvalidParent = errors.New("invalid parent")
+
+// ErrInUse is returned by deletes of a row that others still depend on, such
+// as a category with subcategories
+var ErrInUse = errors.New("in use by other rows")
+
+// ErrInvalidSchedule is returned by schedules that end before they start, such
+// as a version sunset before it is deprecated
+var ErrInvalidSchedule = errors.New("invalid schedule")
+
+// UnavailableError is returned without reaching the database while it is
+// considered down, so callers can fail fast and ask clients to retry later
+type UnavailableError struct {
+	// RetryAfter is how long until the database will be tried again
+	RetryAfter time.Duration
+}
+
+// Error implements error
+func (e *UnavailableError) Error() string {
+	return "database unavailable; retry in " + e.RetryAfter.String()
+}
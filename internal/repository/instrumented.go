@@ -0,0 +1,521 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/yashjain/konnect/internal/auth"
+	"github.com/yashjain/konnect/internal/metrics"
+	"github.com/yashjain/konnect/internal/models"
+	"github.com/yashjain/konnect/pkg/types"
+)
+
+// InstrumentedRepository times every call to the repository it wraps, by
+// method, in the repository_call_duration_seconds histogram, whose count is
+// the number of calls, and counts the calls that fail in repository_errors_total.
+// sql.ErrNoRows is not counted as a failure, as it answers lookups of missing
+// rows. Comparing these across a release pinpoints the calls that regressed.
+// Each method is the wrapped repository's, timed; Health and Close are not.
+type InstrumentedRepository struct {
+	Repository
+}
+
+// NewInstrumentedRepository wraps repo to record metrics of each call
+func NewInstrumentedRepository(repo Repository) *InstrumentedRepository {
+	return &InstrumentedRepository{Repository: repo}
+}
+
+// observe records a call to method that started at start and failed with *err, if not nil
+func observe(method string, start time.Time, err *error) {
+	metrics.RepositoryCallDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+	if *err != nil && !errors.Is(*err, sql.ErrNoRows) {
+		metrics.RepositoryErrors.WithLabelValues(method).Inc()
+	}
+}
+
+func (r *InstrumentedRepository) GetServices(ctx context.Context, p auth.Principal, params types.PaginationParams) (_ []models.Service, _ int, err error) {
+	defer observe("GetServices", time.Now(), &err)
+	return r.Repository.GetServices(ctx, p, params)
+}
+
+func (r *InstrumentedRepository) SearchServices(ctx context.Context, p auth.Principal, params types.SearchParams) (_ []models.Service, _ int, err error) {
+	defer observe("SearchServices", time.Now(), &err)
+	return r.Repository.SearchServices(ctx, p, params)
+}
+
+func (r *InstrumentedRepository) SearchFacets(ctx context.Context, p auth.Principal, params types.SearchParams, facets []string) (_ map[string][]types.FacetBucket, err error) {
+	defer observe("SearchFacets", time.Now(), &err)
+	return r.Repository.SearchFacets(ctx, p, params, facets)
+}
+
+func (r *InstrumentedRepository) SuggestServices(ctx context.Context, p auth.Principal, prefix string, limit int) (_ []models.ServiceSuggestion, err error) {
+	defer observe("SuggestServices", time.Now(), &err)
+	return r.Repository.SuggestServices(ctx, p, prefix, limit)
+}
+
+func (r *InstrumentedRepository) GetServicesByIDs(ctx context.Context, p auth.Principal, ids []string) (_ []models.Service, err error) {
+	defer observe("GetServicesByIDs", time.Now(), &err)
+	return r.Repository.GetServicesByIDs(ctx, p, ids)
+}
+
+func (r *InstrumentedRepository) CreateService(ctx context.Context, service *models.Service, grants ...models.ServiceACL) (err error) {
+	defer observe("CreateService", time.Now(), &err)
+	return r.Repository.CreateService(ctx, service, grants...)
+}
+
+func (r *InstrumentedRepository) GetServiceByID(ctx context.Context, orgID, id string, opts ...types.ReadOptions) (_ *models.Service, err error) {
+	defer observe("GetServiceByID", time.Now(), &err)
+	return r.Repository.GetServiceByID(ctx, orgID, id, opts...)
+}
+
+func (r *InstrumentedRepository) GetServiceBySlug(ctx context.Context, orgID, slug string, opts ...types.ReadOptions) (_ *models.Service, err error) {
+	defer observe("GetServiceBySlug", time.Now(), &err)
+	return r.Repository.GetServiceBySlug(ctx, orgID, slug, opts...)
+}
+
+func (r *InstrumentedRepository) UpdateService(ctx context.Context, orgID, id string, service *models.Service) (_ int64, err error) {
+	defer observe("UpdateService", time.Now(), &err)
+	return r.Repository.UpdateService(ctx, orgID, id, service)
+}
+
+func (r *InstrumentedRepository) DeleteService(ctx context.Context, orgID, id string) (_ int64, err error) {
+	defer observe("DeleteService", time.Now(), &err)
+	return r.Repository.DeleteService(ctx, orgID, id)
+}
+
+func (r *InstrumentedRepository) GetVersions(ctx context.Context, orgID, serviceID string, params types.PaginationParams) (_ []models.Version, _ int, err error) {
+	defer observe("GetVersions", time.Now(), &err)
+	return r.Repository.GetVersions(ctx, orgID, serviceID, params)
+}
+
+func (r *InstrumentedRepository) CreateVersion(ctx context.Context, orgID string, version *models.Version) (err error) {
+	defer observe("CreateVersion", time.Now(), &err)
+	return r.Repository.CreateVersion(ctx, orgID, version)
+}
+
+func (r *InstrumentedRepository) GetVersion(ctx context.Context, orgID, serviceID, id string) (_ *models.Version, err error) {
+	defer observe("GetVersion", time.Now(), &err)
+	return r.Repository.GetVersion(ctx, orgID, serviceID, id)
+}
+
+func (r *InstrumentedRepository) GetVersionBySemver(ctx context.Context, orgID, serviceID, semver string) (_ *models.Version, err error) {
+	defer observe("GetVersionBySemver", time.Now(), &err)
+	return r.Repository.GetVersionBySemver(ctx, orgID, serviceID, semver)
+}
+
+func (r *InstrumentedRepository) UpdateVersion(ctx context.Context, orgID, serviceID, id string, version *models.Version) (_ int64, err error) {
+	defer observe("UpdateVersion", time.Now(), &err)
+	return r.Repository.UpdateVersion(ctx, orgID, serviceID, id, version)
+}
+
+func (r *InstrumentedRepository) ScheduleDeprecation(ctx context.Context, orgID, serviceID, id string, deprecatedAt time.Time, sunsetAt *time.Time) (_ *models.Version, err error) {
+	defer observe("ScheduleDeprecation", time.Now(), &err)
+	return r.Repository.ScheduleDeprecation(ctx, orgID, serviceID, id, deprecatedAt, sunsetAt)
+}
+
+func (r *InstrumentedRepository) CancelDeprecation(ctx context.Context, orgID, serviceID, id string) (_ int64, err error) {
+	defer observe("CancelDeprecation", time.Now(), &err)
+	return r.Repository.CancelDeprecation(ctx, orgID, serviceID, id)
+}
+
+func (r *InstrumentedRepository) ApplyDueDeprecations(ctx context.Context, now time.Time, limit int) (_ int, err error) {
+	defer observe("ApplyDueDeprecations", time.Now(), &err)
+	return r.Repository.ApplyDueDeprecations(ctx, now, limit)
+}
+
+func (r *InstrumentedRepository) QueueSunsetReminders(ctx context.Context, now, before time.Time, limit int) (_ int, err error) {
+	defer observe("QueueSunsetReminders", time.Now(), &err)
+	return r.Repository.QueueSunsetReminders(ctx, now, before, limit)
+}
+
+func (r *InstrumentedRepository) SearchVersions(ctx context.Context, p auth.Principal, query string, limit int) (_ []models.Version, err error) {
+	defer observe("SearchVersions", time.Now(), &err)
+	return r.Repository.SearchVersions(ctx, p, query, limit)
+}
+
+func (r *InstrumentedRepository) GetReleasedVersions(ctx context.Context, p auth.Principal, serviceID string, limit int) (_ []models.Version, err error) {
+	defer observe("GetReleasedVersions", time.Now(), &err)
+	return r.Repository.GetReleasedVersions(ctx, p, serviceID, limit)
+}
+
+func (r *InstrumentedRepository) GetDeprecationCalendar(ctx context.Context, p auth.Principal, teamID, serviceID string, since time.Time, limit int) (_ []models.Version, err error) {
+	defer observe("GetDeprecationCalendar", time.Now(), &err)
+	return r.Repository.GetDeprecationCalendar(ctx, p, teamID, serviceID, since, limit)
+}
+
+func (r *InstrumentedRepository) GetServiceVisibility(ctx context.Context, orgID, serviceID string) (_ string, err error) {
+	defer observe("GetServiceVisibility", time.Now(), &err)
+	return r.Repository.GetServiceVisibility(ctx, orgID, serviceID)
+}
+
+func (r *InstrumentedRepository) GetServicePermission(ctx context.Context, p auth.Principal, serviceID string) (_ string, err error) {
+	defer observe("GetServicePermission", time.Now(), &err)
+	return r.Repository.GetServicePermission(ctx, p, serviceID)
+}
+
+func (r *InstrumentedRepository) CreateServiceACL(ctx context.Context, orgID string, acl *models.ServiceACL) (err error) {
+	defer observe("CreateServiceACL", time.Now(), &err)
+	return r.Repository.CreateServiceACL(ctx, orgID, acl)
+}
+
+func (r *InstrumentedRepository) GetServiceACLs(ctx context.Context, orgID, serviceID string) (_ []models.ServiceACL, err error) {
+	defer observe("GetServiceACLs", time.Now(), &err)
+	return r.Repository.GetServiceACLs(ctx, orgID, serviceID)
+}
+
+func (r *InstrumentedRepository) DeleteServiceACL(ctx context.Context, orgID, serviceID, aclID string) (_ int64, err error) {
+	defer observe("DeleteServiceACL", time.Now(), &err)
+	return r.Repository.DeleteServiceACL(ctx, orgID, serviceID, aclID)
+}
+
+func (r *InstrumentedRepository) SubjectInOrg(ctx context.Context, orgID, subjectType, subjectID string) (_ bool, err error) {
+	defer observe("SubjectInOrg", time.Now(), &err)
+	return r.Repository.SubjectInOrg(ctx, orgID, subjectType, subjectID)
+}
+
+func (r *InstrumentedRepository) CreateOrganization(ctx context.Context, org *models.Organization) (err error) {
+	defer observe("CreateOrganization", time.Now(), &err)
+	return r.Repository.CreateOrganization(ctx, org)
+}
+
+func (r *InstrumentedRepository) GetOrganizations(ctx context.Context) (_ []models.Organization, err error) {
+	defer observe("GetOrganizations", time.Now(), &err)
+	return r.Repository.GetOrganizations(ctx)
+}
+
+func (r *InstrumentedRepository) CreateAPIToken(ctx context.Context, token *models.APIToken, tokenHash string) (err error) {
+	defer observe("CreateAPIToken", time.Now(), &err)
+	return r.Repository.CreateAPIToken(ctx, token, tokenHash)
+}
+
+func (r *InstrumentedRepository) GetPrincipalByTokenHash(ctx context.Context, tokenHash string) (_ auth.Principal, err error) {
+	defer observe("GetPrincipalByTokenHash", time.Now(), &err)
+	return r.Repository.GetPrincipalByTokenHash(ctx, tokenHash)
+}
+
+func (r *InstrumentedRepository) CreateUser(ctx context.Context, user *models.User, passwordHash string) (err error) {
+	defer observe("CreateUser", time.Now(), &err)
+	return r.Repository.CreateUser(ctx, user, passwordHash)
+}
+
+func (r *InstrumentedRepository) CreateTeam(ctx context.Context, team *models.Team) (err error) {
+	defer observe("CreateTeam", time.Now(), &err)
+	return r.Repository.CreateTeam(ctx, team)
+}
+
+func (r *InstrumentedRepository) AddTeamMember(ctx context.Context, orgID, teamID, userID string) (_ int64, err error) {
+	defer observe("AddTeamMember", time.Now(), &err)
+	return r.Repository.AddTeamMember(ctx, orgID, teamID, userID)
+}
+
+func (r *InstrumentedRepository) GetTeamIDsForUser(ctx context.Context, userID string) (_ []string, err error) {
+	defer observe("GetTeamIDsForUser", time.Now(), &err)
+	return r.Repository.GetTeamIDsForUser(ctx, userID)
+}
+
+func (r *InstrumentedRepository) GetUserCredentials(ctx context.Context, email string) (userID, orgID, passwordHash string, err error) {
+	defer observe("GetUserCredentials", time.Now(), &err)
+	return r.Repository.GetUserCredentials(ctx, email)
+}
+
+func (r *InstrumentedRepository) GetUserOrgID(ctx context.Context, userID string) (_ string, err error) {
+	defer observe("GetUserOrgID", time.Now(), &err)
+	return r.Repository.GetUserOrgID(ctx, userID)
+}
+
+func (r *InstrumentedRepository) CreateSession(ctx context.Context, session *models.Session, refreshHash string) (err error) {
+	defer observe("CreateSession", time.Now(), &err)
+	return r.Repository.CreateSession(ctx, session, refreshHash)
+}
+
+func (r *InstrumentedRepository) GetSessionByRefreshHash(ctx context.Context, refreshHash string) (_ *models.Session, err error) {
+	defer observe("GetSessionByRefreshHash", time.Now(), &err)
+	return r.Repository.GetSessionByRefreshHash(ctx, refreshHash)
+}
+
+func (r *InstrumentedRepository) RotateSession(ctx context.Context, oldID string, next *models.Session, refreshHash string) (_ bool, err error) {
+	defer observe("RotateSession", time.Now(), &err)
+	return r.Repository.RotateSession(ctx, oldID, next, refreshHash)
+}
+
+func (r *InstrumentedRepository) RevokeSessionFamily(ctx context.Context, familyID string) (err error) {
+	defer observe("RevokeSessionFamily", time.Now(), &err)
+	return r.Repository.RevokeSessionFamily(ctx, familyID)
+}
+
+func (r *InstrumentedRepository) ReindexServices(ctx context.Context) (err error) {
+	defer observe("ReindexServices", time.Now(), &err)
+	return r.Repository.ReindexServices(ctx)
+}
+
+func (r *InstrumentedRepository) ListAllServices(ctx context.Context, afterID string, limit int) (_ []models.Service, err error) {
+	defer observe("ListAllServices", time.Now(), &err)
+	return r.Repository.ListAllServices(ctx, afterID, limit)
+}
+
+func (r *InstrumentedRepository) ArchiveVersions(ctx context.Context, before time.Time) (_ models.ArchiveResult, err error) {
+	defer observe("ArchiveVersions", time.Now(), &err)
+	return r.Repository.ArchiveVersions(ctx, before)
+}
+
+func (r *InstrumentedRepository) ExportBackup(ctx context.Context, emit func(models.BackupRecord) error) (err error) {
+	defer observe("ExportBackup", time.Now(), &err)
+	return r.Repository.ExportBackup(ctx, emit)
+}
+
+func (r *InstrumentedRepository) RestoreBackup(ctx context.Context, next func() (models.BackupRecord, error)) (_ models.RestoreResult, err error) {
+	defer observe("RestoreBackup", time.Now(), &err)
+	return r.Repository.RestoreBackup(ctx, next)
+}
+
+func (r *InstrumentedRepository) GetPendingEvents(ctx context.Context, limit int) (_ []models.Event, err error) {
+	defer observe("GetPendingEvents", time.Now(), &err)
+	return r.Repository.GetPendingEvents(ctx, limit)
+}
+
+func (r *InstrumentedRepository) DeleteEvent(ctx context.Context, id string) (err error) {
+	defer observe("DeleteEvent", time.Now(), &err)
+	return r.Repository.DeleteEvent(ctx, id)
+}
+
+func (r *InstrumentedRepository) RecordEventFailure(ctx context.Context, id, reason string) (err error) {
+	defer observe("RecordEventFailure", time.Now(), &err)
+	return r.Repository.RecordEventFailure(ctx, id, reason)
+}
+
+func (r *InstrumentedRepository) CreateWebhookSubscription(ctx context.Context, sub *models.WebhookSubscription) (err error) {
+	defer observe("CreateWebhookSubscription", time.Now(), &err)
+	return r.Repository.CreateWebhookSubscription(ctx, sub)
+}
+
+func (r *InstrumentedRepository) GetWebhookSubscriptions(ctx context.Context, orgID string) (_ []models.WebhookSubscription, err error) {
+	defer observe("GetWebhookSubscriptions", time.Now(), &err)
+	return r.Repository.GetWebhookSubscriptions(ctx, orgID)
+}
+
+func (r *InstrumentedRepository) GetWebhookSubscription(ctx context.Context, orgID, id string) (_ *models.WebhookSubscription, err error) {
+	defer observe("GetWebhookSubscription", time.Now(), &err)
+	return r.Repository.GetWebhookSubscription(ctx, orgID, id)
+}
+
+func (r *InstrumentedRepository) UpdateWebhookSubscription(ctx context.Context, orgID, id string, sub *models.WebhookSubscription) (_ int64, err error) {
+	defer observe("UpdateWebhookSubscription", time.Now(), &err)
+	return r.Repository.UpdateWebhookSubscription(ctx, orgID, id, sub)
+}
+
+func (r *InstrumentedRepository) DeleteWebhookSubscription(ctx context.Context, orgID, id string) (_ int64, err error) {
+	defer observe("DeleteWebhookSubscription", time.Now(), &err)
+	return r.Repository.DeleteWebhookSubscription(ctx, orgID, id)
+}
+
+func (r *InstrumentedRepository) GetWebhookDeliveries(ctx context.Context, orgID, subscriptionID string, limit int) (_ []models.WebhookDelivery, err error) {
+	defer observe("GetWebhookDeliveries", time.Now(), &err)
+	return r.Repository.GetWebhookDeliveries(ctx, orgID, subscriptionID, limit)
+}
+
+func (r *InstrumentedRepository) CreateWebhookDelivery(ctx context.Context, orgID string, delivery *models.WebhookDelivery) (err error) {
+	defer observe("CreateWebhookDelivery", time.Now(), &err)
+	return r.Repository.CreateWebhookDelivery(ctx, orgID, delivery)
+}
+
+func (r *InstrumentedRepository) GetDueWebhookDeliveries(ctx context.Context, now time.Time, limit int) (_ []models.WebhookDelivery, err error) {
+	defer observe("GetDueWebhookDeliveries", time.Now(), &err)
+	return r.Repository.GetDueWebhookDeliveries(ctx, now, limit)
+}
+
+func (r *InstrumentedRepository) UpdateWebhookDelivery(ctx context.Context, delivery *models.WebhookDelivery) (err error) {
+	defer observe("UpdateWebhookDelivery", time.Now(), &err)
+	return r.Repository.UpdateWebhookDelivery(ctx, delivery)
+}
+
+func (r *InstrumentedRepository) PruneWebhookDeliveries(ctx context.Context, before time.Time) (_ int64, err error) {
+	defer observe("PruneWebhookDeliveries", time.Now(), &err)
+	return r.Repository.PruneWebhookDeliveries(ctx, before)
+}
+
+func (r *InstrumentedRepository) QueueKongSync(ctx context.Context, orgID, serviceID string) (err error) {
+	defer observe("QueueKongSync", time.Now(), &err)
+	return r.Repository.QueueKongSync(ctx, orgID, serviceID)
+}
+
+func (r *InstrumentedRepository) GetKongSync(ctx context.Context, orgID, serviceID string) (_ *models.KongSync, err error) {
+	defer observe("GetKongSync", time.Now(), &err)
+	return r.Repository.GetKongSync(ctx, orgID, serviceID)
+}
+
+func (r *InstrumentedRepository) GetDueKongSyncs(ctx context.Context, now time.Time, limit int) (_ []models.KongSync, err error) {
+	defer observe("GetDueKongSyncs", time.Now(), &err)
+	return r.Repository.GetDueKongSyncs(ctx, now, limit)
+}
+
+func (r *InstrumentedRepository) UpdateKongSync(ctx context.Context, sync *models.KongSync) (_ bool, err error) {
+	defer observe("UpdateKongSync", time.Now(), &err)
+	return r.Repository.UpdateKongSync(ctx, sync)
+}
+
+func (r *InstrumentedRepository) SetGitHubRepository(ctx context.Context, link *models.GitHubRepository) (err error) {
+	defer observe("SetGitHubRepository", time.Now(), &err)
+	return r.Repository.SetGitHubRepository(ctx, link)
+}
+
+func (r *InstrumentedRepository) GetGitHubRepository(ctx context.Context, orgID, serviceID string) (_ *models.GitHubRepository, err error) {
+	defer observe("GetGitHubRepository", time.Now(), &err)
+	return r.Repository.GetGitHubRepository(ctx, orgID, serviceID)
+}
+
+func (r *InstrumentedRepository) DeleteGitHubRepository(ctx context.Context, orgID, serviceID string) (_ int64, err error) {
+	defer observe("DeleteGitHubRepository", time.Now(), &err)
+	return r.Repository.DeleteGitHubRepository(ctx, orgID, serviceID)
+}
+
+func (r *InstrumentedRepository) FindGitHubRepositories(ctx context.Context, repository string) (_ []models.GitHubRepository, err error) {
+	defer observe("FindGitHubRepositories", time.Now(), &err)
+	return r.Repository.FindGitHubRepositories(ctx, repository)
+}
+
+func (r *InstrumentedRepository) CreateCategory(ctx context.Context, category *models.Category) (err error) {
+	defer observe("CreateCategory", time.Now(), &err)
+	return r.Repository.CreateCategory(ctx, category)
+}
+
+func (r *InstrumentedRepository) GetCategories(ctx context.Context, orgID string) (_ []models.Category, err error) {
+	defer observe("GetCategories", time.Now(), &err)
+	return r.Repository.GetCategories(ctx, orgID)
+}
+
+func (r *InstrumentedRepository) GetCategory(ctx context.Context, orgID, id string) (_ *models.Category, err error) {
+	defer observe("GetCategory", time.Now(), &err)
+	return r.Repository.GetCategory(ctx, orgID, id)
+}
+
+func (r *InstrumentedRepository) UpdateCategory(ctx context.Context, orgID, id string, category *models.Category) (_ int64, err error) {
+	defer observe("UpdateCategory", time.Now(), &err)
+	return r.Repository.UpdateCategory(ctx, orgID, id, category)
+}
+
+func (r *InstrumentedRepository) DeleteCategory(ctx context.Context, orgID, id string) (_ int64, err error) {
+	defer observe("DeleteCategory", time.Now(), &err)
+	return r.Repository.DeleteCategory(ctx, orgID, id)
+}
+
+func (r *InstrumentedRepository) GetServiceCategories(ctx context.Context, orgID, serviceID string) (_ []models.Category, err error) {
+	defer observe("GetServiceCategories", time.Now(), &err)
+	return r.Repository.GetServiceCategories(ctx, orgID, serviceID)
+}
+
+func (r *InstrumentedRepository) AddServiceCategory(ctx context.Context, orgID, serviceID, categoryID string) (err error) {
+	defer observe("AddServiceCategory", time.Now(), &err)
+	return r.Repository.AddServiceCategory(ctx, orgID, serviceID, categoryID)
+}
+
+func (r *InstrumentedRepository) RemoveServiceCategory(ctx context.Context, orgID, serviceID, categoryID string) (_ int64, err error) {
+	defer observe("RemoveServiceCategory", time.Now(), &err)
+	return r.Repository.RemoveServiceCategory(ctx, orgID, serviceID, categoryID)
+}
+
+func (r *InstrumentedRepository) CreateDeployment(ctx context.Context, deployment *models.Deployment) (err error) {
+	defer observe("CreateDeployment", time.Now(), &err)
+	return r.Repository.CreateDeployment(ctx, deployment)
+}
+
+func (r *InstrumentedRepository) GetDeployments(ctx context.Context, orgID, serviceID, versionID string, limit int) (_ []models.Deployment, err error) {
+	defer observe("GetDeployments", time.Now(), &err)
+	return r.Repository.GetDeployments(ctx, orgID, serviceID, versionID, limit)
+}
+
+func (r *InstrumentedRepository) CreateConsumer(ctx context.Context, consumer *models.Consumer) (err error) {
+	defer observe("CreateConsumer", time.Now(), &err)
+	return r.Repository.CreateConsumer(ctx, consumer)
+}
+
+func (r *InstrumentedRepository) GetConsumers(ctx context.Context, orgID, serviceID string) (_ []models.Consumer, err error) {
+	defer observe("GetConsumers", time.Now(), &err)
+	return r.Repository.GetConsumers(ctx, orgID, serviceID)
+}
+
+func (r *InstrumentedRepository) GetConsumer(ctx context.Context, orgID, serviceID, id string) (_ *models.Consumer, err error) {
+	defer observe("GetConsumer", time.Now(), &err)
+	return r.Repository.GetConsumer(ctx, orgID, serviceID, id)
+}
+
+func (r *InstrumentedRepository) DeleteConsumer(ctx context.Context, orgID, serviceID, id string) (_ int64, err error) {
+	defer observe("DeleteConsumer", time.Now(), &err)
+	return r.Repository.DeleteConsumer(ctx, orgID, serviceID, id)
+}
+
+func (r *InstrumentedRepository) SetVersionSpec(ctx context.Context, orgID string, spec *models.VersionSpec) (err error) {
+	defer observe("SetVersionSpec", time.Now(), &err)
+	return r.Repository.SetVersionSpec(ctx, orgID, spec)
+}
+
+func (r *InstrumentedRepository) GetVersionSpec(ctx context.Context, orgID, serviceID, versionID string) (_ *models.VersionSpec, err error) {
+	defer observe("GetVersionSpec", time.Now(), &err)
+	return r.Repository.GetVersionSpec(ctx, orgID, serviceID, versionID)
+}
+
+func (r *InstrumentedRepository) DeleteVersionSpec(ctx context.Context, orgID, serviceID, versionID string) (_ int64, err error) {
+	defer observe("DeleteVersionSpec", time.Now(), &err)
+	return r.Repository.DeleteVersionSpec(ctx, orgID, serviceID, versionID)
+}
+
+func (r *InstrumentedRepository) GetNotificationPreferences(ctx context.Context, orgID, userID string) (_ *models.NotificationPreferences, err error) {
+	defer observe("GetNotificationPreferences", time.Now(), &err)
+	return r.Repository.GetNotificationPreferences(ctx, orgID, userID)
+}
+
+func (r *InstrumentedRepository) SetNotificationPreferences(ctx context.Context, prefs *models.NotificationPreferences) (err error) {
+	defer observe("SetNotificationPreferences", time.Now(), &err)
+	return r.Repository.SetNotificationPreferences(ctx, prefs)
+}
+
+func (r *InstrumentedRepository) SubscribeToService(ctx context.Context, orgID, serviceID, userID string) (err error) {
+	defer observe("SubscribeToService", time.Now(), &err)
+	return r.Repository.SubscribeToService(ctx, orgID, serviceID, userID)
+}
+
+func (r *InstrumentedRepository) UnsubscribeFromService(ctx context.Context, orgID, serviceID, userID string) (_ int64, err error) {
+	defer observe("UnsubscribeFromService", time.Now(), &err)
+	return r.Repository.UnsubscribeFromService(ctx, orgID, serviceID, userID)
+}
+
+func (r *InstrumentedRepository) GetDueEmailNotifications(ctx context.Context, now time.Time, limit int) (_ []models.EmailNotification, err error) {
+	defer observe("GetDueEmailNotifications", time.Now(), &err)
+	return r.Repository.GetDueEmailNotifications(ctx, now, limit)
+}
+
+func (r *InstrumentedRepository) UpdateEmailNotification(ctx context.Context, notification *models.EmailNotification) (err error) {
+	defer observe("UpdateEmailNotification", time.Now(), &err)
+	return r.Repository.UpdateEmailNotification(ctx, notification)
+}
+
+func (r *InstrumentedRepository) RecordSearch(ctx context.Context, query models.SearchQuery) (err error) {
+	defer observe("RecordSearch", time.Now(), &err)
+	return r.Repository.RecordSearch(ctx, query)
+}
+
+func (r *InstrumentedRepository) GetSearchAnalytics(ctx context.Context, orgID string, since time.Time, limit int) (_ *models.SearchAnalytics, err error) {
+	defer observe("GetSearchAnalytics", time.Now(), &err)
+	return r.Repository.GetSearchAnalytics(ctx, orgID, since, limit)
+}
+
+func (r *InstrumentedRepository) GetSynonyms(ctx context.Context) (_ []models.SearchSynonyms, err error) {
+	defer observe("GetSynonyms", time.Now(), &err)
+	return r.Repository.GetSynonyms(ctx)
+}
+
+func (r *InstrumentedRepository) SetSynonyms(ctx context.Context, term string, synonyms []string) (err error) {
+	defer observe("SetSynonyms", time.Now(), &err)
+	return r.Repository.SetSynonyms(ctx, term, synonyms)
+}
+
+func (r *InstrumentedRepository) DeleteSynonyms(ctx context.Context, term string) (_ int64, err error) {
+	defer observe("DeleteSynonyms", time.Now(), &err)
+	return r.Repository.DeleteSynonyms(ctx, term)
+}
+
+func (r *InstrumentedRepository) Ping(ctx context.Context) (err error) {
+	defer observe("Ping", time.Now(), &err)
+	return r.Repository.Ping(ctx)
+}
@@ -0,0 +1,332 @@
+// Package repository defines the storage interfaces handlers depend on, so
+// storage backends can be swapped and handlers tested without a database.
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/yashjain/konnect/internal/auth"
+	"github.com/yashjain/konnect/internal/models"
+	"github.com/yashjain/konnect/pkg/types"
+)
+
+// ServiceRepository stores services. Every method is scoped to an organization.
+// Soft-deleted services are left out of every read unless it asks for them with
+// IncludeDeleted, and cannot be updated.
+type ServiceRepository interface {
+	// GetServices lists the services visible to a principal, returning the page and the total count.
+	// With params.SkipCount the total is 0, and with params.SkipCount or params.Cursor the page holds
+	// one look-ahead row when a next page exists.
+	GetServices(ctx context.Context, p auth.Principal, params types.PaginationParams) ([]models.Service, int, error)
+	// SearchServices full-text searches the services visible to a principal, counting like GetServices
+	SearchServices(ctx context.Context, p auth.Principal, params types.SearchParams) ([]models.Service, int, error)
+	// SearchFacets counts the services SearchServices matches by each of facets
+	SearchFacets(ctx context.Context, p auth.Principal, params types.SearchParams, facets []string) (map[string][]types.FacetBucket, error)
+	// SuggestServices returns up to limit services visible to a principal whose name or slug
+	// starts with prefix, ignoring case, ordered by name and without counting
+	SuggestServices(ctx context.Context, p auth.Principal, prefix string, limit int) ([]models.ServiceSuggestion, error)
+	// GetServicesByIDs returns the services among ids visible to a principal, in no particular order
+	GetServicesByIDs(ctx context.Context, p auth.Principal, ids []string) ([]models.Service, error)
+	// CreateService creates a service together with any initial ACL grants,
+	// returning ErrConflict when the name or slug is taken
+	CreateService(ctx context.Context, service *models.Service, grants ...models.ServiceACL) error
+	// GetServiceByID returns sql.ErrNoRows when the service is not in the organization
+	GetServiceByID(ctx context.Context, orgID, id string, opts ...types.ReadOptions) (*models.Service, error)
+	// GetServiceBySlug returns sql.ErrNoRows when no service in the organization has the slug
+	GetServiceBySlug(ctx context.Context, orgID, slug string, opts ...types.ReadOptions) (*models.Service, error)
+	// UpdateService returns the number of rows updated, and ErrConflict when the name or slug is taken
+	UpdateService(ctx context.Context, orgID, id string, service *models.Service) (int64, error)
+	// DeleteService soft-deletes a service, returning the number of rows deleted,
+	// and ErrInUse while teams consume it
+	DeleteService(ctx context.Context, orgID, id string) (int64, error)
+}
+
+// VersionRepository stores service versions. Versions of a soft-deleted service
+// are treated as deleted too.
+type VersionRepository interface {
+	// GetVersions lists a service's versions, returning the page and the total count like GetServices
+	GetVersions(ctx context.Context, orgID, serviceID string, params types.PaginationParams) ([]models.Version, int, error)
+	// CreateVersion returns sql.ErrNoRows when the service is not in the organization
+	CreateVersion(ctx context.Context, orgID string, version *models.Version) error
+	// GetVersion returns a version of a service, or sql.ErrNoRows
+	GetVersion(ctx context.Context, orgID, serviceID, id string) (*models.Version, error)
+	// GetVersionBySemver returns the newest of a service's versions with the semver, or sql.ErrNoRows
+	GetVersionBySemver(ctx context.Context, orgID, serviceID, semver string) (*models.Version, error)
+	// UpdateVersion sets a version's status and changelog, returning the number of rows updated
+	UpdateVersion(ctx context.Context, orgID, serviceID, id string, version *models.Version) (int64, error)
+	// ScheduleDeprecation sets when a version is deprecated and sunset, deprecating
+	// it right away unless deprecatedAt is in the future. It returns
+	// sql.ErrNoRows when the version is not in the organization, and
+	// ErrInvalidSchedule when sunsetAt is not after the deprecation.
+	ScheduleDeprecation(ctx context.Context, orgID, serviceID, id string, deprecatedAt time.Time, sunsetAt *time.Time) (*models.Version, error)
+	// CancelDeprecation returns the number of scheduled deprecations cancelled
+	CancelDeprecation(ctx context.Context, orgID, serviceID, id string) (int64, error)
+	// ApplyDueDeprecations deprecates up to limit versions of any organization
+	// whose scheduled deprecation is due at now, returning how many it deprecated
+	ApplyDueDeprecations(ctx context.Context, now time.Time, limit int) (int, error)
+	// QueueSunsetReminders emails the recipients of the deprecation emails of up
+	// to limit deprecated versions of any organization whose sunset date falls
+	// after now and no later than before, once per version, returning how many
+	// versions it reminded of
+	QueueSunsetReminders(ctx context.Context, now, before time.Time, limit int) (int, error)
+	// SearchVersions returns up to limit versions of the services visible to a principal
+	// whose semver or changelog contains query, newest first
+	SearchVersions(ctx context.Context, p auth.Principal, query string, limit int) ([]models.Version, error)
+	// GetReleasedVersions returns up to limit released versions of the services visible to a principal, or of one of them when serviceID is set, most recently released first
+	GetReleasedVersions(ctx context.Context, p auth.Principal, serviceID string, limit int) ([]models.Version, error)
+	// GetDeprecationCalendar returns up to limit versions of the services visible
+	// to a principal deprecated or sunset no earlier than since, soonest first:
+	// those teamID consumes when set, those of serviceID when set, and otherwise
+	// those of the services the principal's user is subscribed to
+	GetDeprecationCalendar(ctx context.Context, p auth.Principal, teamID, serviceID string, since time.Time, limit int) ([]models.Version, error)
+}
+
+// AccessRepository stores service visibility and ACL grants
+type AccessRepository interface {
+	// GetServiceVisibility returns sql.ErrNoRows when the service is not in the organization
+	GetServiceVisibility(ctx context.Context, orgID, serviceID string) (string, error)
+	// GetServicePermission returns the strongest permission granted to a principal, or "" when there is none
+	GetServicePermission(ctx context.Context, p auth.Principal, serviceID string) (string, error)
+	CreateServiceACL(ctx context.Context, orgID string, acl *models.ServiceACL) error
+	GetServiceACLs(ctx context.Context, orgID, serviceID string) ([]models.ServiceACL, error)
+	// DeleteServiceACL returns the number of grants deleted
+	DeleteServiceACL(ctx context.Context, orgID, serviceID, aclID string) (int64, error)
+	// SubjectInOrg reports whether a user or team belongs to an organization
+	SubjectInOrg(ctx context.Context, orgID, subjectType, subjectID string) (bool, error)
+}
+
+// OrganizationRepository stores organizations and their API tokens, users and teams
+type OrganizationRepository interface {
+	CreateOrganization(ctx context.Context, org *models.Organization) error
+	GetOrganizations(ctx context.Context) ([]models.Organization, error)
+	CreateAPIToken(ctx context.Context, token *models.APIToken, tokenHash string) error
+	// GetPrincipalByTokenHash returns sql.ErrNoRows for unknown tokens
+	GetPrincipalByTokenHash(ctx context.Context, tokenHash string) (auth.Principal, error)
+	CreateUser(ctx context.Context, user *models.User, passwordHash string) error
+	CreateTeam(ctx context.Context, team *models.Team) error
+	// AddTeamMember returns the number of memberships added
+	AddTeamMember(ctx context.Context, orgID, teamID, userID string) (int64, error)
+	GetTeamIDsForUser(ctx context.Context, userID string) ([]string, error)
+}
+
+// SessionRepository stores login sessions
+type SessionRepository interface {
+	// GetUserCredentials returns sql.ErrNoRows for unknown emails and users without a password
+	GetUserCredentials(ctx context.Context, email string) (userID, orgID, passwordHash string, err error)
+	GetUserOrgID(ctx context.Context, userID string) (string, error)
+	CreateSession(ctx context.Context, session *models.Session, refreshHash string) error
+	// GetSessionByRefreshHash returns sql.ErrNoRows for unknown refresh tokens
+	GetSessionByRefreshHash(ctx context.Context, refreshHash string) (*models.Session, error)
+	// RotateSession revokes oldID and creates next, returning false if oldID was already revoked
+	RotateSession(ctx context.Context, oldID string, next *models.Session, refreshHash string) (bool, error)
+	RevokeSessionFamily(ctx context.Context, familyID string) error
+}
+
+// MaintenanceRepository runs admin maintenance across all organizations
+type MaintenanceRepository interface {
+	ReindexServices(ctx context.Context) error
+	// ListAllServices pages through the services that are not deleted, ordered by ID, starting after afterID
+	ListAllServices(ctx context.Context, afterID string, limit int) ([]models.Service, error)
+	// ArchiveVersions moves versions deleted before a cutoff out of the versions table, reporting how many moved and from which services
+	ArchiveVersions(ctx context.Context, before time.Time) (models.ArchiveResult, error)
+}
+
+// BackupRepository dumps and loads the organizations, services and versions of
+// every organization
+type BackupRepository interface {
+	// ExportBackup passes a consistent snapshot of every row to emit, organizations first, then services, then versions
+	ExportBackup(ctx context.Context, emit func(models.BackupRecord) error) error
+	// RestoreBackup inserts the records returned by next until io.EOF in one transaction, skipping existing rows
+	RestoreBackup(ctx context.Context, next func() (models.BackupRecord, error)) (models.RestoreResult, error)
+}
+
+// OutboxRepository reads and settles the change events recorded with each write,
+// across all organizations
+type OutboxRepository interface {
+	// GetPendingEvents returns up to limit undelivered events in the order they were recorded
+	GetPendingEvents(ctx context.Context, limit int) ([]models.Event, error)
+	// DeleteEvent removes a delivered event
+	DeleteEvent(ctx context.Context, id string) error
+	// RecordEventFailure counts a failed delivery, keeping the event for a retry
+	RecordEventFailure(ctx context.Context, id, reason string) error
+}
+
+// WebhookRepository stores an organization's webhook subscriptions and their delivery history
+type WebhookRepository interface {
+	CreateWebhookSubscription(ctx context.Context, sub *models.WebhookSubscription) error
+	// GetWebhookSubscriptions lists an organization's subscriptions, without their secrets
+	GetWebhookSubscriptions(ctx context.Context, orgID string) ([]models.WebhookSubscription, error)
+	// GetWebhookSubscription returns sql.ErrNoRows when the subscription is not in the organization
+	GetWebhookSubscription(ctx context.Context, orgID, id string) (*models.WebhookSubscription, error)
+	// UpdateWebhookSubscription returns the number of rows updated
+	UpdateWebhookSubscription(ctx context.Context, orgID, id string, sub *models.WebhookSubscription) (int64, error)
+	// DeleteWebhookSubscription deletes a subscription with its deliveries, returning the number of rows deleted
+	DeleteWebhookSubscription(ctx context.Context, orgID, id string) (int64, error)
+	// GetWebhookDeliveries lists up to limit deliveries to a subscription, newest first
+	GetWebhookDeliveries(ctx context.Context, orgID, subscriptionID string, limit int) ([]models.WebhookDelivery, error)
+	// CreateWebhookDelivery records a delivery attempted outside the dispatcher, such as a test
+	CreateWebhookDelivery(ctx context.Context, orgID string, delivery *models.WebhookDelivery) error
+}
+
+// WebhookDeliveryRepository reads and settles the webhook deliveries recorded
+// with each write, across all organizations
+type WebhookDeliveryRepository interface {
+	// GetDueWebhookDeliveries returns up to limit pending deliveries to active subscriptions
+	// whose next attempt is due at now, oldest first, with their subscription's URL and secret
+	GetDueWebhookDeliveries(ctx context.Context, now time.Time, limit int) ([]models.WebhookDelivery, error)
+	// UpdateWebhookDelivery records the outcome of an attempt
+	UpdateWebhookDelivery(ctx context.Context, delivery *models.WebhookDelivery) error
+	// PruneWebhookDeliveries removes settled deliveries created before a cutoff, returning how many
+	PruneWebhookDeliveries(ctx context.Context, before time.Time) (int64, error)
+}
+
+// KongSyncRepository stores the state of each service's sync to the Kong Gateway
+type KongSyncRepository interface {
+	// QueueKongSync marks a service to be synced now, returning sql.ErrNoRows when it is not in the organization
+	QueueKongSync(ctx context.Context, orgID, serviceID string) error
+	// GetKongSync returns sql.ErrNoRows when the service's sync was never queued
+	GetKongSync(ctx context.Context, orgID, serviceID string) (*models.KongSync, error)
+	// GetDueKongSyncs returns up to limit pending syncs across all organizations
+	// whose next attempt is due at now, oldest first
+	GetDueKongSyncs(ctx context.Context, now time.Time, limit int) ([]models.KongSync, error)
+	// UpdateKongSync records the outcome of an attempt, returning false when the
+	// sync was queued again since the attempt read it
+	UpdateKongSync(ctx context.Context, sync *models.KongSync) (bool, error)
+}
+
+// GitHubRepositoryRepository stores the GitHub repository linked to each service
+type GitHubRepositoryRepository interface {
+	// SetGitHubRepository links link.Repository to link.ServiceID, replacing its
+	// previous repository. It returns sql.ErrNoRows when the service is not in
+	// the organization, and ErrConflict when the repository is linked to another
+	// service of the organization.
+	SetGitHubRepository(ctx context.Context, link *models.GitHubRepository) error
+	// GetGitHubRepository returns sql.ErrNoRows when the service has no repository
+	GetGitHubRepository(ctx context.Context, orgID, serviceID string) (*models.GitHubRepository, error)
+	DeleteGitHubRepository(ctx context.Context, orgID, serviceID string) (int64, error)
+	// FindGitHubRepositories returns the links of a repository across all
+	// organizations, with their secrets
+	FindGitHubRepositories(ctx context.Context, repository string) ([]models.GitHubRepository, error)
+}
+
+// CategoryRepository stores the category tree of each organization and the
+// categories services are assigned to
+type CategoryRepository interface {
+	// CreateCategory returns ErrInvalidParent when the parent is not in the
+	// organization, and ErrConflict when the slug is taken
+	CreateCategory(ctx context.Context, category *models.Category) error
+	// GetCategories returns every category of the organization, flat, by name
+	GetCategories(ctx context.Context, orgID string) ([]models.Category, error)
+	GetCategory(ctx context.Context, orgID, id string) (*models.Category, error)
+	// UpdateCategory returns ErrInvalidParent when the new parent is not in the
+	// organization or is in the category's subtree, and ErrConflict when the slug is taken
+	UpdateCategory(ctx context.Context, orgID, id string, category *models.Category) (int64, error)
+	// DeleteCategory returns ErrInUse while the category has subcategories
+	DeleteCategory(ctx context.Context, orgID, id string) (int64, error)
+	GetServiceCategories(ctx context.Context, orgID, serviceID string) ([]models.Category, error)
+	// AddServiceCategory returns sql.ErrNoRows when the service or category is not in the organization
+	AddServiceCategory(ctx context.Context, orgID, serviceID, categoryID string) error
+	RemoveServiceCategory(ctx context.Context, orgID, serviceID, categoryID string) (int64, error)
+}
+
+// DeploymentRepository records the versions of services deployed to each environment
+type DeploymentRepository interface {
+	// CreateDeployment returns sql.ErrNoRows when the version is not in the organization
+	CreateDeployment(ctx context.Context, deployment *models.Deployment) error
+	// GetDeployments lists up to limit deployments of a version, newest first,
+	// returning sql.ErrNoRows when the version is not in the organization
+	GetDeployments(ctx context.Context, orgID, serviceID, versionID string, limit int) ([]models.Deployment, error)
+}
+
+// ConsumerRepository stores the teams consuming each service
+type ConsumerRepository interface {
+	// CreateConsumer returns sql.ErrNoRows when the service, team or version is
+	// not in the organization, and ErrConflict when the team already consumes it
+	CreateConsumer(ctx context.Context, consumer *models.Consumer) error
+	GetConsumers(ctx context.Context, orgID, serviceID string) ([]models.Consumer, error)
+	// GetConsumer returns sql.ErrNoRows when the service has no such consumer
+	GetConsumer(ctx context.Context, orgID, serviceID, id string) (*models.Consumer, error)
+	// DeleteConsumer returns the number of consumers removed
+	DeleteConsumer(ctx context.Context, orgID, serviceID, id string) (int64, error)
+}
+
+// SpecRepository stores the OpenAPI documents attached to versions
+type SpecRepository interface {
+	// SetVersionSpec returns sql.ErrNoRows when the version is not in the organization
+	SetVersionSpec(ctx context.Context, orgID string, spec *models.VersionSpec) error
+	// GetVersionSpec returns sql.ErrNoRows when the version has no document
+	GetVersionSpec(ctx context.Context, orgID, serviceID, versionID string) (*models.VersionSpec, error)
+	// DeleteVersionSpec returns the number of documents removed
+	DeleteVersionSpec(ctx context.Context, orgID, serviceID, versionID string) (int64, error)
+}
+
+// NotificationRepository stores users' notification preferences and service
+// subscriptions, and settles the email notifications queued across all organizations
+type NotificationRepository interface {
+	// GetNotificationPreferences returns every notification on for users who never set them
+	GetNotificationPreferences(ctx context.Context, orgID, userID string) (*models.NotificationPreferences, error)
+	// SetNotificationPreferences returns sql.ErrNoRows when the user is not in the organization
+	SetNotificationPreferences(ctx context.Context, prefs *models.NotificationPreferences) error
+	// SubscribeToService returns sql.ErrNoRows when the service or user is not in the organization
+	SubscribeToService(ctx context.Context, orgID, serviceID, userID string) error
+	UnsubscribeFromService(ctx context.Context, orgID, serviceID, userID string) (int64, error)
+	// GetDueEmailNotifications returns up to limit pending notifications whose
+	// next attempt is due at now, oldest first, with their recipient's email
+	GetDueEmailNotifications(ctx context.Context, now time.Time, limit int) ([]models.EmailNotification, error)
+	// UpdateEmailNotification records the outcome of an attempt
+	UpdateEmailNotification(ctx context.Context, notification *models.EmailNotification) error
+}
+
+// SearchAnalyticsRepository records sampled searches and reports on them per organization
+type SearchAnalyticsRepository interface {
+	RecordSearch(ctx context.Context, query models.SearchQuery) error
+	// GetSearchAnalytics reports on an organization's searches since a point in time,
+	// listing up to limit queries of each kind
+	GetSearchAnalytics(ctx context.Context, orgID string, since time.Time, limit int) (*models.SearchAnalytics, error)
+}
+
+// SynonymRepository stores the synonyms searches expand their terms with, shared
+// by every organization
+type SynonymRepository interface {
+	// GetSynonyms returns every term with its synonyms, ordered by term
+	GetSynonyms(ctx context.Context) ([]models.SearchSynonyms, error)
+	// SetSynonyms replaces the synonyms of a term
+	SetSynonyms(ctx context.Context, term string, synonyms []string) error
+	// DeleteSynonyms returns the number of synonyms removed
+	DeleteSynonyms(ctx context.Context, term string) (int64, error)
+}
+
+// HealthRepository reports whether the storage backend is reachable
+type HealthRepository interface {
+	// Health returns the error from the most recent connectivity check, or nil
+	Health() error
+	// Ping checks the storage backend is reachable now
+	Ping(ctx context.Context) error
+}
+
+// Repository is implemented by each storage backend
+type Repository interface {
+	ServiceRepository
+	VersionRepository
+	AccessRepository
+	OrganizationRepository
+	SessionRepository
+	MaintenanceRepository
+	BackupRepository
+	OutboxRepository
+	WebhookRepository
+	WebhookDeliveryRepository
+	KongSyncRepository
+	GitHubRepositoryRepository
+	CategoryRepository
+	DeploymentRepository
+	ConsumerRepository
+	SpecRepository
+	NotificationRepository
+	SearchAnalyticsRepository
+	SynonymRepository
+	HealthRepository
+
+	Close() error
+}
@@ -0,0 +1,127 @@
+package sanitize
+
+import (
+	"bytes"
+	"html"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/text"
+)
+
+// maxPasses bounds how many sanitize/unescape rounds are applied to stored text
+const maxPasses = 5
+
+var (
+	// policy allows the subset of HTML that user-generated markdown may produce
+	policy = bluemonday.UGCPolicy()
+
+	// renderer converts markdown to HTML; raw HTML in the source is dropped
+	renderer = goldmark.New(goldmark.WithExtensions(extension.GFM))
+)
+
+// Markdown strips scripts and dangerous HTML from markdown source before it is stored.
+// Markdown syntax itself (e.g. "> quote", "a & b") is preserved as written, as is
+// the text of code spans and blocks (e.g. "`<script>`"), which markdown renders
+// literally.
+func Markdown(input string) string {
+	code := codeRanges([]byte(input))
+	if len(code) == 0 {
+		return sanitizeText(input)
+	}
+
+	// Code is swapped for placeholders the policy leaves alone, so only the
+	// text around it is sanitized
+	token := "sanitizedcode"
+	for strings.Contains(input, token) {
+		token += "x"
+	}
+	var prose strings.Builder
+	codes := make([]string, len(code))
+	restore := make([]string, 0, 2*len(code))
+	last := 0
+	for i, r := range code {
+		placeholder := token + strconv.Itoa(i) + token
+		codes[i] = input[r[0]:r[1]]
+		restore = append(restore, placeholder, codes[i])
+		prose.WriteString(input[last:r[0]])
+		prose.WriteString(placeholder)
+		last = r[1]
+	}
+	prose.WriteString(input[last:])
+	out := strings.NewReplacer(restore...).Replace(sanitizeText(prose.String()))
+
+	// Removing HTML around the code must not have moved it out of its span or
+	// block; if it did, the code is sanitized like the rest
+	if !slices.Equal(codeTexts(out), codes) {
+		return sanitizeText(input)
+	}
+	return out
+}
+
+// sanitizeText strips dangerous HTML from input, unescaping what the policy
+// escaped so that markdown is kept as written
+func sanitizeText(input string) string {
+	out := input
+	for i := 0; i < maxPasses; i++ {
+		next := html.UnescapeString(policy.Sanitize(out))
+		if next == out {
+			return out
+		}
+		out = next
+	}
+	// Still changing after maxPasses: fall back to the escaped form, which is always safe
+	return policy.Sanitize(out)
+}
+
+// codeRanges returns the byte ranges of the contents of the code spans and
+// blocks of markdown source, in order
+func codeRanges(source []byte) [][2]int {
+	var ranges [][2]int
+	doc := renderer.Parser().Parse(text.NewReader(source))
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch n := n.(type) {
+		case *ast.CodeSpan:
+			first, ok1 := n.FirstChild().(*ast.Text)
+			last, ok2 := n.LastChild().(*ast.Text)
+			if ok1 && ok2 {
+				ranges = append(ranges, [2]int{first.Segment.Start, last.Segment.Stop})
+			}
+			return ast.WalkSkipChildren, nil
+		case *ast.FencedCodeBlock, *ast.CodeBlock:
+			if lines := n.Lines(); lines.Len() > 0 {
+				ranges = append(ranges, [2]int{lines.At(0).Start, lines.At(lines.Len() - 1).Stop})
+			}
+			return ast.WalkSkipChildren, nil
+		}
+		return ast.WalkContinue, nil
+	})
+	return ranges
+}
+
+// codeTexts returns the contents of the code spans and blocks of markdown source
+func codeTexts(source string) []string {
+	ranges := codeRanges([]byte(source))
+	texts := make([]string, len(ranges))
+	for i, r := range ranges {
+		texts[i] = source[r[0]:r[1]]
+	}
+	return texts
+}
+
+// RenderHTML renders markdown to HTML and sanitizes the result for display
+func RenderHTML(input string) (string, error) {
+	var buf bytes.Buffer
+	if err := renderer.Convert([]byte(input), &buf); err != nil {
+		return "", err
+	}
+	return policy.Sanitize(buf.String()), nil
+}
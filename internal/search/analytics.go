@@ -0,0 +1,68 @@
+package search
+
+import (
+	"context"
+	"log/slog"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/yashjain/konnect/internal/auth"
+	"github.com/yashjain/konnect/internal/metrics"
+	"github.com/yashjain/konnect/internal/models"
+	"github.com/yashjain/konnect/internal/repository"
+	"github.com/yashjain/konnect/pkg/types"
+)
+
+// maxRecordedQuery is the most runes of a query recorded for analytics
+const maxRecordedQuery = 255
+
+// AnalyticsRepository records a sample of the searches run through the
+// repository it wraps, for search analytics. Every other method is the wrapped
+// repository's.
+//
+// Only first pages are recorded, so paging through results counts as one search,
+// and browsing without a query is not recorded.
+// A recording failure is logged and counted rather than failing the search.
+type AnalyticsRepository struct {
+	repository.Repository
+	rate float64
+}
+
+// NewAnalyticsRepository wraps repo to record each search with probability rate
+func NewAnalyticsRepository(repo repository.Repository, rate float64) *AnalyticsRepository {
+	return &AnalyticsRepository{Repository: repo, rate: rate}
+}
+
+// SearchServices searches the wrapped repository and records a sample of the searches
+func (r *AnalyticsRepository) SearchServices(ctx context.Context, p auth.Principal, params types.SearchParams) ([]models.Service, int, error) {
+	services, total, err := r.Repository.SearchServices(ctx, p, params)
+	if err != nil || params.Page > 1 || params.Mode == types.SearchModeBrowse || rand.Float64() >= r.rate {
+		return services, total, err
+	}
+
+	// Without a count, whether the first page is empty still tells zero-result searches apart
+	hits := total
+	if params.SkipCount {
+		hits = len(services)
+	}
+
+	query := models.SearchQuery{OrgID: p.OrgID, Query: normalizeQuery(params.Query), Hits: hits, SearchedAt: time.Now()}
+	if err := r.Repository.RecordSearch(ctx, query); err != nil {
+		metrics.SearchAnalyticsRecords.WithLabelValues("error").Inc()
+		slog.WarnContext(ctx, "Error recording search", "error", err)
+	} else {
+		metrics.SearchAnalyticsRecords.WithLabelValues("ok").Inc()
+	}
+	return services, total, nil
+}
+
+// normalizeQuery lowercases a query and collapses its whitespace, so the same
+// search typed differently is counted together
+func normalizeQuery(query string) string {
+	query = strings.ToLower(strings.Join(strings.Fields(query), " "))
+	if runes := []rune(query); len(runes) > maxRecordedQuery {
+		query = string(runes[:maxRecordedQuery])
+	}
+	return query
+}
@@ -0,0 +1,65 @@
+package search
+
+import (
+	"context"
+	"time"
+
+	"github.com/yashjain/konnect/internal/auth"
+	"github.com/yashjain/konnect/internal/models"
+	"github.com/yashjain/konnect/pkg/types"
+)
+
+// Document is a service as a Backend indexes it
+type Document struct {
+	models.Service
+
+	// Subjects are the users and teams granted access to the service, as
+	// "user:<id>" and "team:<id>", so private services are only found by them
+	Subjects []string
+
+	// IndexedAt is when the document was built, for pruning stale documents
+	IndexedAt time.Time
+}
+
+// Hit is one service found by a Backend, with its relevance score
+type Hit struct {
+	ID    string
+	Score float64
+}
+
+// Result is a page of hits, best first. Total is 0 when the search skipped counting.
+type Result struct {
+	Hits  []Hit
+	Total int
+}
+
+// Backend is an external search engine holding an index of every service
+type Backend interface {
+	// Index adds or replaces the document of a service
+	Index(ctx context.Context, doc Document) error
+
+	// Delete removes the document of a service, if there is one
+	Delete(ctx context.Context, id string) error
+
+	// Prune removes the documents indexed before a time
+	Prune(ctx context.Context, before time.Time) error
+
+	// Search finds the services visible to a principal, honouring the query, mode,
+	// page and MinScore of params. With params.SkipCount the page holds one
+	// look-ahead hit when a next page exists, like the database search.
+	Search(ctx context.Context, p auth.Principal, params types.SearchParams) (Result, error)
+}
+
+// Subject formats a user or team for Document.Subjects
+func Subject(subjectType, subjectID string) string {
+	return subjectType + ":" + subjectID
+}
+
+// Subjects returns the Document.Subjects a principal matches
+func Subjects(p auth.Principal) []string {
+	subjects := []string{Subject(models.SubjectUser, p.UserID)}
+	for _, id := range p.TeamIDs {
+		subjects = append(subjects, Subject(models.SubjectTeam, id))
+	}
+	return subjects
+}
@@ -0,0 +1,128 @@
+// Package search parses the search syntaxes users can type, independently of the
+// database that runs the search, and can serve service search from an external
+// Backend such as Elasticsearch instead of the database.
+package search
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// MaxBooleanTerms bounds the terms of a boolean query, so one request cannot
+// build an arbitrarily expensive search
+const MaxBooleanTerms = 32
+
+// ErrInvalidQuery is returned for queries that do not follow the boolean syntax
+var ErrInvalidQuery = errors.New("invalid search query")
+
+// BooleanTerm is one word or phrase of a boolean query
+type BooleanTerm struct {
+	// Words holds a single word, or the words of a quoted phrase in order
+	Words []string
+
+	// Required terms must match (+) and excluded terms must not (-). Other terms
+	// are optional: at least one must match when there are no required terms.
+	Required bool
+	Excluded bool
+
+	// Prefix matches words starting with the word (word*); phrases cannot be prefixes
+	Prefix bool
+}
+
+// Phrase reports whether the term is a quoted phrase
+func (t BooleanTerm) Phrase() bool {
+	return len(t.Words) > 1
+}
+
+// BooleanQuery is a parsed boolean search such as `+payment -legacy "exact phrase"`
+type BooleanQuery struct {
+	Terms []BooleanTerm
+}
+
+// Filter returns the terms whose Required and Excluded flags are as given
+func (q BooleanQuery) Filter(required, excluded bool) []BooleanTerm {
+	var terms []BooleanTerm
+	for _, t := range q.Terms {
+		if t.Required == required && t.Excluded == excluded {
+			terms = append(terms, t)
+		}
+	}
+	return terms
+}
+
+// ParseBoolean parses a boolean query. Terms are words made of letters, digits
+// and underscores, or quoted phrases of such words, each optionally preceded by
+// + (required) or - (excluded); words may end in * to match as a prefix. Any
+// other operator is rejected rather than passed on to the database.
+func ParseBoolean(query string) (BooleanQuery, error) {
+	var q BooleanQuery
+	rest := strings.TrimSpace(query)
+	for rest != "" {
+		var term BooleanTerm
+		switch rest[0] {
+		case '+':
+			term.Required, rest = true, rest[1:]
+		case '-':
+			term.Excluded, rest = true, rest[1:]
+		}
+
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.IndexByte(rest[1:], '"')
+			if end < 0 {
+				return BooleanQuery{}, fmt.Errorf("%w: unterminated quote", ErrInvalidQuery)
+			}
+			term.Words = strings.FieldsFunc(rest[1:end+1], func(r rune) bool { return !isWordRune(r) })
+			rest = rest[end+2:]
+			if len(term.Words) == 0 {
+				return BooleanQuery{}, fmt.Errorf("%w: empty phrase", ErrInvalidQuery)
+			}
+		} else {
+			word := rest
+			if i := strings.IndexFunc(rest, unicode.IsSpace); i >= 0 {
+				word, rest = rest[:i], rest[i:]
+			} else {
+				rest = ""
+			}
+			if word, term.Prefix = strings.CutSuffix(word, "*"); word == "" {
+				return BooleanQuery{}, fmt.Errorf("%w: operator without a term", ErrInvalidQuery)
+			}
+			if err := checkWord(word); err != nil {
+				return BooleanQuery{}, err
+			}
+			term.Words = []string{word}
+		}
+
+		// Terms must be separated by whitespace
+		if rest != "" && !unicode.IsSpace(rune(rest[0])) {
+			return BooleanQuery{}, fmt.Errorf("%w: missing space after term", ErrInvalidQuery)
+		}
+		rest = strings.TrimSpace(rest)
+
+		q.Terms = append(q.Terms, term)
+		if len(q.Terms) > MaxBooleanTerms {
+			return BooleanQuery{}, fmt.Errorf("%w: more than %d terms", ErrInvalidQuery, MaxBooleanTerms)
+		}
+	}
+
+	if len(q.Terms) == len(q.Filter(false, true)) {
+		return BooleanQuery{}, fmt.Errorf("%w: at least one term must not be excluded", ErrInvalidQuery)
+	}
+	return q, nil
+}
+
+// checkWord rejects words with characters other than letters, digits and underscores
+func checkWord(word string) error {
+	for _, r := range word {
+		if !isWordRune(r) {
+			return fmt.Errorf("%w: unsupported character %q", ErrInvalidQuery, r)
+		}
+	}
+	return nil
+}
+
+// isWordRune reports whether r may appear in a word
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
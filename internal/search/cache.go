@@ -0,0 +1,305 @@
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/yashjain/konnect/internal/auth"
+	"github.com/yashjain/konnect/internal/metrics"
+	"github.com/yashjain/konnect/internal/models"
+	"github.com/yashjain/konnect/internal/repository"
+	"github.com/yashjain/konnect/pkg/types"
+)
+
+// CachedRepository answers identical searches from memory for a short TTL, to
+// absorb bursts such as many dashboards refreshing at once. Every other method
+// is the wrapped repository's.
+//
+// Searches are cached per principal, since each principal sees different
+// services, and writes through the repository drop the cached searches of their
+// organization. Writes made by other instances are only seen once the TTL lapses.
+type CachedRepository struct {
+	repository.Repository
+	ttl        time.Duration
+	maxEntries int
+
+	mu      sync.Mutex
+	entries map[string]map[string]cachedSearch // by organization, then key
+	size    int
+
+	// generations count the invalidations of each organization, so a search that
+	// raced a write is not cached with results from before it
+	generations map[string]uint64
+}
+
+// cachedSearch is one cached SearchServices result
+type cachedSearch struct {
+	services []models.Service
+	total    int
+	expires  time.Time
+}
+
+// NewCachedRepository wraps repo to cache up to maxEntries searches for ttl
+func NewCachedRepository(repo repository.Repository, ttl time.Duration, maxEntries int) *CachedRepository {
+	return &CachedRepository{
+		Repository:  repo,
+		ttl:         ttl,
+		maxEntries:  maxEntries,
+		entries:     make(map[string]map[string]cachedSearch),
+		generations: make(map[string]uint64),
+	}
+}
+
+// SearchServices returns a cached result for the same principal and params when
+// one is fresh, and otherwise searches the wrapped repository and caches the result
+func (r *CachedRepository) SearchServices(ctx context.Context, p auth.Principal, params types.SearchParams) ([]models.Service, int, error) {
+	key, err := cacheKey(p, params)
+	if err != nil {
+		return r.Repository.SearchServices(ctx, p, params)
+	}
+
+	r.mu.Lock()
+	entry, ok := r.entries[p.OrgID][key]
+	generation := r.generations[p.OrgID]
+	r.mu.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		metrics.SearchCacheRequests.WithLabelValues("hit").Inc()
+		return copyServices(entry.services), entry.total, nil
+	}
+	metrics.SearchCacheRequests.WithLabelValues("miss").Inc()
+
+	services, total, err := r.Repository.SearchServices(ctx, p, params)
+	if err != nil {
+		return services, total, err
+	}
+	r.store(p.OrgID, key, generation, cachedSearch{services: copyServices(services), total: total, expires: time.Now().Add(r.ttl)})
+	return services, total, nil
+}
+
+// store caches a search of an organization, unless the organization was
+// invalidated since generation. When the cache is full expired entries are
+// dropped first, then entries of the same organization, then any.
+func (r *CachedRepository) store(orgID, key string, generation uint64, entry cachedSearch) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.generations[orgID] != generation {
+		return
+	}
+	if r.size >= r.maxEntries {
+		r.evict(orgID)
+	}
+	if r.size >= r.maxEntries {
+		return
+	}
+
+	org := r.entries[orgID]
+	if org == nil {
+		org = make(map[string]cachedSearch)
+		r.entries[orgID] = org
+	}
+	if _, ok := org[key]; !ok {
+		r.size++
+	}
+	org[key] = entry
+}
+
+// evict makes room for one entry; r.mu must be held
+func (r *CachedRepository) evict(orgID string) {
+	now := time.Now()
+	for id, org := range r.entries {
+		for key, entry := range org {
+			if now.After(entry.expires) {
+				r.remove(id, key)
+			}
+		}
+	}
+	if r.size < r.maxEntries {
+		return
+	}
+
+	for key := range r.entries[orgID] {
+		r.remove(orgID, key)
+		return
+	}
+	for id, org := range r.entries {
+		for key := range org {
+			r.remove(id, key)
+			return
+		}
+	}
+}
+
+// remove drops one entry; r.mu must be held
+func (r *CachedRepository) remove(orgID, key string) {
+	delete(r.entries[orgID], key)
+	if len(r.entries[orgID]) == 0 {
+		delete(r.entries, orgID)
+	}
+	r.size--
+}
+
+// invalidate drops the cached searches of an organization
+func (r *CachedRepository) invalidate(orgID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.size -= len(r.entries[orgID])
+	delete(r.entries, orgID)
+	r.generations[orgID]++
+}
+
+// invalidateAll drops every cached search
+func (r *CachedRepository) invalidateAll() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for orgID := range r.entries {
+		r.generations[orgID]++
+	}
+	r.entries = make(map[string]map[string]cachedSearch)
+	r.size = 0
+}
+
+// CreateService creates a service and drops its organization's cached searches
+func (r *CachedRepository) CreateService(ctx context.Context, service *models.Service, grants ...models.ServiceACL) error {
+	err := r.Repository.CreateService(ctx, service, grants...)
+	r.invalidate(service.OrgID)
+	return err
+}
+
+// UpdateService updates a service and drops its organization's cached searches
+func (r *CachedRepository) UpdateService(ctx context.Context, orgID, id string, service *models.Service) (int64, error) {
+	rowsAffected, err := r.Repository.UpdateService(ctx, orgID, id, service)
+	r.invalidate(orgID)
+	return rowsAffected, err
+}
+
+// DeleteService deletes a service and drops its organization's cached searches
+func (r *CachedRepository) DeleteService(ctx context.Context, orgID, id string) (int64, error) {
+	rowsAffected, err := r.Repository.DeleteService(ctx, orgID, id)
+	r.invalidate(orgID)
+	return rowsAffected, err
+}
+
+// CreateVersion creates a version and drops its organization's cached searches,
+// which may sort or filter on versions
+func (r *CachedRepository) CreateVersion(ctx context.Context, orgID string, version *models.Version) error {
+	err := r.Repository.CreateVersion(ctx, orgID, version)
+	r.invalidate(orgID)
+	return err
+}
+
+// UpdateVersion updates a version and drops its organization's cached searches
+func (r *CachedRepository) UpdateVersion(ctx context.Context, orgID, serviceID, id string, version *models.Version) (int64, error) {
+	rowsAffected, err := r.Repository.UpdateVersion(ctx, orgID, serviceID, id, version)
+	r.invalidate(orgID)
+	return rowsAffected, err
+}
+
+// ScheduleDeprecation schedules the deprecation of a version, which may
+// deprecate it right away, and drops its organization's cached searches
+func (r *CachedRepository) ScheduleDeprecation(ctx context.Context, orgID, serviceID, id string, deprecatedAt time.Time, sunsetAt *time.Time) (*models.Version, error) {
+	version, err := r.Repository.ScheduleDeprecation(ctx, orgID, serviceID, id, deprecatedAt, sunsetAt)
+	r.invalidate(orgID)
+	return version, err
+}
+
+// CancelDeprecation cancels the scheduled deprecation of a version and drops
+// its organization's cached searches
+func (r *CachedRepository) CancelDeprecation(ctx context.Context, orgID, serviceID, id string) (int64, error) {
+	rowsAffected, err := r.Repository.CancelDeprecation(ctx, orgID, serviceID, id)
+	r.invalidate(orgID)
+	return rowsAffected, err
+}
+
+// ApplyDueDeprecations deprecates due versions of any organization and drops
+// every cached search
+func (r *CachedRepository) ApplyDueDeprecations(ctx context.Context, now time.Time, limit int) (int, error) {
+	n, err := r.Repository.ApplyDueDeprecations(ctx, now, limit)
+	if n > 0 {
+		r.invalidateAll()
+	}
+	return n, err
+}
+
+// SetVersionSpec stores the spec of a version and drops its organization's
+// cached searches, which may sort or filter on spec scores
+func (r *CachedRepository) SetVersionSpec(ctx context.Context, orgID string, spec *models.VersionSpec) error {
+	err := r.Repository.SetVersionSpec(ctx, orgID, spec)
+	r.invalidate(orgID)
+	return err
+}
+
+// DeleteVersionSpec removes the spec of a version and drops its organization's
+// cached searches
+func (r *CachedRepository) DeleteVersionSpec(ctx context.Context, orgID, serviceID, versionID string) (int64, error) {
+	deleted, err := r.Repository.DeleteVersionSpec(ctx, orgID, serviceID, versionID)
+	r.invalidate(orgID)
+	return deleted, err
+}
+
+// SetSynonyms replaces the synonyms of a term, which every organization's
+// searches expand, and drops every cached search
+func (r *CachedRepository) SetSynonyms(ctx context.Context, term string, synonyms []string) error {
+	err := r.Repository.SetSynonyms(ctx, term, synonyms)
+	r.invalidateAll()
+	return err
+}
+
+// DeleteSynonyms removes the synonyms of a term and drops every cached search
+func (r *CachedRepository) DeleteSynonyms(ctx context.Context, term string) (int64, error) {
+	deleted, err := r.Repository.DeleteSynonyms(ctx, term)
+	r.invalidateAll()
+	return deleted, err
+}
+
+// CreateServiceACL grants access to a service and drops its organization's cached searches
+func (r *CachedRepository) CreateServiceACL(ctx context.Context, orgID string, acl *models.ServiceACL) error {
+	err := r.Repository.CreateServiceACL(ctx, orgID, acl)
+	r.invalidate(orgID)
+	return err
+}
+
+// DeleteServiceACL revokes access to a service and drops its organization's cached searches
+func (r *CachedRepository) DeleteServiceACL(ctx context.Context, orgID, serviceID, aclID string) (int64, error) {
+	deleted, err := r.Repository.DeleteServiceACL(ctx, orgID, serviceID, aclID)
+	r.invalidate(orgID)
+	return deleted, err
+}
+
+// ReindexServices rebuilds the search index and drops every cached search
+func (r *CachedRepository) ReindexServices(ctx context.Context) error {
+	err := r.Repository.ReindexServices(ctx)
+	r.invalidateAll()
+	return err
+}
+
+// RestoreBackup restores a backup and drops every cached search
+func (r *CachedRepository) RestoreBackup(ctx context.Context, next func() (models.BackupRecord, error)) (models.RestoreResult, error) {
+	result, err := r.Repository.RestoreBackup(ctx, next)
+	r.invalidateAll()
+	return result, err
+}
+
+// cacheKey identifies a search by the principal and every parameter
+func cacheKey(p auth.Principal, params types.SearchParams) (string, error) {
+	key, err := json.Marshal(struct {
+		Principal auth.Principal
+		Params    types.SearchParams
+	}{p, params})
+	return string(key), err
+}
+
+// copyServices copies a result, so callers changing the services they are
+// handed do not change the cached ones
+func copyServices(services []models.Service) []models.Service {
+	if services == nil {
+		return nil
+	}
+	copied := make([]models.Service, len(services))
+	copy(copied, services)
+	return copied
+}
@@ -0,0 +1,324 @@
+// Package elasticsearch is a search.Backend on Elasticsearch or OpenSearch,
+// spoken to through the REST API both share.
+package elasticsearch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yashjain/konnect/internal/auth"
+	"github.com/yashjain/konnect/internal/search"
+	"github.com/yashjain/konnect/pkg/types"
+)
+
+// mapping is the index mapping. Organizations, visibility and subjects are
+// exact-match keywords used to filter what a principal may find, and tags
+// keywords filtering by tag.
+const mapping = `{
+  "mappings": {
+    "properties": {
+      "org_id":         {"type": "keyword"},
+      "name":           {"type": "text", "fields": {"raw": {"type": "keyword"}}},
+      "slug":           {"type": "text", "analyzer": "simple"},
+      "description":    {"type": "text"},
+      "visibility":     {"type": "keyword"},
+      "subjects":       {"type": "keyword"},
+      "tags":           {"type": "keyword"},
+      "versions_count": {"type": "integer"},
+      "created_at":     {"type": "date"},
+      "indexed_at":     {"type": "date"}
+    }
+  }
+}`
+
+// errNotFound is returned for requests answered with 404 Not Found
+var errNotFound = errors.New("not found")
+
+// object is a JSON object of the query DSL
+type object = map[string]interface{}
+
+// Client is a search.Backend storing services in one index
+type Client struct {
+	url      string
+	index    string
+	username string
+	password string
+	http     *http.Client
+
+	// fields are the document fields queries match, boosted by their weights
+	fields []string
+}
+
+var _ search.Backend = (*Client)(nil)
+
+// New returns a client for the index at baseURL, authenticating with basic auth
+// when username is set. Each request is bounded by timeout, and searches weigh
+// matches in each field by weights.
+func New(baseURL, index, username, password string, timeout time.Duration, weights types.SearchWeights) *Client {
+	return &Client{
+		url:      strings.TrimSuffix(baseURL, "/"),
+		index:    index,
+		username: username,
+		password: password,
+		http:     &http.Client{Timeout: timeout},
+		fields:   searchFields(weights),
+	}
+}
+
+// searchFields boosts each searched field by its weight, leaving out fields
+// weighing zero and leaving those weighing one unboosted
+func searchFields(w types.SearchWeights) []string {
+	var fields []string
+	for _, f := range []struct {
+		name   string
+		weight float64
+	}{{"name", w.Name}, {"slug", w.Slug}, {"description", w.Description}} {
+		switch {
+		case f.weight == 1:
+			fields = append(fields, f.name)
+		case f.weight > 0:
+			fields = append(fields, f.name+"^"+strconv.FormatFloat(f.weight, 'g', -1, 64))
+		}
+	}
+	return fields
+}
+
+// EnsureIndex creates the index with its mapping unless it exists
+func (c *Client) EnsureIndex(ctx context.Context) error {
+	err := c.do(ctx, http.MethodHead, "", nil, nil)
+	if !errors.Is(err, errNotFound) {
+		return err
+	}
+	return c.do(ctx, http.MethodPut, "", json.RawMessage(mapping), nil)
+}
+
+// Ping checks the cluster is reachable and the index exists
+func (c *Client) Ping(ctx context.Context) error {
+	return c.do(ctx, http.MethodHead, "", nil, nil)
+}
+
+// Index implements search.Backend
+func (c *Client) Index(ctx context.Context, doc search.Document) error {
+	subjects := doc.Subjects
+	if subjects == nil {
+		subjects = []string{}
+	}
+	tags := doc.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	return c.do(ctx, http.MethodPut, "/_doc/"+url.PathEscape(doc.ID), object{
+		"org_id":         doc.OrgID,
+		"name":           doc.Name,
+		"slug":           doc.Slug,
+		"description":    doc.Description,
+		"visibility":     doc.Visibility,
+		"subjects":       subjects,
+		"tags":           tags,
+		"versions_count": doc.VersionsCount,
+		"created_at":     doc.CreatedAt,
+		"indexed_at":     doc.IndexedAt,
+	}, nil)
+}
+
+// Delete implements search.Backend
+func (c *Client) Delete(ctx context.Context, id string) error {
+	// A service that was never indexed is already gone
+	if err := c.do(ctx, http.MethodDelete, "/_doc/"+url.PathEscape(id), nil, nil); !errors.Is(err, errNotFound) {
+		return err
+	}
+	return nil
+}
+
+// Prune implements search.Backend
+func (c *Client) Prune(ctx context.Context, before time.Time) error {
+	return c.do(ctx, http.MethodPost, "/_delete_by_query", object{
+		"query": object{"range": object{"indexed_at": object{"lt": before}}},
+	}, nil)
+}
+
+// sorts are the sort clauses of each search order other than relevance. Names
+// sort on their keyword subfield, as text fields cannot be sorted.
+var sorts = map[string][]interface{}{
+	types.SortName:          {object{"name.raw": "asc"}, object{"_id": "asc"}},
+	types.SortCreatedAt:     {object{"created_at": "desc"}},
+	types.SortVersionsCount: {object{"versions_count": "desc"}, object{"created_at": "desc"}},
+}
+
+// sortFor returns the sort clause of a search order, best matches first by default
+func sortFor(sort string) []interface{} {
+	if clause, ok := sorts[sort]; ok {
+		return clause
+	}
+	return []interface{}{"_score", object{"created_at": "desc"}}
+}
+
+// Search implements search.Backend
+func (c *Client) Search(ctx context.Context, p auth.Principal, params types.SearchParams) (search.Result, error) {
+	match, err := queryFor(params, c.fields)
+	if err != nil {
+		return search.Result{}, err
+	}
+
+	filter := []interface{}{object{"term": object{"org_id": p.OrgID}}}
+	if !p.IsOrgWide() {
+		filter = append(filter, object{"bool": object{
+			"should": []interface{}{
+				object{"term": object{"visibility": "public"}},
+				object{"terms": object{"subjects": search.Subjects(p)}},
+			},
+			"minimum_should_match": 1,
+		}})
+	}
+
+	switch {
+	case len(params.Tags.Tags) == 0:
+	case params.Tags.Any:
+		filter = append(filter, object{"terms": object{"tags": params.Tags.Tags}})
+	default:
+		for _, tag := range params.Tags.Tags {
+			filter = append(filter, object{"term": object{"tags": tag}})
+		}
+	}
+
+	size := params.PageSize
+	if params.SkipCount {
+		size++
+	}
+	body := object{
+		"query":            object{"bool": object{"must": match, "filter": filter}},
+		"from":             (params.Page - 1) * params.PageSize,
+		"size":             size,
+		"sort":             sortFor(params.Sort),
+		"track_scores":     true,
+		"track_total_hits": !params.SkipCount,
+		"_source":          false,
+	}
+	if params.MinScore != nil {
+		body["min_score"] = *params.MinScore
+	}
+
+	var response struct {
+		Hits struct {
+			Total struct {
+				Value int `json:"value"`
+			} `json:"total"`
+			Hits []struct {
+				ID    string  `json:"_id"`
+				Score float64 `json:"_score"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/_search", body, &response); err != nil {
+		return search.Result{}, err
+	}
+
+	result := search.Result{Hits: make([]search.Hit, len(response.Hits.Hits))}
+	for i, hit := range response.Hits.Hits {
+		result.Hits[i] = search.Hit{ID: hit.ID, Score: hit.Score}
+	}
+	if !params.SkipCount {
+		result.Total = response.Hits.Total.Value
+	}
+	return result, nil
+}
+
+// queryFor maps a search to the query DSL: a relevance-ranked match on any of
+// its words, or a bool query with the same semantics as the database's boolean
+// mode, matching fields
+func queryFor(params types.SearchParams, fields []string) (object, error) {
+	if params.Mode != types.SearchModeBoolean {
+		match := object{"query": params.Query, "fields": fields}
+		if params.Fuzzy {
+			match["fuzziness"] = "AUTO"
+		}
+		return object{"multi_match": match}, nil
+	}
+
+	q, err := search.ParseBoolean(params.Query)
+	if err != nil {
+		return nil, err
+	}
+
+	clauses := func(terms []search.BooleanTerm) []interface{} {
+		matches := make([]interface{}, len(terms))
+		for i, t := range terms {
+			m := object{"query": strings.Join(t.Words, " "), "fields": fields}
+			switch {
+			case t.Phrase():
+				m["type"] = "phrase"
+			case t.Prefix:
+				m["type"] = "phrase_prefix"
+			}
+			matches[i] = object{"multi_match": m}
+		}
+		return matches
+	}
+
+	query := object{
+		"must":     clauses(q.Filter(true, false)),
+		"must_not": clauses(q.Filter(false, true)),
+		"should":   clauses(q.Filter(false, false)),
+	}
+	if len(q.Filter(true, false)) == 0 {
+		query["minimum_should_match"] = 1
+	}
+	return object{"bool": query}, nil
+}
+
+// do sends a request to the index at path with body encoded as JSON, decoding
+// the response into out when it is not nil. Statuses other than 2xx fail, 404
+// with errNotFound.
+func (c *Client) do(ctx context.Context, method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(encoded)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.url+"/"+url.PathEscape(c.index)+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			slog.WarnContext(ctx, "Error closing elasticsearch response", "error", err)
+		}
+	}()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("elasticsearch %s %s: %w", method, path, errNotFound)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("elasticsearch %s %s: %s: %s", method, path, resp.Status, bytes.TrimSpace(detail))
+	}
+	if out != nil {
+		return json.NewDecoder(resp.Body).Decode(out)
+	}
+	return nil
+}
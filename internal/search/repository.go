@@ -0,0 +1,237 @@
+package search
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/yashjain/konnect/internal/auth"
+	"github.com/yashjain/konnect/internal/metrics"
+	"github.com/yashjain/konnect/internal/models"
+	"github.com/yashjain/konnect/internal/repository"
+	"github.com/yashjain/konnect/pkg/types"
+)
+
+// reindexBatchSize is how many services ReindexServices reads at once
+const reindexBatchSize = 500
+
+// IndexedRepository serves SearchServices from a Backend and keeps the Backend's
+// index in step with the writes of the repository it wraps. Every other method
+// is the wrapped repository's.
+//
+// Writes are indexed after they commit. An index failure is logged and counted
+// rather than failing the write, which has already happened; ReindexServices
+// rebuilds the whole index to repair it.
+type IndexedRepository struct {
+	repository.Repository
+	backend Backend
+}
+
+// NewIndexedRepository wraps repo to search through backend
+func NewIndexedRepository(repo repository.Repository, backend Backend) *IndexedRepository {
+	return &IndexedRepository{Repository: repo, backend: backend}
+}
+
+// SearchServices finds services through the backend, then reads them from the
+// repository so results are current and still visible to the principal. Browsing
+// needs no index, and deleted services are removed from it, so both are served
+// straight from the repository.
+func (r *IndexedRepository) SearchServices(ctx context.Context, p auth.Principal, params types.SearchParams) ([]models.Service, int, error) {
+	if params.Mode == types.SearchModeBrowse || params.IncludeDeleted {
+		return r.Repository.SearchServices(ctx, p, params)
+	}
+
+	result, err := r.backend.Search(ctx, p, params)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	ids := make([]string, len(result.Hits))
+	for i, hit := range result.Hits {
+		ids[i] = hit.ID
+	}
+	found, err := r.Repository.GetServicesByIDs(ctx, p, ids)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	byID := make(map[string]models.Service, len(found))
+	for _, s := range found {
+		byID[s.ID] = s
+	}
+
+	// Keep the backend's order; hits the repository no longer returns are stale
+	services := make([]models.Service, 0, len(result.Hits))
+	for _, hit := range result.Hits {
+		if s, ok := byID[hit.ID]; ok {
+			score := hit.Score
+			s.Score = &score
+			services = append(services, s)
+		}
+	}
+	return services, result.Total, nil
+}
+
+// CreateService creates a service and indexes it
+func (r *IndexedRepository) CreateService(ctx context.Context, service *models.Service, grants ...models.ServiceACL) error {
+	if err := r.Repository.CreateService(ctx, service, grants...); err != nil {
+		return err
+	}
+	r.reindex(ctx, service.OrgID, service.ID)
+	return nil
+}
+
+// UpdateService updates a service and reindexes it
+func (r *IndexedRepository) UpdateService(ctx context.Context, orgID, id string, service *models.Service) (int64, error) {
+	rowsAffected, err := r.Repository.UpdateService(ctx, orgID, id, service)
+	if err == nil && rowsAffected > 0 {
+		r.reindex(ctx, orgID, id)
+	}
+	return rowsAffected, err
+}
+
+// DeleteService deletes a service and removes it from the index
+func (r *IndexedRepository) DeleteService(ctx context.Context, orgID, id string) (int64, error) {
+	rowsAffected, err := r.Repository.DeleteService(ctx, orgID, id)
+	if err == nil && rowsAffected > 0 {
+		r.indexed(id, r.backend.Delete(ctx, id))
+	}
+	return rowsAffected, err
+}
+
+// CreateVersion creates a version and reindexes its service's version count
+func (r *IndexedRepository) CreateVersion(ctx context.Context, orgID string, version *models.Version) error {
+	if err := r.Repository.CreateVersion(ctx, orgID, version); err != nil {
+		return err
+	}
+	r.reindex(ctx, orgID, version.ServiceID)
+	return nil
+}
+
+// CreateServiceACL grants access to a service and reindexes who can find it
+func (r *IndexedRepository) CreateServiceACL(ctx context.Context, orgID string, acl *models.ServiceACL) error {
+	if err := r.Repository.CreateServiceACL(ctx, orgID, acl); err != nil {
+		return err
+	}
+	r.reindex(ctx, orgID, acl.ServiceID)
+	return nil
+}
+
+// DeleteServiceACL revokes access to a service and reindexes who can find it
+func (r *IndexedRepository) DeleteServiceACL(ctx context.Context, orgID, serviceID, aclID string) (int64, error) {
+	deleted, err := r.Repository.DeleteServiceACL(ctx, orgID, serviceID, aclID)
+	if err == nil && deleted > 0 {
+		r.reindex(ctx, orgID, serviceID)
+	}
+	return deleted, err
+}
+
+// RestoreBackup restores a backup and reindexes the services it holds, which
+// also covers the version counts of the versions it restores
+func (r *IndexedRepository) RestoreBackup(ctx context.Context, next func() (models.BackupRecord, error)) (models.RestoreResult, error) {
+	services := make(map[string]string)
+	result, err := r.Repository.RestoreBackup(ctx, func() (models.BackupRecord, error) {
+		record, err := next()
+		if err == nil && record.Type == models.BackupService && record.Service != nil {
+			services[record.Service.ID] = record.Service.OrgID
+		}
+		return record, err
+	})
+	if err != nil {
+		return result, err
+	}
+	for id, orgID := range services {
+		r.reindex(ctx, orgID, id)
+	}
+	return result, nil
+}
+
+// ArchiveVersions archives deleted versions and reindexes the services they
+// were archived from
+func (r *IndexedRepository) ArchiveVersions(ctx context.Context, before time.Time) (models.ArchiveResult, error) {
+	result, err := r.Repository.ArchiveVersions(ctx, before)
+	if err != nil {
+		return result, err
+	}
+	for id, orgID := range result.Services {
+		r.reindex(ctx, orgID, id)
+	}
+	return result, nil
+}
+
+// ReindexServices rebuilds the repository's own index, then indexes every service
+// in the backend and prunes the documents of services that no longer exist
+func (r *IndexedRepository) ReindexServices(ctx context.Context) error {
+	if err := r.Repository.ReindexServices(ctx); err != nil {
+		return err
+	}
+
+	started := time.Now()
+	afterID := ""
+	for {
+		services, err := r.Repository.ListAllServices(ctx, afterID, reindexBatchSize)
+		if err != nil {
+			return err
+		}
+		for _, s := range services {
+			doc, err := r.document(ctx, s)
+			if err != nil {
+				return err
+			}
+			if err := r.backend.Index(ctx, doc); err != nil {
+				return err
+			}
+		}
+		if len(services) < reindexBatchSize {
+			break
+		}
+		afterID = services[len(services)-1].ID
+	}
+
+	return r.backend.Prune(ctx, started)
+}
+
+// reindex indexes the current state of a service, or removes it from the index
+// when it is deleted or gone, logging any failure
+func (r *IndexedRepository) reindex(ctx context.Context, orgID, id string) {
+	service, err := r.Repository.GetServiceByID(ctx, orgID, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		r.indexed(id, r.backend.Delete(ctx, id))
+		return
+	}
+	if err != nil {
+		r.indexed(id, err)
+		return
+	}
+	doc, err := r.document(ctx, *service)
+	if err == nil {
+		err = r.backend.Index(ctx, doc)
+	}
+	r.indexed(id, err)
+}
+
+// document builds the backend document of a service
+func (r *IndexedRepository) document(ctx context.Context, s models.Service) (Document, error) {
+	acls, err := r.Repository.GetServiceACLs(ctx, s.OrgID, s.ID)
+	if err != nil {
+		return Document{}, err
+	}
+
+	doc := Document{Service: s, IndexedAt: time.Now()}
+	for _, acl := range acls {
+		doc.Subjects = append(doc.Subjects, Subject(acl.SubjectType, acl.SubjectID))
+	}
+	return doc, nil
+}
+
+// indexed records the outcome of indexing a service after a write
+func (r *IndexedRepository) indexed(id string, err error) {
+	if err != nil {
+		metrics.SearchIndexUpdates.WithLabelValues("error").Inc()
+		slog.Error("Error indexing service", "service_id", id, "error", err)
+		return
+	}
+	metrics.SearchIndexUpdates.WithLabelValues("ok").Inc()
+}
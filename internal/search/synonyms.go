@@ -0,0 +1,150 @@
+package search
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/yashjain/konnect/internal/auth"
+	"github.com/yashjain/konnect/internal/models"
+	"github.com/yashjain/konnect/internal/repository"
+	"github.com/yashjain/konnect/pkg/types"
+	"github.com/yashjain/konnect/pkg/utils"
+)
+
+// SynonymRepository expands the terms of natural searches run through the
+// repository it wraps with their synonyms, so that "auth" also finds services
+// described as "authentication" or "oauth". Every other method is the wrapped
+// repository's.
+//
+// Synonyms are held in memory, reloaded from the repository every interval and
+// right after they are changed through it, so changes made through other
+// instances apply within the interval. Boolean searches are left as typed.
+type SynonymRepository struct {
+	repository.Repository
+	interval time.Duration
+
+	mu       sync.RWMutex
+	synonyms map[string][]string
+}
+
+// NewSynonymRepository wraps repo to expand searches with the synonyms it
+// stores, reloaded every interval by Run
+func NewSynonymRepository(repo repository.Repository, interval time.Duration) *SynonymRepository {
+	return &SynonymRepository{Repository: repo, interval: interval, synonyms: map[string][]string{}}
+}
+
+// Load reads the synonyms from the wrapped repository, replacing those in use
+func (r *SynonymRepository) Load(ctx context.Context) error {
+	all, err := r.Repository.GetSynonyms(ctx)
+	if err != nil {
+		return err
+	}
+
+	synonyms := make(map[string][]string, len(all))
+	for _, s := range all {
+		synonyms[s.Term] = s.Synonyms
+	}
+
+	r.mu.Lock()
+	r.synonyms = synonyms
+	r.mu.Unlock()
+	return nil
+}
+
+// Run reloads the synonyms every interval until ctx is done, keeping those in
+// use when a reload fails. A zero interval only loads them when they change.
+func (r *SynonymRepository) Run(ctx context.Context) {
+	if r.interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.Load(ctx); err != nil && ctx.Err() == nil {
+				slog.Warn("Error reloading search synonyms", "error", err)
+			}
+		}
+	}
+}
+
+// Expand appends the synonyms of each word of query that are not in it already
+func (r *SynonymRepository) Expand(query string) string {
+	words := strings.FieldsFunc(utils.Fold(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	seen := make(map[string]bool, len(words))
+	for _, w := range words {
+		seen[w] = true
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var extra []string
+	for _, w := range words {
+		for _, synonym := range r.synonyms[w] {
+			if !seen[synonym] {
+				seen[synonym] = true
+				extra = append(extra, synonym)
+			}
+		}
+	}
+	if len(extra) == 0 {
+		return query
+	}
+	return query + " " + strings.Join(extra, " ")
+}
+
+// expand rewrites the query of natural searches with Expand
+func (r *SynonymRepository) expand(params types.SearchParams) types.SearchParams {
+	if params.Mode != types.SearchModeBoolean && params.Mode != types.SearchModeBrowse {
+		params.Query = r.Expand(params.Query)
+	}
+	return params
+}
+
+// GetServices lists services, expanding any search they are narrowed by
+func (r *SynonymRepository) GetServices(ctx context.Context, p auth.Principal, params types.PaginationParams) ([]models.Service, int, error) {
+	if params.Query != "" {
+		params.Query = r.Expand(params.Query)
+	}
+	return r.Repository.GetServices(ctx, p, params)
+}
+
+// SearchServices searches the wrapped repository with the query expanded
+func (r *SynonymRepository) SearchServices(ctx context.Context, p auth.Principal, params types.SearchParams) ([]models.Service, int, error) {
+	return r.Repository.SearchServices(ctx, p, r.expand(params))
+}
+
+// SearchFacets counts the services SearchServices finds, with the query expanded alike
+func (r *SynonymRepository) SearchFacets(ctx context.Context, p auth.Principal, params types.SearchParams, facets []string) (map[string][]types.FacetBucket, error) {
+	return r.Repository.SearchFacets(ctx, p, r.expand(params), facets)
+}
+
+// SetSynonyms replaces the synonyms of a term and reloads them
+func (r *SynonymRepository) SetSynonyms(ctx context.Context, term string, synonyms []string) error {
+	if err := r.Repository.SetSynonyms(ctx, term, synonyms); err != nil {
+		return err
+	}
+	return r.Load(ctx)
+}
+
+// DeleteSynonyms removes the synonyms of a term and reloads them
+func (r *SynonymRepository) DeleteSynonyms(ctx context.Context, term string) (int64, error) {
+	deleted, err := r.Repository.DeleteSynonyms(ctx, term)
+	if err != nil {
+		return deleted, err
+	}
+	return deleted, r.Load(ctx)
+}
@@ -0,0 +1,245 @@
+// Package sentry reports errors to Sentry, or any service accepting Sentry
+// events, through its HTTP store API.
+package sentry
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"path"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/yashjain/konnect/internal/logging"
+)
+
+// queueSize bounds the events waiting to be sent; further events are dropped
+// rather than slowing down the requests that raised them
+const queueSize = 100
+
+// forwardedHeaders are the request headers sent with events; others may carry credentials
+var forwardedHeaders = []string{"Content-Type", "User-Agent", "X-Request-ID"}
+
+// Request describes the request an error happened in
+type Request struct {
+	Method    string
+	Path      string
+	Route     string
+	Headers   http.Header
+	RequestID string
+	OrgID     string
+	UserID    string
+}
+
+// Client sends events to one Sentry project
+type Client struct {
+	storeURL    string
+	auth        string
+	environment string
+	http        *http.Client
+	events      chan event
+}
+
+// event is a Sentry event, in the store API's format
+type event struct {
+	EventID     string            `json:"event_id"`
+	Timestamp   string            `json:"timestamp"`
+	Platform    string            `json:"platform"`
+	Level       string            `json:"level"`
+	Environment string            `json:"environment,omitempty"`
+	Exception   exceptions        `json:"exception"`
+	Request     *eventRequest     `json:"request,omitempty"`
+	Tags        map[string]string `json:"tags,omitempty"`
+	User        map[string]string `json:"user,omitempty"`
+}
+
+type exceptions struct {
+	Values []exception `json:"values"`
+}
+
+type exception struct {
+	Type       string     `json:"type"`
+	Value      string     `json:"value"`
+	Stacktrace stacktrace `json:"stacktrace"`
+}
+
+type stacktrace struct {
+	Frames []frame `json:"frames"`
+}
+
+type frame struct {
+	Function string `json:"function"`
+	Module   string `json:"module,omitempty"`
+	Filename string `json:"filename"`
+	AbsPath  string `json:"abs_path"`
+	Lineno   int    `json:"lineno"`
+	InApp    bool   `json:"in_app"`
+}
+
+type eventRequest struct {
+	Method  string            `json:"method"`
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// New returns a client for the project of dsn, formatted as
+// https://<key>@<host>/<project>, tagging events with environment
+func New(dsn, environment string, timeout time.Duration) (*Client, error) {
+	u, err := url.Parse(dsn)
+	if err != nil || u.User == nil || u.User.Username() == "" || u.Host == "" {
+		return nil, errors.New("invalid Sentry DSN: must be https://<key>@<host>/<project>")
+	}
+	prefix, project := path.Split(strings.TrimSuffix(u.Path, "/"))
+	if project == "" {
+		return nil, errors.New("invalid Sentry DSN: missing project")
+	}
+
+	return &Client{
+		storeURL:    fmt.Sprintf("%s://%s%sapi/%s/store/", u.Scheme, u.Host, prefix, project),
+		auth:        "Sentry sentry_version=7, sentry_client=konnect/1.0, sentry_key=" + u.User.Username(),
+		environment: environment,
+		http:        &http.Client{Timeout: timeout},
+		events:      make(chan event, queueSize),
+	}, nil
+}
+
+// Run sends captured events until ctx is done
+func (c *Client) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case e := <-c.events:
+			if err := c.send(ctx, e); err != nil && ctx.Err() == nil {
+				slog.Warn("Error reporting to Sentry", "event_id", e.EventID, "error", err)
+			}
+		}
+	}
+}
+
+// Capture queues err, raised in req at the call stack stack (program counters
+// as returned by runtime.Callers), to be sent by Run. The error message is
+// redacted like logs, and only a few harmless request headers are sent.
+func (c *Client) Capture(err error, stack []uintptr, req Request) {
+	e := event{
+		EventID:     eventID(),
+		Timestamp:   time.Now().UTC().Format(time.RFC3339),
+		Platform:    "go",
+		Level:       "error",
+		Environment: c.environment,
+		Exception: exceptions{Values: []exception{{
+			Type:       fmt.Sprintf("%T", err),
+			Value:      logging.Redact(err.Error()),
+			Stacktrace: stacktrace{Frames: frames(stack)},
+		}}},
+		Request: &eventRequest{Method: req.Method, URL: req.Path, Headers: map[string]string{}},
+		Tags:    map[string]string{},
+	}
+	for _, name := range forwardedHeaders {
+		if value := req.Headers.Get(name); value != "" {
+			e.Request.Headers[name] = logging.Redact(value)
+		}
+	}
+	if req.Route != "" {
+		e.Tags["route"] = req.Route
+	}
+	if req.RequestID != "" {
+		e.Tags["request_id"] = req.RequestID
+	}
+	if req.OrgID != "" {
+		e.Tags["org_id"] = req.OrgID
+		e.User = map[string]string{"id": req.UserID}
+	}
+
+	select {
+	case c.events <- e:
+	default:
+		slog.Warn("Sentry queue full, dropping event", "event_id", e.EventID)
+	}
+}
+
+// send posts one event to the store API
+func (c *Client) send(ctx context.Context, e event) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.storeURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Sentry-Auth", c.auth)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			slog.Warn("Error closing Sentry response", "error", err)
+		}
+	}()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("sentry: %s: %s", resp.Status, bytes.TrimSpace(detail))
+	}
+	return nil
+}
+
+// frames turns program counters, innermost first, into Sentry frames, which
+// are listed outermost first. Frames of this module are marked in-app.
+func frames(stack []uintptr) []frame {
+	var out []frame
+	callers := runtime.CallersFrames(stack)
+	for {
+		f, more := callers.Next()
+		if f.Function != "" {
+			module, function := splitFunction(f.Function)
+			out = append(out, frame{
+				Function: function,
+				Module:   module,
+				Filename: path.Base(f.File),
+				AbsPath:  f.File,
+				Lineno:   f.Line,
+				InApp:    strings.HasPrefix(module, "github.com/yashjain/konnect"),
+			})
+		}
+		if !more {
+			break
+		}
+	}
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return out
+}
+
+// splitFunction splits a qualified function name such as
+// "github.com/yashjain/konnect/internal/handlers.GetServices.func1" into its
+// package and the function within it
+func splitFunction(name string) (string, string) {
+	slash := strings.LastIndex(name, "/")
+	dot := strings.Index(name[slash+1:], ".")
+	if dot < 0 {
+		return "", name
+	}
+	return name[:slash+1+dot], name[slash+1+dot+1:]
+}
+
+// eventID returns a random 32-character hex event ID
+func eventID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,121 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/yashjain/konnect/internal/models"
+)
+
+// maxTemplateLength bounds the template of a chat subscription
+const maxTemplateLength = 4096
+
+// Message is what a chat message template is executed with
+type Message struct {
+	Event models.Event
+
+	// Service is the event's service, and of version events the version's;
+	// Version is only set for version events
+	Service models.Service
+	Version models.Version
+
+	// URL links to the service, and is empty when no service URL is configured
+	URL string
+}
+
+// defaultTemplates are the messages of each event type when a subscription has no template
+var defaultTemplates = map[string]string{
+	models.EventServiceCreated:    `Service {{link .URL .Service.Name}} was created`,
+	models.EventServiceUpdated:    `Service {{link .URL .Service.Name}} was updated`,
+	models.EventServiceDeleted:    `Service {{.Service.Name}} was deleted`,
+	models.EventVersionCreated:    `Version {{.Version.Semver}} of {{link .URL .Service.Name}} was created`,
+	models.EventVersionUpdated:    `Version {{.Version.Semver}} of {{link .URL .Service.Name}} was updated`,
+	models.EventVersionReleased:   `Version {{.Version.Semver}} of {{link .URL .Service.Name}} was released{{with .Version.Changelog}}: {{.}}{{end}}`,
+	models.EventVersionDeprecated: `Version {{.Version.Semver}} of {{link .URL .Service.Name}} was deprecated`,
+	models.EventWebhookTest:       `Test message from the service catalog`,
+}
+
+// ParseTemplate parses the template of a chat subscription. Templates may use
+// {{link url text}}, which renders a link in the chat's markup, or only the
+// text when the URL is empty.
+func ParseTemplate(text string) (*template.Template, error) {
+	if len(text) > maxTemplateLength {
+		return nil, fmt.Errorf("template must be at most %d characters", maxTemplateLength)
+	}
+	// The link function is replaced with the chat's own when rendering
+	return template.New("message").Option("missingkey=zero").Funcs(template.FuncMap{"link": slackLink}).Parse(text)
+}
+
+// RenderChat renders msg with text, or the default template of its event type
+// when text is empty, into the body of a Slack or Microsoft Teams incoming
+// webhook request
+func RenderChat(format, text string, msg Message) ([]byte, error) {
+	if text == "" {
+		text = defaultTemplates[msg.Event.Type]
+	}
+	tmpl, err := ParseTemplate(text)
+	if err != nil {
+		return nil, err
+	}
+
+	var b strings.Builder
+	switch format {
+	case models.WebhookFormatSlack:
+		if err := tmpl.Funcs(template.FuncMap{"link": slackLink}).Execute(&b, slackEscape(msg)); err != nil {
+			return nil, err
+		}
+		return json.Marshal(map[string]string{"text": b.String()})
+	case models.WebhookFormatTeams:
+		if err := tmpl.Funcs(template.FuncMap{"link": teamsLink}).Execute(&b, msg); err != nil {
+			return nil, err
+		}
+		return json.Marshal(teamsCard(b.String()))
+	}
+	return nil, fmt.Errorf("unknown chat format %q", format)
+}
+
+// slackEscaper escapes the characters Slack's mrkdwn reserves for links and mentions
+var slackEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// slackEscape escapes the text of the service and version, so that names and
+// changelogs cannot mention channels or forge links
+func slackEscape(msg Message) Message {
+	msg.Service.Name = slackEscaper.Replace(msg.Service.Name)
+	msg.Service.Description = slackEscaper.Replace(msg.Service.Description)
+	msg.Version.Changelog = slackEscaper.Replace(msg.Version.Changelog)
+	return msg
+}
+
+// slackLink renders a link in Slack's mrkdwn
+func slackLink(url, text string) string {
+	if url == "" {
+		return text
+	}
+	return "<" + url + "|" + text + ">"
+}
+
+// teamsLink renders a link in the Markdown of Adaptive Card text blocks
+func teamsLink(url, text string) string {
+	if url == "" {
+		return text
+	}
+	return "[" + text + "](" + url + ")"
+}
+
+// teamsCard wraps text in the Adaptive Card message Teams incoming webhooks accept
+func teamsCard(text string) map[string]interface{} {
+	return map[string]interface{}{
+		"type": "message",
+		"attachments": []interface{}{map[string]interface{}{
+			"contentType": "application/vnd.microsoft.card.adaptive",
+			"content": map[string]interface{}{
+				"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+				"type":    "AdaptiveCard",
+				"version": "1.4",
+				"body":    []interface{}{map[string]interface{}{"type": "TextBlock", "text": text, "wrap": true}},
+			},
+		}},
+	}
+}
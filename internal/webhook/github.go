@@ -0,0 +1,30 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// Headers set by GitHub on every event delivery
+const (
+	HeaderGitHubSignature = "X-Hub-Signature-256"
+	HeaderGitHubEvent     = "X-GitHub-Event"
+)
+
+// VerifyGitHub checks the X-Hub-Signature-256 header of an event delivered by
+// GitHub, "sha256=<hex digest>" of the HMAC-SHA256 of the raw payload
+func VerifyGitHub(secret, header string, payload []byte) error {
+	signature, ok := strings.CutPrefix(header, "sha256=")
+	if !ok {
+		return ErrInvalidSignature
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	if !hmac.Equal([]byte(signature), []byte(hex.EncodeToString(mac.Sum(nil)))) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Headers set on every webhook delivery
+const (
+	HeaderSignature = "X-Signature"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderID        = "X-Webhook-ID"
+)
+
+// HeaderEvent names the event type of a delivery to a webhook subscription
+const HeaderEvent = "X-Webhook-Event"
+
+// DefaultTolerance is how far a delivery timestamp may drift before it is rejected as a replay
+const DefaultTolerance = 5 * time.Minute
+
+var (
+	// ErrInvalidSignature is returned when no signature in the header matches the payload
+	ErrInvalidSignature = errors.New("webhook signature mismatch")
+
+	// ErrTimestampOutOfRange is returned when a delivery is older or newer than the tolerance allows
+	ErrTimestampOutOfRange = errors.New("webhook timestamp outside tolerance")
+
+	// ErrReplayed is returned when a delivery ID has already been accepted
+	ErrReplayed = errors.New("webhook delivery already processed")
+)
+
+// Sign computes the signature header value for a payload sent at timestamp.
+// The HMAC-SHA256 covers "<unix timestamp>.<payload>" so the timestamp cannot be altered
+// independently of the body; the result has the form "t=<unix>,v1=<hex digest>".
+func Sign(secret string, timestamp time.Time, payload []byte) string {
+	ts := strconv.FormatInt(timestamp.Unix(), 10)
+	return "t=" + ts + ",v1=" + digest(secret, ts, payload)
+}
+
+// SignRequest sets the signature, timestamp and delivery ID headers on an outgoing delivery
+func SignRequest(req *http.Request, secret, deliveryID string, payload []byte) {
+	now := time.Now()
+	req.Header.Set(HeaderID, deliveryID)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(now.Unix(), 10))
+	req.Header.Set(HeaderSignature, Sign(secret, now, payload))
+}
+
+// ReplayGuard remembers delivery IDs that have already been accepted
+type ReplayGuard interface {
+	// Seen records id and reports whether it had been recorded before
+	Seen(id string) bool
+}
+
+// Verify checks a received delivery. Receivers should pass the raw request body,
+// the X-Signature header and, optionally, the X-Webhook-ID header and a ReplayGuard.
+// Multiple v1 entries are accepted so secrets can be rotated without downtime.
+func Verify(secret, header string, payload []byte, tolerance time.Duration, now time.Time, deliveryID string, guard ReplayGuard) error {
+	ts, signatures, err := parseHeader(header)
+	if err != nil {
+		return err
+	}
+
+	sec, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid webhook timestamp: %w", err)
+	}
+	if d := now.Sub(time.Unix(sec, 0)); d > tolerance || d < -tolerance {
+		return ErrTimestampOutOfRange
+	}
+
+	expected := digest(secret, ts, payload)
+	matched := false
+	for _, sig := range signatures {
+		if hmac.Equal([]byte(sig), []byte(expected)) {
+			matched = true
+			break
+		}
+	}
+	if !matched {
+		return ErrInvalidSignature
+	}
+
+	if guard != nil && deliveryID != "" && guard.Seen(deliveryID) {
+		return ErrReplayed
+	}
+	return nil
+}
+
+// digest returns the hex HMAC-SHA256 of "<timestamp>.<payload>"
+func digest(secret, timestamp string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// parseHeader splits "t=<unix>,v1=<sig>[,v1=<sig>...]"
+func parseHeader(header string) (string, []string, error) {
+	var ts string
+	var signatures []string
+	for _, part := range strings.Split(header, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "t":
+			ts = value
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+	if ts == "" || len(signatures) == 0 {
+		return "", nil, ErrInvalidSignature
+	}
+	return ts, signatures, nil
+}
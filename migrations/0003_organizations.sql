@@ -0,0 +1,48 @@
+-- +goose Up
+CREATE TABLE organizations (
+  id          CHAR(36)     NOT NULL,
+  name        VARCHAR(255) NOT NULL,
+  slug        VARCHAR(255) NOT NULL,
+  created_at  TIMESTAMP    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (id),
+  UNIQUE KEY uq_organizations_slug (slug)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+CREATE TABLE api_tokens (
+  id          CHAR(36)     NOT NULL,
+  org_id      CHAR(36)     NOT NULL,
+  name        VARCHAR(255) NOT NULL,
+  token_hash  CHAR(64)     NOT NULL,
+  created_at  TIMESTAMP    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (id),
+  UNIQUE KEY uq_api_tokens_hash (token_hash),
+  KEY idx_api_tokens_org_id (org_id),
+  CONSTRAINT fk_api_tokens_org FOREIGN KEY (org_id) REFERENCES organizations(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+-- Existing services are adopted by a default organization
+INSERT INTO organizations (id, name, slug) VALUES
+  ('00000000-0000-0000-0000-000000000001', 'Default', 'default');
+
+ALTER TABLE services ADD COLUMN org_id CHAR(36) NOT NULL DEFAULT '00000000-0000-0000-0000-000000000001' AFTER id;
+ALTER TABLE services ALTER COLUMN org_id DROP DEFAULT;
+
+-- Names and slugs only need to be unique within an organization
+ALTER TABLE services
+  DROP INDEX uq_services_name,
+  DROP INDEX uq_services_slug,
+  ADD UNIQUE KEY uq_services_org_name (org_id, name),
+  ADD UNIQUE KEY uq_services_org_slug (org_id, slug),
+  ADD CONSTRAINT fk_services_org FOREIGN KEY (org_id) REFERENCES organizations(id) ON DELETE CASCADE;
+
+-- +goose Down
+ALTER TABLE services
+  DROP FOREIGN KEY fk_services_org,
+  DROP INDEX uq_services_org_name,
+  DROP INDEX uq_services_org_slug,
+  ADD UNIQUE KEY uq_services_name (name),
+  ADD UNIQUE KEY uq_services_slug (slug),
+  DROP COLUMN org_id;
+
+DROP TABLE IF EXISTS api_tokens;
+DROP TABLE IF EXISTS organizations;
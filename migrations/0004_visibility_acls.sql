@@ -0,0 +1,59 @@
+-- +goose Up
+CREATE TABLE users (
+  id          CHAR(36)     NOT NULL,
+  org_id      CHAR(36)     NOT NULL,
+  email       VARCHAR(255) NOT NULL,
+  name        VARCHAR(255) NOT NULL,
+  created_at  TIMESTAMP    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (id),
+  UNIQUE KEY uq_users_email (email),
+  KEY idx_users_org_id (org_id),
+  CONSTRAINT fk_users_org FOREIGN KEY (org_id) REFERENCES organizations(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+CREATE TABLE teams (
+  id          CHAR(36)     NOT NULL,
+  org_id      CHAR(36)     NOT NULL,
+  name        VARCHAR(255) NOT NULL,
+  created_at  TIMESTAMP    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (id),
+  UNIQUE KEY uq_teams_org_name (org_id, name),
+  CONSTRAINT fk_teams_org FOREIGN KEY (org_id) REFERENCES organizations(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+CREATE TABLE team_members (
+  team_id     CHAR(36)     NOT NULL,
+  user_id     CHAR(36)     NOT NULL,
+  PRIMARY KEY (team_id, user_id),
+  KEY idx_team_members_user_id (user_id),
+  CONSTRAINT fk_team_members_team FOREIGN KEY (team_id) REFERENCES teams(id) ON DELETE CASCADE,
+  CONSTRAINT fk_team_members_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+-- Tokens without a user act for the whole organization
+ALTER TABLE api_tokens
+  ADD COLUMN user_id CHAR(36) NULL AFTER org_id,
+  ADD CONSTRAINT fk_api_tokens_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
+
+ALTER TABLE services ADD COLUMN visibility ENUM('public','private') NOT NULL DEFAULT 'public' AFTER description;
+
+CREATE TABLE service_acls (
+  id            CHAR(36)    NOT NULL,
+  service_id    CHAR(36)    NOT NULL,
+  subject_type  ENUM('user','team') NOT NULL,
+  subject_id    CHAR(36)    NOT NULL,
+  permission    ENUM('read','write') NOT NULL,
+  created_at    TIMESTAMP   NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (id),
+  UNIQUE KEY uq_service_acls_subject (service_id, subject_type, subject_id),
+  KEY idx_service_acls_subject (subject_type, subject_id),
+  CONSTRAINT fk_service_acls_service FOREIGN KEY (service_id) REFERENCES services(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+-- +goose Down
+DROP TABLE IF EXISTS service_acls;
+ALTER TABLE services DROP COLUMN visibility;
+ALTER TABLE api_tokens DROP FOREIGN KEY fk_api_tokens_user, DROP COLUMN user_id;
+DROP TABLE IF EXISTS team_members;
+DROP TABLE IF EXISTS teams;
+DROP TABLE IF EXISTS users;
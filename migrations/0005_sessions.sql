@@ -0,0 +1,23 @@
+-- +goose Up
+ALTER TABLE users ADD COLUMN password_hash VARCHAR(255) NULL AFTER name;
+
+-- Each row is one refresh token. Rotating a token revokes its row and inserts a
+-- successor in the same family; reusing a revoked token revokes the whole family.
+CREATE TABLE sessions (
+  id                  CHAR(36)  NOT NULL,
+  family_id           CHAR(36)  NOT NULL,
+  user_id             CHAR(36)  NOT NULL,
+  refresh_token_hash  CHAR(64)  NOT NULL,
+  expires_at          TIMESTAMP NOT NULL,
+  revoked_at          TIMESTAMP NULL,
+  created_at          TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (id),
+  UNIQUE KEY uq_sessions_refresh_token_hash (refresh_token_hash),
+  KEY idx_sessions_family_id (family_id),
+  KEY idx_sessions_user_id (user_id),
+  CONSTRAINT fk_sessions_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+-- +goose Down
+DROP TABLE IF EXISTS sessions;
+ALTER TABLE users DROP COLUMN password_hash;
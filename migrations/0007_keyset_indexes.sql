@@ -0,0 +1,8 @@
+-- +goose Up
+-- Serve keyset pagination, which lists rows newest first by created_at and then id
+CREATE INDEX idx_services_org_created ON services (org_id, created_at, id);
+CREATE INDEX idx_versions_service_created ON versions (service_id, created_at, id);
+
+-- +goose Down
+DROP INDEX idx_versions_service_created ON versions;
+DROP INDEX idx_services_org_created ON services;
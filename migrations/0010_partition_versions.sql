@@ -0,0 +1,36 @@
+-- +goose Up
+-- Hash-partition versions by service so that version queries, which all filter on
+-- service_id, read a single partition however large the table grows. MySQL requires
+-- the partition key in every unique key and does not allow foreign keys on
+-- partitioned tables, so versions of removed services are cleared by archiving
+-- rather than by cascade.
+ALTER TABLE versions DROP FOREIGN KEY fk_versions_service;
+ALTER TABLE versions
+  DROP INDEX idx_versions_service_id,
+  DROP PRIMARY KEY,
+  ADD PRIMARY KEY (service_id, id);
+ALTER TABLE versions PARTITION BY KEY (service_id) PARTITIONS 16;
+
+-- Archived versions are moved here, out of the hot table
+CREATE TABLE versions_archive (
+  id           CHAR(36)    NOT NULL,
+  service_id   CHAR(36)    NOT NULL,
+  semver       VARCHAR(64) NOT NULL,
+  status       ENUM('draft','released','deprecated') NOT NULL,
+  changelog    TEXT NULL,
+  created_at   TIMESTAMP   NOT NULL,
+  deleted_at   TIMESTAMP   NULL,
+  archived_at  TIMESTAMP   NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (id),
+  KEY idx_versions_archive_service_id (service_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+-- +goose Down
+DROP TABLE IF EXISTS versions_archive;
+ALTER TABLE versions REMOVE PARTITIONING;
+ALTER TABLE versions
+  DROP PRIMARY KEY,
+  ADD PRIMARY KEY (id),
+  ADD KEY idx_versions_service_id (service_id);
+DELETE FROM versions WHERE service_id NOT IN (SELECT id FROM services);
+ALTER TABLE versions ADD CONSTRAINT fk_versions_service FOREIGN KEY (service_id) REFERENCES services(id) ON DELETE CASCADE;
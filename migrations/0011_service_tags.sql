@@ -0,0 +1,12 @@
+-- +goose Up
+-- The (tag, service_id) key serves tag filters, which look services up by tag
+CREATE TABLE service_tags (
+  service_id  CHAR(36)    NOT NULL,
+  tag         VARCHAR(40) NOT NULL,
+  PRIMARY KEY (service_id, tag),
+  KEY idx_service_tags_tag (tag, service_id),
+  CONSTRAINT fk_service_tags_service FOREIGN KEY (service_id) REFERENCES services(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+-- +goose Down
+DROP TABLE IF EXISTS service_tags;
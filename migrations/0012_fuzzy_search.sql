@@ -0,0 +1,7 @@
+-- +goose Up
+-- Fuzzy search matches names by their shared n-grams, so a misspelled name still
+-- shares most of them with the correct one
+ALTER TABLE services ADD FULLTEXT INDEX ft_services_name_ngram (name) WITH PARSER ngram;
+
+-- +goose Down
+ALTER TABLE services DROP INDEX ft_services_name_ngram;
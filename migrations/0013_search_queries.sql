@@ -0,0 +1,16 @@
+-- +goose Up
+-- A sample of the searches run in each organization, for search analytics. The
+-- (org_id, searched_at) key serves reports over a recent window.
+CREATE TABLE search_queries (
+  seq          BIGINT       NOT NULL AUTO_INCREMENT,
+  org_id       CHAR(36)     NOT NULL,
+  query        VARCHAR(255) NOT NULL,
+  hits         INT          NOT NULL,
+  searched_at  TIMESTAMP    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (seq),
+  KEY idx_search_queries_org_searched_at (org_id, searched_at),
+  CONSTRAINT fk_search_queries_org FOREIGN KEY (org_id) REFERENCES organizations(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+-- +goose Down
+DROP TABLE IF EXISTS search_queries;
@@ -0,0 +1,16 @@
+-- +goose Up
+-- Searches weigh matches in each field separately, so each field gets a FULLTEXT
+-- index of its own. MySQL picks the index whose columns match a MATCH() exactly,
+-- so the fuzzy n-gram index moves to (name, slug) to stay apart from the name one.
+ALTER TABLE services DROP INDEX ft_services_name_ngram;
+ALTER TABLE services ADD FULLTEXT INDEX ft_services_name_slug_ngram (name, slug) WITH PARSER ngram;
+ALTER TABLE services ADD FULLTEXT INDEX ft_services_name (name);
+ALTER TABLE services ADD FULLTEXT INDEX ft_services_slug (slug);
+ALTER TABLE services ADD FULLTEXT INDEX ft_services_description (description);
+
+-- +goose Down
+ALTER TABLE services DROP INDEX ft_services_description;
+ALTER TABLE services DROP INDEX ft_services_slug;
+ALTER TABLE services DROP INDEX ft_services_name;
+ALTER TABLE services DROP INDEX ft_services_name_slug_ngram;
+ALTER TABLE services ADD FULLTEXT INDEX ft_services_name_ngram (name) WITH PARSER ngram;
@@ -0,0 +1,42 @@
+-- +goose Up
+-- Webhook subscriptions receive an organization's events of the types they list,
+-- stored comma-separated. Secrets are written through field-level encryption.
+CREATE TABLE webhook_subscriptions (
+  id           CHAR(36)      NOT NULL,
+  org_id       CHAR(36)      NOT NULL,
+  url          VARCHAR(2048) NOT NULL,
+  secret       TEXT          NOT NULL,
+  event_types  VARCHAR(512)  NOT NULL,
+  active       BOOLEAN       NOT NULL DEFAULT TRUE,
+  created_at   TIMESTAMP     NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at   TIMESTAMP     NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (id),
+  KEY idx_webhook_subscriptions_org_id (org_id),
+  CONSTRAINT fk_webhook_subscriptions_org FOREIGN KEY (org_id) REFERENCES organizations(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+-- One row per event and subscription, written in the same transaction as the
+-- change, and kept after delivery as the subscription's delivery history.
+CREATE TABLE webhook_deliveries (
+  id               CHAR(36)    NOT NULL,
+  org_id           CHAR(36)    NOT NULL,
+  subscription_id  CHAR(36)    NOT NULL,
+  event_id         CHAR(36)    NOT NULL,
+  event_type       VARCHAR(64) NOT NULL,
+  payload          TEXT        NOT NULL,
+  status           VARCHAR(16) NOT NULL DEFAULT 'pending',
+  attempts         INT         NOT NULL DEFAULT 0,
+  response_status  INT         NULL,
+  last_error       TEXT        NULL,
+  next_attempt_at  TIMESTAMP   NULL,
+  delivered_at     TIMESTAMP   NULL,
+  created_at       TIMESTAMP   NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (id),
+  KEY idx_webhook_deliveries_due (status, next_attempt_at),
+  KEY idx_webhook_deliveries_subscription (subscription_id, created_at),
+  CONSTRAINT fk_webhook_deliveries_subscription FOREIGN KEY (subscription_id) REFERENCES webhook_subscriptions(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+-- +goose Down
+DROP TABLE IF EXISTS webhook_deliveries;
+DROP TABLE IF EXISTS webhook_subscriptions;
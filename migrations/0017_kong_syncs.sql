@@ -0,0 +1,21 @@
+-- +goose Up
+-- The sync of each service to the Kong Gateway, queued in the same transaction
+-- as any change to the service or its versions and settled by the syncer. Each
+-- queueing bumps the revision, so an attempt that read an older one cannot settle it.
+CREATE TABLE kong_syncs (
+  service_id       CHAR(36)    NOT NULL,
+  org_id           CHAR(36)    NOT NULL,
+  status           VARCHAR(16) NOT NULL DEFAULT 'pending',
+  revision         INT         NOT NULL DEFAULT 0,
+  attempts         INT         NOT NULL DEFAULT 0,
+  last_error       TEXT        NULL,
+  next_attempt_at  TIMESTAMP   NULL,
+  synced_at        TIMESTAMP   NULL,
+  updated_at       TIMESTAMP   NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (service_id),
+  KEY idx_kong_syncs_due (status, next_attempt_at),
+  CONSTRAINT fk_kong_syncs_service FOREIGN KEY (service_id) REFERENCES services(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+-- +goose Down
+DROP TABLE IF EXISTS kong_syncs;
@@ -0,0 +1,19 @@
+-- +goose Up
+-- The GitHub repository whose releases become versions of each service. A
+-- repository is stored lowercased as owner/name and links to one service of an
+-- organization. Its events are signed with the link's secret, which only the
+-- repository's admins can configure on its webhook.
+CREATE TABLE github_repositories (
+  service_id  CHAR(36)     NOT NULL,
+  repository  VARCHAR(200) NOT NULL,
+  org_id      CHAR(36)     NOT NULL,
+  secret      TEXT         NOT NULL,
+  created_at  TIMESTAMP    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (service_id),
+  UNIQUE KEY uq_github_repositories_repository (org_id, repository),
+  KEY idx_github_repositories_repository (repository),
+  CONSTRAINT fk_github_repositories_service FOREIGN KEY (service_id) REFERENCES services(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+-- +goose Down
+DROP TABLE IF EXISTS github_repositories;
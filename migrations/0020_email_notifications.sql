@@ -0,0 +1,49 @@
+-- +goose Up
+-- Users' notification preferences; users without a row get every notification.
+CREATE TABLE notification_preferences (
+  user_id       CHAR(36)  NOT NULL,
+  org_id        CHAR(36)  NOT NULL,
+  deprecations  BOOLEAN   NOT NULL DEFAULT TRUE,
+  updated_at    TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (user_id),
+  CONSTRAINT fk_notification_preferences_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+-- Users subscribed to a service's notifications besides its owners
+CREATE TABLE service_subscribers (
+  service_id  CHAR(36)  NOT NULL,
+  user_id     CHAR(36)  NOT NULL,
+  org_id      CHAR(36)  NOT NULL,
+  created_at  TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (service_id, user_id),
+  KEY idx_service_subscribers_user_id (user_id),
+  CONSTRAINT fk_service_subscribers_service FOREIGN KEY (service_id) REFERENCES services(id) ON DELETE CASCADE,
+  CONSTRAINT fk_service_subscribers_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+-- One email per recipient of a notification, queued in the same transaction as
+-- the change it is about and settled by the notifier. Versions are partitioned,
+-- so version_id cannot reference them.
+CREATE TABLE email_notifications (
+  id               CHAR(36)    NOT NULL,
+  org_id           CHAR(36)    NOT NULL,
+  user_id          CHAR(36)    NOT NULL,
+  event_type       VARCHAR(64) NOT NULL,
+  service_id       CHAR(36)    NOT NULL,
+  version_id       CHAR(36)    NOT NULL,
+  status           VARCHAR(16) NOT NULL DEFAULT 'pending',
+  attempts         INT         NOT NULL DEFAULT 0,
+  last_error       TEXT        NULL,
+  next_attempt_at  TIMESTAMP   NULL,
+  sent_at          TIMESTAMP   NULL,
+  created_at       TIMESTAMP   NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (id),
+  KEY idx_email_notifications_due (status, next_attempt_at),
+  CONSTRAINT fk_email_notifications_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+  CONSTRAINT fk_email_notifications_service FOREIGN KEY (service_id) REFERENCES services(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+-- +goose Down
+DROP TABLE IF EXISTS email_notifications;
+DROP TABLE IF EXISTS service_subscribers;
+DROP TABLE IF EXISTS notification_preferences;
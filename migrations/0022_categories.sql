@@ -0,0 +1,29 @@
+-- +goose Up
+-- Categories form a tree per organization, a category without a parent being a
+-- root. Services are assigned to any number of categories; the (category_id,
+-- service_id) key serves category filters.
+CREATE TABLE categories (
+  id          CHAR(36)     NOT NULL,
+  org_id      CHAR(36)     NOT NULL,
+  parent_id   CHAR(36)     NULL,
+  name        VARCHAR(100) NOT NULL,
+  slug        VARCHAR(100) NOT NULL,
+  created_at  TIMESTAMP    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (id),
+  UNIQUE KEY uq_categories_org_slug (org_id, slug),
+  KEY idx_categories_parent (parent_id),
+  CONSTRAINT fk_categories_parent FOREIGN KEY (parent_id) REFERENCES categories(id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+CREATE TABLE service_categories (
+  service_id   CHAR(36) NOT NULL,
+  category_id  CHAR(36) NOT NULL,
+  PRIMARY KEY (service_id, category_id),
+  KEY idx_service_categories_category (category_id, service_id),
+  CONSTRAINT fk_service_categories_service FOREIGN KEY (service_id) REFERENCES services(id) ON DELETE CASCADE,
+  CONSTRAINT fk_service_categories_category FOREIGN KEY (category_id) REFERENCES categories(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+-- +goose Down
+DROP TABLE IF EXISTS service_categories;
+DROP TABLE IF EXISTS categories;
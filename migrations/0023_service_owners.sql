@@ -0,0 +1,15 @@
+-- +goose Up
+-- The team and email owning each service, empty when unowned. Owners are
+-- looked up within an organization, by team or by email.
+ALTER TABLE services
+  ADD COLUMN owner_team  VARCHAR(100) NOT NULL DEFAULT '',
+  ADD COLUMN owner_email VARCHAR(254) NOT NULL DEFAULT '',
+  ADD KEY idx_services_owner_team (org_id, owner_team),
+  ADD KEY idx_services_owner_email (org_id, owner_email);
+
+-- +goose Down
+ALTER TABLE services
+  DROP KEY idx_services_owner_email,
+  DROP KEY idx_services_owner_team,
+  DROP COLUMN owner_email,
+  DROP COLUMN owner_team;
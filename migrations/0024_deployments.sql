@@ -0,0 +1,32 @@
+-- +goose Up
+-- Deployments record each version deployed to an environment, such as prod,
+-- newest last. current_deployments holds the version each environment of a
+-- service runs now, the last one deployed there; the (environment, service_id)
+-- key serves deployed_in filters. Versions are partitioned, so neither table
+-- references them.
+CREATE TABLE deployments (
+  id           CHAR(36)    NOT NULL,
+  org_id       CHAR(36)    NOT NULL,
+  service_id   CHAR(36)    NOT NULL,
+  version_id   CHAR(36)    NOT NULL,
+  environment  VARCHAR(40) NOT NULL,
+  deployed_at  TIMESTAMP   NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (id),
+  KEY idx_deployments_version (version_id, deployed_at),
+  CONSTRAINT fk_deployments_service FOREIGN KEY (service_id) REFERENCES services(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+CREATE TABLE current_deployments (
+  service_id   CHAR(36)    NOT NULL,
+  environment  VARCHAR(40) NOT NULL,
+  version_id   CHAR(36)    NOT NULL,
+  deployed_at  TIMESTAMP   NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (service_id, environment),
+  KEY idx_current_deployments_version (version_id),
+  KEY idx_current_deployments_environment (environment, service_id),
+  CONSTRAINT fk_current_deployments_service FOREIGN KEY (service_id) REFERENCES services(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+-- +goose Down
+DROP TABLE IF EXISTS current_deployments;
+DROP TABLE IF EXISTS deployments;
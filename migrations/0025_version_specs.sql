@@ -0,0 +1,18 @@
+-- +goose Up
+-- Each version may have an OpenAPI document, stored as uploaded, JSON or YAML,
+-- with the SHA-256 digest of its content. Versions are partitioned, so the
+-- table references their service instead.
+CREATE TABLE version_specs (
+  version_id  CHAR(36)    NOT NULL,
+  service_id  CHAR(36)    NOT NULL,
+  format      VARCHAR(10) NOT NULL,
+  content     MEDIUMTEXT  NOT NULL,
+  digest      CHAR(64)    NOT NULL,
+  updated_at  TIMESTAMP   NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (version_id),
+  KEY idx_version_specs_service (service_id),
+  CONSTRAINT fk_version_specs_service FOREIGN KEY (service_id) REFERENCES services(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+-- +goose Down
+DROP TABLE IF EXISTS version_specs;
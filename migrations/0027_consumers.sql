@@ -0,0 +1,21 @@
+-- +goose Up
+-- Teams consuming a service, each either of every version, when version_id is
+-- NULL, or of a single one. Consumers are emailed about deprecations and keep
+-- their service from being deleted. Versions are partitioned, so version_id
+-- does not reference them.
+CREATE TABLE consumers (
+  id          CHAR(36)  NOT NULL,
+  org_id      CHAR(36)  NOT NULL,
+  service_id  CHAR(36)  NOT NULL,
+  team_id     CHAR(36)  NOT NULL,
+  version_id  CHAR(36)  NULL,
+  created_at  TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (id),
+  KEY idx_consumers_service (service_id, team_id),
+  KEY idx_consumers_team (team_id),
+  CONSTRAINT fk_consumers_service FOREIGN KEY (service_id) REFERENCES services(id) ON DELETE CASCADE,
+  CONSTRAINT fk_consumers_team FOREIGN KEY (team_id) REFERENCES teams(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+-- +goose Down
+DROP TABLE IF EXISTS consumers;
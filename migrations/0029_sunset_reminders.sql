@@ -0,0 +1,11 @@
+-- +goose Up
+-- When the recipients of a deprecated version were reminded that its sunset
+-- date approaches, so each is reminded once; rescheduling the sunset clears it.
+ALTER TABLE versions
+  ADD COLUMN sunset_reminded_at TIMESTAMP NULL,
+  ADD KEY idx_versions_sunset_at (sunset_at);
+
+-- +goose Down
+ALTER TABLE versions
+  DROP KEY idx_versions_sunset_at,
+  DROP COLUMN sunset_reminded_at;
@@ -0,0 +1,17 @@
+-- +goose Up
+-- When each version was first released, which release feeds date and order
+-- entries by; drafts have none. Versions released before this was recorded are
+-- dated from their creation.
+ALTER TABLE versions
+  ADD COLUMN released_at TIMESTAMP NULL,
+  ADD KEY idx_versions_released_at (released_at);
+ALTER TABLE versions_archive ADD COLUMN released_at TIMESTAMP NULL;
+
+UPDATE versions SET released_at = created_at WHERE status IN ('released', 'deprecated');
+UPDATE versions_archive SET released_at = created_at WHERE status IN ('released', 'deprecated');
+
+-- +goose Down
+ALTER TABLE versions_archive DROP COLUMN released_at;
+ALTER TABLE versions
+  DROP KEY idx_versions_released_at,
+  DROP COLUMN released_at;
@@ -0,0 +1,20 @@
+// Package migrations embeds the schema migrations that are applied by the
+// application itself rather than by the goose CLI.
+package migrations
+
+import "embed"
+
+// SQLite holds the SQLite migrations, applied on startup when DB_DRIVER=sqlite
+//
+//go:embed sqlite/*.sql
+var SQLite embed.FS
+
+// MySQL holds the MySQL migrations, applied on startup only in dev mode
+//
+//go:embed *.sql
+var MySQL embed.FS
+
+// Postgres holds the Postgres migrations, applied on startup only in dev mode
+//
+//go:embed postgres/*.sql
+var Postgres embed.FS
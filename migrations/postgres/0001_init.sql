@@ -0,0 +1,51 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE FUNCTION set_updated_at() RETURNS trigger AS $$
+BEGIN
+  NEW.updated_at = CURRENT_TIMESTAMP;
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TABLE services (
+  id            CHAR(36)     NOT NULL,
+  name          VARCHAR(255) NOT NULL,
+  slug          VARCHAR(255) NOT NULL,
+  description   TEXT NULL,
+  created_at    TIMESTAMPTZ  NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at    TIMESTAMPTZ  NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  -- Stands in for the MySQL FULLTEXT index on (name, description)
+  search_vector TSVECTOR GENERATED ALWAYS AS (
+    to_tsvector('english', name || ' ' || COALESCE(description, ''))
+  ) STORED,
+  PRIMARY KEY (id),
+  CONSTRAINT uq_services_name UNIQUE (name),
+  CONSTRAINT uq_services_slug UNIQUE (slug)
+);
+
+CREATE INDEX ft_services_name_desc ON services USING GIN (search_vector);
+
+CREATE TRIGGER trg_services_updated_at BEFORE UPDATE ON services
+  FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+
+CREATE TABLE versions (
+  id          CHAR(36)    NOT NULL,
+  service_id  CHAR(36)    NOT NULL,
+  semver      VARCHAR(64) NOT NULL,
+  status      VARCHAR(16) NOT NULL CHECK (status IN ('draft','released','deprecated')),
+  changelog   TEXT NULL,
+  created_at  TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (id),
+  CONSTRAINT fk_versions_service FOREIGN KEY (service_id) REFERENCES services(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_versions_service_id ON versions (service_id);
+CREATE INDEX idx_versions_status ON versions (status);
+
+ALTER TABLE services ADD COLUMN versions_count INT NOT NULL DEFAULT 0;
+
+-- +goose Down
+DROP TABLE IF EXISTS versions;
+DROP TABLE IF EXISTS services;
+DROP FUNCTION IF EXISTS set_updated_at();
@@ -0,0 +1,18 @@
+-- +goose Up
+INSERT INTO services (id, name, slug, description) VALUES
+  (gen_random_uuid(), 'Locate Us', 'locate-us', 'Store locator'),
+  (gen_random_uuid(), 'Collect Money', 'collect-money', 'Payments collection'),
+  (gen_random_uuid(), 'Notifications', 'notifications', 'Send push/email');
+
+INSERT INTO versions (id, service_id, semver, status, changelog) VALUES
+  (gen_random_uuid(), (SELECT id FROM services WHERE slug='notifications'), '1.0.0', 'released', 'Initial release'),
+  (gen_random_uuid(), (SELECT id FROM services WHERE slug='notifications'), '1.1.0', 'released', 'Minor improvements'),
+  (gen_random_uuid(), (SELECT id FROM services WHERE slug='collect-money'), '0.1.0', 'draft', 'WIP');
+
+UPDATE services SET versions_count = (
+  SELECT COUNT(*) FROM versions WHERE service_id = services.id
+);
+
+-- +goose Down
+DELETE FROM versions;
+DELETE FROM services;
@@ -0,0 +1,49 @@
+-- +goose Up
+CREATE TABLE organizations (
+  id          CHAR(36)     NOT NULL,
+  name        VARCHAR(255) NOT NULL,
+  slug        VARCHAR(255) NOT NULL,
+  created_at  TIMESTAMPTZ  NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (id),
+  CONSTRAINT uq_organizations_slug UNIQUE (slug)
+);
+
+CREATE TABLE api_tokens (
+  id          CHAR(36)     NOT NULL,
+  org_id      CHAR(36)     NOT NULL,
+  name        VARCHAR(255) NOT NULL,
+  token_hash  CHAR(64)     NOT NULL,
+  created_at  TIMESTAMPTZ  NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (id),
+  CONSTRAINT uq_api_tokens_hash UNIQUE (token_hash),
+  CONSTRAINT fk_api_tokens_org FOREIGN KEY (org_id) REFERENCES organizations(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_api_tokens_org_id ON api_tokens (org_id);
+
+-- Existing services are adopted by a default organization
+INSERT INTO organizations (id, name, slug) VALUES
+  ('00000000-0000-0000-0000-000000000001', 'Default', 'default');
+
+ALTER TABLE services ADD COLUMN org_id CHAR(36) NOT NULL DEFAULT '00000000-0000-0000-0000-000000000001';
+ALTER TABLE services ALTER COLUMN org_id DROP DEFAULT;
+
+-- Names and slugs only need to be unique within an organization
+ALTER TABLE services
+  DROP CONSTRAINT uq_services_name,
+  DROP CONSTRAINT uq_services_slug,
+  ADD CONSTRAINT uq_services_org_name UNIQUE (org_id, name),
+  ADD CONSTRAINT uq_services_org_slug UNIQUE (org_id, slug),
+  ADD CONSTRAINT fk_services_org FOREIGN KEY (org_id) REFERENCES organizations(id) ON DELETE CASCADE;
+
+-- +goose Down
+ALTER TABLE services
+  DROP CONSTRAINT fk_services_org,
+  DROP CONSTRAINT uq_services_org_name,
+  DROP CONSTRAINT uq_services_org_slug,
+  ADD CONSTRAINT uq_services_name UNIQUE (name),
+  ADD CONSTRAINT uq_services_slug UNIQUE (slug),
+  DROP COLUMN org_id;
+
+DROP TABLE IF EXISTS api_tokens;
+DROP TABLE IF EXISTS organizations;
@@ -0,0 +1,63 @@
+-- +goose Up
+CREATE TABLE users (
+  id          CHAR(36)     NOT NULL,
+  org_id      CHAR(36)     NOT NULL,
+  email       VARCHAR(255) NOT NULL,
+  name        VARCHAR(255) NOT NULL,
+  created_at  TIMESTAMPTZ  NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (id),
+  CONSTRAINT uq_users_email UNIQUE (email),
+  CONSTRAINT fk_users_org FOREIGN KEY (org_id) REFERENCES organizations(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_users_org_id ON users (org_id);
+
+CREATE TABLE teams (
+  id          CHAR(36)     NOT NULL,
+  org_id      CHAR(36)     NOT NULL,
+  name        VARCHAR(255) NOT NULL,
+  created_at  TIMESTAMPTZ  NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (id),
+  CONSTRAINT uq_teams_org_name UNIQUE (org_id, name),
+  CONSTRAINT fk_teams_org FOREIGN KEY (org_id) REFERENCES organizations(id) ON DELETE CASCADE
+);
+
+CREATE TABLE team_members (
+  team_id     CHAR(36)     NOT NULL,
+  user_id     CHAR(36)     NOT NULL,
+  PRIMARY KEY (team_id, user_id),
+  CONSTRAINT fk_team_members_team FOREIGN KEY (team_id) REFERENCES teams(id) ON DELETE CASCADE,
+  CONSTRAINT fk_team_members_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_team_members_user_id ON team_members (user_id);
+
+-- Tokens without a user act for the whole organization
+ALTER TABLE api_tokens
+  ADD COLUMN user_id CHAR(36) NULL,
+  ADD CONSTRAINT fk_api_tokens_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
+
+ALTER TABLE services ADD COLUMN visibility VARCHAR(16) NOT NULL DEFAULT 'public'
+  CHECK (visibility IN ('public','private'));
+
+CREATE TABLE service_acls (
+  id            CHAR(36)    NOT NULL,
+  service_id    CHAR(36)    NOT NULL,
+  subject_type  VARCHAR(16) NOT NULL CHECK (subject_type IN ('user','team')),
+  subject_id    CHAR(36)    NOT NULL,
+  permission    VARCHAR(16) NOT NULL CHECK (permission IN ('read','write')),
+  created_at    TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (id),
+  CONSTRAINT uq_service_acls_subject UNIQUE (service_id, subject_type, subject_id),
+  CONSTRAINT fk_service_acls_service FOREIGN KEY (service_id) REFERENCES services(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_service_acls_subject ON service_acls (subject_type, subject_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS service_acls;
+ALTER TABLE services DROP COLUMN visibility;
+ALTER TABLE api_tokens DROP CONSTRAINT fk_api_tokens_user, DROP COLUMN user_id;
+DROP TABLE IF EXISTS team_members;
+DROP TABLE IF EXISTS teams;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,24 @@
+-- +goose Up
+ALTER TABLE users ADD COLUMN password_hash VARCHAR(255) NULL;
+
+-- Each row is one refresh token. Rotating a token revokes its row and inserts a
+-- successor in the same family; reusing a revoked token revokes the whole family.
+CREATE TABLE sessions (
+  id                  CHAR(36)    NOT NULL,
+  family_id           CHAR(36)    NOT NULL,
+  user_id             CHAR(36)    NOT NULL,
+  refresh_token_hash  CHAR(64)    NOT NULL,
+  expires_at          TIMESTAMPTZ NOT NULL,
+  revoked_at          TIMESTAMPTZ NULL,
+  created_at          TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (id),
+  CONSTRAINT uq_sessions_refresh_token_hash UNIQUE (refresh_token_hash),
+  CONSTRAINT fk_sessions_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_sessions_family_id ON sessions (family_id);
+CREATE INDEX idx_sessions_user_id ON sessions (user_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS sessions;
+ALTER TABLE users DROP COLUMN password_hash;
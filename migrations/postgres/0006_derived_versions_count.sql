@@ -0,0 +1,9 @@
+-- +goose Up
+-- versions_count is now counted from the versions table on read
+ALTER TABLE services DROP COLUMN versions_count;
+
+-- +goose Down
+ALTER TABLE services ADD COLUMN versions_count INT NOT NULL DEFAULT 0;
+UPDATE services SET versions_count = (
+  SELECT COUNT(*) FROM versions WHERE service_id = services.id
+);
@@ -0,0 +1,11 @@
+-- +goose Up
+-- Deleted services and versions are kept with deleted_at set and filtered out on read
+ALTER TABLE services ADD COLUMN deleted_at TIMESTAMPTZ NULL;
+ALTER TABLE versions ADD COLUMN deleted_at TIMESTAMPTZ NULL;
+
+-- +goose Down
+-- Rows soft-deleted in the meantime would come back without the column
+DELETE FROM versions WHERE deleted_at IS NOT NULL;
+DELETE FROM services WHERE deleted_at IS NOT NULL;
+ALTER TABLE versions DROP COLUMN deleted_at;
+ALTER TABLE services DROP COLUMN deleted_at;
@@ -0,0 +1,20 @@
+-- +goose Up
+-- Change events are written here in the same transaction as the change, and
+-- deleted once the relay has delivered them. seq preserves the order of writes.
+CREATE TABLE outbox_events (
+  seq         BIGINT      GENERATED ALWAYS AS IDENTITY,
+  id          CHAR(36)    NOT NULL,
+  org_id      CHAR(36)    NOT NULL,
+  event_type  VARCHAR(64) NOT NULL,
+  subject_id  CHAR(36)    NOT NULL,
+  payload     TEXT        NOT NULL,
+  attempts    INT         NOT NULL DEFAULT 0,
+  last_error  TEXT        NULL,
+  created_at  TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (seq),
+  CONSTRAINT uq_outbox_events_id UNIQUE (id),
+  CONSTRAINT fk_outbox_events_org FOREIGN KEY (org_id) REFERENCES organizations(id) ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS outbox_events;
@@ -0,0 +1,75 @@
+-- +goose Up
+-- Hash-partition versions by service so that version queries, which all filter on
+-- service_id, read a single partition however large the table grows. The table is
+-- rebuilt, since an existing table cannot be partitioned in place.
+ALTER TABLE versions RENAME TO versions_unpartitioned;
+
+CREATE TABLE versions (
+  id          CHAR(36)    NOT NULL,
+  service_id  CHAR(36)    NOT NULL,
+  semver      VARCHAR(64) NOT NULL,
+  status      VARCHAR(16) NOT NULL CHECK (status IN ('draft','released','deprecated')),
+  changelog   TEXT NULL,
+  created_at  TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  deleted_at  TIMESTAMPTZ NULL
+) PARTITION BY HASH (service_id);
+
+-- +goose StatementBegin
+DO $$
+BEGIN
+  FOR i IN 0..15 LOOP
+    EXECUTE format('CREATE TABLE versions_p%s PARTITION OF versions FOR VALUES WITH (MODULUS 16, REMAINDER %s)', i, i);
+  END LOOP;
+END $$;
+-- +goose StatementEnd
+
+INSERT INTO versions (id, service_id, semver, status, changelog, created_at, deleted_at)
+SELECT id, service_id, semver, status, changelog, created_at, deleted_at FROM versions_unpartitioned;
+DROP TABLE versions_unpartitioned;
+
+ALTER TABLE versions
+  ADD CONSTRAINT versions_pkey PRIMARY KEY (service_id, id),
+  ADD CONSTRAINT fk_versions_service FOREIGN KEY (service_id) REFERENCES services(id) ON DELETE CASCADE;
+CREATE INDEX idx_versions_status ON versions (status);
+CREATE INDEX idx_versions_service_created ON versions (service_id, created_at, id);
+
+-- Archived versions are moved here, out of the hot table
+CREATE TABLE versions_archive (
+  id           CHAR(36)    NOT NULL,
+  service_id   CHAR(36)    NOT NULL,
+  semver       VARCHAR(64) NOT NULL,
+  status       VARCHAR(16) NOT NULL,
+  changelog    TEXT NULL,
+  created_at   TIMESTAMPTZ NOT NULL,
+  deleted_at   TIMESTAMPTZ NULL,
+  archived_at  TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (id)
+);
+
+CREATE INDEX idx_versions_archive_service_id ON versions_archive (service_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS versions_archive;
+
+ALTER TABLE versions RENAME TO versions_partitioned;
+
+CREATE TABLE versions (
+  id          CHAR(36)    NOT NULL,
+  service_id  CHAR(36)    NOT NULL,
+  semver      VARCHAR(64) NOT NULL,
+  status      VARCHAR(16) NOT NULL CHECK (status IN ('draft','released','deprecated')),
+  changelog   TEXT NULL,
+  created_at  TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  deleted_at  TIMESTAMPTZ NULL
+);
+
+INSERT INTO versions (id, service_id, semver, status, changelog, created_at, deleted_at)
+SELECT id, service_id, semver, status, changelog, created_at, deleted_at FROM versions_partitioned;
+DROP TABLE versions_partitioned;
+
+ALTER TABLE versions
+  ADD CONSTRAINT versions_pkey PRIMARY KEY (id),
+  ADD CONSTRAINT fk_versions_service FOREIGN KEY (service_id) REFERENCES services(id) ON DELETE CASCADE;
+CREATE INDEX idx_versions_service_id ON versions (service_id);
+CREATE INDEX idx_versions_status ON versions (status);
+CREATE INDEX idx_versions_service_created ON versions (service_id, created_at, id);
@@ -0,0 +1,10 @@
+-- +goose Up
+-- Serve case-insensitive prefix matches on names and slugs for typeahead. MySQL's
+-- case-insensitive collation lets its unique (org_id, name) and (org_id, slug)
+-- keys serve these already.
+CREATE INDEX idx_services_org_name_prefix ON services (org_id, lower(name) text_pattern_ops);
+CREATE INDEX idx_services_org_slug_prefix ON services (org_id, lower(slug) text_pattern_ops);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_services_org_slug_prefix;
+DROP INDEX IF EXISTS idx_services_org_name_prefix;
@@ -0,0 +1,13 @@
+-- +goose Up
+-- The (tag, service_id) index serves tag filters, which look services up by tag
+CREATE TABLE service_tags (
+  service_id  CHAR(36)    NOT NULL,
+  tag         VARCHAR(40) NOT NULL,
+  PRIMARY KEY (service_id, tag),
+  CONSTRAINT fk_service_tags_service FOREIGN KEY (service_id) REFERENCES services(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_service_tags_tag ON service_tags (tag, service_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS service_tags;
@@ -0,0 +1,8 @@
+-- +goose Up
+-- Fuzzy search matches names by their shared trigrams, so a misspelled name still
+-- shares most of them with the correct one
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+CREATE INDEX idx_services_name_trgm ON services USING GIN (name gin_trgm_ops);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_services_name_trgm;
@@ -0,0 +1,17 @@
+-- +goose Up
+-- A sample of the searches run in each organization, for search analytics. The
+-- (org_id, searched_at) index serves reports over a recent window.
+CREATE TABLE search_queries (
+  seq          BIGINT       GENERATED ALWAYS AS IDENTITY,
+  org_id       CHAR(36)     NOT NULL,
+  query        VARCHAR(255) NOT NULL,
+  hits         INT          NOT NULL,
+  searched_at  TIMESTAMPTZ  NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (seq),
+  CONSTRAINT fk_search_queries_org FOREIGN KEY (org_id) REFERENCES organizations(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_search_queries_org_searched_at ON search_queries (org_id, searched_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS search_queries;
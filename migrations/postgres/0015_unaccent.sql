@@ -0,0 +1,37 @@
+-- +goose Up
+-- Name and slug lookups ignore accents as well as case, like MySQL's
+-- utf8mb4_0900_ai_ci collation. unaccent() is only STABLE, as its dictionary could
+-- change, so an IMMUTABLE wrapper pinning the dictionary lets indexes use it.
+CREATE EXTENSION IF NOT EXISTS unaccent;
+
+-- +goose StatementBegin
+CREATE FUNCTION f_unaccent(text) RETURNS text AS $$
+  SELECT public.unaccent('public.unaccent'::regdictionary, $1)
+$$ LANGUAGE sql IMMUTABLE PARALLEL SAFE STRICT;
+-- +goose StatementEnd
+
+DROP INDEX IF EXISTS idx_services_org_name_prefix;
+DROP INDEX IF EXISTS idx_services_org_slug_prefix;
+CREATE INDEX idx_services_org_name_prefix ON services (org_id, lower(f_unaccent(name)) text_pattern_ops);
+CREATE INDEX idx_services_org_slug_prefix ON services (org_id, lower(f_unaccent(slug)) text_pattern_ops);
+
+-- Searches are folded the same way before they reach to_tsquery
+ALTER TABLE services DROP COLUMN search_vector;
+ALTER TABLE services ADD COLUMN search_vector TSVECTOR GENERATED ALWAYS AS (
+  to_tsvector('english', f_unaccent(name || ' ' || COALESCE(description, '')))
+) STORED;
+CREATE INDEX ft_services_name_desc ON services USING GIN (search_vector);
+
+-- +goose Down
+ALTER TABLE services DROP COLUMN search_vector;
+ALTER TABLE services ADD COLUMN search_vector TSVECTOR GENERATED ALWAYS AS (
+  to_tsvector('english', name || ' ' || COALESCE(description, ''))
+) STORED;
+CREATE INDEX ft_services_name_desc ON services USING GIN (search_vector);
+
+DROP INDEX IF EXISTS idx_services_org_slug_prefix;
+DROP INDEX IF EXISTS idx_services_org_name_prefix;
+CREATE INDEX idx_services_org_name_prefix ON services (org_id, lower(name) text_pattern_ops);
+CREATE INDEX idx_services_org_slug_prefix ON services (org_id, lower(slug) text_pattern_ops);
+
+DROP FUNCTION IF EXISTS f_unaccent(text);
@@ -0,0 +1,17 @@
+-- +goose Up
+-- Searches weigh matches in each field, so the search vector labels names A,
+-- slugs B and descriptions C. Slugs are split on hyphens into words.
+ALTER TABLE services DROP COLUMN search_vector;
+ALTER TABLE services ADD COLUMN search_vector TSVECTOR GENERATED ALWAYS AS (
+  setweight(to_tsvector('english', f_unaccent(name)), 'A')
+    || setweight(to_tsvector('english', f_unaccent(replace(slug, '-', ' '))), 'B')
+    || setweight(to_tsvector('english', f_unaccent(COALESCE(description, ''))), 'C')
+) STORED;
+CREATE INDEX ft_services_name_desc ON services USING GIN (search_vector);
+
+-- +goose Down
+ALTER TABLE services DROP COLUMN search_vector;
+ALTER TABLE services ADD COLUMN search_vector TSVECTOR GENERATED ALWAYS AS (
+  to_tsvector('english', f_unaccent(name || ' ' || COALESCE(description, '')))
+) STORED;
+CREATE INDEX ft_services_name_desc ON services USING GIN (search_vector);
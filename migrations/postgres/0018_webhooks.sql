@@ -0,0 +1,44 @@
+-- +goose Up
+-- Webhook subscriptions receive an organization's events of the types they list,
+-- stored comma-separated. Secrets are written through field-level encryption.
+CREATE TABLE webhook_subscriptions (
+  id           CHAR(36)      NOT NULL,
+  org_id       CHAR(36)      NOT NULL,
+  url          VARCHAR(2048) NOT NULL,
+  secret       TEXT          NOT NULL,
+  event_types  VARCHAR(512)  NOT NULL,
+  active       BOOLEAN       NOT NULL DEFAULT TRUE,
+  created_at   TIMESTAMPTZ   NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at   TIMESTAMPTZ   NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (id),
+  CONSTRAINT fk_webhook_subscriptions_org FOREIGN KEY (org_id) REFERENCES organizations(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_webhook_subscriptions_org_id ON webhook_subscriptions (org_id);
+
+-- One row per event and subscription, written in the same transaction as the
+-- change, and kept after delivery as the subscription's delivery history.
+CREATE TABLE webhook_deliveries (
+  id               CHAR(36)    NOT NULL,
+  org_id           CHAR(36)    NOT NULL,
+  subscription_id  CHAR(36)    NOT NULL,
+  event_id         CHAR(36)    NOT NULL,
+  event_type       VARCHAR(64) NOT NULL,
+  payload          TEXT        NOT NULL,
+  status           VARCHAR(16) NOT NULL DEFAULT 'pending',
+  attempts         INT         NOT NULL DEFAULT 0,
+  response_status  INT         NULL,
+  last_error       TEXT        NULL,
+  next_attempt_at  TIMESTAMPTZ NULL,
+  delivered_at     TIMESTAMPTZ NULL,
+  created_at       TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (id),
+  CONSTRAINT fk_webhook_deliveries_subscription FOREIGN KEY (subscription_id) REFERENCES webhook_subscriptions(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_webhook_deliveries_due ON webhook_deliveries (status, next_attempt_at);
+CREATE INDEX idx_webhook_deliveries_subscription ON webhook_deliveries (subscription_id, created_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS webhook_deliveries;
+DROP TABLE IF EXISTS webhook_subscriptions;
@@ -0,0 +1,22 @@
+-- +goose Up
+-- The sync of each service to the Kong Gateway, queued in the same transaction
+-- as any change to the service or its versions and settled by the syncer. Each
+-- queueing bumps the revision, so an attempt that read an older one cannot settle it.
+CREATE TABLE kong_syncs (
+  service_id       CHAR(36)    NOT NULL,
+  org_id           CHAR(36)    NOT NULL,
+  status           VARCHAR(16) NOT NULL DEFAULT 'pending',
+  revision         INT         NOT NULL DEFAULT 0,
+  attempts         INT         NOT NULL DEFAULT 0,
+  last_error       TEXT        NULL,
+  next_attempt_at  TIMESTAMPTZ NULL,
+  synced_at        TIMESTAMPTZ NULL,
+  updated_at       TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (service_id),
+  CONSTRAINT fk_kong_syncs_service FOREIGN KEY (service_id) REFERENCES services(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_kong_syncs_due ON kong_syncs (status, next_attempt_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS kong_syncs;
@@ -0,0 +1,20 @@
+-- +goose Up
+-- The GitHub repository whose releases become versions of each service. A
+-- repository is stored lowercased as owner/name and links to one service of an
+-- organization. Its events are signed with the link's secret, which only the
+-- repository's admins can configure on its webhook.
+CREATE TABLE github_repositories (
+  service_id  CHAR(36)     NOT NULL,
+  repository  VARCHAR(200) NOT NULL,
+  org_id      CHAR(36)     NOT NULL,
+  secret      TEXT         NOT NULL,
+  created_at  TIMESTAMPTZ  NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (service_id),
+  CONSTRAINT uq_github_repositories_repository UNIQUE (org_id, repository),
+  CONSTRAINT fk_github_repositories_service FOREIGN KEY (service_id) REFERENCES services(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_github_repositories_repository ON github_repositories (repository);
+
+-- +goose Down
+DROP TABLE IF EXISTS github_repositories;
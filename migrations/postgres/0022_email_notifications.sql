@@ -0,0 +1,51 @@
+-- +goose Up
+-- Users' notification preferences; users without a row get every notification.
+CREATE TABLE notification_preferences (
+  user_id       CHAR(36)    NOT NULL,
+  org_id        CHAR(36)    NOT NULL,
+  deprecations  BOOLEAN     NOT NULL DEFAULT TRUE,
+  updated_at    TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (user_id),
+  CONSTRAINT fk_notification_preferences_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- Users subscribed to a service's notifications besides its owners
+CREATE TABLE service_subscribers (
+  service_id  CHAR(36)    NOT NULL,
+  user_id     CHAR(36)    NOT NULL,
+  org_id      CHAR(36)    NOT NULL,
+  created_at  TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (service_id, user_id),
+  CONSTRAINT fk_service_subscribers_service FOREIGN KEY (service_id) REFERENCES services(id) ON DELETE CASCADE,
+  CONSTRAINT fk_service_subscribers_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_service_subscribers_user_id ON service_subscribers (user_id);
+
+-- One email per recipient of a notification, queued in the same transaction as
+-- the change it is about and settled by the notifier. Versions are partitioned,
+-- so version_id cannot reference them.
+CREATE TABLE email_notifications (
+  id               CHAR(36)    NOT NULL,
+  org_id           CHAR(36)    NOT NULL,
+  user_id          CHAR(36)    NOT NULL,
+  event_type       VARCHAR(64) NOT NULL,
+  service_id       CHAR(36)    NOT NULL,
+  version_id       CHAR(36)    NOT NULL,
+  status           VARCHAR(16) NOT NULL DEFAULT 'pending',
+  attempts         INT         NOT NULL DEFAULT 0,
+  last_error       TEXT        NULL,
+  next_attempt_at  TIMESTAMPTZ NULL,
+  sent_at          TIMESTAMPTZ NULL,
+  created_at       TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (id),
+  CONSTRAINT fk_email_notifications_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+  CONSTRAINT fk_email_notifications_service FOREIGN KEY (service_id) REFERENCES services(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_email_notifications_due ON email_notifications (status, next_attempt_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS email_notifications;
+DROP TABLE IF EXISTS service_subscribers;
+DROP TABLE IF EXISTS notification_preferences;
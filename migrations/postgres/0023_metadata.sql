@@ -0,0 +1,11 @@
+-- +goose Up
+-- Metadata maps string keys to string values, such as a repository URL or a
+-- cost center, stored as a JSON object and filtered on by key.
+ALTER TABLE services ADD COLUMN metadata JSONB NULL;
+ALTER TABLE versions ADD COLUMN metadata JSONB NULL;
+ALTER TABLE versions_archive ADD COLUMN metadata JSONB NULL;
+
+-- +goose Down
+ALTER TABLE versions_archive DROP COLUMN metadata;
+ALTER TABLE versions DROP COLUMN metadata;
+ALTER TABLE services DROP COLUMN metadata;
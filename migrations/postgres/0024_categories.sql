@@ -0,0 +1,31 @@
+-- +goose Up
+-- Categories form a tree per organization, a category without a parent being a
+-- root. Services are assigned to any number of categories; the (category_id,
+-- service_id) index serves category filters.
+CREATE TABLE categories (
+  id          CHAR(36)     NOT NULL,
+  org_id      CHAR(36)     NOT NULL,
+  parent_id   CHAR(36)     NULL,
+  name        VARCHAR(100) NOT NULL,
+  slug        VARCHAR(100) NOT NULL,
+  created_at  TIMESTAMPTZ  NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (id),
+  CONSTRAINT uq_categories_org_slug UNIQUE (org_id, slug),
+  CONSTRAINT fk_categories_parent FOREIGN KEY (parent_id) REFERENCES categories(id)
+);
+
+CREATE INDEX idx_categories_parent ON categories (parent_id);
+
+CREATE TABLE service_categories (
+  service_id   CHAR(36) NOT NULL,
+  category_id  CHAR(36) NOT NULL,
+  PRIMARY KEY (service_id, category_id),
+  CONSTRAINT fk_service_categories_service FOREIGN KEY (service_id) REFERENCES services(id) ON DELETE CASCADE,
+  CONSTRAINT fk_service_categories_category FOREIGN KEY (category_id) REFERENCES categories(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_service_categories_category ON service_categories (category_id, service_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS service_categories;
+DROP TABLE IF EXISTS categories;
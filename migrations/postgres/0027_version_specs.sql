@@ -0,0 +1,19 @@
+-- +goose Up
+-- Each version may have an OpenAPI document, stored as uploaded, JSON or YAML,
+-- with the SHA-256 digest of its content. Versions are partitioned, so the
+-- table references their service instead.
+CREATE TABLE version_specs (
+  version_id  CHAR(36)    NOT NULL,
+  service_id  CHAR(36)    NOT NULL,
+  format      VARCHAR(10) NOT NULL,
+  content     TEXT        NOT NULL,
+  digest      CHAR(64)    NOT NULL,
+  updated_at  TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (version_id),
+  CONSTRAINT fk_version_specs_service FOREIGN KEY (service_id) REFERENCES services(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_version_specs_service ON version_specs (service_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS version_specs;
@@ -0,0 +1,7 @@
+-- +goose Up
+-- The lint score of each OpenAPI document, from 0 to 100, taken when it was
+-- uploaded; NULL for documents uploaded before documents were linted
+ALTER TABLE version_specs ADD COLUMN lint_score SMALLINT NULL;
+
+-- +goose Down
+ALTER TABLE version_specs DROP COLUMN lint_score;
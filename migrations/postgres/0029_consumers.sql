@@ -0,0 +1,22 @@
+-- +goose Up
+-- Teams consuming a service, each either of every version, when version_id is
+-- NULL, or of a single one. Consumers are emailed about deprecations and keep
+-- their service from being deleted. Versions are partitioned, so version_id
+-- does not reference them.
+CREATE TABLE consumers (
+  id          CHAR(36)    NOT NULL,
+  org_id      CHAR(36)    NOT NULL,
+  service_id  CHAR(36)    NOT NULL,
+  team_id     CHAR(36)    NOT NULL,
+  version_id  CHAR(36)    NULL,
+  created_at  TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (id),
+  CONSTRAINT fk_consumers_service FOREIGN KEY (service_id) REFERENCES services(id) ON DELETE CASCADE,
+  CONSTRAINT fk_consumers_team FOREIGN KEY (team_id) REFERENCES teams(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_consumers_service ON consumers (service_id, team_id);
+CREATE INDEX idx_consumers_team ON consumers (team_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS consumers;
@@ -0,0 +1,10 @@
+-- +goose Up
+-- When the recipients of a deprecated version were reminded that its sunset
+-- date approaches, so each is reminded once; rescheduling the sunset clears it.
+ALTER TABLE versions ADD COLUMN sunset_reminded_at TIMESTAMPTZ NULL;
+
+CREATE INDEX idx_versions_sunset_at ON versions (sunset_at);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_versions_sunset_at;
+ALTER TABLE versions DROP COLUMN sunset_reminded_at;
@@ -0,0 +1,16 @@
+-- +goose Up
+-- When each version was first released, which release feeds date and order
+-- entries by; drafts have none. Versions released before this was recorded are
+-- dated from their creation.
+ALTER TABLE versions ADD COLUMN released_at TIMESTAMPTZ NULL;
+ALTER TABLE versions_archive ADD COLUMN released_at TIMESTAMPTZ NULL;
+
+UPDATE versions SET released_at = created_at WHERE status IN ('released', 'deprecated');
+UPDATE versions_archive SET released_at = created_at WHERE status IN ('released', 'deprecated');
+
+CREATE INDEX idx_versions_released_at ON versions (released_at);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_versions_released_at;
+ALTER TABLE versions_archive DROP COLUMN released_at;
+ALTER TABLE versions DROP COLUMN released_at;
@@ -0,0 +1,147 @@
+-- +goose Up
+-- SQLite is only used for local development and demos, so its schema starts at
+-- the state reached by the MySQL and Postgres migrations up to 0005.
+CREATE TABLE organizations (
+  id          CHAR(36)     NOT NULL PRIMARY KEY,
+  name        VARCHAR(255) NOT NULL,
+  slug        VARCHAR(255) NOT NULL UNIQUE,
+  created_at  TIMESTAMP    NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO organizations (id, name, slug) VALUES
+  ('00000000-0000-0000-0000-000000000001', 'Default', 'default');
+
+CREATE TABLE users (
+  id             CHAR(36)     NOT NULL PRIMARY KEY,
+  org_id         CHAR(36)     NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+  email          VARCHAR(255) NOT NULL UNIQUE,
+  name           VARCHAR(255) NOT NULL,
+  password_hash  VARCHAR(255) NULL,
+  created_at     TIMESTAMP    NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_users_org_id ON users (org_id);
+
+CREATE TABLE teams (
+  id          CHAR(36)     NOT NULL PRIMARY KEY,
+  org_id      CHAR(36)     NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+  name        VARCHAR(255) NOT NULL,
+  created_at  TIMESTAMP    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  UNIQUE (org_id, name)
+);
+
+CREATE TABLE team_members (
+  team_id  CHAR(36) NOT NULL REFERENCES teams(id) ON DELETE CASCADE,
+  user_id  CHAR(36) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  PRIMARY KEY (team_id, user_id)
+);
+
+CREATE INDEX idx_team_members_user_id ON team_members (user_id);
+
+CREATE TABLE api_tokens (
+  id          CHAR(36)     NOT NULL PRIMARY KEY,
+  org_id      CHAR(36)     NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+  user_id     CHAR(36)     NULL REFERENCES users(id) ON DELETE CASCADE,
+  name        VARCHAR(255) NOT NULL,
+  token_hash  CHAR(64)     NOT NULL UNIQUE,
+  created_at  TIMESTAMP    NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_api_tokens_org_id ON api_tokens (org_id);
+
+CREATE TABLE services (
+  id              CHAR(36)     NOT NULL PRIMARY KEY,
+  org_id          CHAR(36)     NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+  name            VARCHAR(255) NOT NULL,
+  slug            VARCHAR(255) NOT NULL,
+  description     TEXT NULL,
+  visibility      VARCHAR(16)  NOT NULL DEFAULT 'public' CHECK (visibility IN ('public','private')),
+  created_at      TIMESTAMP    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at      TIMESTAMP    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  versions_count  INT          NOT NULL DEFAULT 0,
+  UNIQUE (org_id, name),
+  UNIQUE (org_id, slug)
+);
+
+-- +goose StatementBegin
+CREATE TRIGGER trg_services_updated_at AFTER UPDATE ON services
+FOR EACH ROW WHEN NEW.updated_at = OLD.updated_at
+BEGIN
+  UPDATE services SET updated_at = CURRENT_TIMESTAMP WHERE id = NEW.id;
+END;
+-- +goose StatementEnd
+
+-- Stands in for the MySQL FULLTEXT index on (name, description)
+CREATE VIRTUAL TABLE services_fts USING fts5(
+  name, description, content='services', content_rowid='rowid'
+);
+
+-- +goose StatementBegin
+CREATE TRIGGER trg_services_fts_insert AFTER INSERT ON services BEGIN
+  INSERT INTO services_fts (rowid, name, description) VALUES (NEW.rowid, NEW.name, NEW.description);
+END;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TRIGGER trg_services_fts_delete AFTER DELETE ON services BEGIN
+  INSERT INTO services_fts (services_fts, rowid, name, description) VALUES ('delete', OLD.rowid, OLD.name, OLD.description);
+END;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TRIGGER trg_services_fts_update AFTER UPDATE OF name, description ON services BEGIN
+  INSERT INTO services_fts (services_fts, rowid, name, description) VALUES ('delete', OLD.rowid, OLD.name, OLD.description);
+  INSERT INTO services_fts (rowid, name, description) VALUES (NEW.rowid, NEW.name, NEW.description);
+END;
+-- +goose StatementEnd
+
+CREATE TABLE versions (
+  id          CHAR(36)    NOT NULL PRIMARY KEY,
+  service_id  CHAR(36)    NOT NULL REFERENCES services(id) ON DELETE CASCADE,
+  semver      VARCHAR(64) NOT NULL,
+  status      VARCHAR(16) NOT NULL CHECK (status IN ('draft','released','deprecated')),
+  changelog   TEXT NULL,
+  created_at  TIMESTAMP   NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_versions_service_id ON versions (service_id);
+CREATE INDEX idx_versions_status ON versions (status);
+
+CREATE TABLE service_acls (
+  id            CHAR(36)    NOT NULL PRIMARY KEY,
+  service_id    CHAR(36)    NOT NULL REFERENCES services(id) ON DELETE CASCADE,
+  subject_type  VARCHAR(16) NOT NULL CHECK (subject_type IN ('user','team')),
+  subject_id    CHAR(36)    NOT NULL,
+  permission    VARCHAR(16) NOT NULL CHECK (permission IN ('read','write')),
+  created_at    TIMESTAMP   NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  UNIQUE (service_id, subject_type, subject_id)
+);
+
+CREATE INDEX idx_service_acls_subject ON service_acls (subject_type, subject_id);
+
+-- Each row is one refresh token. Rotating a token revokes its row and inserts a
+-- successor in the same family; reusing a revoked token revokes the whole family.
+CREATE TABLE sessions (
+  id                  CHAR(36)  NOT NULL PRIMARY KEY,
+  family_id           CHAR(36)  NOT NULL,
+  user_id             CHAR(36)  NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  refresh_token_hash  CHAR(64)  NOT NULL UNIQUE,
+  expires_at          TIMESTAMP NOT NULL,
+  revoked_at          TIMESTAMP NULL,
+  created_at          TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_sessions_family_id ON sessions (family_id);
+CREATE INDEX idx_sessions_user_id ON sessions (user_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS sessions;
+DROP TABLE IF EXISTS service_acls;
+DROP TABLE IF EXISTS versions;
+DROP TABLE IF EXISTS services_fts;
+DROP TABLE IF EXISTS services;
+DROP TABLE IF EXISTS api_tokens;
+DROP TABLE IF EXISTS team_members;
+DROP TABLE IF EXISTS teams;
+DROP TABLE IF EXISTS users;
+DROP TABLE IF EXISTS organizations;
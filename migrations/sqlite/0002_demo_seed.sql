@@ -0,0 +1,18 @@
+-- +goose Up
+INSERT INTO services (id, org_id, name, slug, description) VALUES
+  ('6f1c2f4e-0000-4000-8000-000000000001', '00000000-0000-0000-0000-000000000001', 'Locate Us', 'locate-us', 'Store locator'),
+  ('6f1c2f4e-0000-4000-8000-000000000002', '00000000-0000-0000-0000-000000000001', 'Collect Money', 'collect-money', 'Payments collection'),
+  ('6f1c2f4e-0000-4000-8000-000000000003', '00000000-0000-0000-0000-000000000001', 'Notifications', 'notifications', 'Send push/email');
+
+INSERT INTO versions (id, service_id, semver, status, changelog) VALUES
+  ('7a2d3e5f-0000-4000-8000-000000000001', '6f1c2f4e-0000-4000-8000-000000000003', '1.0.0', 'released', 'Initial release'),
+  ('7a2d3e5f-0000-4000-8000-000000000002', '6f1c2f4e-0000-4000-8000-000000000003', '1.1.0', 'released', 'Minor improvements'),
+  ('7a2d3e5f-0000-4000-8000-000000000003', '6f1c2f4e-0000-4000-8000-000000000002', '0.1.0', 'draft', 'WIP');
+
+UPDATE services SET versions_count = (
+  SELECT COUNT(*) FROM versions WHERE service_id = services.id
+);
+
+-- +goose Down
+DELETE FROM versions;
+DELETE FROM services;
@@ -0,0 +1,17 @@
+-- +goose Up
+-- Change events are written here in the same transaction as the change, and
+-- deleted once the relay has delivered them. seq preserves the order of writes.
+CREATE TABLE outbox_events (
+  seq         INTEGER     PRIMARY KEY AUTOINCREMENT,
+  id          CHAR(36)    NOT NULL UNIQUE,
+  org_id      CHAR(36)    NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+  event_type  VARCHAR(64) NOT NULL,
+  subject_id  CHAR(36)    NOT NULL,
+  payload     TEXT        NOT NULL,
+  attempts    INT         NOT NULL DEFAULT 0,
+  last_error  TEXT        NULL,
+  created_at  TIMESTAMP   NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS outbox_events;
@@ -0,0 +1,17 @@
+-- +goose Up
+-- SQLite has no partitioning; archived versions are still moved out of versions
+CREATE TABLE versions_archive (
+  id           CHAR(36)    NOT NULL PRIMARY KEY,
+  service_id   CHAR(36)    NOT NULL,
+  semver       VARCHAR(64) NOT NULL,
+  status       VARCHAR(16) NOT NULL,
+  changelog    TEXT NULL,
+  created_at   TIMESTAMP   NOT NULL,
+  deleted_at   TIMESTAMP   NULL,
+  archived_at  TIMESTAMP   NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_versions_archive_service_id ON versions_archive (service_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS versions_archive;
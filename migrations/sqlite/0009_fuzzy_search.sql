@@ -0,0 +1,33 @@
+-- +goose Up
+-- Fuzzy search matches names by their shared trigrams, so a misspelled name still
+-- shares most of them with the correct one
+CREATE VIRTUAL TABLE services_trigram USING fts5(
+  name, content='services', content_rowid='rowid', tokenize='trigram'
+);
+
+INSERT INTO services_trigram (services_trigram) VALUES ('rebuild');
+
+-- +goose StatementBegin
+CREATE TRIGGER trg_services_trigram_insert AFTER INSERT ON services BEGIN
+  INSERT INTO services_trigram (rowid, name) VALUES (NEW.rowid, NEW.name);
+END;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TRIGGER trg_services_trigram_delete AFTER DELETE ON services BEGIN
+  INSERT INTO services_trigram (services_trigram, rowid, name) VALUES ('delete', OLD.rowid, OLD.name);
+END;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TRIGGER trg_services_trigram_update AFTER UPDATE OF name ON services BEGIN
+  INSERT INTO services_trigram (services_trigram, rowid, name) VALUES ('delete', OLD.rowid, OLD.name);
+  INSERT INTO services_trigram (rowid, name) VALUES (NEW.rowid, NEW.name);
+END;
+-- +goose StatementEnd
+
+-- +goose Down
+DROP TRIGGER IF EXISTS trg_services_trigram_update;
+DROP TRIGGER IF EXISTS trg_services_trigram_delete;
+DROP TRIGGER IF EXISTS trg_services_trigram_insert;
+DROP TABLE IF EXISTS services_trigram;
@@ -0,0 +1,15 @@
+-- +goose Up
+-- A sample of the searches run in each organization, for search analytics. The
+-- (org_id, searched_at) index serves reports over a recent window.
+CREATE TABLE search_queries (
+  seq          INTEGER      PRIMARY KEY AUTOINCREMENT,
+  org_id       CHAR(36)     NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+  query        VARCHAR(255) NOT NULL,
+  hits         INT          NOT NULL,
+  searched_at  TIMESTAMP    NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_search_queries_org_searched_at ON search_queries (org_id, searched_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS search_queries;
@@ -0,0 +1,11 @@
+-- +goose Up
+-- Synonyms searches expand each term with, managed by admins for every
+-- organization. Terms and synonyms are stored folded to lowercase without accents.
+CREATE TABLE search_synonyms (
+  term     VARCHAR(100) NOT NULL,
+  synonym  VARCHAR(100) NOT NULL,
+  PRIMARY KEY (term, synonym)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS search_synonyms;
@@ -0,0 +1,62 @@
+-- +goose Up
+-- Searches weigh matches in each field, so slugs get a column of their own
+DROP TRIGGER IF EXISTS trg_services_fts_update;
+DROP TRIGGER IF EXISTS trg_services_fts_delete;
+DROP TRIGGER IF EXISTS trg_services_fts_insert;
+DROP TABLE IF EXISTS services_fts;
+
+CREATE VIRTUAL TABLE services_fts USING fts5(
+  name, slug, description, content='services', content_rowid='rowid'
+);
+
+INSERT INTO services_fts (services_fts) VALUES ('rebuild');
+
+-- +goose StatementBegin
+CREATE TRIGGER trg_services_fts_insert AFTER INSERT ON services BEGIN
+  INSERT INTO services_fts (rowid, name, slug, description) VALUES (NEW.rowid, NEW.name, NEW.slug, NEW.description);
+END;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TRIGGER trg_services_fts_delete AFTER DELETE ON services BEGIN
+  INSERT INTO services_fts (services_fts, rowid, name, slug, description) VALUES ('delete', OLD.rowid, OLD.name, OLD.slug, OLD.description);
+END;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TRIGGER trg_services_fts_update AFTER UPDATE OF name, slug, description ON services BEGIN
+  INSERT INTO services_fts (services_fts, rowid, name, slug, description) VALUES ('delete', OLD.rowid, OLD.name, OLD.slug, OLD.description);
+  INSERT INTO services_fts (rowid, name, slug, description) VALUES (NEW.rowid, NEW.name, NEW.slug, NEW.description);
+END;
+-- +goose StatementEnd
+
+-- +goose Down
+DROP TRIGGER IF EXISTS trg_services_fts_update;
+DROP TRIGGER IF EXISTS trg_services_fts_delete;
+DROP TRIGGER IF EXISTS trg_services_fts_insert;
+DROP TABLE IF EXISTS services_fts;
+
+CREATE VIRTUAL TABLE services_fts USING fts5(
+  name, description, content='services', content_rowid='rowid'
+);
+
+INSERT INTO services_fts (services_fts) VALUES ('rebuild');
+
+-- +goose StatementBegin
+CREATE TRIGGER trg_services_fts_insert AFTER INSERT ON services BEGIN
+  INSERT INTO services_fts (rowid, name, description) VALUES (NEW.rowid, NEW.name, NEW.description);
+END;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TRIGGER trg_services_fts_delete AFTER DELETE ON services BEGIN
+  INSERT INTO services_fts (services_fts, rowid, name, description) VALUES ('delete', OLD.rowid, OLD.name, OLD.description);
+END;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TRIGGER trg_services_fts_update AFTER UPDATE OF name, description ON services BEGIN
+  INSERT INTO services_fts (services_fts, rowid, name, description) VALUES ('delete', OLD.rowid, OLD.name, OLD.description);
+  INSERT INTO services_fts (rowid, name, description) VALUES (NEW.rowid, NEW.name, NEW.description);
+END;
+-- +goose StatementEnd
@@ -0,0 +1,20 @@
+-- +goose Up
+-- The sync of each service to the Kong Gateway, queued in the same transaction
+-- as any change to the service or its versions and settled by the syncer. Each
+-- queueing bumps the revision, so an attempt that read an older one cannot settle it.
+CREATE TABLE kong_syncs (
+  service_id       CHAR(36)    NOT NULL PRIMARY KEY REFERENCES services(id) ON DELETE CASCADE,
+  org_id           CHAR(36)    NOT NULL,
+  status           VARCHAR(16) NOT NULL DEFAULT 'pending',
+  revision         INT         NOT NULL DEFAULT 0,
+  attempts         INT         NOT NULL DEFAULT 0,
+  last_error       TEXT        NULL,
+  next_attempt_at  TIMESTAMP   NULL,
+  synced_at        TIMESTAMP   NULL,
+  updated_at       TIMESTAMP   NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_kong_syncs_due ON kong_syncs (status, next_attempt_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS kong_syncs;
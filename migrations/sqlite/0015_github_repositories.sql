@@ -0,0 +1,18 @@
+-- +goose Up
+-- The GitHub repository whose releases become versions of each service. A
+-- repository is stored lowercased as owner/name and links to one service of an
+-- organization. Its events are signed with the link's secret, which only the
+-- repository's admins can configure on its webhook.
+CREATE TABLE github_repositories (
+  service_id  CHAR(36)     NOT NULL PRIMARY KEY REFERENCES services(id) ON DELETE CASCADE,
+  repository  VARCHAR(200) NOT NULL,
+  org_id      CHAR(36)     NOT NULL,
+  secret      TEXT         NOT NULL,
+  created_at  TIMESTAMP    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  UNIQUE (org_id, repository)
+);
+
+CREATE INDEX idx_github_repositories_repository ON github_repositories (repository);
+
+-- +goose Down
+DROP TABLE IF EXISTS github_repositories;
@@ -0,0 +1,9 @@
+-- +goose Up
+-- Subscriptions in the slack or teams format receive each event as a chat
+-- message, rendered from their template or the default one for its type.
+ALTER TABLE webhook_subscriptions ADD COLUMN format VARCHAR(16) NOT NULL DEFAULT 'json';
+ALTER TABLE webhook_subscriptions ADD COLUMN template TEXT NULL;
+
+-- +goose Down
+ALTER TABLE webhook_subscriptions DROP COLUMN template;
+ALTER TABLE webhook_subscriptions DROP COLUMN format;
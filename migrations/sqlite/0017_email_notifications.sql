@@ -0,0 +1,43 @@
+-- +goose Up
+-- Users' notification preferences; users without a row get every notification.
+CREATE TABLE notification_preferences (
+  user_id       CHAR(36)  NOT NULL PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+  org_id        CHAR(36)  NOT NULL,
+  deprecations  BOOLEAN   NOT NULL DEFAULT TRUE,
+  updated_at    TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Users subscribed to a service's notifications besides its owners
+CREATE TABLE service_subscribers (
+  service_id  CHAR(36)  NOT NULL REFERENCES services(id) ON DELETE CASCADE,
+  user_id     CHAR(36)  NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  org_id      CHAR(36)  NOT NULL,
+  created_at  TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (service_id, user_id)
+);
+
+CREATE INDEX idx_service_subscribers_user_id ON service_subscribers (user_id);
+
+-- One email per recipient of a notification, queued in the same transaction as
+-- the change it is about and settled by the notifier
+CREATE TABLE email_notifications (
+  id               CHAR(36)    NOT NULL PRIMARY KEY,
+  org_id           CHAR(36)    NOT NULL,
+  user_id          CHAR(36)    NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  event_type       VARCHAR(64) NOT NULL,
+  service_id       CHAR(36)    NOT NULL REFERENCES services(id) ON DELETE CASCADE,
+  version_id       CHAR(36)    NOT NULL,
+  status           VARCHAR(16) NOT NULL DEFAULT 'pending',
+  attempts         INT         NOT NULL DEFAULT 0,
+  last_error       TEXT        NULL,
+  next_attempt_at  TIMESTAMP   NULL,
+  sent_at          TIMESTAMP   NULL,
+  created_at       TIMESTAMP   NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_email_notifications_due ON email_notifications (status, next_attempt_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS email_notifications;
+DROP TABLE IF EXISTS service_subscribers;
+DROP TABLE IF EXISTS notification_preferences;
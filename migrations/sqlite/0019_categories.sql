@@ -0,0 +1,27 @@
+-- +goose Up
+-- Categories form a tree per organization, a category without a parent being a
+-- root. Services are assigned to any number of categories; the (category_id,
+-- service_id) index serves category filters.
+CREATE TABLE categories (
+  id          CHAR(36)     NOT NULL PRIMARY KEY,
+  org_id      CHAR(36)     NOT NULL,
+  parent_id   CHAR(36)     NULL REFERENCES categories(id),
+  name        VARCHAR(100) NOT NULL,
+  slug        VARCHAR(100) NOT NULL,
+  created_at  TIMESTAMP    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  UNIQUE (org_id, slug)
+);
+
+CREATE INDEX idx_categories_parent ON categories (parent_id);
+
+CREATE TABLE service_categories (
+  service_id   CHAR(36) NOT NULL REFERENCES services(id) ON DELETE CASCADE,
+  category_id  CHAR(36) NOT NULL REFERENCES categories(id) ON DELETE CASCADE,
+  PRIMARY KEY (service_id, category_id)
+);
+
+CREATE INDEX idx_service_categories_category ON service_categories (category_id, service_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS service_categories;
+DROP TABLE IF EXISTS categories;
@@ -0,0 +1,14 @@
+-- +goose Up
+-- The team and email owning each service, empty when unowned. Owners are
+-- looked up within an organization, by team or by email.
+ALTER TABLE services ADD COLUMN owner_team VARCHAR(100) NOT NULL DEFAULT '';
+ALTER TABLE services ADD COLUMN owner_email VARCHAR(254) NOT NULL DEFAULT '';
+
+CREATE INDEX idx_services_owner_team ON services (org_id, owner_team);
+CREATE INDEX idx_services_owner_email ON services (org_id, owner_email);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_services_owner_email;
+DROP INDEX IF EXISTS idx_services_owner_team;
+ALTER TABLE services DROP COLUMN owner_email;
+ALTER TABLE services DROP COLUMN owner_team;
@@ -0,0 +1,30 @@
+-- +goose Up
+-- Deployments record each version deployed to an environment, such as prod,
+-- newest last. current_deployments holds the version each environment of a
+-- service runs now, the last one deployed there; the (environment, service_id)
+-- index serves deployed_in filters.
+CREATE TABLE deployments (
+  id           CHAR(36)    NOT NULL PRIMARY KEY,
+  org_id       CHAR(36)    NOT NULL,
+  service_id   CHAR(36)    NOT NULL REFERENCES services(id) ON DELETE CASCADE,
+  version_id   CHAR(36)    NOT NULL,
+  environment  VARCHAR(40) NOT NULL,
+  deployed_at  TIMESTAMP   NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_deployments_version ON deployments (version_id, deployed_at);
+
+CREATE TABLE current_deployments (
+  service_id   CHAR(36)    NOT NULL REFERENCES services(id) ON DELETE CASCADE,
+  environment  VARCHAR(40) NOT NULL,
+  version_id   CHAR(36)    NOT NULL,
+  deployed_at  TIMESTAMP   NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (service_id, environment)
+);
+
+CREATE INDEX idx_current_deployments_version ON current_deployments (version_id);
+CREATE INDEX idx_current_deployments_environment ON current_deployments (environment, service_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS current_deployments;
+DROP TABLE IF EXISTS deployments;
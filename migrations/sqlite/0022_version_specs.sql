@@ -0,0 +1,16 @@
+-- +goose Up
+-- Each version may have an OpenAPI document, stored as uploaded, JSON or YAML,
+-- with the SHA-256 digest of its content
+CREATE TABLE version_specs (
+  version_id  CHAR(36)    NOT NULL PRIMARY KEY,
+  service_id  CHAR(36)    NOT NULL REFERENCES services(id) ON DELETE CASCADE,
+  format      VARCHAR(10) NOT NULL,
+  content     TEXT        NOT NULL,
+  digest      CHAR(64)    NOT NULL,
+  updated_at  TIMESTAMP   NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_version_specs_service ON version_specs (service_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS version_specs;
@@ -0,0 +1,18 @@
+-- +goose Up
+-- Teams consuming a service, each either of every version, when version_id is
+-- NULL, or of a single one. Consumers are emailed about deprecations and keep
+-- their service from being deleted.
+CREATE TABLE consumers (
+  id          CHAR(36)  NOT NULL PRIMARY KEY,
+  org_id      CHAR(36)  NOT NULL,
+  service_id  CHAR(36)  NOT NULL REFERENCES services(id) ON DELETE CASCADE,
+  team_id     CHAR(36)  NOT NULL REFERENCES teams(id) ON DELETE CASCADE,
+  version_id  CHAR(36)  NULL,
+  created_at  TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_consumers_service ON consumers (service_id, team_id);
+CREATE INDEX idx_consumers_team ON consumers (team_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS consumers;
@@ -0,0 +1,22 @@
+-- +goose Up
+-- When each version is or will be deprecated, and when it stops being served.
+-- Versions not yet deprecated are flipped to deprecated once their
+-- deprecated_at passes, which the deprecated_at index finds.
+ALTER TABLE versions ADD COLUMN deprecated_at TIMESTAMP NULL;
+ALTER TABLE versions ADD COLUMN sunset_at TIMESTAMP NULL;
+ALTER TABLE versions_archive ADD COLUMN deprecated_at TIMESTAMP NULL;
+ALTER TABLE versions_archive ADD COLUMN sunset_at TIMESTAMP NULL;
+
+CREATE INDEX idx_versions_deprecated_at ON versions (deprecated_at);
+
+-- Versions keep no record of when their status last changed, so those already
+-- deprecated are dated from their creation, the one time known to precede it.
+UPDATE versions SET deprecated_at = created_at WHERE status = 'deprecated' AND deprecated_at IS NULL;
+UPDATE versions_archive SET deprecated_at = created_at WHERE status = 'deprecated' AND deprecated_at IS NULL;
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_versions_deprecated_at;
+ALTER TABLE versions_archive DROP COLUMN sunset_at;
+ALTER TABLE versions_archive DROP COLUMN deprecated_at;
+ALTER TABLE versions DROP COLUMN sunset_at;
+ALTER TABLE versions DROP COLUMN deprecated_at;
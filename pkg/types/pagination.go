@@ -1,30 +1,207 @@
 package types
 
+import "time"
+
 // PaginationParams represents pagination parameters for API requests
 type PaginationParams struct {
 	Page     int `form:"page" binding:"min=1"`
 	PageSize int `form:"page_size" binding:"min=1,max=100"`
+
+	// SkipCount is set by count=false to skip the total count
+	SkipCount bool `form:"-"`
+
+	// Cursor is set by cursor= to continue after a row instead of at Page
+	Cursor *Cursor `form:"-"`
+
+	// CursorOnly pages by cursor alone, as API v2 does: pages after the first
+	// are only reached through a cursor, and no page numbers are reported
+	CursorOnly bool `form:"-"`
+
+	// IncludeDeleted also lists soft-deleted rows
+	IncludeDeleted bool `form:"-"`
+
+	// Filter is set by filter= to list only the rows meeting every condition
+	Filter []FilterCondition `form:"-"`
+
+	// Tags is set by tag= to list only services with those tags
+	Tags TagFilter `form:"-"`
+
+	// Metadata is set by metadata.<key>= to list only rows whose metadata has
+	// those values
+	Metadata map[string]string `form:"-"`
+
+	// Category is set by category= to list only services in a category, given
+	// by ID or slug, or in any of its subcategories
+	Category string `form:"-"`
+
+	// Owner is set by owner= to list only services owned by a team or, given
+	// an address, an email
+	Owner string `form:"-"`
+
+	// DeployedIn is set by deployed_in= to list only services, or versions,
+	// currently deployed to an environment
+	DeployedIn string `form:"-"`
+
+	// Query is set by q= to list only services matching a search
+	Query string `form:"-"`
+}
+
+// Tag filter modes
+const (
+	// TagModeAll matches services with every tag of the filter
+	TagModeAll = "all"
+
+	// TagModeAny matches services with at least one tag of the filter
+	TagModeAny = "any"
+)
+
+// TagFilter restricts services to those carrying its tags. An empty filter matches every service.
+type TagFilter struct {
+	Tags []string
+
+	// Any matches services with any of Tags rather than all of them
+	Any bool
+}
+
+// FilterCondition is one condition of a list filter, such as status==released.
+// Values hold one value, or the list of an =in= condition, typed as string, int
+// or time.Time after the field.
+type FilterCondition struct {
+	Field    string
+	Operator string
+	Values   []interface{}
+}
+
+// ReadOptions adjust which rows a single-row read may return
+type ReadOptions struct {
+	// IncludeDeleted also returns a soft-deleted row
+	IncludeDeleted bool
+}
+
+// Cursor identifies the last row of a page for keyset pagination, which lists
+// rows newest first by created_at and then id
+type Cursor struct {
+	CreatedAt time.Time
+	ID        string
+}
+
+// Search modes
+const (
+	// SearchModeNatural matches any of the query's words, ranking by relevance
+	SearchModeNatural = "natural"
+
+	// SearchModeBoolean understands +required, -excluded, "exact phrase" and prefix* terms
+	SearchModeBoolean = "boolean"
+
+	// SearchModeBrowse lists every service without a query, in the chosen sort
+	// order; relevance then lists the newest first
+	SearchModeBrowse = "browse"
+)
+
+// SearchWeights are the relative weights of matches in each field of a service
+// in its relevance score. Zero ignores matches in a field when ranking.
+type SearchWeights struct {
+	Name        float64
+	Slug        float64
+	Description float64
 }
 
+// DefaultSearchWeights rank matches in names above matches in slugs, and those
+// above matches in descriptions
+var DefaultSearchWeights = SearchWeights{Name: 3, Slug: 2, Description: 1}
+
+// Search result orders
+const (
+	// SortRelevance lists the best matches first, then the newest
+	SortRelevance = "relevance"
+
+	// SortName lists results by name, A to Z
+	SortName = "name"
+
+	// SortCreatedAt lists the newest results first
+	SortCreatedAt = "created_at"
+
+	// SortVersionsCount lists the results with the most versions first, then the newest
+	SortVersionsCount = "versions_count"
+)
+
 // SearchParams represents search parameters for API requests
 type SearchParams struct {
 	Query    string `form:"q" binding:"required"`
 	Page     int    `form:"page" binding:"min=1"`
 	PageSize int    `form:"page_size" binding:"min=1,max=100"`
+
+	// Mode is SearchModeNatural (the default), SearchModeBoolean or
+	// SearchModeBrowse, which Query must be empty for
+	Mode string `form:"mode" binding:"omitempty,oneof=natural boolean browse"`
+
+	// Sort is SortRelevance (the default), SortName, SortCreatedAt or SortVersionsCount
+	Sort string `form:"sort" binding:"omitempty,oneof=relevance name created_at versions_count"`
+
+	// Tags is set by tag= to find only services with those tags
+	Tags TagFilter `form:"-"`
+
+	// Fuzzy is set by fuzzy=true to also find services whose names resemble Query
+	// despite typos; it does not apply in boolean mode
+	Fuzzy bool `form:"-"`
+
+	// MinScore leaves out full-text matches scoring below it, when set by min_score
+	MinScore *float64 `form:"-"`
+
+	// SkipCount is set by count=false to skip the total count
+	SkipCount bool `form:"-"`
+
+	// IncludeDeleted is set by include_archived=true to also search soft-deleted rows
+	IncludeDeleted bool `form:"-"`
+}
+
+// Search facets
+const (
+	// FacetVisibility counts results by service visibility
+	FacetVisibility = "visibility"
+
+	// FacetVersionStatus counts results by the statuses of their versions; a
+	// service with versions of several statuses counts towards each
+	FacetVersionStatus = "version_status"
+)
+
+// FacetBucket counts the results sharing one value of a facet
+type FacetBucket struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
 }
 
 // PaginatedResponse represents a paginated API response
 type PaginatedResponse struct {
 	Data       interface{} `json:"data"`
 	Pagination Pagination  `json:"pagination"`
+
+	// Facets holds the buckets of each facet a search asked for, largest first
+	Facets map[string][]FacetBucket `json:"facets,omitempty"`
+
+	// Links point to this page and to the first, previous, next and last ones
+	Links Links `json:"_links,omitempty"`
+}
+
+// Link is a hypermedia link, in the HAL style of {"href": "..."}
+type Link struct {
+	Href string `json:"href"`
 }
 
-// Pagination represents pagination metadata
+// Links are the links of a resource or page, by relation
+type Links map[string]Link
+
+// Pagination represents pagination metadata. Total and TotalPages are nil when
+// the total count was skipped, and Page and TotalPages are left out of pages
+// fetched with a cursor.
 type Pagination struct {
-	Page       int  `json:"page"`
+	Page       int  `json:"page,omitempty"`
 	PageSize   int  `json:"page_size"`
-	Total      int  `json:"total"`
-	TotalPages int  `json:"total_pages"`
+	Total      *int `json:"total,omitempty"`
+	TotalPages *int `json:"total_pages,omitempty"`
 	HasNext    bool `json:"has_next"`
 	HasPrev    bool `json:"has_prev"`
+
+	// NextCursor continues the list after this page when HasNext is set
+	NextCursor string `json:"next_cursor,omitempty"`
 }
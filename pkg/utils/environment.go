@@ -0,0 +1,23 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+)
+
+// MaxEnvironmentLength bounds the length of a deployment environment
+const MaxEnvironmentLength = 40
+
+// ErrInvalidEnvironment is returned for environments that NormalizeEnvironment rejects
+var ErrInvalidEnvironment = errors.New("invalid environment")
+
+// NormalizeEnvironment rewrites an environment name with NormalizeSlug, so
+// "Prod EU" becomes "prod-eu". Environments hold no commas, so they can be
+// listed comma-separated.
+func NormalizeEnvironment(environment string) (string, error) {
+	normalized, err := NormalizeSlug(environment)
+	if err != nil || len(normalized) > MaxEnvironmentLength {
+		return "", fmt.Errorf("%w: environment must have 1 to %d letters, digits or hyphens", ErrInvalidEnvironment, MaxEnvironmentLength)
+	}
+	return normalized, nil
+}
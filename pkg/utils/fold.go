@@ -0,0 +1,50 @@
+package utils
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// ErrInvalidSlug is returned for slugs that NormalizeSlug leaves empty
+var ErrInvalidSlug = errors.New("slug must contain at least one letter or digit")
+
+// Fold lowercases s and strips its diacritics, so that "Café" and "cafe" fold to
+// the same string. Comparisons that should ignore case and accents compare folded
+// strings, matching MySQL's accent- and case-insensitive utf8mb4_0900_ai_ci collation.
+func Fold(s string) string {
+	// Transformers keep state between calls, so each call chains its own
+	t := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	folded, _, err := transform.String(t, s)
+	if err != nil {
+		folded = s
+	}
+	return strings.ToLower(folded)
+}
+
+// NormalizeSlug folds s and replaces each run of characters other than ASCII
+// letters and digits with a hyphen, trimming hyphens at either end, so that
+// "Café-API" becomes "cafe-api"
+func NormalizeSlug(s string) (string, error) {
+	var b strings.Builder
+	hyphen := false
+	for _, r := range Fold(s) {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' {
+			if hyphen && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			hyphen = false
+			continue
+		}
+		hyphen = true
+	}
+	if b.Len() == 0 {
+		return "", ErrInvalidSlug
+	}
+	return b.String(), nil
+}
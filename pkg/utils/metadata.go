@@ -0,0 +1,94 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MaxMetadataKeys bounds the keys of one metadata map, and of one metadata filter
+const MaxMetadataKeys = 32
+
+// MaxMetadataKeyLength bounds the length of a metadata key
+const MaxMetadataKeyLength = 64
+
+// MaxMetadataValueLength bounds the length of a metadata value
+const MaxMetadataValueLength = 512
+
+// MaxMetadataSize bounds the size of a metadata map encoded as JSON
+const MaxMetadataSize = 8 << 10
+
+// metadataParam prefixes the query parameters filtering on metadata, such as metadata.team=payments
+const metadataParam = "metadata."
+
+// ErrInvalidMetadata is returned for metadata that ValidateMetadata rejects
+var ErrInvalidMetadata = errors.New("invalid metadata")
+
+// ValidateMetadata checks that metadata has at most MaxMetadataKeys keys of
+// lowercase letters, digits, hyphens and underscores, starting with a letter, and
+// values of at most MaxMetadataValueLength characters, and that it encodes to at
+// most MaxMetadataSize bytes. Keys are restricted so that they can be named in
+// query parameters and JSON paths as is.
+func ValidateMetadata(metadata map[string]string) error {
+	if len(metadata) > MaxMetadataKeys {
+		return fmt.Errorf("%w: more than %d keys", ErrInvalidMetadata, MaxMetadataKeys)
+	}
+	for key, value := range metadata {
+		if err := validateMetadataKey(key); err != nil {
+			return err
+		}
+		if len(value) > MaxMetadataValueLength {
+			return fmt.Errorf("%w: the value of %q is longer than %d characters", ErrInvalidMetadata, key, MaxMetadataValueLength)
+		}
+	}
+	encoded, err := json.Marshal(metadata)
+	if err != nil {
+		return err
+	}
+	if len(encoded) > MaxMetadataSize {
+		return fmt.Errorf("%w: larger than %d bytes", ErrInvalidMetadata, MaxMetadataSize)
+	}
+	return nil
+}
+
+// validateMetadataKey checks one key of ValidateMetadata
+func validateMetadataKey(key string) error {
+	if key == "" || len(key) > MaxMetadataKeyLength {
+		return fmt.Errorf("%w: keys must be 1 to %d characters", ErrInvalidMetadata, MaxMetadataKeyLength)
+	}
+	for i, r := range key {
+		if !(r >= 'a' && r <= 'z' || i > 0 && (r >= '0' && r <= '9' || r == '-' || r == '_')) {
+			return fmt.Errorf("%w: unsupported character %q in key %q", ErrInvalidMetadata, r, key)
+		}
+	}
+	return nil
+}
+
+// GetMetadataFilter parses metadata.<key>=<value> parameters into the values
+// metadata must have, nil when there are none. A key may only be given once.
+func GetMetadataFilter(c *gin.Context) (map[string]string, error) {
+	var filter map[string]string
+	for param, values := range c.Request.URL.Query() {
+		if !strings.HasPrefix(param, metadataParam) {
+			continue
+		}
+		key := strings.TrimPrefix(param, metadataParam)
+		if err := validateMetadataKey(key); err != nil {
+			return nil, err
+		}
+		if len(values) != 1 || values[0] == "" {
+			return nil, fmt.Errorf("%w: %s takes one value", ErrInvalidMetadata, param)
+		}
+		if filter == nil {
+			filter = map[string]string{}
+		}
+		filter[key] = values[0]
+	}
+	if len(filter) > MaxMetadataKeys {
+		return nil, fmt.Errorf("%w: more than %d keys", ErrInvalidMetadata, MaxMetadataKeys)
+	}
+	return filter, nil
+}
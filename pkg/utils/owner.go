@@ -0,0 +1,54 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+)
+
+// MaxOwnerTeamLength bounds the length of a service's owner team
+const MaxOwnerTeamLength = 100
+
+// MaxOwnerEmailLength bounds the length of a service's owner email
+const MaxOwnerEmailLength = 254
+
+// ErrInvalidOwner is returned for owners that NormalizeOwnerTeam or NormalizeOwnerEmail reject
+var ErrInvalidOwner = errors.New("invalid owner")
+
+// NormalizeOwnerTeam rewrites a team name with NormalizeSlug, so "Payments Core"
+// becomes "payments-core". An empty team, meaning no owner, is left as is.
+func NormalizeOwnerTeam(team string) (string, error) {
+	if strings.TrimSpace(team) == "" {
+		return "", nil
+	}
+	normalized, err := NormalizeSlug(team)
+	if err != nil || len(normalized) > MaxOwnerTeamLength {
+		return "", fmt.Errorf("%w: owner_team must have 1 to %d letters, digits or hyphens", ErrInvalidOwner, MaxOwnerTeamLength)
+	}
+	return normalized, nil
+}
+
+// NormalizeOwnerEmail checks that email is a bare address, such as
+// team@example.com, and lowercases it. An empty email, meaning no owner, is
+// left as is.
+func NormalizeOwnerEmail(email string) (string, error) {
+	email = strings.TrimSpace(email)
+	if email == "" {
+		return "", nil
+	}
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email || len(email) > MaxOwnerEmailLength {
+		return "", fmt.Errorf("%w: owner_email must be an email address of at most %d characters", ErrInvalidOwner, MaxOwnerEmailLength)
+	}
+	return strings.ToLower(email), nil
+}
+
+// NormalizeOwner normalizes the owner= filter, which names an owner email when
+// it holds an @ and an owner team otherwise
+func NormalizeOwner(owner string) (string, error) {
+	if strings.Contains(owner, "@") {
+		return NormalizeOwnerEmail(owner)
+	}
+	return NormalizeOwnerTeam(owner)
+}
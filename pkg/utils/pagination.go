@@ -1,7 +1,12 @@
 package utils
 
 import (
+	"encoding/base64"
+	"errors"
+	"math"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/yashjain/konnect/pkg/types"
@@ -28,6 +33,8 @@ func GetPaginationParams(c *gin.Context) types.PaginationParams {
 		}
 	}
 
+	params.SkipCount = skipCount(c)
+
 	return params
 }
 
@@ -35,6 +42,8 @@ func GetPaginationParams(c *gin.Context) types.PaginationParams {
 func GetSearchParams(c *gin.Context) types.SearchParams {
 	params := types.SearchParams{
 		Query:    c.Query("q"),
+		Mode:     c.DefaultQuery("mode", types.SearchModeNatural),
+		Sort:     c.DefaultQuery("sort", types.SortRelevance),
 		Page:     1,
 		PageSize: 10,
 	}
@@ -53,9 +62,52 @@ func GetSearchParams(c *gin.Context) types.SearchParams {
 		}
 	}
 
+	params.SkipCount = skipCount(c)
+	params.Fuzzy = fuzzy(c)
+	params.IncludeDeleted = includeArchived(c)
+
 	return params
 }
 
+// errInvalidMinScore is returned for min_score values that are not non-negative numbers
+var errInvalidMinScore = errors.New("min_score must be a non-negative number")
+
+// GetMinScore extracts the minimum search score from the request, or nil when there is none
+func GetMinScore(c *gin.Context) (*float64, error) {
+	raw := c.Query("min_score")
+	if raw == "" {
+		return nil, nil
+	}
+
+	score, err := strconv.ParseFloat(raw, 64)
+	if err != nil || score < 0 || math.IsInf(score, 0) {
+		return nil, errInvalidMinScore
+	}
+	return &score, nil
+}
+
+// errInvalidFacets is returned for facets values naming unknown facets
+var errInvalidFacets = errors.New("facets must be a comma-separated list of visibility and version_status")
+
+// GetFacets extracts the comma-separated facets a search asks for, or nil when there are none
+func GetFacets(c *gin.Context) ([]string, error) {
+	raw := c.Query("facets")
+	if raw == "" {
+		return nil, nil
+	}
+
+	var facets []string
+	for _, facet := range strings.Split(raw, ",") {
+		switch facet = strings.TrimSpace(facet); facet {
+		case types.FacetVisibility, types.FacetVersionStatus:
+			facets = append(facets, facet)
+		default:
+			return nil, errInvalidFacets
+		}
+	}
+	return facets, nil
+}
+
 // CalculatePagination calculates pagination metadata
 func CalculatePagination(page, pageSize, total int) types.Pagination {
 	totalPages := (total + pageSize - 1) / pageSize // Ceiling division
@@ -63,9 +115,115 @@ func CalculatePagination(page, pageSize, total int) types.Pagination {
 	return types.Pagination{
 		Page:       page,
 		PageSize:   pageSize,
-		Total:      total,
-		TotalPages: totalPages,
+		Total:      &total,
+		TotalPages: &totalPages,
 		HasNext:    page < totalPages,
 		HasPrev:    page > 1,
 	}
 }
+
+// PageWithoutCount calculates pagination metadata for a page fetched without a
+// total count, which holds one look-ahead row past the page when a next page
+// exists. It returns the page with that row dropped.
+func PageWithoutCount[T any](items []T, page, pageSize int) ([]T, types.Pagination) {
+	hasNext := len(items) > pageSize
+	if hasNext {
+		items = items[:pageSize]
+	}
+
+	return items, types.Pagination{
+		Page:     page,
+		PageSize: pageSize,
+		HasNext:  hasNext,
+		HasPrev:  page > 1,
+	}
+}
+
+// Paginate trims and calculates pagination metadata for a list page fetched with
+// params. Pages fetched without a count or after a cursor hold a look-ahead row
+// when a next page exists. cursorOf returns the cursor of an item, from which
+// the next cursor is taken.
+func Paginate[T any](items []T, params types.PaginationParams, total int, cursorOf func(T) types.Cursor) ([]T, types.Pagination) {
+	var pagination types.Pagination
+	if params.SkipCount || params.Cursor != nil {
+		items, pagination = PageWithoutCount(items, params.Page, params.PageSize)
+	} else {
+		pagination = CalculatePagination(params.Page, params.PageSize, total)
+	}
+
+	// A cursor replaces the page number, but a counted total still covers the whole list
+	if params.Cursor != nil || params.CursorOnly {
+		pagination.Page = 0
+		pagination.TotalPages = nil
+		pagination.HasPrev = params.Cursor != nil
+		if !params.SkipCount {
+			pagination.Total = &total
+		}
+	}
+
+	if pagination.HasNext && len(items) > 0 {
+		pagination.NextCursor = EncodeCursor(cursorOf(items[len(items)-1]))
+	}
+	return items, pagination
+}
+
+// errInvalidCursor is returned for cursors that were not issued by EncodeCursor
+var errInvalidCursor = errors.New("cursor is invalid")
+
+// GetCursor extracts the keyset pagination cursor from the request, or nil when there is none
+func GetCursor(c *gin.Context) (*types.Cursor, error) {
+	encoded := c.Query("cursor")
+	if encoded == "" {
+		return nil, nil
+	}
+
+	cursor, err := DecodeCursor(encoded)
+	if err != nil {
+		return nil, err
+	}
+	return &cursor, nil
+}
+
+// EncodeCursor encodes a cursor as an opaque URL-safe string
+func EncodeCursor(cursor types.Cursor) string {
+	raw := cursor.CreatedAt.UTC().Format(time.RFC3339Nano) + "," + cursor.ID
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// DecodeCursor decodes a cursor encoded by EncodeCursor
+func DecodeCursor(encoded string) (types.Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return types.Cursor{}, errInvalidCursor
+	}
+
+	createdAt, id, ok := strings.Cut(string(raw), ",")
+	if !ok || id == "" {
+		return types.Cursor{}, errInvalidCursor
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, createdAt)
+	if err != nil {
+		return types.Cursor{}, errInvalidCursor
+	}
+	return types.Cursor{CreatedAt: t, ID: id}, nil
+}
+
+// skipCount reports whether the client opted out of the total count with count=false
+func skipCount(c *gin.Context) bool {
+	count, err := strconv.ParseBool(c.Query("count"))
+	return err == nil && !count
+}
+
+// fuzzy reports whether fuzzy=true asks for typo-tolerant search
+func fuzzy(c *gin.Context) bool {
+	fuzzy, err := strconv.ParseBool(c.Query("fuzzy"))
+	return err == nil && fuzzy
+}
+
+// includeArchived reports whether include_archived=true asks to search
+// soft-deleted services too
+func includeArchived(c *gin.Context) bool {
+	include, err := strconv.ParseBool(c.Query("include_archived"))
+	return err == nil && include
+}
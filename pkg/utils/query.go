@@ -0,0 +1,40 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MinQueryLength is the shortest search query matched against the full-text
+// index, in characters; shorter ones match nearly every service
+const MinQueryLength = 2
+
+// MaxQueryLength bounds the length of a search query, in characters
+const MaxQueryLength = 200
+
+// ErrInvalidQuery is returned for search queries that ValidateQuery rejects
+var ErrInvalidQuery = errors.New("invalid search query")
+
+// ValidateQuery checks a trimmed search query is valid UTF-8 without control
+// characters, and between minLength and MaxQueryLength characters long
+func ValidateQuery(q string, minLength int) error {
+	if !utf8.ValidString(q) {
+		return fmt.Errorf("%w: q must be valid UTF-8", ErrInvalidQuery)
+	}
+	for _, r := range q {
+		if unicode.IsControl(r) {
+			return fmt.Errorf("%w: q must not contain control characters", ErrInvalidQuery)
+		}
+	}
+
+	length := utf8.RuneCountInString(q)
+	if length < minLength {
+		return fmt.Errorf("%w: q must be at least %d characters", ErrInvalidQuery, minLength)
+	}
+	if length > MaxQueryLength {
+		return fmt.Errorf("%w: q must be at most %d characters", ErrInvalidQuery, MaxQueryLength)
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package utils
+
+import (
+	"strconv"
+	"strings"
+)
+
+// IsMajorBump reports whether semver to increments the part of semver from
+// that signals incompatible changes: the major version, or the minor version
+// while the major version is 0, so 1.4.2 to 2.0.0 and 0.3.1 to 0.4.0 are
+// major bumps. Semvers that do not begin with numeric major and minor versions
+// are never major bumps.
+func IsMajorBump(from, to string) bool {
+	fromMajor, fromMinor, ok := majorMinor(from)
+	if !ok {
+		return false
+	}
+	toMajor, toMinor, ok := majorMinor(to)
+	if !ok {
+		return false
+	}
+	if fromMajor == 0 && toMajor == 0 {
+		return toMinor > fromMinor
+	}
+	return toMajor > fromMajor
+}
+
+// majorMinor returns the major and minor versions of a semver, accepting a v prefix
+func majorMinor(semver string) (major, minor int, ok bool) {
+	semver = strings.TrimPrefix(strings.TrimPrefix(strings.TrimSpace(semver), "v"), "V")
+	parts := strings.SplitN(semver, ".", 3)
+	if len(parts) < 2 {
+		return 0, 0, false
+	}
+	// The minor version may be followed by a pre-release or build, as in 1.0-rc.1
+	minorPart := parts[1]
+	if i := strings.IndexAny(minorPart, "-+"); i >= 0 {
+		minorPart = minorPart[:i]
+	}
+	major, err := strconv.Atoi(parts[0])
+	if err != nil || major < 0 {
+		return 0, 0, false
+	}
+	minor, err = strconv.Atoi(minorPart)
+	if err != nil || minor < 0 {
+		return 0, 0, false
+	}
+	return major, minor, true
+}
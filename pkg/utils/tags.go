@@ -0,0 +1,74 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yashjain/konnect/pkg/types"
+)
+
+// MaxTags bounds the tags of one service, and of one tag filter
+const MaxTags = 20
+
+// MaxTagLength bounds the length of a tag
+const MaxTagLength = 40
+
+// ErrInvalidTags is returned for tags that NormalizeTags rejects
+var ErrInvalidTags = errors.New("invalid tags")
+
+var errInvalidTagMode = errors.New("tag_mode must be all or any")
+
+// NormalizeTags lowercases and trims tags, drops duplicates and sorts them. Tags
+// may only contain letters, digits, hyphens, underscores and dots, so they can be
+// listed comma-separated. A nil slice stays nil.
+func NormalizeTags(tags []string) ([]string, error) {
+	if tags == nil {
+		return nil, nil
+	}
+
+	seen := make(map[string]bool, len(tags))
+	normalized := []string{}
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || len(tag) > MaxTagLength {
+			return nil, fmt.Errorf("%w: tags must be 1 to %d characters", ErrInvalidTags, MaxTagLength)
+		}
+		for _, r := range tag {
+			if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.') {
+				return nil, fmt.Errorf("%w: unsupported character %q in %q", ErrInvalidTags, r, tag)
+			}
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			normalized = append(normalized, tag)
+		}
+	}
+	if len(normalized) > MaxTags {
+		return nil, fmt.Errorf("%w: more than %d tags", ErrInvalidTags, MaxTags)
+	}
+
+	sort.Strings(normalized)
+	return normalized, nil
+}
+
+// GetTagFilter parses repeated tag= parameters and tag_mode=all (the default) or any
+func GetTagFilter(c *gin.Context) (types.TagFilter, error) {
+	var filter types.TagFilter
+	switch c.DefaultQuery("tag_mode", types.TagModeAll) {
+	case types.TagModeAll:
+	case types.TagModeAny:
+		filter.Any = true
+	default:
+		return types.TagFilter{}, errInvalidTagMode
+	}
+
+	tags, err := NormalizeTags(c.QueryArray("tag"))
+	if err != nil {
+		return types.TagFilter{}, err
+	}
+	filter.Tags = tags
+	return filter, nil
+}
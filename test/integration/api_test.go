@@ -14,11 +14,19 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/yashjain/konnect/internal/auth"
 	"github.com/yashjain/konnect/internal/database"
 	"github.com/yashjain/konnect/internal/handlers"
+	"github.com/yashjain/konnect/internal/middleware"
 	"github.com/yashjain/konnect/internal/models"
 )
 
+const (
+	testOrgID  = "org-1"
+	otherOrgID = "org-2"
+	aclOrgID   = "org-3"
+)
+
 func TestMain(m *testing.M) {
 	// Setup test database
 	setupTestDB()
@@ -32,6 +40,9 @@ func TestMain(m *testing.M) {
 	os.Exit(code)
 }
 
+// testStore is the repository under test, shared by every router
+var testStore *database.Store
+
 func setupTestDB() {
 	// Use test database or create one
 	dsn := os.Getenv("TEST_MYSQL_DSN")
@@ -43,7 +54,9 @@ func setupTestDB() {
 	_ = os.Setenv("MYSQL_DSN", dsn)
 
 	// Initialize database
-	if err := database.Init(); err != nil {
+	var err error
+	testStore, err = database.Open()
+	if err != nil {
 		panic(fmt.Sprintf("Failed to connect to test database: %v", err))
 	}
 
@@ -58,37 +71,56 @@ func setupTestDB() {
 }
 
 func cleanupTestData() {
-	if database.DB != nil {
+	if testStore != nil {
 		// Clean up test data
-		_, _ = database.DB.Exec("DELETE FROM versions")
-		_, _ = database.DB.Exec("DELETE FROM services")
+		_, _ = testStore.DB().Exec("DELETE FROM service_acls")
+		_, _ = testStore.DB().Exec("DELETE FROM versions")
+		_, _ = testStore.DB().Exec("DELETE FROM services")
+		_, _ = testStore.DB().Exec("DELETE FROM organizations")
 	}
 }
 
 func cleanupTestDB() {
-	if database.DB != nil {
+	if testStore != nil {
 		// Clean up test data
-		_, _ = database.DB.Exec("DELETE FROM versions")
-		_, _ = database.DB.Exec("DELETE FROM services")
-		_ = database.Close()
+		_, _ = testStore.DB().Exec("DELETE FROM service_acls")
+		_, _ = testStore.DB().Exec("DELETE FROM versions")
+		_, _ = testStore.DB().Exec("DELETE FROM services")
+		_, _ = testStore.DB().Exec("DELETE FROM organizations")
+		_ = testStore.Close()
 	}
 }
 
 func createTestTables() {
+	// Create organizations table
+	organizationsSQL := `
+	CREATE TABLE IF NOT EXISTS organizations (
+		id          CHAR(36)     NOT NULL,
+		name        VARCHAR(255) NOT NULL,
+		slug        VARCHAR(255) NOT NULL,
+		created_at  TIMESTAMP    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (id),
+		UNIQUE KEY uq_organizations_slug (slug)
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+	`
+
 	// Create services table
 	servicesSQL := `
 	CREATE TABLE IF NOT EXISTS services (
 		id            CHAR(36)     NOT NULL,
+		org_id        CHAR(36)     NOT NULL,
 		name          VARCHAR(255) NOT NULL,
 		slug          VARCHAR(255) NOT NULL,
 		description   TEXT NULL,
+		visibility    ENUM('public','private') NOT NULL DEFAULT 'public',
 		created_at    TIMESTAMP    NOT NULL DEFAULT CURRENT_TIMESTAMP,
 		updated_at    TIMESTAMP    NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
-		versions_count INT NOT NULL DEFAULT 0,
+		deleted_at    TIMESTAMP    NULL,
 		PRIMARY KEY (id),
-		UNIQUE KEY uq_services_name (name),
-		UNIQUE KEY uq_services_slug (slug),
-		FULLTEXT KEY ft_services_name_desc (name, description)
+		UNIQUE KEY uq_services_org_name (org_id, name),
+		UNIQUE KEY uq_services_org_slug (org_id, slug),
+		FULLTEXT KEY ft_services_name_desc (name, description),
+		CONSTRAINT fk_services_org FOREIGN KEY (org_id) REFERENCES organizations(id) ON DELETE CASCADE
 	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
 	`
 
@@ -101,6 +133,7 @@ func createTestTables() {
 		status      ENUM('draft','released','deprecated') NOT NULL,
 		changelog   TEXT NULL,
 		created_at  TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		deleted_at  TIMESTAMP NULL,
 		PRIMARY KEY (id),
 		KEY idx_versions_service_id (service_id),
 		KEY idx_versions_status (status),
@@ -108,11 +141,34 @@ func createTestTables() {
 	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
 	`
 
-	_, _ = database.DB.Exec(servicesSQL)
-	_, _ = database.DB.Exec(versionsSQL)
+	// Create service ACL table
+	aclsSQL := `
+	CREATE TABLE IF NOT EXISTS service_acls (
+		id            CHAR(36)    NOT NULL,
+		service_id    CHAR(36)    NOT NULL,
+		subject_type  ENUM('user','team') NOT NULL,
+		subject_id    CHAR(36)    NOT NULL,
+		permission    ENUM('read','write') NOT NULL,
+		created_at    TIMESTAMP   NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (id),
+		UNIQUE KEY uq_service_acls_subject (service_id, subject_type, subject_id),
+		CONSTRAINT fk_service_acls_service FOREIGN KEY (service_id) REFERENCES services(id) ON DELETE CASCADE
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+	`
+
+	_, _ = testStore.DB().Exec(organizationsSQL)
+	_, _ = testStore.DB().Exec(servicesSQL)
+	_, _ = testStore.DB().Exec(versionsSQL)
+	_, _ = testStore.DB().Exec(aclsSQL)
 }
 
 func seedTestData() {
+	// Insert test organizations
+	_, _ = testStore.DB().Exec("INSERT INTO organizations (id, name, slug) VALUES (?, ?, ?), (?, ?, ?)",
+		testOrgID, "Test Org", "test-org", otherOrgID, "Other Org", "other-org")
+	_, _ = testStore.DB().Exec("INSERT INTO organizations (id, name, slug) VALUES (?, ?, ?)",
+		aclOrgID, "ACL Org", "acl-org")
+
 	// Insert test services
 	services := []models.Service{
 		{ID: "service-1", Name: "Test Service 1", Slug: "test-service-1", Description: "First test service"},
@@ -121,10 +177,21 @@ func seedTestData() {
 	}
 
 	for _, service := range services {
-		_, _ = database.DB.Exec("INSERT INTO services (id, name, slug, description) VALUES (?, ?, ?, ?)",
-			service.ID, service.Name, service.Slug, service.Description)
+		_, _ = testStore.DB().Exec("INSERT INTO services (id, org_id, name, slug, description) VALUES (?, ?, ?, ?, ?)",
+			service.ID, testOrgID, service.Name, service.Slug, service.Description)
 	}
 
+	// Insert a service owned by another organization, which must never be visible to testOrgID
+	_, _ = testStore.DB().Exec("INSERT INTO services (id, org_id, name, slug, description) VALUES (?, ?, ?, ?, ?)",
+		"foreign-service", otherOrgID, "Foreign Test Service", "foreign-test-service", "Owned by another test org")
+
+	// Insert a public service and a private service readable by a single team
+	_, _ = testStore.DB().Exec("INSERT INTO services (id, org_id, name, slug, description, visibility) VALUES (?, ?, ?, ?, ?, ?), (?, ?, ?, ?, ?, ?)",
+		"public-service", aclOrgID, "Public Ledger", "public-ledger", "Open to all", "public",
+		"private-service", aclOrgID, "Private Ledger", "private-ledger", "Internal only", "private")
+	_, _ = testStore.DB().Exec("INSERT INTO service_acls (id, service_id, subject_type, subject_id, permission) VALUES (?, ?, ?, ?, ?)",
+		"acl-1", "private-service", "team", "team-readers", "read")
+
 	// Insert test versions
 	versions := []models.Version{
 		{ID: "version-1", ServiceID: "service-1", Semver: "1.0.0", Status: "released", Changelog: "Initial release"},
@@ -134,28 +201,42 @@ func seedTestData() {
 	}
 
 	for _, version := range versions {
-		_, _ = database.DB.Exec("INSERT INTO versions (id, service_id, semver, status, changelog) VALUES (?, ?, ?, ?, ?)",
+		_, _ = testStore.DB().Exec("INSERT INTO versions (id, service_id, semver, status, changelog) VALUES (?, ?, ?, ?, ?)",
 			version.ID, version.ServiceID, version.Semver, version.Status, version.Changelog)
 	}
-
-	// Update versions_count
-	_, _ = database.DB.Exec("UPDATE services SET versions_count = (SELECT COUNT(*) FROM versions WHERE service_id = services.id)")
 }
 
 func setupTestRouter() *gin.Engine {
+	return setupTestRouterForOrg(testOrgID)
+}
+
+// setupTestRouterForOrg builds a router whose requests are scoped to orgID,
+// standing in for the bearer-token middleware
+func setupTestRouterForOrg(orgID string) *gin.Engine {
+	return setupTestRouterForPrincipal(auth.Principal{OrgID: orgID})
+}
+
+// setupTestRouterForPrincipal builds a router whose requests are made by principal
+func setupTestRouterForPrincipal(principal auth.Principal) *gin.Engine {
 	gin.SetMode(gin.TestMode)
 	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		middleware.SetPrincipal(c, principal)
+	})
 
 	// Add routes
-	router.GET("/health", handlers.HealthCheck)
-	router.GET("/api/v1/services", handlers.GetServices)
-	router.GET("/api/v1/services/search", handlers.SearchServices)
-	router.POST("/api/v1/services", handlers.CreateService)
-	router.GET("/api/v1/services/:id", handlers.GetService)
-	router.PUT("/api/v1/services/:id", handlers.UpdateService)
-	router.DELETE("/api/v1/services/:id", handlers.DeleteService)
-	router.GET("/api/v1/services/:id/versions", handlers.GetVersions)
-	router.POST("/api/v1/services/:id/versions", handlers.CreateVersion)
+	router.GET("/health", handlers.HealthCheck(testStore))
+	router.GET("/api/v1/services", handlers.GetServices(testStore))
+	router.GET("/api/v1/services/search", handlers.SearchServices(testStore))
+	router.POST("/api/v1/services", handlers.CreateService(testStore))
+	router.GET("/api/v1/services/:id", handlers.GetService(testStore, testStore))
+	router.PUT("/api/v1/services/:id", handlers.UpdateService(testStore, testStore))
+	router.DELETE("/api/v1/services/:id", handlers.DeleteService(testStore, testStore))
+	router.GET("/api/v1/services/:id/versions", handlers.GetVersions(testStore, testStore))
+	router.POST("/api/v1/services/:id/versions", handlers.CreateVersion(testStore, testStore))
+	router.GET("/api/v1/services/:id/acl", handlers.GetServiceACLs(testStore))
+	router.POST("/api/v1/services/:id/acl", handlers.CreateServiceACL(testStore))
+	router.DELETE("/api/v1/services/:id/acl/:acl_id", handlers.DeleteServiceACL(testStore))
 
 	return router
 }
@@ -485,7 +566,7 @@ func TestCreateVersionIntegration(t *testing.T) {
 				Status:    "released",
 				Changelog: "Test version",
 			},
-			expectedStatus: http.StatusInternalServerError, // Foreign key constraint violation
+			expectedStatus: http.StatusNotFound,
 		},
 	}
 
@@ -513,3 +594,142 @@ func TestCreateVersionIntegration(t *testing.T) {
 		})
 	}
 }
+
+func TestOrganizationIsolationIntegration(t *testing.T) {
+	router := setupTestRouter()
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		body           string
+		expectedStatus int
+	}{
+		{
+			name:           "get foreign service",
+			method:         "GET",
+			path:           "/api/v1/services/foreign-service",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "update foreign service",
+			method:         "PUT",
+			path:           "/api/v1/services/foreign-service",
+			body:           `{"name":"Hijacked","slug":"hijacked","description":"x"}`,
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "delete foreign service",
+			method:         "DELETE",
+			path:           "/api/v1/services/foreign-service",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "create version on foreign service",
+			method:         "POST",
+			path:           "/api/v1/services/foreign-service/versions",
+			body:           `{"semver":"9.9.9","status":"draft","changelog":"x"}`,
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(tt.method, tt.path, bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+		})
+	}
+
+	// The foreign service is excluded from listings and search
+	req, _ := http.NewRequest("GET", "/api/v1/services/search?q=foreign", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	data, _ := response["data"].([]interface{})
+	assert.Empty(t, data)
+
+	// The owning organization still sees it
+	otherRouter := setupTestRouterForOrg(otherOrgID)
+	req, _ = http.NewRequest("GET", "/api/v1/services/foreign-service", nil)
+	w = httptest.NewRecorder()
+	otherRouter.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestServiceVisibilityIntegration(t *testing.T) {
+	outsider := setupTestRouterForPrincipal(auth.Principal{OrgID: aclOrgID, UserID: "user-outsider"})
+	reader := setupTestRouterForPrincipal(auth.Principal{OrgID: aclOrgID, UserID: "user-reader", TeamIDs: []string{"team-readers"}})
+
+	tests := []struct {
+		name           string
+		router         *gin.Engine
+		method         string
+		path           string
+		body           string
+		expectedStatus int
+	}{
+		{
+			name:           "outsider cannot see private service",
+			router:         outsider,
+			method:         "GET",
+			path:           "/api/v1/services/private-service",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "outsider can see public service",
+			router:         outsider,
+			method:         "GET",
+			path:           "/api/v1/services/public-service",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "team reader can see private service",
+			router:         reader,
+			method:         "GET",
+			path:           "/api/v1/services/private-service",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "team reader cannot write private service",
+			router:         reader,
+			method:         "POST",
+			path:           "/api/v1/services/private-service/versions",
+			body:           `{"semver":"1.0.0","status":"draft","changelog":"x"}`,
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "outsider cannot write public service",
+			router:         outsider,
+			method:         "POST",
+			path:           "/api/v1/services/public-service/versions",
+			body:           `{"semver":"1.0.0","status":"draft","changelog":"x"}`,
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "outsider cannot make public service private",
+			router:         outsider,
+			method:         "PUT",
+			path:           "/api/v1/services/public-service",
+			body:           `{"name":"Public Ledger","visibility":"private"}`,
+			expectedStatus: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(tt.method, tt.path, bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			tt.router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+		})
+	}
+}
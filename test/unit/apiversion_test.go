@@ -0,0 +1,103 @@
+package unit
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yashjain/konnect/internal/auth"
+	"github.com/yashjain/konnect/internal/handlers"
+	"github.com/yashjain/konnect/internal/middleware"
+	"github.com/yashjain/konnect/internal/models"
+	"github.com/yashjain/konnect/internal/repository"
+)
+
+// setupVersionedRouter serves the service routes backed by repo in a deprecated
+// v1, with legacy errors, and in v2
+func setupVersionedRouter(repo repository.Repository, deprecatedAt, sunset time.Time) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		middleware.SetPrincipal(c, auth.Principal{OrgID: "00000000-0000-0000-0000-000000000001"})
+	})
+	v1 := router.Group("/api/v1")
+	v1.Use(middleware.Deprecated(deprecatedAt, sunset, "/api/v1", "/api/v2"))
+	v2 := router.Group("/api/v2")
+	v2.Use(middleware.Versioned(2), middleware.Problems())
+	for _, api := range []*gin.RouterGroup{v1, v2} {
+		api.GET("/services", handlers.GetServices(repo))
+		api.GET("/services/:id", handlers.GetService(repo, repo))
+	}
+	return router
+}
+
+func TestAPIVersions(t *testing.T) {
+	captureLogs(t)
+	deprecatedAt := time.Date(2026, time.October, 16, 0, 0, 0, 0, time.UTC)
+	sunset := time.Date(2027, time.October, 16, 0, 0, 0, 0, time.UTC)
+	router := setupVersionedRouter(openSQLiteStore(t), deprecatedAt, sunset)
+	const notificationsID = "6f1c2f4e-0000-4000-8000-000000000003"
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// v1 announces its deprecation and successor, next to its page links
+	w := get("/api/v1/services?page=2&page_size=1")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "@1792108800", w.Header().Get("Deprecation"))
+	assert.Equal(t, "Sat, 16 Oct 2027 00:00:00 GMT", w.Header().Get("Sunset"))
+	links := w.Header().Values("Link")
+	require.Len(t, links, 2)
+	assert.Equal(t, `</api/v2/services>; rel="successor-version"`, links[0])
+	assert.Contains(t, links[1], `rel="first"`)
+
+	// v1 keeps its errors as they were
+	w = get("/api/v1/services?page_size=500")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "application/json")
+
+	// v2 is not deprecated, and links within itself
+	w = get("/api/v2/services/" + notificationsID)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Empty(t, w.Header().Get("Deprecation"))
+	assert.Empty(t, w.Header().Get("Sunset"))
+	var service models.Service
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &service))
+	assert.Equal(t, "/api/v2/services/"+notificationsID+"/versions", service.Links["versions"].Href)
+
+	// v2 pages by cursor alone
+	var page struct {
+		Pagination map[string]interface{} `json:"pagination"`
+	}
+	w = get("/api/v2/services?page_size=2")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	assert.NotContains(t, page.Pagination, "page")
+	assert.NotContains(t, page.Pagination, "total_pages")
+	assert.Equal(t, float64(3), page.Pagination["total"])
+	assert.Equal(t, false, page.Pagination["has_prev"])
+	cursor, _ := page.Pagination["next_cursor"].(string)
+	require.NotEmpty(t, cursor)
+	assert.Regexp(t, `^</api/v2/services\?page_size=2>; rel="first", </api/v2/services\?cursor=[^>]+&page_size=2>; rel="next"$`, w.Header().Get("Link"))
+
+	w = get("/api/v2/services?page_size=2&cursor=" + cursor)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	assert.Equal(t, true, page.Pagination["has_prev"])
+	assert.Equal(t, false, page.Pagination["has_next"])
+
+	// and answers errors with problem details
+	w = get("/api/v2/services?page=2")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), "follow next_cursor")
+}
@@ -0,0 +1,144 @@
+package unit
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yashjain/konnect/internal/audit"
+	"github.com/yashjain/konnect/internal/logging"
+	"github.com/yashjain/konnect/internal/webhook"
+)
+
+func TestAuditWebhookRedeliversUntilAccepted(t *testing.T) {
+	buf := captureLogs(t)
+
+	var attempts atomic.Int32
+	received := make(chan []audit.Entry, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var entries []audit.Entry
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&entries))
+		assert.NotEmpty(t, r.Header.Get(webhook.HeaderSignature))
+		assert.Equal(t, entries[0].ID, r.Header.Get(webhook.HeaderID))
+		if attempts.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		received <- entries
+	}))
+	defer server.Close()
+
+	exporter := audit.NewExporter(audit.NewWebhookSink(server.URL, "s3cret", time.Second), 10, 10, time.Second)
+	audit.SetExporter(exporter)
+	t.Cleanup(func() { audit.SetExporter(nil) })
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Recorded before the exporter runs, so both go out in one batch
+	reqCtx := logging.WithRequestID(context.Background(), "req-1")
+	audit.Log(reqCtx, "Service access granted", "service_id", "svc-1", "permission", "write")
+	audit.Log(reqCtx, "Configuration changed", "source", "dial app:s3cret@tcp(db:3306)/app")
+	go exporter.Run(ctx)
+
+	var entries []audit.Entry
+	select {
+	case entries = <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no entries delivered")
+	}
+	assert.Equal(t, int32(2), attempts.Load(), "the failed batch is sent again")
+	require.Len(t, entries, 2)
+	assert.Equal(t, "Service access granted", entries[0].Action)
+	assert.Equal(t, "req-1", entries[0].RequestID)
+	assert.Equal(t, "svc-1", entries[0].Fields["service_id"])
+	assert.NotContains(t, entries[1].Fields["source"], "s3cret")
+
+	// Entries are logged as well as exported
+	var logged int
+	for _, record := range logRecords(t, buf) {
+		if record["audit"] == true {
+			assert.Equal(t, "WARN", record["level"])
+			logged++
+		}
+	}
+	assert.Equal(t, 2, logged)
+}
+
+func TestAuditSyslogFramesMessages(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	messages := make(chan string, 2)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		for {
+			prefix, err := r.ReadString(' ')
+			if err != nil {
+				return
+			}
+			n, err := strconv.Atoi(strings.TrimSpace(prefix))
+			if err != nil {
+				return
+			}
+			msg := make([]byte, n)
+			if _, err := io.ReadFull(r, msg); err != nil {
+				return
+			}
+			messages <- string(msg)
+		}
+	}()
+
+	sink, err := audit.NewSyslogSink("tcp://"+listener.Addr().String(), time.Second)
+	require.NoError(t, err)
+	entries := []audit.Entry{
+		{ID: "a1", Time: time.Now(), Action: "Team created"},
+		{ID: "a2", Time: time.Now(), Action: "Team member added"},
+	}
+	require.NoError(t, sink.Send(context.Background(), entries))
+
+	for _, entry := range entries {
+		select {
+		case msg := <-messages:
+			assert.True(t, strings.HasPrefix(msg, "<109>1 "), msg)
+			assert.Contains(t, msg, " konnect - audit - {")
+			assert.Contains(t, msg, `"id":"`+entry.ID+`"`)
+		case <-time.After(5 * time.Second):
+			t.Fatal("no syslog message received")
+		}
+	}
+
+	_, err = audit.NewSyslogSink("http://siem:514", time.Second)
+	assert.Error(t, err)
+}
+
+func TestAuditExporterDropsWhenQueueStaysFull(t *testing.T) {
+	buf := captureLogs(t)
+
+	// Not running, so the queue fills up
+	exporter := audit.NewExporter(audit.NewWebhookSink("http://127.0.0.1:1", "", time.Second), 1, 1, 10*time.Millisecond)
+	exporter.Record(context.Background(), audit.Entry{ID: "kept"})
+
+	start := time.Now()
+	exporter.Record(context.Background(), audit.Entry{ID: "dropped"})
+	assert.GreaterOrEqual(t, time.Since(start), 10*time.Millisecond, "waits for room before dropping")
+	assert.Contains(t, buf.String(), "Audit export queue full, dropping entry")
+	assert.Contains(t, buf.String(), `"audit_id":"dropped"`)
+}
@@ -0,0 +1,128 @@
+package unit
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yashjain/konnect/internal/auth"
+)
+
+func TestAccessTokenRoundTrip(t *testing.T) {
+	key := []byte("test-signing-key")
+
+	token, err := auth.IssueAccessToken(key, "org-1", "user-1", "session-1", time.Minute)
+	require.NoError(t, err)
+	assert.True(t, auth.LooksLikeAccessToken(token))
+
+	claims, err := auth.ParseAccessToken(key, token)
+	require.NoError(t, err)
+	assert.Equal(t, "org-1", claims.OrgID)
+	assert.Equal(t, "user-1", claims.Subject)
+	assert.Equal(t, "session-1", claims.SessionID)
+}
+
+func TestAccessTokenRejected(t *testing.T) {
+	key := []byte("test-signing-key")
+
+	expired, err := auth.IssueAccessToken(key, "org-1", "user-1", "session-1", -time.Minute)
+	require.NoError(t, err)
+	valid, err := auth.IssueAccessToken(key, "org-1", "user-1", "session-1", time.Minute)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name  string
+		key   []byte
+		token string
+	}{
+		{name: "expired", key: key, token: expired},
+		{name: "wrong key", key: []byte("other-key"), token: valid},
+		{name: "garbage", key: key, token: "a.b.c"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := auth.ParseAccessToken(tt.key, tt.token)
+			assert.ErrorIs(t, err, auth.ErrInvalidAccessToken)
+		})
+	}
+}
+
+func TestOpaqueTokens(t *testing.T) {
+	token, err := auth.GenerateToken()
+	require.NoError(t, err)
+	assert.Len(t, token, 64)
+	assert.False(t, auth.LooksLikeAccessToken(token))
+	assert.Equal(t, auth.HashToken(token), auth.HashToken(token))
+	assert.NotEqual(t, token, auth.HashToken(token))
+}
+
+func TestPasswordHashing(t *testing.T) {
+	hash, err := auth.HashPassword("s3cret")
+	require.NoError(t, err)
+	assert.True(t, auth.CheckPassword(hash, "s3cret"))
+	assert.False(t, auth.CheckPassword(hash, "wrong"))
+}
+
+func TestLockoutBacksOffExponentially(t *testing.T) {
+	lockout := auth.NewLockout(3, time.Minute, 10*time.Minute)
+	key := auth.IPKey("203.0.113.7")
+	now := time.Now()
+
+	assert.Zero(t, lockout.Fail(key, now))
+	assert.Zero(t, lockout.Fail(key, now))
+	assert.Zero(t, lockout.Locked(key, now))
+
+	assert.Equal(t, time.Minute, lockout.Fail(key, now))
+	assert.Equal(t, time.Minute, lockout.Locked(key, now))
+	assert.Zero(t, lockout.Locked(key, now.Add(time.Minute)))
+
+	assert.Equal(t, 2*time.Minute, lockout.Fail(key, now))
+	assert.Equal(t, 4*time.Minute, lockout.Fail(key, now))
+	assert.Equal(t, 8*time.Minute, lockout.Fail(key, now))
+	assert.Equal(t, 10*time.Minute, lockout.Fail(key, now), "lockout is capped at max")
+
+	// Other keys are unaffected
+	assert.Zero(t, lockout.Locked(auth.PrincipalKey("a@example.com"), now))
+
+	lockout.Reset(key)
+	assert.Zero(t, lockout.Locked(key, now))
+}
+
+func TestLockoutForgetsOldFailures(t *testing.T) {
+	lockout := auth.NewLockout(2, time.Minute, 10*time.Minute)
+	key := auth.PrincipalKey("User@Example.com ")
+	now := time.Now()
+
+	assert.Equal(t, auth.PrincipalKey("user@example.com"), key)
+	assert.Zero(t, lockout.Fail(key, now))
+	assert.Zero(t, lockout.Fail(key, now.Add(10*time.Minute)), "failures older than max no longer count")
+}
+
+func TestLockoutEvictsOldestKeys(t *testing.T) {
+	lockout := auth.NewLockout(1, time.Minute, 10*time.Minute)
+	now := time.Now()
+
+	// Past 10,000 tracked keys, the one that failed longest ago is forgotten
+	first, last := auth.IPKey("198.51.100.1"), auth.IPKey("198.51.100.2")
+	assert.Equal(t, time.Minute, lockout.Fail(first, now))
+	assert.Equal(t, time.Minute, lockout.Fail(last, now))
+	assert.Equal(t, 2*time.Minute, lockout.Fail(first, now.Add(time.Second)), "failing again makes a key the newest")
+	for i := 0; i < 9999; i++ {
+		lockout.Fail(auth.IPKey(fmt.Sprintf("10.0.%d.%d", i/256, i%256)), now.Add(time.Second))
+	}
+	assert.NotZero(t, lockout.Locked(first, now.Add(time.Second)))
+	assert.Zero(t, lockout.Locked(last, now.Add(time.Second)))
+}
+
+func TestLockoutDisabled(t *testing.T) {
+	lockout := auth.NewLockout(0, time.Minute, time.Hour)
+	key := auth.IPKey("203.0.113.7")
+	for i := 0; i < 10; i++ {
+		assert.Zero(t, lockout.Fail(key, time.Now()))
+	}
+	assert.Zero(t, lockout.Locked(key, time.Now()))
+}
@@ -0,0 +1,93 @@
+package unit
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+
+	"github.com/yashjain/konnect/internal/auth"
+	"github.com/yashjain/konnect/internal/handlers"
+	"github.com/yashjain/konnect/internal/middleware"
+)
+
+// backstageEntity is the part of an exported Backstage entity the tests look at
+type backstageEntity struct {
+	APIVersion string `yaml:"apiVersion"`
+	Kind       string `yaml:"kind"`
+	Metadata   struct {
+		Name        string            `yaml:"name"`
+		Annotations map[string]string `yaml:"annotations"`
+	} `yaml:"metadata"`
+	Spec struct {
+		Type         string   `yaml:"type"`
+		Lifecycle    string   `yaml:"lifecycle"`
+		Owner        string   `yaml:"owner"`
+		ProvidesAPIs []string `yaml:"providesApis"`
+		Definition   string   `yaml:"definition"`
+	} `yaml:"spec"`
+}
+
+func TestExportBackstage(t *testing.T) {
+	captureLogs(t)
+	store := openSQLiteStore(t)
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		middleware.SetPrincipal(c, auth.Principal{OrgID: "00000000-0000-0000-0000-000000000001"})
+	})
+	router.GET("/export/backstage", handlers.ExportBackstage(store, store))
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get("/export/backstage?owner=group:default/platform")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "application/yaml; charset=utf-8", w.Header().Get("Content-Type"))
+
+	entities := map[string]backstageEntity{}
+	dec := yaml.NewDecoder(strings.NewReader(w.Body.String()))
+	for {
+		var entity backstageEntity
+		err := dec.Decode(&entity)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		assert.Equal(t, "backstage.io/v1alpha1", entity.APIVersion)
+		assert.Equal(t, "group:default/platform", entity.Spec.Owner)
+		entities[entity.Kind+":"+entity.Metadata.Name] = entity
+	}
+	// Three components, and the two released versions of notifications
+	require.Len(t, entities, 5)
+
+	notifications := entities["Component:notifications"]
+	assert.Equal(t, "service", notifications.Spec.Type)
+	assert.Equal(t, "production", notifications.Spec.Lifecycle)
+	assert.Equal(t, []string{"notifications-1-0-0", "notifications-1-1-0"}, notifications.Spec.ProvidesAPIs)
+	assert.Equal(t, "6f1c2f4e-0000-4000-8000-000000000003", notifications.Metadata.Annotations["konnect/service-id"])
+
+	api := entities["API:notifications-1-1-0"]
+	assert.Equal(t, "Minor improvements", api.Spec.Definition)
+	assert.Equal(t, "7a2d3e5f-0000-4000-8000-000000000002", api.Metadata.Annotations["konnect/version-id"])
+
+	// Draft versions are not APIs yet
+	collectMoney := entities["Component:collect-money"]
+	assert.Equal(t, "experimental", collectMoney.Spec.Lifecycle)
+	assert.Empty(t, collectMoney.Spec.ProvidesAPIs)
+	assert.Equal(t, "experimental", entities["Component:locate-us"].Spec.Lifecycle)
+
+	// Owners are entity references
+	w = get("/export/backstage?owner=" + "%20platform")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
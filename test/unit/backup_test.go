@@ -0,0 +1,209 @@
+package unit
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yashjain/konnect/internal/handlers"
+	"github.com/yashjain/konnect/internal/models"
+	"github.com/yashjain/konnect/internal/repository"
+	"github.com/yashjain/konnect/pkg/types"
+)
+
+func backupRouter(repo repository.BackupRepository) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/admin/backup", handlers.ExportBackup(repo))
+	router.POST("/admin/restore", handlers.RestoreBackup(repo))
+	return router
+}
+
+func TestBackupRoundTrip(t *testing.T) {
+	source := openSQLiteStore(t)
+	ctx := context.Background()
+	const orgID = "00000000-0000-0000-0000-000000000001"
+
+	require.NoError(t, source.CreateOrganization(ctx, &models.Organization{ID: "org-2", Name: "Acme", Slug: "acme"}))
+	require.NoError(t, source.CreateService(ctx, &models.Service{ID: "svc-1", OrgID: "org-2", Name: "Payments", Slug: "payments", Visibility: models.VisibilityPublic}))
+	require.NoError(t, source.CreateVersion(ctx, "org-2", &models.Version{ID: "ver-1", ServiceID: "svc-1", Semver: "1.0.0", Status: "released", Changelog: "First"}))
+	require.NoError(t, source.CreateService(ctx, &models.Service{ID: "svc-2", OrgID: orgID, Name: "Retired", Slug: "retired", Visibility: models.VisibilityPublic}))
+	_, err := source.DeleteService(ctx, orgID, "svc-2")
+	require.NoError(t, err)
+
+	// The version's spec, deployments and environments, and the category tree,
+	// whose root was created after the category moved under it
+	score := 90
+	require.NoError(t, source.SetVersionSpec(ctx, "org-2", &models.VersionSpec{VersionID: "ver-1", ServiceID: "svc-1", Format: "yaml", Content: []byte("openapi: 3.0.0\n"), Digest: strings.Repeat("0", 64), Score: &score}))
+	for _, d := range []models.Deployment{{ID: "dep-1", Environment: "staging"}, {ID: "dep-2", Environment: "prod"}} {
+		d.OrgID, d.ServiceID, d.VersionID = "org-2", "svc-1", "ver-1"
+		require.NoError(t, source.CreateDeployment(ctx, &d))
+	}
+	require.NoError(t, source.CreateCategory(ctx, &models.Category{ID: "cat-billing", OrgID: "org-2", Name: "Billing", Slug: "billing"}))
+	require.NoError(t, source.CreateCategory(ctx, &models.Category{ID: "cat-finance", OrgID: "org-2", Name: "Finance", Slug: "finance"}))
+	parent := "cat-finance"
+	_, err = source.UpdateCategory(ctx, "org-2", "cat-billing", &models.Category{Name: "Billing", Slug: "billing", ParentID: &parent})
+	require.NoError(t, err)
+	require.NoError(t, source.AddServiceCategory(ctx, "org-2", "svc-1", "cat-billing"))
+
+	// Users and teams with the access they were granted, and the teams consuming services
+	require.NoError(t, source.CreateUser(ctx, &models.User{ID: "user-1", OrgID: "org-2", Email: "ana@acme.test", Name: "Ana"}, "hash"))
+	require.NoError(t, source.CreateTeam(ctx, &models.Team{ID: "team-1", OrgID: "org-2", Name: "Payments"}))
+	_, err = source.AddTeamMember(ctx, "org-2", "team-1", "user-1")
+	require.NoError(t, err)
+	require.NoError(t, source.CreateServiceACL(ctx, "org-2", &models.ServiceACL{ID: "acl-1", ServiceID: "svc-1", SubjectType: models.SubjectTeam, SubjectID: "team-1", Permission: models.PermissionWrite}))
+	version := "ver-1"
+	require.NoError(t, source.CreateConsumer(ctx, &models.Consumer{ID: "con-1", OrgID: "org-2", ServiceID: "svc-1", TeamID: "team-1", VersionID: &version}))
+
+	w := httptest.NewRecorder()
+	backupRouter(source).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/backup", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/x-ndjson", w.Header().Get("Content-Type"))
+	backup := w.Body.Bytes()
+
+	// Records come one per line, organizations before services before versions,
+	// each after the rows they belong to
+	var recordTypes []string
+	scanner := bufio.NewScanner(bytes.NewReader(backup))
+	for scanner.Scan() {
+		var record models.BackupRecord
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &record))
+		if len(recordTypes) == 0 || recordTypes[len(recordTypes)-1] != record.Type {
+			recordTypes = append(recordTypes, record.Type)
+		}
+	}
+	assert.Equal(t, []string{models.BackupOrganization, models.BackupService, models.BackupVersion, models.BackupSpec,
+		models.BackupDeployment, models.BackupEnvironment, models.BackupCategory, models.BackupServiceCategory,
+		models.BackupUser, models.BackupTeam, models.BackupTeamMember, models.BackupServiceACL, models.BackupConsumer}, recordTypes)
+
+	// The target shares the demo seed, which is skipped, and receives everything else
+	target := openSQLiteStore(t)
+	w = httptest.NewRecorder()
+	backupRouter(target).ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/admin/restore", bytes.NewReader(backup)))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var result models.RestoreResult
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+	assert.Equal(t, models.RestoreResult{Organizations: 1, Services: 2, Versions: 1, Specs: 1, Deployments: 2, Environments: 2,
+		Categories: 2, ServiceCategories: 1, Users: 1, Teams: 1, TeamMembers: 1, ServiceACLs: 1, Consumers: 1, Skipped: result.Skipped}, result)
+	assert.NotZero(t, result.Skipped)
+
+	restored, err := target.GetServiceByID(ctx, "org-2", "svc-1")
+	require.NoError(t, err)
+	assert.Equal(t, 1, restored.VersionsCount)
+	original, err := source.GetServiceByID(ctx, "org-2", "svc-1")
+	require.NoError(t, err)
+	assert.True(t, original.CreatedAt.Equal(restored.CreatedAt))
+	versions, _, err := target.GetVersions(ctx, "org-2", "svc-1", types.PaginationParams{Page: 1, PageSize: 10})
+	require.NoError(t, err)
+	require.Len(t, versions, 1)
+	assert.Equal(t, "First", versions[0].Changelog)
+	assert.ElementsMatch(t, []string{"prod", "staging"}, versions[0].Environments)
+	assert.True(t, versions[0].HasSpec)
+
+	spec, err := target.GetVersionSpec(ctx, "org-2", "svc-1", "ver-1")
+	require.NoError(t, err)
+	assert.Equal(t, "openapi: 3.0.0\n", string(spec.Content))
+	require.NotNil(t, spec.Score)
+	assert.Equal(t, 90, *spec.Score)
+	deployments, err := target.GetDeployments(ctx, "org-2", "svc-1", "ver-1", 10)
+	require.NoError(t, err)
+	assert.Len(t, deployments, 2)
+	categories, err := target.GetServiceCategories(ctx, "org-2", "svc-1")
+	require.NoError(t, err)
+	require.Len(t, categories, 1)
+	require.NotNil(t, categories[0].ParentID)
+	assert.Equal(t, "cat-finance", *categories[0].ParentID)
+
+	// Access control comes back with the users and teams it names, and users
+	// keep their passwords
+	acls, err := target.GetServiceACLs(ctx, "org-2", "svc-1")
+	require.NoError(t, err)
+	require.Len(t, acls, 1)
+	assert.Equal(t, models.PermissionWrite, acls[0].Permission)
+	teamIDs, err := target.GetTeamIDsForUser(ctx, "user-1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"team-1"}, teamIDs)
+	userID, _, hash, err := target.GetUserCredentials(ctx, "ana@acme.test")
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", userID)
+	assert.Equal(t, "hash", hash)
+	consumers, err := target.GetConsumers(ctx, "org-2", "svc-1")
+	require.NoError(t, err)
+	require.Len(t, consumers, 1)
+	assert.Equal(t, "Payments", consumers[0].TeamName)
+
+	// Soft-deleted services stay deleted
+	retired, err := target.GetServiceByID(ctx, orgID, "svc-2", types.ReadOptions{IncludeDeleted: true})
+	require.NoError(t, err)
+	assert.NotNil(t, retired.DeletedAt)
+
+	// Restoring again changes nothing
+	w = httptest.NewRecorder()
+	backupRouter(target).ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/admin/restore", bytes.NewReader(backup)))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+	assert.Equal(t, models.RestoreResult{Skipped: len(strings.Split(strings.TrimSpace(string(backup)), "\n"))}, result)
+}
+
+func TestRestoreRejectsInvalidBackup(t *testing.T) {
+	store := openSQLiteStore(t)
+	ctx := context.Background()
+
+	// A bad record rolls back the records before it
+	body := `{"type":"organization","organization":{"id":"org-2","name":"Acme","slug":"acme","created_at":"2024-01-01T00:00:00Z"}}
+{"error":"backup aborted"}
+`
+	w := httptest.NewRecorder()
+	backupRouter(store).ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/admin/restore", strings.NewReader(body)))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "record 2")
+
+	orgs, err := store.GetOrganizations(ctx)
+	require.NoError(t, err)
+	for _, o := range orgs {
+		assert.NotEqual(t, "org-2", o.ID)
+	}
+
+	// Grants to unknown subject types are rejected
+	body = `{"type":"service_acl","service_acl":{"id":"acl-1","service_id":"svc-1","subject_type":"organization","subject_id":"org-2","permission":"read"}}
+`
+	w = httptest.NewRecorder()
+	backupRouter(store).ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/admin/restore", strings.NewReader(body)))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestRestoreSkipsGrantsAcrossOrganizations(t *testing.T) {
+	store := openSQLiteStore(t)
+	ctx := context.Background()
+	const orgID = "00000000-0000-0000-0000-000000000001"
+
+	require.NoError(t, store.CreateOrganization(ctx, &models.Organization{ID: "org-2", Name: "Acme", Slug: "acme"}))
+	require.NoError(t, store.CreateService(ctx, &models.Service{ID: "svc-1", OrgID: orgID, Name: "Payments", Slug: "payments", Visibility: models.VisibilityPrivate}))
+	require.NoError(t, store.CreateUser(ctx, &models.User{ID: "user-2", OrgID: "org-2", Email: "eve@acme.test", Name: "Eve"}, ""))
+	require.NoError(t, store.CreateTeam(ctx, &models.Team{ID: "team-2", OrgID: "org-2", Name: "Platform"}))
+
+	// A backup edited to grant another organization's user access, or to make
+	// its team a consumer, restores neither
+	body := `{"type":"service_acl","service_acl":{"id":"acl-1","service_id":"svc-1","subject_type":"user","subject_id":"user-2","permission":"write"}}
+{"type":"consumer","consumer":{"id":"con-1","service_id":"svc-1","team_id":"team-2","version_id":null}}
+`
+	w := httptest.NewRecorder()
+	backupRouter(store).ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/admin/restore", strings.NewReader(body)))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var result models.RestoreResult
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+	assert.Equal(t, models.RestoreResult{Skipped: 2}, result)
+
+	acls, err := store.GetServiceACLs(ctx, orgID, "svc-1")
+	require.NoError(t, err)
+	assert.Empty(t, acls)
+}
@@ -0,0 +1,97 @@
+package unit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yashjain/konnect/internal/auth"
+	"github.com/yashjain/konnect/internal/handlers"
+	"github.com/yashjain/konnect/internal/middleware"
+	"github.com/yashjain/konnect/internal/models"
+)
+
+func TestDeprecationCalendar(t *testing.T) {
+	captureLogs(t)
+	store := openSQLiteStore(t)
+	ctx := context.Background()
+	const orgID = "00000000-0000-0000-0000-000000000001"
+	const serviceID = "6f1c2f4e-0000-4000-8000-000000000003"
+	const v1 = "7a2d3e5f-0000-4000-8000-000000000001"
+
+	// Alice subscribes to Notifications, whose 1.0.0 Mobile consumes, and to a private service she cannot read
+	require.NoError(t, store.CreateUser(ctx, &models.User{ID: "user-alice", OrgID: orgID, Email: "alice@example.com", Name: "alice"}, ""))
+	require.NoError(t, store.CreateTeam(ctx, &models.Team{ID: "team-mobile", OrgID: orgID, Name: "mobile"}))
+	version := v1
+	require.NoError(t, store.CreateConsumer(ctx, &models.Consumer{ID: "consumer-mobile", OrgID: orgID, ServiceID: serviceID, TeamID: "team-mobile", VersionID: &version}))
+	require.NoError(t, store.SubscribeToService(ctx, orgID, serviceID, "user-alice"))
+	require.NoError(t, store.CreateService(ctx, &models.Service{ID: "svc-private", OrgID: orgID, Name: "Ledger", Slug: "ledger", Visibility: models.VisibilityPrivate}))
+	require.NoError(t, store.CreateVersion(ctx, orgID, &models.Version{ID: "ver-ledger", ServiceID: "svc-private", Semver: "2.0.0", Status: models.VersionReleased}))
+	require.NoError(t, store.SubscribeToService(ctx, orgID, "svc-private", "user-alice"))
+
+	deprecatedAt := time.Date(time.Now().Year()+1, time.March, 1, 12, 0, 0, 0, time.UTC)
+	sunsetAt := deprecatedAt.AddDate(0, 6, 0)
+	for _, id := range []string{v1, "ver-ledger"} {
+		service := serviceID
+		if id == "ver-ledger" {
+			service = "svc-private"
+		}
+		_, err := store.ScheduleDeprecation(ctx, orgID, service, id, deprecatedAt, &sunsetAt)
+		require.NoError(t, err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	get := func(p auth.Principal, query string) *httptest.ResponseRecorder {
+		router := gin.New()
+		router.Use(func(c *gin.Context) { middleware.SetPrincipal(c, p) })
+		router.GET("/api/v1/calendar.ics", handlers.GetDeprecationCalendar(store, store, store))
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/api/v1/calendar.ics"+query, nil)
+		req.Host = "catalog.example.com"
+		router.ServeHTTP(w, req)
+		return w
+	}
+	alice := auth.Principal{OrgID: orgID, UserID: "user-alice"}
+	admin := auth.Principal{OrgID: orgID}
+
+	// A user's calendar lists the deadlines of the services they subscribe to and can read
+	w := get(alice, "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "text/calendar; charset=utf-8", w.Header().Get("Content-Type"))
+	body := w.Body.String()
+	assert.True(t, strings.HasPrefix(body, "BEGIN:VCALENDAR\r\nVERSION:2.0\r\n"))
+	assert.True(t, strings.HasSuffix(body, "END:VCALENDAR\r\n"))
+	assert.Equal(t, 2, strings.Count(body, "BEGIN:VEVENT"))
+	assert.Contains(t, body, "SUMMARY:Notifications 1.0.0 deprecated\r\n")
+	assert.Contains(t, body, "DTSTART;VALUE=DATE:"+deprecatedAt.Format("20060102")+"\r\n")
+	assert.Contains(t, body, "SUMMARY:Notifications 1.0.0 sunset\r\n")
+	assert.Contains(t, body, "DTSTART;VALUE=DATE:"+sunsetAt.Format("20060102")+"\r\n")
+	assert.Contains(t, body, "UID:"+v1+"-sunset@konnect\r\n")
+	assert.NotContains(t, body, "Ledger")
+	for _, line := range strings.Split(strings.TrimSuffix(body, "\r\n"), "\r\n") {
+		assert.LessOrEqual(t, len(line), 75, line)
+	}
+
+	// Team and service calendars
+	w = get(admin, "?team=team-mobile")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 2, strings.Count(w.Body.String(), "BEGIN:VEVENT"))
+	w = get(admin, "?team=team-web")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "BEGIN:VEVENT")
+	w = get(admin, "?service=svc-private")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "SUMMARY:Ledger 2.0.0 deprecated\r\n")
+	assert.Contains(t, w.Body.String(), "X-WR-CALNAME:Ledger deadlines\r\n")
+
+	assert.Equal(t, http.StatusBadRequest, get(admin, "").Code)
+	assert.Equal(t, http.StatusBadRequest, get(admin, "?team=team-mobile&service="+serviceID).Code)
+	assert.Equal(t, http.StatusNotFound, get(admin, "?service=svc-missing").Code)
+}
@@ -0,0 +1,81 @@
+package unit
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yashjain/konnect/internal/handlers"
+	"github.com/yashjain/konnect/internal/middleware"
+)
+
+func TestBodyCapture(t *testing.T) {
+	buf := captureLogs(t)
+
+	gin.SetMode(gin.TestMode)
+	capture := middleware.NewBodyCapture(64, time.Hour)
+	router := gin.New()
+	router.Use(middleware.RequestID(), capture.Handler())
+	router.POST("/login", func(c *gin.Context) {
+		body, _ := io.ReadAll(c.Request.Body)
+		c.JSON(http.StatusOK, gin.H{"received": len(body), "access_token": "secret-token"})
+	})
+	router.POST("/admin/debug/capture", handlers.EnableDebugCapture(capture))
+	post := func(path, id, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Request-ID", id)
+		router.ServeHTTP(w, req)
+		return w
+	}
+	login := `{"email":"alice@example.com","password":"hunter2"}`
+
+	// Nothing is captured without a rule
+	post("/login", "req-1", login)
+	assert.Empty(t, buf.String())
+
+	// Invalid rules are rejected
+	assert.Equal(t, http.StatusBadRequest, post("/admin/debug/capture", "", `{"ttl":"5m"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, post("/admin/debug/capture", "", `{"route":"POST /login","request_id":"req-2"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, post("/admin/debug/capture", "", `{"route":"POST /login","ttl":"2h"}`).Code)
+
+	// Bodies of requests with a captured ID are logged redacted, and still reach the handler
+	assert.Equal(t, http.StatusCreated, post("/admin/debug/capture", "", `{"request_id":"req-2","ttl":"5m"}`).Code)
+	buf.Reset()
+	w := post("/login", "req-2", login)
+	assert.Contains(t, w.Body.String(), fmt.Sprintf(`"received":%d`, len(login)))
+	records := logRecords(t, buf)
+	require.Len(t, records, 1)
+	assert.Equal(t, "Request captured", records[0]["msg"])
+	assert.Equal(t, "req-2", records[0]["request_id"])
+	assert.Contains(t, records[0]["request_body"], "alice@example.com")
+	assert.NotContains(t, buf.String(), "hunter2")
+	assert.NotContains(t, buf.String(), "secret-token")
+	assert.Contains(t, records[0]["response_body"], fmt.Sprintf(`"received":%d`, len(login)))
+
+	// Other requests are not captured, and bodies beyond the limit are left out
+	buf.Reset()
+	post("/login", "req-3", login)
+	assert.Empty(t, buf.String())
+	post("/login", "req-2", `{"description":"`+strings.Repeat("x", 100)+`"}`)
+	records = logRecords(t, buf)
+	require.Len(t, records, 1)
+	assert.Equal(t, "[TOO LARGE]", records[0]["request_body"])
+
+	// Rules expire, and can be removed
+	rule, err := capture.Enable("POST /login", "", time.Millisecond)
+	require.NoError(t, err)
+	time.Sleep(2 * time.Millisecond)
+	assert.NotContains(t, capture.Rules(), rule)
+	capture.Disable()
+	assert.Empty(t, capture.Rules())
+}
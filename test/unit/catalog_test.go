@@ -0,0 +1,168 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+
+	"github.com/yashjain/konnect/internal/auth"
+	"github.com/yashjain/konnect/internal/handlers"
+	"github.com/yashjain/konnect/internal/middleware"
+	"github.com/yashjain/konnect/internal/models"
+	"github.com/yashjain/konnect/internal/repository"
+)
+
+// setupCatalogRouter serves the catalog routes backed by repo for requests made by principal
+func setupCatalogRouter(repo repository.Repository, principal auth.Principal) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		middleware.SetPrincipal(c, principal)
+	})
+	router.GET("/export", handlers.ExportCatalog(repo, repo))
+	router.POST("/import", handlers.ImportCatalog(repo, repo, repo, nil))
+	return router
+}
+
+func TestCatalogExportImport(t *testing.T) {
+	captureLogs(t)
+	store := openSQLiteStore(t)
+	ctx := context.Background()
+	const orgID = "00000000-0000-0000-0000-000000000001"
+	const otherOrgID = "00000000-0000-0000-0000-000000000002"
+	require.NoError(t, store.CreateOrganization(ctx, &models.Organization{ID: otherOrgID, Name: "Other", Slug: "other"}))
+
+	source := setupCatalogRouter(store, auth.Principal{OrgID: orgID})
+	target := setupCatalogRouter(store, auth.Principal{OrgID: otherOrgID})
+	do := func(router *gin.Engine, method, path, contentType, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Services and versions are exported oldest first
+	w := do(source, "GET", "/export", "", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	exported := w.Body.String()
+	catalog := decodeCatalog(t, w)
+	require.Len(t, catalog.Services, 3)
+	notifications := catalog.Services[2]
+	assert.Equal(t, "notifications", notifications.Slug)
+	require.Len(t, notifications.Versions, 2)
+	assert.Equal(t, "1.0.0", notifications.Versions[0].Semver)
+	assert.Equal(t, "1.1.0", notifications.Versions[1].Semver)
+
+	w = do(source, "GET", "/export?format=yaml", "", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var fromYAML models.Catalog
+	require.NoError(t, yaml.Unmarshal(w.Body.Bytes(), &fromYAML))
+	assert.Equal(t, catalog, fromYAML)
+	assert.Equal(t, http.StatusBadRequest, do(source, "GET", "/export?format=xml", "", "").Code)
+
+	// A dry run changes nothing
+	w = do(target, "POST", "/import?dry_run=true", "application/json", exported)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	report := decodeReport(t, w)
+	assert.True(t, report.DryRun)
+	assert.Equal(t, 6, report.Created)
+	w = do(target, "GET", "/export", "", "")
+	assert.JSONEq(t, `{"services":[]}`, w.Body.String())
+
+	// Importing the export into another organization recreates the catalog
+	w = do(target, "POST", "/import", "application/json", exported)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	report = decodeReport(t, w)
+	assert.Equal(t, 6, report.Created)
+	assert.Equal(t, 0, report.Failed)
+	for _, item := range report.Items {
+		assert.NotEmpty(t, item.ID)
+	}
+	assert.Equal(t, sortedCatalog(catalog), sortedCatalog(decodeCatalog(t, do(target, "GET", "/export", "", ""))))
+
+	// Skipping keeps existing services and versions and adds the new ones
+	changed := `
+services:
+  - name: Notifications
+    slug: notifications
+    description: Push, email and SMS
+    versions:
+      - {semver: 1.1.0, status: deprecated}
+      - {semver: 2.0.0, status: released, changelog: SMS}
+      - {semver: 2.1.0, status: shipped}
+  - name: Collect Money
+    slug: billing
+  - name: Ledger
+    slug: "***"
+`
+	w = do(target, "POST", "/import", "application/yaml", changed)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	report = decodeReport(t, w)
+	results := map[string]string{}
+	for _, item := range report.Items {
+		results[item.Kind+" "+item.Slug+" "+item.Semver] = item.Result
+	}
+	assert.Equal(t, map[string]string{
+		"service notifications ":      models.ImportSkipped,
+		"version notifications 1.1.0": models.ImportSkipped,
+		"version notifications 2.0.0": models.ImportCreated,
+		"version notifications 2.1.0": models.ImportFailed,
+		"service billing ":            models.ImportFailed,
+		"service *** ":                models.ImportFailed,
+	}, results)
+	assert.Equal(t, models.ImportReport{OnConflict: models.ImportSkip, Created: 1, Skipped: 2, Failed: 3, Items: report.Items}, report)
+
+	// Overwriting updates them
+	w = do(target, "POST", "/import?on_conflict=overwrite", "application/yaml", changed)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, 3, decodeReport(t, w).Updated)
+	notifications = sortedCatalog(decodeCatalog(t, do(target, "GET", "/export", "", ""))).Services[2]
+	assert.Equal(t, "Push, email and SMS", notifications.Description)
+	assert.Equal(t, []models.CatalogVersion{
+		{Semver: "1.0.0", Status: "released", Changelog: "Initial release"},
+		{Semver: "1.1.0", Status: "deprecated"},
+		{Semver: "2.0.0", Status: "released", Changelog: "SMS"},
+	}, notifications.Versions)
+
+	assert.Equal(t, http.StatusBadRequest, do(target, "POST", "/import?on_conflict=merge", "application/json", exported).Code)
+	assert.Equal(t, http.StatusBadRequest, do(target, "POST", "/import", "application/json", `{"services": {}}`).Code)
+	assert.Equal(t, http.StatusUnsupportedMediaType, do(target, "POST", "/import", "text/csv", exported).Code)
+}
+
+// decodeCatalog parses the catalog of a JSON export
+func decodeCatalog(t *testing.T, w *httptest.ResponseRecorder) models.Catalog {
+	t.Helper()
+	var catalog models.Catalog
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &catalog))
+	return catalog
+}
+
+// decodeReport parses an import report
+func decodeReport(t *testing.T, w *httptest.ResponseRecorder) models.ImportReport {
+	t.Helper()
+	var report models.ImportReport
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	return report
+}
+
+// sortedCatalog orders services by slug and their versions by semver, since
+// rows imported within the same second are exported in no particular order
+func sortedCatalog(catalog models.Catalog) models.Catalog {
+	sort.Slice(catalog.Services, func(i, j int) bool { return catalog.Services[i].Slug < catalog.Services[j].Slug })
+	for _, service := range catalog.Services {
+		sort.Slice(service.Versions, func(i, j int) bool { return service.Versions[i].Semver < service.Versions[j].Semver })
+	}
+	return catalog
+}
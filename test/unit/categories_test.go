@@ -0,0 +1,185 @@
+package unit
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yashjain/konnect/internal/auth"
+	"github.com/yashjain/konnect/internal/handlers"
+	"github.com/yashjain/konnect/internal/middleware"
+	"github.com/yashjain/konnect/internal/models"
+	"github.com/yashjain/konnect/internal/repository"
+	"github.com/yashjain/konnect/pkg/types"
+)
+
+func TestSQLiteCategories(t *testing.T) {
+	store := openSQLiteStore(t)
+	ctx := context.Background()
+	const orgID = "00000000-0000-0000-0000-000000000001"
+	const otherOrgID = "00000000-0000-0000-0000-000000000002"
+	p := auth.Principal{OrgID: orgID}
+
+	create := func(id, slug string, parentID *string) {
+		t.Helper()
+		require.NoError(t, store.CreateCategory(ctx, &models.Category{ID: id, OrgID: orgID, ParentID: parentID, Name: slug, Slug: slug}))
+	}
+	parent := func(id string) *string { return &id }
+	create("cat-payments", "payments", nil)
+	create("cat-billing", "billing", parent("cat-payments"))
+	create("cat-invoicing", "invoicing", parent("cat-billing"))
+	create("cat-identity", "identity", nil)
+
+	assert.ErrorIs(t, store.CreateCategory(ctx, &models.Category{ID: "cat-dup", OrgID: orgID, Name: "Payments", Slug: "payments"}), repository.ErrConflict)
+	assert.ErrorIs(t, store.CreateCategory(ctx, &models.Category{ID: "cat-orphan", OrgID: orgID, ParentID: parent("cat-missing"), Name: "Orphan", Slug: "orphan"}), repository.ErrInvalidParent)
+	assert.ErrorIs(t, store.CreateCategory(ctx, &models.Category{ID: "cat-foreign", OrgID: otherOrgID, ParentID: parent("cat-payments"), Name: "Foreign", Slug: "foreign"}), repository.ErrInvalidParent)
+
+	categories, err := store.GetCategories(ctx, orgID)
+	require.NoError(t, err)
+	assert.Len(t, categories, 4)
+
+	// A category cannot be moved under itself or its subtree
+	_, err = store.UpdateCategory(ctx, orgID, "cat-payments", &models.Category{Name: "Payments", Slug: "payments", ParentID: parent("cat-invoicing")})
+	assert.ErrorIs(t, err, repository.ErrInvalidParent)
+	_, err = store.UpdateCategory(ctx, orgID, "cat-payments", &models.Category{Name: "Payments", Slug: "payments", ParentID: parent("cat-payments")})
+	assert.ErrorIs(t, err, repository.ErrInvalidParent)
+	n, err := store.UpdateCategory(ctx, otherOrgID, "cat-payments", &models.Category{Name: "Payments", Slug: "payments"})
+	require.NoError(t, err)
+	assert.Zero(t, n)
+
+	// Services are listed by category, including subcategories
+	require.NoError(t, store.AddServiceCategory(ctx, orgID, "6f1c2f4e-0000-4000-8000-000000000002", "cat-invoicing"))
+	require.NoError(t, store.AddServiceCategory(ctx, orgID, "6f1c2f4e-0000-4000-8000-000000000002", "cat-invoicing"))
+	require.NoError(t, store.AddServiceCategory(ctx, orgID, "6f1c2f4e-0000-4000-8000-000000000001", "cat-identity"))
+	assert.ErrorIs(t, store.AddServiceCategory(ctx, otherOrgID, "6f1c2f4e-0000-4000-8000-000000000001", "cat-identity"), sql.ErrNoRows)
+
+	list := func(category string) []string {
+		services, total, err := store.GetServices(ctx, p, types.PaginationParams{Page: 1, PageSize: 10, Category: category})
+		require.NoError(t, err)
+		assert.Equal(t, len(services), total)
+		var ids []string
+		for _, s := range services {
+			ids = append(ids, s.ID)
+		}
+		return ids
+	}
+	assert.Equal(t, []string{"6f1c2f4e-0000-4000-8000-000000000002"}, list("payments"))
+	assert.Equal(t, []string{"6f1c2f4e-0000-4000-8000-000000000002"}, list("cat-billing"))
+	assert.Equal(t, []string{"6f1c2f4e-0000-4000-8000-000000000001"}, list("identity"))
+	assert.Empty(t, list("unknown"))
+
+	// Moving a subtree moves its services with it
+	_, err = store.UpdateCategory(ctx, orgID, "cat-billing", &models.Category{Name: "Billing", Slug: "billing", ParentID: parent("cat-identity")})
+	require.NoError(t, err)
+	assert.Empty(t, list("payments"))
+	assert.Len(t, list("identity"), 2)
+
+	// Only leaves can be deleted, unassigning their services
+	_, err = store.DeleteCategory(ctx, orgID, "cat-billing")
+	assert.ErrorIs(t, err, repository.ErrInUse)
+	n, err = store.DeleteCategory(ctx, orgID, "cat-invoicing")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+	assigned, err := store.GetServiceCategories(ctx, orgID, "6f1c2f4e-0000-4000-8000-000000000002")
+	require.NoError(t, err)
+	assert.Empty(t, assigned)
+}
+
+func TestCategoryHandlers(t *testing.T) {
+	captureLogs(t)
+	store := openSQLiteStore(t)
+	const orgID = "00000000-0000-0000-0000-000000000001"
+	const serviceID = "6f1c2f4e-0000-4000-8000-000000000003"
+
+	gin.SetMode(gin.TestMode)
+	as := func(p auth.Principal) *gin.Engine {
+		router := gin.New()
+		router.Use(func(c *gin.Context) { middleware.SetPrincipal(c, p) })
+		router.GET("/categories", handlers.GetCategories(store))
+		router.POST("/categories", handlers.CreateCategory(store))
+		router.GET("/categories/:id", handlers.GetCategory(store))
+		router.PUT("/categories/:id", handlers.UpdateCategory(store))
+		router.DELETE("/categories/:id", handlers.DeleteCategory(store))
+		router.GET("/services", handlers.GetServices(store))
+		router.GET("/services/:id/categories", handlers.GetServiceCategories(store, store))
+		router.PUT("/services/:id/categories/:category_id", handlers.AddServiceCategory(store, store))
+		router.DELETE("/services/:id/categories/:category_id", handlers.RemoveServiceCategory(store, store))
+		return router
+	}
+	admin := auth.Principal{OrgID: orgID}
+	user := auth.Principal{OrgID: orgID, UserID: "user-1"}
+	do := func(p auth.Principal, method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		as(p).ServeHTTP(w, req)
+		return w
+	}
+	createCategory := func(body string) models.Category {
+		t.Helper()
+		w := do(admin, "POST", "/categories", body)
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		var category models.Category
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &category))
+		return category
+	}
+
+	payments := createCategory(`{"name": "Payments"}`)
+	assert.Equal(t, "payments", payments.Slug)
+	billing := createCategory(`{"name": "Billing & Invoices", "parent_id": "` + payments.ID + `"}`)
+	assert.Equal(t, "billing-invoices", billing.Slug)
+
+	assert.Equal(t, http.StatusForbidden, do(user, "POST", "/categories", `{"name": "Ops"}`).Code)
+	assert.Equal(t, http.StatusConflict, do(admin, "POST", "/categories", `{"name": "PAYMENTS"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, do(admin, "POST", "/categories", `{"name": "  "}`).Code)
+	assert.Equal(t, http.StatusBadRequest, do(admin, "POST", "/categories", `{"name": "Ops", "parent_id": "missing"}`).Code)
+
+	// The tree nests subcategories under their parents
+	w := do(user, "GET", "/categories", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var tree struct {
+		Data []models.Category `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &tree))
+	require.Len(t, tree.Data, 1)
+	require.Len(t, tree.Data[0].Children, 1)
+	assert.Equal(t, billing.ID, tree.Data[0].Children[0].ID)
+
+	w = do(user, "GET", "/categories/"+payments.ID, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"children":[{"id":"`+billing.ID+`"`)
+	assert.Equal(t, http.StatusNotFound, do(user, "GET", "/categories/missing", "").Code)
+
+	assert.Equal(t, http.StatusBadRequest, do(admin, "PUT", "/categories/"+payments.ID, `{"name": "Payments", "parent_id": "`+billing.ID+`"}`).Code)
+	assert.Equal(t, http.StatusNotFound, do(admin, "PUT", "/categories/missing", `{"name": "Missing"}`).Code)
+
+	// Assigning needs write access to the service
+	require.NoError(t, store.CreateService(context.Background(), &models.Service{ID: "svc-private", OrgID: orgID, Name: "Ledger", Slug: "ledger", Visibility: models.VisibilityPrivate}))
+	assert.Equal(t, http.StatusNotFound, do(user, "PUT", "/services/svc-private/categories/"+billing.ID, "").Code)
+	assert.Equal(t, http.StatusForbidden, do(user, "PUT", "/services/"+serviceID+"/categories/"+billing.ID, "").Code)
+	assert.Equal(t, http.StatusOK, do(admin, "PUT", "/services/"+serviceID+"/categories/"+billing.ID, "").Code)
+	assert.Equal(t, http.StatusNotFound, do(admin, "PUT", "/services/"+serviceID+"/categories/missing", "").Code)
+
+	w = do(user, "GET", "/services/"+serviceID+"/categories", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"slug":"billing-invoices"`)
+
+	w = do(user, "GET", "/services?category=payments", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"id":"`+serviceID+`"`)
+	assert.NotContains(t, w.Body.String(), `"slug":"locate-us"`)
+
+	assert.Equal(t, http.StatusConflict, do(admin, "DELETE", "/categories/"+payments.ID, "").Code)
+	assert.Equal(t, http.StatusOK, do(admin, "DELETE", "/services/"+serviceID+"/categories/"+billing.ID, "").Code)
+	assert.Equal(t, http.StatusNotFound, do(admin, "DELETE", "/services/"+serviceID+"/categories/"+billing.ID, "").Code)
+	assert.Equal(t, http.StatusOK, do(admin, "DELETE", "/categories/"+billing.ID, "").Code)
+	assert.Equal(t, http.StatusOK, do(admin, "DELETE", "/categories/"+payments.ID, "").Code)
+}
@@ -0,0 +1,144 @@
+package unit
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yashjain/konnect/internal/audit"
+	"github.com/yashjain/konnect/internal/middleware"
+)
+
+// channelSink delivers exported audit entries to a channel
+type channelSink chan audit.Entry
+
+func (s channelSink) Send(ctx context.Context, entries []audit.Entry) error {
+	for _, entry := range entries {
+		s <- entry
+	}
+	return nil
+}
+
+// issueCert returns a certificate for template signed by parent, self-signed
+// when parent is nil
+func issueCert(t *testing.T, template *x509.Certificate, parent *tls.Certificate) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+
+	issuer, signer := template, any(key)
+	if parent != nil {
+		issuer, signer = parent.Leaf, parent.PrivateKey
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, issuer, &key.PublicKey, signer)
+	require.NoError(t, err)
+	leaf, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+func TestClientCertRecordedOnAuditEntries(t *testing.T) {
+	buf := captureLogs(t)
+
+	ca := issueCert(t, &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, nil)
+	spiffe, _ := url.Parse("spiffe://example.com/billing")
+	client := issueCert(t, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "billing-service", Organization: []string{"Billing"}},
+		DNSNames:     []string{"billing.internal"},
+		URIs:         []*url.URL{spiffe},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, &ca)
+
+	entries := make(channelSink, 2)
+	exporter := audit.NewExporter(entries, 10, 10, 10*time.Millisecond)
+	audit.SetExporter(exporter)
+	t.Cleanup(func() { audit.SetExporter(nil) })
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go exporter.Run(ctx)
+
+	r := gin.New()
+	r.Use(middleware.ClientCert())
+	identities := make(chan middleware.ClientIdentity, 2)
+	r.POST("/action", func(c *gin.Context) {
+		if identity, ok := middleware.GetClientIdentity(c); ok {
+			identities <- identity
+		}
+		audit.Log(c.Request.Context(), "Service deleted", "service_id", "svc-1")
+		c.Status(http.StatusNoContent)
+	})
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.Leaf)
+	server := httptest.NewUnstartedServer(r)
+	server.TLS = &tls.Config{ClientCAs: pool, ClientAuth: tls.VerifyClientCertIfGiven}
+	server.StartTLS()
+	defer server.Close()
+
+	httpClient := server.Client()
+	httpClient.Transport.(*http.Transport).TLSClientConfig.Certificates = []tls.Certificate{client}
+	resp, err := httpClient.Post(server.URL+"/action", "", nil)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	var entry audit.Entry
+	select {
+	case entry = <-entries:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no entry exported")
+	}
+	require.NotNil(t, entry.ClientCertificate)
+	assert.Equal(t, audit.ClientCertificate{
+		CommonName:   "billing-service",
+		Organization: []string{"Billing"},
+		DNSNames:     []string{"billing.internal"},
+		URIs:         []string{"spiffe://example.com/billing"},
+	}, *entry.ClientCertificate)
+	assert.Equal(t, "svc-1", entry.Fields["service_id"])
+	require.Len(t, identities, 1)
+	assert.Equal(t, *entry.ClientCertificate, <-identities)
+
+	records := logRecords(t, buf)
+	require.NotEmpty(t, records)
+	assert.Equal(t, "billing-service", records[len(records)-1]["client_certificate"])
+
+	// Without a client certificate the entry names no client
+	httpClient.Transport.(*http.Transport).TLSClientConfig.Certificates = nil
+	httpClient.CloseIdleConnections()
+	resp, err = httpClient.Post(server.URL+"/action", "", nil)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	select {
+	case entry = <-entries:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no entry exported")
+	}
+	assert.Nil(t, entry.ClientCertificate)
+	assert.Empty(t, identities)
+}
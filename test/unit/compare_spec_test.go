@@ -0,0 +1,275 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yashjain/konnect/internal/apispec"
+	"github.com/yashjain/konnect/internal/app"
+	"github.com/yashjain/konnect/internal/auth"
+	"github.com/yashjain/konnect/internal/handlers"
+	"github.com/yashjain/konnect/internal/middleware"
+	"github.com/yashjain/konnect/internal/models"
+	"github.com/yashjain/konnect/pkg/utils"
+)
+
+const petsV1 = `openapi: 3.0.3
+info: {title: Pets, version: "1"}
+paths:
+  /pets:
+    get:
+      parameters:
+        - {name: limit, in: query, schema: {type: integer}}
+      responses:
+        "200":
+          description: Pets
+          content:
+            application/json:
+              schema:
+                type: array
+                items: {$ref: "#/components/schemas/Pet"}
+    post:
+      requestBody:
+        content:
+          application/json:
+            schema: {$ref: "#/components/schemas/Pet"}
+      responses:
+        "201": {description: Created}
+  /pets/{id}:
+    get:
+      parameters:
+        - {name: id, in: path, required: true, schema: {type: string}}
+      responses:
+        "200":
+          description: The pet
+          content:
+            application/json:
+              schema: {$ref: "#/components/schemas/Pet"}
+        "404": {description: Not found}
+  /owners:
+    get:
+      responses:
+        "200": {description: Owners}
+components:
+  schemas:
+    Pet:
+      type: object
+      required: [name]
+      properties:
+        name: {type: string}
+        tag: {type: string}
+        status: {type: string, enum: [available, sold]}
+        parent: {$ref: "#/components/schemas/Pet"}
+`
+
+// petsV2 renames the path parameter, removes /owners, requires limit, drops
+// tag, adds a status and an age, and removes a 404 response
+const petsV2 = `openapi: 3.0.3
+info: {title: Pets, version: "2"}
+paths:
+  /pets:
+    get:
+      parameters:
+        - {name: limit, in: query, required: true, schema: {type: integer}}
+      responses:
+        "200":
+          description: Pets
+          content:
+            application/json:
+              schema:
+                type: array
+                items: {$ref: "#/components/schemas/Pet"}
+    post:
+      requestBody:
+        content:
+          application/json:
+            schema: {$ref: "#/components/schemas/Pet"}
+      responses:
+        "201": {description: Created}
+  /pets/{petId}:
+    get:
+      parameters:
+        - {name: petId, in: path, required: true, schema: {type: string}}
+      responses:
+        "200":
+          description: The pet
+          content:
+            application/json:
+              schema: {$ref: "#/components/schemas/Pet"}
+components:
+  schemas:
+    Pet:
+      type: object
+      required: [name]
+      properties:
+        name: {type: string}
+        status: {type: string, enum: [available, sold, pending]}
+        parent: {$ref: "#/components/schemas/Pet"}
+        age: {type: integer}
+`
+
+func TestIsMajorBump(t *testing.T) {
+	tests := []struct {
+		from, to string
+		want     bool
+	}{
+		{"1.4.2", "2.0.0", true},
+		{"v1.4.2", "v2.0.0-rc.1", true},
+		{"1.4.2", "1.5.0", false},
+		{"0.3.1", "0.4.0", true},
+		{"0.3.1", "0.3.2", false},
+		{"0.9.0", "1.0.0", true},
+		{"2.0.0", "1.9.0", false},
+		{"latest", "2.0.0", false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, utils.IsMajorBump(tt.from, tt.to), tt.from+" to "+tt.to)
+	}
+}
+
+func TestCompareSpecs(t *testing.T) {
+	from, err := apispec.Parse([]byte(petsV1))
+	require.NoError(t, err)
+	to, err := apispec.Parse([]byte(petsV2))
+	require.NoError(t, err)
+
+	assert.Empty(t, apispec.Compare(from, from))
+
+	breaking := map[string]bool{}
+	other := map[string]bool{}
+	for _, change := range apispec.Compare(from, to) {
+		if change.Breaking {
+			breaking[change.Type+" "+change.Location] = true
+		} else {
+			other[change.Type+" "+change.Location] = true
+		}
+	}
+	assert.Equal(t, map[string]bool{
+		"parameter_required GET /pets parameter query.limit":                           true,
+		"property_removed GET /pets response 200 application/json body[].tag":          true,
+		"enum_value_added GET /pets response 200 application/json body[].status":       true,
+		"property_removed GET /pets/{petId} response 200 application/json body.tag":    true,
+		"enum_value_added GET /pets/{petId} response 200 application/json body.status": true,
+		"path_removed /owners": true,
+	}, breaking)
+	// Requests may send less and responses may return more
+	assert.True(t, other["property_removed POST /pets request body application/json body.tag"])
+	assert.True(t, other["property_added POST /pets request body application/json body.age"])
+	assert.True(t, other["response_removed GET /pets/{petId} response 404"])
+	assert.True(t, other["enum_value_added POST /pets request body application/json body.status"])
+
+	// Going back, requests accept less
+	for _, change := range apispec.Compare(to, from) {
+		if change.Type == apispec.ChangeEnumValueRemoved && strings.HasPrefix(change.Location, "POST") {
+			assert.True(t, change.Breaking)
+		}
+	}
+}
+
+func TestReleaseGate(t *testing.T) {
+	store := openSQLiteStore(t)
+	ctx := context.Background()
+	const orgID = "00000000-0000-0000-0000-000000000001"
+	const serviceID = "6f1c2f4e-0000-4000-8000-000000000003"
+	attach := func(versionID, content string) {
+		t.Helper()
+		require.NoError(t, store.SetVersionSpec(ctx, orgID, &models.VersionSpec{VersionID: versionID, ServiceID: serviceID, Format: apispec.FormatYAML, Content: []byte(content), Digest: strings.Repeat("0", 64)}))
+	}
+	draft := func(id, semver string) *models.Version {
+		t.Helper()
+		version := &models.Version{ID: id, ServiceID: serviceID, Semver: semver, Status: models.VersionDraft, Metadata: map[string]string{}}
+		require.NoError(t, store.CreateVersion(ctx, orgID, version))
+		return version
+	}
+	attach("7a2d3e5f-0000-4000-8000-000000000001", petsV1)
+	attach("7a2d3e5f-0000-4000-8000-000000000002", petsV1)
+
+	var gate *app.ReleaseGate
+	minor := draft("7a2d3e5f-0000-4000-8000-000000000010", "1.2.0")
+	attach(minor.ID, petsV2)
+	assert.NoError(t, gate.CheckRelease(ctx, orgID, minor))
+
+	gate = &app.ReleaseGate{Versions: store, Specs: store}
+	err := gate.CheckRelease(ctx, orgID, minor)
+	require.ErrorIs(t, err, app.ErrUndeclaredBreakingChanges)
+	assert.Contains(t, err.Error(), "from released version 1.1.0")
+
+	major := draft("7a2d3e5f-0000-4000-8000-000000000011", "2.0.0")
+	attach(major.ID, petsV2)
+	assert.NoError(t, gate.CheckRelease(ctx, orgID, major))
+	assert.NoError(t, gate.CheckRelease(ctx, orgID, draft("7a2d3e5f-0000-4000-8000-000000000012", "1.3.0")))
+
+	// Imports releasing the version fail
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) { middleware.SetPrincipal(c, auth.Principal{OrgID: orgID}) })
+	router.POST("/import", handlers.ImportCatalog(store, store, store, gate))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/import?on_conflict=overwrite", strings.NewReader(`{"services": [{"name": "Notifications", "slug": "notifications",
+		"description": "Send push/email", "versions": [{"semver": "1.2.0", "status": "released"}, {"semver": "2.0.0", "status": "released"}]}]}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var report models.ImportReport
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	require.Len(t, report.Items, 3)
+	assert.Equal(t, models.ImportFailed, report.Items[1].Result)
+	assert.Contains(t, report.Items[1].Error, "undeclared breaking changes")
+	assert.Equal(t, models.ImportUpdated, report.Items[2].Result)
+
+	version, err := store.GetVersion(ctx, orgID, serviceID, minor.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.VersionDraft, version.Status)
+}
+
+func TestCompareVersionSpecsHandler(t *testing.T) {
+	captureLogs(t)
+	store := openSQLiteStore(t)
+	const orgID = "00000000-0000-0000-0000-000000000001"
+	const serviceID = "6f1c2f4e-0000-4000-8000-000000000003"
+	const v1, v2 = "7a2d3e5f-0000-4000-8000-000000000001", "7a2d3e5f-0000-4000-8000-000000000002"
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) { middleware.SetPrincipal(c, auth.Principal{OrgID: orgID}) })
+	router.GET("/services/:id/versions/compare-spec", handlers.CompareVersionSpecs(store, store, store))
+	router.PUT("/services/:id/versions/:version_id/spec", handlers.PutVersionSpec(store, store, nil))
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/yaml")
+		router.ServeHTTP(w, req)
+		return w
+	}
+	compare := "/services/" + serviceID + "/versions/compare-spec"
+
+	require.Equal(t, http.StatusOK, do("PUT", "/services/"+serviceID+"/versions/"+v1+"/spec", petsV1).Code)
+	w := do("GET", compare+"?from=1.0.0&to="+v2, "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), "Version 1.1.0 has no OpenAPI document")
+	require.Equal(t, http.StatusOK, do("PUT", "/services/"+serviceID+"/versions/"+v2+"/spec", petsV2).Code)
+
+	w = do("GET", compare+"?from=1.0.0&to="+v2, "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var comparison models.SpecComparison
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &comparison))
+	assert.Equal(t, v1, comparison.From.ID)
+	assert.Equal(t, "1.1.0", comparison.To.Semver)
+	assert.Equal(t, 6, comparison.BreakingChanges)
+	assert.False(t, comparison.Declared)
+
+	w = do("GET", compare+"?from="+v1+"&to="+v1, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"changes":[]`)
+
+	assert.Equal(t, http.StatusBadRequest, do("GET", compare+"?from=1.0.0", "").Code)
+	assert.Equal(t, http.StatusNotFound, do("GET", compare+"?from=1.0.0&to=9.9.9", "").Code)
+}
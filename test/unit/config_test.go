@@ -0,0 +1,123 @@
+package unit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yashjain/konnect/internal/config"
+)
+
+func TestSecretSourceFileRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dsn")
+	require.NoError(t, os.WriteFile(path, []byte("user:old@tcp(db)/app\n"), 0o600))
+
+	t.Setenv("TEST_SECRET", "from-env")
+	t.Setenv("TEST_SECRET_FILE", path)
+	source := config.NewSecretSource("TEST_SECRET", "default")
+
+	value, err := source.Resolve()
+	require.NoError(t, err)
+	assert.Equal(t, "user:old@tcp(db)/app", value)
+
+	// A rotated file is picked up on the next resolve
+	require.NoError(t, os.WriteFile(path, []byte("user:new@tcp(db)/app"), 0o600))
+	value, err = source.Resolve()
+	require.NoError(t, err)
+	assert.Equal(t, "user:new@tcp(db)/app", value)
+}
+
+func TestSecretSourceFallbacks(t *testing.T) {
+	t.Setenv("TEST_SECRET", "from-env")
+	value, err := config.NewSecretSource("TEST_SECRET", "default").Resolve()
+	require.NoError(t, err)
+	assert.Equal(t, "from-env", value)
+
+	value, err = config.NewSecretSource("TEST_SECRET_UNSET", "default").Resolve()
+	require.NoError(t, err)
+	assert.Equal(t, "default", value)
+}
+
+func TestSecretSourceVault(t *testing.T) {
+	vault := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "root" || r.URL.Path != "/v1/secret/data/konnect" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		_, _ = w.Write([]byte(`{"data":{"data":{"mysql_dsn":"user:vault@tcp(db)/app"}}}`))
+	}))
+	defer vault.Close()
+
+	t.Setenv("VAULT_ADDR", vault.URL)
+	t.Setenv("VAULT_TOKEN", "root")
+	t.Setenv("TEST_SECRET_VAULT", "secret/data/konnect#mysql_dsn")
+
+	value, err := config.NewSecretSource("TEST_SECRET", "default").Resolve()
+	require.NoError(t, err)
+	assert.Equal(t, "user:vault@tcp(db)/app", value)
+}
+
+func TestDevMode(t *testing.T) {
+	t.Setenv("DEV_MODE", "true")
+	assert.True(t, config.Load().DevMode)
+
+	t.Setenv("DEV_MODE", "not-a-bool")
+	assert.False(t, config.Load().DevMode)
+}
+
+func TestPprofIsOffByDefault(t *testing.T) {
+	assert.False(t, config.Load().Pprof)
+
+	t.Setenv("PPROF_ENABLED", "true")
+	assert.True(t, config.Load().Pprof)
+}
+
+func TestConfigReload(t *testing.T) {
+	captureLogs(t)
+	path := filepath.Join(t.TempDir(), "konnect.env")
+	require.NoError(t, os.WriteFile(path, []byte("# overrides\nLOG_LEVEL=info\nMAX_IN_FLIGHT=100\n"), 0o600))
+	t.Setenv("CONFIG_FILE", path)
+	t.Setenv("MAX_IN_FLIGHT", "200")
+	t.Cleanup(func() {
+		os.Unsetenv("CONFIG_FILE")
+		config.Load()
+	})
+
+	// The file takes precedence over the environment
+	cfg := config.Load()
+	assert.Equal(t, "info", cfg.LogLevel)
+	assert.Equal(t, 100, cfg.LoadShed.MaxInFlight)
+
+	reloader := config.NewReloader()
+	var applied []config.Reloadable
+	reloader.OnReload(func(settings config.Reloadable) { applied = append(applied, settings) })
+
+	// Reloadable changes are applied; others wait for a restart
+	require.NoError(t, os.WriteFile(path, []byte("LOG_LEVEL='warn'\nACCESS_LOG_SAMPLE_RATE=0.5\nPORT=9999\n"), 0o600))
+	changes, err := reloader.Reload("test")
+	require.NoError(t, err)
+	assert.Equal(t, []config.Change{
+		{Key: "ACCESS_LOG_SAMPLE_RATE", Old: "", New: "0.5"},
+		{Key: "LOG_LEVEL", Old: "info", New: "warn"},
+		{Key: "MAX_IN_FLIGHT", Old: "100", New: "200"},
+	}, changes)
+	require.Len(t, applied, 1)
+	assert.Equal(t, "warn", applied[0].LogLevel)
+	assert.Equal(t, 0.5, applied[0].AccessLog.SampleRate)
+	assert.Equal(t, 200, applied[0].LoadShed.MaxInFlight)
+
+	// Invalid values are rejected without applying anything
+	require.NoError(t, os.WriteFile(path, []byte("LOG_LEVEL=error\nMAX_IN_FLIGHT=-1\n"), 0o600))
+	_, err = reloader.Reload("test")
+	assert.ErrorContains(t, err, "MAX_IN_FLIGHT")
+	require.NoError(t, os.WriteFile(path, []byte("LOG_LEVEL\n"), 0o600))
+	_, err = reloader.Reload("test")
+	assert.ErrorContains(t, err, "line 1")
+	assert.Len(t, applied, 1)
+	assert.Equal(t, "warn", config.Load().LogLevel)
+}
@@ -0,0 +1,114 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yashjain/konnect/internal/auth"
+	"github.com/yashjain/konnect/internal/handlers"
+	"github.com/yashjain/konnect/internal/middleware"
+	"github.com/yashjain/konnect/internal/models"
+	"github.com/yashjain/konnect/internal/outbox"
+	"github.com/yashjain/konnect/internal/repository"
+)
+
+func TestConsumers(t *testing.T) {
+	captureLogs(t)
+	t.Setenv("SMTP_HOST", "smtp.example.com")
+	store := openSQLiteStore(t)
+	ctx := context.Background()
+	const orgID = "00000000-0000-0000-0000-000000000001"
+	const serviceID = "6f1c2f4e-0000-4000-8000-000000000003"
+	const v1 = "7a2d3e5f-0000-4000-8000-000000000001"
+
+	// Alice is in Mobile, which consumes every version, and Bob in Web, which consumes 1.0.0
+	for _, name := range []string{"alice", "bob"} {
+		require.NoError(t, store.CreateUser(ctx, &models.User{ID: "user-" + name, OrgID: orgID, Email: name + "@example.com", Name: name}, ""))
+	}
+	for team, user := range map[string]string{"team-mobile": "user-alice", "team-web": "user-bob"} {
+		require.NoError(t, store.CreateTeam(ctx, &models.Team{ID: team, OrgID: orgID, Name: strings.TrimPrefix(team, "team-")}))
+		_, err := store.AddTeamMember(ctx, orgID, team, user)
+		require.NoError(t, err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	as := func(principal auth.Principal) *gin.Engine {
+		router := gin.New()
+		router.Use(func(c *gin.Context) { middleware.SetPrincipal(c, principal) })
+		router.GET("/services/:id/consumers", handlers.GetConsumers(store, store))
+		router.POST("/services/:id/consumers", handlers.CreateConsumer(store, store))
+		router.DELETE("/services/:id/consumers/:consumer_id", handlers.DeleteConsumer(store, store))
+		router.DELETE("/services/:id", handlers.DeleteService(store, store))
+		return router
+	}
+	alice := auth.Principal{OrgID: orgID, UserID: "user-alice", TeamIDs: []string{"team-mobile"}}
+	orgWide := auth.Principal{OrgID: orgID}
+	do := func(principal auth.Principal, method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		as(principal).ServeHTTP(w, req)
+		return w
+	}
+	consumers := "/services/" + serviceID + "/consumers"
+
+	// Users register their own teams only
+	assert.Equal(t, http.StatusForbidden, do(alice, "POST", consumers, `{"team_id": "team-web"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, do(alice, "POST", consumers, `{}`).Code)
+	w := do(alice, "POST", consumers, `{"team_id": "team-mobile"}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var mobile models.Consumer
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &mobile))
+	assert.Equal(t, "mobile", mobile.TeamName)
+	assert.Nil(t, mobile.VersionID)
+	assert.Equal(t, http.StatusConflict, do(alice, "POST", consumers, `{"team_id": "team-mobile"}`).Code)
+
+	assert.Equal(t, http.StatusNotFound, do(orgWide, "POST", consumers, `{"team_id": "team-missing"}`).Code)
+	assert.Equal(t, http.StatusNotFound, do(orgWide, "POST", consumers, `{"team_id": "team-web", "version_id": "ver-missing"}`).Code)
+	w = do(orgWide, "POST", consumers, `{"team_id": "team-web", "version_id": "`+v1+`"}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var web models.Consumer
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &web))
+
+	list, err := store.GetConsumers(ctx, orgID, serviceID)
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	assert.Equal(t, []string{"mobile", "web"}, []string{list[0].TeamName, list[1].TeamName})
+	require.NotNil(t, list[1].VersionID)
+	assert.Equal(t, v1, *list[1].VersionID)
+	assert.Contains(t, do(alice, "GET", consumers, "").Body.String(), `"team_id":"team-web"`)
+
+	// Deprecating 1.0.0 emails both teams; a version only Mobile consumes emails Alice alone
+	mailer := &fakeMailer{}
+	notifier := outbox.NewEmailNotifier(store, store, store, mailer, "", time.Second, outbox.RetryPolicy{MaxAttempts: 3})
+	_, err = store.UpdateVersion(ctx, orgID, serviceID, v1, &models.Version{Status: models.VersionDeprecated})
+	require.NoError(t, err)
+	require.NoError(t, store.CreateVersion(ctx, orgID, &models.Version{ID: "ver-old", ServiceID: serviceID, Semver: "0.9.0", Status: models.VersionDeprecated}))
+	sent, err := notifier.Flush(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 3, sent)
+	assert.ElementsMatch(t, []string{"alice@example.com", "bob@example.com", "alice@example.com"}, mailer.recipients())
+
+	// The service cannot be deleted until its consumers unregister
+	w = do(orgWide, "DELETE", "/services/"+serviceID, "")
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), "consumers")
+	_, err = store.DeleteService(ctx, orgID, serviceID)
+	assert.ErrorIs(t, err, repository.ErrInUse)
+
+	assert.Equal(t, http.StatusForbidden, do(alice, "DELETE", consumers+"/"+web.ID, "").Code)
+	assert.Equal(t, http.StatusOK, do(alice, "DELETE", consumers+"/"+mobile.ID, "").Code)
+	assert.Equal(t, http.StatusNotFound, do(alice, "DELETE", consumers+"/"+mobile.ID, "").Code)
+
+	require.Equal(t, http.StatusOK, do(orgWide, "DELETE", consumers+"/"+web.ID, "").Code)
+	assert.Equal(t, http.StatusOK, do(orgWide, "DELETE", "/services/"+serviceID, "").Code)
+}
@@ -0,0 +1,135 @@
+package unit
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+
+	"github.com/yashjain/konnect/internal/auth"
+	"github.com/yashjain/konnect/internal/handlers"
+	"github.com/yashjain/konnect/internal/middleware"
+	"github.com/yashjain/konnect/internal/models"
+	"github.com/yashjain/konnect/pkg/types"
+)
+
+func TestDeclarativeConfig(t *testing.T) {
+	captureLogs(t)
+	store := openSQLiteStore(t)
+	ctx := context.Background()
+	const orgID = "00000000-0000-0000-0000-000000000001"
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		middleware.SetPrincipal(c, auth.Principal{OrgID: orgID})
+	})
+	router.GET("/config/dump", handlers.DumpConfig(store, store))
+	router.POST("/config/apply", handlers.ApplyConfig(store, store, store, nil))
+
+	dump := func() models.Catalog {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/config/dump", nil)
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var config models.Catalog
+		require.NoError(t, yaml.Unmarshal(w.Body.Bytes(), &config))
+		return config
+	}
+	apply := func(path string, config models.Catalog) (*httptest.ResponseRecorder, models.ConfigReport) {
+		body, err := yaml.Marshal(config)
+		require.NoError(t, err)
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, path, strings.NewReader(string(body)))
+		req.Header.Set("Content-Type", "application/yaml")
+		router.ServeHTTP(w, req)
+		var report models.ConfigReport
+		_ = json.Unmarshal(w.Body.Bytes(), &report)
+		return w, report
+	}
+
+	// A dump applied as is changes nothing
+	config := dump()
+	require.Len(t, config.Services, 3)
+	w, report := apply("/config/apply", config)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Empty(t, report.Changes)
+	assert.Equal(t, 6, report.Unchanged)
+
+	// Edit the dump: update a service and a version, drop a service and add one
+	var desired models.Catalog
+	for _, service := range config.Services {
+		switch service.Slug {
+		case "collect-money":
+			continue
+		case "locate-us":
+			service.Description = "Find our offices"
+		case "notifications":
+			service.Versions[1].Changelog = "Batching"
+		}
+		desired.Services = append(desired.Services, service)
+	}
+	desired.Services = append(desired.Services, models.CatalogService{
+		Name: "Payments", Slug: "payments", Tags: []string{"billing"},
+		Versions: []models.CatalogVersion{{Semver: "1.0.0", Status: models.VersionReleased}},
+	})
+
+	// A dry run previews the diff without applying it
+	w, report = apply("/config/apply?dry_run=true", desired)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.True(t, report.DryRun)
+	assert.Equal(t, 2, report.Created)
+	assert.Equal(t, 2, report.Updated)
+	assert.Equal(t, 1, report.Deleted)
+	assert.Zero(t, report.Failed)
+	require.Len(t, report.Changes, 5)
+	assert.Equal(t, models.ConfigChange{Kind: models.ImportItemService, Slug: "collect-money", Action: models.ConfigDelete, ID: "6f1c2f4e-0000-4000-8000-000000000002"}, report.Changes[0])
+	assert.Equal(t, []models.FieldDiff{{Field: "changelog", From: "Minor improvements", To: "Batching"}}, report.Changes[2].Diff)
+	_, err := store.GetServiceBySlug(ctx, orgID, "payments", types.ReadOptions{})
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+
+	// Applying makes the catalog match the config, and applying it again changes nothing
+	w, report = apply("/config/apply", desired)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.False(t, report.DryRun)
+	assert.Len(t, report.Changes, 5)
+	assert.NotEmpty(t, report.Changes[3].ID)
+	applied := dump()
+	slugs := []string{}
+	for _, service := range applied.Services {
+		slugs = append(slugs, service.Slug)
+	}
+	assert.ElementsMatch(t, []string{"locate-us", "notifications", "payments"}, slugs)
+	_, err = store.GetServiceBySlug(ctx, orgID, "collect-money", types.ReadOptions{})
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+	w, report = apply("/config/apply", applied)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, report.Changes)
+
+	// A config that cannot be applied in full is not applied at all
+	for i := range applied.Services {
+		switch applied.Services[i].Slug {
+		case "locate-us":
+			applied.Services[i].Description = "Find us"
+		case "notifications":
+			applied.Services[i].Versions = applied.Services[i].Versions[:1]
+		}
+	}
+	w, report = apply("/config/apply", applied)
+	require.Equal(t, http.StatusUnprocessableEntity, w.Code, w.Body.String())
+	assert.Equal(t, 1, report.Failed)
+	locate, err := store.GetServiceBySlug(ctx, orgID, "locate-us", types.ReadOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "Find our offices", locate.Description)
+
+	w, _ = apply("/config/apply?dry_run=maybe", applied)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
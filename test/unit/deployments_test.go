@@ -0,0 +1,139 @@
+package unit
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yashjain/konnect/internal/auth"
+	"github.com/yashjain/konnect/internal/handlers"
+	"github.com/yashjain/konnect/internal/middleware"
+	"github.com/yashjain/konnect/internal/models"
+	"github.com/yashjain/konnect/pkg/types"
+	"github.com/yashjain/konnect/pkg/utils"
+)
+
+func TestNormalizeEnvironment(t *testing.T) {
+	environment, err := utils.NormalizeEnvironment("Prod EU")
+	require.NoError(t, err)
+	assert.Equal(t, "prod-eu", environment)
+
+	for _, invalid := range []string{"", "---", strings.Repeat("a", utils.MaxEnvironmentLength+1)} {
+		_, err := utils.NormalizeEnvironment(invalid)
+		assert.ErrorIs(t, err, utils.ErrInvalidEnvironment, invalid)
+	}
+}
+
+func TestSQLiteDeployments(t *testing.T) {
+	store := openSQLiteStore(t)
+	ctx := context.Background()
+	const orgID = "00000000-0000-0000-0000-000000000001"
+	const otherOrgID = "00000000-0000-0000-0000-000000000002"
+	const serviceID = "6f1c2f4e-0000-4000-8000-000000000003"
+	const v1, v2 = "7a2d3e5f-0000-4000-8000-000000000001", "7a2d3e5f-0000-4000-8000-000000000002"
+	p := auth.Principal{OrgID: orgID}
+
+	deploy := func(id, versionID, environment string) {
+		t.Helper()
+		require.NoError(t, store.CreateDeployment(ctx, &models.Deployment{ID: id, OrgID: orgID, ServiceID: serviceID, VersionID: versionID, Environment: environment}))
+	}
+	environments := func(versionID string) []string {
+		t.Helper()
+		version, err := store.GetVersion(ctx, orgID, serviceID, versionID)
+		require.NoError(t, err)
+		return version.Environments
+	}
+
+	assert.Empty(t, environments(v1))
+	deploy("dep-1", v1, "staging")
+	deploy("dep-2", v1, "prod")
+	assert.Equal(t, []string{"prod", "staging"}, environments(v1))
+
+	// Promoting a version to prod takes prod off the previous one
+	deploy("dep-3", v2, "prod")
+	assert.Equal(t, []string{"staging"}, environments(v1))
+	assert.Equal(t, []string{"prod"}, environments(v2))
+
+	assert.ErrorIs(t, store.CreateDeployment(ctx, &models.Deployment{ID: "dep-4", OrgID: otherOrgID, ServiceID: serviceID, VersionID: v1, Environment: "prod"}), sql.ErrNoRows)
+	assert.ErrorIs(t, store.CreateDeployment(ctx, &models.Deployment{ID: "dep-5", OrgID: orgID, ServiceID: "6f1c2f4e-0000-4000-8000-000000000001", VersionID: v1, Environment: "prod"}), sql.ErrNoRows)
+
+	deployments, err := store.GetDeployments(ctx, orgID, serviceID, v1, 1)
+	require.NoError(t, err)
+	require.Len(t, deployments, 1)
+	assert.Equal(t, v1, deployments[0].VersionID)
+	deployments, err = store.GetDeployments(ctx, orgID, serviceID, v1, 10)
+	require.NoError(t, err)
+	assert.Len(t, deployments, 2)
+	_, err = store.GetDeployments(ctx, otherOrgID, serviceID, v1, 10)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+
+	versions, total, err := store.GetVersions(ctx, orgID, serviceID, types.PaginationParams{Page: 1, PageSize: 10, DeployedIn: "prod"})
+	require.NoError(t, err)
+	assert.Equal(t, 1, total)
+	require.Len(t, versions, 1)
+	assert.Equal(t, v2, versions[0].ID)
+
+	services, total, err := store.GetServices(ctx, p, types.PaginationParams{Page: 1, PageSize: 10, DeployedIn: "staging"})
+	require.NoError(t, err)
+	assert.Equal(t, 1, total)
+	require.Len(t, services, 1)
+	assert.Equal(t, serviceID, services[0].ID)
+	_, total, err = store.GetServices(ctx, p, types.PaginationParams{Page: 1, PageSize: 10, DeployedIn: "dev"})
+	require.NoError(t, err)
+	assert.Zero(t, total)
+}
+
+func TestDeploymentHandlers(t *testing.T) {
+	captureLogs(t)
+	store := openSQLiteStore(t)
+	const orgID = "00000000-0000-0000-0000-000000000001"
+	const serviceID = "6f1c2f4e-0000-4000-8000-000000000003"
+	const versionID = "7a2d3e5f-0000-4000-8000-000000000002"
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) { middleware.SetPrincipal(c, auth.Principal{OrgID: orgID}) })
+	router.GET("/services", handlers.GetServices(store))
+	router.GET("/services/:id/versions", handlers.GetVersions(store, store))
+	router.GET("/services/:id/versions/:version_id/deployments", handlers.GetDeployments(store, store))
+	router.POST("/services/:id/versions/:version_id/deployments", handlers.CreateDeployment(store, store))
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		return w
+	}
+	deployments := "/services/" + serviceID + "/versions/" + versionID + "/deployments"
+
+	w := do("POST", deployments, `{"environment": "Prod EU"}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"environment":"prod-eu"`)
+	assert.Equal(t, http.StatusBadRequest, do("POST", deployments, `{"environment": "---"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, do("POST", deployments, `{}`).Code)
+	assert.Equal(t, http.StatusNotFound, do("POST", "/services/"+serviceID+"/versions/missing/deployments", `{"environment": "prod"}`).Code)
+
+	w = do("GET", deployments, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"environment":"prod-eu"`)
+	assert.Equal(t, http.StatusBadRequest, do("GET", deployments+"?limit=0", "").Code)
+	assert.Equal(t, http.StatusNotFound, do("GET", "/services/"+serviceID+"/versions/missing/deployments", "").Code)
+
+	w = do("GET", "/services/"+serviceID+"/versions?deployed_in=prod-eu", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"environments":["prod-eu"]`)
+	assert.Equal(t, 1, strings.Count(w.Body.String(), `"semver"`))
+
+	w = do("GET", "/services?deployed_in=PROD%20EU", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"id":"`+serviceID+`"`)
+	assert.NotContains(t, w.Body.String(), `"slug":"locate-us"`)
+	assert.Equal(t, http.StatusBadRequest, do("GET", "/services?deployed_in=---", "").Code)
+}
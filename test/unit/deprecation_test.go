@@ -0,0 +1,227 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yashjain/konnect/internal/auth"
+	"github.com/yashjain/konnect/internal/handlers"
+	"github.com/yashjain/konnect/internal/middleware"
+	"github.com/yashjain/konnect/internal/models"
+	"github.com/yashjain/konnect/internal/outbox"
+)
+
+func TestDeprecationSchedule(t *testing.T) {
+	captureLogs(t)
+	t.Setenv("SMTP_HOST", "smtp.example.com")
+	store := openSQLiteStore(t)
+	ctx := context.Background()
+	const orgID = "00000000-0000-0000-0000-000000000001"
+	const serviceID = "6f1c2f4e-0000-4000-8000-000000000003"
+	const v1 = "7a2d3e5f-0000-4000-8000-000000000001"
+	const v2 = "7a2d3e5f-0000-4000-8000-000000000002"
+
+	require.NoError(t, store.CreateUser(ctx, &models.User{ID: "user-alice", OrgID: orgID, Email: "alice@example.com", Name: "alice"}, ""))
+	require.NoError(t, store.CreateTeam(ctx, &models.Team{ID: "team-mobile", OrgID: orgID, Name: "mobile"}))
+	_, err := store.AddTeamMember(ctx, orgID, "team-mobile", "user-alice")
+	require.NoError(t, err)
+	require.NoError(t, store.CreateConsumer(ctx, &models.Consumer{ID: "consumer-mobile", OrgID: orgID, ServiceID: serviceID, TeamID: "team-mobile"}))
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) { middleware.SetPrincipal(c, auth.Principal{OrgID: orgID}) })
+	router.PUT("/services/:id/versions/:version_id/deprecation", handlers.ScheduleDeprecation(store, store))
+	router.DELETE("/services/:id/versions/:version_id/deprecation", handlers.CancelDeprecation(store, store))
+	do := func(method, versionID, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, "/services/"+serviceID+"/versions/"+versionID+"/deprecation", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		return w
+	}
+	mailer := &fakeMailer{}
+	notifier := outbox.NewEmailNotifier(store, store, store, mailer, "", time.Second, outbox.RetryPolicy{MaxAttempts: 3})
+
+	// A future deprecation only sets the dates
+	deprecatedAt := time.Now().UTC().Add(time.Hour).Truncate(time.Second)
+	sunsetAt := deprecatedAt.Add(30 * 24 * time.Hour)
+	body := `{"deprecated_at": "` + deprecatedAt.Format(time.RFC3339) + `", "sunset_at": "` + sunsetAt.Format(time.RFC3339) + `"}`
+	assert.Equal(t, http.StatusBadRequest, do("PUT", v2, `{"deprecated_at": "`+deprecatedAt.Format(time.RFC3339)+`", "sunset_at": "`+deprecatedAt.Format(time.RFC3339)+`"}`).Code)
+	assert.Equal(t, http.StatusNotFound, do("PUT", "ver-missing", body).Code)
+	w := do("PUT", v2, body)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var version models.Version
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &version))
+	assert.Equal(t, models.VersionReleased, version.Status)
+	require.NotNil(t, version.DeprecatedAt)
+	require.NotNil(t, version.SunsetAt)
+	assert.True(t, deprecatedAt.Equal(*version.DeprecatedAt))
+	assert.True(t, sunsetAt.Equal(*version.SunsetAt))
+
+	scheduler := outbox.NewDeprecationScheduler(store, time.Minute, 0)
+	n, err := scheduler.Flush(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+
+	// A scheduled deprecation can be cancelled until it happens
+	assert.Equal(t, http.StatusOK, do("DELETE", v2, "").Code)
+	assert.Equal(t, http.StatusNotFound, do("DELETE", v2, "").Code)
+	got, err := store.GetVersion(ctx, orgID, serviceID, v2)
+	require.NoError(t, err)
+	assert.Nil(t, got.DeprecatedAt)
+	assert.Nil(t, got.SunsetAt)
+
+	// Once due, the scheduler deprecates the version and emails its consumers about the sunset
+	require.Equal(t, http.StatusOK, do("PUT", v2, body).Code)
+	n, err = store.ApplyDueDeprecations(ctx, deprecatedAt.Add(time.Minute), 10)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	n, err = store.ApplyDueDeprecations(ctx, deprecatedAt.Add(time.Minute), 10)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+	got, err = store.GetVersion(ctx, orgID, serviceID, v2)
+	require.NoError(t, err)
+	assert.Equal(t, models.VersionDeprecated, got.Status)
+	require.NotNil(t, got.DeprecatedAt)
+	assert.True(t, deprecatedAt.Equal(*got.DeprecatedAt))
+	sent, err := notifier.Flush(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, sent)
+	assert.Equal(t, []string{"alice@example.com"}, mailer.recipients())
+	assert.Contains(t, mailer.sent[0].body, "sunset on "+sunsetAt.Format("January 2, 2006"))
+	assert.Equal(t, http.StatusConflict, do("DELETE", v2, "").Code)
+
+	// Without a date the version is deprecated now
+	w = do("PUT", v1, `{}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &version))
+	assert.Equal(t, models.VersionDeprecated, version.Status)
+	require.NotNil(t, version.DeprecatedAt)
+	assert.Nil(t, version.SunsetAt)
+	sent, err = notifier.Flush(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, sent)
+
+	// Undeprecating a version clears its dates, and deprecating it stamps them
+	_, err = store.UpdateVersion(ctx, orgID, serviceID, v1, &models.Version{Status: models.VersionReleased})
+	require.NoError(t, err)
+	got, err = store.GetVersion(ctx, orgID, serviceID, v1)
+	require.NoError(t, err)
+	assert.Nil(t, got.DeprecatedAt)
+	_, err = store.UpdateVersion(ctx, orgID, serviceID, v1, &models.Version{Status: models.VersionDeprecated})
+	require.NoError(t, err)
+	got, err = store.GetVersion(ctx, orgID, serviceID, v1)
+	require.NoError(t, err)
+	assert.NotNil(t, got.DeprecatedAt)
+
+	require.NoError(t, store.CreateVersion(ctx, orgID, &models.Version{ID: "ver-old", ServiceID: serviceID, Semver: "0.9.0", Status: models.VersionDeprecated}))
+	got, err = store.GetVersion(ctx, orgID, serviceID, "ver-old")
+	require.NoError(t, err)
+	assert.NotNil(t, got.DeprecatedAt)
+}
+
+func TestApplyDueDeprecationsSkipsFailures(t *testing.T) {
+	captureLogs(t)
+	store := openSQLiteStore(t)
+	ctx := context.Background()
+	const orgID = "00000000-0000-0000-0000-000000000001"
+	const serviceID = "6f1c2f4e-0000-4000-8000-000000000003"
+	const v1 = "7a2d3e5f-0000-4000-8000-000000000001"
+	const v2 = "7a2d3e5f-0000-4000-8000-000000000002"
+
+	// 1.0.0 is due first but cannot be deprecated
+	soon := time.Now().UTC().Add(time.Hour).Truncate(time.Second)
+	_, err := store.ScheduleDeprecation(ctx, orgID, serviceID, v1, soon, nil)
+	require.NoError(t, err)
+	_, err = store.ScheduleDeprecation(ctx, orgID, serviceID, v2, soon.Add(time.Minute), nil)
+	require.NoError(t, err)
+	_, err = store.DB().ExecContext(ctx, `
+		CREATE TRIGGER fail_deprecation BEFORE UPDATE OF status ON versions
+		WHEN NEW.id = '`+v1+`' BEGIN SELECT RAISE(ABORT, 'version is locked'); END`)
+	require.NoError(t, err)
+
+	// The failure does not hold up 1.1.0, and is reported once it is deprecated
+	n, err := store.ApplyDueDeprecations(ctx, soon.Add(time.Hour), 10)
+	assert.ErrorContains(t, err, "version is locked")
+	assert.Equal(t, 1, n)
+	got, err := store.GetVersion(ctx, orgID, serviceID, v2)
+	require.NoError(t, err)
+	assert.Equal(t, models.VersionDeprecated, got.Status)
+	got, err = store.GetVersion(ctx, orgID, serviceID, v1)
+	require.NoError(t, err)
+	assert.Equal(t, models.VersionReleased, got.Status)
+}
+
+func TestSunsetReminders(t *testing.T) {
+	captureLogs(t)
+	t.Setenv("SMTP_HOST", "smtp.example.com")
+	store := openSQLiteStore(t)
+	ctx := context.Background()
+	const orgID = "00000000-0000-0000-0000-000000000001"
+	const serviceID = "6f1c2f4e-0000-4000-8000-000000000003"
+	const v1 = "7a2d3e5f-0000-4000-8000-000000000001"
+	const v2 = "7a2d3e5f-0000-4000-8000-000000000002"
+
+	// Alice subscribes, Bob consumes through Web and Carol subscribes with deprecation emails off
+	for _, name := range []string{"alice", "bob", "carol"} {
+		require.NoError(t, store.CreateUser(ctx, &models.User{ID: "user-" + name, OrgID: orgID, Email: name + "@example.com", Name: name}, ""))
+	}
+	require.NoError(t, store.SubscribeToService(ctx, orgID, serviceID, "user-alice"))
+	require.NoError(t, store.SubscribeToService(ctx, orgID, serviceID, "user-carol"))
+	off := false
+	require.NoError(t, store.SetNotificationPreferences(ctx, &models.NotificationPreferences{OrgID: orgID, UserID: "user-carol", Deprecations: &off}))
+	require.NoError(t, store.CreateTeam(ctx, &models.Team{ID: "team-web", OrgID: orgID, Name: "web"}))
+	_, err := store.AddTeamMember(ctx, orgID, "team-web", "user-bob")
+	require.NoError(t, err)
+	require.NoError(t, store.CreateConsumer(ctx, &models.Consumer{ID: "consumer-web", OrgID: orgID, ServiceID: serviceID, TeamID: "team-web"}))
+
+	// 1.0.0 is sunset in 3 days and 1.1.0 in 30
+	now := time.Now().UTC().Truncate(time.Second)
+	at := func(d time.Duration) *time.Time { t := now.Add(d); return &t }
+	_, err = store.ScheduleDeprecation(ctx, orgID, serviceID, v1, now, at(3*24*time.Hour))
+	require.NoError(t, err)
+	_, err = store.ScheduleDeprecation(ctx, orgID, serviceID, v2, now, at(30*24*time.Hour))
+	require.NoError(t, err)
+	mailer := &fakeMailer{}
+	notifier := outbox.NewEmailNotifier(store, store, store, mailer, "", time.Second, outbox.RetryPolicy{MaxAttempts: 3})
+	_, err = notifier.Flush(ctx)
+	require.NoError(t, err)
+	mailer.sent = nil
+
+	// Only 1.0.0 is within a week of its sunset, and its recipients are reminded once
+	scheduler := outbox.NewDeprecationScheduler(store, time.Minute, 7*24*time.Hour)
+	n, err := scheduler.Remind(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	n, err = scheduler.Remind(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+	sent, err := notifier.Flush(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, sent)
+	assert.ElementsMatch(t, []string{"alice@example.com", "bob@example.com"}, mailer.recipients())
+	for _, e := range mailer.sent {
+		assert.Contains(t, e.subject, "Notifications 1.0.0 will be sunset on")
+	}
+
+	// Moving the sunset date reminds of it again
+	_, err = store.ScheduleDeprecation(ctx, orgID, serviceID, v1, now, at(5*24*time.Hour))
+	require.NoError(t, err)
+	n, err = scheduler.Remind(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	// No reminders without a lead
+	n, err = outbox.NewDeprecationScheduler(store, time.Minute, 0).Remind(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+}
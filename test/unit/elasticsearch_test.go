@@ -0,0 +1,231 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yashjain/konnect/internal/auth"
+	"github.com/yashjain/konnect/internal/models"
+	"github.com/yashjain/konnect/internal/search"
+	"github.com/yashjain/konnect/internal/search/elasticsearch"
+	"github.com/yashjain/konnect/pkg/types"
+)
+
+// fakeElasticsearch keeps the documents of one index in memory. Searches return
+// every document of the filtered organization, best score for the lowest ID.
+type fakeElasticsearch struct {
+	mu         sync.Mutex
+	created    bool
+	docs       map[string]map[string]interface{}
+	lastSearch map[string]interface{}
+}
+
+func (f *fakeElasticsearch) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var body map[string]interface{}
+	_ = json.NewDecoder(r.Body).Decode(&body)
+	path := strings.TrimPrefix(r.URL.Path, "/services")
+
+	switch {
+	case r.Method == http.MethodHead && path == "":
+		if !f.created {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	case r.Method == http.MethodPut && path == "":
+		f.created = true
+	case strings.HasPrefix(path, "/_doc/"):
+		id := strings.TrimPrefix(path, "/_doc/")
+		if r.Method == http.MethodDelete {
+			if _, ok := f.docs[id]; !ok {
+				w.WriteHeader(http.StatusNotFound)
+			}
+			delete(f.docs, id)
+			return
+		}
+		f.docs[id] = body
+	case path == "/_delete_by_query":
+		before, _ := time.Parse(time.RFC3339Nano, body["query"].(map[string]interface{})["range"].(map[string]interface{})["indexed_at"].(map[string]interface{})["lt"].(string))
+		for id, doc := range f.docs {
+			if indexedAt, _ := time.Parse(time.RFC3339Nano, doc["indexed_at"].(string)); indexedAt.Before(before) {
+				delete(f.docs, id)
+			}
+		}
+	case path == "/_search":
+		f.lastSearch = body
+		filter := body["query"].(map[string]interface{})["bool"].(map[string]interface{})["filter"].([]interface{})
+		orgID := filter[0].(map[string]interface{})["term"].(map[string]interface{})["org_id"]
+
+		var ids []string
+		for id, doc := range f.docs {
+			if doc["org_id"] == orgID {
+				ids = append(ids, id)
+			}
+		}
+		sort.Strings(ids)
+		hits := make([]map[string]interface{}, len(ids))
+		for i, id := range ids {
+			hits[i] = map[string]interface{}{"_id": id, "_score": float64(len(ids) - i)}
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"hits": map[string]interface{}{"total": map[string]interface{}{"value": len(ids)}, "hits": hits},
+		})
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func TestElasticsearchBackend(t *testing.T) {
+	fake := &fakeElasticsearch{docs: make(map[string]map[string]interface{})}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	backend := elasticsearch.New(server.URL, "services", "", "", time.Second, types.DefaultSearchWeights)
+	require.NoError(t, backend.EnsureIndex(context.Background()))
+	require.True(t, fake.created)
+
+	store := openSQLiteStore(t)
+	repo := search.NewIndexedRepository(store, backend)
+	ctx := context.Background()
+	const orgID = "00000000-0000-0000-0000-000000000001"
+	alice := auth.Principal{OrgID: orgID, UserID: "alice"}
+
+	// Writes are indexed, with the subjects granted access
+	require.NoError(t, repo.CreateService(ctx, &models.Service{ID: "es-1", OrgID: orgID, Name: "Payments", Slug: "payments", Visibility: models.VisibilityPublic}))
+	require.NoError(t, repo.CreateService(ctx, &models.Service{ID: "es-2", OrgID: orgID, Name: "Ledger", Slug: "ledger", Visibility: models.VisibilityPrivate},
+		models.ServiceACL{ID: "acl-1", SubjectType: models.SubjectUser, SubjectID: "alice", Permission: models.PermissionRead}))
+	require.NoError(t, repo.CreateService(ctx, &models.Service{ID: "es-3", OrgID: orgID, Name: "Vault", Slug: "vault", Visibility: models.VisibilityPrivate}))
+	require.Len(t, fake.docs, 3)
+	assert.Equal(t, []interface{}{"user:alice"}, fake.docs["es-2"]["subjects"])
+
+	// Hits are read back from the database in the backend's order, with its scores;
+	// services the principal cannot see are dropped even if the index returns them
+	results, total, err := repo.SearchServices(ctx, alice, types.SearchParams{Query: "payments", Mode: types.SearchModeNatural, Page: 1, PageSize: 10})
+	require.NoError(t, err)
+	assert.Equal(t, 3, total)
+	require.Len(t, results, 2)
+	assert.Equal(t, "es-1", results[0].ID)
+	assert.Equal(t, 3.0, *results[0].Score)
+	assert.Equal(t, "es-2", results[1].ID)
+
+	// The search is scoped to the principal's organization and visibility
+	filter := fake.lastSearch["query"].(map[string]interface{})["bool"].(map[string]interface{})["filter"].([]interface{})
+	require.Len(t, filter, 2)
+	assert.Contains(t, mustJSON(t, filter[1]), `"subjects":["user:alice"]`)
+
+	// Boolean mode maps to a bool query
+	minScore := 0.5
+	_, _, err = repo.SearchServices(ctx, alice, types.SearchParams{Query: `+payment -legacy "card flow"`, Mode: types.SearchModeBoolean, Page: 1, PageSize: 10, MinScore: &minScore})
+	require.NoError(t, err)
+	query := mustJSON(t, fake.lastSearch["query"])
+	assert.Contains(t, query, `"must_not":[{"multi_match":{"fields":["name^3","slug^2","description"],"query":"legacy"}}]`)
+	assert.Contains(t, query, `"type":"phrase"`)
+	assert.Equal(t, 0.5, fake.lastSearch["min_score"])
+
+	// Sorts map to the keyword subfield of names and the indexed version count,
+	// which follows new versions
+	assert.Equal(t, []interface{}{"_score", map[string]interface{}{"created_at": "desc"}}, fake.lastSearch["sort"])
+	_, _, err = repo.SearchServices(ctx, alice, types.SearchParams{Query: "payments", Sort: types.SortName, Page: 1, PageSize: 10})
+	require.NoError(t, err)
+	assert.Equal(t, `[{"name.raw":"asc"},{"_id":"asc"}]`, mustJSON(t, fake.lastSearch["sort"]))
+	// Fuzzy searches let terms match despite typos
+	_, _, err = repo.SearchServices(ctx, alice, types.SearchParams{Query: "paymnets", Fuzzy: true, Page: 1, PageSize: 10})
+	require.NoError(t, err)
+	assert.Contains(t, mustJSON(t, fake.lastSearch["query"]), `"fuzziness":"AUTO"`)
+
+	// Tag filters require every tag as its own term, or any of them as one terms filter
+	_, _, err = repo.SearchServices(ctx, alice, types.SearchParams{Query: "payments", Page: 1, PageSize: 10, Tags: types.TagFilter{Tags: []string{"core", "payments"}}})
+	require.NoError(t, err)
+	assert.Contains(t, mustJSON(t, fake.lastSearch["query"]), `{"term":{"tags":"core"}},{"term":{"tags":"payments"}}`)
+	_, _, err = repo.SearchServices(ctx, alice, types.SearchParams{Query: "payments", Page: 1, PageSize: 10, Tags: types.TagFilter{Tags: []string{"core", "payments"}, Any: true}})
+	require.NoError(t, err)
+	assert.Contains(t, mustJSON(t, fake.lastSearch["query"]), `{"terms":{"tags":["core","payments"]}}`)
+
+	require.NoError(t, repo.CreateVersion(ctx, orgID, &models.Version{ID: "es-ver-1", ServiceID: "es-1", Semver: "1.0.0", Status: models.VersionReleased}))
+	assert.Equal(t, 1.0, fake.docs["es-1"]["versions_count"])
+
+	// Deletes remove the document
+	_, err = repo.DeleteService(ctx, orgID, "es-3")
+	require.NoError(t, err)
+	assert.NotContains(t, fake.docs, "es-3")
+
+	// Reindexing restores lost documents and prunes stale ones
+	delete(fake.docs, "es-1")
+	fake.docs["stale"] = map[string]interface{}{"org_id": orgID, "indexed_at": time.Now().Add(-time.Hour).Format(time.RFC3339Nano)}
+	require.NoError(t, repo.ReindexServices(ctx))
+	assert.Contains(t, fake.docs, "es-1")
+	assert.NotContains(t, fake.docs, "stale")
+	assert.NotContains(t, fake.docs, "es-3")
+}
+
+func TestIndexedRepositoryRestoreAndArchive(t *testing.T) {
+	fake := &fakeElasticsearch{docs: make(map[string]map[string]interface{})}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	store := openSQLiteStore(t)
+	repo := search.NewIndexedRepository(store, elasticsearch.New(server.URL, "services", "", "", time.Second, types.DefaultSearchWeights))
+	ctx := context.Background()
+	const orgID = "00000000-0000-0000-0000-000000000001"
+
+	// Restored services are indexed with their restored versions; deleted ones are not
+	deletedAt := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+	records := []models.BackupRecord{
+		{Type: models.BackupService, Service: &models.Service{ID: "es-restored", OrgID: orgID, Name: "Restored", Slug: "restored", Visibility: models.VisibilityPublic}},
+		{Type: models.BackupService, Service: &models.Service{ID: "es-gone", OrgID: orgID, Name: "Gone", Slug: "gone", Visibility: models.VisibilityPublic, DeletedAt: &deletedAt}},
+		{Type: models.BackupVersion, Version: &models.Version{ID: "es-restored-1", ServiceID: "es-restored", Semver: "1.0.0", Status: models.VersionReleased}},
+	}
+	fake.docs["es-gone"] = map[string]interface{}{"org_id": orgID}
+	result, err := repo.RestoreBackup(ctx, func() (models.BackupRecord, error) {
+		if len(records) == 0 {
+			return models.BackupRecord{}, io.EOF
+		}
+		record := records[0]
+		records = records[1:]
+		return record, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, result.Services)
+	require.Contains(t, fake.docs, "es-restored")
+	assert.Equal(t, 1.0, fake.docs["es-restored"]["versions_count"])
+	assert.NotContains(t, fake.docs, "es-gone")
+
+	// Archiving reindexes the services versions were archived from, dropping
+	// services deleted behind the index's back
+	require.NoError(t, repo.CreateService(ctx, &models.Service{ID: "es-live", OrgID: orgID, Name: "Live", Slug: "live", Visibility: models.VisibilityPublic}))
+	require.NoError(t, repo.CreateService(ctx, &models.Service{ID: "es-deleted", OrgID: orgID, Name: "Deleted", Slug: "deleted", Visibility: models.VisibilityPublic}))
+	for _, id := range []string{"es-live-1", "es-live-2"} {
+		require.NoError(t, repo.CreateVersion(ctx, orgID, &models.Version{ID: id, ServiceID: "es-live", Semver: "1.0." + id[len(id)-1:], Status: models.VersionReleased}))
+	}
+	require.NoError(t, repo.CreateVersion(ctx, orgID, &models.Version{ID: "es-deleted-1", ServiceID: "es-deleted", Semver: "1.0.0", Status: models.VersionReleased}))
+	_, err = store.DB().ExecContext(ctx, "UPDATE versions SET deleted_at = ? WHERE id = ?", deletedAt, "es-live-1")
+	require.NoError(t, err)
+	_, err = store.DeleteService(ctx, orgID, "es-deleted")
+	require.NoError(t, err)
+	assert.Equal(t, 2.0, fake.docs["es-live"]["versions_count"])
+	require.Contains(t, fake.docs, "es-deleted")
+
+	archived, err := repo.ArchiveVersions(ctx, time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), archived.Versions)
+	assert.Equal(t, 1.0, fake.docs["es-live"]["versions_count"])
+	assert.NotContains(t, fake.docs, "es-deleted")
+}
+
+func mustJSON(t *testing.T, v interface{}) string {
+	encoded, err := json.Marshal(v)
+	require.NoError(t, err)
+	return string(encoded)
+}
@@ -0,0 +1,63 @@
+package unit
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yashjain/konnect/internal/database"
+)
+
+func testKey(b byte) string {
+	return base64.StdEncoding.EncodeToString([]byte(strings.Repeat(string(b), 32)))
+}
+
+func TestFieldEncryptionRoundTrip(t *testing.T) {
+	require.NoError(t, database.SetFieldEncryptionKeys("k1:"+testKey('a')))
+	defer func() { _ = database.SetFieldEncryptionKeys("") }()
+
+	stored, err := database.EncryptField("s3cret")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(stored, "enc:v1:k1:"))
+	assert.NotContains(t, stored, "s3cret")
+
+	plaintext, err := database.DecryptField(stored)
+	require.NoError(t, err)
+	assert.Equal(t, "s3cret", plaintext)
+
+	// Legacy plaintext values pass through
+	plaintext, err = database.DecryptField("not encrypted")
+	require.NoError(t, err)
+	assert.Equal(t, "not encrypted", plaintext)
+}
+
+func TestFieldEncryptionKeyRotation(t *testing.T) {
+	require.NoError(t, database.SetFieldEncryptionKeys("k1:"+testKey('a')))
+	old, err := database.EncryptField("s3cret")
+	require.NoError(t, err)
+
+	// k2 becomes active; k1 is kept for decryption
+	require.NoError(t, database.SetFieldEncryptionKeys("k2:"+testKey('b')+",k1:"+testKey('a')))
+	defer func() { _ = database.SetFieldEncryptionKeys("") }()
+
+	plaintext, err := database.DecryptField(old)
+	require.NoError(t, err)
+	assert.Equal(t, "s3cret", plaintext)
+
+	fresh, err := database.EncryptField("s3cret")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(fresh, "enc:v1:k2:"))
+
+	// Dropping k1 makes old values unreadable
+	require.NoError(t, database.SetFieldEncryptionKeys("k2:"+testKey('b')))
+	_, err = database.DecryptField(old)
+	assert.ErrorIs(t, err, database.ErrUnknownEncryptionKey)
+}
+
+func TestFieldEncryptionInvalidKey(t *testing.T) {
+	assert.Error(t, database.SetFieldEncryptionKeys("k1:"+base64.StdEncoding.EncodeToString([]byte("short"))))
+	assert.Error(t, database.SetFieldEncryptionKeys("missing-separator"))
+}
@@ -0,0 +1,121 @@
+package unit
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yashjain/konnect/internal/auth"
+	"github.com/yashjain/konnect/internal/handlers"
+	"github.com/yashjain/konnect/internal/middleware"
+	"github.com/yashjain/konnect/internal/models"
+	"github.com/yashjain/konnect/internal/repository"
+)
+
+// setupExportRouter serves the export routes backed by repo for requests made by principal
+func setupExportRouter(repo repository.Repository, principal auth.Principal) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		middleware.SetPrincipal(c, principal)
+	})
+	router.GET("/services/export", handlers.ExportServices(repo))
+	router.GET("/services/:id/versions/export", handlers.ExportVersions(repo, repo))
+	return router
+}
+
+// exportCSV requests path and parses the CSV it returns
+func exportCSV(t *testing.T, router *gin.Engine, path string) [][]string {
+	t.Helper()
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", path, nil)
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "text/csv; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Header().Get("Content-Disposition"), "attachment")
+
+	records, err := csv.NewReader(w.Body).ReadAll()
+	require.NoError(t, err)
+	return records
+}
+
+func TestExportServices(t *testing.T) {
+	captureLogs(t)
+	store := openSQLiteStore(t)
+	ctx := context.Background()
+	const orgID = "00000000-0000-0000-0000-000000000001"
+
+	// Enough services to take several pages
+	for i := 0; i < 520; i++ {
+		require.NoError(t, store.CreateService(ctx, &models.Service{
+			ID: fmt.Sprintf("6f1c2f4e-1000-4000-8000-%012d", i), OrgID: orgID,
+			Name: fmt.Sprintf("Bulk %d", i), Slug: fmt.Sprintf("bulk-%d", i), Visibility: models.VisibilityPublic,
+		}))
+	}
+	require.NoError(t, store.CreateService(ctx, &models.Service{
+		ID: "6f1c2f4e-2000-4000-8000-000000000001", OrgID: orgID,
+		Name: "=HYPERLINK(\"https://evil.example.com\")", Slug: "formula", Description: "Line one,\nline two", Visibility: models.VisibilityPublic,
+	}))
+
+	router := setupExportRouter(store, auth.Principal{OrgID: orgID})
+
+	records := exportCSV(t, router, "/services/export")
+	assert.Equal(t, []string{"id", "name", "slug", "description", "visibility", "tags", "versions_count", "created_at", "updated_at"}, records[0])
+	require.Len(t, records, 1+3+520+1)
+	seen := map[string]bool{}
+	for _, record := range records[1:] {
+		assert.False(t, seen[record[0]], "duplicate row %s", record[0])
+		seen[record[0]] = true
+	}
+
+	// Columns come in the order asked for, and formulas are neutralized
+	records = exportCSV(t, router, "/services/export?columns=slug,name,description&q=formula")
+	require.Len(t, records, 2)
+	assert.Equal(t, []string{"slug", "name", "description"}, records[0])
+	assert.Equal(t, []string{"formula", "'=HYPERLINK(\"https://evil.example.com\")", "Line one,\nline two"}, records[1])
+
+	records = exportCSV(t, router, "/services/export?columns=slug&filter=slug==locate-us")
+	assert.Equal(t, [][]string{{"slug"}, {"locate-us"}}, records)
+
+	for _, query := range []string{"format=xlsx", "columns=slug,owner", "filter=owner==me"} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/services/export?"+query, nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+}
+
+func TestExportVersions(t *testing.T) {
+	captureLogs(t)
+	store := openSQLiteStore(t)
+	const orgID = "00000000-0000-0000-0000-000000000001"
+	const serviceID = "6f1c2f4e-0000-4000-8000-000000000003"
+
+	router := setupExportRouter(store, auth.Principal{OrgID: orgID})
+
+	records := exportCSV(t, router, "/services/"+serviceID+"/versions/export?columns=semver,status")
+	assert.Equal(t, [][]string{{"semver", "status"}, {"1.1.0", "released"}, {"1.0.0", "released"}}, sortedAfterHeader(records))
+
+	records = exportCSV(t, router, "/services/"+serviceID+"/versions/export?columns=semver&filter=semver==1.0.0")
+	assert.Equal(t, [][]string{{"semver"}, {"1.0.0"}}, records)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/services/6f1c2f4e-0000-4000-8000-00000000ffff/versions/export", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+// sortedAfterHeader orders the rows after the header by their first column, descending
+func sortedAfterHeader(records [][]string) [][]string {
+	rows := records[1:]
+	sort.Slice(rows, func(i, j int) bool { return rows[i][0] > rows[j][0] })
+	return records
+}
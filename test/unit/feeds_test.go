@@ -0,0 +1,152 @@
+package unit
+
+import (
+	"context"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yashjain/konnect/internal/auth"
+	"github.com/yashjain/konnect/internal/handlers"
+	"github.com/yashjain/konnect/internal/middleware"
+	"github.com/yashjain/konnect/internal/models"
+)
+
+type atomDoc struct {
+	Title string `xml:"title"`
+	Links []struct {
+		Rel  string `xml:"rel,attr"`
+		Href string `xml:"href,attr"`
+	} `xml:"link"`
+	Entries []struct {
+		ID      string `xml:"id"`
+		Title   string `xml:"title"`
+		Updated string `xml:"updated"`
+		Link    struct {
+			Href string `xml:"href,attr"`
+		} `xml:"link"`
+		Content string `xml:"content"`
+	} `xml:"entry"`
+}
+
+type rssDoc struct {
+	Channel struct {
+		Title string `xml:"title"`
+		Items []struct {
+			Title       string `xml:"title"`
+			Link        string `xml:"link"`
+			Category    string `xml:"category"`
+			Description string `xml:"description"`
+		} `xml:"item"`
+	} `xml:"channel"`
+}
+
+func TestReleaseFeeds(t *testing.T) {
+	captureLogs(t)
+	store := openSQLiteStore(t)
+	ctx := context.Background()
+	const orgID = "00000000-0000-0000-0000-000000000001"
+	const serviceID = "6f1c2f4e-0000-4000-8000-000000000003"
+
+	// A private service is only in the feeds of those who can read it
+	require.NoError(t, store.CreateService(ctx, &models.Service{ID: "svc-private", OrgID: orgID, Name: "Ledger", Slug: "ledger", Visibility: models.VisibilityPrivate}))
+	require.NoError(t, store.CreateVersion(ctx, orgID, &models.Version{ID: "ver-ledger", ServiceID: "svc-private", Semver: "2.0.0", Status: models.VersionReleased, Changelog: "**Bold** <script>alert(1)</script>"}))
+	require.NoError(t, store.CreateVersion(ctx, orgID, &models.Version{ID: "ver-draft", ServiceID: serviceID, Semver: "2.0.0-rc.1", Status: models.VersionDraft}))
+
+	gin.SetMode(gin.TestMode)
+	as := func(p auth.Principal) *gin.Engine {
+		router := gin.New()
+		router.Use(func(c *gin.Context) { middleware.SetPrincipal(c, p) })
+		router.GET("/api/v1/services/:id/versions/feed.atom", handlers.GetServiceAtomFeed(store, store, store))
+		router.GET("/api/v1/services/:id/versions/feed.rss", handlers.GetServiceRSSFeed(store, store, store))
+		router.GET("/api/v1/versions/feed.atom", handlers.GetCatalogAtomFeed(store, store))
+		router.GET("/api/v1/versions/feed.rss", handlers.GetCatalogRSSFeed(store, store))
+		return router
+	}
+	admin := auth.Principal{OrgID: orgID}
+	user := auth.Principal{OrgID: orgID, UserID: "user-1"}
+	get := func(p auth.Principal, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		req.Host = "catalog.example.com"
+		req.Header.Set("X-Forwarded-Proto", "https")
+		as(p).ServeHTTP(w, req)
+		return w
+	}
+
+	// A service's feed lists its released versions, newest first, with absolute links
+	w := get(admin, "/api/v1/services/"+serviceID+"/versions/feed.atom")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/atom+xml; charset=utf-8", w.Header().Get("Content-Type"))
+	var atom atomDoc
+	require.NoError(t, xml.Unmarshal(w.Body.Bytes(), &atom))
+	assert.Equal(t, "Notifications releases", atom.Title)
+	require.Len(t, atom.Entries, 2)
+	assert.ElementsMatch(t, []string{"Notifications 1.0.0", "Notifications 1.1.0"}, []string{atom.Entries[0].Title, atom.Entries[1].Title})
+	assert.Regexp(t, `^https://catalog\.example\.com/api/v1/services/`+serviceID+`/versions/7a2d3e5f-`, atom.Entries[0].Link.Href)
+	assert.Contains(t, atom.Links[0].Href, "https://catalog.example.com/api/v1/services/"+serviceID+"/versions/feed.atom")
+
+	w = get(admin, "/api/v1/services/"+serviceID+"/versions/feed.rss")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/rss+xml; charset=utf-8", w.Header().Get("Content-Type"))
+	var rss rssDoc
+	require.NoError(t, xml.Unmarshal(w.Body.Bytes(), &rss))
+	require.Len(t, rss.Channel.Items, 2)
+	assert.Equal(t, "Notifications", rss.Channel.Items[0].Category)
+
+	assert.Equal(t, http.StatusNotFound, get(admin, "/api/v1/services/6f1c2f4e-0000-4000-8000-00000000dead/versions/feed.atom").Code)
+	assert.Equal(t, http.StatusNotFound, get(user, "/api/v1/services/svc-private/versions/feed.atom").Code)
+
+	// The catalog feed spans services, rendering changelogs as sanitized HTML
+	w = get(admin, "/api/v1/versions/feed.atom")
+	require.Equal(t, http.StatusOK, w.Code)
+	atom = atomDoc{}
+	require.NoError(t, xml.Unmarshal(w.Body.Bytes(), &atom))
+	require.Len(t, atom.Entries, 3)
+	assert.Equal(t, "Ledger 2.0.0", atom.Entries[0].Title)
+	assert.Contains(t, atom.Entries[0].Content, "<strong>Bold</strong>")
+	assert.NotContains(t, atom.Entries[0].Content, "<script>")
+
+	w = get(user, "/api/v1/versions/feed.rss")
+	require.Equal(t, http.StatusOK, w.Code)
+	rss = rssDoc{}
+	require.NoError(t, xml.Unmarshal(w.Body.Bytes(), &rss))
+	require.Len(t, rss.Channel.Items, 2)
+	for _, item := range rss.Channel.Items {
+		assert.Equal(t, "Notifications", item.Category)
+	}
+
+	// A draft released long after it was created is dated, and ordered, by its release
+	_, err := store.DB().ExecContext(ctx, "UPDATE versions SET created_at = ? WHERE id = ?", time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC), "ver-draft")
+	require.NoError(t, err)
+	released := time.Now().UTC().Truncate(time.Second)
+	_, err = store.UpdateVersion(ctx, orgID, serviceID, "ver-draft", &models.Version{Status: models.VersionReleased})
+	require.NoError(t, err)
+	version, err := store.GetVersion(ctx, orgID, serviceID, "ver-draft")
+	require.NoError(t, err)
+	require.NotNil(t, version.ReleasedAt)
+	assert.False(t, version.ReleasedAt.Before(released))
+
+	w = get(admin, "/api/v1/services/"+serviceID+"/versions/feed.atom")
+	require.Equal(t, http.StatusOK, w.Code)
+	atom = atomDoc{}
+	require.NoError(t, xml.Unmarshal(w.Body.Bytes(), &atom))
+	require.Len(t, atom.Entries, 3)
+	assert.Equal(t, "Notifications 2.0.0-rc.1", atom.Entries[0].Title)
+	assert.Equal(t, version.ReleasedAt.UTC().Format(time.RFC3339), atom.Entries[0].Updated)
+
+	// Releasing it again keeps its first release date
+	_, err = store.UpdateVersion(ctx, orgID, serviceID, "ver-draft", &models.Version{Status: models.VersionDraft})
+	require.NoError(t, err)
+	_, err = store.UpdateVersion(ctx, orgID, serviceID, "ver-draft", &models.Version{Status: models.VersionReleased})
+	require.NoError(t, err)
+	again, err := store.GetVersion(ctx, orgID, serviceID, "ver-draft")
+	require.NoError(t, err)
+	assert.Equal(t, version.ReleasedAt, again.ReleasedAt)
+}
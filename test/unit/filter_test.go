@@ -0,0 +1,62 @@
+package unit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yashjain/konnect/internal/filter"
+	"github.com/yashjain/konnect/pkg/types"
+)
+
+func TestParseFilter(t *testing.T) {
+	conditions, err := filter.Parse(`visibility==public;name=like=payments;created_at>=2024-01-01;versions_count=in=(1, 2);description!="a;b \"c\""`, filter.ServiceFields)
+	require.NoError(t, err)
+	assert.Equal(t, []types.FilterCondition{
+		{Field: "visibility", Operator: filter.OpEqual, Values: []interface{}{"public"}},
+		{Field: "name", Operator: filter.OpLike, Values: []interface{}{"payments"}},
+		{Field: "created_at", Operator: filter.OpGreaterEqual, Values: []interface{}{time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}},
+		{Field: "versions_count", Operator: filter.OpIn, Values: []interface{}{1, 2}},
+		{Field: "description", Operator: filter.OpNotEqual, Values: []interface{}{`a;b "c"`}},
+	}, conditions)
+
+	conditions, err = filter.Parse("", filter.ServiceFields)
+	require.NoError(t, err)
+	assert.Empty(t, conditions)
+}
+
+func TestParseFilterRejectsInvalidFilters(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter string
+	}{
+		{name: "unknown field", filter: "org_id==x"},
+		{name: "column expression", filter: "(name)==x"},
+		{name: "missing operator", filter: "name"},
+		{name: "unsupported operator", filter: "visibility=like=pub"},
+		{name: "comparison on string", filter: "name>a"},
+		{name: "unknown enum value", filter: "visibility==secret"},
+		{name: "not an integer", filter: "versions_count>=many"},
+		{name: "not a time", filter: "created_at>=yesterday"},
+		{name: "missing value", filter: "name=="},
+		{name: "unterminated quote", filter: `name=="payments`},
+		{name: "text after quote", filter: `name=="pay"ments`},
+		{name: "unquoted parenthesis", filter: "name==f(x)"},
+		{name: "in without list", filter: "versions_count=in=1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := filter.Parse(tt.filter, filter.ServiceFields)
+			assert.ErrorIs(t, err, filter.ErrInvalidFilter)
+		})
+	}
+
+	// Status is only a field of versions
+	_, err := filter.Parse("status==released", filter.ServiceFields)
+	assert.ErrorIs(t, err, filter.ErrInvalidFilter)
+	_, err = filter.Parse("status==released", filter.VersionFields)
+	assert.NoError(t, err)
+}
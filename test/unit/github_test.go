@@ -0,0 +1,166 @@
+package unit
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yashjain/konnect/internal/auth"
+	"github.com/yashjain/konnect/internal/handlers"
+	"github.com/yashjain/konnect/internal/middleware"
+	"github.com/yashjain/konnect/internal/models"
+	"github.com/yashjain/konnect/internal/webhook"
+)
+
+func TestGitHubReleases(t *testing.T) {
+	captureLogs(t)
+	store := openSQLiteStore(t)
+	ctx := context.Background()
+	const orgID = "00000000-0000-0000-0000-000000000001"
+	const serviceID = "6f1c2f4e-0000-4000-8000-000000000003"
+	const otherOrgID = "00000000-0000-0000-0000-000000000002"
+	const otherServiceID = "6f1c2f4e-0000-4000-8000-000000000009"
+	require.NoError(t, store.CreateOrganization(ctx, &models.Organization{ID: otherOrgID, Name: "Other", Slug: "other"}))
+	require.NoError(t, store.CreateService(ctx, &models.Service{ID: otherServiceID, OrgID: otherOrgID, Name: "Notifications", Slug: "notifications", Visibility: models.VisibilityPublic}))
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/integrations/github/events", handlers.GitHubEvents(store, store))
+	api := router.Group("/", func(c *gin.Context) {
+		org := orgID
+		if c.GetHeader("X-Org") != "" {
+			org = c.GetHeader("X-Org")
+		}
+		middleware.SetPrincipal(c, auth.Principal{OrgID: org})
+	})
+	api.GET("/services/:id/github", handlers.GetGitHubRepository(store, store))
+	api.PUT("/services/:id/github", handlers.SetGitHubRepository(store, store))
+	api.DELETE("/services/:id/github", handlers.DeleteGitHubRepository(store, store))
+
+	doAs := func(org, method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Org", org)
+		router.ServeHTTP(w, req)
+		return w
+	}
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		return doAs(orgID, method, path, body)
+	}
+	secret := "github-secret-of-the-owner"
+	deliverWith := func(secret, event, body string) *httptest.ResponseRecorder {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write([]byte(body))
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/integrations/github/events", strings.NewReader(body))
+		req.Header.Set(webhook.HeaderGitHubEvent, event)
+		req.Header.Set(webhook.HeaderGitHubSignature, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+		router.ServeHTTP(w, req)
+		return w
+	}
+	deliver := func(event, body string) *httptest.ResponseRecorder {
+		return deliverWith(secret, event, body)
+	}
+	release := func(repository, action, tag string, prerelease bool) string {
+		body, _ := json.Marshal(map[string]interface{}{
+			"action":     action,
+			"release":    map[string]interface{}{"tag_name": tag, "body": "Release notes for " + tag, "prerelease": prerelease},
+			"repository": map[string]interface{}{"full_name": repository},
+		})
+		return string(body)
+	}
+
+	// Repositories are linked to one service each within an organization, with
+	// the secret their webhook signs events with, returned only when linked
+	w := do("PUT", "/services/"+serviceID+"/github", `{"repository": "Acme/Notifications", "secret": "`+secret+`"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var link models.GitHubRepository
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &link))
+	assert.Equal(t, secret, link.Secret)
+	w = do("GET", "/services/"+serviceID+"/github", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	link = models.GitHubRepository{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &link))
+	assert.Equal(t, "acme/notifications", link.Repository)
+	assert.Equal(t, serviceID, link.ServiceID)
+	assert.Empty(t, link.Secret)
+	w = do("PUT", "/services/6f1c2f4e-0000-4000-8000-000000000001/github", `{"repository": "acme/notifications"}`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	w = do("PUT", "/services/"+serviceID+"/github", `{"repository": "not a repository"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = do("PUT", "/services/"+serviceID+"/github", `{"repository": "acme/notifications", "secret": "short"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// Another organization may link the same repository, with a generated secret
+	w = doAs(otherOrgID, "PUT", "/services/"+otherServiceID+"/github", `{"repository": "acme/notifications"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var otherLink models.GitHubRepository
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &otherLink))
+	assert.GreaterOrEqual(t, len(otherLink.Secret), 16)
+	assert.NotEqual(t, secret, otherLink.Secret)
+	w = do("GET", "/services/6f1c2f4e-0000-4000-8000-000000000001/github", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// Events must be signed with the secret of a link of their repository
+	w = deliver("ping", `{"zen": "Keep it logically awesome.", "repository": {"full_name": "acme/notifications"}}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	req, _ := http.NewRequest(http.MethodPost, "/integrations/github/events", strings.NewReader(`{"repository": {"full_name": "acme/notifications"}}`))
+	req.Header.Set(webhook.HeaderGitHubEvent, "ping")
+	req.Header.Set(webhook.HeaderGitHubSignature, "sha256=00")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	// A published release becomes a released version, once, of the service
+	// whose link's secret signed it
+	w = deliver("release", release("acme/Notifications", "published", "v2.0.0", false))
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	version, err := store.GetVersionBySemver(ctx, orgID, serviceID, "2.0.0")
+	require.NoError(t, err)
+	_, err = store.GetVersionBySemver(ctx, otherOrgID, otherServiceID, "2.0.0")
+	assert.Error(t, err)
+	w = deliverWith(otherLink.Secret, "release", release("acme/notifications", "published", "v9.0.0", false))
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	_, err = store.GetVersionBySemver(ctx, otherOrgID, otherServiceID, "9.0.0")
+	assert.NoError(t, err)
+	_, err = store.GetVersionBySemver(ctx, orgID, serviceID, "9.0.0")
+	assert.Error(t, err)
+	assert.Equal(t, models.VersionReleased, version.Status)
+	assert.Equal(t, "Release notes for v2.0.0", version.Changelog)
+	w = deliver("release", release("acme/notifications", "released", "v2.0.0", false))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Prereleases wait until they are released
+	w = deliver("release", release("acme/notifications", "published", "2.1.0-rc.1", true))
+	assert.Equal(t, http.StatusOK, w.Code)
+	_, err = store.GetVersionBySemver(ctx, orgID, serviceID, "2.1.0-rc.1")
+	assert.Error(t, err)
+	w = deliver("release", release("acme/notifications", "released", "2.1.0-rc.1", false))
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	// Other events are acknowledged and ignored, while events of unlinked
+	// repositories are rejected like forged ones
+	w = deliver("push", `{"repository": {"full_name": "acme/notifications"}}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = deliver("release", release("acme/other", "published", "v1.0.0", false))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	// Unlinked repositories no longer create versions
+	w = do("DELETE", "/services/"+serviceID+"/github", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	w = deliver("release", release("acme/notifications", "published", "v3.0.0", false))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	_, err = store.GetVersionBySemver(ctx, orgID, serviceID, "3.0.0")
+	assert.Error(t, err)
+}
@@ -0,0 +1,157 @@
+package unit
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yashjain/konnect/internal/auth"
+	"github.com/yashjain/konnect/internal/handlers"
+	"github.com/yashjain/konnect/internal/middleware"
+	"github.com/yashjain/konnect/internal/repository"
+)
+
+// jsonAPIResponse is the part of a JSON:API document the tests look at
+type jsonAPIResponse struct {
+	Data          json.RawMessage `json:"data"`
+	Links         map[string]string
+	Meta          map[string]json.RawMessage
+	Errors        []map[string]string
+	JSONAPIObject map[string]string `json:"jsonapi"`
+}
+
+// jsonAPITestResource is a JSON:API resource object as the tests read it
+type jsonAPITestResource struct {
+	Type          string                 `json:"type"`
+	ID            string                 `json:"id"`
+	Attributes    map[string]interface{} `json:"attributes"`
+	Relationships map[string]struct {
+		Data  *struct{ Type, ID string } `json:"data"`
+		Links map[string]string          `json:"links"`
+		Meta  map[string]interface{}     `json:"meta"`
+	} `json:"relationships"`
+	Links map[string]string `json:"links"`
+}
+
+// setupJSONAPIRouter serves the service and version routes backed by repo behind the JSON:API middleware
+func setupJSONAPIRouter(repo repository.Repository) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middleware.JSONAPI())
+	router.Use(func(c *gin.Context) {
+		middleware.SetPrincipal(c, auth.Principal{OrgID: "00000000-0000-0000-0000-000000000001"})
+	})
+	router.GET("/api/v1/services", handlers.GetServices(repo))
+	router.POST("/api/v1/services", handlers.CreateService(repo))
+	router.GET("/api/v1/services/:id", handlers.GetService(repo, repo))
+	router.GET("/api/v1/services/:id/versions", handlers.GetVersions(repo, repo))
+	return router
+}
+
+func TestJSONAPI(t *testing.T) {
+	captureLogs(t)
+	router := setupJSONAPIRouter(openSQLiteStore(t))
+	const notificationsID = "6f1c2f4e-0000-4000-8000-000000000003"
+	do := func(method, path, accept, contentType, body string) (*httptest.ResponseRecorder, jsonAPIResponse) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		router.ServeHTTP(w, req)
+		var doc jsonAPIResponse
+		_ = json.Unmarshal(w.Body.Bytes(), &doc)
+		return w, doc
+	}
+
+	// Lists are resource collections with page links
+	w, doc := do("GET", "/api/v1/services?page_size=2&count=true", middleware.MediaTypeJSONAPI, "", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, middleware.MediaTypeJSONAPI, w.Header().Get("Content-Type"))
+	assert.Equal(t, "Accept", w.Header().Get("Vary"))
+	assert.Equal(t, "1.1", doc.JSONAPIObject["version"])
+	var services []jsonAPITestResource
+	require.NoError(t, json.Unmarshal(doc.Data, &services))
+	require.Len(t, services, 2)
+	assert.Equal(t, "services", services[0].Type)
+	assert.Contains(t, doc.Links["next"], "/api/v1/services?count=true&cursor=")
+	assert.Equal(t, "/api/v1/services?count=true&page=2&page_size=2", doc.Links["last"])
+	assert.Contains(t, doc.Meta, "pagination")
+
+	// A service links to its versions
+	w, doc = do("GET", "/api/v1/services/"+notificationsID, "application/json, application/vnd.api+json;q=0.5", "", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var service jsonAPITestResource
+	require.NoError(t, json.Unmarshal(doc.Data, &service))
+	assert.Equal(t, notificationsID, service.ID)
+	assert.Equal(t, "notifications", service.Attributes["slug"])
+	assert.NotContains(t, service.Attributes, "id")
+	assert.Equal(t, "/api/v1/services/"+notificationsID, service.Links["self"])
+	versions := service.Relationships["versions"]
+	assert.Equal(t, "/api/v1/services/"+notificationsID+"/versions", versions.Links["related"])
+	assert.EqualValues(t, 2, versions.Meta["count"])
+
+	// and a version to its service
+	w, doc = do("GET", "/api/v1/services/"+notificationsID+"/versions", middleware.MediaTypeJSONAPI, "", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var serviceVersions []jsonAPITestResource
+	require.NoError(t, json.Unmarshal(doc.Data, &serviceVersions))
+	require.Len(t, serviceVersions, 2)
+	assert.Equal(t, "versions", serviceVersions[0].Type)
+	assert.Equal(t, "services", serviceVersions[0].Relationships["service"].Data.Type)
+	assert.Equal(t, notificationsID, serviceVersions[0].Relationships["service"].Data.ID)
+
+	// Resources are created from a JSON:API document
+	w, doc = do("POST", "/api/v1/services", middleware.MediaTypeJSONAPI, middleware.MediaTypeJSONAPI,
+		`{"data": {"type": "services", "attributes": {"name": "Ledger", "slug": "ledger"}}}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var created jsonAPITestResource
+	require.NoError(t, json.Unmarshal(doc.Data, &created))
+	assert.Equal(t, "Ledger", created.Attributes["name"])
+	assert.Equal(t, "/api/v1/services/"+created.ID, w.Header().Get("Location"))
+
+	w, doc = do("POST", "/api/v1/services", middleware.MediaTypeJSONAPI, middleware.MediaTypeJSONAPI,
+		`{"data": {"type": "versions", "attributes": {"name": "Ledger", "slug": "ledger"}}}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	require.Len(t, doc.Errors, 1)
+	assert.Equal(t, "data.type must be services", doc.Errors[0]["detail"])
+
+	// Errors are JSON:API error objects
+	w, doc = do("GET", "/api/v1/services/6f1c2f4e-0000-4000-8000-000000000009", middleware.MediaTypeJSONAPI, "", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, middleware.MediaTypeJSONAPI, w.Header().Get("Content-Type"))
+	require.Len(t, doc.Errors, 1)
+	assert.Equal(t, "404", doc.Errors[0]["status"])
+	assert.Equal(t, "not_found", doc.Errors[0]["code"])
+	assert.Equal(t, "Not Found", doc.Errors[0]["title"])
+	assert.NotEmpty(t, doc.Errors[0]["detail"])
+
+	// Media type parameters other than profile are refused
+	w, _ = do("GET", "/api/v1/services", "application/vnd.api+json; ext=atomic", "", "")
+	assert.Equal(t, http.StatusNotAcceptable, w.Code)
+	w, _ = do("GET", "/api/v1/services", `application/vnd.api+json; ext=atomic, application/vnd.api+json; profile="https://example.com/p"`, "", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, middleware.MediaTypeJSONAPI, w.Header().Get("Content-Type"))
+	w, _ = do("POST", "/api/v1/services", "", "application/vnd.api+json; charset=utf-8", `{}`)
+	assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+
+	// Plain JSON is unchanged
+	w, _ = do("GET", "/api/v1/services/"+notificationsID, "", "", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "application/json")
+	var plain map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &plain))
+	assert.Equal(t, notificationsID, plain["id"])
+	w, _ = do("GET", "/api/v1/services/6f1c2f4e-0000-4000-8000-000000000009", "", "", "")
+	assert.Contains(t, w.Body.String(), `"error"`)
+}
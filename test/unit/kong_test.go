@@ -0,0 +1,303 @@
+package unit
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yashjain/konnect/internal/auth"
+	"github.com/yashjain/konnect/internal/handlers"
+	"github.com/yashjain/konnect/internal/kong"
+	"github.com/yashjain/konnect/internal/middleware"
+	"github.com/yashjain/konnect/internal/models"
+	"github.com/yashjain/konnect/internal/outbox"
+	"github.com/yashjain/konnect/pkg/types"
+)
+
+// fakeKong is a Kong Admin API recording the requests made to it, answering
+// with status when it is set
+type fakeKong struct {
+	mu       sync.Mutex
+	requests []string
+	bodies   map[string]map[string]interface{}
+	status   int
+}
+
+func (k *fakeKong) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if r.Header.Get("Kong-Admin-Token") != "kong-token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if k.status != 0 {
+		w.WriteHeader(k.status)
+		_, _ = w.Write([]byte(`{"message": "database unavailable"}`))
+		return
+	}
+
+	k.requests = append(k.requests, r.Method+" "+r.URL.Path)
+	body, _ := io.ReadAll(r.Body)
+	if r.Method == http.MethodPut {
+		var entity map[string]interface{}
+		_ = json.Unmarshal(body, &entity)
+		k.bodies[r.URL.Path] = entity
+	}
+	if r.Method == http.MethodDelete {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	_, _ = w.Write(body)
+}
+
+// take returns the requests made since the last call
+func (k *fakeKong) take() []string {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	requests := k.requests
+	k.requests = nil
+	return requests
+}
+
+func (k *fakeKong) fail(status int) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.status = status
+}
+
+func TestKongSync(t *testing.T) {
+	captureLogs(t)
+	t.Setenv("KONG_ADMIN_URL", "http://127.0.0.1/unused")
+	store := openSQLiteStore(t)
+	ctx := context.Background()
+	const orgID = "00000000-0000-0000-0000-000000000001"
+	const serviceID = "1b4e28ba-2fa1-41d2-883f-0016d3cca427"
+
+	admin := &fakeKong{bodies: map[string]map[string]interface{}{}}
+	server := httptest.NewServer(admin)
+	defer server.Close()
+	retry := outbox.RetryPolicy{MaxAttempts: 3, Base: time.Minute, Max: time.Hour}
+	syncer := outbox.NewKongSyncer(store, store, store, kong.New(server.URL, "kong-token", time.Second), "http://{slug}.internal:8080", time.Second, retry)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		middleware.SetPrincipal(c, auth.Principal{OrgID: orgID})
+	})
+	router.GET("/services/:id/sync", handlers.GetServiceSync(store, store))
+	router.POST("/services/:id/sync", handlers.SyncService(syncer, store))
+	do := func(method, path string) (*httptest.ResponseRecorder, models.KongSync) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, nil)
+		router.ServeHTTP(w, req)
+		var sync models.KongSync
+		_ = json.Unmarshal(w.Body.Bytes(), &sync)
+		return w, sync
+	}
+
+	// Changes to a service and its versions queue one sync
+	service := &models.Service{ID: serviceID, OrgID: orgID, Name: "Payments", Slug: "payments", Visibility: models.VisibilityPublic, Tags: []string{"billing"}}
+	require.NoError(t, store.CreateService(ctx, service))
+	require.NoError(t, store.CreateVersion(ctx, orgID, &models.Version{ID: "ver-1", ServiceID: serviceID, Semver: "1.0.0", Status: models.VersionReleased}))
+	require.NoError(t, store.CreateVersion(ctx, orgID, &models.Version{ID: "ver-2", ServiceID: serviceID, Semver: "2.0.0", Status: models.VersionDraft}))
+	w, sync := do("GET", "/services/"+serviceID+"/sync")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, models.KongSyncPending, sync.Status)
+
+	// The service becomes a Kong service and route, tagged with its newest released version
+	synced, err := syncer.Flush(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, synced)
+	assert.Equal(t, []string{"PUT /services/" + serviceID, "PUT /routes/" + serviceID}, admin.take())
+	kongService := admin.bodies["/services/"+serviceID]
+	assert.Equal(t, "payments", kongService["name"])
+	assert.Equal(t, "http://payments.internal:8080", kongService["url"])
+	assert.Equal(t, []interface{}{"konnect", "billing", "konnect-version:1.0.0"}, kongService["tags"])
+	route := admin.bodies["/routes/"+serviceID]
+	assert.Equal(t, []interface{}{"/payments"}, route["paths"])
+	assert.Equal(t, map[string]interface{}{"id": serviceID}, route["service"])
+
+	w, sync = do("GET", "/services/"+serviceID+"/sync")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, models.KongSyncSynced, sync.Status)
+	assert.Equal(t, 1, sync.Attempts)
+	assert.NotNil(t, sync.SyncedAt)
+
+	// Nothing is due until the service changes again
+	synced, err = syncer.Flush(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, synced)
+	assert.Empty(t, admin.take())
+
+	// A failed sync is retried later, with Kong's error
+	admin.fail(http.StatusInternalServerError)
+	service.Description = "Card payments"
+	_, err = store.UpdateService(ctx, orgID, serviceID, service)
+	require.NoError(t, err)
+	synced, err = syncer.Flush(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, synced)
+	current, err := store.GetKongSync(ctx, orgID, serviceID)
+	require.NoError(t, err)
+	assert.Equal(t, models.KongSyncPending, current.Status)
+	assert.Equal(t, 1, current.Attempts)
+	assert.Contains(t, current.LastError, "database unavailable")
+	require.NotNil(t, current.NextAttemptAt)
+	assert.True(t, current.NextAttemptAt.After(time.Now()))
+
+	// A manual sync does not wait for the retry
+	admin.fail(0)
+	w, sync = do("POST", "/services/"+serviceID+"/sync")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, models.KongSyncSynced, sync.Status)
+	assert.Empty(t, sync.LastError)
+	assert.Len(t, admin.take(), 2)
+
+	// Deleted services are removed from Kong, routes first
+	_, err = store.DeleteService(ctx, orgID, serviceID)
+	require.NoError(t, err)
+	synced, err = syncer.Flush(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, synced)
+	assert.Equal(t, []string{"DELETE /routes/" + serviceID, "DELETE /services/" + serviceID}, admin.take())
+
+	// Services changed before syncing was enabled have no sync until one is triggered
+	w, _ = do("GET", "/services/6f1c2f4e-0000-4000-8000-000000000001/sync")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w, sync = do("POST", "/services/6f1c2f4e-0000-4000-8000-000000000001/sync")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, models.KongSyncSynced, sync.Status)
+	w, _ = do("POST", "/services/6f1c2f4e-0000-4000-8000-00000000dead/sync")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+// kongLists is a Kong Admin API listing services and routes two per page,
+// answering with status when it is set
+type kongLists struct {
+	services []kong.Service
+	routes   []kong.Route
+	status   int
+}
+
+func (k *kongLists) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if k.status != 0 {
+		w.WriteHeader(k.status)
+		_, _ = w.Write([]byte(`{"message": "database unavailable"}`))
+		return
+	}
+
+	var data []interface{}
+	switch r.URL.Path {
+	case "/services":
+		for _, s := range k.services {
+			data = append(data, s)
+		}
+	case "/routes":
+		for _, route := range k.routes {
+			data = append(data, route)
+		}
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	start, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+	end := min(start+2, len(data))
+	page := map[string]interface{}{"data": data[start:end], "offset": nil}
+	if end < len(data) {
+		page["offset"] = strconv.Itoa(end)
+	}
+	_ = json.NewEncoder(w).Encode(page)
+}
+
+func TestKongImport(t *testing.T) {
+	captureLogs(t)
+	store := openSQLiteStore(t)
+	ctx := context.Background()
+	const orgID = "00000000-0000-0000-0000-000000000001"
+
+	admin := &kongLists{
+		services: []kong.Service{
+			{ID: "k-1", Name: "orders", Protocol: "http", Host: "orders.internal", Port: 8080, Path: "/v1", Tags: []string{"Team-A", "not a tag!"}},
+			{ID: "k-2", Name: "payments", Protocol: "http", Host: "payments", Port: 80, Tags: []string{"konnect", "billing"}},
+			{ID: "k-3", Protocol: "https", Host: "legacy-billing.internal", Port: 443},
+			{ID: "k-4", Name: "locate-us", Protocol: "http", Host: "locate", Port: 80},
+		},
+		routes: []kong.Route{
+			{ID: "r-1", Paths: []string{"/shop/orders"}, Service: &kong.Reference{ID: "k-1"}},
+			{ID: "r-2", Paths: []string{"/orders"}, Service: &kong.Reference{ID: "k-1"}},
+			{ID: "r-3", Paths: []string{"/payments"}, Service: &kong.Reference{ID: "k-2"}},
+		},
+	}
+	server := httptest.NewServer(admin)
+	defer server.Close()
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		middleware.SetPrincipal(c, auth.Principal{OrgID: orgID})
+	})
+	router.POST("/import/kong", handlers.ImportKong(kong.New(server.URL, "", time.Second), store, store, store))
+	do := func(path string) (*httptest.ResponseRecorder, models.ImportReport) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, path, nil)
+		router.ServeHTTP(w, req)
+		var report models.ImportReport
+		_ = json.Unmarshal(w.Body.Bytes(), &report)
+		return w, report
+	}
+
+	// A dry run reports what would be imported, following Kong's pages, without writing anything
+	w, report := do("/import/kong?dry_run=true")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.True(t, report.DryRun)
+	assert.Equal(t, 2, report.Created)
+	assert.Equal(t, 1, report.Skipped)
+	var slugs []string
+	for _, item := range report.Items {
+		slugs = append(slugs, item.Slug)
+	}
+	assert.Equal(t, []string{"legacy-billing-internal", "locate-us", "orders"}, slugs)
+	_, err := store.GetServiceBySlug(ctx, orgID, "orders", types.ReadOptions{})
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+
+	// Kong services become private services described by their upstream and routes
+	w, report = do("/import/kong")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, 2, report.Created)
+	orders, err := store.GetServiceBySlug(ctx, orgID, "orders", types.ReadOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "orders", orders.Name)
+	assert.Equal(t, models.VisibilityPrivate, orders.Visibility)
+	assert.Equal(t, []string{"team-a"}, orders.Tags)
+	assert.Equal(t, "Imported from Kong. Proxies to `http://orders.internal:8080/v1`. Routes match `/orders`, `/shop/orders`.", orders.Description)
+	legacy, err := store.GetServiceBySlug(ctx, orgID, "legacy-billing-internal", types.ReadOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "legacy-billing.internal", legacy.Name)
+	_, err = store.GetServiceBySlug(ctx, orgID, "payments", types.ReadOptions{})
+	assert.ErrorIs(t, err, sql.ErrNoRows, "services synced from a catalog are left out")
+
+	// Importing again only adds what is new
+	w, report = do("/import/kong")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Zero(t, report.Created)
+	assert.Equal(t, 3, report.Skipped)
+
+	w, _ = do("/import/kong?on_conflict=merge")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	admin.status = http.StatusInternalServerError
+	w, _ = do("/import/kong")
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+	assert.Contains(t, w.Body.String(), "database unavailable")
+}
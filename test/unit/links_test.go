@@ -0,0 +1,139 @@
+package unit
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yashjain/konnect/internal/auth"
+	"github.com/yashjain/konnect/internal/handlers"
+	"github.com/yashjain/konnect/internal/middleware"
+	"github.com/yashjain/konnect/internal/models"
+	"github.com/yashjain/konnect/internal/repository"
+	"github.com/yashjain/konnect/pkg/types"
+)
+
+// setupLinksRouter serves the service and version routes backed by repo
+func setupLinksRouter(repo repository.Repository) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		middleware.SetPrincipal(c, auth.Principal{OrgID: "00000000-0000-0000-0000-000000000001"})
+	})
+	router.GET("/api/v1/services", handlers.GetServices(repo))
+	router.GET("/api/v1/services/:id", handlers.GetService(repo, repo))
+	router.GET("/api/v1/services/:id/versions", handlers.GetVersions(repo, repo))
+	router.POST("/api/v1/services/:id/versions", handlers.CreateVersion(repo, repo))
+	router.GET("/api/v1/services/:id/versions/export", handlers.ExportVersions(repo, repo))
+	router.GET("/api/v1/services/:id/versions/:version_id", handlers.GetVersion(repo, repo))
+	return router
+}
+
+func TestHypermediaLinks(t *testing.T) {
+	captureLogs(t)
+	router := setupLinksRouter(openSQLiteStore(t))
+	const collectMoneyID = "6f1c2f4e-0000-4000-8000-000000000002"
+	const notificationsID = "6f1c2f4e-0000-4000-8000-000000000003"
+	do := func(method, path, body string, out interface{}) *httptest.ResponseRecorder {
+		t.Helper()
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		router.ServeHTTP(w, req)
+		if out != nil && w.Code < http.StatusBadRequest {
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), out), w.Body.String())
+		}
+		return w
+	}
+
+	// A service links to itself, its versions and its newest version
+	var service models.Service
+	require.Equal(t, http.StatusOK, do("GET", "/api/v1/services/"+notificationsID, "", &service).Code)
+	assert.Equal(t, types.Links{
+		"self":     {Href: "/api/v1/services/" + notificationsID},
+		"versions": {Href: "/api/v1/services/" + notificationsID + "/versions"},
+		"latest":   {Href: "/api/v1/services/" + notificationsID + "/versions/latest"},
+	}, service.Links)
+
+	// Pages link to themselves and their neighbours
+	var page struct {
+		Data  []models.Service `json:"data"`
+		Links types.Links      `json:"_links"`
+	}
+	require.Equal(t, http.StatusOK, do("GET", "/api/v1/services?page=2&page_size=1&count=true", "", &page).Code)
+	require.Len(t, page.Data, 1)
+	assert.NotEmpty(t, page.Data[0].Links["self"].Href)
+	assert.Equal(t, "/api/v1/services?page=2&page_size=1&count=true", page.Links["self"].Href)
+	assert.Equal(t, "/api/v1/services?count=true&page_size=1", page.Links["first"].Href)
+	assert.Equal(t, "/api/v1/services?count=true&page=1&page_size=1", page.Links["prev"].Href)
+	assert.Contains(t, page.Links["next"].Href, "cursor=")
+	assert.Equal(t, "/api/v1/services?count=true&page=3&page_size=1", page.Links["last"].Href)
+
+	// Following the links leads to the versions
+	var versions struct {
+		Data  []models.Version `json:"data"`
+		Links types.Links      `json:"_links"`
+	}
+	require.Equal(t, http.StatusOK, do("GET", service.Links["versions"].Href, "", &versions).Code)
+	require.Len(t, versions.Data, 2)
+	assert.NotContains(t, versions.Links, "prev")
+	newest := versions.Data[0]
+	assert.Equal(t, types.Links{
+		"self":     {Href: "/api/v1/services/" + notificationsID + "/versions/" + newest.ID},
+		"service":  {Href: "/api/v1/services/" + notificationsID},
+		"versions": {Href: "/api/v1/services/" + notificationsID + "/versions"},
+	}, newest.Links)
+
+	var latest, version models.Version
+	require.Equal(t, http.StatusOK, do("GET", service.Links["latest"].Href, "", &latest).Code)
+	assert.Equal(t, newest, latest)
+	require.Equal(t, http.StatusOK, do("GET", newest.Links["self"].Href+"?render=html", "", &version).Code)
+	assert.Equal(t, newest.ID, version.ID)
+	assert.NotEmpty(t, version.ChangelogHTML)
+
+	// Created versions are located by their self link
+	w := do("POST", "/api/v1/services/"+collectMoneyID+"/versions", `{"semver": "0.2.0", "status": "draft"}`, &version)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	assert.Equal(t, "/api/v1/services/"+collectMoneyID+"/versions/"+version.ID, w.Header().Get("Location"))
+
+	assert.Equal(t, http.StatusNotFound, do("GET", "/api/v1/services/"+collectMoneyID+"/versions/"+newest.ID, "", nil).Code)
+	assert.Equal(t, http.StatusNotFound, do("GET", "/api/v1/services/6f1c2f4e-0000-4000-8000-000000000001/versions/latest", "", nil).Code)
+	assert.Equal(t, http.StatusOK, do("GET", "/api/v1/services/"+notificationsID+"/versions/export?format=csv", "", nil).Code)
+}
+
+func TestPageHeaders(t *testing.T) {
+	captureLogs(t)
+	router := setupLinksRouter(openSQLiteStore(t))
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		return w
+	}
+
+	// Counted pages link to every neighbour and report the total
+	w := get("/api/v1/services?page=2&page_size=1")
+	assert.Equal(t, "3", w.Header().Get("X-Total-Count"))
+	links := strings.Split(w.Header().Get("Link"), ", ")
+	require.Len(t, links, 4)
+	assert.Equal(t, `</api/v1/services?page_size=1>; rel="first"`, links[0])
+	assert.Equal(t, `</api/v1/services?page=1&page_size=1>; rel="prev"`, links[1])
+	assert.Regexp(t, `^</api/v1/services\?cursor=[^>]+&page_size=1>; rel="next"$`, links[2])
+	assert.Equal(t, `</api/v1/services?page=3&page_size=1>; rel="last"`, links[3])
+
+	// Uncounted pages have no total and no last page
+	w = get("/api/v1/services?page_size=5&count=false")
+	assert.Empty(t, w.Header().Get("X-Total-Count"))
+	assert.Equal(t, `</api/v1/services?count=false&page_size=5>; rel="first"`, w.Header().Get("Link"))
+
+	w = get("/api/v1/services/6f1c2f4e-0000-4000-8000-000000000003/versions")
+	assert.Equal(t, "2", w.Header().Get("X-Total-Count"))
+	assert.Equal(t, `</api/v1/services/6f1c2f4e-0000-4000-8000-000000000003/versions?page=1>; rel="last"`,
+		strings.Split(w.Header().Get("Link"), ", ")[1])
+}
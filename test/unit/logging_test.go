@@ -0,0 +1,349 @@
+package unit
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yashjain/konnect/internal/auth"
+	"github.com/yashjain/konnect/internal/config"
+	"github.com/yashjain/konnect/internal/handlers"
+	"github.com/yashjain/konnect/internal/logging"
+	"github.com/yashjain/konnect/internal/metrics"
+	"github.com/yashjain/konnect/internal/middleware"
+)
+
+// captureLogs sends slog's default logger to a buffer of JSON lines for the rest of the test
+func captureLogs(t *testing.T) *bytes.Buffer {
+	var buf bytes.Buffer
+	logger, err := logging.New(&buf, slog.LevelDebug, logging.FormatJSON)
+	require.NoError(t, err)
+
+	previous := slog.Default()
+	slog.SetDefault(logger)
+	t.Cleanup(func() { slog.SetDefault(previous) })
+	return &buf
+}
+
+// logRecords decodes each JSON line logged to buf
+func logRecords(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	var records []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var record map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(line), &record), line)
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestParseLevel(t *testing.T) {
+	for input, expected := range map[string]slog.Level{"debug": slog.LevelDebug, "INFO": slog.LevelInfo, "warn": slog.LevelWarn, "error": slog.LevelError} {
+		level, err := logging.ParseLevel(input)
+		require.NoError(t, err, input)
+		assert.Equal(t, expected, level, input)
+	}
+
+	level, err := logging.ParseLevel("verbose")
+	assert.Error(t, err)
+	assert.Equal(t, slog.LevelInfo, level)
+}
+
+func TestLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := logging.New(&buf, slog.LevelInfo, logging.FormatJSON)
+	require.NoError(t, err)
+
+	logger.Debug("hidden")
+	logger.Warn("Read replica disabled", "error", "dial app:s3cret@tcp(db:3306)/app")
+
+	records := logRecords(t, &buf)
+	require.Len(t, records, 1)
+	assert.Equal(t, "WARN", records[0]["level"])
+	assert.Equal(t, "Read replica disabled", records[0]["msg"])
+	assert.NotEmpty(t, records[0]["time"])
+	assert.Equal(t, "dial app:[REDACTED]@tcp(db:3306)/app", records[0]["error"])
+
+	buf.Reset()
+	logger, err = logging.New(&buf, slog.LevelInfo, logging.FormatText)
+	require.NoError(t, err)
+	logger.Info("Server starting", "port", "8080")
+	assert.Contains(t, buf.String(), `level=INFO msg="Server starting" port=8080`)
+
+	_, err = logging.New(&buf, slog.LevelInfo, "xml")
+	assert.Error(t, err)
+}
+
+func TestAccessLog(t *testing.T) {
+	buf := captureLogs(t)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middleware.RequestID(), middleware.AccessLog(config.AccessLogConfig{}))
+	router.GET("/services/:id", func(c *gin.Context) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Service not found"})
+	})
+
+	req, _ := http.NewRequest("GET", "/services/svc-1?token=secret", nil)
+	req.Header.Set("X-Request-ID", "req-1")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	records := logRecords(t, buf)
+	require.Len(t, records, 1)
+	record := records[0]
+	assert.Equal(t, "WARN", record["level"])
+	assert.Equal(t, "request", record["msg"])
+	assert.Equal(t, "GET", record["method"])
+	assert.Equal(t, "/services/:id", record["route"])
+	assert.Equal(t, "/services/svc-1", record["path"])
+	assert.Equal(t, float64(http.StatusNotFound), record["status"])
+	assert.Equal(t, "req-1", record["request_id"])
+	assert.Contains(t, record, "latency_ms")
+	assert.NotContains(t, buf.String(), "secret")
+}
+
+func TestAccessLogExclusionsAndSampling(t *testing.T) {
+	buf := captureLogs(t)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middleware.AccessLog(config.AccessLogConfig{
+		Exclude:      []string{"/health"},
+		SampleRoutes: []string{"GET /services/search"},
+		SampleRate:   0,
+	}))
+	router.Use(func(c *gin.Context) {
+		middleware.SetPrincipal(c, auth.Principal{OrgID: "org-1", UserID: "alice"})
+	})
+	status := http.StatusOK
+	respond := func(c *gin.Context) { c.JSON(status, gin.H{}) }
+	router.GET("/health", respond)
+	router.GET("/services/search", respond)
+	router.GET("/services", respond)
+	get := func(path string) {
+		req, _ := http.NewRequest("GET", path, nil)
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	// Excluded paths and unsampled routes are left out while they succeed
+	get("/health")
+	get("/services/search")
+	assert.Empty(t, buf.String())
+
+	// Other routes are logged with the principal
+	get("/services")
+	records := logRecords(t, buf)
+	require.Len(t, records, 1)
+	assert.Equal(t, "org-1", records[0]["org_id"])
+	assert.Equal(t, "alice", records[0]["user_id"])
+
+	// Failures are always logged from sampled routes, and server errors from excluded paths
+	buf.Reset()
+	status = http.StatusBadRequest
+	get("/services/search")
+	get("/health")
+	status = http.StatusServiceUnavailable
+	get("/health")
+	records = logRecords(t, buf)
+	require.Len(t, records, 2)
+	assert.Equal(t, "/services/search", records[0]["path"])
+	assert.Equal(t, float64(http.StatusServiceUnavailable), records[1]["status"])
+}
+
+func TestRequestID(t *testing.T) {
+	buf := captureLogs(t)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middleware.RequestID())
+	router.GET("/ok", func(c *gin.Context) {
+		slog.InfoContext(c.Request.Context(), "handled")
+		c.JSON(http.StatusOK, gin.H{"data": middleware.GetRequestID(c)})
+	})
+	router.GET("/fail", func(c *gin.Context) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "page must be greater than 0"})
+	})
+	router.GET("/empty", func(c *gin.Context) {
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{})
+	})
+	get := func(path, id string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		if id != "" {
+			req.Header.Set("X-Request-ID", id)
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// A valid ID is propagated to the context, the logs and the response
+	w := get("/ok", "req-1")
+	assert.Equal(t, "req-1", w.Header().Get("X-Request-ID"))
+	assert.JSONEq(t, `{"data":"req-1"}`, w.Body.String())
+	records := logRecords(t, buf)
+	require.Len(t, records, 1)
+	assert.Equal(t, "req-1", records[0]["request_id"])
+
+	// Missing and unusable IDs are replaced with generated ones
+	w = get("/ok", "")
+	generated := w.Header().Get("X-Request-ID")
+	assert.Len(t, generated, 36)
+	w = get("/ok", "bad id")
+	assert.Len(t, w.Header().Get("X-Request-ID"), 36)
+	assert.NotEqual(t, generated, w.Header().Get("X-Request-ID"))
+	assert.Len(t, get("/ok", strings.Repeat("x", 129)).Header().Get("X-Request-ID"), 36)
+
+	// Error responses carry the ID in their body
+	w = get("/fail", "req-2")
+	assert.JSONEq(t, `{"error":"page must be greater than 0","request_id":"req-2"}`, w.Body.String())
+	w = get("/empty", "req-3")
+	assert.JSONEq(t, `{"request_id":"req-3"}`, w.Body.String())
+}
+
+func TestRecover(t *testing.T) {
+	buf := captureLogs(t)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middleware.RequestID(), middleware.Recover())
+	router.GET("/panic", func(c *gin.Context) {
+		panic("boom")
+	})
+	before := testutil.ToFloat64(metrics.Panics.WithLabelValues("/panic"))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/panic", nil)
+	req.Header.Set("X-Request-ID", "req-1")
+	router.ServeHTTP(w, req)
+
+	// The client gets the usual error body with a code and the request ID
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.JSONEq(t, `{"error":"internal server error","code":"internal_error","request_id":"req-1"}`, w.Body.String())
+	assert.Equal(t, before+1, testutil.ToFloat64(metrics.Panics.WithLabelValues("/panic")))
+
+	// The panic is logged with its stack trace, starting where it was raised
+	records := logRecords(t, buf)
+	require.Len(t, records, 1)
+	assert.Equal(t, "ERROR", records[0]["level"])
+	assert.Equal(t, "Panic recovered", records[0]["msg"])
+	assert.Equal(t, "boom", records[0]["panic"])
+	assert.Equal(t, "req-1", records[0]["request_id"])
+	stack := records[0]["stack"].([]interface{})
+	require.NotEmpty(t, stack)
+	top := stack[0].(map[string]interface{})
+	assert.Contains(t, top["function"], "TestRecover")
+	assert.True(t, strings.HasSuffix(top["file"].(string), "logging_test.go"))
+}
+
+func TestAccessLoggerUpdate(t *testing.T) {
+	buf := captureLogs(t)
+
+	gin.SetMode(gin.TestMode)
+	accessLog := middleware.NewAccessLogger(config.AccessLogConfig{})
+	router := gin.New()
+	router.Use(accessLog.Handler())
+	router.GET("/health", func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{}) })
+	get := func() {
+		req, _ := http.NewRequest("GET", "/health", nil)
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	get()
+	assert.Len(t, logRecords(t, buf), 1)
+
+	// Updates apply to the requests handled next
+	buf.Reset()
+	accessLog.Update(config.AccessLogConfig{Exclude: []string{"/health"}})
+	get()
+	assert.Empty(t, buf.String())
+}
+
+func TestLogLevelEndpoint(t *testing.T) {
+	level := new(slog.LevelVar)
+	level.Set(slog.LevelInfo)
+	var buf bytes.Buffer
+	logger, err := logging.New(&buf, level, logging.FormatJSON)
+	require.NoError(t, err)
+	previous := slog.Default()
+	slog.SetDefault(logger)
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/admin/loglevel", handlers.GetLogLevel(level))
+	router.PUT("/admin/loglevel", handlers.SetLogLevel(level))
+	send := func(method, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, "/admin/loglevel", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.JSONEq(t, `{"level":"info"}`, send("GET", "").Body.String())
+	slog.Debug("hidden")
+
+	// Debug records are logged from the moment the level is lowered
+	w := send("PUT", `{"level":"DEBUG"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"level":"debug"}`, w.Body.String())
+	slog.Debug("shown")
+	records := logRecords(t, &buf)
+	require.Len(t, records, 2)
+	assert.Equal(t, "Log level changed", records[0]["msg"])
+	assert.Equal(t, "info", records[0]["old"])
+	assert.Equal(t, "debug", records[0]["new"])
+	assert.Equal(t, "shown", records[1]["msg"])
+
+	assert.Equal(t, http.StatusBadRequest, send("PUT", `{"level":"verbose"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, send("PUT", `{}`).Code)
+	assert.Equal(t, slog.LevelDebug, level.Level())
+}
+
+func TestAccessLogSamplesEveryRouteButErrorsAndSlowRequests(t *testing.T) {
+	buf := captureLogs(t)
+
+	gin.SetMode(gin.TestMode)
+	accessLog := middleware.NewAccessLogger(config.AccessLogConfig{SampleRoutes: []string{"*"}, SampleRate: 0, SlowThreshold: 20 * time.Millisecond})
+	router := gin.New()
+	router.Use(accessLog.Handler())
+	router.GET("/fast", func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{}) })
+	router.GET("/slow", func(c *gin.Context) {
+		time.Sleep(25 * time.Millisecond)
+		c.JSON(http.StatusOK, gin.H{})
+	})
+	router.GET("/fail", func(c *gin.Context) { c.JSON(http.StatusNotFound, gin.H{}) })
+	get := func(path string) {
+		req, _ := http.NewRequest("GET", path, nil)
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	get("/fast")
+	get("/slow")
+	get("/fail")
+	records := logRecords(t, buf)
+	require.Len(t, records, 2)
+	assert.Equal(t, "/slow", records[0]["path"])
+	assert.Equal(t, "/fail", records[1]["path"])
+	assert.NotContains(t, records[0], "sample_rate")
+
+	// Sampled records carry the rate they were sampled at
+	buf.Reset()
+	accessLog.Update(config.AccessLogConfig{SampleRoutes: []string{"*"}, SampleRate: 1})
+	get("/fast")
+	records = logRecords(t, buf)
+	require.Len(t, records, 1)
+	assert.Equal(t, float64(1), records[0]["sample_rate"])
+}
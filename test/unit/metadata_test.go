@@ -0,0 +1,180 @@
+package unit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yashjain/konnect/internal/auth"
+	"github.com/yashjain/konnect/internal/handlers"
+	"github.com/yashjain/konnect/internal/middleware"
+	"github.com/yashjain/konnect/internal/models"
+	"github.com/yashjain/konnect/pkg/types"
+	"github.com/yashjain/konnect/pkg/utils"
+)
+
+func TestValidateMetadata(t *testing.T) {
+	tooMany := map[string]string{}
+	for i := 0; i <= utils.MaxMetadataKeys; i++ {
+		tooMany["key-"+strings.Repeat("a", i)] = "value"
+	}
+	tooLarge := map[string]string{}
+	for i := 0; i < 20; i++ {
+		tooLarge["key"+strings.Repeat("a", i)] = strings.Repeat("x", utils.MaxMetadataValueLength)
+	}
+
+	tests := map[string]struct {
+		metadata map[string]string
+		invalid  bool
+	}{
+		"nil":            {metadata: nil},
+		"empty":          {metadata: map[string]string{}},
+		"valid":          {metadata: map[string]string{"team": "payments", "cost_center": "cc-42", "repo-url": "https://example.com"}},
+		"empty value":    {metadata: map[string]string{"team": ""}},
+		"uppercase key":  {metadata: map[string]string{"Team": "payments"}, invalid: true},
+		"dotted key":     {metadata: map[string]string{"team.name": "payments"}, invalid: true},
+		"quoted key":     {metadata: map[string]string{`te"am`: "payments"}, invalid: true},
+		"leading digit":  {metadata: map[string]string{"1team": "payments"}, invalid: true},
+		"empty key":      {metadata: map[string]string{"": "payments"}, invalid: true},
+		"long key":       {metadata: map[string]string{strings.Repeat("k", utils.MaxMetadataKeyLength+1): "v"}, invalid: true},
+		"long value":     {metadata: map[string]string{"team": strings.Repeat("x", utils.MaxMetadataValueLength+1)}, invalid: true},
+		"too many keys":  {metadata: tooMany, invalid: true},
+		"too many bytes": {metadata: tooLarge, invalid: true},
+	}
+
+	for name, tt := range tests {
+		err := utils.ValidateMetadata(tt.metadata)
+		if tt.invalid {
+			assert.ErrorIs(t, err, utils.ErrInvalidMetadata, name)
+		} else {
+			assert.NoError(t, err, name)
+		}
+	}
+}
+
+func TestGetMetadataFilter(t *testing.T) {
+	tests := []struct {
+		query    string
+		expected map[string]string
+		invalid  bool
+	}{
+		{query: ""},
+		{query: "?tag=core&filter=name==payments"},
+		{query: "?metadata.team=payments&metadata.tier=1", expected: map[string]string{"team": "payments", "tier": "1"}},
+		{query: "?metadata.repo_url=https%3A%2F%2Fexample.com", expected: map[string]string{"repo_url": "https://example.com"}},
+		{query: "?metadata.team=", invalid: true},
+		{query: "?metadata.team=a&metadata.team=b", invalid: true},
+		{query: "?metadata.=payments", invalid: true},
+		{query: "?metadata.Team=payments", invalid: true},
+	}
+
+	for _, tt := range tests {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request, _ = http.NewRequest("GET", "/services"+tt.query, nil)
+		filter, err := utils.GetMetadataFilter(c)
+		if tt.invalid {
+			assert.ErrorIs(t, err, utils.ErrInvalidMetadata, tt.query)
+			continue
+		}
+		require.NoError(t, err, tt.query)
+		assert.Equal(t, tt.expected, filter, tt.query)
+	}
+}
+
+func TestSQLiteMetadata(t *testing.T) {
+	store := openSQLiteStore(t)
+	ctx := context.Background()
+	const orgID = "00000000-0000-0000-0000-000000000001"
+	p := auth.Principal{OrgID: orgID}
+
+	require.NoError(t, store.CreateService(ctx, &models.Service{ID: "svc-1", OrgID: orgID, Name: "Card Payments", Slug: "card-payments", Visibility: models.VisibilityPublic, Metadata: map[string]string{"team": "payments", "tier": "1"}}))
+	require.NoError(t, store.CreateService(ctx, &models.Service{ID: "svc-2", OrgID: orgID, Name: "Payouts", Slug: "payouts", Visibility: models.VisibilityPublic, Metadata: map[string]string{"team": "payments", "tier": "2"}}))
+	require.NoError(t, store.CreateService(ctx, &models.Service{ID: "svc-3", OrgID: orgID, Name: "Identity", Slug: "identity", Visibility: models.VisibilityPublic}))
+
+	service, err := store.GetServiceByID(ctx, orgID, "svc-3")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{}, service.Metadata)
+
+	list := func(metadata map[string]string) []string {
+		services, total, err := store.GetServices(ctx, p, types.PaginationParams{Page: 1, PageSize: 10, Metadata: metadata})
+		require.NoError(t, err)
+		assert.Equal(t, len(services), total)
+		var ids []string
+		for _, s := range services {
+			ids = append(ids, s.ID)
+		}
+		return ids
+	}
+	assert.ElementsMatch(t, []string{"svc-1", "svc-2"}, list(map[string]string{"team": "payments"}))
+	assert.ElementsMatch(t, []string{"svc-2"}, list(map[string]string{"team": "payments", "tier": "2"}))
+	assert.Empty(t, list(map[string]string{"team": "identity"}))
+
+	// Updates keep the metadata unless given new metadata, which replaces it
+	_, err = store.UpdateService(ctx, orgID, "svc-2", &models.Service{Name: "Payouts", Slug: "payouts"})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"svc-1", "svc-2"}, list(map[string]string{"team": "payments"}))
+	_, err = store.UpdateService(ctx, orgID, "svc-2", &models.Service{Name: "Payouts", Slug: "payouts", Metadata: map[string]string{"team": "treasury"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"svc-1"}, list(map[string]string{"team": "payments"}))
+	service, err = store.GetServiceByID(ctx, orgID, "svc-2")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"team": "treasury"}, service.Metadata)
+
+	// Versions are filtered on their own metadata
+	require.NoError(t, store.CreateVersion(ctx, orgID, &models.Version{ID: "ver-1", ServiceID: "svc-1", Semver: "1.0.0", Status: models.VersionReleased, Metadata: map[string]string{"commit": "4f2a9c1"}}))
+	require.NoError(t, store.CreateVersion(ctx, orgID, &models.Version{ID: "ver-2", ServiceID: "svc-1", Semver: "1.1.0", Status: models.VersionReleased}))
+	versions, total, err := store.GetVersions(ctx, orgID, "svc-1", types.PaginationParams{Page: 1, PageSize: 10, Metadata: map[string]string{"commit": "4f2a9c1"}})
+	require.NoError(t, err)
+	assert.Equal(t, 1, total)
+	require.Len(t, versions, 1)
+	assert.Equal(t, map[string]string{"commit": "4f2a9c1"}, versions[0].Metadata)
+
+	_, err = store.UpdateVersion(ctx, orgID, "svc-1", "ver-1", &models.Version{Status: models.VersionDeprecated})
+	require.NoError(t, err)
+	version, err := store.GetVersion(ctx, orgID, "svc-1", "ver-1")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"commit": "4f2a9c1"}, version.Metadata)
+}
+
+func TestMetadataHandlers(t *testing.T) {
+	captureLogs(t)
+	store := openSQLiteStore(t)
+	const orgID = "00000000-0000-0000-0000-000000000001"
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) { middleware.SetPrincipal(c, auth.Principal{OrgID: orgID}) })
+	router.GET("/services", handlers.GetServices(store))
+	router.POST("/services", handlers.CreateService(store))
+	router.POST("/services/:id/versions", handlers.CreateVersion(store, store))
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("POST", "/services", `{"name": "Ledger", "slug": "ledger", "metadata": {"team": "finance", "cost_center": "cc-7"}}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+	assert.Contains(t, w.Body.String(), `"metadata":{"cost_center":"cc-7","team":"finance"}`)
+	w = do("POST", "/services", `{"name": "Vault", "slug": "vault"}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+	assert.Contains(t, w.Body.String(), `"metadata":{}`)
+
+	assert.Equal(t, http.StatusBadRequest, do("POST", "/services", `{"name": "Bad", "slug": "bad", "metadata": {"Team": "finance"}}`).Code)
+	assert.Equal(t, http.StatusBadRequest, do("POST", "/services", `{"name": "Bad", "slug": "bad", "metadata": {"team": 7}}`).Code)
+	assert.Equal(t, http.StatusBadRequest, do("POST", "/services/6f1c2f4e-0000-4000-8000-000000000001/versions", `{"semver": "9.0.0", "status": "draft", "metadata": {"": "x"}}`).Code)
+
+	w = do("GET", "/services?metadata.team=finance", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"slug":"ledger"`)
+	assert.NotContains(t, w.Body.String(), `"slug":"vault"`)
+	assert.Equal(t, http.StatusBadRequest, do("GET", "/services?metadata.team.name=finance", "").Code)
+}
@@ -0,0 +1,107 @@
+package unit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/ugorji/go/codec"
+
+	"github.com/yashjain/konnect/internal/middleware"
+	"github.com/yashjain/konnect/internal/render"
+)
+
+func TestNegotiate(t *testing.T) {
+	captureLogs(t)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middleware.RequestID(), middleware.Negotiate(render.MsgPack{}, render.XML{}), middleware.Problems())
+	router.GET("/service", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"data": []gin.H{{
+			"name":           "Collect <Money>",
+			"versions_count": 2,
+			"score":          1.5,
+			"public":         true,
+			"deleted_at":     nil,
+			"tags":           []string{"billing"},
+			"facets":         gin.H{"2024": 1},
+		}}})
+	})
+	router.GET("/missing", func(c *gin.Context) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Service not found"})
+	})
+	router.GET("/export", func(c *gin.Context) {
+		c.Data(http.StatusOK, "text/csv", []byte("id,name\n"))
+	})
+	get := func(path, accept string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		req.Header.Set("X-Request-ID", "req-1")
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// JSON is served unless another supported media type is preferred
+	for _, accept := range []string{"", "*/*", "text/html", "application/json, application/xml", "application/xml;q=0.5, application/json"} {
+		w := get("/service", accept)
+		assert.Contains(t, w.Header().Get("Content-Type"), "application/json", accept)
+		assert.Equal(t, "Accept", w.Header().Get("Vary"))
+	}
+
+	// XML keeps the document's order, escapes text and leaves nulls out
+	w := get("/service", "application/json;q=0.9, application/xml")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/xml; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>`+"\n"+
+		`<response><data><item><facets><entry key="2024">1</entry></facets><name>Collect &lt;Money&gt;</name>`+
+		`<public>true</public><score>1.5</score><tags><item>billing</item></tags><versions_count>2</versions_count>`+
+		`</item></data></response>`, w.Body.String())
+
+	// Problem details get their own XML type
+	w = get("/missing", "text/xml")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, "application/problem+xml; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), `<problem xmlns="urn:ietf:rfc:7807"><type>about:blank</type><title>Not Found</title><status>404</status>`)
+
+	// MessagePack keeps integers as integers
+	w = get("/service", "application/msgpack")
+	assert.Equal(t, render.MediaTypeMsgPack, w.Header().Get("Content-Type"))
+	doc := decodeMsgPack(t, w)
+	service := doc["data"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, "Collect <Money>", service["name"])
+	assert.Equal(t, int64(2), service["versions_count"])
+	assert.Equal(t, 1.5, service["score"])
+	assert.Equal(t, true, service["public"])
+	assert.Nil(t, service["deleted_at"])
+
+	w = get("/missing", "application/x-msgpack")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	doc = decodeMsgPack(t, w)
+	assert.Equal(t, "not_found", doc["code"])
+	assert.Equal(t, "req-1", doc["request_id"])
+
+	// Other formats are written as they are
+	w = get("/export", "application/xml")
+	assert.Equal(t, "text/csv", w.Header().Get("Content-Type"))
+	assert.Equal(t, "id,name\n", w.Body.String())
+}
+
+// decodeMsgPack decodes a MessagePack map, with signed integers and strings as strings
+func decodeMsgPack(t *testing.T, w *httptest.ResponseRecorder) map[string]interface{} {
+	t.Helper()
+	h := &codec.MsgpackHandle{}
+	h.MapType = reflect.TypeOf(map[string]interface{}(nil))
+	h.SignedInteger = true
+	h.RawToString = true
+	var doc map[string]interface{}
+	require.NoError(t, codec.NewDecoderBytes(w.Body.Bytes(), h).Decode(&doc))
+	return doc
+}
@@ -0,0 +1,149 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yashjain/konnect/internal/auth"
+	"github.com/yashjain/konnect/internal/handlers"
+	"github.com/yashjain/konnect/internal/middleware"
+	"github.com/yashjain/konnect/internal/models"
+	"github.com/yashjain/konnect/internal/outbox"
+)
+
+// sentEmail is an email recorded by fakeMailer
+type sentEmail struct {
+	to, subject, body string
+}
+
+// fakeMailer records the emails it is asked to send, failing while fail is set
+type fakeMailer struct {
+	mu   sync.Mutex
+	sent []sentEmail
+	fail bool
+}
+
+func (m *fakeMailer) Send(ctx context.Context, to, subject, body string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.fail {
+		return errors.New("connection refused")
+	}
+	m.sent = append(m.sent, sentEmail{to, subject, body})
+	return nil
+}
+
+func (m *fakeMailer) recipients() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var to []string
+	for _, e := range m.sent {
+		to = append(to, e.to)
+	}
+	return to
+}
+
+func TestDeprecationEmails(t *testing.T) {
+	captureLogs(t)
+	t.Setenv("SMTP_HOST", "smtp.example.com")
+	store := openSQLiteStore(t)
+	ctx := context.Background()
+	const orgID = "00000000-0000-0000-0000-000000000001"
+	const serviceID = "6f1c2f4e-0000-4000-8000-000000000003"
+
+	// Alice owns the service, Bob through his team; Carol subscribes and Dave opts out
+	users := map[string]string{}
+	for _, name := range []string{"alice", "bob", "carol", "dave", "erin"} {
+		user := models.User{ID: "user-" + name, OrgID: orgID, Email: name + "@example.com", Name: name}
+		require.NoError(t, store.CreateUser(ctx, &user, ""))
+		users[name] = user.ID
+	}
+	require.NoError(t, store.CreateTeam(ctx, &models.Team{ID: "team-api", OrgID: orgID, Name: "API"}))
+	_, err := store.AddTeamMember(ctx, orgID, "team-api", users["bob"])
+	require.NoError(t, err)
+	for _, acl := range []models.ServiceACL{
+		{ID: "acl-alice", ServiceID: serviceID, SubjectType: models.SubjectUser, SubjectID: users["alice"], Permission: models.PermissionWrite},
+		{ID: "acl-team", ServiceID: serviceID, SubjectType: models.SubjectTeam, SubjectID: "team-api", Permission: models.PermissionWrite},
+		{ID: "acl-dave", ServiceID: serviceID, SubjectType: models.SubjectUser, SubjectID: users["dave"], Permission: models.PermissionWrite},
+		{ID: "acl-erin", ServiceID: serviceID, SubjectType: models.SubjectUser, SubjectID: users["erin"], Permission: models.PermissionRead},
+	} {
+		require.NoError(t, store.CreateServiceACL(ctx, orgID, &acl))
+	}
+
+	gin.SetMode(gin.TestMode)
+	as := func(userID string) *gin.Engine {
+		router := gin.New()
+		router.Use(func(c *gin.Context) {
+			middleware.SetPrincipal(c, auth.Principal{OrgID: orgID, UserID: userID})
+		})
+		router.GET("/notifications/preferences", handlers.GetNotificationPreferences(store))
+		router.PUT("/notifications/preferences", handlers.SetNotificationPreferences(store))
+		router.PUT("/services/:id/subscription", handlers.SubscribeToService(store, store))
+		router.DELETE("/services/:id/subscription", handlers.UnsubscribeFromService(store))
+		return router
+	}
+	do := func(userID, method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		as(userID).ServeHTTP(w, req)
+		return w
+	}
+
+	// Preferences and subscriptions belong to users
+	assert.Equal(t, http.StatusForbidden, do("", "GET", "/notifications/preferences", "").Code)
+	w := do(users["dave"], "GET", "/notifications/preferences", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"user_id": "user-dave", "deprecations": true, "updated_at": "0001-01-01T00:00:00Z"}`, w.Body.String())
+	assert.Equal(t, http.StatusBadRequest, do(users["dave"], "PUT", "/notifications/preferences", `{}`).Code)
+	require.Equal(t, http.StatusOK, do(users["dave"], "PUT", "/notifications/preferences", `{"deprecations": false}`).Code)
+	require.Equal(t, http.StatusOK, do(users["carol"], "PUT", "/services/"+serviceID+"/subscription", "").Code)
+	require.Equal(t, http.StatusOK, do(users["carol"], "PUT", "/services/"+serviceID+"/subscription", "").Code)
+	assert.Equal(t, http.StatusNotFound, do(users["carol"], "PUT", "/services/6f1c2f4e-0000-4000-8000-00000000dead/subscription", "").Code)
+	require.Equal(t, http.StatusOK, do(users["erin"], "PUT", "/services/"+serviceID+"/subscription", "").Code)
+	require.Equal(t, http.StatusOK, do(users["erin"], "DELETE", "/services/"+serviceID+"/subscription", "").Code)
+	assert.Equal(t, http.StatusNotFound, do(users["erin"], "DELETE", "/services/"+serviceID+"/subscription", "").Code)
+
+	// Deprecating a version emails its owners and subscribers once, after a failed attempt
+	mailer := &fakeMailer{fail: true}
+	notifier := outbox.NewEmailNotifier(store, store, store, mailer, "https://portal.example.com/services/{slug}", time.Second, outbox.RetryPolicy{MaxAttempts: 3})
+	version := &models.Version{ID: "ver-old", ServiceID: serviceID, Semver: "0.9.0", Status: models.VersionReleased, Changelog: "Legacy API"}
+	require.NoError(t, store.CreateVersion(ctx, orgID, version))
+	_, err = store.UpdateVersion(ctx, orgID, serviceID, version.ID, &models.Version{Status: models.VersionDeprecated, Changelog: "Legacy API"})
+	require.NoError(t, err)
+	_, err = store.UpdateVersion(ctx, orgID, serviceID, version.ID, &models.Version{Status: models.VersionDeprecated, Changelog: "Use 2.0"})
+	require.NoError(t, err)
+
+	sent, err := notifier.Flush(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, sent)
+	mailer.fail = false
+	sent, err = notifier.Flush(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 3, sent)
+	assert.ElementsMatch(t, []string{"alice@example.com", "bob@example.com", "carol@example.com"}, mailer.recipients())
+	email := mailer.sent[0]
+	assert.Equal(t, "Notifications 0.9.0 is deprecated", email.subject)
+	assert.Contains(t, email.body, "Use 2.0")
+	assert.Contains(t, email.body, "https://portal.example.com/services/notifications")
+
+	sent, err = notifier.Flush(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, sent)
+
+	// Versions created deprecated are announced too
+	require.NoError(t, store.CreateVersion(ctx, orgID, &models.Version{ID: "ver-older", ServiceID: serviceID, Semver: "0.8.0", Status: models.VersionDeprecated}))
+	sent, err = notifier.Flush(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 3, sent)
+}
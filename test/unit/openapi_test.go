@@ -0,0 +1,95 @@
+package unit
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yashjain/konnect/internal/config"
+	"github.com/yashjain/konnect/internal/handlers"
+	"github.com/yashjain/konnect/internal/openapi"
+)
+
+func TestOpenAPI(t *testing.T) {
+	router := setupLinksRouter(nil)
+	router.POST("/api/v1/services/:id/acl", handlers.CreateServiceACL(nil))
+	router.POST("/auth/login", handlers.Login(config.AuthConfig{}, nil, nil))
+
+	doc, err := openapi.Generate(handlers.APISpec(false), router.Routes())
+	require.NoError(t, err)
+	legacy, err := openapi.Generate(handlers.APISpec(true), router.Routes())
+	require.NoError(t, err)
+	router.GET("/openapi.json", handlers.OpenAPI(doc))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/openapi.json", nil)
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	var spec struct {
+		OpenAPI    string                                       `json:"openapi"`
+		Paths      map[string]map[string]map[string]interface{} `json:"paths"`
+		Components struct {
+			Schemas map[string]map[string]interface{} `json:"schemas"`
+		} `json:"components"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &spec))
+	assert.Equal(t, "3.1.0", spec.OpenAPI)
+
+	// Paths and path parameters come from the routes; v1 is deprecated
+	assert.Len(t, spec.Paths, 7)
+	version := spec.Paths["/api/v1/services/{id}/versions/{version_id}"]["get"]
+	require.NotNil(t, version)
+	assert.Equal(t, "GetVersionV1", version["operationId"])
+	assert.Equal(t, true, version["deprecated"])
+	params := version["parameters"].([]interface{})
+	require.Len(t, params, 3)
+	assert.Equal(t, "id", params[0].(map[string]interface{})["name"])
+	assert.Equal(t, "version_id", params[1].(map[string]interface{})["name"])
+
+	// Pages are documented with the type of their data
+	list := spec.Paths["/api/v1/services"]["get"]["responses"].(map[string]interface{})["200"]
+	assert.JSONEq(t, `{"description": "OK", "content": {
+		"application/json": {"schema": {"allOf": [
+			{"$ref": "#/components/schemas/PaginatedResponse"},
+			{"type": "object", "properties": {"data": {"type": "array", "items": {"$ref": "#/components/schemas/Service"}}}}
+		]}},
+		"application/vnd.api+json": {"schema": {}}
+	}}`, toJSON(t, list))
+
+	// Errors are problem details
+	notFound := version["responses"].(map[string]interface{})["404"]
+	assert.JSONEq(t, `{"description": "Not Found", "content": {
+		"application/problem+json": {"schema": {"$ref": "#/components/schemas/Problem"}}
+	}}`, toJSON(t, notFound))
+
+	// Binding rules become constraints
+	acl := spec.Components.Schemas["ServiceACL"]
+	assert.ElementsMatch(t, []interface{}{"subject_type", "subject_id", "permission"}, acl["required"])
+	assert.JSONEq(t, `{"type": "string", "enum": ["read", "write"]}`, toJSON(t, acl["properties"].(map[string]interface{})["permission"]))
+	login := spec.Components.Schemas["LoginRequest"]
+	assert.JSONEq(t, `{"type": "string", "format": "email"}`, toJSON(t, login["properties"].(map[string]interface{})["email"]))
+	service := spec.Components.Schemas["Service"]["properties"].(map[string]interface{})
+	assert.JSONEq(t, `{"type": "string", "enum": ["public", "private"]}`, toJSON(t, service["visibility"]))
+	assert.JSONEq(t, `{"type": ["string", "null"], "format": "date-time"}`, toJSON(t, service["deleted_at"]))
+
+	// Legacy errors are documented as they are sent
+	assert.Contains(t, legacy.Paths["/api/v1/services"]["get"].Responses["400"].Content, "application/json")
+
+	// Every route must be documented
+	router.GET("/undocumented", func(*gin.Context) {})
+	_, err = openapi.Generate(handlers.APISpec(false), router.Routes())
+	assert.ErrorContains(t, err, "GET /undocumented")
+}
+
+// toJSON marshals v, for comparing parts of decoded documents with JSONEq
+func toJSON(t *testing.T, v interface{}) string {
+	t.Helper()
+	data, err := json.Marshal(v)
+	require.NoError(t, err)
+	return string(data)
+}
@@ -0,0 +1,140 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yashjain/konnect/internal/database"
+	"github.com/yashjain/konnect/internal/models"
+	"github.com/yashjain/konnect/internal/outbox"
+	"github.com/yashjain/konnect/internal/webhook"
+)
+
+// openOutboxStore opens an in-memory SQLite store that records change events
+func openOutboxStore(t *testing.T) *database.Store {
+	t.Setenv("OUTBOX_WEBHOOK_URL", "http://127.0.0.1/unused")
+	return openSQLiteStore(t)
+}
+
+func TestSQLiteOutboxRecordsChanges(t *testing.T) {
+	store := openOutboxStore(t)
+	ctx := context.Background()
+	const orgID = "00000000-0000-0000-0000-000000000001"
+
+	// A change that rolls back records no event
+	service := &models.Service{ID: "svc-1", OrgID: orgID, Name: "Payments Gateway", Slug: "payments-gateway", Visibility: models.VisibilityPrivate}
+	err := store.CreateService(ctx, service, models.ServiceACL{ID: "acl-1", SubjectType: "user", SubjectID: "user-1", Permission: "admin"})
+	require.Error(t, err)
+	events, err := store.GetPendingEvents(ctx, 10)
+	require.NoError(t, err)
+	assert.Empty(t, events)
+
+	require.NoError(t, store.CreateService(ctx, service))
+	require.NoError(t, store.CreateVersion(ctx, orgID, &models.Version{ID: "ver-1", ServiceID: "svc-1", Semver: "1.0.0", Status: "draft"}))
+	service.Description = "Card payments"
+	_, err = store.UpdateService(ctx, orgID, "svc-1", service)
+	require.NoError(t, err)
+	_, err = store.DeleteService(ctx, orgID, "svc-1")
+	require.NoError(t, err)
+
+	// Deleting again changes nothing and records nothing
+	_, err = store.DeleteService(ctx, orgID, "svc-1")
+	require.NoError(t, err)
+
+	events, err = store.GetPendingEvents(ctx, 10)
+	require.NoError(t, err)
+	require.Len(t, events, 4)
+	var eventTypes []string
+	for _, e := range events {
+		assert.Equal(t, orgID, e.OrgID)
+		eventTypes = append(eventTypes, e.Type)
+	}
+	assert.Equal(t, []string{models.EventServiceCreated, models.EventVersionCreated, models.EventServiceUpdated, models.EventServiceDeleted}, eventTypes)
+	assert.Equal(t, "ver-1", events[1].SubjectID)
+
+	// Payloads hold the row as it was after the change
+	var updated, deleted models.Service
+	require.NoError(t, json.Unmarshal(events[2].Payload, &updated))
+	assert.Equal(t, "Card payments", updated.Description)
+	assert.Equal(t, 1, updated.VersionsCount)
+	require.NoError(t, json.Unmarshal(events[3].Payload, &deleted))
+	assert.NotNil(t, deleted.DeletedAt)
+}
+
+func TestSQLiteOutboxDisabled(t *testing.T) {
+	store := openSQLiteStore(t)
+	ctx := context.Background()
+
+	require.NoError(t, store.CreateService(ctx, &models.Service{ID: "svc-1", OrgID: "00000000-0000-0000-0000-000000000001", Name: "Payments Gateway", Slug: "payments-gateway", Visibility: models.VisibilityPublic}))
+	events, err := store.GetPendingEvents(ctx, 10)
+	require.NoError(t, err)
+	assert.Empty(t, events)
+}
+
+func TestOutboxRelay(t *testing.T) {
+	store := openOutboxStore(t)
+	ctx := context.Background()
+	const orgID = "00000000-0000-0000-0000-000000000001"
+	const secret = "outbox-secret"
+
+	for _, svc := range []*models.Service{
+		{ID: "svc-1", OrgID: orgID, Name: "One", Slug: "one", Visibility: models.VisibilityPublic},
+		{ID: "svc-2", OrgID: orgID, Name: "Two", Slug: "two", Visibility: models.VisibilityPublic},
+		{ID: "svc-3", OrgID: orgID, Name: "Three", Slug: "three", Visibility: models.VisibilityPublic},
+	} {
+		require.NoError(t, store.CreateService(ctx, svc))
+	}
+
+	var mu sync.Mutex
+	var received []string
+	failNext := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if err := webhook.Verify(secret, r.Header.Get(webhook.HeaderSignature), body, webhook.DefaultTolerance, time.Now(), "", nil); err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		if failNext {
+			failNext = false
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var event models.Event
+		_ = json.Unmarshal(body, &event)
+		assert.Equal(t, event.ID, r.Header.Get(webhook.HeaderID))
+		received = append(received, event.SubjectID)
+	}))
+	defer server.Close()
+
+	relay := outbox.NewRelay(store, outbox.NewWebhookPublisher(server.URL, secret), time.Second, 2)
+
+	// A failed delivery stops the relay and keeps the event for a retry
+	delivered, err := relay.Flush(ctx)
+	require.Error(t, err)
+	assert.Zero(t, delivered)
+	events, err := store.GetPendingEvents(ctx, 10)
+	require.NoError(t, err)
+	require.Len(t, events, 3)
+	assert.Equal(t, 1, events[0].Attempts)
+
+	// The retry delivers everything in order, across batches, and empties the outbox
+	delivered, err = relay.Flush(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 3, delivered)
+	assert.Equal(t, []string{"svc-1", "svc-2", "svc-3"}, received)
+	events, err = store.GetPendingEvents(ctx, 10)
+	require.NoError(t, err)
+	assert.Empty(t, events)
+}
@@ -0,0 +1,159 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yashjain/konnect/internal/auth"
+	"github.com/yashjain/konnect/internal/handlers"
+	"github.com/yashjain/konnect/internal/middleware"
+	"github.com/yashjain/konnect/internal/models"
+	"github.com/yashjain/konnect/pkg/types"
+	"github.com/yashjain/konnect/pkg/utils"
+)
+
+func TestNormalizeOwner(t *testing.T) {
+	tests := []struct {
+		owner    string
+		expected string
+		invalid  bool
+	}{
+		{owner: "", expected: ""},
+		{owner: "Payments Core", expected: "payments-core"},
+		{owner: "payments-core", expected: "payments-core"},
+		{owner: "Équipe Paiements", expected: "equipe-paiements"},
+		{owner: "Oncall@Example.com", expected: "oncall@example.com"},
+		{owner: "!!!", invalid: true},
+		{owner: strings.Repeat("a", utils.MaxOwnerTeamLength+1), invalid: true},
+		{owner: "oncall@", invalid: true},
+		{owner: "Oncall <oncall@example.com>", invalid: true},
+	}
+
+	for _, tt := range tests {
+		owner, err := utils.NormalizeOwner(tt.owner)
+		if tt.invalid {
+			assert.ErrorIs(t, err, utils.ErrInvalidOwner, tt.owner)
+			continue
+		}
+		require.NoError(t, err, tt.owner)
+		assert.Equal(t, tt.expected, owner, tt.owner)
+	}
+}
+
+func TestSQLiteOwners(t *testing.T) {
+	store := openSQLiteStore(t)
+	ctx := context.Background()
+	const orgID = "00000000-0000-0000-0000-000000000001"
+	p := auth.Principal{OrgID: orgID}
+	owner := func(s string) *string { return &s }
+
+	require.NoError(t, store.CreateService(ctx, &models.Service{ID: "svc-1", OrgID: orgID, Name: "Ledger", Slug: "ledger", Visibility: models.VisibilityPublic, OwnerTeam: owner("payments"), OwnerEmail: owner("payments@example.com")}))
+	require.NoError(t, store.CreateService(ctx, &models.Service{ID: "svc-2", OrgID: orgID, Name: "Payouts", Slug: "payouts", Visibility: models.VisibilityPublic, OwnerTeam: owner("payments"), OwnerEmail: owner("treasury@example.com")}))
+	require.NoError(t, store.CreateService(ctx, &models.Service{ID: "svc-3", OrgID: orgID, Name: "Vault", Slug: "vault", Visibility: models.VisibilityPublic}))
+
+	list := func(owner string) []string {
+		services, total, err := store.GetServices(ctx, p, types.PaginationParams{Page: 1, PageSize: 10, Owner: owner})
+		require.NoError(t, err)
+		assert.Equal(t, len(services), total)
+		var ids []string
+		for _, s := range services {
+			ids = append(ids, s.ID)
+		}
+		return ids
+	}
+	assert.ElementsMatch(t, []string{"svc-1", "svc-2"}, list("payments"))
+	assert.Equal(t, []string{"svc-2"}, list("treasury@example.com"))
+	assert.Empty(t, list("identity"))
+
+	// Services created without owners are unowned
+	service, err := store.GetServiceByID(ctx, orgID, "svc-3")
+	require.NoError(t, err)
+	require.NotNil(t, service.OwnerTeam)
+	require.NotNil(t, service.OwnerEmail)
+	assert.Empty(t, *service.OwnerTeam)
+	assert.Empty(t, *service.OwnerEmail)
+
+	// Owners left out of an update keep their value, and empty ones are cleared
+	_, err = store.UpdateService(ctx, orgID, "svc-2", &models.Service{Name: "Payouts", Slug: "payouts", OwnerTeam: owner("treasury")})
+	require.NoError(t, err)
+	service, err = store.GetServiceByID(ctx, orgID, "svc-2")
+	require.NoError(t, err)
+	assert.Equal(t, "treasury", *service.OwnerTeam)
+	assert.Equal(t, "treasury@example.com", *service.OwnerEmail)
+	assert.Equal(t, []string{"svc-1"}, list("payments"))
+
+	_, err = store.UpdateService(ctx, orgID, "svc-2", &models.Service{Name: "Payouts", Slug: "payouts", OwnerEmail: owner("")})
+	require.NoError(t, err)
+	service, err = store.GetServiceByID(ctx, orgID, "svc-2")
+	require.NoError(t, err)
+	assert.Equal(t, "treasury", *service.OwnerTeam)
+	assert.Empty(t, *service.OwnerEmail)
+	assert.Empty(t, list("treasury@example.com"))
+}
+
+func TestOwnerHandlers(t *testing.T) {
+	captureLogs(t)
+	store := openSQLiteStore(t)
+	const orgID = "00000000-0000-0000-0000-000000000001"
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) { middleware.SetPrincipal(c, auth.Principal{OrgID: orgID}) })
+	router.GET("/services", handlers.GetServices(store))
+	router.POST("/services", handlers.CreateService(store))
+	router.PUT("/services/:id", handlers.UpdateService(store, store))
+	router.GET("/teams/:team/services", handlers.GetTeamServices(store))
+	router.GET("/export/backstage", handlers.ExportBackstage(store, store))
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("POST", "/services", `{"name": "Ledger", "slug": "ledger", "owner_team": "Payments Core", "owner_email": "Oncall@Example.com"}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"owner_team":"payments-core","owner_email":"oncall@example.com"`)
+	var ledger models.Service
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &ledger))
+	w = do("POST", "/services", `{"name": "Vault", "slug": "vault"}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+	assert.Contains(t, w.Body.String(), `"owner_team":"","owner_email":""`)
+
+	assert.Equal(t, http.StatusBadRequest, do("POST", "/services", `{"name": "Bad", "slug": "bad", "owner_email": "not an email"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, do("POST", "/services", `{"name": "Bad", "slug": "bad", "owner_team": "---"}`).Code)
+
+	for _, path := range []string{"/services?owner=Payments%20Core", "/services?owner=oncall@example.com", "/teams/payments-core/services"} {
+		w = do("GET", path, "")
+		require.Equal(t, http.StatusOK, w.Code, path)
+		assert.Contains(t, w.Body.String(), `"slug":"ledger"`, path)
+		assert.NotContains(t, w.Body.String(), `"slug":"vault"`, path)
+	}
+	assert.Equal(t, http.StatusBadRequest, do("GET", "/services?owner=oncall@", "").Code)
+
+	// An update leaving owners out keeps them, like tags and metadata, and an
+	// empty owner clears it
+	w = do("PUT", "/services/"+ledger.ID, `{"name": "Ledger", "slug": "ledger", "description": "Double-entry ledger"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"owner_team":"payments-core","owner_email":"oncall@example.com"`)
+	w = do("PUT", "/services/"+ledger.ID, `{"name": "Ledger", "slug": "ledger", "owner_email": ""}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"owner_team":"payments-core","owner_email":""`)
+	assert.Equal(t, http.StatusBadRequest, do("PUT", "/services/"+ledger.ID, `{"name": "Ledger", "slug": "ledger", "owner_team": "---"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, do("GET", "/teams/---/services", "").Code)
+
+	// Backstage entities are owned by the service's team when it has one
+	w = do("GET", "/export/backstage?owner=group:default/platform", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "owner: group:default/payments-core")
+	assert.Contains(t, w.Body.String(), "owner: group:default/platform")
+}
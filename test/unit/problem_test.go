@@ -0,0 +1,81 @@
+package unit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/yashjain/konnect/internal/middleware"
+)
+
+func TestProblems(t *testing.T) {
+	captureLogs(t)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middleware.RequestID(), middleware.Problems(), middleware.Recover())
+	router.GET("/services/:id", func(c *gin.Context) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Service not found"})
+	})
+	router.GET("/locked", func(c *gin.Context) {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "too many failed attempts; try again later", "code": "locked_out"})
+	})
+	router.GET("/panic", func(c *gin.Context) {
+		panic("boom")
+	})
+	router.GET("/text", func(c *gin.Context) {
+		c.String(http.StatusBadRequest, "bad request")
+	})
+	router.GET("/ok", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"error": "not an error"})
+	})
+	jsonAPI := router.Group("/", middleware.JSONAPI())
+	jsonAPI.GET("/jsonapi", func(c *gin.Context) {
+		c.JSON(http.StatusConflict, gin.H{"error": "A service with this name or slug already exists"})
+	})
+	get := func(path, accept string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		req.Header.Set("X-Request-ID", "req-1")
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Errors become problem details with a code derived from their status
+	w := get("/services/42?render=html", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, middleware.MediaTypeProblem, w.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"type":"about:blank","title":"Not Found","status":404,"detail":"Service not found",
+		"instance":"/services/42","code":"not_found","request_id":"req-1"}`, w.Body.String())
+
+	// or the code they were given
+	w = get("/locked", "")
+	assert.JSONEq(t, `{"type":"about:blank","title":"Too Many Requests","status":429,
+		"detail":"too many failed attempts; try again later","instance":"/locked","code":"locked_out","request_id":"req-1"}`, w.Body.String())
+
+	w = get("/panic", "")
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.JSONEq(t, `{"type":"about:blank","title":"Internal Server Error","status":500,
+		"detail":"internal server error","instance":"/panic","code":"internal_error","request_id":"req-1"}`, w.Body.String())
+
+	// Other bodies are left alone
+	w = get("/text", "")
+	assert.Equal(t, "bad request", w.Body.String())
+	w = get("/ok", "")
+	assert.JSONEq(t, `{"error":"not an error"}`, w.Body.String())
+
+	// JSON:API requests get JSON:API errors, with the same code
+	w = get("/jsonapi", middleware.MediaTypeJSONAPI)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, middleware.MediaTypeJSONAPI, w.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"errors":[{"status":"409","code":"conflict","title":"Conflict",
+		"detail":"A service with this name or slug already exists"}]}`, w.Body.String())
+	w = get("/jsonapi", "")
+	assert.Equal(t, middleware.MediaTypeProblem, w.Header().Get("Content-Type"))
+}
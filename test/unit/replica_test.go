@@ -0,0 +1,189 @@
+package unit
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yashjain/konnect/internal/config"
+	"github.com/yashjain/konnect/internal/database"
+)
+
+const fakeOrgID = "00000000-0000-0000-0000-000000000001"
+
+// fakeDriver is a database whose statements all fail with err, or succeed when
+// it is nil, counting the statements it was sent. Queries find no rows.
+type fakeDriver struct {
+	mu      sync.Mutex
+	err     error
+	execs   int
+	queries int
+}
+
+func (d *fakeDriver) Open(string) (driver.Conn, error)             { return &fakeDriverConn{d: d}, nil }
+func (d *fakeDriver) Connect(context.Context) (driver.Conn, error) { return &fakeDriverConn{d: d}, nil }
+func (d *fakeDriver) Driver() driver.Driver                        { return d }
+
+// setErr makes later statements fail with err
+func (d *fakeDriver) setErr(err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.err = err
+}
+
+// counts returns how many statements and queries the database was sent
+func (d *fakeDriver) counts() (execs, queries int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.execs, d.queries
+}
+
+type fakeDriverConn struct {
+	d *fakeDriver
+}
+
+func (c *fakeDriverConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("not supported")
+}
+func (c *fakeDriverConn) Close() error              { return nil }
+func (c *fakeDriverConn) Begin() (driver.Tx, error) { return nil, errors.New("not supported") }
+
+func (c *fakeDriverConn) ExecContext(context.Context, string, []driver.NamedValue) (driver.Result, error) {
+	c.d.mu.Lock()
+	defer c.d.mu.Unlock()
+	c.d.execs++
+	if c.d.err != nil {
+		return nil, c.d.err
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (c *fakeDriverConn) QueryContext(context.Context, string, []driver.NamedValue) (driver.Rows, error) {
+	c.d.mu.Lock()
+	defer c.d.mu.Unlock()
+	c.d.queries++
+	if c.d.err != nil {
+		return nil, c.d.err
+	}
+	return fakeRows{}, nil
+}
+
+// fakeRows has no rows
+type fakeRows struct{}
+
+func (fakeRows) Columns() []string         { return []string{"n"} }
+func (fakeRows) Close() error              { return nil }
+func (fakeRows) Next([]driver.Value) error { return io.EOF }
+
+// newFakeStore returns a MySQL store on primary, reading from replica when it
+// is not nil, configured by cfg
+func newFakeStore(t *testing.T, cfg config.DatabaseConfig, primary, replica *fakeDriver) *database.Store {
+	t.Helper()
+	cfg.Driver = config.DriverMySQL
+	var replicaConnector driver.Connector
+	if replica != nil {
+		replicaConnector = replica
+	}
+	store, err := database.NewStore(cfg, primary, replicaConnector)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = store.Close() })
+	return store
+}
+
+// fakeRead reads through the store's read pool; it finds no rows when the
+// database answers
+func fakeRead(store *database.Store) error {
+	_, err := store.GetServiceByID(context.Background(), fakeOrgID, "svc-1")
+	return err
+}
+
+// fakeWrite writes through the store's primary
+func fakeWrite(store *database.Store) error {
+	_, err := store.DeleteGitHubRepository(context.Background(), fakeOrgID, "svc-1")
+	return err
+}
+
+func TestReplicaReads(t *testing.T) {
+	captureLogs(t)
+	unreachable := errors.New("dial tcp: connection refused")
+	rejected := &mysql.MySQLError{Number: 1146, Message: "Table doesn't exist"}
+
+	tests := []struct {
+		name string
+		// replicaErr is what every replica statement fails with
+		replicaErr error
+		// wantErr is what reads return
+		wantErr error
+		// wantReplica and wantPrimary count the queries each database is sent
+		// by two reads
+		wantReplica, wantPrimary int
+	}{
+		{"healthy replica serves reads", nil, sql.ErrNoRows, 2, 0},
+		// The first read falls back, then reads skip the replica while it is down
+		{"unreachable replica falls back to the primary", unreachable, sql.ErrNoRows, 1, 2},
+		// The replica is up and answered, so its error is the query's
+		{"errors from the replica are returned", rejected, rejected, 2, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			primary, replica := &fakeDriver{}, &fakeDriver{err: tt.replicaErr}
+			store := newFakeStore(t, config.DatabaseConfig{RetryAttempts: 1, ReplicaDownFor: time.Minute}, primary, replica)
+
+			for i := 0; i < 2; i++ {
+				assert.ErrorIs(t, fakeRead(store), tt.wantErr)
+			}
+
+			_, replicaQueries := replica.counts()
+			_, primaryQueries := primary.counts()
+			assert.Equal(t, tt.wantReplica, replicaQueries)
+			assert.Equal(t, tt.wantPrimary, primaryQueries)
+		})
+	}
+}
+
+func TestReplicaRecovers(t *testing.T) {
+	captureLogs(t)
+	primary, replica := &fakeDriver{}, &fakeDriver{err: errors.New("connection refused")}
+	store := newFakeStore(t, config.DatabaseConfig{RetryAttempts: 1, ReplicaDownFor: 20 * time.Millisecond}, primary, replica)
+
+	// While the replica is down reads skip it
+	assert.ErrorIs(t, fakeRead(store), sql.ErrNoRows)
+	replica.setErr(nil)
+	assert.ErrorIs(t, fakeRead(store), sql.ErrNoRows)
+	_, replicaQueries := replica.counts()
+	assert.Equal(t, 1, replicaQueries)
+
+	// Once ReplicaDownFor has passed, reads try the replica again
+	time.Sleep(30 * time.Millisecond)
+	assert.ErrorIs(t, fakeRead(store), sql.ErrNoRows)
+	_, replicaQueries = replica.counts()
+	_, primaryQueries := primary.counts()
+	assert.Equal(t, 2, replicaQueries)
+	assert.Equal(t, 2, primaryQueries)
+}
+
+func TestReplicaWritesGoToPrimary(t *testing.T) {
+	captureLogs(t)
+	for _, replicaErr := range []error{nil, errors.New("connection refused")} {
+		primary, replica := &fakeDriver{}, &fakeDriver{err: replicaErr}
+		store := newFakeStore(t, config.DatabaseConfig{RetryAttempts: 1, ReplicaDownFor: time.Minute}, primary, replica)
+
+		for i := 0; i < 3; i++ {
+			require.NoError(t, fakeWrite(store))
+		}
+		replicaExecs, replicaQueries := replica.counts()
+		primaryExecs, _ := primary.counts()
+		assert.Zero(t, replicaExecs)
+		assert.Zero(t, replicaQueries)
+		assert.Equal(t, 3, primaryExecs)
+	}
+}
@@ -0,0 +1,230 @@
+package unit
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yashjain/konnect/internal/auth"
+	"github.com/yashjain/konnect/internal/handlers"
+	"github.com/yashjain/konnect/internal/middleware"
+	"github.com/yashjain/konnect/internal/models"
+	"github.com/yashjain/konnect/internal/repository"
+	"github.com/yashjain/konnect/pkg/types"
+)
+
+// fakeServiceRepo is an in-memory repository.ServiceRepository and
+// repository.AccessRepository for exercising handlers without MySQL
+type fakeServiceRepo struct {
+	services map[string]models.Service
+	acls     []models.ServiceACL
+	err      error
+}
+
+func newFakeServiceRepo() *fakeServiceRepo {
+	return &fakeServiceRepo{services: make(map[string]models.Service)}
+}
+
+func (r *fakeServiceRepo) GetServices(ctx context.Context, p auth.Principal, params types.PaginationParams) ([]models.Service, int, error) {
+	if r.err != nil {
+		return nil, 0, r.err
+	}
+	var services []models.Service
+	for _, s := range r.services {
+		if s.OrgID == p.OrgID {
+			services = append(services, s)
+		}
+	}
+	return services, len(services), nil
+}
+
+func (r *fakeServiceRepo) SearchServices(ctx context.Context, p auth.Principal, params types.SearchParams) ([]models.Service, int, error) {
+	return r.GetServices(ctx, p, types.PaginationParams{Page: params.Page, PageSize: params.PageSize})
+}
+
+func (r *fakeServiceRepo) SearchFacets(ctx context.Context, p auth.Principal, params types.SearchParams, facets []string) (map[string][]types.FacetBucket, error) {
+	return nil, r.err
+}
+
+func (r *fakeServiceRepo) SuggestServices(ctx context.Context, p auth.Principal, prefix string, limit int) ([]models.ServiceSuggestion, error) {
+	return nil, r.err
+}
+
+func (r *fakeServiceRepo) GetServicesByIDs(ctx context.Context, p auth.Principal, ids []string) ([]models.Service, error) {
+	var services []models.Service
+	for _, id := range ids {
+		if s, ok := r.services[id]; ok && s.OrgID == p.OrgID {
+			services = append(services, s)
+		}
+	}
+	return services, r.err
+}
+
+func (r *fakeServiceRepo) CreateService(ctx context.Context, service *models.Service, grants ...models.ServiceACL) error {
+	r.services[service.ID] = *service
+	r.acls = append(r.acls, grants...)
+	return r.err
+}
+
+func (r *fakeServiceRepo) GetServiceByID(ctx context.Context, orgID, id string, opts ...types.ReadOptions) (*models.Service, error) {
+	s, ok := r.services[id]
+	if !ok || s.OrgID != orgID {
+		return nil, sql.ErrNoRows
+	}
+	return &s, nil
+}
+
+func (r *fakeServiceRepo) GetServiceBySlug(ctx context.Context, orgID, slug string, opts ...types.ReadOptions) (*models.Service, error) {
+	for _, s := range r.services {
+		if s.OrgID == orgID && s.Slug == slug {
+			return &s, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (r *fakeServiceRepo) UpdateService(ctx context.Context, orgID, id string, service *models.Service) (int64, error) {
+	if _, err := r.GetServiceByID(ctx, orgID, id); err != nil {
+		return 0, nil
+	}
+	service.ID, service.OrgID = id, orgID
+	r.services[id] = *service
+	return 1, nil
+}
+
+func (r *fakeServiceRepo) DeleteService(ctx context.Context, orgID, id string) (int64, error) {
+	if _, err := r.GetServiceByID(ctx, orgID, id); err != nil {
+		return 0, nil
+	}
+	delete(r.services, id)
+	return 1, nil
+}
+
+func (r *fakeServiceRepo) GetServiceVisibility(ctx context.Context, orgID, serviceID string) (string, error) {
+	s, err := r.GetServiceByID(ctx, orgID, serviceID)
+	if err != nil {
+		return "", err
+	}
+	return s.Visibility, nil
+}
+
+func (r *fakeServiceRepo) GetServicePermission(ctx context.Context, p auth.Principal, serviceID string) (string, error) {
+	for _, acl := range r.acls {
+		if acl.ServiceID == serviceID && acl.SubjectType == models.SubjectUser && acl.SubjectID == p.UserID {
+			return acl.Permission, nil
+		}
+	}
+	return "", nil
+}
+
+func (r *fakeServiceRepo) CreateServiceACL(ctx context.Context, orgID string, acl *models.ServiceACL) error {
+	r.acls = append(r.acls, *acl)
+	return nil
+}
+
+func (r *fakeServiceRepo) GetServiceACLs(ctx context.Context, orgID, serviceID string) ([]models.ServiceACL, error) {
+	return r.acls, nil
+}
+
+func (r *fakeServiceRepo) DeleteServiceACL(ctx context.Context, orgID, serviceID, aclID string) (int64, error) {
+	return 0, nil
+}
+
+func (r *fakeServiceRepo) SubjectInOrg(ctx context.Context, orgID, subjectType, subjectID string) (bool, error) {
+	return true, nil
+}
+
+// setupFakeRouter serves the service routes backed by repo for requests made by principal
+func setupFakeRouter(repo *fakeServiceRepo, principal auth.Principal) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		middleware.SetPrincipal(c, principal)
+	})
+	router.GET("/services", handlers.GetServices(repo))
+	router.POST("/services", handlers.CreateService(repo))
+	router.GET("/services/:id", handlers.GetService(repo, repo))
+	router.DELETE("/services/:id", handlers.DeleteService(repo, repo))
+	return router
+}
+
+func TestServiceHandlersWithFakeRepository(t *testing.T) {
+	repo := newFakeServiceRepo()
+	alice := auth.Principal{OrgID: "org-1", UserID: "alice"}
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/services", strings.NewReader(`{"name":"Billing","slug":"billing","description":"Bills","visibility":"private"}`))
+	req.Header.Set("Content-Type", "application/json")
+	setupFakeRouter(repo, alice).ServeHTTP(w, req)
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	var created models.Service
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.Equal(t, "org-1", created.OrgID)
+	require.Len(t, repo.acls, 1, "the creator of a private service is granted write access")
+
+	tests := []struct {
+		name      string
+		principal auth.Principal
+		expected  int
+	}{
+		{"creator", alice, http.StatusOK},
+		{"org-wide token", auth.Principal{OrgID: "org-1"}, http.StatusOK},
+		{"user without grant", auth.Principal{OrgID: "org-1", UserID: "bob"}, http.StatusNotFound},
+		{"other organization", auth.Principal{OrgID: "org-2"}, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/services/"+created.ID, nil)
+			setupFakeRouter(repo, tt.principal).ServeHTTP(w, req)
+			assert.Equal(t, tt.expected, w.Code)
+		})
+	}
+}
+
+func TestServiceHandlersHideRepositoryErrors(t *testing.T) {
+	repo := newFakeServiceRepo()
+	repo.err = errors.New("dial tcp 10.0.0.5:3306: connection refused")
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/services", nil)
+	setupFakeRouter(repo, auth.Principal{OrgID: "org-1"}).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.NotContains(t, w.Body.String(), "10.0.0.5")
+}
+
+func TestServiceHandlersReportQueryTimeouts(t *testing.T) {
+	repo := newFakeServiceRepo()
+	repo.err = fmt.Errorf("query services: %w", context.DeadlineExceeded)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/services", nil)
+	setupFakeRouter(repo, auth.Principal{OrgID: "org-1"}).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+}
+
+func TestServiceHandlersFailFastWhileDatabaseUnavailable(t *testing.T) {
+	repo := newFakeServiceRepo()
+	repo.err = &repository.UnavailableError{RetryAfter: 2500 * time.Millisecond}
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/services", nil)
+	setupFakeRouter(repo, auth.Principal{OrgID: "org-1"}).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "3", w.Header().Get("Retry-After"))
+}
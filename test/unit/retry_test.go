@@ -0,0 +1,229 @@
+package unit
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yashjain/konnect/internal/config"
+	"github.com/yashjain/konnect/internal/database"
+	"github.com/yashjain/konnect/internal/repository"
+)
+
+// sqliteErrors returns the errors SQLite raises when another writer holds the
+// lock, and for a unique key violation
+func sqliteErrors(t *testing.T) (busy, unique error) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "errors.db")
+	first, err := sql.Open("sqlite", path)
+	require.NoError(t, err)
+	defer first.Close()
+	second, err := sql.Open("sqlite", path)
+	require.NoError(t, err)
+	defer second.Close()
+
+	_, err = first.ExecContext(ctx, "CREATE TABLE t (k TEXT UNIQUE)")
+	require.NoError(t, err)
+	_, err = first.ExecContext(ctx, "INSERT INTO t (k) VALUES ('a')")
+	require.NoError(t, err)
+	_, unique = first.ExecContext(ctx, "INSERT INTO t (k) VALUES ('a')")
+	require.Error(t, unique)
+
+	writer, err := first.Conn(ctx)
+	require.NoError(t, err)
+	defer writer.Close()
+	_, err = writer.ExecContext(ctx, "BEGIN IMMEDIATE")
+	require.NoError(t, err)
+	defer func() {
+		_, _ = writer.ExecContext(ctx, "ROLLBACK")
+	}()
+	_, busy = second.ExecContext(ctx, "BEGIN IMMEDIATE")
+	require.Error(t, busy)
+	return busy, unique
+}
+
+func TestRetryClassification(t *testing.T) {
+	captureLogs(t)
+	busy, unique := sqliteErrors(t)
+
+	// Lock conflicts roll back the work, so reads and writes are rerun; a
+	// dropped connection may have applied a write, so only reads are rerun
+	tests := []struct {
+		name                 string
+		err                  error
+		wantReads, wantWrite int
+	}{
+		{"success", nil, 1, 1},
+		{"mysql deadlock", &mysql.MySQLError{Number: 1213}, 3, 3},
+		{"mysql lock wait timeout", &mysql.MySQLError{Number: 1205}, 3, 3},
+		{"mysql duplicate entry", &mysql.MySQLError{Number: 1062}, 1, 1},
+		{"wrapped mysql deadlock", fmt.Errorf("update: %w", &mysql.MySQLError{Number: 1213}), 3, 3},
+		{"mysql invalid connection", mysql.ErrInvalidConn, 3, 1},
+		{"postgres serialization failure", &pgconn.PgError{Code: "40001"}, 3, 3},
+		{"postgres deadlock", &pgconn.PgError{Code: "40P01"}, 3, 3},
+		{"postgres lock not available", &pgconn.PgError{Code: "55P03"}, 3, 3},
+		{"postgres unique violation", &pgconn.PgError{Code: "23505"}, 1, 1},
+		{"sqlite busy", busy, 3, 3},
+		{"sqlite unique constraint", unique, 1, 1},
+		{"unexpected EOF", io.ErrUnexpectedEOF, 3, 1},
+		{"connection reset", &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}, 3, 1},
+		{"network error", &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}, 3, 1},
+		{"network timeout", &net.DNSError{Err: "timeout", IsTimeout: true}, 1, 1},
+		{"deadline exceeded", context.DeadlineExceeded, 1, 1},
+		{"other", errors.New("boom"), 1, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &fakeDriver{err: tt.err}
+			store := newFakeStore(t, config.DatabaseConfig{RetryAttempts: 3, RetryBase: time.Microsecond, RetryMax: time.Microsecond}, d, nil)
+
+			wantErr := tt.err
+			if wantErr == nil {
+				wantErr = sql.ErrNoRows
+			}
+			assert.ErrorIs(t, fakeRead(store), wantErr)
+			err := fakeWrite(store)
+			if tt.err == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, tt.err)
+			}
+
+			execs, queries := d.counts()
+			assert.Equal(t, tt.wantReads, queries)
+			assert.Equal(t, tt.wantWrite, execs)
+		})
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	captureLogs(t)
+	deadlock := &mysql.MySQLError{Number: 1213}
+
+	// Waits are capped at RetryMax
+	d := &fakeDriver{err: deadlock}
+	store := newFakeStore(t, config.DatabaseConfig{RetryAttempts: 4, RetryBase: 20 * time.Millisecond, RetryMax: 20 * time.Millisecond}, d, nil)
+	start := time.Now()
+	assert.ErrorIs(t, fakeRead(store), deadlock)
+	assert.Less(t, time.Since(start), time.Second)
+	_, queries := d.counts()
+	assert.Equal(t, 4, queries)
+
+	// A call running out of time stops retrying during the backoff
+	d = &fakeDriver{err: deadlock}
+	store = newFakeStore(t, config.DatabaseConfig{QueryTimeout: 20 * time.Millisecond, RetryAttempts: 3, RetryBase: time.Hour, RetryMax: time.Hour}, d, nil)
+	start = time.Now()
+	assert.ErrorIs(t, fakeRead(store), deadlock)
+	assert.Less(t, time.Since(start), time.Second)
+	_, queries = d.counts()
+	assert.Equal(t, 1, queries)
+
+	// Fewer than one attempt configured still runs once
+	d = &fakeDriver{err: deadlock}
+	store = newFakeStore(t, config.DatabaseConfig{RetryAttempts: 0}, d, nil)
+	assert.ErrorIs(t, fakeRead(store), deadlock)
+	_, queries = d.counts()
+	assert.Equal(t, 1, queries)
+}
+
+func TestBreakerTransitions(t *testing.T) {
+	captureLogs(t)
+	down := errors.New("connection refused")
+	const cooldown = 20 * time.Millisecond
+	ctx := context.Background()
+
+	// read reads through store with err as the database's answer, reporting
+	// whether the database was reached
+	read := func(t *testing.T, store *database.Store, d *fakeDriver, ctx context.Context, err error) bool {
+		t.Helper()
+		d.setErr(err)
+		_, before := d.counts()
+		_, _ = store.GetServiceByID(ctx, fakeOrgID, "svc-1")
+		_, after := d.counts()
+		return after > before
+	}
+	assertOpen := func(t *testing.T, store *database.Store, d *fakeDriver) {
+		t.Helper()
+		_, before := d.counts()
+		var unavailable *repository.UnavailableError
+		require.ErrorAs(t, fakeRead(store), &unavailable)
+		assert.Greater(t, unavailable.RetryAfter, time.Duration(0))
+		assert.LessOrEqual(t, unavailable.RetryAfter, cooldown)
+		_, after := d.counts()
+		assert.Equal(t, before, after)
+	}
+
+	tests := []struct {
+		name string
+		run  func(t *testing.T, store *database.Store, d *fakeDriver)
+	}{
+		{"stays closed below the threshold", func(t *testing.T, store *database.Store, d *fakeDriver) {
+			read(t, store, d, ctx, down)
+			assert.True(t, read(t, store, d, ctx, nil))
+		}},
+		{"opens at the threshold", func(t *testing.T, store *database.Store, d *fakeDriver) {
+			read(t, store, d, ctx, down)
+			read(t, store, d, ctx, down)
+			assertOpen(t, store, d)
+		}},
+		{"timeouts count as failures", func(t *testing.T, store *database.Store, d *fakeDriver) {
+			read(t, store, d, ctx, context.DeadlineExceeded)
+			read(t, store, d, ctx, down)
+			assertOpen(t, store, d)
+		}},
+		{"server errors close it", func(t *testing.T, store *database.Store, d *fakeDriver) {
+			read(t, store, d, ctx, down)
+			read(t, store, d, ctx, &mysql.MySQLError{Number: 1062})
+			read(t, store, d, ctx, down)
+			assert.True(t, read(t, store, d, ctx, nil))
+		}},
+		{"abandoned calls are ignored", func(t *testing.T, store *database.Store, d *fakeDriver) {
+			cancelled, cancel := context.WithCancel(ctx)
+			cancel()
+			read(t, store, d, ctx, down)
+			read(t, store, d, cancelled, nil)
+			read(t, store, d, ctx, down)
+			assertOpen(t, store, d)
+		}},
+		{"reopens when the probe after the cooldown fails", func(t *testing.T, store *database.Store, d *fakeDriver) {
+			read(t, store, d, ctx, down)
+			read(t, store, d, ctx, down)
+			time.Sleep(cooldown + 5*time.Millisecond)
+			assert.True(t, read(t, store, d, ctx, down))
+			assertOpen(t, store, d)
+		}},
+		{"closes when the probe succeeds", func(t *testing.T, store *database.Store, d *fakeDriver) {
+			read(t, store, d, ctx, down)
+			read(t, store, d, ctx, down)
+			time.Sleep(cooldown + 5*time.Millisecond)
+			assert.True(t, read(t, store, d, ctx, nil))
+			assert.True(t, read(t, store, d, ctx, down))
+			assert.True(t, read(t, store, d, ctx, nil))
+		}},
+		{"a disabled breaker admits everything", func(t *testing.T, _ *database.Store, d *fakeDriver) {
+			store := newFakeStore(t, config.DatabaseConfig{RetryAttempts: 1}, d, nil)
+			for i := 0; i < 5; i++ {
+				assert.True(t, read(t, store, d, ctx, down))
+			}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &fakeDriver{}
+			store := newFakeStore(t, config.DatabaseConfig{RetryAttempts: 1, BreakerThreshold: 2, BreakerCooldown: cooldown}, d, nil)
+			tt.run(t, store, d)
+		})
+	}
+}
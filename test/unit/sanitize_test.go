@@ -0,0 +1,72 @@
+package unit
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yashjain/konnect/internal/sanitize"
+)
+
+func TestSanitizeMarkdown(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "plain markdown is preserved",
+			input:    "# Title\n\n> quoted & **bold**",
+			expected: "# Title\n\n> quoted & **bold**",
+		},
+		{
+			name:     "script tags are removed",
+			input:    "hello<script>alert(1)</script> world",
+			expected: "hello world",
+		},
+		{
+			name:     "event handlers are removed",
+			input:    `<a href="https://example.com" onclick="steal()">link</a>`,
+			expected: `<a href="https://example.com" rel="nofollow">link</a>`,
+		},
+		{
+			name:     "escaped script is not revived",
+			input:    "&lt;script&gt;alert(1)&lt;/script&gt;",
+			expected: "",
+		},
+		{
+			name:     "code spans are preserved",
+			input:    "Call `GET /users` and avoid `<script>` tags",
+			expected: "Call `GET /users` and avoid `<script>` tags",
+		},
+		{
+			name:     "code blocks are preserved",
+			input:    "```html\n<b onclick=\"x()\">&amp;</b>\n```\n<script>alert(1)</script>",
+			expected: "```html\n<b onclick=\"x()\">&amp;</b>\n```\n",
+		},
+		{
+			name:     "html around code spans is removed",
+			input:    "`List<T>` <img src=x onerror=alert(1)>",
+			expected: "`List<T>` <img src=\"x\">",
+		},
+		{
+			name:     "code spans broken up by removed html are sanitized",
+			input:    "x <script>`</script>`<img src=x onerror=alert(1)>`",
+			expected: "x `<img src=\"x\">`",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, sanitize.Markdown(tt.input))
+		})
+	}
+}
+
+func TestRenderHTML(t *testing.T) {
+	out, err := sanitize.RenderHTML("**bold** <img src=x onerror=alert(1)>")
+	require.NoError(t, err)
+	assert.Contains(t, out, "<strong>bold</strong>")
+	assert.NotContains(t, out, "onerror")
+}
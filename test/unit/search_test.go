@@ -0,0 +1,495 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yashjain/konnect/internal/auth"
+	"github.com/yashjain/konnect/internal/handlers"
+	"github.com/yashjain/konnect/internal/middleware"
+	"github.com/yashjain/konnect/internal/models"
+	"github.com/yashjain/konnect/internal/outbox"
+	"github.com/yashjain/konnect/internal/search"
+	"github.com/yashjain/konnect/pkg/types"
+	"github.com/yashjain/konnect/pkg/utils"
+)
+
+func TestParseBoolean(t *testing.T) {
+	q, err := search.ParseBoolean(`+payment -legacy "exact  phrase" refund*`)
+	require.NoError(t, err)
+	assert.Equal(t, []search.BooleanTerm{
+		{Words: []string{"payment"}, Required: true},
+		{Words: []string{"legacy"}, Excluded: true},
+		{Words: []string{"exact", "phrase"}},
+		{Words: []string{"refund"}, Prefix: true},
+	}, q.Terms)
+	assert.Len(t, q.Filter(false, false), 2)
+}
+
+func TestParseBooleanRejectsInvalidQueries(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{name: "unterminated quote", query: `"exact phrase`},
+		{name: "empty phrase", query: `+"" payment`},
+		{name: "bare operator", query: "payment +"},
+		{name: "unsupported operator", query: "payment ~legacy"},
+		{name: "grouping", query: "(payment legacy)"},
+		{name: "missing space", query: `"exact phrase"payment`},
+		{name: "only exclusions", query: "-legacy -old"},
+		{name: "empty", query: "   "},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := search.ParseBoolean(tt.query)
+			assert.ErrorIs(t, err, search.ErrInvalidQuery)
+		})
+	}
+}
+
+func TestParseBooleanLimitsTerms(t *testing.T) {
+	query := ""
+	for i := 0; i <= search.MaxBooleanTerms; i++ {
+		query += "word "
+	}
+	_, err := search.ParseBoolean(query)
+	assert.ErrorIs(t, err, search.ErrInvalidQuery)
+}
+
+func TestGlobalSearch(t *testing.T) {
+	store := openSQLiteStore(t)
+	ctx := context.Background()
+	const orgID = "00000000-0000-0000-0000-000000000001"
+
+	require.NoError(t, store.CreateService(ctx, &models.Service{ID: "svc-1", OrgID: orgID, Name: "Payments", Slug: "payments", Description: "Card payment processing", Visibility: models.VisibilityPublic}))
+	require.NoError(t, store.CreateService(ctx, &models.Service{ID: "svc-2", OrgID: orgID, Name: "Ledger", Slug: "ledger", Description: "Internal books", Visibility: models.VisibilityPrivate}))
+	require.NoError(t, store.CreateVersion(ctx, orgID, &models.Version{ID: "ver-1", ServiceID: "svc-1", Semver: "1.0.0", Status: "released", Changelog: "Accept payments by card\n\nDetails"}))
+	require.NoError(t, store.CreateVersion(ctx, orgID, &models.Version{ID: "ver-2", ServiceID: "svc-2", Semver: "1.0.0", Status: "released", Changelog: "Record payments"}))
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		middleware.SetPrincipal(c, auth.Principal{OrgID: orgID, UserID: "alice"})
+	})
+	router.GET("/search", handlers.GlobalSearch(store, store))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/search?q=payment", nil)
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var response models.GlobalSearchResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	require.Len(t, response.Groups, 2)
+
+	services := response.Groups[0]
+	assert.Equal(t, models.SearchHitService, services.Type)
+	require.Len(t, services.Hits, 1)
+	assert.Equal(t, models.SearchHit{Type: "service", ID: "svc-1", Title: "Payments", Summary: "Card payment processing", Link: "/api/v1/services/svc-1"}, services.Hits[0])
+
+	// Versions of the private service are not visible to alice
+	versions := response.Groups[1]
+	assert.Equal(t, models.SearchHitVersion, versions.Type)
+	require.Len(t, versions.Hits, 1)
+	assert.Equal(t, models.SearchHit{Type: "version", ID: "ver-1", Title: "1.0.0", Summary: "Accept payments by card", Link: "/api/v1/services/svc-1/versions/ver-1"}, versions.Hits[0])
+
+	for _, query := range []string{"/search", "/search?q=payment&limit=0", "/search?q=payment&limit=21"} {
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest("GET", query, nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+}
+
+func TestSearchAnalytics(t *testing.T) {
+	store := openSQLiteStore(t)
+	ctx := context.Background()
+	const orgID = "00000000-0000-0000-0000-000000000001"
+	p := auth.Principal{OrgID: orgID}
+
+	require.NoError(t, store.CreateService(ctx, &models.Service{ID: "svc-1", OrgID: orgID, Name: "Payments", Slug: "payments", Description: "Card payment processing", Visibility: models.VisibilityPublic}))
+
+	// Every first page is sampled at rate 1, counted by its normalized query
+	repo := search.NewAnalyticsRepository(store, 1)
+	for _, params := range []types.SearchParams{
+		{Query: "Card  Payment", Page: 1, PageSize: 10},
+		{Query: "card payment", Page: 1, PageSize: 10, SkipCount: true},
+		{Query: "card payment", Page: 2, PageSize: 10},
+		{Query: "kubernetes", Page: 1, PageSize: 10},
+	} {
+		_, _, err := repo.SearchServices(ctx, p, params)
+		require.NoError(t, err)
+	}
+
+	// Failing to record, here for an unknown organization, does not fail the search
+	_, _, err := repo.SearchServices(ctx, auth.Principal{OrgID: "00000000-0000-0000-0000-000000000002"}, types.SearchParams{Query: "kubernetes", Page: 1, PageSize: 10})
+	require.NoError(t, err)
+
+	// Nothing is recorded at rate 0
+	_, _, err = search.NewAnalyticsRepository(store, 0).SearchServices(ctx, p, types.SearchParams{Query: "kubernetes", Page: 1, PageSize: 10})
+	require.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/admin/search/analytics", handlers.SearchAnalytics(store, 1))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/admin/search/analytics?org_id="+orgID, nil)
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var analytics models.SearchAnalytics
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &analytics))
+	assert.Equal(t, 3, analytics.Searches)
+	assert.Equal(t, 1, analytics.ZeroResultSearches)
+	assert.Equal(t, 1.0, analytics.SampleRate)
+	require.Len(t, analytics.TopQueries, 2)
+	assert.Equal(t, "card payment", analytics.TopQueries[0].Query)
+	assert.Equal(t, 2, analytics.TopQueries[0].Count)
+	assert.Greater(t, analytics.TopQueries[0].AvgHits, 0.0)
+	assert.Equal(t, []models.QueryStat{{Query: "kubernetes", Count: 1}}, analytics.ZeroResultQueries)
+
+	for _, query := range []string{"", "?org_id=" + orgID + "&since=-1h", "?org_id=" + orgID + "&limit=101"} {
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest("GET", "/admin/search/analytics"+query, nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+}
+
+func TestSearchCache(t *testing.T) {
+	store := openSQLiteStore(t)
+	ctx := context.Background()
+	const orgID = "00000000-0000-0000-0000-000000000001"
+	p := auth.Principal{OrgID: orgID}
+	params := types.SearchParams{Query: "ledger", Page: 1, PageSize: 10}
+
+	require.NoError(t, store.CreateService(ctx, &models.Service{ID: "svc-1", OrgID: orgID, Name: "Ledger", Slug: "ledger", Visibility: models.VisibilityPublic}))
+
+	repo := search.NewCachedRepository(store, time.Minute, 10)
+	_, total, err := repo.SearchServices(ctx, p, params)
+	require.NoError(t, err)
+	assert.Equal(t, 1, total)
+
+	// Writes made behind the cache's back are not seen until the TTL lapses
+	require.NoError(t, store.CreateService(ctx, &models.Service{ID: "svc-2", OrgID: orgID, Name: "Ledger Archive", Slug: "ledger-archive", Visibility: models.VisibilityPublic}))
+	_, total, err = repo.SearchServices(ctx, p, params)
+	require.NoError(t, err)
+	assert.Equal(t, 1, total)
+
+	// Other principals and parameters are cached separately
+	_, total, err = repo.SearchServices(ctx, auth.Principal{OrgID: orgID, UserID: "alice"}, params)
+	require.NoError(t, err)
+	assert.Equal(t, 2, total)
+
+	// Writes through the cache drop the organization's searches
+	require.NoError(t, repo.CreateService(ctx, &models.Service{ID: "svc-3", OrgID: orgID, Name: "Ledger Reports", Slug: "ledger-reports", Visibility: models.VisibilityPublic}))
+	services, total, err := repo.SearchServices(ctx, p, params)
+	require.NoError(t, err)
+	assert.Equal(t, 3, total)
+
+	// Callers cannot change cached results
+	services[0].Name = "changed"
+	cached, _, err := repo.SearchServices(ctx, p, params)
+	require.NoError(t, err)
+	assert.NotEqual(t, "changed", cached[0].Name)
+
+	_, err = repo.DeleteService(ctx, orgID, "svc-3")
+	require.NoError(t, err)
+	_, total, err = repo.SearchServices(ctx, p, params)
+	require.NoError(t, err)
+	assert.Equal(t, 2, total)
+
+	// Storing a spec and cancelling a deprecation drop them too, each picking up
+	// a service created behind the cache's back
+	const versionID = "ver-ledger"
+	require.NoError(t, repo.CreateVersion(ctx, orgID, &models.Version{ID: versionID, ServiceID: "svc-1", Semver: "1.0.0", Status: models.VersionReleased}))
+	_, _, err = repo.SearchServices(ctx, p, params)
+	require.NoError(t, err)
+	require.NoError(t, store.CreateService(ctx, &models.Service{ID: "svc-4", OrgID: orgID, Name: "Ledger Sync", Slug: "ledger-sync", Visibility: models.VisibilityPublic}))
+	_, total, err = repo.SearchServices(ctx, p, params)
+	require.NoError(t, err)
+	assert.Equal(t, 2, total)
+	require.NoError(t, repo.SetVersionSpec(ctx, orgID, &models.VersionSpec{VersionID: versionID, ServiceID: "svc-1", Format: "yaml", Content: []byte("openapi: 3.0.0\n"), Digest: strings.Repeat("0", 64)}))
+	_, total, err = repo.SearchServices(ctx, p, params)
+	require.NoError(t, err)
+	assert.Equal(t, 3, total)
+
+	_, err = repo.ScheduleDeprecation(ctx, orgID, "svc-1", versionID, time.Now().Add(24*time.Hour), nil)
+	require.NoError(t, err)
+	_, _, err = repo.SearchServices(ctx, p, params)
+	require.NoError(t, err)
+	require.NoError(t, store.CreateService(ctx, &models.Service{ID: "svc-5", OrgID: orgID, Name: "Ledger Audit", Slug: "ledger-audit", Visibility: models.VisibilityPublic}))
+	_, total, err = repo.SearchServices(ctx, p, params)
+	require.NoError(t, err)
+	assert.Equal(t, 3, total)
+	cancelled, err := repo.CancelDeprecation(ctx, orgID, "svc-1", versionID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), cancelled)
+	_, total, err = repo.SearchServices(ctx, p, params)
+	require.NoError(t, err)
+	assert.Equal(t, 4, total)
+
+	// So do removing the spec and changing synonyms, which every organization's
+	// searches expand
+	require.NoError(t, store.CreateService(ctx, &models.Service{ID: "svc-6", OrgID: orgID, Name: "Ledger Export", Slug: "ledger-export", Visibility: models.VisibilityPublic}))
+	deleted, err := repo.DeleteVersionSpec(ctx, orgID, "svc-1", versionID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+	_, total, err = repo.SearchServices(ctx, p, params)
+	require.NoError(t, err)
+	assert.Equal(t, 5, total)
+
+	require.NoError(t, store.CreateService(ctx, &models.Service{ID: "svc-7", OrgID: orgID, Name: "Ledger Import", Slug: "ledger-import", Visibility: models.VisibilityPublic}))
+	require.NoError(t, repo.SetSynonyms(ctx, "ledger", []string{"journal"}))
+	_, total, err = repo.SearchServices(ctx, p, params)
+	require.NoError(t, err)
+	assert.Equal(t, 6, total)
+
+	require.NoError(t, store.CreateService(ctx, &models.Service{ID: "svc-8", OrgID: orgID, Name: "Ledger Backfill", Slug: "ledger-backfill", Visibility: models.VisibilityPublic}))
+	_, err = repo.DeleteSynonyms(ctx, "ledger")
+	require.NoError(t, err)
+	_, total, err = repo.SearchServices(ctx, p, params)
+	require.NoError(t, err)
+	assert.Equal(t, 7, total)
+	for _, id := range []string{"svc-4", "svc-5", "svc-6", "svc-7", "svc-8"} {
+		_, err = store.DeleteService(ctx, orgID, id)
+		require.NoError(t, err)
+	}
+
+	// Entries expire after the TTL
+	short := search.NewCachedRepository(store, 10*time.Millisecond, 10)
+	_, total, err = short.SearchServices(ctx, p, params)
+	require.NoError(t, err)
+	assert.Equal(t, 2, total)
+	_, err = store.DeleteService(ctx, orgID, "svc-2")
+	require.NoError(t, err)
+	time.Sleep(20 * time.Millisecond)
+	_, total, err = short.SearchServices(ctx, p, params)
+	require.NoError(t, err)
+	assert.Equal(t, 1, total)
+}
+
+func TestScheduledDeprecationsDropCachedSearches(t *testing.T) {
+	captureLogs(t)
+	store := openSQLiteStore(t)
+	ctx := context.Background()
+	const orgID = "00000000-0000-0000-0000-000000000001"
+	p := auth.Principal{OrgID: orgID}
+	params := types.SearchParams{Query: "ledger", Page: 1, PageSize: 10}
+
+	require.NoError(t, store.CreateService(ctx, &models.Service{ID: "svc-1", OrgID: orgID, Name: "Ledger", Slug: "ledger", Visibility: models.VisibilityPublic}))
+	require.NoError(t, store.CreateVersion(ctx, orgID, &models.Version{ID: "ver-1", ServiceID: "svc-1", Semver: "1.0.0", Status: models.VersionReleased}))
+
+	repo := search.NewCachedRepository(store, time.Minute, 10)
+	scheduler := outbox.NewDeprecationScheduler(repo, time.Minute, 0)
+	_, total, err := repo.SearchServices(ctx, p, params)
+	require.NoError(t, err)
+	assert.Equal(t, 1, total)
+
+	// A service added behind the cache's back shows once a search is dropped
+	require.NoError(t, store.CreateService(ctx, &models.Service{ID: "svc-2", OrgID: orgID, Name: "Ledger Archive", Slug: "ledger-archive", Visibility: models.VisibilityPublic}))
+
+	// Flushing with nothing due keeps the cached search
+	n, err := scheduler.Flush(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+	_, total, err = repo.SearchServices(ctx, p, params)
+	require.NoError(t, err)
+	assert.Equal(t, 1, total)
+
+	// Deprecating a due version drops it. Dates are stored to the second, so
+	// the deprecation is scheduled for the next one.
+	deprecatedAt := time.Now().UTC().Truncate(time.Second).Add(time.Second)
+	_, err = store.ScheduleDeprecation(ctx, orgID, "svc-1", "ver-1", deprecatedAt, nil)
+	require.NoError(t, err)
+	time.Sleep(time.Until(deprecatedAt) + 10*time.Millisecond)
+	n, err = scheduler.Flush(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	_, total, err = repo.SearchServices(ctx, p, params)
+	require.NoError(t, err)
+	assert.Equal(t, 2, total)
+}
+
+func TestSearchSynonyms(t *testing.T) {
+	store := openSQLiteStore(t)
+	ctx := context.Background()
+	const orgID = "00000000-0000-0000-0000-000000000001"
+	p := auth.Principal{OrgID: orgID}
+	params := types.SearchParams{Query: "auth", Page: 1, PageSize: 10}
+
+	require.NoError(t, store.CreateService(ctx, &models.Service{ID: "svc-1", OrgID: orgID, Name: "Login", Slug: "login", Description: "Authentication for users", Visibility: models.VisibilityPublic}))
+	require.NoError(t, store.CreateService(ctx, &models.Service{ID: "svc-2", OrgID: orgID, Name: "Token Broker", Slug: "token-broker", Description: "Issues OAuth tokens", Visibility: models.VisibilityPublic}))
+
+	repo := search.NewSynonymRepository(store, 0)
+	require.NoError(t, repo.Load(ctx))
+	_, total, err := repo.SearchServices(ctx, p, params)
+	require.NoError(t, err)
+	assert.Zero(t, total)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/admin/search/synonyms", handlers.GetSynonyms(repo))
+	router.PUT("/admin/search/synonyms/:term", handlers.SetSynonyms(repo))
+	router.DELETE("/admin/search/synonyms/:term", handlers.DeleteSynonyms(repo))
+	send := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Terms and synonyms are folded, and changes apply at once
+	w := send("PUT", "/admin/search/synonyms/AUTH", `{"synonyms": ["Authentication", "oauth", "auth", "OAuth"]}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	var set models.SearchSynonyms
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &set))
+	assert.Equal(t, models.SearchSynonyms{Term: "auth", Synonyms: []string{"authentication", "oauth"}}, set)
+	assert.Equal(t, "Auth authentication oauth", repo.Expand("Auth"))
+	assert.Equal(t, "auth oauth authentication", repo.Expand("auth oauth"))
+
+	_, total, err = repo.SearchServices(ctx, p, params)
+	require.NoError(t, err)
+	assert.Equal(t, 2, total)
+	facets, err := repo.SearchFacets(ctx, p, params, []string{types.FacetVisibility})
+	require.NoError(t, err)
+	assert.Equal(t, []types.FacetBucket{{Value: models.VisibilityPublic, Count: 2}}, facets[types.FacetVisibility])
+
+	// Boolean searches are left as typed
+	_, total, err = repo.SearchServices(ctx, p, types.SearchParams{Query: "+auth", Mode: types.SearchModeBoolean, Page: 1, PageSize: 10})
+	require.NoError(t, err)
+	assert.Zero(t, total)
+
+	w = send("GET", "/admin/search/synonyms", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"data": [{"term": "auth", "synonyms": ["authentication", "oauth"]}]}`, w.Body.String())
+
+	for _, tt := range []struct{ path, body string }{
+		{"/admin/search/synonyms/single-sign-on", `{"synonyms": ["sso"]}`},
+		{"/admin/search/synonyms/sso", `{"synonyms": []}`},
+		{"/admin/search/synonyms/sso", `{"synonyms": ["sso"]}`},
+		{"/admin/search/synonyms/sso", `{}`},
+	} {
+		assert.Equal(t, http.StatusBadRequest, send("PUT", tt.path, tt.body).Code, tt.path+" "+tt.body)
+	}
+
+	// Changes made elsewhere apply once reloaded
+	require.NoError(t, store.SetSynonyms(ctx, "login", []string{"sign in"}))
+	assert.Equal(t, "login", repo.Expand("login"))
+	require.NoError(t, repo.Load(ctx))
+	assert.Equal(t, "login sign in", repo.Expand("login"))
+
+	assert.Equal(t, http.StatusOK, send("DELETE", "/admin/search/synonyms/auth", "").Code)
+	assert.Equal(t, http.StatusNotFound, send("DELETE", "/admin/search/synonyms/auth", "").Code)
+	assert.Equal(t, "auth", repo.Expand("auth"))
+}
+
+func TestSearchBrowse(t *testing.T) {
+	store := openSQLiteStore(t)
+	ctx := context.Background()
+	const orgID = "00000000-0000-0000-0000-0000000000b1"
+
+	require.NoError(t, store.CreateOrganization(ctx, &models.Organization{ID: orgID, Name: "Browse", Slug: "browse"}))
+	require.NoError(t, store.CreateService(ctx, &models.Service{ID: "svc-1", OrgID: orgID, Name: "Ledger", Slug: "ledger", Visibility: models.VisibilityPublic, Tags: []string{"finance"}}))
+	require.NoError(t, store.CreateService(ctx, &models.Service{ID: "svc-2", OrgID: orgID, Name: "Alerts", Slug: "alerts", Visibility: models.VisibilityPublic}))
+	require.NoError(t, store.CreateService(ctx, &models.Service{ID: "svc-3", OrgID: orgID, Name: "Billing", Slug: "billing", Visibility: models.VisibilityPrivate, Tags: []string{"finance"}}))
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		middleware.SetPrincipal(c, auth.Principal{OrgID: orgID})
+	})
+	router.GET("/services/search", handlers.SearchServices(store))
+	browse := func(query string) ([]string, types.Pagination) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/services/search"+query, nil)
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code, query)
+
+		var response struct {
+			Data       []models.Service `json:"data"`
+			Pagination types.Pagination `json:"pagination"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		ids := make([]string, len(response.Data))
+		for i, s := range response.Data {
+			ids[i] = s.ID
+			assert.Nil(t, s.Score, query)
+		}
+		return ids, response.Pagination
+	}
+
+	// Without q every service is listed, newest first unless sorted otherwise
+	ids, pagination := browse("")
+	assert.Len(t, ids, 3)
+	require.NotNil(t, pagination.Total)
+	assert.Equal(t, 3, *pagination.Total)
+	ids, _ = browse("?q=%20&sort=name")
+	assert.Equal(t, []string{"svc-2", "svc-3", "svc-1"}, ids)
+	ids, pagination = browse("?mode=browse&sort=name&page=2&page_size=2")
+	assert.Equal(t, []string{"svc-1"}, ids)
+	require.NotNil(t, pagination.TotalPages)
+	assert.Equal(t, 2, *pagination.TotalPages)
+	ids, _ = browse("?sort=name&tag=finance")
+	assert.Equal(t, []string{"svc-3", "svc-1"}, ids)
+
+	for _, query := range []string{"?mode=browse&q=ledger", "?fuzzy=true", "?min_score=1", "?mode=boolean"} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/services/search"+query, nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+}
+
+func TestSearchRejectsInvalidQueries(t *testing.T) {
+	store := openSQLiteStore(t)
+	const orgID = "00000000-0000-0000-0000-000000000001"
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		middleware.SetPrincipal(c, auth.Principal{OrgID: orgID})
+	})
+	router.GET("/services", handlers.GetServices(store))
+	router.GET("/services/search", handlers.SearchServices(store))
+	router.GET("/services/suggest", handlers.SuggestServices(store))
+	router.GET("/search", handlers.GlobalSearch(store, store))
+
+	long := url.QueryEscape(strings.Repeat("payments ", utils.MaxQueryLength/9+1))
+	for _, path := range []string{"/services/search", "/services", "/search"} {
+		for _, q := range []string{"p", " p ", long, "pay%00ments", "pay%0Aments", "pay%FFments"} {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", path+"?q="+q, nil)
+			router.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusBadRequest, w.Code, path+"?q="+q)
+			assert.Contains(t, w.Body.String(), "q must", path+"?q="+q)
+		}
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path+"?q=pa", nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code, path)
+	}
+
+	// Suggestions complete prefixes, so a single character is enough
+	for q, code := range map[string]int{"p": http.StatusOK, long: http.StatusBadRequest, "p%00": http.StatusBadRequest} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/services/suggest?q="+q, nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, code, w.Code, q)
+	}
+}
@@ -0,0 +1,100 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yashjain/konnect/internal/auth"
+	"github.com/yashjain/konnect/internal/middleware"
+	"github.com/yashjain/konnect/internal/sentry"
+)
+
+func TestSentryDSN(t *testing.T) {
+	for _, dsn := range []string{"", "https://sentry.example.com/1", "https://key@sentry.example.com/", "://key@host/1"} {
+		_, err := sentry.New(dsn, "", time.Second)
+		assert.Error(t, err, dsn)
+	}
+}
+
+func TestSentryReportsPanicsAndServerErrors(t *testing.T) {
+	captureLogs(t)
+
+	received := make(chan map[string]interface{}, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/prefix/api/42/store/", r.URL.Path)
+		assert.Contains(t, r.Header.Get("X-Sentry-Auth"), "sentry_key=public")
+		var event map[string]interface{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&event))
+		received <- event
+	}))
+	defer server.Close()
+
+	client, err := sentry.New(strings.Replace(server.URL, "://", "://public@", 1)+"/prefix/42", "test", time.Second)
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go client.Run(ctx)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middleware.RequestID(), gin.Recovery(), middleware.ReportErrors(client))
+	router.Use(func(c *gin.Context) {
+		middleware.SetPrincipal(c, auth.Principal{OrgID: "org-1", UserID: "alice"})
+	})
+	router.GET("/panic", func(c *gin.Context) {
+		panic("boom")
+	})
+	router.GET("/services/:id", func(c *gin.Context) {
+		middleware.ReportError(c, errors.New("dial app:s3cret@tcp(db:3306)/app"))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+	})
+	get := func(path string) int {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		req.Header.Set("X-Request-ID", "req-1")
+		req.Header.Set("Authorization", "Bearer secret-token")
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+	next := func() map[string]interface{} {
+		select {
+		case event := <-received:
+			return event
+		case <-time.After(5 * time.Second):
+			t.Fatal("no event reported")
+			return nil
+		}
+	}
+
+	// Panics are reported, then answered by the recovery middleware
+	assert.Equal(t, http.StatusInternalServerError, get("/panic"))
+	event := next()
+	exception := event["exception"].(map[string]interface{})["values"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, "panic: boom", exception["value"])
+	frames := exception["stacktrace"].(map[string]interface{})["frames"].([]interface{})
+	innermost := frames[len(frames)-1].(map[string]interface{})
+	assert.Equal(t, "sentry_test.go", innermost["filename"])
+	assert.Equal(t, true, innermost["in_app"])
+	assert.Equal(t, "test", event["environment"])
+
+	// Server errors are reported redacted, with the request they happened in
+	get("/services/svc-1")
+	event = next()
+	exception = event["exception"].(map[string]interface{})["values"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, "dial app:[REDACTED]@tcp(db:3306)/app", exception["value"])
+	assert.Equal(t, map[string]interface{}{"route": "/services/:id", "request_id": "req-1", "org_id": "org-1"}, event["tags"])
+	assert.Equal(t, map[string]interface{}{"id": "alice"}, event["user"])
+	request := event["request"].(map[string]interface{})
+	assert.Equal(t, "/services/svc-1", request["url"])
+	assert.NotContains(t, request["headers"], "Authorization")
+}
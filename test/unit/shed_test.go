@@ -0,0 +1,60 @@
+package unit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/yashjain/konnect/internal/middleware"
+)
+
+func TestShed(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	global := middleware.NewLimiter("global", 2)
+	router := gin.New()
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	slow := func(c *gin.Context) {
+		entered <- struct{}{}
+		<-release
+		c.JSON(http.StatusOK, gin.H{})
+	}
+	router.GET("/search", middleware.Shed(1500*time.Millisecond, global, middleware.NewLimiter("search", 1)), slow)
+	router.GET("/services", middleware.Shed(time.Second, global, middleware.NewLimiter("services", 0)), slow)
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		router.ServeHTTP(w, req)
+		return w
+	}
+	background := func(path string) chan int {
+		done := make(chan int, 1)
+		go func() { done <- get(path).Code }()
+		<-entered
+		return done
+	}
+
+	// A full group limit sheds its routes' requests while others are admitted
+	first := background("/search")
+	w := get("/search")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "2", w.Header().Get("Retry-After"))
+	assert.JSONEq(t, `{"error":"service temporarily unavailable","code":"overloaded"}`, w.Body.String())
+	second := background("/services")
+
+	// A full global limit sheds every request
+	w = get("/services")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+
+	// Slots are freed once requests are handled
+	close(release)
+	assert.Equal(t, http.StatusOK, <-first)
+	assert.Equal(t, http.StatusOK, <-second)
+	go func() { <-entered }()
+	assert.Equal(t, http.StatusOK, get("/search").Code)
+}
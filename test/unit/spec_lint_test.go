@@ -0,0 +1,168 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yashjain/konnect/internal/apispec"
+	"github.com/yashjain/konnect/internal/auth"
+	"github.com/yashjain/konnect/internal/filter"
+	"github.com/yashjain/konnect/internal/handlers"
+	"github.com/yashjain/konnect/internal/middleware"
+	"github.com/yashjain/konnect/internal/models"
+	"github.com/yashjain/konnect/pkg/types"
+)
+
+const lintedYAML = `openapi: 3.1.0
+info:
+  title: Pets
+  version: "1.0"
+  description: Pets of the store
+  contact: {email: pets@example.com}
+security:
+  - bearer: []
+paths:
+  /pet-owners/{ownerId}/pets:
+    parameters:
+      - {name: ownerId, in: path, required: true, description: The owner, schema: {type: string}}
+    get:
+      operationId: listOwnerPets
+      summary: List an owner's pets
+      tags: [pets]
+      responses:
+        "200": {description: The pets}
+  /health:
+    get:
+      operationId: getHealth
+      summary: Health check
+      tags: [ops]
+      security: []
+      responses:
+        2XX: {description: Healthy}
+components:
+  securitySchemes:
+    bearer: {type: http, scheme: bearer}
+  schemas:
+    Pet: {type: object}
+`
+
+func TestLintSpec(t *testing.T) {
+	doc, err := apispec.Parse([]byte(lintedYAML))
+	require.NoError(t, err)
+	report := apispec.NewLinter(nil).Lint(doc)
+	assert.Equal(t, 100, report.Score, report.Problems)
+	assert.Empty(t, report.Problems)
+	assert.Len(t, report.Rules, len(apispec.Rules))
+
+	// petstoreYAML has no description, contact, security, tags or summary
+	doc, err = apispec.Parse([]byte(strings.Replace(petstoreYAML, "operationId: getPet", "operationId: Get_Pet", 1)))
+	require.NoError(t, err)
+	report = apispec.NewLinter(nil).Lint(doc)
+	problems := map[string]string{}
+	for _, p := range report.Problems {
+		problems[p.Rule] = p.Location
+	}
+	assert.Equal(t, map[string]string{
+		"info-description":      "info",
+		"info-contact":          "info",
+		"security-schemes":      "components",
+		"operation-security":    "paths./pets/{id}.get",
+		"operation-id-case":     "paths./pets/{id}.get.operationId",
+		"operation-description": "paths./pets/{id}.get",
+		"operation-tags":        "paths./pets/{id}.get",
+		"parameter-description": "paths./pets/{id}.get.parameters[0]",
+	}, problems)
+	assert.Equal(t, len(report.Problems), report.ProblemsCount)
+	// Of 27 weighted checks, the 9 of operation-id, operation-success-response and path-case pass
+	assert.Equal(t, 33, report.Score)
+
+	// Only the listed rules run, with their severities changed
+	report = apispec.NewLinter([]string{"operation-tags:error", "info-contact:fatal", "no-such-rule"}).Lint(doc)
+	require.Len(t, report.Rules, 2)
+	assert.Equal(t, apispec.SeverityError, report.Rules[0].Severity)
+	assert.Equal(t, apispec.SeverityInfo, report.Rules[1].Severity)
+	assert.Zero(t, report.Score)
+
+	doc, err = apispec.Parse([]byte(`{"openapi": "3.1.0", "info": {"title": "t", "version": "1"}, "paths": {"/Pets/{id}/feed.atom": {}}}`))
+	require.NoError(t, err)
+	report = apispec.NewLinter([]string{"path-case"}).Lint(doc)
+	require.Len(t, report.Problems, 1)
+	assert.Equal(t, "segments Pets are not lowercase kebab-case", report.Problems[0].Message)
+}
+
+func TestSpecScores(t *testing.T) {
+	captureLogs(t)
+	store := openSQLiteStore(t)
+	ctx := context.Background()
+	const orgID = "00000000-0000-0000-0000-000000000001"
+	const serviceID = "6f1c2f4e-0000-4000-8000-000000000003"
+	const v1, v2 = "7a2d3e5f-0000-4000-8000-000000000001", "7a2d3e5f-0000-4000-8000-000000000002"
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) { middleware.SetPrincipal(c, auth.Principal{OrgID: orgID}) })
+	router.PUT("/services/:id/versions/:version_id/spec", handlers.PutVersionSpec(store, store, nil))
+	router.GET("/services/:id/versions/:version_id/spec/lint", handlers.LintVersionSpec(store, store, apispec.NewLinter([]string{"info-contact"})))
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/yaml")
+		router.ServeHTTP(w, req)
+		return w
+	}
+	specPath := func(versionID string) string {
+		return "/services/" + serviceID + "/versions/" + versionID + "/spec"
+	}
+
+	service, err := store.GetServiceByID(ctx, orgID, serviceID)
+	require.NoError(t, err)
+	assert.Nil(t, service.SpecScore)
+
+	w := do("PUT", specPath(v1), lintedYAML)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"score":100`)
+	require.Equal(t, http.StatusOK, do("PUT", specPath(v2), petstoreYAML).Code)
+
+	version, err := store.GetVersion(ctx, orgID, serviceID, v2)
+	require.NoError(t, err)
+	require.NotNil(t, version.SpecScore)
+	assert.Less(t, *version.SpecScore, 100)
+
+	// The service has the score of its newest version with a scored document
+	service, err = store.GetServiceByID(ctx, orgID, serviceID)
+	require.NoError(t, err)
+	assert.Equal(t, version.SpecScore, service.SpecScore)
+
+	conditions, err := filter.Parse("spec_score<100", filter.ServiceFields)
+	require.NoError(t, err)
+	services, total, err := store.GetServices(ctx, auth.Principal{OrgID: orgID}, types.PaginationParams{Page: 1, PageSize: 10, Filter: conditions})
+	require.NoError(t, err)
+	assert.Equal(t, 1, total)
+	require.Len(t, services, 1)
+	assert.Equal(t, serviceID, services[0].ID)
+
+	conditions, err = filter.Parse("spec_score==100", filter.VersionFields)
+	require.NoError(t, err)
+	versions, _, err := store.GetVersions(ctx, orgID, serviceID, types.PaginationParams{Page: 1, PageSize: 10, Filter: conditions})
+	require.NoError(t, err)
+	require.Len(t, versions, 1)
+	assert.Equal(t, v1, versions[0].ID)
+
+	// Linting uses the rules in effect now
+	w = do("GET", specPath(v2)+"/lint", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var report models.SpecLintReport
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	assert.Zero(t, report.Score)
+	require.Len(t, report.Problems, 1)
+	assert.Equal(t, "info-contact", report.Problems[0].Rule)
+	assert.Equal(t, http.StatusNotFound, do("GET", "/services/"+serviceID+"/versions/missing/spec/lint", "").Code)
+}
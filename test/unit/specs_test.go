@@ -0,0 +1,204 @@
+package unit
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yashjain/konnect/internal/apispec"
+	"github.com/yashjain/konnect/internal/auth"
+	"github.com/yashjain/konnect/internal/handlers"
+	"github.com/yashjain/konnect/internal/middleware"
+	"github.com/yashjain/konnect/internal/models"
+)
+
+const petstoreYAML = `openapi: 3.0.3
+info:
+  title: Petstore
+  version: "1.0"
+paths:
+  /pets/{id}:
+    get:
+      operationId: getPet
+      parameters:
+        - name: id
+          in: path
+          required: true
+          schema:
+            type: string
+      responses:
+        "200":
+          description: |
+            The pet
+            with its owner
+`
+
+func TestParseSpec(t *testing.T) {
+	doc, err := apispec.Parse([]byte(petstoreYAML))
+	require.NoError(t, err)
+	assert.Equal(t, "3.0.3", doc.OpenAPI)
+	assert.Equal(t, "Petstore", doc.Title)
+	assert.Equal(t, "1.0", doc.Version)
+
+	tests := []struct {
+		name    string
+		spec    string
+		problem string
+	}{
+		{name: "not a document", spec: `[1, 2]`, problem: "document must be an object"},
+		{name: "swagger", spec: `{"swagger": "2.0", "info": {"title": "t", "version": "1"}, "paths": {}}`, problem: "Swagger 2.0"},
+		{name: "version", spec: `{"openapi": "2.0", "info": {"title": "t", "version": "1"}, "paths": {}}`, problem: "openapi: must be an OpenAPI 3.0 or 3.1 version"},
+		{name: "info", spec: `{"openapi": "3.1.0", "paths": {}}`, problem: "info is required"},
+		{name: "blank title", spec: `{"openapi": "3.1.0", "info": {"title": " ", "version": "1"}, "paths": {}}`, problem: "info.title: must not be blank"},
+		{name: "paths", spec: `{"openapi": "3.0.0", "info": {"title": "t", "version": "1"}}`, problem: "paths is required"},
+		{name: "nothing", spec: `{"openapi": "3.1.0", "info": {"title": "t", "version": "1"}}`, problem: "document must have paths, webhooks or components"},
+		{name: "path", spec: `{"openapi": "3.1.0", "info": {"title": "t", "version": "1"}, "paths": {"pets": {}}}`, problem: "paths.pets: path must begin with /"},
+		{name: "responses", spec: `{"openapi": "3.0.0", "info": {"title": "t", "version": "1"}, "paths": {"/pets": {"get": {}}}}`, problem: "paths./pets.get: responses is required"},
+		{name: "status", spec: `{"openapi": "3.1.0", "info": {"title": "t", "version": "1"}, "paths": {"/pets": {"get": {"responses": {"OK": {}}}}}}`, problem: "paths./pets.get.responses.OK: must be an HTTP status code"},
+		{name: "path parameter", spec: `{"openapi": "3.1.0", "info": {"title": "t", "version": "1"}, "paths": {"/pets/{id}": {"parameters": [{"name": "id", "in": "path"}]}}}`, problem: "paths./pets/{id}.parameters[0]: path parameters must be required"},
+		{name: "operationId", spec: `{"openapi": "3.1.0", "info": {"title": "t", "version": "1"}, "paths": {"/a": {"get": {"operationId": "x"}}, "/b": {"get": {"operationId": "x"}}}}`, problem: `"x" is also the operationId of paths./a.get`},
+		{name: "syntax", spec: `{"openapi": `, problem: "yaml:"},
+	}
+	for _, tt := range tests {
+		_, err := apispec.Parse([]byte(tt.spec))
+		require.ErrorIs(t, err, apispec.ErrInvalidSpec, tt.name)
+		assert.Contains(t, err.Error(), tt.problem, tt.name)
+	}
+}
+
+func TestEncodeSpec(t *testing.T) {
+	assert.Equal(t, apispec.FormatYAML, apispec.FormatOf([]byte(petstoreYAML)))
+
+	doc, err := apispec.Parse([]byte(petstoreYAML))
+	require.NoError(t, err)
+	data, err := doc.Encode(apispec.FormatJSON)
+	require.NoError(t, err)
+	assert.Equal(t, apispec.FormatJSON, apispec.FormatOf(data))
+	// Keys keep their order, and strings that look like numbers stay strings
+	assert.True(t, strings.Index(string(data), `"openapi"`) < strings.Index(string(data), `"info"`))
+	assert.Contains(t, string(data), `"version": "1.0"`)
+	assert.Contains(t, string(data), `"required": true`)
+	assert.Contains(t, string(data), `"description": "The pet\nwith its owner\n"`)
+
+	// Converting back to YAML gives the same document
+	doc, err = apispec.Parse(data)
+	require.NoError(t, err)
+	yamlData, err := doc.Encode(apispec.FormatYAML)
+	require.NoError(t, err)
+	assert.Equal(t, petstoreYAML, string(yamlData))
+}
+
+func TestSQLiteVersionSpecs(t *testing.T) {
+	store := openSQLiteStore(t)
+	ctx := context.Background()
+	const orgID = "00000000-0000-0000-0000-000000000001"
+	const otherOrgID = "00000000-0000-0000-0000-000000000002"
+	const serviceID = "6f1c2f4e-0000-4000-8000-000000000003"
+	const versionID = "7a2d3e5f-0000-4000-8000-000000000001"
+
+	spec := func(content string) *models.VersionSpec {
+		return &models.VersionSpec{VersionID: versionID, ServiceID: serviceID, Format: apispec.FormatYAML, Content: []byte(content), Digest: strings.Repeat("0", 64)}
+	}
+	require.NoError(t, store.SetVersionSpec(ctx, orgID, spec("first")))
+	require.NoError(t, store.SetVersionSpec(ctx, orgID, spec(petstoreYAML)))
+	assert.ErrorIs(t, store.SetVersionSpec(ctx, otherOrgID, spec(petstoreYAML)), sql.ErrNoRows)
+
+	got, err := store.GetVersionSpec(ctx, orgID, serviceID, versionID)
+	require.NoError(t, err)
+	assert.Equal(t, petstoreYAML, string(got.Content))
+	assert.Equal(t, len(petstoreYAML), got.Size)
+	_, err = store.GetVersionSpec(ctx, otherOrgID, serviceID, versionID)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+
+	version, err := store.GetVersion(ctx, orgID, serviceID, versionID)
+	require.NoError(t, err)
+	assert.True(t, version.HasSpec)
+
+	n, err := store.DeleteVersionSpec(ctx, otherOrgID, serviceID, versionID)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+	n, err = store.DeleteVersionSpec(ctx, orgID, serviceID, versionID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+	version, err = store.GetVersion(ctx, orgID, serviceID, versionID)
+	require.NoError(t, err)
+	assert.False(t, version.HasSpec)
+}
+
+func TestVersionSpecHandlers(t *testing.T) {
+	captureLogs(t)
+	store := openSQLiteStore(t)
+	const orgID = "00000000-0000-0000-0000-000000000001"
+	const serviceID = "6f1c2f4e-0000-4000-8000-000000000003"
+	const versionID = "7a2d3e5f-0000-4000-8000-000000000001"
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) { middleware.SetPrincipal(c, auth.Principal{OrgID: orgID}) })
+	router.GET("/services/:id/versions/:version_id", handlers.GetVersion(store, store))
+	router.GET("/services/:id/versions/:version_id/spec", handlers.GetVersionSpec(store, store))
+	router.PUT("/services/:id/versions/:version_id/spec", handlers.PutVersionSpec(store, store, nil))
+	router.DELETE("/services/:id/versions/:version_id/spec", handlers.DeleteVersionSpec(store, store))
+	do := func(method, path, contentType, accept, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+	path := "/services/" + serviceID + "/versions/" + versionID + "/spec"
+
+	assert.Equal(t, http.StatusNotFound, do("GET", path, "", "", "").Code)
+
+	w := do("PUT", path, "application/yaml", "", petstoreYAML)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var spec models.VersionSpec
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &spec))
+	assert.Equal(t, apispec.FormatYAML, spec.Format)
+	assert.Equal(t, "3.0.3", spec.OpenAPI)
+	assert.Equal(t, "Petstore", spec.Title)
+	assert.Equal(t, "1.0", spec.APIVersion)
+	assert.Len(t, spec.Digest, 64)
+
+	w = do("PUT", path, "application/json", "", `{"openapi": "3.1.0", "info": {"title": "Petstore"}, "paths": {}}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "info: version is required")
+	assert.Equal(t, http.StatusUnsupportedMediaType, do("PUT", path, "text/html", "", petstoreYAML).Code)
+	assert.Equal(t, http.StatusNotFound, do("PUT", "/services/"+serviceID+"/versions/missing/spec", "application/yaml", "", petstoreYAML).Code)
+
+	// The document is served as uploaded unless JSON is asked for
+	w = do("GET", path, "", "", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, petstoreYAML, w.Body.String())
+	assert.Contains(t, w.Header().Get("Content-Type"), "application/yaml")
+	for _, accept := range []string{"application/json", "application/yaml;q=0.5, application/vnd.oai.openapi+json"} {
+		w = do("GET", path, "", accept, "")
+		require.Equal(t, http.StatusOK, w.Code, accept)
+		assert.Contains(t, w.Header().Get("Content-Type"), "application/json", accept)
+		assert.True(t, json.Valid(w.Body.Bytes()), accept)
+	}
+	w = do("GET", path+"?format=json", "", "application/yaml", "")
+	assert.Contains(t, w.Header().Get("Content-Type"), "application/json")
+	assert.Equal(t, http.StatusBadRequest, do("GET", path+"?format=xml", "", "", "").Code)
+
+	w = do("GET", "/services/"+serviceID+"/versions/"+versionID, "", "", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"has_spec":true`)
+	assert.Contains(t, w.Body.String(), `"spec":{"href":"/api/v1/services/`+serviceID+`/versions/`+versionID+`/spec"}`)
+
+	assert.Equal(t, http.StatusOK, do("DELETE", path, "", "", "").Code)
+	assert.Equal(t, http.StatusNotFound, do("DELETE", path, "", "", "").Code)
+}
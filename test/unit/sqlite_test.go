@@ -0,0 +1,809 @@
+package unit
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yashjain/konnect/internal/auth"
+	"github.com/yashjain/konnect/internal/database"
+	"github.com/yashjain/konnect/internal/filter"
+	"github.com/yashjain/konnect/internal/handlers"
+	"github.com/yashjain/konnect/internal/logging"
+	"github.com/yashjain/konnect/internal/metrics"
+	"github.com/yashjain/konnect/internal/middleware"
+	"github.com/yashjain/konnect/internal/models"
+	"github.com/yashjain/konnect/internal/repository"
+	"github.com/yashjain/konnect/migrations"
+	"github.com/yashjain/konnect/pkg/types"
+)
+
+// openSQLiteStore opens a migrated in-memory SQLite store
+func openSQLiteStore(t *testing.T) *database.Store {
+	t.Setenv("DB_DRIVER", "sqlite")
+	t.Setenv("SQLITE_PATH", ":memory:")
+
+	store, err := database.Open()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = store.Close() })
+	return store
+}
+
+func TestSQLiteStore(t *testing.T) {
+	store := openSQLiteStore(t)
+	ctx := context.Background()
+	const orgID = "00000000-0000-0000-0000-000000000001"
+	p := auth.Principal{OrgID: orgID}
+
+	require.NoError(t, store.Ping(ctx))
+
+	// The demo seed is applied with the schema
+	services, total, err := store.GetServices(ctx, p, types.PaginationParams{Page: 1, PageSize: 10})
+	require.NoError(t, err)
+	assert.Equal(t, 3, total)
+	assert.Len(t, services, 3)
+
+	// Skipping the count fetches one look-ahead row instead
+	services, total, err = store.GetServices(ctx, p, types.PaginationParams{Page: 1, PageSize: 2, SkipCount: true})
+	require.NoError(t, err)
+	assert.Zero(t, total)
+	assert.Len(t, services, 3)
+
+	// Keyset pages continue after the cursor row, here seed rows sharing a created_at
+	after := types.Cursor{CreatedAt: services[0].CreatedAt, ID: services[0].ID}
+	rest, _, err := store.GetServices(ctx, p, types.PaginationParams{Page: 1, PageSize: 10, Cursor: &after})
+	require.NoError(t, err)
+	assert.Equal(t, services[1:], rest)
+
+	service := &models.Service{ID: "svc-1", OrgID: orgID, Name: "Payments Gateway", Slug: "payments-gateway", Description: "Card payments", Visibility: models.VisibilityPublic}
+	require.NoError(t, store.CreateService(ctx, service))
+	require.NoError(t, store.CreateVersion(ctx, orgID, &models.Version{ID: "ver-1", ServiceID: "svc-1", Semver: "1.0.0", Status: "released"}))
+
+	got, err := store.GetServiceByID(ctx, orgID, "svc-1")
+	require.NoError(t, err)
+	assert.Equal(t, 1, got.VersionsCount)
+
+	// Timestamps read back in UTC as stamped on insert
+	assert.False(t, service.CreatedAt.IsZero())
+	assert.True(t, service.CreatedAt.Equal(got.CreatedAt))
+	assert.Equal(t, time.UTC, got.CreatedAt.Location())
+
+	// The count is derived, so versions written outside the store are counted too
+	_, err = store.DB().Exec("INSERT INTO versions (id, service_id, semver, status, changelog) VALUES ('ver-2', 'svc-1', '1.1.0', 'draft', '')")
+	require.NoError(t, err)
+	got, err = store.GetServiceByID(ctx, orgID, "svc-1")
+	require.NoError(t, err)
+	assert.Equal(t, 2, got.VersionsCount)
+
+	// FTS5 search treats operators in user input literally
+	results, total, err := store.SearchServices(ctx, p, types.SearchParams{Query: `card "AND`, Page: 1, PageSize: 10})
+	require.NoError(t, err)
+	assert.Equal(t, 1, total)
+	require.Len(t, results, 1)
+	assert.Equal(t, "svc-1", results[0].ID)
+
+	// The search index follows updates
+	service.Description = "Wallets"
+	_, err = store.UpdateService(ctx, orgID, "svc-1", service)
+	require.NoError(t, err)
+	_, total, err = store.SearchServices(ctx, p, types.SearchParams{Query: "card", Page: 1, PageSize: 10})
+	require.NoError(t, err)
+	assert.Zero(t, total)
+
+	// Other organizations see nothing
+	_, total, err = store.GetServices(ctx, auth.Principal{OrgID: "org-2"}, types.PaginationParams{Page: 1, PageSize: 10})
+	require.NoError(t, err)
+	assert.Zero(t, total)
+
+	// Deleting a service hides it and its versions from every read
+	deleted, err := store.DeleteService(ctx, orgID, "svc-1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+	_, err = store.GetServiceByID(ctx, orgID, "svc-1")
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+	_, total, err = store.GetServices(ctx, p, types.PaginationParams{Page: 1, PageSize: 10})
+	require.NoError(t, err)
+	assert.Equal(t, 3, total)
+	_, total, err = store.GetVersions(ctx, orgID, "svc-1", types.PaginationParams{Page: 1, PageSize: 10})
+	require.NoError(t, err)
+	assert.Zero(t, total)
+	_, err = store.GetServiceVisibility(ctx, orgID, "svc-1")
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+
+	// A deleted service cannot be deleted again, updated or given versions
+	deleted, err = store.DeleteService(ctx, orgID, "svc-1")
+	require.NoError(t, err)
+	assert.Zero(t, deleted)
+	updated, err := store.UpdateService(ctx, orgID, "svc-1", service)
+	require.NoError(t, err)
+	assert.Zero(t, updated)
+	err = store.CreateVersion(ctx, orgID, &models.Version{ID: "ver-3", ServiceID: "svc-1", Semver: "2.0.0", Status: "draft"})
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+
+	// but is still returned when deleted rows are asked for
+	got, err = store.GetServiceByID(ctx, orgID, "svc-1", types.ReadOptions{IncludeDeleted: true})
+	require.NoError(t, err)
+	require.NotNil(t, got.DeletedAt)
+	assert.Equal(t, time.UTC, got.DeletedAt.Location())
+	_, total, err = store.GetServices(ctx, p, types.PaginationParams{Page: 1, PageSize: 10, IncludeDeleted: true})
+	require.NoError(t, err)
+	assert.Equal(t, 4, total)
+	versions, total, err := store.GetVersions(ctx, orgID, "svc-1", types.PaginationParams{Page: 1, PageSize: 10, IncludeDeleted: true})
+	require.NoError(t, err)
+	assert.Equal(t, 2, total)
+	assert.Nil(t, versions[0].DeletedAt)
+}
+
+func TestSQLiteTransactionsRollBackOnFailure(t *testing.T) {
+	store := openSQLiteStore(t)
+	ctx := context.Background()
+	const orgID = "00000000-0000-0000-0000-000000000001"
+
+	// The second grant violates the CHECK constraint, so the service must not be created either
+	service := &models.Service{ID: "svc-1", OrgID: orgID, Name: "Payments Gateway", Slug: "payments-gateway", Visibility: models.VisibilityPrivate}
+	err := store.CreateService(ctx, service,
+		models.ServiceACL{ID: "acl-1", SubjectType: "user", SubjectID: "user-1", Permission: "read"},
+		models.ServiceACL{ID: "acl-2", SubjectType: "user", SubjectID: "user-2", Permission: "admin"})
+	require.Error(t, err)
+
+	_, err = store.GetServiceByID(ctx, orgID, "svc-1")
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+
+	// Retrying after the failure succeeds on a clean slate
+	require.NoError(t, store.CreateService(ctx, service,
+		models.ServiceACL{ID: "acl-1", SubjectType: "user", SubjectID: "user-1", Permission: "read"}))
+	acls, err := store.GetServiceACLs(ctx, orgID, "svc-1")
+	require.NoError(t, err)
+	assert.Len(t, acls, 1)
+}
+
+func TestSQLiteStatementCacheEviction(t *testing.T) {
+	// Once the cache is full, each new query evicts the least recently used one,
+	// whose results are still read in full
+	t.Setenv("DB_STATEMENT_CACHE_SIZE", "1")
+	store := openSQLiteStore(t)
+	ctx := context.Background()
+	const orgID = "00000000-0000-0000-0000-000000000001"
+	p := auth.Principal{OrgID: orgID}
+
+	for i := 0; i < 2; i++ {
+		_, total, err := store.GetServices(ctx, p, types.PaginationParams{Page: 1, PageSize: 10})
+		require.NoError(t, err)
+		assert.Equal(t, 3, total)
+
+		service, err := store.GetServiceByID(ctx, orgID, "6f1c2f4e-0000-4000-8000-000000000003")
+		require.NoError(t, err)
+		assert.Equal(t, 2, service.VersionsCount)
+	}
+
+	// Concurrent queries evicting each other's statements still succeed
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var err error
+			if i%2 == 0 {
+				_, _, err = store.GetServices(ctx, p, types.PaginationParams{Page: 1, PageSize: 10})
+			} else {
+				_, err = store.GetServiceByID(ctx, orgID, "6f1c2f4e-0000-4000-8000-000000000003")
+			}
+			errs <- err
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		assert.NoError(t, err)
+	}
+}
+
+func TestSQLitePoolStatsMetrics(t *testing.T) {
+	store := openSQLiteStore(t)
+	pools := store.Pools()
+	require.Contains(t, pools, "primary")
+	assert.NotContains(t, pools, "replica")
+
+	metrics.RegisterDBStats("sqlite-test", pools["primary"])
+
+	w := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Contains(t, w.Body.String(), `go_sql_in_use_connections{db_name="sqlite-test"}`)
+	assert.Contains(t, w.Body.String(), `go_sql_wait_count_total{db_name="sqlite-test"}`)
+}
+
+func TestSQLiteRepositoryMetrics(t *testing.T) {
+	repo := repository.NewInstrumentedRepository(openSQLiteStore(t))
+	ctx := context.Background()
+	const orgID = "00000000-0000-0000-0000-000000000001"
+	calls := func() uint64 {
+		var m dto.Metric
+		require.NoError(t, metrics.RepositoryCallDuration.WithLabelValues("GetServiceByID").(prometheus.Histogram).Write(&m))
+		return m.GetHistogram().GetSampleCount()
+	}
+	before, errorsBefore := calls(), testutil.ToFloat64(metrics.RepositoryErrors.WithLabelValues("GetServiceByID"))
+
+	// Lookups of missing rows are timed but not counted as errors
+	_, err := repo.GetServiceByID(ctx, orgID, "missing")
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+	assert.Equal(t, before+1, calls())
+	assert.Equal(t, errorsBefore, testutil.ToFloat64(metrics.RepositoryErrors.WithLabelValues("GetServiceByID")))
+
+	// Failures are counted
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = repo.GetServiceByID(cancelled, orgID, "missing")
+	assert.Error(t, err)
+	assert.Equal(t, before+2, calls())
+	assert.Equal(t, errorsBefore+1, testutil.ToFloat64(metrics.RepositoryErrors.WithLabelValues("GetServiceByID")))
+}
+
+func TestSQLiteArchiveVersions(t *testing.T) {
+	store := openSQLiteStore(t)
+	ctx := context.Background()
+	const orgID = "00000000-0000-0000-0000-000000000001"
+
+	for _, id := range []string{"svc-1", "svc-2"} {
+		require.NoError(t, store.CreateService(ctx, &models.Service{ID: id, OrgID: orgID, Name: id, Slug: id, Visibility: models.VisibilityPublic}))
+		require.NoError(t, store.CreateVersion(ctx, orgID, &models.Version{ID: id + "-ver-1", ServiceID: id, Semver: "1.0.0", Status: "released"}))
+		require.NoError(t, store.CreateVersion(ctx, orgID, &models.Version{ID: id + "-ver-2", ServiceID: id, Semver: "1.1.0", Status: "draft"}))
+	}
+	_, err := store.DeleteService(ctx, orgID, "svc-1")
+	require.NoError(t, err)
+
+	// Nothing was deleted before the cutoff
+	archived, err := store.ArchiveVersions(ctx, time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	assert.Zero(t, archived.Versions)
+	assert.Empty(t, archived.Services)
+
+	// The deleted service's versions move to the archive; the live service's stay
+	archived, err = store.ArchiveVersions(ctx, time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), archived.Versions)
+	assert.Equal(t, map[string]string{"svc-1": orgID}, archived.Services)
+
+	var inArchive int
+	require.NoError(t, store.DB().QueryRow("SELECT COUNT(*) FROM versions_archive WHERE service_id = 'svc-1'").Scan(&inArchive))
+	assert.Equal(t, 2, inArchive)
+	_, total, err := store.GetVersions(ctx, orgID, "svc-1", types.PaginationParams{Page: 1, PageSize: 10, IncludeDeleted: true})
+	require.NoError(t, err)
+	assert.Zero(t, total)
+	_, total, err = store.GetVersions(ctx, orgID, "svc-2", types.PaginationParams{Page: 1, PageSize: 10})
+	require.NoError(t, err)
+	assert.Equal(t, 2, total)
+}
+
+func TestMigrationsEmbedded(t *testing.T) {
+	// Dev mode bootstraps MySQL and Postgres from the same migrations the goose CLI applies
+	mysqlFiles, err := fs.Glob(migrations.MySQL, "*.sql")
+	require.NoError(t, err)
+	postgresFiles, err := fs.Glob(migrations.Postgres, "postgres/*.sql")
+	require.NoError(t, err)
+
+	mysqlOnDisk, err := filepath.Glob(filepath.Join("..", "..", "migrations", "*.sql"))
+	require.NoError(t, err)
+	postgresOnDisk, err := filepath.Glob(filepath.Join("..", "..", "migrations", "postgres", "*.sql"))
+	require.NoError(t, err)
+	assert.Len(t, mysqlFiles, len(mysqlOnDisk))
+	assert.Len(t, postgresFiles, len(postgresOnDisk))
+
+	// Bootstrapping an already migrated database is a no-op
+	store := openSQLiteStore(t)
+	require.NoError(t, store.Migrate(context.Background()))
+}
+
+func TestSQLiteSearchShortWords(t *testing.T) {
+	store := openSQLiteStore(t)
+	ctx := context.Background()
+	const orgID = "00000000-0000-0000-0000-000000000001"
+	p := auth.Principal{OrgID: orgID}
+
+	require.NoError(t, store.CreateService(ctx, &models.Service{ID: "svc-1", OrgID: orgID, Name: "Orders DB", Slug: "orders-db", Description: "Order storage", Visibility: models.VisibilityPublic}))
+	require.NoError(t, store.CreateService(ctx, &models.Service{ID: "svc-2", OrgID: orgID, Name: "Discounts", Slug: "discounts", Description: "Takes 100% off", Visibility: models.VisibilityPublic}))
+
+	// Words full-text search ignores fall back to case-insensitive substring matching
+	results, total, err := store.SearchServices(ctx, p, types.SearchParams{Query: "db", Page: 1, PageSize: 10})
+	require.NoError(t, err)
+	assert.Equal(t, 1, total)
+	require.Len(t, results, 1)
+	assert.Equal(t, "svc-1", results[0].ID)
+
+	// LIKE wildcards in the query are taken literally
+	results, _, err = store.SearchServices(ctx, p, types.SearchParams{Query: "0%", Page: 1, PageSize: 10})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "svc-2", results[0].ID)
+	_, total, err = store.SearchServices(ctx, p, types.SearchParams{Query: "o_d", Page: 1, PageSize: 10})
+	require.NoError(t, err)
+	assert.Zero(t, total)
+
+	// Stopwords alone fall back too
+	_, total, err = store.SearchServices(ctx, p, types.SearchParams{Query: "the off", Page: 1, PageSize: 10})
+	require.NoError(t, err)
+	assert.Equal(t, 1, total)
+}
+
+func TestSQLiteSearchBooleanMode(t *testing.T) {
+	store := openSQLiteStore(t)
+	ctx := context.Background()
+	const orgID = "00000000-0000-0000-0000-000000000001"
+	p := auth.Principal{OrgID: orgID}
+
+	require.NoError(t, store.CreateService(ctx, &models.Service{ID: "svc-1", OrgID: orgID, Name: "Payments", Slug: "payments", Description: "Card payment processing", Visibility: models.VisibilityPublic}))
+	require.NoError(t, store.CreateService(ctx, &models.Service{ID: "svc-2", OrgID: orgID, Name: "Legacy Payments", Slug: "legacy-payments", Description: "Old payment gateway", Visibility: models.VisibilityPublic}))
+	require.NoError(t, store.CreateService(ctx, &models.Service{ID: "svc-3", OrgID: orgID, Name: "Refunds", Slug: "refunds", Description: "Refund processing", Visibility: models.VisibilityPublic}))
+
+	search := func(query string) []string {
+		results, total, err := store.SearchServices(ctx, p, types.SearchParams{Query: query, Mode: types.SearchModeBoolean, Page: 1, PageSize: 10})
+		require.NoError(t, err)
+		ids := make([]string, len(results))
+		for i, s := range results {
+			ids[i] = s.ID
+		}
+		assert.Len(t, ids, total)
+		return ids
+	}
+
+	assert.ElementsMatch(t, []string{"svc-1"}, search("+payment -legacy"))
+	assert.ElementsMatch(t, []string{"svc-1", "svc-3"}, search(`"card payment" refund`))
+	prefixed := search("+pay*")
+	assert.Subset(t, prefixed, []string{"svc-1", "svc-2"})
+	assert.NotContains(t, prefixed, "svc-3")
+
+	// Operators outside the boolean syntax are rejected rather than passed to FTS5
+	_, _, err := store.SearchServices(ctx, p, types.SearchParams{Query: "payment OR NEAR(", Mode: types.SearchModeBoolean, Page: 1, PageSize: 10})
+	assert.Error(t, err)
+}
+
+func TestSQLiteSuggestServices(t *testing.T) {
+	store := openSQLiteStore(t)
+	ctx := context.Background()
+	const orgID = "00000000-0000-0000-0000-000000000001"
+
+	require.NoError(t, store.CreateService(ctx, &models.Service{ID: "svc-1", OrgID: orgID, Name: "Payments", Slug: "payments", Visibility: models.VisibilityPublic}))
+	require.NoError(t, store.CreateService(ctx, &models.Service{ID: "svc-2", OrgID: orgID, Name: "Card Gateway", Slug: "pay-gateway", Visibility: models.VisibilityPublic}))
+	require.NoError(t, store.CreateService(ctx, &models.Service{ID: "svc-3", OrgID: orgID, Name: "Repayments", Slug: "repayments", Visibility: models.VisibilityPublic}))
+	require.NoError(t, store.CreateService(ctx, &models.Service{ID: "svc-4", OrgID: orgID, Name: "Payroll", Slug: "payroll", Visibility: models.VisibilityPrivate}))
+
+	// Name or slug prefixes match regardless of case, ordered by name; private services need a grant
+	suggestions, err := store.SuggestServices(ctx, auth.Principal{OrgID: orgID, UserID: "alice"}, "PAY", 10)
+	require.NoError(t, err)
+	assert.Equal(t, []models.ServiceSuggestion{
+		{ID: "svc-2", Name: "Card Gateway", Slug: "pay-gateway"},
+		{ID: "svc-1", Name: "Payments", Slug: "payments"},
+	}, suggestions)
+
+	suggestions, err = store.SuggestServices(ctx, auth.Principal{OrgID: orgID}, "pay", 2)
+	require.NoError(t, err)
+	assert.Len(t, suggestions, 2)
+
+	// LIKE wildcards are taken literally
+	suggestions, err = store.SuggestServices(ctx, auth.Principal{OrgID: orgID}, "%ay", 10)
+	require.NoError(t, err)
+	assert.Empty(t, suggestions)
+}
+
+func TestSQLiteSearchScores(t *testing.T) {
+	store := openSQLiteStore(t)
+	ctx := context.Background()
+	const orgID = "00000000-0000-0000-0000-000000000001"
+	p := auth.Principal{OrgID: orgID}
+
+	require.NoError(t, store.CreateService(ctx, &models.Service{ID: "svc-1", OrgID: orgID, Name: "Invoices", Slug: "invoices", Description: "Invoices, invoice drafts and invoice delivery", Visibility: models.VisibilityPublic}))
+	require.NoError(t, store.CreateService(ctx, &models.Service{ID: "svc-2", OrgID: orgID, Name: "Ledger", Slug: "ledger", Description: "Books every paid invoice among many other entries of many kinds", Visibility: models.VisibilityPublic}))
+
+	results, total, err := store.SearchServices(ctx, p, types.SearchParams{Query: "invoice", Page: 1, PageSize: 10})
+	require.NoError(t, err)
+	require.Equal(t, 2, total)
+	require.NotNil(t, results[0].Score)
+	require.NotNil(t, results[1].Score)
+	assert.Equal(t, "svc-1", results[0].ID)
+	assert.Greater(t, *results[0].Score, *results[1].Score)
+
+	// A minimum between the two scores cuts off the weaker match, from the count too
+	minScore := (*results[0].Score + *results[1].Score) / 2
+	results, total, err = store.SearchServices(ctx, p, types.SearchParams{Query: "invoice", Page: 1, PageSize: 10, MinScore: &minScore})
+	require.NoError(t, err)
+	assert.Equal(t, 1, total)
+	require.Len(t, results, 1)
+	assert.Equal(t, "svc-1", results[0].ID)
+
+	// Substring matches of short queries are not scored, so the minimum does not apply
+	results, _, err = store.SearchServices(ctx, p, types.SearchParams{Query: "led", Page: 1, PageSize: 10, MinScore: &minScore})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Nil(t, results[0].Score)
+}
+
+func TestSQLiteSearchWeights(t *testing.T) {
+	create := func(t *testing.T) *database.Store {
+		store := openSQLiteStore(t)
+		ctx := context.Background()
+		const orgID = "00000000-0000-0000-0000-000000000001"
+		require.NoError(t, store.CreateService(ctx, &models.Service{ID: "svc-1", OrgID: orgID, Name: "Remittance", Slug: "remittance", Description: "Sends money abroad", Visibility: models.VisibilityPublic}))
+		require.NoError(t, store.CreateService(ctx, &models.Service{ID: "svc-2", OrgID: orgID, Name: "Ledger", Slug: "ledger", Description: "Books each remittance, remittance fee and remittance refund", Visibility: models.VisibilityPublic}))
+		return store
+	}
+	search := func(t *testing.T, store *database.Store) []string {
+		p := auth.Principal{OrgID: "00000000-0000-0000-0000-000000000001"}
+		results, _, err := store.SearchServices(context.Background(), p, types.SearchParams{Query: "remittance", Page: 1, PageSize: 10})
+		require.NoError(t, err)
+		ids := make([]string, len(results))
+		for i, s := range results {
+			ids[i] = s.ID
+		}
+		return ids
+	}
+
+	// By default the service named after the search outranks one mentioning it more often
+	assert.Equal(t, []string{"svc-1", "svc-2"}, search(t, create(t)))
+
+	// Weighing only descriptions reverses that
+	t.Setenv("SEARCH_WEIGHT_NAME", "0")
+	t.Setenv("SEARCH_WEIGHT_SLUG", "0")
+	t.Setenv("SEARCH_WEIGHT_DESCRIPTION", "1")
+	assert.Equal(t, []string{"svc-2", "svc-1"}, search(t, create(t)))
+}
+
+func TestSlowQueryLog(t *testing.T) {
+	t.Setenv("DB_SLOW_QUERY_THRESHOLD", "1ns")
+	store := openSQLiteStore(t)
+	buf := captureLogs(t)
+
+	ctx := logging.WithEndpoint(context.Background(), "GET /api/v1/services/:id")
+	_, err := store.GetServiceByID(ctx, "00000000-0000-0000-0000-000000000001", "secret-service-id")
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	records := logRecords(t, buf)
+	require.NotEmpty(t, records)
+	record := records[0]
+	assert.Equal(t, "Slow query", record["msg"])
+	assert.Contains(t, record["sql"], "FROM services")
+	assert.Equal(t, "GET /api/v1/services/:id", record["endpoint"])
+	assert.Len(t, record["args_hash"], 16)
+	assert.Contains(t, record, "duration_ms")
+	assert.NotContains(t, buf.String(), "secret-service-id")
+}
+
+func TestSQLiteSearchSort(t *testing.T) {
+	store := openSQLiteStore(t)
+	ctx := context.Background()
+	const orgID = "00000000-0000-0000-0000-000000000001"
+	p := auth.Principal{OrgID: orgID}
+
+	require.NoError(t, store.CreateService(ctx, &models.Service{ID: "svc-1", OrgID: orgID, Name: "Reconciler", Slug: "reconciler", Description: "Reconciliation, reconciliation reports and reconciliation alerts", Visibility: models.VisibilityPublic}))
+	require.NoError(t, store.CreateService(ctx, &models.Service{ID: "svc-2", OrgID: orgID, Name: "Accounts", Slug: "accounts", Description: "Ledger accounts ready for reconciliation", Visibility: models.VisibilityPublic}))
+	require.NoError(t, store.CreateService(ctx, &models.Service{ID: "svc-3", OrgID: orgID, Name: "Banking", Slug: "banking", Description: "Bank feeds awaiting reconciliation among many other bank tasks", Visibility: models.VisibilityPublic}))
+	require.NoError(t, store.CreateVersion(ctx, orgID, &models.Version{ID: "ver-1", ServiceID: "svc-2", Semver: "1.0.0", Status: "released"}))
+	require.NoError(t, store.CreateVersion(ctx, orgID, &models.Version{ID: "ver-2", ServiceID: "svc-2", Semver: "1.1.0", Status: "released"}))
+	require.NoError(t, store.CreateVersion(ctx, orgID, &models.Version{ID: "ver-3", ServiceID: "svc-3", Semver: "1.0.0", Status: "released"}))
+
+	ids := func(sort string) []string {
+		results, total, err := store.SearchServices(ctx, p, types.SearchParams{Query: "reconciliation", Sort: sort, Page: 1, PageSize: 10})
+		require.NoError(t, err)
+		require.Equal(t, 3, total)
+		ids := make([]string, len(results))
+		for i, s := range results {
+			ids[i] = s.ID
+			assert.NotNil(t, s.Score, "results keep their score whatever the order")
+		}
+		return ids
+	}
+
+	assert.Equal(t, "svc-1", ids(types.SortRelevance)[0])
+	assert.Equal(t, []string{"svc-2", "svc-3", "svc-1"}, ids(types.SortName))
+	assert.Equal(t, []string{"svc-3", "svc-2", "svc-1"}, ids(types.SortCreatedAt))
+	assert.Equal(t, []string{"svc-2", "svc-3", "svc-1"}, ids(types.SortVersionsCount))
+}
+
+func TestSQLiteSearchFuzzy(t *testing.T) {
+	store := openSQLiteStore(t)
+	ctx := context.Background()
+	const orgID = "00000000-0000-0000-0000-000000000001"
+	p := auth.Principal{OrgID: orgID}
+
+	require.NoError(t, store.CreateService(ctx, &models.Service{ID: "svc-1", OrgID: orgID, Name: "Reconciliation Engine", Slug: "reconciliation-engine", Description: "Matches ledgers", Visibility: models.VisibilityPublic}))
+	require.NoError(t, store.CreateService(ctx, &models.Service{ID: "svc-2", OrgID: orgID, Name: "Ledger", Slug: "ledger", Description: "Feeds reconciliation", Visibility: models.VisibilityPublic}))
+
+	// A misspelled name finds nothing without fuzzy matching
+	_, total, err := store.SearchServices(ctx, p, types.SearchParams{Query: "reconcilation", Page: 1, PageSize: 10})
+	require.NoError(t, err)
+	assert.Zero(t, total)
+
+	results, _, err := store.SearchServices(ctx, p, types.SearchParams{Query: "reconcilation", Fuzzy: true, Page: 1, PageSize: 10})
+	require.NoError(t, err)
+	require.NotEmpty(t, results)
+	assert.Equal(t, "svc-1", results[0].ID)
+	require.NotNil(t, results[0].Score)
+
+	// Exact matches still count, and matching both ways ranks first
+	results, _, err = store.SearchServices(ctx, p, types.SearchParams{Query: "reconciliation", Fuzzy: true, Page: 1, PageSize: 10})
+	require.NoError(t, err)
+	require.NotEmpty(t, results)
+	assert.Equal(t, "svc-1", results[0].ID)
+	ids := make([]string, len(results))
+	for i, s := range results {
+		ids[i] = s.ID
+	}
+	assert.Contains(t, ids, "svc-2")
+}
+
+func TestSQLiteSearchFacets(t *testing.T) {
+	store := openSQLiteStore(t)
+	ctx := context.Background()
+	const orgID = "00000000-0000-0000-0000-000000000001"
+	p := auth.Principal{OrgID: orgID}
+
+	require.NoError(t, store.CreateService(ctx, &models.Service{ID: "svc-1", OrgID: orgID, Name: "Billing API", Slug: "billing-api", Description: "Invoices", Visibility: models.VisibilityPublic}))
+	require.NoError(t, store.CreateService(ctx, &models.Service{ID: "svc-2", OrgID: orgID, Name: "Billing Jobs", Slug: "billing-jobs", Description: "Invoice runs", Visibility: models.VisibilityPrivate}))
+	require.NoError(t, store.CreateService(ctx, &models.Service{ID: "svc-3", OrgID: orgID, Name: "Billing Archive", Slug: "billing-archive", Description: "Old invoices", Visibility: models.VisibilityPublic}))
+	require.NoError(t, store.CreateVersion(ctx, orgID, &models.Version{ID: "ver-1", ServiceID: "svc-1", Semver: "1.0.0", Status: "released"}))
+	require.NoError(t, store.CreateVersion(ctx, orgID, &models.Version{ID: "ver-2", ServiceID: "svc-1", Semver: "1.1.0", Status: "released"}))
+	require.NoError(t, store.CreateVersion(ctx, orgID, &models.Version{ID: "ver-3", ServiceID: "svc-1", Semver: "2.0.0", Status: "draft"}))
+	require.NoError(t, store.CreateVersion(ctx, orgID, &models.Version{ID: "ver-4", ServiceID: "svc-2", Semver: "1.0.0", Status: "released"}))
+	_, err := store.DeleteService(ctx, orgID, "svc-3")
+	require.NoError(t, err)
+
+	params := types.SearchParams{Query: "billing", Page: 1, PageSize: 1}
+	facets, err := store.SearchFacets(ctx, p, params, []string{types.FacetVisibility, types.FacetVersionStatus})
+	require.NoError(t, err)
+
+	// Facets count every match, not just the page, and each service once per status
+	assert.Equal(t, []types.FacetBucket{{Value: "private", Count: 1}, {Value: "public", Count: 1}}, facets[types.FacetVisibility])
+	assert.Equal(t, []types.FacetBucket{{Value: "released", Count: 2}, {Value: "draft", Count: 1}}, facets[types.FacetVersionStatus])
+
+	// They respect the principal's visibility; equal counts are ordered by value
+	facets, err = store.SearchFacets(ctx, auth.Principal{OrgID: orgID, UserID: "alice"}, params, []string{types.FacetVersionStatus})
+	require.NoError(t, err)
+	assert.Equal(t, []types.FacetBucket{{Value: "draft", Count: 1}, {Value: "released", Count: 1}}, facets[types.FacetVersionStatus])
+}
+
+func TestSQLiteServiceTags(t *testing.T) {
+	store := openSQLiteStore(t)
+	ctx := context.Background()
+	const orgID = "00000000-0000-0000-0000-000000000001"
+	p := auth.Principal{OrgID: orgID}
+
+	require.NoError(t, store.CreateService(ctx, &models.Service{ID: "svc-1", OrgID: orgID, Name: "Card Payments", Slug: "card-payments", Description: "Settlement of card payments", Visibility: models.VisibilityPublic, Tags: []string{"core", "payments"}}))
+	require.NoError(t, store.CreateService(ctx, &models.Service{ID: "svc-2", OrgID: orgID, Name: "Payouts", Slug: "payouts", Description: "Settlement of payouts", Visibility: models.VisibilityPublic, Tags: []string{"payments"}}))
+	require.NoError(t, store.CreateService(ctx, &models.Service{ID: "svc-3", OrgID: orgID, Name: "Identity", Slug: "identity", Description: "Settlement of nothing", Visibility: models.VisibilityPublic, Tags: []string{"core"}}))
+
+	service, err := store.GetServiceByID(ctx, orgID, "svc-1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"core", "payments"}, service.Tags)
+
+	ids := func(services []models.Service) []string {
+		ids := make([]string, len(services))
+		for i, s := range services {
+			ids[i] = s.ID
+		}
+		return ids
+	}
+	list := func(filter types.TagFilter) []string {
+		services, total, err := store.GetServices(ctx, p, types.PaginationParams{Page: 1, PageSize: 10, Tags: filter})
+		require.NoError(t, err)
+		assert.Equal(t, len(services), total)
+		return ids(services)
+	}
+
+	assert.ElementsMatch(t, []string{"svc-1"}, list(types.TagFilter{Tags: []string{"core", "payments"}}))
+	assert.ElementsMatch(t, []string{"svc-1", "svc-2", "svc-3"}, list(types.TagFilter{Tags: []string{"core", "payments"}, Any: true}))
+	assert.Empty(t, list(types.TagFilter{Tags: []string{"unknown"}}))
+
+	// Search applies the same filter to its matches
+	results, total, err := store.SearchServices(ctx, p, types.SearchParams{Query: "settlement", Page: 1, PageSize: 10, Tags: types.TagFilter{Tags: []string{"payments"}}})
+	require.NoError(t, err)
+	assert.Equal(t, 2, total)
+	assert.ElementsMatch(t, []string{"svc-1", "svc-2"}, ids(results))
+
+	// Updates keep the tags unless given new ones
+	_, err = store.UpdateService(ctx, orgID, "svc-2", &models.Service{Name: "Payouts", Slug: "payouts"})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"svc-1", "svc-2"}, list(types.TagFilter{Tags: []string{"payments"}}))
+	_, err = store.UpdateService(ctx, orgID, "svc-2", &models.Service{Name: "Payouts", Slug: "payouts", Tags: []string{}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"svc-1"}, list(types.TagFilter{Tags: []string{"payments"}}))
+
+	service, err = store.GetServiceByID(ctx, orgID, "svc-2")
+	require.NoError(t, err)
+	assert.Equal(t, []string{}, service.Tags)
+}
+
+func TestSQLiteUpdateServiceResponse(t *testing.T) {
+	captureLogs(t)
+	store := openSQLiteStore(t)
+	const orgID = "00000000-0000-0000-0000-000000000001"
+	const serviceID = "6f1c2f4e-0000-4000-8000-000000000003"
+	_, err := store.UpdateService(context.Background(), orgID, serviceID, &models.Service{Name: "Notifications", Slug: "notifications", Tags: []string{"push"}})
+	require.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) { middleware.SetPrincipal(c, auth.Principal{OrgID: orgID}) })
+	router.PUT("/services/:id", handlers.UpdateService(store, store))
+
+	// Fields left out keep their value, and the response shows the service as
+	// stored rather than echoing the request
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PUT", "/services/"+serviceID, strings.NewReader(`{"name": "Notifications", "slug": "notifications", "description": "Push and email"}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var service models.Service
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &service))
+	assert.Equal(t, "Push and email", service.Description)
+	assert.Equal(t, models.VisibilityPublic, service.Visibility)
+	assert.Equal(t, []string{"push"}, service.Tags)
+	assert.Equal(t, 2, service.VersionsCount)
+	assert.False(t, service.CreatedAt.IsZero())
+	assert.False(t, service.UpdatedAt.IsZero())
+}
+
+func TestSQLiteListFilters(t *testing.T) {
+	store := openSQLiteStore(t)
+	ctx := context.Background()
+	const orgID = "00000000-0000-0000-0000-000000000001"
+	p := auth.Principal{OrgID: orgID}
+
+	require.NoError(t, store.CreateService(ctx, &models.Service{ID: "svc-1", OrgID: orgID, Name: "Payments", Slug: "payments", Visibility: models.VisibilityPublic}))
+	require.NoError(t, store.CreateService(ctx, &models.Service{ID: "svc-2", OrgID: orgID, Name: "Payments Legacy", Slug: "payments-legacy", Visibility: models.VisibilityPrivate}))
+	require.NoError(t, store.CreateVersion(ctx, orgID, &models.Version{ID: "ver-1", ServiceID: "svc-1", Semver: "1.0.0", Status: models.VersionReleased}))
+	require.NoError(t, store.CreateVersion(ctx, orgID, &models.Version{ID: "ver-2", ServiceID: "svc-1", Semver: "2.0.0", Status: models.VersionDraft}))
+
+	list := func(raw string) ([]models.Service, int) {
+		conditions, err := filter.Parse(raw, filter.ServiceFields)
+		require.NoError(t, err)
+		services, total, err := store.GetServices(ctx, p, types.PaginationParams{Page: 1, PageSize: 10, Filter: conditions})
+		require.NoError(t, err)
+		return services, total
+	}
+
+	services, total := list("name=like=PAYMENTS;visibility==private")
+	assert.Equal(t, 1, total)
+	require.Len(t, services, 1)
+	assert.Equal(t, "svc-2", services[0].ID)
+
+	services, _ = list("versions_count>=2;name=like=pay")
+	require.Len(t, services, 1)
+	assert.Equal(t, "svc-1", services[0].ID)
+
+	_, total = list("created_at>=2000-01-01;slug=in=(payments,payments-legacy)")
+	assert.Equal(t, 2, total)
+	_, total = list("created_at<2000-01-01")
+	assert.Zero(t, total)
+
+	conditions, err := filter.Parse("status=in=(released,deprecated)", filter.VersionFields)
+	require.NoError(t, err)
+	versions, total, err := store.GetVersions(ctx, orgID, "svc-1", types.PaginationParams{Page: 1, PageSize: 10, Filter: conditions})
+	require.NoError(t, err)
+	assert.Equal(t, 1, total)
+	require.Len(t, versions, 1)
+	assert.Equal(t, "ver-1", versions[0].ID)
+}
+
+func TestSQLiteListCombinedFilters(t *testing.T) {
+	store := openSQLiteStore(t)
+	ctx := context.Background()
+	const orgID = "00000000-0000-0000-0000-000000000001"
+	p := auth.Principal{OrgID: orgID}
+
+	require.NoError(t, store.CreateService(ctx, &models.Service{ID: "svc-1", OrgID: orgID, Name: "Invoice Renderer", Slug: "invoice-renderer", Description: "Renders invoices", Visibility: models.VisibilityPublic, Tags: []string{"billing"}}))
+	require.NoError(t, store.CreateService(ctx, &models.Service{ID: "svc-2", OrgID: orgID, Name: "Invoice Mailer", Slug: "invoice-mailer", Description: "Mails invoices", Visibility: models.VisibilityPublic, Tags: []string{"billing"}}))
+	require.NoError(t, store.CreateService(ctx, &models.Service{ID: "svc-3", OrgID: orgID, Name: "Invoice Archive", Slug: "invoice-archive", Description: "Stores invoices", Visibility: models.VisibilityPublic}))
+	require.NoError(t, store.CreateVersion(ctx, orgID, &models.Version{ID: "ver-1", ServiceID: "svc-1", Semver: "1.0.0", Status: models.VersionReleased}))
+	require.NoError(t, store.CreateVersion(ctx, orgID, &models.Version{ID: "ver-2", ServiceID: "svc-1", Semver: "2.0.0", Status: models.VersionDraft}))
+	require.NoError(t, store.CreateVersion(ctx, orgID, &models.Version{ID: "ver-3", ServiceID: "svc-2", Semver: "1.0.0", Status: models.VersionReleased}))
+	require.NoError(t, store.CreateVersion(ctx, orgID, &models.Version{ID: "ver-4", ServiceID: "svc-3", Semver: "1.0.0", Status: models.VersionReleased}))
+
+	list := func(query, raw string, tags ...string) []string {
+		conditions, err := filter.Parse(raw, filter.ServiceFields)
+		require.NoError(t, err)
+		services, total, err := store.GetServices(ctx, p, types.PaginationParams{Page: 1, PageSize: 10, Query: query, Filter: conditions, Tags: types.TagFilter{Tags: tags}})
+		require.NoError(t, err)
+		ids := make([]string, len(services))
+		for i, s := range services {
+			ids[i] = s.ID
+		}
+		assert.Equal(t, len(ids), total)
+		return ids
+	}
+
+	// The latest version of svc-1 is a draft
+	assert.Equal(t, []string{"svc-3", "svc-2"}, list("invoices", "latest_status==released"))
+	assert.Equal(t, []string{"svc-1"}, list("invoices", "latest_status==draft"))
+
+	// Search, tags, version status and dates all combine, keeping the newest-first order
+	assert.Equal(t, []string{"svc-2"}, list("invoices", "latest_status==released;created_at>=2000-01-01", "billing"))
+	assert.Empty(t, list("invoices", "created_at<2000-01-01", "billing"))
+	assert.Equal(t, []string{"svc-2", "svc-1"}, list("invoices", "", "billing"))
+}
+
+func TestSQLiteUnicodeNames(t *testing.T) {
+	store := openSQLiteStore(t)
+	ctx := context.Background()
+	const orgID = "00000000-0000-0000-0000-000000000001"
+	p := auth.Principal{OrgID: orgID}
+
+	require.NoError(t, store.CreateService(ctx, &models.Service{ID: "svc-1", OrgID: orgID, Name: "Café API", Slug: "cafe-api", Description: "Crème brûlée orders", Visibility: models.VisibilityPublic}))
+	require.NoError(t, store.CreateService(ctx, &models.Service{ID: "svc-2", OrgID: orgID, Name: "Zürich Ledger", Slug: "zurich-ledger", Visibility: models.VisibilityPublic}))
+
+	// Prefixes match regardless of case and accents, either way round
+	suggestions, err := store.SuggestServices(ctx, p, "cafe", 10)
+	require.NoError(t, err)
+	require.Len(t, suggestions, 1)
+	assert.Equal(t, "svc-1", suggestions[0].ID)
+	suggestions, err = store.SuggestServices(ctx, p, "ZUR", 10)
+	require.NoError(t, err)
+	require.Len(t, suggestions, 1)
+	assert.Equal(t, "svc-2", suggestions[0].ID)
+
+	list := func(raw string) []models.Service {
+		conditions, err := filter.Parse(raw, filter.ServiceFields)
+		require.NoError(t, err)
+		services, _, err := store.GetServices(ctx, p, types.PaginationParams{Page: 1, PageSize: 10, Filter: conditions})
+		require.NoError(t, err)
+		return services
+	}
+	for _, raw := range []string{"name==cafe api", "name==CAFÉ API", "slug==Cafe-API", "description=like=creme"} {
+		services := list(raw)
+		require.Len(t, services, 1, raw)
+		assert.Equal(t, "svc-1", services[0].ID, raw)
+	}
+
+	// Full-text search and its short-word fallback fold accents too
+	for _, query := range []string{"cafe", "CAFÉ", "brulee", "zürich", "ZÜR"} {
+		results, total, err := store.SearchServices(ctx, p, types.SearchParams{Query: query, Page: 1, PageSize: 10})
+		require.NoError(t, err, query)
+		assert.Equal(t, 1, total, query)
+		require.Len(t, results, 1, query)
+	}
+}
+
+func TestSQLiteSearchIncludeArchived(t *testing.T) {
+	store := openSQLiteStore(t)
+	ctx := context.Background()
+	const orgID = "00000000-0000-0000-0000-000000000001"
+	p := auth.Principal{OrgID: orgID}
+
+	require.NoError(t, store.CreateService(ctx, &models.Service{ID: "svc-1", OrgID: orgID, Name: "Ledger", Slug: "ledger", Visibility: models.VisibilityPublic}))
+	require.NoError(t, store.CreateService(ctx, &models.Service{ID: "svc-2", OrgID: orgID, Name: "Ledger Legacy", Slug: "ledger-legacy", Visibility: models.VisibilityPublic}))
+	_, err := store.DeleteService(ctx, orgID, "svc-2")
+	require.NoError(t, err)
+
+	// Deleted services are left out unless asked for, and then carry deleted_at
+	results, total, err := store.SearchServices(ctx, p, types.SearchParams{Query: "ledger", Page: 1, PageSize: 10})
+	require.NoError(t, err)
+	assert.Equal(t, 1, total)
+	require.Len(t, results, 1)
+	assert.Equal(t, "svc-1", results[0].ID)
+
+	params := types.SearchParams{Query: "ledger", Page: 1, PageSize: 10, IncludeDeleted: true}
+	results, total, err = store.SearchServices(ctx, p, params)
+	require.NoError(t, err)
+	assert.Equal(t, 2, total)
+	for _, s := range results {
+		assert.Equal(t, s.ID == "svc-2", s.DeletedAt != nil, s.ID)
+	}
+
+	facets, err := store.SearchFacets(ctx, p, params, []string{types.FacetVisibility})
+	require.NoError(t, err)
+	assert.Equal(t, []types.FacetBucket{{Value: models.VisibilityPublic, Count: 2}}, facets[types.FacetVisibility])
+}
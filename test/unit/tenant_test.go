@@ -0,0 +1,203 @@
+package unit
+
+import (
+	"context"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yashjain/konnect/internal/auth"
+	"github.com/yashjain/konnect/internal/database"
+	"github.com/yashjain/konnect/internal/models"
+	"github.com/yashjain/konnect/internal/search"
+	"github.com/yashjain/konnect/pkg/types"
+)
+
+func TestScopeQuery(t *testing.T) {
+	tests := []struct {
+		name         string
+		query        string
+		args         []interface{}
+		expectedSQL  string
+		expectedArgs []interface{}
+	}{
+		{
+			name:         "where clause",
+			query:        "SELECT * FROM services WHERE id = ? AND {{tenant}} LIMIT ?",
+			args:         []interface{}{"svc", 10},
+			expectedSQL:  "SELECT * FROM services WHERE id = ? AND org_id = ? LIMIT ?",
+			expectedArgs: []interface{}{"svc", "org", 10},
+		},
+		{
+			name:         "aliased",
+			query:        "SELECT * FROM versions v JOIN services s ON s.id = v.service_id WHERE {{tenant:s}}",
+			expectedSQL:  "SELECT * FROM versions v JOIN services s ON s.id = v.service_id WHERE s.org_id = ?",
+			expectedArgs: []interface{}{"org"},
+		},
+		{
+			name:         "insert value",
+			query:        "INSERT INTO services (id, org_id, name) VALUES (?, {{tenant_id}}, ?)",
+			args:         []interface{}{"svc", "name"},
+			expectedSQL:  "INSERT INTO services (id, org_id, name) VALUES (?, ?, ?)",
+			expectedArgs: []interface{}{"svc", "org", "name"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, args, err := database.ScopeQuery("org", tt.query, tt.args)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedSQL, query)
+			assert.Equal(t, tt.expectedArgs, args)
+		})
+	}
+}
+
+func TestScopeQueryFailsClosed(t *testing.T) {
+	_, _, err := database.ScopeQuery("", "SELECT * FROM services WHERE {{tenant}}", nil)
+	assert.ErrorIs(t, err, database.ErrMissingTenant)
+
+	_, _, err = database.ScopeQuery("org", "SELECT * FROM services", nil)
+	assert.ErrorIs(t, err, database.ErrMissingTenantFilter)
+}
+
+// tenantTableRef matches SQL referencing tables whose rows belong to a single organization
+var tenantTableRef = regexp.MustCompile(`(?i)\b(FROM|JOIN|INTO|UPDATE)\s+(services|versions|service_acls|users|teams|team_members|outbox_events|search_queries|webhook_subscriptions|webhook_deliveries|kong_syncs|github_repositories|notification_preferences|service_subscribers|email_notifications|categories|deployments|consumers|version_specs|current_deployments|service_categories|service_tags)\b`)
+
+// TestTenantScopedQueries fails if any SQL in the database package touches a
+// tenant-owned table without a tenant placeholder. Functions that must run before
+// a tenant is known opt out with a "tenant:exempt <reason>" doc comment.
+func TestTenantScopedQueries(t *testing.T) {
+	dir := filepath.Join("..", "..", "internal", "database")
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, nil, parser.ParseComments)
+	require.NoError(t, err)
+
+	for _, pkg := range pkgs {
+		for _, file := range pkg.Files {
+			for _, decl := range file.Decls {
+				fn, ok := decl.(*ast.FuncDecl)
+				if !ok || fn.Body == nil {
+					continue
+				}
+				if fn.Doc != nil && strings.Contains(fn.Doc.Text(), "tenant:exempt") {
+					continue
+				}
+
+				ast.Inspect(fn.Body, func(n ast.Node) bool {
+					lit, ok := n.(*ast.BasicLit)
+					if !ok || lit.Kind != token.STRING {
+						return true
+					}
+					sql, err := strconv.Unquote(lit.Value)
+					if err != nil || !tenantTableRef.MatchString(sql) {
+						return true
+					}
+					assert.Contains(t, sql, "{{tenant",
+						"%s: %s queries a tenant table without a tenant filter", fset.Position(lit.Pos()), fn.Name.Name)
+					return true
+				})
+			}
+		}
+	}
+}
+
+// leakyBackend is a search.Backend whose index ignores organizations, returning
+// every service it was given
+type leakyBackend struct {
+	ids []string
+}
+
+func (b *leakyBackend) Index(ctx context.Context, doc search.Document) error { return nil }
+func (b *leakyBackend) Delete(ctx context.Context, id string) error          { return nil }
+func (b *leakyBackend) Prune(ctx context.Context, before time.Time) error    { return nil }
+
+func (b *leakyBackend) Search(ctx context.Context, p auth.Principal, params types.SearchParams) (search.Result, error) {
+	result := search.Result{Total: len(b.ids)}
+	for _, id := range b.ids {
+		result.Hits = append(result.Hits, search.Hit{ID: id, Score: 1})
+	}
+	return result, nil
+}
+
+func TestSearchIsTenantScoped(t *testing.T) {
+	store := openSQLiteStore(t)
+	ctx := context.Background()
+	orgs := map[string]string{
+		"00000000-0000-0000-0000-0000000000a1": "tenant-a",
+		"00000000-0000-0000-0000-0000000000a2": "tenant-b",
+	}
+	for orgID, id := range orgs {
+		require.NoError(t, store.CreateOrganization(ctx, &models.Organization{ID: orgID, Name: id, Slug: id}))
+		require.NoError(t, store.CreateService(ctx, &models.Service{ID: id, OrgID: orgID, Name: "Shared Ledger", Slug: "shared-ledger", Description: "Bookkeeping", Visibility: models.VisibilityPublic, Tags: []string{"finance"}}))
+		require.NoError(t, store.CreateService(ctx, &models.Service{ID: id + "-old", OrgID: orgID, Name: "Shared Ledger Legacy", Slug: "shared-ledger-legacy", Visibility: models.VisibilityPublic}))
+		_, err := store.DeleteService(ctx, orgID, id+"-old")
+		require.NoError(t, err)
+	}
+
+	searches := []types.SearchParams{
+		{Query: "ledger"},
+		{Query: "+shared +ledger", Mode: types.SearchModeBoolean},
+		{Query: "ledgr", Fuzzy: true},
+		{Query: "bookkeeping", Tags: types.TagFilter{Tags: []string{"finance"}}},
+		{Mode: types.SearchModeBrowse},
+		{Query: "ledger", IncludeDeleted: true},
+	}
+
+	for orgID, id := range orgs {
+		p := auth.Principal{OrgID: orgID}
+		own := map[string]bool{id: true, id + "-old": true}
+
+		for _, params := range searches {
+			params.Page, params.PageSize = 1, 10
+			results, total, err := store.SearchServices(ctx, p, params)
+			require.NoError(t, err, params)
+			require.NotEmpty(t, results, params)
+			assert.Equal(t, len(results), total, params)
+			for _, s := range results {
+				assert.True(t, own[s.ID], "%s found %s for %+v", orgID, s.ID, params)
+			}
+
+			facets, err := store.SearchFacets(ctx, p, params, []string{types.FacetVisibility})
+			require.NoError(t, err)
+			assert.Equal(t, []types.FacetBucket{{Value: models.VisibilityPublic, Count: total}}, facets[types.FacetVisibility], params)
+		}
+
+		suggestions, err := store.SuggestServices(ctx, p, "shared", 10)
+		require.NoError(t, err)
+		require.Len(t, suggestions, 1)
+		assert.Equal(t, id, suggestions[0].ID)
+
+		services, total, err := store.GetServices(ctx, p, types.PaginationParams{Page: 1, PageSize: 10, Query: "ledger"})
+		require.NoError(t, err)
+		assert.Equal(t, 1, total)
+		require.Len(t, services, 1)
+		assert.Equal(t, id, services[0].ID)
+
+		// Hits of other organizations are dropped even if an external index returns them
+		repo := search.NewIndexedRepository(store, &leakyBackend{ids: []string{"tenant-a", "tenant-b"}})
+		results, _, err := repo.SearchServices(ctx, p, types.SearchParams{Query: "ledger", Page: 1, PageSize: 10})
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		assert.Equal(t, id, results[0].ID)
+	}
+
+	// An organization without services finds nothing, whatever it searches for
+	p := auth.Principal{OrgID: "00000000-0000-0000-0000-0000000000a3"}
+	for _, params := range searches {
+		params.Page, params.PageSize = 1, 10
+		results, total, err := store.SearchServices(ctx, p, params)
+		require.NoError(t, err)
+		assert.Empty(t, results, params)
+		assert.Zero(t, total, params)
+	}
+}
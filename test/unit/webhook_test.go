@@ -0,0 +1,65 @@
+package unit
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yashjain/konnect/internal/webhook"
+)
+
+// memoryGuard is a ReplayGuard backed by a map
+type memoryGuard map[string]bool
+
+func (g memoryGuard) Seen(id string) bool {
+	seen := g[id]
+	g[id] = true
+	return seen
+}
+
+func TestWebhookSignatureVerify(t *testing.T) {
+	payload := []byte(`{"event":"version.created"}`)
+	now := time.Unix(1700000000, 0)
+	header := webhook.Sign("s3cret", now, payload)
+
+	tests := []struct {
+		name     string
+		secret   string
+		payload  []byte
+		now      time.Time
+		expected error
+	}{
+		{name: "valid", secret: "s3cret", payload: payload, now: now},
+		{name: "wrong secret", secret: "other", payload: payload, now: now, expected: webhook.ErrInvalidSignature},
+		{name: "tampered payload", secret: "s3cret", payload: []byte(`{}`), now: now, expected: webhook.ErrInvalidSignature},
+		{name: "stale", secret: "s3cret", payload: payload, now: now.Add(10 * time.Minute), expected: webhook.ErrTimestampOutOfRange},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := webhook.Verify(tt.secret, header, tt.payload, webhook.DefaultTolerance, tt.now, "", nil)
+			if tt.expected == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, tt.expected)
+			}
+		})
+	}
+}
+
+func TestWebhookReplayProtection(t *testing.T) {
+	payload := []byte(`{}`)
+	req, err := http.NewRequest("POST", "https://example.com/hook", nil)
+	require.NoError(t, err)
+	webhook.SignRequest(req, "s3cret", "delivery-1", payload)
+
+	guard := memoryGuard{}
+	header := req.Header.Get(webhook.HeaderSignature)
+	id := req.Header.Get(webhook.HeaderID)
+
+	require.NoError(t, webhook.Verify("s3cret", header, payload, webhook.DefaultTolerance, time.Now(), id, guard))
+	assert.ErrorIs(t, webhook.Verify("s3cret", header, payload, webhook.DefaultTolerance, time.Now(), id, guard), webhook.ErrReplayed)
+}
@@ -0,0 +1,258 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yashjain/konnect/internal/auth"
+	"github.com/yashjain/konnect/internal/handlers"
+	"github.com/yashjain/konnect/internal/middleware"
+	"github.com/yashjain/konnect/internal/models"
+	"github.com/yashjain/konnect/internal/outbox"
+	"github.com/yashjain/konnect/internal/repository"
+	"github.com/yashjain/konnect/internal/webhook"
+)
+
+// setupWebhookRouter serves the webhook subscription routes backed by repo for requests made by principal
+func setupWebhookRouter(repo repository.WebhookRepository, sender handlers.WebhookSender, principal auth.Principal) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		middleware.SetPrincipal(c, principal)
+	})
+	router.GET("/webhooks", handlers.GetWebhookSubscriptions(repo))
+	router.POST("/webhooks", handlers.CreateWebhookSubscription(repo))
+	router.PUT("/webhooks/:id", handlers.UpdateWebhookSubscription(repo))
+	router.DELETE("/webhooks/:id", handlers.DeleteWebhookSubscription(repo))
+	router.GET("/webhooks/:id/deliveries", handlers.GetWebhookDeliveries(repo))
+	router.POST("/webhooks/:id/test", handlers.TestWebhookSubscription(repo, sender))
+	return router
+}
+
+func TestWebhookSubscriptions(t *testing.T) {
+	captureLogs(t)
+	store := openSQLiteStore(t)
+	ctx := context.Background()
+	const orgID = "00000000-0000-0000-0000-000000000001"
+	const serviceID = "6f1c2f4e-0000-4000-8000-000000000001"
+
+	// The receiver fails the first delivery, then accepts
+	var calls atomic.Int32
+	var secret string
+	received := make(chan models.Event, 4)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		assert.NoError(t, webhook.Verify(secret, r.Header.Get(webhook.HeaderSignature), body, time.Minute, time.Now(), "", nil))
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		var event models.Event
+		assert.NoError(t, json.Unmarshal(body, &event))
+		assert.Equal(t, event.Type, r.Header.Get(webhook.HeaderEvent))
+		received <- event
+	}))
+	defer server.Close()
+
+	dispatcher := outbox.NewDispatcher(store, store, "", time.Second, time.Second, 0, outbox.RetryPolicy{MaxAttempts: 3})
+	router := setupWebhookRouter(store, dispatcher, auth.Principal{OrgID: orgID})
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Only organization-wide principals manage subscriptions
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/webhooks", nil)
+	setupWebhookRouter(store, dispatcher, auth.Principal{OrgID: orgID, UserID: "alice"}).ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	assert.Equal(t, http.StatusBadRequest, do("POST", "/webhooks", `{"url":"ftp://ci.example.com","event_types":["version.created"]}`).Code)
+	assert.Equal(t, http.StatusBadRequest, do("POST", "/webhooks", `{"url":"https://ci.example.com","event_types":["version.archived"]}`).Code)
+	assert.Equal(t, http.StatusBadRequest, do("POST", "/webhooks", `{"url":"https://ci.example.com","event_types":[]}`).Code)
+
+	// The generated secret is only returned at creation
+	w = do("POST", "/webhooks", `{"url":"`+server.URL+`","event_types":["version.created","version.created"]}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var sub models.WebhookSubscription
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &sub))
+	assert.NotEmpty(t, sub.Secret)
+	assert.Equal(t, []string{models.EventVersionCreated}, sub.EventTypes)
+	require.NotNil(t, sub.Active)
+	assert.True(t, *sub.Active)
+	secret = sub.Secret
+
+	w = do("GET", "/webhooks", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), secret)
+
+	// A version created afterwards is queued for the subscription, but a service update is not
+	require.NoError(t, store.CreateVersion(ctx, orgID, &models.Version{ID: "ver-hook", ServiceID: serviceID, Semver: "9.0.0", Status: "released"}))
+	_, err := store.UpdateService(ctx, orgID, serviceID, &models.Service{Name: "Locate Us", Slug: "locate-us"})
+	require.NoError(t, err)
+
+	// The failed attempt is retried on the next flush, with no delay configured
+	delivered, err := dispatcher.Flush(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, delivered)
+	delivered, err = dispatcher.Flush(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, delivered)
+
+	event := <-received
+	assert.Equal(t, models.EventVersionCreated, event.Type)
+	assert.Equal(t, "ver-hook", event.SubjectID)
+
+	w = do("GET", "/webhooks/"+sub.ID+"/deliveries", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var history struct {
+		Data []models.WebhookDelivery `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &history))
+	require.Len(t, history.Data, 1)
+	assert.Equal(t, models.DeliveryDelivered, history.Data[0].Status)
+	assert.Equal(t, 2, history.Data[0].Attempts)
+	assert.Equal(t, http.StatusOK, history.Data[0].ResponseStatus)
+	assert.NotNil(t, history.Data[0].DeliveredAt)
+
+	// A test delivery is sent straight away and recorded
+	w = do("POST", "/webhooks/"+sub.ID+"/test", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, models.EventWebhookTest, (<-received).Type)
+	require.NoError(t, json.Unmarshal(do("GET", "/webhooks/"+sub.ID+"/deliveries", "").Body.Bytes(), &history))
+	assert.Len(t, history.Data, 2)
+
+	// Paused subscriptions keep their deliveries until resumed
+	w = do("PUT", "/webhooks/"+sub.ID, `{"url":"`+server.URL+`","event_types":["version.created"],"active":false}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.NoError(t, store.CreateVersion(ctx, orgID, &models.Version{ID: "ver-paused", ServiceID: serviceID, Semver: "9.1.0", Status: "released"}))
+	delivered, err = dispatcher.Flush(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, delivered)
+
+	w = do("PUT", "/webhooks/"+sub.ID, `{"url":"`+server.URL+`","event_types":["version.created"],"active":true}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	delivered, err = dispatcher.Flush(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, delivered)
+	assert.Equal(t, "ver-paused", (<-received).SubjectID)
+
+	// Deleting a subscription removes its history
+	assert.Equal(t, http.StatusOK, do("DELETE", "/webhooks/"+sub.ID, "").Code)
+	assert.Equal(t, http.StatusNotFound, do("GET", "/webhooks/"+sub.ID+"/deliveries", "").Code)
+}
+
+func TestWebhookDeliveryGivesUpAfterMaxAttempts(t *testing.T) {
+	captureLogs(t)
+	store := openSQLiteStore(t)
+	ctx := context.Background()
+	const orgID = "00000000-0000-0000-0000-000000000001"
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	active := true
+	sub := models.WebhookSubscription{ID: "sub-1", OrgID: orgID, URL: server.URL, Secret: "0123456789abcdef", EventTypes: []string{models.EventVersionCreated}, Active: &active}
+	require.NoError(t, store.CreateWebhookSubscription(ctx, &sub))
+	require.NoError(t, store.CreateVersion(ctx, orgID, &models.Version{ID: "ver-fail", ServiceID: "6f1c2f4e-0000-4000-8000-000000000002", Semver: "1.0.1", Status: "released"}))
+
+	dispatcher := outbox.NewDispatcher(store, store, "", time.Second, time.Second, 0, outbox.RetryPolicy{MaxAttempts: 2})
+	for i := 0; i < 3; i++ {
+		_, err := dispatcher.Flush(ctx)
+		require.NoError(t, err)
+	}
+
+	deliveries, err := store.GetWebhookDeliveries(ctx, orgID, sub.ID, 10)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	assert.Equal(t, models.DeliveryFailed, deliveries[0].Status)
+	assert.Equal(t, 2, deliveries[0].Attempts)
+	assert.Equal(t, http.StatusInternalServerError, deliveries[0].ResponseStatus)
+	assert.Contains(t, deliveries[0].LastError, "500")
+	assert.Nil(t, deliveries[0].NextAttemptAt)
+}
+
+func TestChatWebhooks(t *testing.T) {
+	captureLogs(t)
+	store := openSQLiteStore(t)
+	ctx := context.Background()
+	const orgID = "00000000-0000-0000-0000-000000000001"
+	const serviceID = "6f1c2f4e-0000-4000-8000-000000000003"
+
+	received := make(chan map[string]interface{}, 4)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		received <- body
+	}))
+	defer server.Close()
+
+	dispatcher := outbox.NewDispatcher(store, store, "https://portal.example.com/services/{slug}", time.Second, time.Second, 0, outbox.RetryPolicy{MaxAttempts: 3})
+	router := setupWebhookRouter(store, dispatcher, auth.Principal{OrgID: orgID})
+	create := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/webhooks", strings.NewReader(body))
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusBadRequest, create(`{"url":"`+server.URL+`","format":"discord","event_types":["version.released"]}`).Code)
+	assert.Equal(t, http.StatusBadRequest, create(`{"url":"`+server.URL+`","format":"slack","template":"{{.Nope}}","event_types":["version.released"]}`).Code)
+	assert.Equal(t, http.StatusBadRequest, create(`{"url":"`+server.URL+`","format":"slack","template":"{{if}}","event_types":["version.released"]}`).Code)
+
+	w := create(`{"url":"` + server.URL + `","format":"slack","event_types":["version.released"]}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	w = create(`{"url":"` + server.URL + `","format":"teams","template":"{{link .URL .Service.Name}} {{.Version.Semver}} is {{.Version.Status}}","event_types":["version.deprecated"]}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var teams models.WebhookSubscription
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &teams))
+	assert.Equal(t, models.WebhookFormatTeams, teams.Format)
+
+	// Releasing a draft posts a Slack message, escaped and linking to the service
+	version := &models.Version{ID: "ver-chat", ServiceID: serviceID, Semver: "2.0.0", Status: models.VersionDraft}
+	require.NoError(t, store.CreateVersion(ctx, orgID, version))
+	_, err := store.UpdateVersion(ctx, orgID, serviceID, version.ID, &models.Version{Status: models.VersionReleased, Changelog: "<!channel> Batching"})
+	require.NoError(t, err)
+	delivered, err := dispatcher.Flush(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, delivered)
+	assert.Equal(t, map[string]interface{}{
+		"text": "Version 2.0.0 of <https://portal.example.com/services/notifications|Notifications> was released: &lt;!channel&gt; Batching",
+	}, <-received)
+
+	// Updating a released version does not release it again; deprecating it posts to Teams
+	_, err = store.UpdateVersion(ctx, orgID, serviceID, version.ID, &models.Version{Status: models.VersionReleased, Changelog: "Batching"})
+	require.NoError(t, err)
+	_, err = store.UpdateVersion(ctx, orgID, serviceID, version.ID, &models.Version{Status: models.VersionDeprecated, Changelog: "Batching"})
+	require.NoError(t, err)
+	delivered, err = dispatcher.Flush(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, delivered)
+	card := <-received
+	assert.Equal(t, "message", card["type"])
+	text := card["attachments"].([]interface{})[0].(map[string]interface{})["content"].(map[string]interface{})["body"].([]interface{})[0].(map[string]interface{})["text"]
+	assert.Equal(t, "[Notifications](https://portal.example.com/services/notifications) 2.0.0 is deprecated", text)
+
+	// Test deliveries render the test message
+	w = httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/webhooks/"+teams.ID+"/test", nil)
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "message", (<-received)["type"])
+}